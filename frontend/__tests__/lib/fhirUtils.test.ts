@@ -35,6 +35,14 @@ describe('codingToMessage', () => {
         const codings = [{code: 'E0004'}]
         expect(codingToMessage(codings)).toStrictEqual([MessageType.InvalidPhone]);
     });
+    it('no BSN', () => {
+        const codings = [{code: 'E0005'}]
+        expect(codingToMessage(codings)).toStrictEqual([MessageType.NoBSN]);
+    });
+    it('no birth date', () => {
+        const codings = [{code: 'E0006'}]
+        expect(codingToMessage(codings)).toStrictEqual([MessageType.NoBirthDate]);
+    });
     it('unknown code', () => {
         const codings = [{code: '1'}]
         expect(codingToMessage(codings)).toStrictEqual(['Er is een onbekende fout opgetreden. Probeer het later opnieuw of neem contact op met de systeembeheerder: functioneelbeheer@zorgbijjou.nl. Vermeld daarbij de volgende code: 1']);
@@ -376,6 +376,12 @@ export function codingToMessage(codings: Coding[]): String[] {
             case "E0004":
                 messages.push(MessageType.InvalidPhone);
                 break;
+            case "E0005":
+                messages.push(MessageType.NoBSN);
+                break;
+            case "E0006":
+                messages.push(MessageType.NoBirthDate);
+                break;
             default:
                 messages.push(MessageType.Unknown + coding.code );
                 break;
@@ -386,8 +392,10 @@ export function codingToMessage(codings: Coding[]): String[] {
 
 export enum MessageType {
     InvalidEmail = "Ongeldig e-mailadres. Controleer het e-mailadres van de patiënt in het EPD en probeer het opnieuw.",
-    InvalidPhone = "Ongeldig telefoonnummer. Voor de aanmelding is minstens één mobiel nummer uit Nederland, België of Duitsland nodig. Controleer de telefoonnummers in het EPD en probeer het opnieuw.",
+    InvalidPhone = "Ongeldig telefoonnummer. Geen van de telefoonnummers van de patiënt voldoet aan het formaat dat voor deze aanmelding nodig is. Controleer de telefoonnummers in het EPD en probeer het opnieuw.",
     NoEmail = "Er is geen e-mailadres van de patiënt gevonden. Dit is nodig voor de aanmelding. Voeg het e-mailadres toe in het EPD en probeer het opnieuw.",
     NoPhone = "Er is geen telefoonnummer van de patiënt gevonden. Dit is nodig voor de aanmelding. Voeg het telefoonnummer toe in het EPD en probeer het opnieuw.",
+    NoBSN = "Er is geen BSN van de patiënt gevonden. Dit is nodig voor de aanmelding. Voeg het BSN toe in het EPD en probeer het opnieuw.",
+    NoBirthDate = "Er is geen geboortedatum van de patiënt gevonden. Dit is nodig voor de aanmelding. Voeg de geboortedatum toe in het EPD en probeer het opnieuw.",
     Unknown = "Er is een onbekende fout opgetreden. Probeer het later opnieuw of neem contact op met de systeembeheerder: functioneelbeheer@zorgbijjou.nl. Vermeld daarbij de volgende code: "
 }
\ No newline at end of file
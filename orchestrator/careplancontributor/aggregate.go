@@ -0,0 +1,317 @@
+package careplancontributor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/careplanservice"
+	"github.com/SanteonNL/orca/orchestrator/cmd/profile"
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/debug"
+	"github.com/SanteonNL/orca/orchestrator/lib/logging"
+	"github.com/SanteonNL/orca/orchestrator/lib/must"
+	"github.com/SanteonNL/orca/orchestrator/lib/otel"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// defaultAggregatedSearchTimeout is the time each CareTeam member's SCP-node gets to respond to an aggregated search, if not configured.
+const defaultAggregatedSearchTimeout = 10 * time.Second
+
+// isAggregatedSearchRequest returns whether the external FHIR proxy request should be executed at all CareTeam members of the CarePlan:
+// which is the case if the X-Scp-Context header is set, but the request doesn't target a specific SCP-node.
+func isAggregatedSearchRequest(request *http.Request) bool {
+	return request.Header.Get(carePlanURLHeaderKey) != "" &&
+		request.Header.Get(scpEntityIdentifierHeaderKey) == "" &&
+		request.Header.Get(scpFHIRBaseURL) == ""
+}
+
+// aggregatedSearchNode is a CareTeam member's SCP-node that's queried in an aggregated search.
+type aggregatedSearchNode struct {
+	identifier  fhir.Identifier
+	fhirBaseURL *url.URL
+	result      fhir.Bundle
+	err         error
+}
+
+func (s *Service) handleAggregatedSearch(httpResponse http.ResponseWriter, httpRequest *http.Request) {
+	result, err := s.aggregatedSearch(httpRequest)
+	if err != nil {
+		coolfhir.WriteOperationOutcomeFromError(httpRequest.Context(), err, fmt.Sprintf("CarePlanContributor/AggregatedSearch %s", httpRequest.URL.Path), httpResponse)
+		return
+	}
+	coolfhir.SendResponse(httpResponse, http.StatusOK, result)
+}
+
+// aggregatedSearch executes a FHIR search at the SCP-nodes of all active members of the CareTeam of the CarePlan in the X-Scp-Context header,
+// and merges the results into a single searchset Bundle. The meta.source of each resource is set to its URL at the SCP-node it was retrieved from.
+// If SCP-nodes fail or don't respond in time, an OperationOutcome listing them is added to the Bundle (with search mode 'outcome').
+func (s *Service) aggregatedSearch(httpRequest *http.Request) (*fhir.Bundle, error) {
+	ctx, span := tracer.Start(
+		httpRequest.Context(),
+		debug.GetFullCallerName(),
+		trace.WithSpanKind(trace.SpanKindServer),
+	)
+	defer span.End()
+
+	if httpRequest.Method != http.MethodGet {
+		return nil, otel.Error(span, coolfhir.BadRequest("aggregated search only supports GET requests"))
+	}
+	resourceType := httpRequest.PathValue("rest")
+	if resourceType == "" || strings.Contains(resourceType, "/") {
+		return nil, otel.Error(span, coolfhir.BadRequest("aggregated search only supports searching on a resource type (e.g. GET /Observation?patient=...)"))
+	}
+	carePlanURL := httpRequest.Header.Get(carePlanURLHeaderKey)
+	span.SetAttributes(
+		attribute.String(otel.FHIRResourceType, resourceType),
+		attribute.String("fhir.careplan_url", carePlanURL),
+	)
+
+	carePlan, err := s.readCarePlanForAggregatedSearch(ctx, carePlanURL)
+	if err != nil {
+		return nil, otel.Error(span, err)
+	}
+	careTeam, err := coolfhir.CareTeamFromCarePlan(carePlan)
+	if err != nil {
+		return nil, otel.Error(span, coolfhir.BadRequest("specified SCP context header does not refer to a CarePlan with a CareTeam: %s", err.Error()))
+	}
+
+	// Resolve the FHIR base URLs of the active CareTeam members, and query them in parallel
+	var nodes []*aggregatedSearchNode
+	now := time.Now()
+	for _, participant := range careTeam.Participant {
+		if participant.Member == nil || participant.Member.Identifier == nil {
+			continue
+		}
+		if active, _ := coolfhir.ValidateCareTeamParticipantPeriod(participant, now); !active {
+			continue
+		}
+		if slicesContainsIdentifier(nodes, *participant.Member.Identifier) {
+			continue
+		}
+		node := &aggregatedSearchNode{identifier: *participant.Member.Identifier}
+		node.fhirBaseURL, node.err = s.lookupFHIRBaseURL(ctx, node.identifier)
+		nodes = append(nodes, node)
+	}
+	timeout := s.config.AggregatedSearchTimeout
+	if timeout <= 0 {
+		timeout = defaultAggregatedSearchTimeout
+	}
+	query := httpRequest.URL.Query()
+	var wg sync.WaitGroup
+	for _, node := range nodes {
+		if node.err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func(node *aggregatedSearchNode) {
+			defer wg.Done()
+			nodeCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			node.err = s.searchAggregatedSearchNode(nodeCtx, node, resourceType, query, carePlanURL)
+		}(node)
+	}
+	wg.Wait()
+
+	result := mergeAggregatedSearchResults(nodes)
+	span.SetAttributes(
+		attribute.Int("aggregated_search.nodes", len(nodes)),
+		attribute.Int(otel.FHIRBundleEntryCount, len(result.Entry)),
+	)
+	span.SetStatus(codes.Ok, "")
+	return &result, nil
+}
+
+// readCarePlanForAggregatedSearch reads the CarePlan referenced by the X-Scp-Context header from its Care Plan Service.
+func (s *Service) readCarePlanForAggregatedSearch(ctx context.Context, carePlanURL string) (*fhir.CarePlan, error) {
+	if _, err := s.parseFHIRBaseURL(carePlanURL); err != nil {
+		return nil, coolfhir.BadRequest("specified SCP context header is not a valid URL")
+	}
+	cpsBaseURL, carePlanRef, err := coolfhir.ParseExternalLiteralReference(carePlanURL, "CarePlan")
+	if err != nil {
+		return nil, coolfhir.BadRequest("specified SCP context header does not refer to a CarePlan")
+	}
+	tenant, err := tenants.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var cpsClient fhirclient.Client
+	if localCPSURL := tenant.URL(s.orcaPublicURL, careplanservice.FHIRBaseURL); s.cpsEnabled && cpsBaseURL.String() == localCPSURL.String() {
+		cpsClient = fhirClientFactory(localCPSURL, s.httpClientForLocalCPS(tenant))
+	} else {
+		cpsClient, _, err = s.createFHIRClientForURL(ctx, cpsBaseURL)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var carePlan fhir.CarePlan
+	if err := cpsClient.ReadWithContext(ctx, carePlanRef, &carePlan); err != nil {
+		var outcomeError fhirclient.OperationOutcomeError
+		if errors.As(err, &outcomeError) {
+			return nil, coolfhir.NewErrorWithCode(outcomeError.Error(), outcomeError.HttpStatusCode)
+		}
+		return nil, fmt.Errorf("failed to read CarePlan (url=%s): %w", carePlanURL, err)
+	}
+	return &carePlan, nil
+}
+
+// lookupFHIRBaseURL resolves the FHIR base URL of the SCP-node of the given care organization through the CSD.
+func (s *Service) lookupFHIRBaseURL(ctx context.Context, identifier fhir.Identifier) (*url.URL, error) {
+	endpoints, err := s.profile.CsdDirectory().LookupEndpoint(ctx, &identifier, profile.FHIRBaseURLEndpointName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lookup FHIR base URL: %w", err)
+	}
+	if len(endpoints) != 1 {
+		return nil, fmt.Errorf("expected one FHIR base URL, got %d", len(endpoints))
+	}
+	fhirBaseURL, err := s.parseFHIRBaseURL(endpoints[0].Address)
+	if err != nil {
+		return nil, fmt.Errorf("registered FHIR base URL is invalid: %w", err)
+	}
+	return fhirBaseURL, nil
+}
+
+func (s *Service) searchAggregatedSearchNode(ctx context.Context, node *aggregatedSearchNode, resourceType string, query url.Values, carePlanURL string) error {
+	ctx, span := tracer.Start(
+		ctx,
+		debug.GetFullCallerName(),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String(otel.FHIRBaseURL, node.fhirBaseURL.String()),
+			attribute.String(otel.FHIRResourceType, resourceType),
+		),
+	)
+	defer span.End()
+
+	fhirClient, _, err := s.createFHIRClientForIdentifier(ctx, node.fhirBaseURL, node.identifier)
+	if err != nil {
+		return otel.Error(span, err)
+	}
+	headers := http.Header{}
+	headers.Set(carePlanURLHeaderKey, carePlanURL)
+	if err := fhirClient.SearchWithContext(ctx, resourceType, query, &node.result, fhirclient.RequestHeaders(headers)); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = errors.New("SCP-node didn't respond in time")
+		}
+		slog.WarnContext(ctx, "Aggregated search: SCP-node search failed",
+			slog.String(logging.FieldIdentifier, coolfhir.ToString(node.identifier)),
+			slog.String(logging.FieldUrl, node.fhirBaseURL.String()),
+			slog.String(logging.FieldError, err.Error()),
+		)
+		return otel.Error(span, err)
+	}
+	span.SetAttributes(attribute.Int(otel.FHIRBundleEntryCount, len(node.result.Entry)))
+	span.SetStatus(codes.Ok, "")
+	return nil
+}
+
+// mergeAggregatedSearchResults merges the search results of the SCP-nodes into a single searchset Bundle.
+// Resources get an absolute fullUrl and meta.source pointing to the SCP-node they were retrieved from.
+// Failed SCP-nodes are reported in an OperationOutcome entry.
+// Only the first page of each SCP-node's results is included: the pagination links of the SCP-nodes can't be combined into links of the merged Bundle,
+// so the merged Bundle has none. SCP-nodes that returned more pages are reported in the OperationOutcome, so the caller knows the result is incomplete.
+func mergeAggregatedSearchResults(nodes []*aggregatedSearchNode) fhir.Bundle {
+	result := fhir.Bundle{
+		Type:  fhir.BundleTypeSearchset,
+		Entry: []fhir.BundleEntry{},
+	}
+	var issues []fhir.OperationOutcomeIssue
+	for _, node := range nodes {
+		if node.err != nil {
+			diagnostics := fmt.Sprintf("%s: %s", coolfhir.ToString(node.identifier), node.err.Error())
+			if node.fhirBaseURL != nil {
+				diagnostics = fmt.Sprintf("%s (%s): %s", coolfhir.ToString(node.identifier), node.fhirBaseURL.String(), node.err.Error())
+			}
+			issues = append(issues, fhir.OperationOutcomeIssue{
+				Severity:    fhir.IssueSeverityWarning,
+				Code:        fhir.IssueTypeIncomplete,
+				Diagnostics: to.Ptr(diagnostics),
+			})
+			continue
+		}
+		for _, entry := range node.result.Entry {
+			if entry.Search != nil && entry.Search.Mode != nil && *entry.Search.Mode == fhir.SearchEntryModeOutcome {
+				// Skip the SCP-node's own OperationOutcome
+				continue
+			}
+			var resource coolfhir.Resource
+			if err := json.Unmarshal(entry.Resource, &resource); err != nil || resource.Type == "" {
+				continue
+			}
+			resourceURL := node.fhirBaseURL.JoinPath(resource.Type, resource.ID).String()
+			resourceData, err := setMetaSource(entry.Resource, resourceURL)
+			if err != nil {
+				continue
+			}
+			result.Entry = append(result.Entry, fhir.BundleEntry{
+				FullUrl:  to.Ptr(resourceURL),
+				Resource: resourceData,
+				Search:   entry.Search,
+			})
+		}
+		if hasNextPage(node.result) {
+			issues = append(issues, fhir.OperationOutcomeIssue{
+				Severity:    fhir.IssueSeverityWarning,
+				Code:        fhir.IssueTypeIncomplete,
+				Diagnostics: to.Ptr(fmt.Sprintf("%s (%s): only the first page of search results is included", coolfhir.ToString(node.identifier), node.fhirBaseURL.String())),
+			})
+		}
+	}
+	result.Total = to.Ptr(len(result.Entry))
+	if len(issues) > 0 {
+		result.Entry = append(result.Entry, fhir.BundleEntry{
+			Resource: must.MarshalJSON(fhir.OperationOutcome{Issue: issues}),
+			Search: &fhir.BundleEntrySearch{
+				Mode: to.Ptr(fhir.SearchEntryModeOutcome),
+			},
+		})
+	}
+	return result
+}
+
+// hasNextPage returns whether the searchset Bundle links to a next page of search results.
+func hasNextPage(bundle fhir.Bundle) bool {
+	for _, link := range bundle.Link {
+		if link.Relation == "next" {
+			return true
+		}
+	}
+	return false
+}
+
+// setMetaSource sets meta.source of the given resource, retaining any other properties.
+func setMetaSource(resourceData json.RawMessage, source string) (json.RawMessage, error) {
+	var resource map[string]interface{}
+	if err := json.Unmarshal(resourceData, &resource); err != nil {
+		return nil, err
+	}
+	meta, _ := resource["meta"].(map[string]interface{})
+	if meta == nil {
+		meta = map[string]interface{}{}
+	}
+	meta["source"] = source
+	resource["meta"] = meta
+	return json.Marshal(resource)
+}
+
+func slicesContainsIdentifier(nodes []*aggregatedSearchNode, identifier fhir.Identifier) bool {
+	for _, node := range nodes {
+		if coolfhir.IdentifierEquals(&node.identifier, &identifier) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,210 @@
+package careplancontributor
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SanteonNL/orca/orchestrator/cmd/profile"
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/must"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+func TestService_AggregatedSearch(t *testing.T) {
+	ura := func(value string) *fhir.Identifier {
+		return &fhir.Identifier{System: to.Ptr(coolfhir.URANamingSystem), Value: to.Ptr(value)}
+	}
+	careTeam := fhir.CareTeam{
+		Id: to.Ptr("cps-careteam"),
+		Participant: []fhir.CareTeamParticipant{
+			{Member: &fhir.Reference{Identifier: ura("1")}, Period: &fhir.Period{Start: to.Ptr("2020-01-01T00:00:00Z")}},
+			{Member: &fhir.Reference{Identifier: ura("2")}, Period: &fhir.Period{Start: to.Ptr("2020-01-01T00:00:00Z")}},
+			{Member: &fhir.Reference{Identifier: ura("3")}, Period: &fhir.Period{Start: to.Ptr("2020-01-01T00:00:00Z")}},
+			// Participation ended, should not be queried
+			{Member: &fhir.Reference{Identifier: ura("4")}, Period: &fhir.Period{Start: to.Ptr("2020-01-01T00:00:00Z"), End: to.Ptr("2021-01-01T00:00:00Z")}},
+		},
+	}
+	carePlan := fhir.CarePlan{
+		Id:        to.Ptr("1"),
+		Contained: must.MarshalJSON([]any{careTeam}),
+		CareTeam:  []fhir.Reference{{Reference: to.Ptr("#cps-careteam")}},
+	}
+
+	var capturedSCPContext string
+	var capturedQuery string
+	node1Mux := http.NewServeMux()
+	node1Mux.HandleFunc("POST /fhir/Observation/_search", func(writer http.ResponseWriter, request *http.Request) {
+		capturedSCPContext = request.Header.Get("X-Scp-Context")
+		capturedQuery = request.PostFormValue("patient")
+		coolfhir.SendResponse(writer, http.StatusOK, fhir.Bundle{
+			Type: fhir.BundleTypeSearchset,
+			Entry: []fhir.BundleEntry{
+				{Resource: must.MarshalJSON(fhir.Observation{Id: to.Ptr("a")})},
+			},
+		})
+	})
+	node1 := httptest.NewServer(node1Mux)
+	defer node1.Close()
+	node2Mux := http.NewServeMux()
+	node2Mux.HandleFunc("POST /fhir/Observation/_search", func(writer http.ResponseWriter, request *http.Request) {
+		select {
+		case <-request.Context().Done():
+		case <-time.After(time.Second):
+		}
+	})
+	node2 := httptest.NewServer(node2Mux)
+	defer node2.Close()
+	node4Mux := http.NewServeMux()
+	node4Mux.HandleFunc("POST /fhir/Observation/_search", func(writer http.ResponseWriter, request *http.Request) {
+		t.Error("SCP-node of inactive CareTeam member should not be queried")
+	})
+	node4 := httptest.NewServer(node4Mux)
+	defer node4.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /cps/test/CarePlan/1", func(writer http.ResponseWriter, request *http.Request) {
+		coolfhir.SendResponse(writer, http.StatusOK, carePlan)
+	})
+	mux.HandleFunc("GET /cps/test/CarePlan/2", func(writer http.ResponseWriter, request *http.Request) {
+		coolfhir.SendResponse(writer, http.StatusNotFound, fhir.OperationOutcome{
+			Issue: []fhir.OperationOutcomeIssue{{Severity: fhir.IssueSeverityError, Code: fhir.IssueTypeNotFound}},
+		})
+	})
+	httpServer := httptest.NewServer(mux)
+	defer httpServer.Close()
+	service := &Service{
+		profile: profile.TestProfile{
+			Principal: auth.TestPrincipal1,
+			CSD: profile.TestCsdDirectory{
+				Endpoints: map[string]map[string]string{
+					"http://fhir.nl/fhir/NamingSystem/ura|1": {"fhirBaseURL": node1.URL + "/fhir"},
+					"http://fhir.nl/fhir/NamingSystem/ura|2": {"fhirBaseURL": node2.URL + "/fhir"},
+					"http://fhir.nl/fhir/NamingSystem/ura|4": {"fhirBaseURL": node4.URL + "/fhir"},
+				},
+			},
+		},
+		tenants: map[string]tenants.Properties{
+			"test": tenants.Test().Sole(),
+		},
+		httpHandler:   mux,
+		cpsEnabled:    true,
+		orcaPublicURL: must.ParseURL(httpServer.URL),
+		config: Config{
+			StaticBearerToken:       "secret",
+			AggregatedSearchTimeout: 100 * time.Millisecond,
+		},
+	}
+	service.createFHIRClientForURL = service.defaultCreateFHIRClientForURL
+	service.RegisterHandlers(mux)
+
+	baseURL := httpServer.URL + "/cpc/test/external/fhir"
+	carePlanURL := httpServer.URL + "/cps/test/CarePlan/1"
+
+	t.Run("ok", func(t *testing.T) {
+		httpRequest, _ := http.NewRequest(http.MethodGet, baseURL+"/Observation?patient=Patient/1", nil)
+		httpRequest.Header.Set("Authorization", "Bearer secret")
+		httpRequest.Header.Set("X-Scp-Context", carePlanURL)
+		httpResponse, err := httpServer.Client().Do(httpRequest)
+		require.NoError(t, err)
+		responseData, err := io.ReadAll(httpResponse.Body)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, httpResponse.StatusCode, string(responseData))
+
+		var bundle fhir.Bundle
+		require.NoError(t, json.Unmarshal(responseData, &bundle))
+		assert.Equal(t, fhir.BundleTypeSearchset, bundle.Type)
+		assert.Equal(t, 1, *bundle.Total)
+		assert.Equal(t, carePlanURL, capturedSCPContext)
+		assert.Equal(t, "Patient/1", capturedQuery)
+		require.Len(t, bundle.Entry, 2)
+		t.Run("result of SCP-node has meta.source", func(t *testing.T) {
+			var observation fhir.Observation
+			require.NoError(t, json.Unmarshal(bundle.Entry[0].Resource, &observation))
+			assert.Equal(t, node1.URL+"/fhir/Observation/a", *bundle.Entry[0].FullUrl)
+			assert.Equal(t, node1.URL+"/fhir/Observation/a", *observation.Meta.Source)
+		})
+		t.Run("failed SCP-nodes are reported", func(t *testing.T) {
+			assert.Equal(t, fhir.SearchEntryModeOutcome, *bundle.Entry[1].Search.Mode)
+			var outcome fhir.OperationOutcome
+			require.NoError(t, json.Unmarshal(bundle.Entry[1].Resource, &outcome))
+			require.Len(t, outcome.Issue, 2)
+			assert.Contains(t, *outcome.Issue[0].Diagnostics, "http://fhir.nl/fhir/NamingSystem/ura|2")
+			assert.Contains(t, *outcome.Issue[0].Diagnostics, "didn't respond in time")
+			assert.Contains(t, *outcome.Issue[1].Diagnostics, "http://fhir.nl/fhir/NamingSystem/ura|3")
+		})
+	})
+	t.Run("only type-level search is supported", func(t *testing.T) {
+		httpRequest, _ := http.NewRequest(http.MethodGet, baseURL+"/Observation/a", nil)
+		httpRequest.Header.Set("Authorization", "Bearer secret")
+		httpRequest.Header.Set("X-Scp-Context", carePlanURL)
+		httpResponse, err := httpServer.Client().Do(httpRequest)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, httpResponse.StatusCode)
+	})
+	t.Run("CarePlan not found", func(t *testing.T) {
+		httpRequest, _ := http.NewRequest(http.MethodGet, baseURL+"/Observation", nil)
+		httpRequest.Header.Set("Authorization", "Bearer secret")
+		httpRequest.Header.Set("X-Scp-Context", httpServer.URL+"/cps/test/CarePlan/2")
+		httpResponse, err := httpServer.Client().Do(httpRequest)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, httpResponse.StatusCode)
+	})
+}
+
+func TestMergeAggregatedSearchResults(t *testing.T) {
+	t.Run("only first page of SCP-node results is included", func(t *testing.T) {
+		node := &aggregatedSearchNode{
+			identifier:  fhir.Identifier{System: to.Ptr(coolfhir.URANamingSystem), Value: to.Ptr("1")},
+			fhirBaseURL: must.ParseURL("http://example.com/fhir"),
+			result: fhir.Bundle{
+				Type: fhir.BundleTypeSearchset,
+				Link: []fhir.BundleLink{
+					{Relation: "self", Url: "http://example.com/fhir/Observation?patient=Patient/1"},
+					{Relation: "next", Url: "http://example.com/fhir/Observation?patient=Patient/1&page=2"},
+				},
+				Entry: []fhir.BundleEntry{
+					{Resource: must.MarshalJSON(fhir.Observation{Id: to.Ptr("a")})},
+				},
+			},
+		}
+
+		result := mergeAggregatedSearchResults([]*aggregatedSearchNode{node})
+
+		assert.Empty(t, result.Link)
+		assert.Equal(t, 1, *result.Total)
+		require.Len(t, result.Entry, 2)
+		var outcome fhir.OperationOutcome
+		require.NoError(t, json.Unmarshal(result.Entry[1].Resource, &outcome))
+		require.Len(t, outcome.Issue, 1)
+		assert.Equal(t, fhir.IssueTypeIncomplete, outcome.Issue[0].Code)
+		assert.Equal(t, "http://fhir.nl/fhir/NamingSystem/ura|1 (http://example.com/fhir): only the first page of search results is included", *outcome.Issue[0].Diagnostics)
+	})
+	t.Run("single page", func(t *testing.T) {
+		node := &aggregatedSearchNode{
+			identifier:  fhir.Identifier{System: to.Ptr(coolfhir.URANamingSystem), Value: to.Ptr("1")},
+			fhirBaseURL: must.ParseURL("http://example.com/fhir"),
+			result: fhir.Bundle{
+				Type: fhir.BundleTypeSearchset,
+				Link: []fhir.BundleLink{
+					{Relation: "self", Url: "http://example.com/fhir/Observation?patient=Patient/1"},
+				},
+				Entry: []fhir.BundleEntry{
+					{Resource: must.MarshalJSON(fhir.Observation{Id: to.Ptr("a")})},
+				},
+			},
+		}
+
+		result := mergeAggregatedSearchResults([]*aggregatedSearchNode{node})
+
+		require.Len(t, result.Entry, 1)
+	})
+}
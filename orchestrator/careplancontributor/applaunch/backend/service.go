@@ -0,0 +1,89 @@
+// Package backend provides system-level access to the tenants' EHR FHIR APIs, which isn't bound to an app launch (user session).
+// The connection is configured per tenant (ehr.fhir), typically authenticating using SMART Backend Services.
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/careplancontributor/applaunch/clients"
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/logging"
+	"github.com/SanteonNL/orca/orchestrator/lib/must"
+)
+
+const fhirLauncherKey = "backend"
+
+func init() {
+	// Register FHIR client factory that creates FHIR clients from a FHIR client configuration (JSON-serialized in the fhir_config property),
+	// for sessions that access the EHR's FHIR API using system-level credentials.
+	clients.Factories[fhirLauncherKey] = func(properties map[string]string) clients.ClientProperties {
+		var fhirConfig coolfhir.ClientConfig
+		if err := json.Unmarshal([]byte(properties["fhir_config"]), &fhirConfig); err != nil {
+			slog.ErrorContext(context.Background(), "Failed to unmarshal serialized FHIR config", slog.String(logging.FieldError, err.Error()))
+			return clients.ClientProperties{
+				BaseURL: must.ParseURL(properties["iss"]),
+				Client:  http.DefaultTransport,
+			}
+		}
+		transport, _, err := coolfhir.NewAuthRoundTripper(fhirConfig, coolfhir.Config())
+		if err != nil {
+			slog.Error("Failed to create authenticated FHIR transport", slog.String(logging.FieldError, err.Error()))
+			return clients.ClientProperties{
+				BaseURL: fhirConfig.ParseBaseURL(),
+				Client:  http.DefaultTransport,
+			}
+		}
+		return clients.ClientProperties{
+			BaseURL: fhirConfig.ParseBaseURL(),
+			Client:  transport,
+		}
+	}
+}
+
+type Service struct {
+	tenants       tenants.Config
+	orcaPublicURL *url.URL
+}
+
+func New(tenants tenants.Config, orcaPublicURL *url.URL) *Service {
+	return &Service{
+		tenants:       tenants,
+		orcaPublicURL: orcaPublicURL,
+	}
+}
+
+func (s *Service) RegisterHandlers(_ *http.ServeMux) {
+	// No handlers: there's no app launch
+}
+
+// CreateEHRProxies creates HTTP proxies and FHIR clients for the EHR FHIR API of tenants that have it configured.
+func (s *Service) CreateEHRProxies() (map[string]coolfhir.HttpProxy, map[string]fhirclient.Client) {
+	proxies := make(map[string]coolfhir.HttpProxy)
+	fhirClients := make(map[string]fhirclient.Client)
+	for _, tenant := range s.tenants {
+		if tenant.EHR.FHIR.BaseURL == "" {
+			continue
+		}
+		fhirBaseURL := tenant.EHR.FHIR.ParseBaseURL()
+		transport, fhirClient, err := coolfhir.NewAuthRoundTripper(tenant.EHR.FHIR, coolfhir.Config())
+		if err != nil {
+			slog.Error(
+				"Failed to create EHR FHIR client for tenant",
+				slog.String("tenant", tenant.ID),
+				slog.String("baseURL", fhirBaseURL.String()),
+				slog.String(logging.FieldError, err.Error()),
+			)
+			continue
+		}
+		tenantBasePath := "/cpc/" + tenant.ID + "/fhir"
+		proxies[tenant.ID] = coolfhir.NewProxy("EHR", fhirBaseURL, tenantBasePath, s.orcaPublicURL.JoinPath(tenantBasePath), transport, false, false)
+		fhirClients[tenant.ID] = fhirClient
+	}
+	return proxies, fhirClients
+}
@@ -0,0 +1,37 @@
+package backend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/must"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+func TestService_CreateEHRProxies(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /fhir/Patient/1", func(w http.ResponseWriter, r *http.Request) {
+		coolfhir.SendResponse(w, http.StatusOK, fhir.Patient{})
+	})
+	ehrServer := httptest.NewServer(mux)
+	defer ehrServer.Close()
+	tenantsConfig := tenants.Config{
+		"with-ehr": tenants.Properties{
+			ID:  "with-ehr",
+			EHR: tenants.EHRProperties{FHIR: coolfhir.ClientConfig{BaseURL: ehrServer.URL + "/fhir"}},
+		},
+		"without-ehr": tenants.Properties{ID: "without-ehr"},
+	}
+
+	proxies, fhirClients := New(tenantsConfig, must.ParseURL("https://example.com/orca")).CreateEHRProxies()
+
+	require.Len(t, proxies, 1)
+	require.Len(t, fhirClients, 1)
+	require.Contains(t, proxies, "with-ehr")
+	var patient fhir.Patient
+	require.NoError(t, fhirClients["with-ehr"].Read("Patient/1", &patient))
+}
@@ -17,6 +17,8 @@ type IssuerConfig struct {
 	ClientID  string `koanf:"clientid"`
 	OAuth2URL string `koanf:"oauth2url"`
 	Tenant    string `koanf:"tenant"`
+	// Scopes are requested in addition to openid, fhirUser and launch, e.g. patient/*.read to read the launch context.
+	Scopes []string `koanf:"scopes"`
 }
 
 func DefaultConfig() Config {
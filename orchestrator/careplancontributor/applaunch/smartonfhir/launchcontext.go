@@ -0,0 +1,236 @@
+package smartonfhir
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/careplancontributor/applaunch/session"
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/logging"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/google/uuid"
+	"github.com/zitadel/oidc/v3/pkg/oidc"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+// launchContext contains the FHIR resources that define the context of a SMART on FHIR app launch.
+// Resources are read from the EHR's FHIR API using the access token obtained during the launch.
+type launchContext struct {
+	tenant           *tenants.Properties
+	patient          fhir.Patient
+	practitioner     fhir.Practitioner
+	practitionerRole *fhir.PractitionerRole
+	serviceRequest   *fhir.ServiceRequest
+	condition        *fhir.Condition
+	organization     fhir.Organization
+}
+
+// taskIdentifier returns the identifier of the Task that is (to be) created for the ServiceRequest from the launch context.
+// The ServiceRequest's first identifier is used, so relaunching the app for the same ServiceRequest opens the existing Task.
+func (l launchContext) taskIdentifier() *string {
+	if l.serviceRequest == nil {
+		return nil
+	}
+	for _, identifier := range l.serviceRequest.Identifier {
+		if identifier.System != nil && identifier.Value != nil {
+			return to.Ptr(coolfhir.ToString(identifier))
+		}
+	}
+	return nil
+}
+
+// sessionData creates the user session data for the launch context. The resources are cached under their path in the EHR's FHIR API,
+// so the app->EHR proxy doesn't have to fetch them again.
+func (l launchContext) sessionData(accessToken string, fhirBaseURL string) session.Data {
+	result := session.Data{
+		FHIRLauncher: fhirLauncherKey,
+		LauncherProperties: map[string]string{
+			"access_token": accessToken,
+			"iss":          fhirBaseURL,
+		},
+		TenantID:       l.tenant.ID,
+		TaskIdentifier: l.taskIdentifier(),
+	}
+	result.Set("Patient/"+*l.patient.Id, l.patient)
+	result.Set("Practitioner/"+*l.practitioner.Id, l.practitioner)
+	if l.practitionerRole != nil {
+		result.Set("PractitionerRole/"+*l.practitionerRole.Id, *l.practitionerRole)
+	}
+	if l.serviceRequest != nil {
+		result.Set("ServiceRequest/"+*l.serviceRequest.Id, *l.serviceRequest)
+	}
+	if l.condition != nil {
+		result.Set("Condition/"+*l.condition.Id, *l.condition)
+	}
+	result.Set("Organization/magic-"+uuid.NewString(), l.organization)
+	return result
+}
+
+// fhirContextItem is an entry of the fhirContext token response parameter.
+// SMART App Launch 2.0 specifies it as an array of relative references, 2.1 as an array of objects with a reference property.
+type fhirContextItem struct {
+	Reference string `json:"reference"`
+}
+
+func (f *fhirContextItem) UnmarshalJSON(data []byte) error {
+	var reference string
+	if err := json.Unmarshal(data, &reference); err == nil {
+		f.Reference = reference
+		return nil
+	}
+	type alias fhirContextItem
+	return json.Unmarshal(data, (*alias)(f))
+}
+
+// fhirContextReferences returns the references from the fhirContext token response parameter.
+func fhirContextReferences(tokens *oidc.Tokens[*oidc.IDTokenClaims]) ([]string, error) {
+	value := tokens.Extra("fhirContext")
+	if value == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var items []fhirContextItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("invalid fhirContext: %w", err)
+	}
+	var result []string
+	for _, item := range items {
+		if item.Reference != "" {
+			result = append(result, item.Reference)
+		}
+	}
+	return result, nil
+}
+
+// resolveLaunchContext reads the resources from the launch context from the EHR's FHIR API:
+//   - the Patient from the patient token response parameter,
+//   - the Practitioner (and PractitionerRole) from the fhirUser ID token claim,
+//     or if not available, from the userFirstName and userLastName token response parameters (Epic),
+//   - ServiceRequest, Condition and PractitionerRole from the fhirContext token response parameter.
+//     If only the ServiceRequest is given, its reasonReference is used to resolve the Condition.
+func resolveLaunchContext(ctx context.Context, ehrClient fhirclient.Client, tokens *oidc.Tokens[*oidc.IDTokenClaims]) (*launchContext, error) {
+	result := &launchContext{}
+	patientID, hasPatientID := tokens.Extra("patient").(string)
+	if !hasPatientID || patientID == "" {
+		return nil, fmt.Errorf("no patient ID found in token response")
+	}
+	if err := ehrClient.ReadWithContext(ctx, "Patient/"+strings.TrimPrefix(patientID, "Patient/"), &result.patient); err != nil {
+		return nil, fmt.Errorf("failed to read Patient from EHR: %w", err)
+	}
+
+	fhirUser, _ := tokens.IDTokenClaims.Claims["fhirUser"].(string)
+	if err := result.resolveUser(ctx, ehrClient, fhirUser, tokens); err != nil {
+		return nil, err
+	}
+
+	references, err := fhirContextReferences(tokens)
+	if err != nil {
+		return nil, err
+	}
+	for _, reference := range references {
+		resourceType, _, _ := strings.Cut(reference, "/")
+		var target any
+		switch resourceType {
+		case "ServiceRequest":
+			result.serviceRequest = &fhir.ServiceRequest{}
+			target = result.serviceRequest
+		case "Condition":
+			result.condition = &fhir.Condition{}
+			target = result.condition
+		case "PractitionerRole":
+			result.practitionerRole = &fhir.PractitionerRole{}
+			target = result.practitionerRole
+		default:
+			slog.DebugContext(ctx, "SMART on FHIR: ignoring unsupported fhirContext resource", slog.String(logging.FieldResourceType, resourceType))
+			continue
+		}
+		if err := ehrClient.ReadWithContext(ctx, reference, target); err != nil {
+			return nil, fmt.Errorf("failed to read %s from EHR: %w", reference, err)
+		}
+	}
+	if result.serviceRequest != nil && result.condition == nil {
+		for _, reasonReference := range result.serviceRequest.ReasonReference {
+			if reasonReference.Reference == nil || !strings.HasPrefix(*reasonReference.Reference, "Condition/") {
+				continue
+			}
+			result.condition = &fhir.Condition{}
+			if err := ehrClient.ReadWithContext(ctx, *reasonReference.Reference, result.condition); err != nil {
+				return nil, fmt.Errorf("failed to read ServiceRequest reason %s from EHR: %w", *reasonReference.Reference, err)
+			}
+			break
+		}
+	}
+	return result, nil
+}
+
+// fhirUserReference returns the relative reference (<type>/<id>) of the Practitioner or PractitionerRole the fhirUser claim refers to,
+// which is the last 2 path segments of the (absolute or relative) reference, ignoring any version. It returns an empty string if the claim
+// doesn't refer to a Practitioner or PractitionerRole.
+func fhirUserReference(fhirUser string) string {
+	parsed, err := url.Parse(fhirUser)
+	if err != nil {
+		return ""
+	}
+	segments := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if len(segments) >= 4 && segments[len(segments)-2] == "_history" {
+		segments = segments[:len(segments)-2]
+	}
+	if len(segments) < 2 {
+		return ""
+	}
+	resourceType, id := segments[len(segments)-2], segments[len(segments)-1]
+	if (resourceType != "Practitioner" && resourceType != "PractitionerRole") || id == "" {
+		return ""
+	}
+	return resourceType + "/" + id
+}
+
+// resolveUser resolves the launching user from the fhirUser claim, which is an (absolute or relative) reference to a Practitioner or PractitionerRole.
+func (l *launchContext) resolveUser(ctx context.Context, ehrClient fhirclient.Client, fhirUser string, tokens *oidc.Tokens[*oidc.IDTokenClaims]) error {
+	reference := fhirUserReference(fhirUser)
+	switch {
+	case strings.HasPrefix(reference, "PractitionerRole/"):
+		l.practitionerRole = &fhir.PractitionerRole{}
+		if err := ehrClient.ReadWithContext(ctx, reference, l.practitionerRole); err != nil {
+			return fmt.Errorf("failed to read fhirUser %s from EHR: %w", reference, err)
+		}
+		if l.practitionerRole.Practitioner == nil || l.practitionerRole.Practitioner.Reference == nil {
+			return fmt.Errorf("fhirUser %s doesn't refer to a Practitioner", reference)
+		}
+		reference = *l.practitionerRole.Practitioner.Reference
+		fallthrough
+	case strings.HasPrefix(reference, "Practitioner/"):
+		if err := ehrClient.ReadWithContext(ctx, reference, &l.practitioner); err != nil {
+			return fmt.Errorf("failed to read fhirUser %s from EHR: %w", reference, err)
+		}
+		return nil
+	}
+	// No (supported) fhirUser claim, fall back to the user's name from the token response
+	userFirstName, ok := tokens.Extra("userFirstName").(string)
+	if !ok {
+		return fmt.Errorf("no userFirstName found in token response")
+	}
+	userLastName, ok := tokens.Extra("userLastName").(string)
+	if !ok {
+		return fmt.Errorf("no userLastName found in token response")
+	}
+	l.practitioner = fhir.Practitioner{
+		Id: to.Ptr(tokens.IDTokenClaims.Subject),
+		Name: []fhir.HumanName{
+			{
+				Family: to.Ptr(userLastName),
+				Given:  []string{userFirstName},
+			},
+		},
+	}
+	return nil
+}
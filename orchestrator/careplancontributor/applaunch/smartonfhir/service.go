@@ -38,7 +38,6 @@ import (
 	"github.com/zitadel/oidc/v3/pkg/client/rp"
 	zitadelHTTP "github.com/zitadel/oidc/v3/pkg/http"
 	"github.com/zitadel/oidc/v3/pkg/oidc"
-	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
 	"golang.org/x/oauth2"
 )
 
@@ -85,6 +84,7 @@ type trustedIssuer struct {
 	clientID        string
 	realIssuerURL   string
 	tenantID        string
+	scopes          []string
 }
 
 func (t trustedIssuer) issuerURL() string {
@@ -107,6 +107,7 @@ func New(config Config, tenants tenants.Config, sessionManager *user.SessionMana
 			clientID:        curr.ClientID,
 			realIssuerURL:   curr.OAuth2URL,
 			tenantID:        curr.Tenant,
+			scopes:          curr.Scopes,
 		}
 		issuersByURL[curr.URL] = issuer
 		issuersByKey[key] = issuer
@@ -192,7 +193,7 @@ func (s *Service) handleAppLaunch(response http.ResponseWriter, request *http.Re
 	}
 	// Epic on FHIR requirement: aud claim in the authorization request
 	urlOptions = append(urlOptions, rp.WithURLParam("aud", provider.Issuer()))
-	var stateParams url.Values
+	stateParams := url.Values{}
 	for key, value := range request.URL.Query() {
 		switch key {
 		case "iss", "launch":
@@ -234,96 +235,85 @@ func (s *Service) handleCallback(response http.ResponseWriter, request *http.Req
 			"SMART on FHIR app launched with ID token",
 			slog.String("token", string(idTokenJSON)),
 		)
-		patient, practitioner, organization, tenant, err := s.loadContext(httpRequest.Context(), issuer, tokens)
+		launchContext, err := s.loadContext(httpRequest.Context(), issuer, tokens)
 		if err != nil {
 			s.SendError(request.Context(), issuer.key, fmt.Errorf("failed to load context for SMART App Launch: %w", err), httpResponse, http.StatusInternalServerError)
 			return
 		}
-		sessionData := session.Data{
-			FHIRLauncher: fhirLauncherKey,
-			LauncherProperties: map[string]string{
-				"access_token": tokens.AccessToken,
-				"iss":          issuer.issuerURL(),
-			},
-			TenantID: tenant.ID,
+		sessionData := launchContext.sessionData(tokens.AccessToken, issuer.issuerLaunchURL)
+		ctx := tenants.WithTenant(httpRequest.Context(), *launchContext.tenant)
+		redirectURL, err := s.landingURL(ctx, sessionData)
+		if err != nil {
+			s.SendError(request.Context(), issuer.key, err, httpResponse, http.StatusInternalServerError)
+			return
 		}
-		sessionData.Set("Patient/"+*patient.Id, *patient)
-		sessionData.Set("Practitioner/"+*practitioner.Id, *practitioner)
-		sessionData.Set("Organization/magic-"+uuid.NewString(), *organization)
 		s.sessionManager.Create(httpResponse, sessionData)
 		slog.InfoContext(request.Context(), "SMART on FHIR app launch succeeded")
-		// Note: we don't support enrolment/task creation through SMART on FHIR yet, so we redirect to the task overview
-		http.Redirect(httpResponse, request, s.frontendBaseURL.JoinPath("list").String(), http.StatusFound)
+		http.Redirect(httpResponse, request, redirectURL.String(), http.StatusFound)
 	}, issuer.client, codeExchangeOpts...)(response, request)
 }
 
-func (s *Service) loadContext(ctx context.Context, issuer *trustedIssuer, tokens *oidc.Tokens[*oidc.IDTokenClaims]) (*fhir.Patient, *fhir.Practitioner, *fhir.Organization, *tenants.Properties, error) {
-	patientID, hasPatientID := tokens.Extra("patient").(string)
-	if !hasPatientID || patientID == "" {
-		return nil, nil, nil, nil, fmt.Errorf("no patient ID found in token response")
-	}
-
-	userFirstName, ok := tokens.Extra("userFirstName").(string)
-	if !ok {
-		return nil, nil, nil, nil, fmt.Errorf("no userFirstName found in token response")
-	}
-	userLastName, ok := tokens.Extra("userLastName").(string)
-	if !ok {
-		return nil, nil, nil, nil, fmt.Errorf("no userLastName found in token response")
-	}
-	practitioner := fhir.Practitioner{
-		Id: to.Ptr(tokens.IDTokenClaims.Subject),
-		Name: []fhir.HumanName{
-			{
-				Family: to.Ptr(userLastName),
-				Given:  []string{userFirstName},
-			},
-		},
+// landingURL determines the frontend page the user is sent to after the launch:
+// the existing Task if the ServiceRequest from the launch context was already enrolled,
+// the enrollment page if there's a ServiceRequest without a Task, or the Task overview otherwise.
+func (s *Service) landingURL(ctx context.Context, sessionData session.Data) (*url.URL, error) {
+	if sessionData.GetByType("ServiceRequest") == nil {
+		return s.frontendBaseURL.JoinPath("list"), nil
 	}
-	slog.DebugContext(
-		ctx,
-		"SMART on FHIR practitioner",
-		slog.String("first_name", userFirstName),
-		slog.String("last_name", userLastName),
-		slog.String("practitioner_id", *practitioner.Id),
-	)
-
-	if !strings.HasPrefix(patientID, "Patient/") {
-		// If the patient ID is not prefixed with "Patient/", we assume it's just the ID and prefix it.
-		patientID = "Patient/" + patientID
+	if sessionData.TaskIdentifier != nil {
+		taskIdentifier, err := coolfhir.TokenToIdentifier(*sessionData.TaskIdentifier)
+		if err != nil {
+			return nil, err
+		}
+		cpsFHIRClient, err := globals.CreateCPSFHIRClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		existingTask, err := coolfhir.GetTaskByIdentifier(ctx, cpsFHIRClient, *taskIdentifier)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check for existing CPS Task: %w", err)
+		}
+		if existingTask != nil {
+			return s.frontendBaseURL.JoinPath("task", *existingTask.Id), nil
+		}
 	}
+	return s.frontendBaseURL.JoinPath("new"), nil
+}
 
+// loadContext resolves the launch context (Patient, Practitioner, ServiceRequest, etc.) from the EHR's FHIR API,
+// and the identity of the local care organization.
+func (s *Service) loadContext(ctx context.Context, issuer *trustedIssuer, tokens *oidc.Tokens[*oidc.IDTokenClaims]) (*launchContext, error) {
 	// Select tenant
 	tenant, err := s.tenants.Get(issuer.tenantID)
 	if err != nil {
-		return nil, nil, nil, nil, fmt.Errorf("failed to get tenant %s: %w", issuer.tenantID, err)
+		return nil, fmt.Errorf("failed to get tenant %s: %w", issuer.tenantID, err)
 	}
 	ctx = tenants.WithTenant(ctx, *tenant)
 
-	cpsFHIRClient, err := globals.CreateCPSFHIRClient(ctx)
+	ehrFHIRClient := createFHIRClient(ctx, must.ParseURL(issuer.issuerLaunchURL), tokens.AccessToken)
+	result, err := resolveLaunchContext(ctx, ehrFHIRClient, tokens)
 	if err != nil {
-		return nil, nil, nil, nil, err
-	}
-	// Currently, the SMART on FHIR launch can't be used with a ServiceRequest to request new Tasks,
-	// but only to inspect existing Tasks.
-	// It expects the patient to exist, using the patient ID from the EHR to look it up in the CPS FHIR instance.
-	// This should probably change in the future, since this relies on patient IDs copied from the EHR to CPS (which should rely on its own IDs instead).
-	var patient fhir.Patient
-	if err := cpsFHIRClient.Read(patientID, &patient); err != nil {
-		return nil, nil, nil, nil, fmt.Errorf("failed to read patient resource: %w", err)
+		return nil, err
 	}
+	result.tenant = tenant
+	slog.DebugContext(
+		ctx,
+		"SMART on FHIR launch context",
+		slog.String("patient_id", *result.patient.Id),
+		slog.String("practitioner_id", to.Empty(result.practitioner.Id)),
+		slog.Bool("service_request", result.serviceRequest != nil),
+	)
 
 	// Resolve identity of local care organization
 	identities, err := s.profile.Identities(ctx)
 	if err != nil {
-		return nil, nil, nil, nil, fmt.Errorf("failed to get identities from profile: %w", err)
+		return nil, fmt.Errorf("failed to get identities from profile: %w", err)
 	}
 	if len(identities) != 1 {
-		return nil, nil, nil, nil, fmt.Errorf("expected exactly one identity, got %d", len(identities))
+		return nil, fmt.Errorf("expected exactly one identity, got %d", len(identities))
 	}
-	organization := identities[0]
-
-	return &patient, &practitioner, &organization, tenant, nil
+	result.organization = identities[0]
+	return result, nil
 }
 
 func (s *Service) getIssuerByKey(request *http.Request, issuerKey string) (rp.RelyingParty, error) {
@@ -370,7 +360,7 @@ func (s *Service) initializeIssuer(ctx context.Context, issuer *trustedIssuer) (
 		}),
 	}
 
-	scopes := []string{"openid", "fhirUser", "launch"}
+	scopes := append([]string{"openid", "fhirUser", "launch"}, issuer.scopes...)
 	redirectURI := s.orcaBaseURL.JoinPath("smart-app-launch", "callback", issuer.key)
 	slog.InfoContext(
 		ctx,
@@ -21,6 +21,7 @@ import (
 	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
 	"github.com/SanteonNL/orca/orchestrator/globals"
 	"github.com/SanteonNL/orca/orchestrator/lib/az/azkeyvault"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
 	"github.com/SanteonNL/orca/orchestrator/lib/must"
 	"github.com/SanteonNL/orca/orchestrator/lib/test"
 	"github.com/SanteonNL/orca/orchestrator/lib/to"
@@ -40,14 +41,9 @@ func TestService(t *testing.T) {
 	sessionManager := user.NewSessionManager[session.Data](time.Minute)
 	cpsClient := &test.StubFHIRClient{
 		Resources: []any{
-			fhir.Patient{
-				Id: to.Ptr(fhirPatientID),
-				Name: []fhir.HumanName{
-					{
-						Family: to.Ptr("Doe"),
-						Given:  []string{"John"},
-					},
-				},
+			fhir.Task{
+				Id:         to.Ptr("existing-task"),
+				Identifier: []fhir.Identifier{{System: to.Ptr("http://example.com/sr"), Value: to.Ptr("enrolled")}},
 			},
 		},
 	}
@@ -57,8 +53,10 @@ func TestService(t *testing.T) {
 	const clientID = "test-client-id"
 	clientURL := must.ParseURL(httpServer.URL).JoinPath("smart-app-launch")
 	var frontendCalled bool
-	httpMux.HandleFunc("/frontend/list", func(writer http.ResponseWriter, request *http.Request) {
+	var frontendPath string
+	httpMux.HandleFunc("/frontend/", func(writer http.ResponseWriter, request *http.Request) {
 		frontendCalled = true
+		frontendPath = request.URL.Path
 		writer.Header().Set("Content-Type", "text/html")
 		_, _ = writer.Write([]byte("<html><body>Frontend called</body></html>"))
 	})
@@ -94,6 +92,33 @@ func TestService(t *testing.T) {
 		})
 	})
 
+	// EHR FHIR API, which is the SMART on FHIR issuer
+	var capturedEHRAuthorization string
+	ehrResources := map[string]any{
+		"Patient/" + fhirPatientID: fhir.Patient{
+			Id:   to.Ptr(fhirPatientID),
+			Name: []fhir.HumanName{{Family: to.Ptr("Doe"), Given: []string{"John"}}},
+		},
+		"Practitioner/1":     fhir.Practitioner{Id: to.Ptr("1")},
+		"PractitionerRole/1": fhir.PractitionerRole{Id: to.Ptr("1"), Practitioner: &fhir.Reference{Reference: to.Ptr("Practitioner/1")}},
+		"ServiceRequest/1": fhir.ServiceRequest{
+			Id:              to.Ptr("1"),
+			Identifier:      []fhir.Identifier{{System: to.Ptr("http://example.com/sr"), Value: to.Ptr("new")}},
+			ReasonReference: []fhir.Reference{{Reference: to.Ptr("Condition/1")}},
+		},
+		"ServiceRequest/2": fhir.ServiceRequest{
+			Id:         to.Ptr("2"),
+			Identifier: []fhir.Identifier{{System: to.Ptr("http://example.com/sr"), Value: to.Ptr("enrolled")}},
+		},
+		"Condition/1": fhir.Condition{Id: to.Ptr("1")},
+	}
+	for path, resource := range ehrResources {
+		httpMux.HandleFunc("GET /fhir/"+path, func(w http.ResponseWriter, r *http.Request) {
+			capturedEHRAuthorization = r.Header.Get("Authorization")
+			coolfhir.SendResponse(w, http.StatusOK, resource)
+		})
+	}
+
 	var capturedScope []string
 	var capturedAudience []string
 	var capturedClientID []string
@@ -107,6 +132,8 @@ func TestService(t *testing.T) {
 		http.Redirect(w, r, r.URL.Query().Get("redirect_uri")+"?state="+r.URL.Query().Get("state"), http.StatusFound)
 	})
 	var capturedClientAssertion string
+	fhirUser := "Practitioner/1"
+	var fhirContext []any
 	httpMux.HandleFunc("/fhir/token", func(w http.ResponseWriter, r *http.Request) {
 		capturedClientAssertion = r.PostFormValue("client_assertion")
 		// Simulate a token response
@@ -121,22 +148,22 @@ func TestService(t *testing.T) {
 				ID:        uuid.NewString(),
 			}).
 			Claims(map[string]any{
-				"fhirUser": "Practitioner/1",
+				"fhirUser": fhirUser,
 			}).
 			Serialize()
 		require.NoError(t, err)
 		w.Header().Set("Content-Type", "application/json")
-
-		_, _ = w.Write([]byte(`{
-			"access_token": "test-access-token",
-			"id_token": "` + idToken + `",
-			"token_type": "Bearer",	
-			"expires_in": 3600,
-			"scope": "` + strings.Join(capturedScope, " ") + `",
-			"patient": "1234",
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  "test-access-token",
+			"id_token":      idToken,
+			"token_type":    "Bearer",
+			"expires_in":    3600,
+			"scope":         strings.Join(capturedScope, " "),
+			"patient":       fhirPatientID,
 			"userFirstName": "John",
-			"userLastName": "Doe"
-		}`))
+			"userLastName":  "Doe",
+			"fhirContext":   fhirContext,
+		})
 	})
 
 	service, err := New(Config{
@@ -237,6 +264,60 @@ func TestService(t *testing.T) {
 			// Assert the "browser" was redirected to the frontend
 			require.True(t, frontendCalled)
 		})
+		launch := func(t *testing.T) *session.Data {
+			cookieJar, _ := cookiejar.New(nil)
+			httpClient := http.Client{Jar: cookieJar}
+			httpResponse, err := httpClient.Get(clientURL.String() + "?" + url.Values{"iss": []string{issuerURL}}.Encode())
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, httpResponse.StatusCode)
+			require.True(t, frontendCalled)
+			frontendCalled = false
+			httpRequest := httptest.NewRequest(http.MethodGet, "/", nil)
+			for _, cookie := range cookieJar.Cookies(must.ParseURL(httpServer.URL)) {
+				httpRequest.AddCookie(cookie)
+			}
+			return sessionManager.Get(httpRequest)
+		}
+		t.Run("without fhirContext, redirects to Task overview", func(t *testing.T) {
+			sessionData := launch(t)
+
+			require.Equal(t, "/frontend/list", frontendPath)
+			require.NotNil(t, sessionData)
+			require.Equal(t, "Bearer test-access-token", capturedEHRAuthorization)
+			require.Equal(t, issuerURL, sessionData.LauncherProperties["iss"])
+			require.Equal(t, "test-access-token", sessionData.LauncherProperties["access_token"])
+			require.NotNil(t, sessionData.GetByPath("Patient/"+fhirPatientID))
+			require.NotNil(t, sessionData.GetByPath("Practitioner/1"))
+			require.Nil(t, sessionData.TaskIdentifier)
+		})
+		t.Run("with ServiceRequest in fhirContext, redirects to enrollment", func(t *testing.T) {
+			fhirUser = issuerURL + "/PractitionerRole/1"
+			fhirContext = []any{map[string]string{"reference": "ServiceRequest/1"}}
+			defer func() {
+				fhirUser = "Practitioner/1"
+				fhirContext = nil
+			}()
+
+			sessionData := launch(t)
+
+			require.Equal(t, "/frontend/new", frontendPath)
+			require.NotNil(t, sessionData.GetByPath("ServiceRequest/1"))
+			require.NotNil(t, sessionData.GetByPath("Condition/1"), "Condition should be resolved from ServiceRequest.reasonReference")
+			require.NotNil(t, sessionData.GetByPath("PractitionerRole/1"))
+			require.NotNil(t, sessionData.GetByPath("Practitioner/1"))
+			require.Equal(t, "http://example.com/sr|new", *sessionData.TaskIdentifier)
+		})
+		t.Run("with already enrolled ServiceRequest in fhirContext (SMART 2.0 format), redirects to Task", func(t *testing.T) {
+			fhirContext = []any{"ServiceRequest/2", "Condition/1"}
+			defer func() {
+				fhirContext = nil
+			}()
+
+			sessionData := launch(t)
+
+			require.Equal(t, "/frontend/task/existing-task", frontendPath)
+			require.NotNil(t, sessionData.GetByPath("Condition/1"))
+		})
 	})
 }
 
@@ -280,3 +361,25 @@ func Test_loadJWTSigningKeyFromAzureKeyVault(t *testing.T) {
 	require.Equal(t, "59adb1f2af5539daa47e7053ba82f80685e81c5324a19f6ac27b55f58a7d92ed", jwkKeySet.Keys[0].KeyID, "Expected key ID to be '0'")
 	require.NotNil(t, jwkKeySet.Keys[0].Key)
 }
+
+func TestFHIRUserReference(t *testing.T) {
+	tests := map[string]string{
+		"Practitioner/1":                                     "Practitioner/1",
+		"PractitionerRole/1":                                 "PractitionerRole/1",
+		"https://example.com/fhir/Practitioner/1":            "Practitioner/1",
+		"https://example.com/fhir/PractitionerRole/1":        "PractitionerRole/1",
+		"https://example.com/fhir/Practitioner/1/_history/2": "Practitioner/1",
+		// Only the resource type matters, not where "Practitioner" appears
+		"https://example.com/Practitioner/fhir/Patient/1":      "",
+		"https://example.com/fhir/PractitionerRoleExtension/1": "",
+		"Practitioner/":   "",
+		"Practitioner":    "",
+		"RelatedPerson/1": "",
+		"":                "",
+	}
+	for fhirUser, expected := range tests {
+		t.Run(fhirUser, func(t *testing.T) {
+			require.Equal(t, expected, fhirUserReference(fhirUser))
+		})
+	}
+}
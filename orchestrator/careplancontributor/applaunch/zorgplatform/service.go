@@ -294,7 +294,7 @@ func (s *stsAccessTokenRoundTripper) RoundTrip(httpRequest *http.Request) (*http
 		trace.WithSpanKind(trace.SpanKindClient),
 		trace.WithAttributes(
 			attribute.String(otel.HTTPMethod, httpRequest.Method),
-			attribute.String(otel.HTTPURL, httpRequest.URL.String()),
+			attribute.String(otel.HTTPURL, otel.RedactURL(httpRequest.URL)),
 		),
 	)
 	defer span.End()
@@ -358,12 +358,11 @@ func (s *stsAccessTokenRoundTripper) refreshAccessToken(ctx context.Context, car
 		slog.DebugContext(ctx, "(cache miss) Getting Zorgplatform access token", slog.String("careplan", carePlanReference))
 		workflowCtx, err = s.getWorkflowContext(ctx, carePlanReference)
 	} else {
-		// Use X-Scp-WorkflowID and X-Scp-PatientID headers
+		// Use X-Scp-WorkflowID and X-Scp-PatientID headers. The patient ID is a BSN, so it's not logged.
 		slog.DebugContext(
 			ctx,
 			"(cache miss) Getting Zorgplatform access token",
 			slog.String("workflow_id", workflowId),
-			slog.String("patient_id", patientID),
 		)
 		workflowCtx = &workflowContext{
 			workflowId: workflowId,
@@ -405,7 +404,7 @@ func (s *Service) handleLaunch(response http.ResponseWriter, request *http.Reque
 		trace.WithSpanKind(trace.SpanKindServer),
 		trace.WithAttributes(
 			attribute.String(otel.HTTPMethod, request.Method),
-			attribute.String(otel.HTTPURL, request.URL.String()),
+			attribute.String(otel.HTTPURL, otel.RedactURL(request.URL)),
 		),
 	)
 	defer span.End()
@@ -1,14 +1,18 @@
 package careplancontributor
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 
 	fhirclient "github.com/SanteonNL/go-fhir-client"
 	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/auth"
 	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
 	"github.com/SanteonNL/orca/orchestrator/lib/debug"
 	"github.com/SanteonNL/orca/orchestrator/lib/must"
@@ -20,6 +24,10 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
+// ContributorTagSystem is the system of the meta.tag that is added to resources written to the EHR by remote CareTeam members through batch Bundles.
+// Its code contains the identifier of the contributing organization, in the form of <system>|<value>.
+const ContributorTagSystem = "http://santeonnl.github.io/orca/CodeSystem/contributing-organization"
+
 func (s *Service) handleFHIRBatchBundle(httpRequest *http.Request, requestBundle fhir.Bundle) (*fhir.Bundle, error) {
 	tenant, err := tenants.FromContext(httpRequest.Context())
 	if err != nil {
@@ -35,7 +43,7 @@ func (s *Service) handleFHIRBatchBundle(httpRequest *http.Request, requestBundle
 		trace.WithSpanKind(trace.SpanKindServer),
 		trace.WithAttributes(
 			attribute.String(otel.HTTPMethod, httpRequest.Method),
-			attribute.String(otel.HTTPURL, httpRequest.URL.String()),
+			attribute.String(otel.HTTPURL, otel.RedactURL(httpRequest.URL)),
 			attribute.String(otel.FHIRBundleType, requestBundle.Type.String()),
 			attribute.Int(otel.FHIRBundleEntryCount, len(requestBundle.Entry)),
 		),
@@ -44,12 +52,19 @@ func (s *Service) handleFHIRBatchBundle(httpRequest *http.Request, requestBundle
 
 	slog.DebugContext(ctx, "Handling external FHIR API request")
 
-	_, err = s.authorizeScpMember(httpRequest.WithContext(ctx))
+	scpValidation, err := s.authorizeScpMember(httpRequest.WithContext(ctx))
+	if err != nil {
+		return nil, otel.Error(span, err)
+	}
+	principal, err := auth.PrincipalFromContext(ctx)
 	if err != nil {
 		return nil, otel.Error(span, err)
 	}
+	if err = s.enforceConsent(ctx, tenant.ID, scpValidation.carePlan, principal); err != nil {
+		return nil, otel.Error(span, err)
+	}
 
-	result, err := s.doHandleBatch(httpRequest.WithContext(ctx), requestBundle, fhirClient)
+	result, err := s.doHandleBatch(httpRequest.WithContext(ctx), requestBundle, fhirClient, scpValidation)
 	if err != nil {
 		return nil, otel.Error(span, err)
 	}
@@ -58,7 +73,18 @@ func (s *Service) handleFHIRBatchBundle(httpRequest *http.Request, requestBundle
 	return result, nil
 }
 
-func (s *Service) doHandleBatch(httpRequest *http.Request, requestBundle fhir.Bundle, fhirClient fhirclient.Client) (*fhir.Bundle, error) {
+func (s *Service) doHandleBatch(httpRequest *http.Request, requestBundle fhir.Bundle, fhirClient fhirclient.Client, scpValidation *ScpValidationResult) (*fhir.Bundle, error) {
+	writeProperties := batchWriteProperties(httpRequest)
+	containsWrites := false
+	for _, requestEntry := range requestBundle.Entry {
+		if requestEntry.Request != nil && requestEntry.Request.Method != fhir.HTTPVerbGET {
+			containsWrites = true
+		}
+	}
+	if containsWrites && writeProperties.Transaction && len(writeProperties.ResourceTypes) > 0 {
+		return s.doHandleBatchAsTransaction(httpRequest, requestBundle, fhirClient, scpValidation, writeProperties)
+	}
+
 	responseBundle := coolfhir.BatchResponse()
 	// This looks complicated, but is to support parallel execution of the bundle entries;
 	// entries in the response bundle need to be in the same order as the request entries.
@@ -71,24 +97,29 @@ func (s *Service) doHandleBatch(httpRequest *http.Request, requestBundle fhir.Bu
 	outcomesChan := make(chan entryResult, len(requestBundle.Entry))
 	for idx, requestEntry := range requestBundle.Entry {
 		fn := func(index int, requestEntry fhir.BundleEntry) entryResult {
-			if requestEntry.Request == nil || requestEntry.Request.Method != fhir.HTTPVerbGET {
+			if requestEntry.Request == nil || !slices.Contains(supportedBatchMethods(writeProperties), requestEntry.Request.Method) {
+				supported := "GET"
+				if len(writeProperties.ResourceTypes) > 0 {
+					supported = "GET, POST and PUT"
+				}
 				return entryResult{
 					index: index,
 					operationOutcomeIssue: &fhir.OperationOutcomeIssue{
 						Severity: fhir.IssueSeverityError,
 						Code:     fhir.IssueTypeNotSupported,
 						Details: &fhir.CodeableConcept{
-							Text: to.Ptr("Only GET requests are supported in batch processing"),
+							Text: to.Ptr("Only " + supported + " requests are supported in batch processing"),
 						},
 					},
 					operationOutcomeStatusCode: to.Ptr(http.StatusBadRequest),
 				}
 			}
-			requestURL := must.ParseURL(requestEntry.Request.Url)
 			var responseStatusCode int
+			var responseHeaders fhirclient.Headers
 			var responseData []byte
 			requestOpts := []fhirclient.Option{
 				fhirclient.ResponseStatusCode(&responseStatusCode),
+				fhirclient.ResponseHeaders(&responseHeaders),
 				fhirclient.RequestHeaders(map[string][]string{
 					// We need to propagate the X-Scp-Context header to FHIR client doing the request,
 					// Zorgplatform STS RoundTripper needs it.
@@ -96,12 +127,43 @@ func (s *Service) doHandleBatch(httpRequest *http.Request, requestBundle fhir.Bu
 				}),
 			}
 			var err error
-			if !strings.Contains(requestEntry.Request.Url, "/") {
-				// It's a search operation
-				err = fhirClient.SearchWithContext(httpRequest.Context(), requestURL.Path, requestURL.Query(), &responseData, requestOpts...)
+			if requestEntry.Request.Method == fhir.HTTPVerbGET {
+				requestURL := must.ParseURL(requestEntry.Request.Url)
+				if !strings.Contains(requestEntry.Request.Url, "/") {
+					// It's a search operation
+					err = fhirClient.SearchWithContext(httpRequest.Context(), requestURL.Path, requestURL.Query(), &responseData, requestOpts...)
+				} else {
+					// It's a read operation
+					err = fhirClient.ReadWithContext(httpRequest.Context(), requestURL.Path, &responseData, requestOpts...)
+				}
 			} else {
-				// It's a read operation
-				err = fhirClient.ReadWithContext(httpRequest.Context(), requestURL.Path, &responseData, requestOpts...)
+				// It's a create or update
+				var writeEntry *fhir.BundleEntry
+				writeEntry, err = s.prepareBatchWrite(httpRequest, requestEntry, fhirClient, scpValidation, writeProperties)
+				var errorWithCode *coolfhir.ErrorWithCode
+				if errors.As(err, &errorWithCode) {
+					issueType := fhir.IssueTypeInvalid
+					if errorWithCode.StatusCode == http.StatusForbidden {
+						issueType = fhir.IssueTypeForbidden
+					}
+					return entryResult{
+						index: index,
+						operationOutcomeIssue: &fhir.OperationOutcomeIssue{
+							Severity: fhir.IssueSeverityError,
+							Code:     issueType,
+							Details: &fhir.CodeableConcept{
+								Text: to.Ptr(errorWithCode.Message),
+							},
+						},
+						operationOutcomeStatusCode: to.Ptr(errorWithCode.StatusCode),
+					}
+				} else if err == nil {
+					if writeEntry.Request.Method == fhir.HTTPVerbPOST {
+						err = fhirClient.CreateWithContext(httpRequest.Context(), []byte(writeEntry.Resource), &responseData, requestOpts...)
+					} else {
+						err = fhirClient.UpdateWithContext(httpRequest.Context(), writeEntry.Request.Url, writeEntry.Resource, &responseData, requestOpts...)
+					}
+				}
 			}
 			if err != nil {
 				var opOutcomeErr fhirclient.OperationOutcomeError
@@ -131,19 +193,24 @@ func (s *Service) doHandleBatch(httpRequest *http.Request, requestBundle fhir.Bu
 					}
 				}
 			} else {
+				response := &fhir.BundleEntryResponse{
+					Status: strconv.Itoa(responseStatusCode) + " " + http.StatusText(responseStatusCode),
+				}
+				if location := responseHeaders.Get("Location"); location != "" {
+					response.Location = to.Ptr(location)
+				}
 				return entryResult{
 					index: index,
 					entry: &fhir.BundleEntry{
-						Response: &fhir.BundleEntryResponse{
-							Status: strconv.Itoa(responseStatusCode) + " " + http.StatusText(responseStatusCode),
-						},
+						Response: response,
 						Resource: responseData,
 					},
 				}
 			}
 		}
 
-		if s.config.ParallelBatch {
+		// Batches containing writes are executed in order, so that e.g. an update can follow a create.
+		if s.config.ParallelBatch && !containsWrites {
 			go func(requestEntry fhir.BundleEntry) {
 				outcomesChan <- fn(idx, requestEntry)
 			}(requestEntry)
@@ -170,3 +237,176 @@ func (s *Service) doHandleBatch(httpRequest *http.Request, requestBundle fhir.Bu
 
 	return to.Ptr(responseBundle.Bundle()), nil
 }
+
+// doHandleBatchAsTransaction executes the batch as a FHIR transaction against the EHR's FHIR API, so that either all or none of the entries succeed.
+func (s *Service) doHandleBatchAsTransaction(httpRequest *http.Request, requestBundle fhir.Bundle, fhirClient fhirclient.Client,
+	scpValidation *ScpValidationResult, writeProperties tenants.BatchWriteProperties) (*fhir.Bundle, error) {
+	transaction := coolfhir.Transaction()
+	for i, requestEntry := range requestBundle.Entry {
+		if requestEntry.Request == nil || !slices.Contains(supportedBatchMethods(writeProperties), requestEntry.Request.Method) {
+			return nil, coolfhir.BadRequest("entry %d: only GET, POST and PUT requests are supported in batch processing", i)
+		}
+		if requestEntry.Request.Method == fhir.HTTPVerbGET {
+			transaction.AppendEntry(fhir.BundleEntry{Request: requestEntry.Request})
+			continue
+		}
+		writeEntry, err := s.prepareBatchWrite(httpRequest, requestEntry, fhirClient, scpValidation, writeProperties)
+		if err != nil {
+			var errorWithCode *coolfhir.ErrorWithCode
+			if errors.As(err, &errorWithCode) {
+				return nil, coolfhir.NewErrorWithCode(fmt.Sprintf("entry %d: %s", i, errorWithCode.Message), errorWithCode.StatusCode)
+			}
+			return nil, fmt.Errorf("entry %d: %w", i, err)
+		}
+		transaction.AppendEntry(*writeEntry)
+	}
+	var transactionResult fhir.Bundle
+	err := fhirClient.CreateWithContext(httpRequest.Context(), transaction.Bundle(), &transactionResult,
+		fhirclient.AtPath("/"),
+		fhirclient.RequestHeaders(map[string][]string{
+			"X-Scp-Context": {httpRequest.Header.Get("X-Scp-Context")},
+		}))
+	if err != nil {
+		return nil, fmt.Errorf("EHR FHIR transaction failed: %w", err)
+	}
+	if len(transactionResult.Entry) != len(requestBundle.Entry) {
+		return nil, fmt.Errorf("EHR FHIR transaction response contains %d entries, expected %d", len(transactionResult.Entry), len(requestBundle.Entry))
+	}
+	responseBundle := coolfhir.BatchResponse()
+	for _, entry := range transactionResult.Entry {
+		responseBundle.AppendEntry(fhir.BundleEntry{
+			Response: entry.Response,
+			Resource: entry.Resource,
+		})
+	}
+	return to.Ptr(responseBundle.Bundle()), nil
+}
+
+// prepareBatchWrite authorizes a create or update of a batch entry, and tags the resource with the contributing organization.
+// The resource type must be allowed for the tenant, the resource must belong to the subject of the CarePlan the requester is a CareTeam member of,
+// and updates may only be performed on resources contributed by the same organization.
+func (s *Service) prepareBatchWrite(httpRequest *http.Request, requestEntry fhir.BundleEntry, fhirClient fhirclient.Client,
+	scpValidation *ScpValidationResult, writeProperties tenants.BatchWriteProperties) (*fhir.BundleEntry, error) {
+	ctx := httpRequest.Context()
+	var resource coolfhir.Resource
+	if err := json.Unmarshal(requestEntry.Resource, &resource); err != nil || resource.Type == "" {
+		return nil, coolfhir.BadRequest("entry must contain a valid FHIR resource")
+	}
+	if !writeProperties.Allowed(resource.Type) {
+		return nil, coolfhir.NewErrorWithCode(fmt.Sprintf("resource type %s may not be written in batch processing", resource.Type), http.StatusForbidden)
+	}
+	expectedURL := resource.Type
+	if requestEntry.Request.Method == fhir.HTTPVerbPUT {
+		if resource.ID == "" {
+			return nil, coolfhir.BadRequest("resource must have an ID when updating")
+		}
+		expectedURL = resource.Type + "/" + resource.ID
+	}
+	if requestEntry.Request.Url != expectedURL {
+		return nil, coolfhir.BadRequest("%s request URL must be %s", requestEntry.Request.Method.Code(), expectedURL)
+	}
+	if scpValidation == nil || scpValidation.carePlan == nil {
+		return nil, coolfhir.NewErrorWithCode("writes require a CarePlan context", http.StatusForbidden)
+	}
+	principal, err := auth.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	scope, err := newPatientScope(scpValidation.carePlan, fhirClient)
+	if err != nil {
+		return nil, coolfhir.NewErrorWithCode(err.Error(), http.StatusForbidden)
+	}
+	if err := scope.authorizeResource(ctx, requestEntry.Resource); err != nil {
+		return nil, err
+	}
+	if requestEntry.Request.Method == fhir.HTTPVerbPUT {
+		// Only resources contributed by the requester may be updated, not resources created by the EHR itself or other care organizations.
+		var existing fhir.Resource
+		err := fhirClient.ReadWithContext(ctx, expectedURL, &existing)
+		var outcomeError fhirclient.OperationOutcomeError
+		if err == nil {
+			if !isContributedBy(existing.Meta, principal.Organization) {
+				return nil, coolfhir.NewErrorWithCode("only resources contributed by the requester may be updated", http.StatusForbidden)
+			}
+		} else if !errors.As(err, &outcomeError) || outcomeError.HttpStatusCode != http.StatusNotFound {
+			return nil, fmt.Errorf("failed to read existing resource %s: %w", expectedURL, err)
+		}
+	}
+	resourceData, err := tagContributor(requestEntry.Resource, principal.Organization)
+	if err != nil {
+		return nil, err
+	}
+	return &fhir.BundleEntry{
+		// Retain the fullUrl, so other entries of a transaction can refer to the resource
+		FullUrl:  requestEntry.FullUrl,
+		Resource: resourceData,
+		Request: &fhir.BundleEntryRequest{
+			Method: requestEntry.Request.Method,
+			Url:    requestEntry.Request.Url,
+		},
+	}, nil
+}
+
+// tagContributor sets the meta.tag of the resource identifying the contributing organization, replacing any such tags provided by the requester.
+func tagContributor(resourceData json.RawMessage, organization fhir.Organization) (json.RawMessage, error) {
+	var resource map[string]interface{}
+	if err := json.Unmarshal(resourceData, &resource); err != nil {
+		return nil, err
+	}
+	var meta fhir.Meta
+	if resource["meta"] != nil {
+		if err := json.Unmarshal(must.MarshalJSON(resource["meta"]), &meta); err != nil {
+			return nil, coolfhir.BadRequest("invalid resource meta")
+		}
+	}
+	var tags []fhir.Coding
+	for _, tag := range meta.Tag {
+		if tag.System == nil || *tag.System != ContributorTagSystem {
+			tags = append(tags, tag)
+		}
+	}
+	for _, identifier := range organization.Identifier {
+		tags = append(tags, fhir.Coding{
+			System:  to.Ptr(ContributorTagSystem),
+			Code:    to.Ptr(coolfhir.ToString(identifier)),
+			Display: organization.Name,
+		})
+	}
+	meta.Tag = tags
+	resource["meta"] = meta
+	return json.Marshal(resource)
+}
+
+// isContributedBy returns whether the resource meta contains a contributor tag of the given organization.
+func isContributedBy(meta *fhir.Meta, organization fhir.Organization) bool {
+	if meta == nil {
+		return false
+	}
+	for _, tag := range meta.Tag {
+		if tag.System == nil || *tag.System != ContributorTagSystem || tag.Code == nil {
+			continue
+		}
+		for _, identifier := range organization.Identifier {
+			if *tag.Code == coolfhir.ToString(identifier) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// batchWriteProperties returns the tenant's batch write configuration, or empty properties (no writes allowed) if the request isn't scoped to a tenant.
+func batchWriteProperties(httpRequest *http.Request) tenants.BatchWriteProperties {
+	tenant, err := tenants.FromContext(httpRequest.Context())
+	if err != nil {
+		return tenants.BatchWriteProperties{}
+	}
+	return tenant.BatchWrite
+}
+
+func supportedBatchMethods(writeProperties tenants.BatchWriteProperties) []fhir.HTTPVerb {
+	if len(writeProperties.ResourceTypes) > 0 {
+		return []fhir.HTTPVerb{fhir.HTTPVerbGET, fhir.HTTPVerbPOST, fhir.HTTPVerbPUT}
+	}
+	return []fhir.HTTPVerb{fhir.HTTPVerbGET}
+}
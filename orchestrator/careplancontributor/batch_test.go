@@ -1,17 +1,24 @@
 package careplancontributor
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"testing"
 
 	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/careplancontributor/mock"
 	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/deep"
+	"github.com/SanteonNL/orca/orchestrator/lib/must"
 	"github.com/SanteonNL/orca/orchestrator/lib/test"
 	"github.com/SanteonNL/orca/orchestrator/lib/to"
 	"github.com/stretchr/testify/require"
 	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	"go.uber.org/mock/gomock"
 )
 
 func TestService_handleBatch(t *testing.T) {
@@ -41,7 +48,7 @@ func TestService_handleBatch(t *testing.T) {
 				},
 			},
 		}
-		actual, err := s.doHandleBatch(httpRequest, requestBundle, &fhirClient)
+		actual, err := s.doHandleBatch(httpRequest, requestBundle, &fhirClient, nil)
 
 		require.NoError(t, err)
 		require.Len(t, actual.Entry, 1)
@@ -68,7 +75,7 @@ func TestService_handleBatch(t *testing.T) {
 				},
 			},
 		}
-		actual, err := s.doHandleBatch(httpRequest, requestBundle, nil)
+		actual, err := s.doHandleBatch(httpRequest, requestBundle, nil, nil)
 
 		require.NoError(t, err)
 		require.Len(t, actual.Entry, 1)
@@ -113,7 +120,7 @@ func TestService_handleBatch(t *testing.T) {
 				},
 			},
 		}
-		actual, err := s.doHandleBatch(httpRequest, requestBundle, fhirClient)
+		actual, err := s.doHandleBatch(httpRequest, requestBundle, fhirClient, nil)
 
 		require.NoError(t, err)
 		require.Len(t, actual.Entry, 2)
@@ -241,7 +248,7 @@ func TestService_handleBatch(t *testing.T) {
 			},
 		}
 
-		actual, err := s.doHandleBatch(httpRequest, requestBundle, fhirClient)
+		actual, err := s.doHandleBatch(httpRequest, requestBundle, fhirClient, nil)
 
 		require.NoError(t, err)
 		require.Len(t, actual.Entry, 15, "Should return exactly 15 entries")
@@ -309,7 +316,7 @@ func TestService_handleBatch(t *testing.T) {
 			},
 		}
 
-		actual, err := s.doHandleBatch(httpRequest, requestBundle, fhirClient)
+		actual, err := s.doHandleBatch(httpRequest, requestBundle, fhirClient, nil)
 
 		require.NoError(t, err)
 		require.Len(t, actual.Entry, 8, "Should return exactly 8 entries")
@@ -395,7 +402,7 @@ func TestService_handleBatch(t *testing.T) {
 				},
 			},
 		}
-		actual, err := s.doHandleBatch(httpRequest, requestBundle, fhirClient)
+		actual, err := s.doHandleBatch(httpRequest, requestBundle, fhirClient, nil)
 
 		require.NoError(t, err)
 		require.Len(t, actual.Entry, 1)
@@ -425,7 +432,7 @@ func TestService_handleBatch(t *testing.T) {
 				},
 			},
 		}
-		actual, err := s.doHandleBatch(httpRequest, requestBundle, fhirClient)
+		actual, err := s.doHandleBatch(httpRequest, requestBundle, fhirClient, nil)
 
 		require.NoError(t, err)
 		require.Len(t, actual.Entry, 1)
@@ -449,7 +456,7 @@ func TestService_handleBatch(t *testing.T) {
 				},
 			},
 		}
-		actual, err := s.doHandleBatch(httpRequest, requestBundle, &fhirClient)
+		actual, err := s.doHandleBatch(httpRequest, requestBundle, &fhirClient, nil)
 
 		require.NoError(t, err)
 		require.Len(t, actual.Entry, 1)
@@ -463,3 +470,214 @@ func TestService_handleBatch(t *testing.T) {
 		require.Equal(t, "Upstream FHIR server error: network error", *outcome.Issue[0].Details.Text)
 	})
 }
+
+func TestService_handleBatch_writes(t *testing.T) {
+	bsn := fhir.Identifier{System: to.Ptr("http://fhir.nl/fhir/NamingSystem/bsn"), Value: to.Ptr("111222333")}
+	scpValidation := &ScpValidationResult{
+		carePlan: &fhir.CarePlan{Subject: fhir.Reference{Identifier: &bsn}},
+	}
+	contributorTag := fhir.Coding{
+		System:  to.Ptr(ContributorTagSystem),
+		Code:    to.Ptr("http://fhir.nl/fhir/NamingSystem/ura|1"),
+		Display: auth.TestPrincipal1.Organization.Name,
+	}
+	newRequest := func(batchWrite tenants.BatchWriteProperties) *http.Request {
+		tenant := tenants.Test(func(properties *tenants.Properties) {
+			properties.BatchWrite = batchWrite
+		}).Sole()
+		ctx := tenants.WithTenant(context.Background(), tenant)
+		ctx = auth.WithPrincipal(ctx, *auth.TestPrincipal1)
+		httpRequest, _ := http.NewRequestWithContext(ctx, http.MethodPost, "/", nil)
+		httpRequest.Header.Add("X-Scp-Context", "valid")
+		return httpRequest
+	}
+	allowObservations := tenants.BatchWriteProperties{ResourceTypes: []string{"observation"}}
+	observation := fhir.Observation{
+		Subject: &fhir.Reference{Identifier: &bsn},
+		Meta: &fhir.Meta{
+			// Contributor tags set by the requester are replaced
+			Tag: []fhir.Coding{{System: to.Ptr(ContributorTagSystem), Code: to.Ptr("http://fhir.nl/fhir/NamingSystem/ura|2")}},
+		},
+	}
+	createEntry := func(resource any) fhir.BundleEntry {
+		return fhir.BundleEntry{
+			Resource: must.MarshalJSON(resource),
+			Request:  &fhir.BundleEntryRequest{Method: fhir.HTTPVerbPOST, Url: coolfhir.ResourceType(resource)},
+		}
+	}
+	s := &Service{config: Config{ParallelBatch: true}}
+
+	t.Run("create", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		fhirClient := mock.NewMockClient(ctrl)
+		var created fhir.Observation
+		fhirClient.EXPECT().CreateWithContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, resource any, result any, _ ...fhirclient.Option) error {
+				require.NoError(t, json.Unmarshal(resource.([]byte), &created))
+				*result.(*[]byte) = resource.([]byte)
+				return nil
+			})
+		requestBundle := fhir.Bundle{Entry: []fhir.BundleEntry{createEntry(observation)}}
+
+		actual, err := s.doHandleBatch(newRequest(allowObservations), requestBundle, fhirClient, scpValidation)
+
+		require.NoError(t, err)
+		require.Len(t, actual.Entry, 1)
+		require.Nil(t, actual.Entry[0].Response.Outcome)
+		require.Equal(t, []fhir.Coding{contributorTag}, created.Meta.Tag)
+	})
+	t.Run("writes not enabled for tenant", func(t *testing.T) {
+		requestBundle := fhir.Bundle{Entry: []fhir.BundleEntry{createEntry(observation)}}
+
+		actual, err := s.doHandleBatch(newRequest(tenants.BatchWriteProperties{}), requestBundle, nil, scpValidation)
+
+		require.NoError(t, err)
+		require.Equal(t, "400 Bad Request", actual.Entry[0].Response.Status)
+		var outcome fhir.OperationOutcome
+		require.NoError(t, json.Unmarshal(actual.Entry[0].Response.Outcome, &outcome))
+		require.Equal(t, "Only GET requests are supported in batch processing", *outcome.Issue[0].Details.Text)
+	})
+	t.Run("resource type not allowed", func(t *testing.T) {
+		requestBundle := fhir.Bundle{Entry: []fhir.BundleEntry{createEntry(fhir.DocumentReference{Subject: &fhir.Reference{Identifier: &bsn}})}}
+
+		actual, err := s.doHandleBatch(newRequest(allowObservations), requestBundle, nil, scpValidation)
+
+		require.NoError(t, err)
+		require.Equal(t, "403 Forbidden", actual.Entry[0].Response.Status)
+		var outcome fhir.OperationOutcome
+		require.NoError(t, json.Unmarshal(actual.Entry[0].Response.Outcome, &outcome))
+		require.Equal(t, "resource type DocumentReference may not be written in batch processing", *outcome.Issue[0].Details.Text)
+	})
+	t.Run("resource of other patient", func(t *testing.T) {
+		otherPatient := fhir.Observation{Subject: &fhir.Reference{Identifier: &fhir.Identifier{System: bsn.System, Value: to.Ptr("999")}}}
+		requestBundle := fhir.Bundle{Entry: []fhir.BundleEntry{createEntry(otherPatient)}}
+
+		actual, err := s.doHandleBatch(newRequest(allowObservations), requestBundle, mock.NewMockClient(gomock.NewController(t)), scpValidation)
+
+		require.NoError(t, err)
+		require.Equal(t, "403 Forbidden", actual.Entry[0].Response.Status)
+	})
+	t.Run("update", func(t *testing.T) {
+		updateEntry := fhir.BundleEntry{
+			Resource: must.MarshalJSON(fhir.Observation{Id: to.Ptr("1"), Subject: &fhir.Reference{Identifier: &bsn}}),
+			Request:  &fhir.BundleEntryRequest{Method: fhir.HTTPVerbPUT, Url: "Observation/1"},
+		}
+		requestBundle := fhir.Bundle{Entry: []fhir.BundleEntry{updateEntry}}
+		t.Run("contributed by requester", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			fhirClient := mock.NewMockClient(ctrl)
+			fhirClient.EXPECT().ReadWithContext(gomock.Any(), "Observation/1", gomock.Any()).
+				DoAndReturn(func(_ context.Context, _ string, target any, _ ...fhirclient.Option) error {
+					*target.(*fhir.Resource) = fhir.Resource{Meta: &fhir.Meta{Tag: []fhir.Coding{contributorTag}}}
+					return nil
+				})
+			fhirClient.EXPECT().UpdateWithContext(gomock.Any(), "Observation/1", gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+
+			actual, err := s.doHandleBatch(newRequest(allowObservations), requestBundle, fhirClient, scpValidation)
+
+			require.NoError(t, err)
+			require.Nil(t, actual.Entry[0].Response.Outcome)
+		})
+		t.Run("not contributed by requester", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			fhirClient := mock.NewMockClient(ctrl)
+			fhirClient.EXPECT().ReadWithContext(gomock.Any(), "Observation/1", gomock.Any()).Return(nil)
+
+			actual, err := s.doHandleBatch(newRequest(allowObservations), requestBundle, fhirClient, scpValidation)
+
+			require.NoError(t, err)
+			require.Equal(t, "403 Forbidden", actual.Entry[0].Response.Status)
+		})
+		t.Run("URL doesn't match resource", func(t *testing.T) {
+			entry := deep.Copy(updateEntry)
+			entry.Request.Url = "Observation/2"
+
+			actual, err := s.doHandleBatch(newRequest(allowObservations), fhir.Bundle{Entry: []fhir.BundleEntry{entry}}, nil, scpValidation)
+
+			require.NoError(t, err)
+			require.Equal(t, "400 Bad Request", actual.Entry[0].Response.Status)
+		})
+	})
+	t.Run("transaction", func(t *testing.T) {
+		properties := allowObservations
+		properties.Transaction = true
+		requestBundle := fhir.Bundle{
+			Entry: []fhir.BundleEntry{
+				createEntry(observation),
+				{Request: &fhir.BundleEntryRequest{Method: fhir.HTTPVerbGET, Url: "Observation?patient=Patient/1"}},
+			},
+		}
+		t.Run("ok", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			fhirClient := mock.NewMockClient(ctrl)
+			var transaction fhir.Bundle
+			fhirClient.EXPECT().CreateWithContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, resource any, result any, _ ...fhirclient.Option) error {
+					transaction = resource.(fhir.Bundle)
+					*result.(*fhir.Bundle) = fhir.Bundle{
+						Type: fhir.BundleTypeTransactionResponse,
+						Entry: []fhir.BundleEntry{
+							{Response: &fhir.BundleEntryResponse{Status: "201 Created", Location: to.Ptr("Observation/1/_history/1")}},
+							{Response: &fhir.BundleEntryResponse{Status: "200 OK"}},
+						},
+					}
+					return nil
+				})
+
+			actual, err := s.doHandleBatch(newRequest(properties), requestBundle, fhirClient, scpValidation)
+
+			require.NoError(t, err)
+			require.Equal(t, fhir.BundleTypeTransaction, transaction.Type)
+			require.Len(t, transaction.Entry, 2)
+			var created fhir.Observation
+			require.NoError(t, json.Unmarshal(transaction.Entry[0].Resource, &created))
+			require.Equal(t, []fhir.Coding{contributorTag}, created.Meta.Tag)
+			require.Equal(t, fhir.BundleTypeBatchResponse, actual.Type)
+			require.Len(t, actual.Entry, 2)
+			require.Equal(t, "201 Created", actual.Entry[0].Response.Status)
+		})
+		t.Run("entries referring to each other through fullUrl", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			fhirClient := mock.NewMockClient(ctrl)
+			var transaction fhir.Bundle
+			fhirClient.EXPECT().CreateWithContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, resource any, result any, _ ...fhirclient.Option) error {
+					transaction = resource.(fhir.Bundle)
+					*result.(*fhir.Bundle) = fhir.Bundle{
+						Type: fhir.BundleTypeTransactionResponse,
+						Entry: []fhir.BundleEntry{
+							{Response: &fhir.BundleEntryResponse{Status: "201 Created", Location: to.Ptr("Observation/1/_history/1")}},
+							{Response: &fhir.BundleEntryResponse{Status: "201 Created", Location: to.Ptr("Observation/2/_history/1")}},
+						},
+					}
+					return nil
+				})
+			member := createEntry(observation)
+			member.FullUrl = to.Ptr("urn:uuid:member")
+			panel := createEntry(fhir.Observation{
+				Subject:   &fhir.Reference{Identifier: &bsn},
+				HasMember: []fhir.Reference{{Reference: to.Ptr("urn:uuid:member")}},
+			})
+			panel.FullUrl = to.Ptr("urn:uuid:panel")
+			requestBundle := fhir.Bundle{Entry: []fhir.BundleEntry{member, panel}}
+
+			_, err := s.doHandleBatch(newRequest(properties), requestBundle, fhirClient, scpValidation)
+
+			require.NoError(t, err)
+			require.Len(t, transaction.Entry, 2)
+			require.Equal(t, "urn:uuid:member", *transaction.Entry[0].FullUrl)
+			require.Equal(t, "urn:uuid:panel", *transaction.Entry[1].FullUrl)
+			var created fhir.Observation
+			require.NoError(t, json.Unmarshal(transaction.Entry[1].Resource, &created))
+			require.Equal(t, "urn:uuid:member", *created.HasMember[0].Reference)
+		})
+		t.Run("unauthorized entry fails the whole batch", func(t *testing.T) {
+			requestBundle := deep.Copy(requestBundle)
+			requestBundle.Entry = append(requestBundle.Entry, createEntry(fhir.DocumentReference{}))
+
+			_, err := s.doHandleBatch(newRequest(properties), requestBundle, mock.NewMockClient(gomock.NewController(t)), scpValidation)
+
+			require.EqualError(t, err, "entry 2: resource type DocumentReference may not be written in batch processing")
+		})
+	})
+}
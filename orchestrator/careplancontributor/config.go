@@ -22,7 +22,9 @@ func DefaultConfig() Config {
 		FrontendConfig: FrontendConfig{
 			URL: "/frontend/enrollment",
 		},
-		ParallelBatch: true,
+		ParallelBatch:           true,
+		HealthDataViewRateLimit: 600,
+		AggregatedSearchTimeout: defaultAggregatedSearchTimeout,
 	}
 }
 
@@ -33,9 +35,14 @@ type Config struct {
 	TaskFiller                    TaskFillerConfig `koanf:"taskfiller"`
 	Enabled                       bool             `koanf:"enabled"`
 	HealthDataViewEndpointEnabled bool             `koanf:"healthdataviewendpointenabled"`
-	SessionTimeout                time.Duration    `koanf:"sessiontimeout"`
-	ParallelBatch                 bool             `koanf:"parallelbatch"`
-	StaticBearerToken             string
+	// HealthDataViewRateLimit is the maximum number of requests per minute per requesting organization on the health data view endpoint.
+	// A value of 0 disables rate limiting.
+	HealthDataViewRateLimit int           `koanf:"healthdataviewratelimit"`
+	SessionTimeout          time.Duration `koanf:"sessiontimeout"`
+	ParallelBatch           bool          `koanf:"parallelbatch"`
+	// AggregatedSearchTimeout is the time each CareTeam member's SCP-node gets to respond to an aggregated search.
+	AggregatedSearchTimeout time.Duration `koanf:"aggregatedsearchtimeout"`
+	StaticBearerToken       string
 }
 
 func (c Config) Validate() error {
@@ -62,6 +69,9 @@ type TaskFillerConfig struct {
 	// also because HAPI doesn't allow storing Questionnaires in partitions.
 	QuestionnaireFHIR     coolfhir.ClientConfig `koanf:"questionnairefhir"`
 	QuestionnaireSyncURLs []string              `koanf:"questionnairesyncurls"`
+	// TerminologyURLs contains the URLs of FHIR Bundles with CodeSystems and ValueSets,
+	// used to match service and condition codes to workflows when Questionnaires and HealthcareServices are kept in-memory.
+	TerminologyURLs []string `koanf:"terminologyurls"`
 	// The bundle will contain the Task, Patient, and other relevant resources.
 	TaskAcceptedBundleEndpoint string `koanf:"taskacceptedbundleendpoint"`
 	// StatusNote contains notes that'll be added on the Task when a Task status is updated.
@@ -77,6 +87,13 @@ func (c TaskFillerConfig) Validate() error {
 			return errors.New("questionnairesyncurls must be http, https or file URLs")
 		}
 	}
+	for _, u := range c.TerminologyURLs {
+		if !strings.HasPrefix(u, "http://") &&
+			!strings.HasPrefix(u, "https://") &&
+			!strings.HasPrefix(u, "file://") {
+			return errors.New("terminologyurls must be http, https or file URLs")
+		}
+	}
 	return c.QuestionnaireFHIR.Validate()
 }
 
@@ -0,0 +1,118 @@
+package ehr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/events"
+	"github.com/SanteonNL/orca/orchestrator/lib/debug"
+	"github.com/SanteonNL/orca/orchestrator/lib/logging"
+	"github.com/SanteonNL/orca/orchestrator/lib/otel"
+	"github.com/SanteonNL/orca/orchestrator/messaging"
+	"github.com/pkg/errors"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	baseotel "go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var _ events.Type = &CommunicationReceivedEvent{}
+
+// CommunicationReceivedEvent is published when the local care organization received a Communication (secure message)
+// from another member of a CarePlan's CareTeam. The notifier delivers it to the EHR, if the tenant configured an endpoint for it.
+type CommunicationReceivedEvent struct {
+	FHIRBaseURL   string             `json:"fhirBaseURL"`
+	Communication fhir.Communication `json:"communication"`
+	TenantID      string             `json:"tenantId"`
+	// MessageID identifies the delivery to the EHR. It's derived from the Communication's URL and version when the event is published,
+	// so redeliveries of the event and repeated notifications of the same version use the same ID, allowing the EHR to deduplicate.
+	MessageID string `json:"messageId"`
+}
+
+func (c CommunicationReceivedEvent) Entity() messaging.Entity {
+	return messaging.Entity{
+		Name:   "orca.ehr.communication-received",
+		Prefix: true,
+	}
+}
+
+func (c CommunicationReceivedEvent) Instance() events.Type {
+	return &CommunicationReceivedEvent{}
+}
+
+// processCommunicationReceivedEvent delivers a received Communication to the EHR's Communication endpoint, so it appears in the EHR's inbox.
+// If it returns an error, the message broker redelivers the event. If the EHR responds with a bad request, retrying won't help,
+// so the Communication is dropped.
+func (n *notifier) processCommunicationReceivedEvent(ctx context.Context, event *CommunicationReceivedEvent) error {
+	ctx, span := tracer.Start(
+		ctx,
+		debug.GetFullCallerName(),
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String(otel.FHIRBaseURL, event.FHIRBaseURL),
+			attribute.String(otel.FHIRResourceID, *event.Communication.Id),
+		),
+	)
+	defer span.End()
+
+	tenant, err := n.tenants.Get(event.TenantID)
+	if err != nil {
+		return otel.Error(span, errors.Wrapf(err, "failed to get tenant of communication-received event (tenant-id=%s)", event.TenantID))
+	}
+	ctx = tenants.WithTenant(ctx, *tenant)
+	endpoint := tenant.CommunicationNotification.Endpoint
+	if endpoint == "" {
+		slog.DebugContext(ctx, "No EHR endpoint configured for Communications, skipping",
+			slog.String(logging.FieldResourceID, *event.Communication.Id),
+			slog.String(logging.FieldResourceType, fhir.ResourceTypeCommunication.String()),
+		)
+		span.SetStatus(codes.Ok, "no endpoint configured, skipping")
+		return nil
+	}
+	httpClient, err := n.httpClient(*tenant)
+	if err != nil {
+		return otel.Error(span, err)
+	}
+
+	data, err := json.Marshal(event.Communication)
+	if err != nil {
+		return otel.Error(span, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return otel.Error(span, errors.Wrap(err, "failed to create HTTP request"))
+	}
+	req.Header.Set("Content-Type", "application/fhir+json")
+	req.Header.Set("Idempotency-Key", event.MessageID)
+	baseotel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	slog.InfoContext(ctx, "Sending Communication to EHR",
+		slog.String(logging.FieldResourceID, *event.Communication.Id),
+		slog.String(logging.FieldResourceType, fhir.ResourceTypeCommunication.String()),
+		slog.String(logging.FieldEndpoint, endpoint),
+	)
+	httpResponse, err := httpClient.Do(req)
+	if err != nil {
+		return otel.Error(span, errors.Wrap(err, "failed to send Communication to EHR"))
+	}
+	defer httpResponse.Body.Close()
+	if httpResponse.StatusCode == http.StatusBadRequest {
+		slog.WarnContext(ctx, "EHR can't process Communication, dropping it",
+			slog.String(logging.FieldResourceID, *event.Communication.Id),
+			slog.String(logging.FieldResourceType, fhir.ResourceTypeCommunication.String()),
+		)
+		span.SetStatus(codes.Ok, "EHR responded with bad request, dropped")
+		return nil
+	}
+	if httpResponse.StatusCode < 200 || httpResponse.StatusCode >= 300 {
+		return otel.Error(span, errors.Errorf("failed to send Communication to EHR, status code: %d", httpResponse.StatusCode))
+	}
+	span.SetStatus(codes.Ok, "")
+	return nil
+}
@@ -0,0 +1,98 @@
+package ehr
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/events"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/SanteonNL/orca/orchestrator/messaging"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+func TestNotifier_CommunicationReceived(t *testing.T) {
+	event := &CommunicationReceivedEvent{
+		FHIRBaseURL: "https://example.com/cps",
+		Communication: fhir.Communication{
+			Id:      to.Ptr("1"),
+			Payload: []fhir.CommunicationPayload{{ContentString: to.Ptr("Hello")}},
+		},
+		MessageID: "message-1",
+	}
+	setup := func(t *testing.T, statusCode int, capture func(r *http.Request)) (events.Manager, *messaging.MemoryBroker, tenants.Config) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if capture != nil {
+				capture(r)
+			}
+			w.WriteHeader(statusCode)
+		}))
+		t.Cleanup(server.Close)
+		tenantCfg := tenants.Test(func(properties *tenants.Properties) {
+			properties.CommunicationNotification.Endpoint = server.URL
+		})
+		messageBroker := messaging.NewMemoryBroker()
+		eventManager := events.NewManager(messageBroker)
+		_, err := NewNotifier(eventManager, tenantCfg, "", nil, nil)
+		require.NoError(t, err)
+		return eventManager, messageBroker, tenantCfg
+	}
+	t.Run("Communication is delivered to EHR", func(t *testing.T) {
+		var capturedRequest *http.Request
+		var capturedCommunication fhir.Communication
+		eventManager, messageBroker, tenantCfg := setup(t, http.StatusCreated, func(r *http.Request) {
+			capturedRequest = r
+			_ = json.NewDecoder(r.Body).Decode(&capturedCommunication)
+		})
+		event := *event
+		event.TenantID = tenantCfg.Sole().ID
+
+		err := eventManager.Notify(context.Background(), &event)
+
+		require.NoError(t, err)
+		require.Nil(t, messageBroker.LastHandlerError.Load())
+		require.NotNil(t, capturedRequest)
+		require.Equal(t, "message-1", capturedRequest.Header.Get("Idempotency-Key"))
+		require.Equal(t, "application/fhir+json", capturedRequest.Header.Get("Content-Type"))
+		require.Equal(t, "Hello", *capturedCommunication.Payload[0].ContentString)
+	})
+	t.Run("failed delivery is returned to message broker for redelivery", func(t *testing.T) {
+		eventManager, messageBroker, tenantCfg := setup(t, http.StatusServiceUnavailable, nil)
+		event := *event
+		event.TenantID = tenantCfg.Sole().ID
+
+		err := eventManager.Notify(context.Background(), &event)
+
+		require.NoError(t, err)
+		handlerErr := messageBroker.LastHandlerError.Load()
+		require.NotNil(t, handlerErr)
+		require.ErrorContains(t, *handlerErr, "status code: 503")
+	})
+	t.Run("Communication rejected by EHR is dropped", func(t *testing.T) {
+		eventManager, messageBroker, tenantCfg := setup(t, http.StatusBadRequest, nil)
+		event := *event
+		event.TenantID = tenantCfg.Sole().ID
+
+		err := eventManager.Notify(context.Background(), &event)
+
+		require.NoError(t, err)
+		require.Nil(t, messageBroker.LastHandlerError.Load())
+	})
+	t.Run("no endpoint configured", func(t *testing.T) {
+		messageBroker := messaging.NewMemoryBroker()
+		eventManager := events.NewManager(messageBroker)
+		_, err := NewNotifier(eventManager, tenants.Test(), "", nil, nil)
+		require.NoError(t, err)
+		event := *event
+		event.TenantID = tenants.Test().Sole().ID
+
+		err = eventManager.Notify(context.Background(), &event)
+
+		require.NoError(t, err)
+		require.Nil(t, messageBroker.LastHandlerError.Load())
+	})
+}
@@ -25,9 +25,22 @@ import (
 
 var tracer = baseotel.Tracer("careplancontributor.ehr")
 
+// BundleSetEventTaskAccepted indicates the BundleSet is sent because the Task was accepted.
+const BundleSetEventTaskAccepted = "task-accepted"
+
+// BundleSetEventTaskReviewRequested indicates the BundleSet is sent because the Task awaits review by a care professional.
+const BundleSetEventTaskReviewRequested = "task-review-requested"
+
+// TaskStatusChangedEvent returns the BundleSet event indicating the Task transitioned to the given status, e.g. task-completed.
+func TaskStatusChangedEvent(status fhir.TaskStatus) string {
+	return "task-" + status.Code()
+}
+
 // BundleSet represents a collection of FHIR bundles associated with a specific task, identified by an ID.
 type BundleSet struct {
-	Id      string
+	Id string
+	// Event indicates why the BundleSet was sent, e.g. task-accepted.
+	Event   string `json:"event,omitempty"`
 	task    string
 	Bundles []fhir.Bundle `json:"bundles"`
 }
@@ -88,6 +101,15 @@ func TaskNotificationBundleSet(ctx context.Context, cpsClient fhirclient.Client,
 		return nil, otel.Error(span, err, "failed to fetch patient")
 	}
 
+	// The EHR identifies the patient by BSN, not by the pseudonym the Care Plan Service might have issued for the CarePlan
+	if bsn := patientBSN(*patientBundle); bsn != nil {
+		for _, bundle := range []*fhir.Bundle{taskBundle, carePlanBundle} {
+			if err := depseudonymizeBundle(bundle, *bsn); err != nil {
+				return nil, otel.Error(span, err, "failed to depseudonymize patient identifiers")
+			}
+		}
+	}
+
 	serviceRequestBundle, err := fetchServiceRequest(ctx, cpsClient, tasks)
 	if err != nil {
 		return nil, otel.Error(span, err, "failed to fetch service request")
@@ -5,17 +5,25 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
+	"time"
 
 	fhirclient "github.com/SanteonNL/go-fhir-client"
 	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
 	"github.com/SanteonNL/orca/orchestrator/events"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
 	"github.com/SanteonNL/orca/orchestrator/lib/debug"
 	"github.com/SanteonNL/orca/orchestrator/lib/logging"
 	"github.com/SanteonNL/orca/orchestrator/lib/otel"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
 	"github.com/SanteonNL/orca/orchestrator/messaging"
+	"github.com/google/uuid"
+	"github.com/jellydator/ttlcache/v3"
 	"github.com/pkg/errors"
 	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
 	baseotel "go.opentelemetry.io/otel"
@@ -23,14 +31,20 @@ import (
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/oauth2"
 )
 
+const httpClientSpanNameBase = "ehr.notifier"
+
 var _ events.Type = &TaskAcceptedEvent{}
 
 type TaskAcceptedEvent struct {
 	FHIRBaseURL string    `json:"fhirBaseURL"`
 	Task        fhir.Task `json:"task"`
 	TenantID    string    `json:"tenantId"`
+	// BundleSetID is the ID of the BundleSet sent to the EHR. It's determined when the event is published,
+	// so redeliveries of the event use the same ID, allowing the EHR to deduplicate.
+	BundleSetID string `json:"bundleSetId,omitempty"`
 }
 
 func (t TaskAcceptedEvent) Entity() messaging.Entity {
@@ -44,9 +58,63 @@ func (t TaskAcceptedEvent) Instance() events.Type {
 	return &TaskAcceptedEvent{}
 }
 
+var _ events.Type = &TaskReviewRequestedEvent{}
+
+// TaskReviewRequestedEvent is published when a Task awaits review by a care professional. The notifier delivers the BundleSet of the Task to the EHR,
+// and retries if that fails.
+type TaskReviewRequestedEvent struct {
+	FHIRBaseURL string    `json:"fhirBaseURL"`
+	Task        fhir.Task `json:"task"`
+	TenantID    string    `json:"tenantId"`
+	// BundleSetID is the ID of the BundleSet sent to the EHR. It's determined when the event is published,
+	// so redeliveries of the event use the same ID, allowing the EHR to deduplicate.
+	BundleSetID string `json:"bundleSetId,omitempty"`
+}
+
+func (t TaskReviewRequestedEvent) Entity() messaging.Entity {
+	return messaging.Entity{
+		Name:   "orca.taskengine.task-review-requested",
+		Prefix: true,
+	}
+}
+
+func (t TaskReviewRequestedEvent) Instance() events.Type {
+	return &TaskReviewRequestedEvent{}
+}
+
+var _ events.Type = &TaskTransitionedEvent{}
+
+// TaskTransitionedEvent is published when a Task of which the local care organization is requester or owner transitioned to a status
+// the tenant configured the EHR to be notified of. The notifier delivers the BundleSet of the Task to the EHR, and retries if that fails.
+type TaskTransitionedEvent struct {
+	FHIRBaseURL string    `json:"fhirBaseURL"`
+	Task        fhir.Task `json:"task"`
+	TenantID    string    `json:"tenantId"`
+	// BundleSetID is the ID of the BundleSet sent to the EHR. It's determined when the event is published,
+	// so redeliveries of the event use the same ID, allowing the EHR to deduplicate.
+	BundleSetID string `json:"bundleSetId,omitempty"`
+}
+
+func (t TaskTransitionedEvent) Entity() messaging.Entity {
+	return messaging.Entity{
+		Name:   "orca.taskengine.task-transitioned",
+		Prefix: true,
+	}
+}
+
+func (t TaskTransitionedEvent) Instance() events.Type {
+	return &TaskTransitionedEvent{}
+}
+
 // Notifier is an interface for sending notifications regarding task acceptance within a FHIR-based system.
 type Notifier interface {
 	NotifyTaskAccepted(ctx context.Context, fhirBaseURL string, task *fhir.Task) error
+	// NotifyTaskReviewRequested notifies the EHR that a Task completed Questionnaire negotiation,
+	// and now awaits review by a care professional before it can be accepted or rejected.
+	NotifyTaskReviewRequested(ctx context.Context, fhirBaseURL string, task *fhir.Task) error
+	// NotifyTaskStatusChanged notifies the EHR that a Task of which the local care organization is requester or owner
+	// transitioned to its current status, if the tenant configured the EHR to be notified of that status.
+	NotifyTaskStatusChanged(ctx context.Context, fhirBaseURL string, task *fhir.Task) error
 }
 
 // notifier is a type that uses a ServiceBusClient to send messages to a message broker.
@@ -55,15 +123,44 @@ type notifier struct {
 	eventManager               events.Manager
 	fhirClientFactory          func(ctx context.Context, fhirBaseURL *url.URL) (fhirclient.Client, *http.Client, error)
 	taskAcceptedBundleEndpoint string
+	// ehrFHIRClients contains the FHIR clients for the EHR's FHIR API, per tenant.
+	// They're used for tenants that have Task data written into their EHR's FHIR API.
+	ehrFHIRClients map[string]fhirclient.Client
+	// httpClients contains the HTTP clients for sending BundleSets to the EHR, per tenant.
+	httpClients sync.Map
+	// writtenTaskVersions contains the version of each Task (by URL) this notifier updated when it recorded the resources written into the EHR.
+	// The update causes a notification of the Task, which must not cause the Task to be delivered to the EHR again.
+	writtenTaskVersions *ttlcache.Cache[string, string]
+	// taskStatuses contains the last status this notifier observed for each Task (by URL), to detect status transitions:
+	// Task notifications are also received for updates that don't change the status.
+	taskStatuses *ttlcache.Cache[string, fhir.TaskStatus]
 }
 
+// writtenTaskVersionsTTL specifies how long the Task versions written by the notifier are remembered.
+// The notification of the update is typically received within seconds.
+const writtenTaskVersionsTTL = time.Hour
+
+// taskStatusesTTL specifies how long the last observed status of a Task is remembered.
+// If it's unknown (e.g. after a restart), a Task with a configured status is considered to have transitioned to it.
+const taskStatusesTTL = 7 * 24 * time.Hour
+
 // NewNotifier creates and returns a Notifier implementation using the provided ServiceBusClient for message handling.
-func NewNotifier(eventManager events.Manager, tenants tenants.Config, taskAcceptedBundleEndpoint string, fhirClientFactory func(ctx context.Context, fhirBaseURL *url.URL) (fhirclient.Client, *http.Client, error)) (Notifier, error) {
+func NewNotifier(eventManager events.Manager, tenants tenants.Config, taskAcceptedBundleEndpoint string, fhirClientFactory func(ctx context.Context, fhirBaseURL *url.URL) (fhirclient.Client, *http.Client, error),
+	ehrFHIRClients map[string]fhirclient.Client) (Notifier, error) {
 	n := &notifier{
 		eventManager:               eventManager,
 		fhirClientFactory:          fhirClientFactory,
 		tenants:                    tenants,
 		taskAcceptedBundleEndpoint: taskAcceptedBundleEndpoint,
+		ehrFHIRClients:             ehrFHIRClients,
+		writtenTaskVersions: ttlcache.New[string, string](
+			ttlcache.WithTTL[string, string](writtenTaskVersionsTTL),
+			ttlcache.WithCapacity[string, string](10000),
+		),
+		taskStatuses: ttlcache.New[string, fhir.TaskStatus](
+			ttlcache.WithTTL[string, fhir.TaskStatus](taskStatusesTTL),
+			ttlcache.WithCapacity[string, fhir.TaskStatus](100000),
+		),
 	}
 	if err := n.start(); err != nil {
 		return nil, err
@@ -71,7 +168,8 @@ func NewNotifier(eventManager events.Manager, tenants tenants.Config, taskAccept
 	return n, nil
 }
 
-// NotifyTaskAccepted sends notification data comprehensively related to a specific FHIR Task to a message broker.
+// NotifyTaskAccepted publishes an event for the accepted Task to the message broker,
+// which delivers the BundleSet of the Task to the EHR and retries if that fails.
 func (n *notifier) NotifyTaskAccepted(ctx context.Context, fhirBaseURL string, task *fhir.Task) error {
 	ctx, span := tracer.Start(
 		ctx,
@@ -90,18 +188,163 @@ func (n *notifier) NotifyTaskAccepted(ctx context.Context, fhirBaseURL string, t
 		return otel.Error(span, err)
 	}
 
-	// Process the task notification synchronously to propagate errors
 	event := TaskAcceptedEvent{
 		TenantID:    tenant.ID,
 		FHIRBaseURL: fhirBaseURL,
 		Task:        *task,
+		BundleSetID: uuid.NewString(),
+	}
+	span.SetAttributes(attribute.String(otel.FHIRBundleSetId, event.BundleSetID))
+	if err := n.eventManager.Notify(ctx, &event); err != nil {
+		return otel.Error(span, errors.Wrap(err, "failed to publish task-accepted event"))
+	}
+	span.SetStatus(codes.Ok, "")
+	return nil
+}
+
+// NotifyTaskReviewRequested publishes an event for a Task that awaits manual review to the message broker,
+// which delivers the BundleSet of the Task to the EHR and retries if that fails.
+func (n *notifier) NotifyTaskReviewRequested(ctx context.Context, fhirBaseURL string, task *fhir.Task) error {
+	ctx, span := tracer.Start(
+		ctx,
+		debug.GetFullCallerName(),
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			attribute.String(otel.FHIRBaseURL, fhirBaseURL),
+			attribute.String(otel.FHIRTaskID, *task.Id),
+			attribute.String(otel.FHIRTaskStatus, task.Status.Code()),
+		),
+	)
+	defer span.End()
+
+	tenant, err := tenants.FromContext(ctx)
+	if err != nil {
+		return otel.Error(span, err)
+	}
+
+	event := TaskReviewRequestedEvent{
+		TenantID:    tenant.ID,
+		FHIRBaseURL: fhirBaseURL,
+		Task:        *task,
+		BundleSetID: uuid.NewString(),
+	}
+	span.SetAttributes(attribute.String(otel.FHIRBundleSetId, event.BundleSetID))
+	if err := n.eventManager.Notify(ctx, &event); err != nil {
+		return otel.Error(span, errors.Wrap(err, "failed to publish task-review-requested event"))
+	}
+	span.SetStatus(codes.Ok, "")
+	return nil
+}
+
+// NotifyTaskStatusChanged publishes an event for a Task that changed status to the message broker, if the tenant configured the EHR
+// to be notified of Tasks transitioning to that status. The event delivers the BundleSet of the Task to the EHR and retries if that fails.
+// Notifications of Tasks of which the status didn't change since the previous notification are ignored.
+func (n *notifier) NotifyTaskStatusChanged(ctx context.Context, fhirBaseURL string, task *fhir.Task) error {
+	ctx, span := tracer.Start(
+		ctx,
+		debug.GetFullCallerName(),
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			attribute.String(otel.FHIRBaseURL, fhirBaseURL),
+			attribute.String(otel.FHIRTaskID, *task.Id),
+			attribute.String(otel.FHIRTaskStatus, task.Status.Code()),
+		),
+	)
+	defer span.End()
+
+	tenant, err := tenants.FromContext(ctx)
+	if err != nil {
+		return otel.Error(span, err)
+	}
+	if n.isWrittenTaskVersion(fhirBaseURL, *task) {
+		slog.DebugContext(ctx, "Task version was written by this notifier (recording EHR resources), skipping",
+			slog.String(logging.FieldResourceID, *task.Id),
+			slog.String(logging.FieldResourceType, fhir.ResourceTypeTask.String()),
+		)
+		span.SetStatus(codes.Ok, "Task version written by notifier, skipping")
+		return nil
+	}
+	key := taskURL(fhirBaseURL, *task.Id)
+	var previous *fhir.TaskStatus
+	if item := n.taskStatuses.Get(key); item != nil {
+		previous = to.Ptr(item.Value())
+	}
+	n.taskStatuses.Set(key, task.Status, ttlcache.DefaultTTL)
+	if previous != nil && *previous == task.Status {
+		slog.DebugContext(ctx, "Task status didn't change, skipping",
+			slog.String(logging.FieldResourceID, *task.Id),
+			slog.String(logging.FieldResourceType, fhir.ResourceTypeTask.String()),
+			slog.String("status", task.Status.Code()),
+		)
+		span.SetStatus(codes.Ok, "Task status didn't change, skipping")
+		return nil
+	}
+	if !tenant.TaskNotification.Enabled(task.Status) {
+		slog.DebugContext(ctx, "EHR isn't notified of Tasks with this status, skipping",
+			slog.String(logging.FieldResourceID, *task.Id),
+			slog.String(logging.FieldResourceType, fhir.ResourceTypeTask.String()),
+			slog.String("status", task.Status.Code()),
+		)
+		span.SetStatus(codes.Ok, "Task status not configured for notification, skipping")
+		return nil
+	}
+	event := TaskTransitionedEvent{
+		TenantID:    tenant.ID,
+		FHIRBaseURL: fhirBaseURL,
+		Task:        *task,
+		BundleSetID: uuid.NewString(),
+	}
+	span.SetAttributes(attribute.String(otel.FHIRBundleSetId, event.BundleSetID))
+	if err := n.eventManager.Notify(ctx, &event); err != nil {
+		// Forget the transition, so it's published when the notification is retried
+		if previous != nil {
+			n.taskStatuses.Set(key, *previous, ttlcache.DefaultTTL)
+		} else {
+			n.taskStatuses.Delete(key)
+		}
+		return otel.Error(span, errors.Wrap(err, "failed to publish task-transitioned event"))
 	}
+	span.SetStatus(codes.Ok, "")
+	return nil
+}
+
+// processTaskTransitionedEvent delivers the BundleSet of a Task that transitioned to a new status to the EHR.
+// If it returns an error, the message broker redelivers the event. If the EHR can't process the BundleSet,
+// the Task isn't rejected since it already transitioned.
+func (n *notifier) processTaskTransitionedEvent(ctx context.Context, event *TaskTransitionedEvent) error {
+	ctx, span := tracer.Start(
+		ctx,
+		debug.GetFullCallerName(),
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			attribute.String(otel.FHIRBaseURL, event.FHIRBaseURL),
+			attribute.String(otel.FHIRTaskID, *event.Task.Id),
+			attribute.String(otel.FHIRTaskStatus, event.Task.Status.Code()),
+		),
+	)
+	defer span.End()
 
+	if err := n.deliverBundleSet(ctx, event.TenantID, event.FHIRBaseURL, event.Task, event.BundleSetID, TaskStatusChangedEvent(event.Task.Status), false); err != nil {
+		var badRequest *BadRequest
+		if errors.As(err, &badRequest) {
+			// Retrying won't help
+			slog.WarnContext(ctx, "EHR can't process BundleSet of transitioned Task, dropping it",
+				slog.String(logging.FieldResourceID, *event.Task.Id),
+				slog.String(logging.FieldResourceType, fhir.ResourceTypeTask.String()),
+				slog.String(logging.FieldError, err.Error()),
+			)
+			span.SetStatus(codes.Ok, "EHR can't process BundleSet, dropping it")
+			return nil
+		}
+		return otel.Error(span, err)
+	}
 	span.SetStatus(codes.Ok, "")
-	return n.processTaskAcceptedEvent(ctx, &event)
+	return nil
 }
 
-// processTaskAcceptedEvent handles the actual task processing synchronously
+// processTaskAcceptedEvent delivers the BundleSet of an accepted Task to the EHR.
+// If it returns an error, the message broker redelivers the event. Errors that can't be resolved by retrying
+// (the EHR responding with a bad request) are handled by rejecting the Task, after which nil is returned.
 func (n *notifier) processTaskAcceptedEvent(ctx context.Context, event *TaskAcceptedEvent) error {
 	ctx, span := tracer.Start(
 		ctx,
@@ -115,70 +358,214 @@ func (n *notifier) processTaskAcceptedEvent(ctx context.Context, event *TaskAcce
 	)
 	defer span.End()
 
+	if err := n.deliverBundleSet(ctx, event.TenantID, event.FHIRBaseURL, event.Task, event.BundleSetID, BundleSetEventTaskAccepted, true); err != nil {
+		return otel.Error(span, err)
+	}
+	span.SetStatus(codes.Ok, "")
+	return nil
+}
+
+// processTaskReviewRequestedEvent delivers the BundleSet of a Task that awaits review to the EHR.
+// If it returns an error, the message broker redelivers the event. If the EHR responds with a bad request,
+// the Task is rejected (like accepted Tasks), after which nil is returned.
+func (n *notifier) processTaskReviewRequestedEvent(ctx context.Context, event *TaskReviewRequestedEvent) error {
+	ctx, span := tracer.Start(
+		ctx,
+		debug.GetFullCallerName(),
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			attribute.String(otel.FHIRBaseURL, event.FHIRBaseURL),
+			attribute.String(otel.FHIRTaskID, *event.Task.Id),
+			attribute.String(otel.FHIRTaskStatus, event.Task.Status.Code()),
+		),
+	)
+	defer span.End()
+
+	if err := n.deliverBundleSet(ctx, event.TenantID, event.FHIRBaseURL, event.Task, event.BundleSetID, BundleSetEventTaskReviewRequested, true); err != nil {
+		return otel.Error(span, err)
+	}
+	span.SetStatus(codes.Ok, "")
+	return nil
+}
+
+// deliverBundleSet builds the BundleSet of the given Task and sends it to the EHR endpoint configured for the Task's status.
+// If bundleSetID is set, it's used as ID of the BundleSet, otherwise a new ID is generated.
+// If the EHR responds with a bad request and rejectOnBadRequest is set, the Task is rejected since the EHR won't be able to process it.
+func (n *notifier) deliverBundleSet(ctx context.Context, tenantID string, fhirBaseURLValue string, task fhir.Task, bundleSetID string, eventName string, rejectOnBadRequest bool) error {
 	// Lookup tenant
-	tenant, err := n.tenants.Get(event.TenantID)
+	tenant, err := n.tenants.Get(tenantID)
 	if err != nil {
-		return otel.Error(span, errors.Wrapf(err, "failed to get from task accepted event (tenant-id=%s)", event.TenantID))
+		return errors.Wrapf(err, "failed to get tenant of %s event (tenant-id=%s)", eventName, tenantID)
 	}
 	ctx = tenants.WithTenant(ctx, *tenant)
 
-	fhirBaseURL, err := url.Parse(event.FHIRBaseURL)
+	fhirBaseURL, err := url.Parse(fhirBaseURLValue)
 	if err != nil {
-		return otel.Error(span, err)
+		return err
 	}
 
 	cpsClient, _, err := n.fhirClientFactory(ctx, fhirBaseURL)
 	if err != nil {
-		return otel.Error(span, errors.Wrap(err, "failed to create FHIR client for invoking CarePlanService"))
+		return errors.Wrap(err, "failed to create FHIR client for invoking CarePlanService")
 	}
 
-	bundles, err := TaskNotificationBundleSet(ctx, cpsClient, *event.Task.Id)
+	bundles, err := TaskNotificationBundleSet(ctx, cpsClient, *task.Id)
 	if err != nil {
-		return otel.Error(span, errors.Wrap(err, "failed to create task notification bundle"))
+		return errors.Wrap(err, "failed to create task notification bundle")
 	}
-	slog.InfoContext(
-		ctx,
-		"Sending set for task notifier started",
-		slog.String(logging.FieldResourceID, *event.Task.Id),
-		slog.String(logging.FieldResourceType, fhir.ResourceTypeTask.String()),
-		slog.String(logging.FieldEndpoint, n.taskAcceptedBundleEndpoint),
-	)
+	if bundleSetID != "" {
+		bundles.Id = bundleSetID
+	}
+	bundles.Event = eventName
 
-	err = sendBundle(ctx, n.taskAcceptedBundleEndpoint, *bundles)
+	if tenant.TaskNotification.Delivery == tenants.TaskDeliveryFHIR {
+		ehrClient, ok := n.ehrFHIRClients[tenant.ID]
+		if !ok {
+			return errors.Errorf("no EHR FHIR client configured for tenant %s", tenant.ID)
+		}
+		slog.InfoContext(
+			ctx,
+			"Writing Task data into EHR FHIR API",
+			slog.String(logging.FieldResourceID, *task.Id),
+			slog.String(logging.FieldResourceType, fhir.ResourceTypeTask.String()),
+			slog.String("event", eventName),
+		)
+		var writtenTask *fhir.Task
+		writtenTask, err = writeTransaction(ctx, cpsClient, ehrClient, task, *bundles)
+		if writtenTask != nil && writtenTask.Meta != nil && writtenTask.Meta.VersionId != nil {
+			n.writtenTaskVersions.Set(taskURL(fhirBaseURLValue, *task.Id), *writtenTask.Meta.VersionId, ttlcache.DefaultTTL)
+		}
+	} else {
+		endpoint := tenant.TaskNotification.EndpointFor(task.Status)
+		if endpoint == "" {
+			endpoint = tenant.TaskNotification.DefaultEndpoint
+		}
+		if endpoint == "" {
+			endpoint = n.taskAcceptedBundleEndpoint
+		}
+		if endpoint == "" {
+			return errors.Errorf("no EHR endpoint configured for %s event", eventName)
+		}
+		slog.InfoContext(
+			ctx,
+			"Sending set for task notifier started",
+			slog.String(logging.FieldResourceID, *task.Id),
+			slog.String(logging.FieldResourceType, fhir.ResourceTypeTask.String()),
+			slog.String(logging.FieldEndpoint, endpoint),
+			slog.String("event", eventName),
+		)
+		var httpClient *http.Client
+		httpClient, err = n.httpClient(*tenant)
+		if err != nil {
+			return err
+		}
+		err = sendBundle(ctx, httpClient, endpoint, *bundles)
+	}
 	if err != nil {
 		var badRequest *BadRequest
-		if errors.As(err, &badRequest) {
+		if errors.As(err, &badRequest) && rejectOnBadRequest {
 			// Handle BadRequest error specifically
 			slog.WarnContext(ctx, "Task enrollment failed due to bad request", slog.String(logging.FieldError, err.Error()))
-			task := event.Task
 			task.Status = fhir.TaskStatusRejected
 			task.StatusReason = &fhir.CodeableConcept{
 				Text: badRequest.Reason,
 			}
-			err = cpsClient.UpdateWithContext(ctx, "Task/"+*event.Task.Id, task, &task)
+			err = cpsClient.UpdateWithContext(ctx, "Task/"+*task.Id, task, &task)
 			if err != nil {
-				return otel.Error(span, errors.Wrap(err, "failed to update task status"))
+				return errors.Wrap(err, "failed to update task status")
 			}
 			return nil
 		}
 		return err
 	}
-
-	span.SetStatus(codes.Ok, "")
 	return nil
 }
 
+// isWrittenTaskVersion returns whether the given version of the Task was written by this notifier, when recording the resources written into the EHR.
+func (n *notifier) isWrittenTaskVersion(fhirBaseURL string, task fhir.Task) bool {
+	if task.Meta == nil || task.Meta.VersionId == nil {
+		return false
+	}
+	item := n.writtenTaskVersions.Get(taskURL(fhirBaseURL, *task.Id))
+	return item != nil && item.Value() == *task.Meta.VersionId
+}
+
+func taskURL(fhirBaseURL string, taskID string) string {
+	return strings.TrimSuffix(fhirBaseURL, "/") + "/Task/" + taskID
+}
+
+// httpClient returns the HTTP client for sending BundleSets to the EHR of the given tenant.
+func (n *notifier) httpClient(tenant tenants.Properties) (*http.Client, error) {
+	if client, ok := n.httpClients.Load(tenant.ID); ok {
+		return client.(*http.Client), nil
+	}
+	transport, err := endpointTransport(tenant.TaskNotification.Auth)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create HTTP client for EHR endpoint (tenant=%s)", tenant.ID)
+	}
+	actual, _ := n.httpClients.LoadOrStore(tenant.ID, otel.NewTracedHTTPClientWithTransport(httpClientSpanNameBase, transport))
+	return actual.(*http.Client), nil
+}
+
+// endpointTransport returns the HTTP transport that authenticates to the EHR's endpoints as configured for the tenant.
+func endpointTransport(auth tenants.EndpointAuthProperties) (http.RoundTripper, error) {
+	switch auth.Type {
+	case tenants.EndpointAuthNone:
+		return http.DefaultTransport, nil
+	case tenants.EndpointAuthMTLS:
+		return coolfhir.NewMutualTLSTransport(coolfhir.ClientCertificateConfig{
+			CertFile: auth.CertFile,
+			KeyFile:  auth.KeyFile,
+			CAFile:   auth.CAFile,
+		})
+	case tenants.EndpointAuthOAuth2ClientCredentials:
+		// The token source caches the access token, and requests a new one when it expires.
+		tokenSource, err := coolfhir.NewOAuth2ClientCredentialsTokenSource(coolfhir.AuthConfig{
+			Type:          coolfhir.OAuth2ClientCredentials,
+			TokenEndpoint: auth.TokenURL,
+			ClientID:      auth.ClientID,
+			ClientSecret:  auth.ClientSecret,
+			OAuth2Scopes:  auth.Scopes,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &oauth2.Transport{Source: tokenSource, Base: http.DefaultTransport}, nil
+	default:
+		return nil, fmt.Errorf("unsupported EHR endpoint authentication type: %s", auth.Type)
+	}
+}
+
 func (n *notifier) start() error {
-	return n.eventManager.Subscribe(TaskAcceptedEvent{}, func(ctx context.Context, rawEvent events.Type) error {
+	if err := n.eventManager.Subscribe(TaskAcceptedEvent{}, func(ctx context.Context, rawEvent events.Type) error {
 		event := rawEvent.(*TaskAcceptedEvent)
 		return n.processTaskAcceptedEvent(ctx, event)
+	}); err != nil {
+		return err
+	}
+	if err := n.eventManager.Subscribe(TaskReviewRequestedEvent{}, func(ctx context.Context, rawEvent events.Type) error {
+		event := rawEvent.(*TaskReviewRequestedEvent)
+		return n.processTaskReviewRequestedEvent(ctx, event)
+	}); err != nil {
+		return err
+	}
+	if err := n.eventManager.Subscribe(TaskTransitionedEvent{}, func(ctx context.Context, rawEvent events.Type) error {
+		event := rawEvent.(*TaskTransitionedEvent)
+		return n.processTaskTransitionedEvent(ctx, event)
+	}); err != nil {
+		return err
+	}
+	return n.eventManager.Subscribe(CommunicationReceivedEvent{}, func(ctx context.Context, rawEvent events.Type) error {
+		event := rawEvent.(*CommunicationReceivedEvent)
+		return n.processCommunicationReceivedEvent(ctx, event)
 	})
 }
 
-// sendBundle sends a serialized BundleSet to a Service Bus using the provided ServiceBusClient.
+// sendBundle sends a serialized BundleSet to the given EHR endpoint using the provided HTTP client.
+// The BundleSet ID is sent as Idempotency-Key header, so the EHR can deduplicate redelivered BundleSets.
 // It logs the process and errors during submission while wrapping and returning them.
-// Returns an error if serialization or message submission fails.
-func sendBundle(ctx context.Context, taskAcceptedBundleEndpoint string, set BundleSet) error {
+// Returns a BadRequest error if the EHR can't process the BundleSet, which can't be resolved by retrying.
+func sendBundle(ctx context.Context, httpClient *http.Client, taskAcceptedBundleEndpoint string, set BundleSet) error {
 	ctx, span := tracer.Start(
 		ctx,
 		debug.GetFullCallerName(),
@@ -213,11 +600,12 @@ func sendBundle(ctx context.Context, taskAcceptedBundleEndpoint string, set Bund
 		return otel.Error(span, errors.Wrap(err, "failed to create HTTP request"))
 	}
 	req.Header.Set("Content-Type", "application/fhir+json")
+	req.Header.Set("Idempotency-Key", set.Id)
 
 	// Inject trace context into request headers
 	baseotel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
-	httpResponse, err := otel.NewTracedHTTPClient("ehr.notifier").Do(req)
+	httpResponse, err := httpClient.Do(req)
 	if err != nil {
 		slog.ErrorContext(
 			ctx,
@@ -231,18 +619,14 @@ func sendBundle(ctx context.Context, taskAcceptedBundleEndpoint string, set Bund
 	}
 	defer httpResponse.Body.Close()
 	if httpResponse.StatusCode == http.StatusBadRequest {
-		var badRequest BadRequest
+		// Any bad request means the EHR can't process the BundleSet, even if it doesn't explain why (in an OperationOutcome)
+		badRequest := &BadRequest{Reason: to.Ptr("unknown error")}
 		var operationOutcome fhir.OperationOutcome
-		if err := json.NewDecoder(httpResponse.Body).Decode(&operationOutcome); err != nil {
-			return otel.Error(span, errors.Wrap(err, "failed to decode bad request response"))
-		}
-		if len(operationOutcome.Issue) > 0 {
+		if err := json.NewDecoder(httpResponse.Body).Decode(&operationOutcome); err == nil &&
+			len(operationOutcome.Issue) > 0 && operationOutcome.Issue[0].Diagnostics != nil {
 			badRequest.Reason = operationOutcome.Issue[0].Diagnostics
-		} else {
-			unknownError := "unknown error"
-			badRequest.Reason = &unknownError
 		}
-		return &BadRequest{Reason: badRequest.Reason}
+		return otel.Error(span, badRequest)
 	}
 	if httpResponse.StatusCode < 200 || httpResponse.StatusCode >= 300 {
 		return otel.Error(span, errors.Errorf("failed to send task to endpoint, status code: %d", httpResponse.StatusCode))
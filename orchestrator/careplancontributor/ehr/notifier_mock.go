@@ -54,3 +54,31 @@ func (mr *MockNotifierMockRecorder) NotifyTaskAccepted(ctx, fhirBaseURL, task an
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NotifyTaskAccepted", reflect.TypeOf((*MockNotifier)(nil).NotifyTaskAccepted), ctx, fhirBaseURL, task)
 }
+
+// NotifyTaskReviewRequested mocks base method.
+func (m *MockNotifier) NotifyTaskReviewRequested(ctx context.Context, fhirBaseURL string, task *fhir.Task) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NotifyTaskReviewRequested", ctx, fhirBaseURL, task)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// NotifyTaskReviewRequested indicates an expected call of NotifyTaskReviewRequested.
+func (mr *MockNotifierMockRecorder) NotifyTaskReviewRequested(ctx, fhirBaseURL, task any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NotifyTaskReviewRequested", reflect.TypeOf((*MockNotifier)(nil).NotifyTaskReviewRequested), ctx, fhirBaseURL, task)
+}
+
+// NotifyTaskStatusChanged mocks base method.
+func (m *MockNotifier) NotifyTaskStatusChanged(ctx context.Context, fhirBaseURL string, task *fhir.Task) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NotifyTaskStatusChanged", ctx, fhirBaseURL, task)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// NotifyTaskStatusChanged indicates an expected call of NotifyTaskStatusChanged.
+func (mr *MockNotifierMockRecorder) NotifyTaskStatusChanged(ctx, fhirBaseURL, task any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NotifyTaskStatusChanged", reflect.TypeOf((*MockNotifier)(nil).NotifyTaskStatusChanged), ctx, fhirBaseURL, task)
+}
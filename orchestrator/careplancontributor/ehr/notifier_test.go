@@ -13,18 +13,22 @@ import (
 	"testing"
 
 	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/careplancontributor/mock"
 	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
 	"github.com/SanteonNL/orca/orchestrator/events"
+	"github.com/SanteonNL/orca/orchestrator/lib/deep"
+	"github.com/SanteonNL/orca/orchestrator/lib/must"
 	"github.com/SanteonNL/orca/orchestrator/lib/test"
 	"github.com/SanteonNL/orca/orchestrator/messaging"
 	"github.com/google/uuid"
+	"github.com/jellydator/ttlcache/v3"
 	"github.com/stretchr/testify/require"
 	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
 	"go.uber.org/mock/gomock"
 )
 
-func TestNotifier_NotifyTaskAccepted(t *testing.T) {
-	ctx := tenants.WithTenant(context.Background(), tenants.Test().Sole())
+// notificationTestResources returns a primary Task and the resources needed to create its BundleSet.
+func notificationTestResources() (fhir.Task, []any) {
 	taskId := uuid.NewString()
 	subtaskId := uuid.NewString()
 	patientId := uuid.NewString()
@@ -97,10 +101,20 @@ func TestNotifier_NotifyTaskAccepted(t *testing.T) {
 	questionnaireResponse2 := fhir.QuestionnaireResponse{
 		Id: &questionnaireResp2Id,
 	}
+	return primaryTask, []any{primaryTask, primaryPatient, serviceReq, questionnaire, questionnaireResponse1, questionnaireResponse2, carePlan, secondaryTask, careTeam}
+}
+
+func TestNotifier_DeliverBundleSet(t *testing.T) {
+	ctx := tenants.WithTenant(context.Background(), tenants.Test().Sole())
+	primaryTask, resources := notificationTestResources()
+	taskId := *primaryTask.Id
 
 	tests := []struct {
-		name                     string
-		task                     fhir.Task
+		name string
+		task fhir.Task
+		// notify invokes the tested notification, defaults to processing a TaskAcceptedEvent
+		notify                   func(n Notifier, ctx context.Context, fhirBaseURL string, task *fhir.Task) error
+		tenant                   func(properties *tenants.Properties)
 		setup                    func(*test.StubFHIRClient)
 		mockServerSetup          func() *httptest.Server
 		expectedError            error
@@ -111,8 +125,7 @@ func TestNotifier_NotifyTaskAccepted(t *testing.T) {
 			name: "successful notification with HTTP 200 response",
 			task: primaryTask,
 			setup: func(client *test.StubFHIRClient) {
-				client.Resources = append(client.Resources, primaryTask, primaryPatient, serviceReq,
-					questionnaire, questionnaireResponse1, questionnaireResponse2, carePlan, secondaryTask, careTeam)
+				client.Resources = append(client.Resources, resources...)
 			},
 			mockServerSetup: func() *httptest.Server {
 				return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -127,17 +140,49 @@ func TestNotifier_NotifyTaskAccepted(t *testing.T) {
 					var bundleSet BundleSet
 					err = json.Unmarshal(body, &bundleSet)
 					require.NoError(t, err)
+					require.Equal(t, BundleSetEventTaskAccepted, bundleSet.Event)
 
 					w.WriteHeader(http.StatusOK)
 				}))
 			},
 		},
+		{
+			name:   "review requested notification",
+			task:   primaryTask,
+			notify: Notifier.NotifyTaskReviewRequested,
+			setup: func(client *test.StubFHIRClient) {
+				client.Resources = append(client.Resources, resources...)
+			},
+			mockServerSetup: func() *httptest.Server {
+				return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					var bundleSet BundleSet
+					require.NoError(t, json.NewDecoder(r.Body).Decode(&bundleSet))
+					require.Equal(t, BundleSetEventTaskReviewRequested, bundleSet.Event)
+					w.WriteHeader(http.StatusOK)
+				}))
+			},
+		},
+		{
+			name:   "review requested notification, HTTP 400 rejects Task",
+			task:   primaryTask,
+			notify: Notifier.NotifyTaskReviewRequested,
+			setup: func(client *test.StubFHIRClient) {
+				client.Resources = append(client.Resources, resources...)
+			},
+			mockServerSetup: func() *httptest.Server {
+				return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusBadRequest)
+					_, _ = w.Write([]byte("invalid BundleSet"))
+				}))
+			},
+			expectedTaskStatusUpdate: true,
+			expectedTaskStatus:       fhir.TaskStatusRejected,
+		},
 		{
 			name: "HTTP 400 bad request with OperationOutcome",
 			task: primaryTask,
 			setup: func(client *test.StubFHIRClient) {
-				client.Resources = append(client.Resources, primaryTask, primaryPatient, serviceReq,
-					questionnaire, questionnaireResponse1, questionnaireResponse2, carePlan, secondaryTask, careTeam)
+				client.Resources = append(client.Resources, resources...)
 			},
 			mockServerSetup: func() *httptest.Server {
 				return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -156,12 +201,69 @@ func TestNotifier_NotifyTaskAccepted(t *testing.T) {
 			expectedTaskStatusUpdate: true,
 			expectedTaskStatus:       fhir.TaskStatusRejected,
 		},
+		{
+			name: "status change notification",
+			task: deep.AlterCopy(primaryTask, func(task *fhir.Task) {
+				task.Status = fhir.TaskStatusCompleted
+			}),
+			notify: Notifier.NotifyTaskStatusChanged,
+			tenant: func(properties *tenants.Properties) {
+				properties.TaskNotification.Statuses = []string{"completed"}
+			},
+			setup: func(client *test.StubFHIRClient) {
+				client.Resources = append(client.Resources, resources...)
+			},
+			mockServerSetup: func() *httptest.Server {
+				return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					var bundleSet BundleSet
+					require.NoError(t, json.NewDecoder(r.Body).Decode(&bundleSet))
+					require.Equal(t, "task-completed", bundleSet.Event)
+					w.WriteHeader(http.StatusOK)
+				}))
+			},
+		},
+		{
+			name: "status change notification, status not configured",
+			task: deep.AlterCopy(primaryTask, func(task *fhir.Task) {
+				task.Status = fhir.TaskStatusFailed
+			}),
+			notify: Notifier.NotifyTaskStatusChanged,
+			tenant: func(properties *tenants.Properties) {
+				properties.TaskNotification.Statuses = []string{"completed"}
+			},
+			mockServerSetup: func() *httptest.Server {
+				return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					t.Error("EHR should not be notified")
+				}))
+			},
+		},
+		{
+			name: "status change notification, HTTP 400 doesn't reject Task",
+			task: deep.AlterCopy(primaryTask, func(task *fhir.Task) {
+				task.Status = fhir.TaskStatusCompleted
+			}),
+			notify: Notifier.NotifyTaskStatusChanged,
+			tenant: func(properties *tenants.Properties) {
+				properties.TaskNotification.Statuses = []string{"completed"}
+			},
+			setup: func(client *test.StubFHIRClient) {
+				client.Resources = append(client.Resources, resources...)
+			},
+			mockServerSetup: func() *httptest.Server {
+				return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusBadRequest)
+					_ = json.NewEncoder(w).Encode(fhir.OperationOutcome{})
+				}))
+			},
+			expectedTaskStatusUpdate: true,
+			expectedTaskStatus:       fhir.TaskStatusAccepted,
+		},
 		{
 			name: "HTTP 500 server error",
 			task: primaryTask,
 			setup: func(client *test.StubFHIRClient) {
-				client.Resources = append(client.Resources, primaryTask, primaryPatient, serviceReq,
-					questionnaire, questionnaireResponse1, questionnaireResponse2, carePlan, secondaryTask, careTeam)
+				client.Resources = append(client.Resources, resources...)
 			},
 			mockServerSetup: func() *httptest.Server {
 				return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -187,8 +289,7 @@ func TestNotifier_NotifyTaskAccepted(t *testing.T) {
 			name: "HTTP endpoint unreachable",
 			task: primaryTask,
 			setup: func(client *test.StubFHIRClient) {
-				client.Resources = append(client.Resources, primaryTask, primaryPatient, serviceReq,
-					questionnaire, questionnaireResponse1, questionnaireResponse2, carePlan, secondaryTask, careTeam)
+				client.Resources = append(client.Resources, resources...)
 			},
 			mockServerSetup: func() *httptest.Server {
 				// Return a server that we'll immediately close to simulate unreachable endpoint
@@ -212,18 +313,32 @@ func TestNotifier_NotifyTaskAccepted(t *testing.T) {
 			messageBroker := messaging.NewMemoryBroker()
 			fhirClient := &test.StubFHIRClient{}
 			tenantCfg := tenants.Test()
+			if tt.tenant != nil {
+				tenantCfg = tenants.Test(tt.tenant)
+			}
 
 			if tt.setup != nil {
 				tt.setup(fhirClient)
 			}
 
-			notifier, err := NewNotifier(events.NewManager(messageBroker), tenantCfg, mockServer.URL, func(_ context.Context, _ *url.URL) (fhirclient.Client, *http.Client, error) {
+			n, err := NewNotifier(events.NewManager(messageBroker), tenantCfg, mockServer.URL, func(_ context.Context, _ *url.URL) (fhirclient.Client, *http.Client, error) {
 				return fhirClient, nil, nil
-			})
+			}, nil)
 			require.NoError(t, err)
 
 			// Execute the notification
-			err = notifier.NotifyTaskAccepted(ctx, fhirClient.Path().String(), &tt.task)
+			notify := tt.notify
+			if notify == nil {
+				notify = func(n Notifier, ctx context.Context, fhirBaseURL string, task *fhir.Task) error {
+					return n.(*notifier).processTaskAcceptedEvent(ctx, &TaskAcceptedEvent{
+						FHIRBaseURL: fhirBaseURL,
+						Task:        *task,
+						TenantID:    tenantCfg.Sole().ID,
+						BundleSetID: uuid.NewString(),
+					})
+				}
+			}
+			err = notify(n, tenants.WithTenant(ctx, tenantCfg.Sole()), fhirClient.Path().String(), &tt.task)
 
 			// Check expectations
 			if tt.expectedError != nil {
@@ -285,6 +400,30 @@ func TestSendBundle(t *testing.T) {
 			bundleSet:     BundleSet{task: "Task/123"},
 			expectedError: &BadRequest{Reason: to.Ptr("Test error")},
 		},
+		{
+			name: "bad request without operation outcome",
+			mockServerSetup: func() *httptest.Server {
+				return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Content-Type", "text/plain")
+					w.WriteHeader(http.StatusBadRequest)
+					_, _ = w.Write([]byte("Bad Request"))
+				}))
+			},
+			bundleSet:     BundleSet{task: "Task/123"},
+			expectedError: &BadRequest{Reason: to.Ptr("unknown error")},
+		},
+		{
+			name: "bad request with operation outcome without diagnostics",
+			mockServerSetup: func() *httptest.Server {
+				return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusBadRequest)
+					_ = json.NewEncoder(w).Encode(fhir.OperationOutcome{Issue: []fhir.OperationOutcomeIssue{{}}})
+				}))
+			},
+			bundleSet:     BundleSet{task: "Task/123"},
+			expectedError: &BadRequest{Reason: to.Ptr("unknown error")},
+		},
 	}
 
 	for _, tt := range tests {
@@ -293,7 +432,7 @@ func TestSendBundle(t *testing.T) {
 			defer mockServer.Close()
 
 			ctx := context.Background()
-			err := sendBundle(ctx, mockServer.URL, tt.bundleSet)
+			err := sendBundle(ctx, http.DefaultClient, mockServer.URL, tt.bundleSet)
 
 			if tt.expectedError != nil {
 				require.Error(t, err)
@@ -309,3 +448,301 @@ func TestSendBundle(t *testing.T) {
 		})
 	}
 }
+
+func TestNotifier_NotifyTaskAccepted(t *testing.T) {
+	ctx := tenants.WithTenant(context.Background(), tenants.Test().Sole())
+	task, resources := notificationTestResources()
+	fhirClient := &test.StubFHIRClient{
+		Resources: resources,
+	}
+	fhirClientFactory := func(_ context.Context, _ *url.URL) (fhirclient.Client, *http.Client, error) {
+		return fhirClient, nil, nil
+	}
+	t.Run("BundleSet is delivered with idempotency key", func(t *testing.T) {
+		var capturedIdempotencyKey string
+		var capturedBundleSet BundleSet
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			capturedIdempotencyKey = r.Header.Get("Idempotency-Key")
+			_ = json.NewDecoder(r.Body).Decode(&capturedBundleSet)
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+		messageBroker := messaging.NewMemoryBroker()
+		n, err := NewNotifier(events.NewManager(messageBroker), tenants.Test(), server.URL, fhirClientFactory, nil)
+		require.NoError(t, err)
+
+		err = n.NotifyTaskAccepted(ctx, fhirClient.Path().String(), &task)
+
+		require.NoError(t, err)
+		require.Nil(t, messageBroker.LastHandlerError.Load())
+		require.NotEmpty(t, capturedIdempotencyKey)
+		require.Equal(t, capturedIdempotencyKey, capturedBundleSet.Id)
+		require.Equal(t, BundleSetEventTaskAccepted, capturedBundleSet.Event)
+	})
+	t.Run("failed delivery is returned to message broker for redelivery", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+		messageBroker := messaging.NewMemoryBroker()
+		n, err := NewNotifier(events.NewManager(messageBroker), tenants.Test(), server.URL, fhirClientFactory, nil)
+		require.NoError(t, err)
+
+		err = n.NotifyTaskAccepted(ctx, fhirClient.Path().String(), &task)
+
+		require.NoError(t, err)
+		handlerErr := messageBroker.LastHandlerError.Load()
+		require.NotNil(t, handlerErr)
+		require.ErrorContains(t, *handlerErr, "status code: 503")
+	})
+	t.Run("tenant endpoint takes precedence", func(t *testing.T) {
+		var called bool
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+		tenantCfg := tenants.Test(func(properties *tenants.Properties) {
+			properties.TaskNotification.DefaultEndpoint = server.URL
+		})
+		messageBroker := messaging.NewMemoryBroker()
+		n, err := NewNotifier(events.NewManager(messageBroker), tenantCfg, "http://localhost:1/unreachable", fhirClientFactory, nil)
+		require.NoError(t, err)
+
+		err = n.NotifyTaskAccepted(tenants.WithTenant(context.Background(), tenantCfg.Sole()), fhirClient.Path().String(), &task)
+
+		require.NoError(t, err)
+		require.Nil(t, messageBroker.LastHandlerError.Load())
+		require.True(t, called)
+	})
+	t.Run("Task data is written into EHR FHIR API", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		tenantCfg := tenants.Test(func(properties *tenants.Properties) {
+			properties.TaskNotification.Delivery = tenants.TaskDeliveryFHIR
+		})
+		ehrClient := mock.NewMockClient(ctrl)
+		ehrClient.EXPECT().Path(gomock.Any()).Return(must.ParseURL("https://ehr.example.com/fhir/Task/1")).AnyTimes()
+		ehrClient.EXPECT().CreateWithContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, resource interface{}, result interface{}, _ ...fhirclient.Option) error {
+				response := fhir.Bundle{Type: fhir.BundleTypeTransactionResponse}
+				for range resource.(fhir.Bundle).Entry {
+					response.Entry = append(response.Entry, fhir.BundleEntry{
+						Response: &fhir.BundleEntryResponse{Status: "201 Created", Location: to.Ptr("Task/1/_history/1")},
+					})
+				}
+				*result.(*fhir.Bundle) = response
+				return nil
+			})
+		messageBroker := messaging.NewMemoryBroker()
+		n, err := NewNotifier(events.NewManager(messageBroker), tenantCfg, "", fhirClientFactory, map[string]fhirclient.Client{
+			tenantCfg.Sole().ID: ehrClient,
+		})
+		require.NoError(t, err)
+
+		err = n.NotifyTaskAccepted(tenants.WithTenant(context.Background(), tenantCfg.Sole()), fhirClient.Path().String(), &task)
+
+		require.NoError(t, err)
+		require.Nil(t, messageBroker.LastHandlerError.Load())
+	})
+	t.Run("no EHR FHIR client for tenant", func(t *testing.T) {
+		tenantCfg := tenants.Test(func(properties *tenants.Properties) {
+			properties.TaskNotification.Delivery = tenants.TaskDeliveryFHIR
+		})
+		messageBroker := messaging.NewMemoryBroker()
+		n, err := NewNotifier(events.NewManager(messageBroker), tenantCfg, "", fhirClientFactory, nil)
+		require.NoError(t, err)
+
+		err = n.NotifyTaskAccepted(tenants.WithTenant(context.Background(), tenantCfg.Sole()), fhirClient.Path().String(), &task)
+
+		require.NoError(t, err)
+		handlerErr := messageBroker.LastHandlerError.Load()
+		require.NotNil(t, handlerErr)
+		require.ErrorContains(t, *handlerErr, "no EHR FHIR client configured for tenant")
+	})
+}
+
+func TestNotifier_NotifyTaskReviewRequested(t *testing.T) {
+	ctx := tenants.WithTenant(context.Background(), tenants.Test().Sole())
+	task, resources := notificationTestResources()
+	fhirClient := &test.StubFHIRClient{
+		Resources: resources,
+	}
+	fhirClientFactory := func(_ context.Context, _ *url.URL) (fhirclient.Client, *http.Client, error) {
+		return fhirClient, nil, nil
+	}
+	t.Run("BundleSet is delivered with idempotency key", func(t *testing.T) {
+		var capturedIdempotencyKey string
+		var capturedBundleSet BundleSet
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			capturedIdempotencyKey = r.Header.Get("Idempotency-Key")
+			_ = json.NewDecoder(r.Body).Decode(&capturedBundleSet)
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+		messageBroker := messaging.NewMemoryBroker()
+		n, err := NewNotifier(events.NewManager(messageBroker), tenants.Test(), server.URL, fhirClientFactory, nil)
+		require.NoError(t, err)
+
+		err = n.NotifyTaskReviewRequested(ctx, fhirClient.Path().String(), &task)
+
+		require.NoError(t, err)
+		require.Nil(t, messageBroker.LastHandlerError.Load())
+		require.NotEmpty(t, capturedIdempotencyKey)
+		require.Equal(t, capturedIdempotencyKey, capturedBundleSet.Id)
+		require.Equal(t, BundleSetEventTaskReviewRequested, capturedBundleSet.Event)
+	})
+	t.Run("failed delivery is returned to message broker for redelivery", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+		messageBroker := messaging.NewMemoryBroker()
+		n, err := NewNotifier(events.NewManager(messageBroker), tenants.Test(), server.URL, fhirClientFactory, nil)
+		require.NoError(t, err)
+
+		err = n.NotifyTaskReviewRequested(ctx, fhirClient.Path().String(), &task)
+
+		require.NoError(t, err)
+		handlerErr := messageBroker.LastHandlerError.Load()
+		require.NotNil(t, handlerErr)
+		require.ErrorContains(t, *handlerErr, "status code: 503")
+	})
+}
+
+func TestNotifier_NotifyTaskStatusChanged(t *testing.T) {
+	task, resources := notificationTestResources()
+	fhirClient := &test.StubFHIRClient{
+		Resources: resources,
+	}
+	fhirClientFactory := func(_ context.Context, _ *url.URL) (fhirclient.Client, *http.Client, error) {
+		return fhirClient, nil, nil
+	}
+	tenantCfg := tenants.Test(func(properties *tenants.Properties) {
+		properties.TaskNotification.Statuses = []string{"completed"}
+	})
+	ctx := tenants.WithTenant(context.Background(), tenantCfg.Sole())
+	withStatus := func(status fhir.TaskStatus) *fhir.Task {
+		return to.Ptr(deep.AlterCopy(task, func(task *fhir.Task) {
+			task.Status = status
+		}))
+	}
+	t.Run("only transitions are delivered", func(t *testing.T) {
+		var deliveries int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			deliveries++
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+		n, err := NewNotifier(events.NewManager(messaging.NewMemoryBroker()), tenantCfg, server.URL, fhirClientFactory, nil)
+		require.NoError(t, err)
+
+		require.NoError(t, n.NotifyTaskStatusChanged(ctx, fhirClient.Path().String(), withStatus(fhir.TaskStatusCompleted)))
+		// Other update of the completed Task (e.g. output added)
+		require.NoError(t, n.NotifyTaskStatusChanged(ctx, fhirClient.Path().String(), withStatus(fhir.TaskStatusCompleted)))
+		require.Equal(t, 1, deliveries)
+
+		// Task transitions to a status that isn't configured, and back
+		require.NoError(t, n.NotifyTaskStatusChanged(ctx, fhirClient.Path().String(), withStatus(fhir.TaskStatusInProgress)))
+		require.NoError(t, n.NotifyTaskStatusChanged(ctx, fhirClient.Path().String(), withStatus(fhir.TaskStatusCompleted)))
+		require.Equal(t, 2, deliveries)
+	})
+	t.Run("failed delivery is retried by the message broker", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+		messageBroker := messaging.NewMemoryBroker()
+		n, err := NewNotifier(events.NewManager(messageBroker), tenantCfg, server.URL, fhirClientFactory, nil)
+		require.NoError(t, err)
+
+		require.NoError(t, n.NotifyTaskStatusChanged(ctx, fhirClient.Path().String(), withStatus(fhir.TaskStatusCompleted)))
+
+		handlerErr := messageBroker.LastHandlerError.Load()
+		require.NotNil(t, handlerErr)
+		require.ErrorContains(t, *handlerErr, "status code: 503")
+	})
+	t.Run("bad request isn't retried", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"resourceType":"OperationOutcome","issue":[{"diagnostics":"unknown patient"}]}`))
+		}))
+		defer server.Close()
+		messageBroker := messaging.NewMemoryBroker()
+		n, err := NewNotifier(events.NewManager(messageBroker), tenantCfg, server.URL, fhirClientFactory, nil)
+		require.NoError(t, err)
+
+		require.NoError(t, n.NotifyTaskStatusChanged(ctx, fhirClient.Path().String(), withStatus(fhir.TaskStatusCompleted)))
+
+		require.Nil(t, messageBroker.LastHandlerError.Load())
+	})
+	t.Run("transition is published again after publishing failed", func(t *testing.T) {
+		// Notifier isn't started, so there's no subscriber for the event, which makes publishing fail
+		n := &notifier{
+			tenants:             tenantCfg,
+			eventManager:        events.NewManager(messaging.NewMemoryBroker()),
+			writtenTaskVersions: ttlcache.New[string, string](),
+			taskStatuses:        ttlcache.New[string, fhir.TaskStatus](),
+		}
+
+		require.ErrorContains(t, n.NotifyTaskStatusChanged(ctx, fhirClient.Path().String(), withStatus(fhir.TaskStatusCompleted)), "failed to publish task-transitioned event")
+		require.ErrorContains(t, n.NotifyTaskStatusChanged(ctx, fhirClient.Path().String(), withStatus(fhir.TaskStatusCompleted)), "failed to publish task-transitioned event")
+	})
+}
+
+func TestNotifier_httpClient(t *testing.T) {
+	t.Run("no authentication", func(t *testing.T) {
+		n := &notifier{}
+		client, err := n.httpClient(tenants.Properties{ID: "test"})
+		require.NoError(t, err)
+		require.NotNil(t, client)
+
+		cached, err := n.httpClient(tenants.Properties{ID: "test"})
+		require.NoError(t, err)
+		require.Same(t, client, cached, "client should be reused for the tenant")
+	})
+	t.Run("oauth2-client-credentials", func(t *testing.T) {
+		tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, r.ParseForm())
+			require.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token":"token","token_type":"Bearer","expires_in":3600}`))
+		}))
+		defer tokenServer.Close()
+		var capturedAuthHeader string
+		ehrServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			capturedAuthHeader = r.Header.Get("Authorization")
+		}))
+		defer ehrServer.Close()
+		tenant := tenants.Properties{ID: "test"}
+		tenant.TaskNotification.Auth = tenants.EndpointAuthProperties{
+			Type:         tenants.EndpointAuthOAuth2ClientCredentials,
+			TokenURL:     tokenServer.URL,
+			ClientID:     "client",
+			ClientSecret: "secret",
+		}
+
+		client, err := (&notifier{}).httpClient(tenant)
+		require.NoError(t, err)
+		response, err := client.Get(ehrServer.URL)
+
+		require.NoError(t, err)
+		_ = response.Body.Close()
+		require.Equal(t, "Bearer token", capturedAuthHeader)
+	})
+	t.Run("mtls, certificate not found", func(t *testing.T) {
+		tenant := tenants.Properties{ID: "test"}
+		tenant.TaskNotification.Auth = tenants.EndpointAuthProperties{
+			Type:     tenants.EndpointAuthMTLS,
+			CertFile: "not-found.pem",
+			KeyFile:  "not-found.pem",
+		}
+		_, err := (&notifier{}).httpClient(tenant)
+		require.ErrorContains(t, err, "failed to create HTTP client for EHR endpoint (tenant=test): failed to load client certificate")
+	})
+	t.Run("unsupported type", func(t *testing.T) {
+		tenant := tenants.Properties{ID: "test"}
+		tenant.TaskNotification.Auth = tenants.EndpointAuthProperties{Type: "other"}
+		_, err := (&notifier{}).httpClient(tenant)
+		require.EqualError(t, err, "failed to create HTTP client for EHR endpoint (tenant=test): unsupported EHR endpoint authentication type: other")
+	})
+}
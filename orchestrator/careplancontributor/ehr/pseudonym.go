@@ -0,0 +1,58 @@
+package ehr
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+// patientBSN returns the BSN of the Patient in the given Bundle, or nil if there's no Patient or it has no BSN.
+func patientBSN(patientBundle fhir.Bundle) *fhir.Identifier {
+	var patient fhir.Patient
+	if err := coolfhir.ResourceInBundle(&patientBundle, coolfhir.EntryIsOfType("Patient"), &patient); err != nil {
+		return nil
+	}
+	bsn := coolfhir.FilterFirstIdentifier(&patient.Identifier, coolfhir.BSNNamingSystem)
+	if bsn == nil || bsn.Value == nil {
+		return nil
+	}
+	return bsn
+}
+
+// depseudonymizeBundle replaces the pseudonymous patient identifiers issued by the Care Plan Service in Task.for and CarePlan.subject
+// of the resources in the Bundle with the given BSN, since the EHR identifies the patient by BSN.
+func depseudonymizeBundle(bundle *fhir.Bundle, bsn fhir.Identifier) error {
+	for i, entry := range bundle.Entry {
+		var resource coolfhir.Resource
+		if err := json.Unmarshal(entry.Resource, &resource); err != nil {
+			return fmt.Errorf("failed to unmarshal resource of bundle entry[#%d]: %w", i, err)
+		}
+		var err error
+		switch resource.Type {
+		case "Task":
+			var task fhir.Task
+			if err = json.Unmarshal(entry.Resource, &task); err == nil && task.For != nil && depseudonymizeReference(task.For, bsn) {
+				bundle.Entry[i].Resource, err = json.Marshal(task)
+			}
+		case "CarePlan":
+			var carePlan fhir.CarePlan
+			if err = json.Unmarshal(entry.Resource, &carePlan); err == nil && depseudonymizeReference(&carePlan.Subject, bsn) {
+				bundle.Entry[i].Resource, err = json.Marshal(carePlan)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to depseudonymize %s in bundle entry[#%d]: %w", resource.Type, i, err)
+		}
+	}
+	return nil
+}
+
+func depseudonymizeReference(reference *fhir.Reference, bsn fhir.Identifier) bool {
+	if reference.Identifier == nil || reference.Identifier.System == nil || *reference.Identifier.System != coolfhir.PatientPseudonymNamingSystem {
+		return false
+	}
+	reference.Identifier = &bsn
+	return true
+}
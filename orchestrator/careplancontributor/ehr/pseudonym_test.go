@@ -0,0 +1,60 @@
+package ehr
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/must"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+func Test_depseudonymizeBundle(t *testing.T) {
+	bsn := fhir.Identifier{System: to.Ptr(coolfhir.BSNNamingSystem), Value: to.Ptr("111222333")}
+	pseudonym := &fhir.Identifier{System: to.Ptr(coolfhir.PatientPseudonymNamingSystem), Value: to.Ptr("b9a0fa3e")}
+	otherIdentifier := &fhir.Identifier{System: to.Ptr("http://example.com/patient"), Value: to.Ptr("1")}
+	bundle := fhir.Bundle{
+		Entry: []fhir.BundleEntry{
+			{Resource: must.MarshalJSON(fhir.Task{Id: to.Ptr("1"), For: &fhir.Reference{Reference: to.Ptr("Patient/1"), Identifier: pseudonym}})},
+			{Resource: must.MarshalJSON(fhir.Task{Id: to.Ptr("2"), For: &fhir.Reference{Identifier: otherIdentifier}})},
+			{Resource: must.MarshalJSON(fhir.Task{Id: to.Ptr("3")})},
+			{Resource: must.MarshalJSON(fhir.CarePlan{Id: to.Ptr("1"), Subject: fhir.Reference{Reference: to.Ptr("Patient/1"), Identifier: pseudonym}})},
+			{Resource: must.MarshalJSON(fhir.ServiceRequest{Id: to.Ptr("1"), Subject: fhir.Reference{Identifier: pseudonym}})},
+		},
+	}
+
+	err := depseudonymizeBundle(&bundle, bsn)
+
+	require.NoError(t, err)
+	var task1, task2, task3 fhir.Task
+	require.NoError(t, json.Unmarshal(bundle.Entry[0].Resource, &task1))
+	require.Equal(t, bsn, *task1.For.Identifier)
+	require.Equal(t, "Patient/1", *task1.For.Reference)
+	require.NoError(t, json.Unmarshal(bundle.Entry[1].Resource, &task2))
+	require.Equal(t, otherIdentifier, task2.For.Identifier)
+	require.NoError(t, json.Unmarshal(bundle.Entry[2].Resource, &task3))
+	require.Nil(t, task3.For)
+	var carePlan fhir.CarePlan
+	require.NoError(t, json.Unmarshal(bundle.Entry[3].Resource, &carePlan))
+	require.Equal(t, bsn, *carePlan.Subject.Identifier)
+	var serviceRequest fhir.ServiceRequest
+	require.NoError(t, json.Unmarshal(bundle.Entry[4].Resource, &serviceRequest))
+	require.Equal(t, pseudonym, serviceRequest.Subject.Identifier, "only Task and CarePlan are depseudonymized")
+}
+
+func Test_patientBSN(t *testing.T) {
+	bsn := fhir.Identifier{System: to.Ptr(coolfhir.BSNNamingSystem), Value: to.Ptr("111222333")}
+	t.Run("ok", func(t *testing.T) {
+		bundle := fhir.Bundle{Entry: []fhir.BundleEntry{{Resource: must.MarshalJSON(fhir.Patient{Identifier: []fhir.Identifier{bsn}})}}}
+		require.Equal(t, &bsn, patientBSN(bundle))
+	})
+	t.Run("Patient without BSN", func(t *testing.T) {
+		bundle := fhir.Bundle{Entry: []fhir.BundleEntry{{Resource: must.MarshalJSON(fhir.Patient{})}}}
+		require.Nil(t, patientBSN(bundle))
+	})
+	t.Run("no Patient", func(t *testing.T) {
+		require.Nil(t, patientBSN(fhir.Bundle{}))
+	})
+}
@@ -0,0 +1,360 @@
+package ehr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/debug"
+	"github.com/SanteonNL/orca/orchestrator/lib/logging"
+	"github.com/SanteonNL/orca/orchestrator/lib/must"
+	"github.com/SanteonNL/orca/orchestrator/lib/otel"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// EHRResourceOutputType is the Task.output.type of outputs that refer to resources that were created in the EHR's FHIR API for the Task.
+var EHRResourceOutputType = fhir.CodeableConcept{
+	Coding: []fhir.Coding{
+		{
+			System: to.Ptr("http://santeonnl.github.io/orca/CodeSystem/task-output-type"),
+			Code:   to.Ptr("ehr-resource"),
+		},
+	},
+}
+
+// sourceIdentifierSystem is the identifier system used for conditional creates of resources that don't have a business identifier.
+// The identifier value is the absolute URL of the resource at the Care Plan Service.
+const sourceIdentifierSystem = "urn:ietf:rfc:3986"
+
+// transactionResourceTypes contains the resource types of the BundleSet that are written into the EHR's FHIR API.
+var transactionResourceTypes = []string{"Task", "Patient", "ServiceRequest", "Condition", "QuestionnaireResponse"}
+
+// TaskTransaction maps the Task, Patient, ServiceRequest, Condition and QuestionnaireResponse resources of a Task's BundleSet
+// into a FHIR transaction Bundle, which creates the resources in the EHR's FHIR API if they don't exist yet.
+// Resources are created conditionally on their first identifier, or if they don't have one, on their URL at the Care Plan Service.
+// The Task is updated conditionally instead, so the EHR's copy of the Task reflects its current status.
+// References between resources in the transaction are replaced by the fullUrl of the entries,
+// other local references are made absolute, so they refer to the resource at the Care Plan Service.
+func TaskTransaction(cpsBaseURL *url.URL, bundleSet BundleSet, conditions []fhir.Condition) (fhir.Bundle, error) {
+	type transactionResource struct {
+		resourceType string
+		sourceURL    string
+		fullURL      string
+		data         map[string]interface{}
+	}
+	var resources []transactionResource
+	fullURLs := map[string]string{}
+	addResource := func(raw json.RawMessage) error {
+		var data map[string]interface{}
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return err
+		}
+		resourceType, _ := data["resourceType"].(string)
+		id, _ := data["id"].(string)
+		if !isTransactionResourceType(resourceType) || id == "" {
+			return nil
+		}
+		localRef := resourceType + "/" + id
+		if _, exists := fullURLs[localRef]; exists {
+			return nil
+		}
+		resource := transactionResource{
+			resourceType: resourceType,
+			sourceURL:    cpsBaseURL.JoinPath(resourceType, id).String(),
+			fullURL:      "urn:uuid:" + uuid.NewString(),
+			data:         data,
+		}
+		fullURLs[localRef] = resource.fullURL
+		fullURLs[resource.sourceURL] = resource.fullURL
+		resources = append(resources, resource)
+		return nil
+	}
+	for _, bundle := range bundleSet.Bundles {
+		for _, entry := range bundle.Entry {
+			if err := addResource(entry.Resource); err != nil {
+				return fhir.Bundle{}, fmt.Errorf("failed to unmarshal BundleSet resource: %w", err)
+			}
+		}
+	}
+	for _, condition := range conditions {
+		if err := addResource(must.MarshalJSON(condition)); err != nil {
+			return fhir.Bundle{}, err
+		}
+	}
+
+	tx := coolfhir.Transaction()
+	for _, resource := range resources {
+		delete(resource.data, "id")
+		resource.data["meta"] = map[string]interface{}{
+			"source": resource.sourceURL,
+		}
+		replaceReferences(resource.data, func(reference string) string {
+			if fullURL, ok := fullURLs[reference]; ok {
+				return fullURL
+			}
+			if isLocalReference(reference) {
+				return cpsBaseURL.JoinPath(reference).String()
+			}
+			return reference
+		})
+		identifier := conditionalCreateIdentifier(resource.data, resource.sourceURL)
+		searchParams := url.Values{"identifier": []string{coolfhir.IdentifierToToken(identifier)}}
+		request := &fhir.BundleEntryRequest{
+			Method:      fhir.HTTPVerbPOST,
+			Url:         resource.resourceType,
+			IfNoneExist: to.Ptr(searchParams.Encode()),
+		}
+		if resource.resourceType == "Task" {
+			// The Task is written again when it changes (e.g. when its status changes), so the EHR must get its current version
+			request = &fhir.BundleEntryRequest{
+				Method: fhir.HTTPVerbPUT,
+				Url:    resource.resourceType + "?" + searchParams.Encode(),
+			}
+		}
+		tx.Append(resource.data, request, nil, coolfhir.WithFullUrl(resource.fullURL))
+	}
+	return tx.Bundle(), nil
+}
+
+// writeTransaction writes the resources of the Task's BundleSet into the EHR's FHIR API,
+// and records references to the created resources as outputs of the Task at the Care Plan Service.
+// It returns the Task as updated at the Care Plan Service, or nil if it wasn't updated (e.g. because the outputs were already recorded).
+// If the EHR rejects the transaction (HTTP 400 or 422), a BadRequest error is returned.
+func writeTransaction(ctx context.Context, cpsClient fhirclient.Client, ehrClient fhirclient.Client, task fhir.Task, bundleSet BundleSet) (*fhir.Task, error) {
+	ctx, span := tracer.Start(
+		ctx,
+		debug.GetFullCallerName(),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String(otel.FHIRTaskID, *task.Id),
+			attribute.String(otel.FHIRBundleSetId, bundleSet.Id),
+		),
+	)
+	defer span.End()
+
+	conditions, err := fetchConditions(ctx, cpsClient, bundleSet)
+	if err != nil {
+		return nil, otel.Error(span, errors.Wrap(err, "failed to fetch conditions"))
+	}
+	transaction, err := TaskTransaction(cpsClient.Path(), bundleSet, conditions)
+	if err != nil {
+		return nil, otel.Error(span, errors.Wrap(err, "failed to create EHR transaction"))
+	}
+	var transactionResult fhir.Bundle
+	if err := ehrClient.CreateWithContext(ctx, transaction, &transactionResult, fhirclient.AtPath("/")); err != nil {
+		var operationOutcome fhirclient.OperationOutcomeError
+		if errors.As(err, &operationOutcome) &&
+			(operationOutcome.HttpStatusCode == http.StatusBadRequest || operationOutcome.HttpStatusCode == http.StatusUnprocessableEntity) {
+			reason := "EHR rejected the transaction"
+			if len(operationOutcome.Issue) > 0 && operationOutcome.Issue[0].Diagnostics != nil {
+				reason = *operationOutcome.Issue[0].Diagnostics
+			}
+			return nil, otel.Error(span, &BadRequest{Reason: &reason})
+		}
+		return nil, otel.Error(span, errors.Wrap(err, "failed to execute EHR transaction"))
+	}
+	if len(transactionResult.Entry) != len(transaction.Entry) {
+		return nil, otel.Error(span, fmt.Errorf("EHR transaction response contains %d entries, expected %d", len(transactionResult.Entry), len(transaction.Entry)))
+	}
+	var outputs []fhir.TaskOutput
+	for i, entry := range transactionResult.Entry {
+		if entry.Response == nil || entry.Response.Location == nil {
+			return nil, otel.Error(span, fmt.Errorf("EHR transaction response entry #%d has no location", i))
+		}
+		resourceType, resourceID, err := parseLocation(*entry.Response.Location)
+		if err != nil {
+			return nil, otel.Error(span, err)
+		}
+		outputs = append(outputs, fhir.TaskOutput{
+			Type: EHRResourceOutputType,
+			ValueReference: &fhir.Reference{
+				Type:      to.Ptr(resourceType),
+				Reference: to.Ptr(ehrClient.Path(resourceType, resourceID).String()),
+			},
+		})
+	}
+	span.SetAttributes(attribute.Int(otel.FHIRBundleEntryCount, len(outputs)))
+
+	updatedTask, err := recordEHRResources(ctx, cpsClient, *task.Id, outputs)
+	if err != nil {
+		// The resources were written into the EHR, so don't fail (and retry) the delivery
+		slog.WarnContext(
+			ctx,
+			"Task data was written into EHR, but couldn't be recorded on the Task",
+			slog.String(logging.FieldResourceID, *task.Id),
+			slog.String(logging.FieldResourceType, fhir.ResourceTypeTask.String()),
+			slog.String(logging.FieldError, err.Error()),
+		)
+	}
+	span.SetStatus(codes.Ok, "")
+	return updatedTask, nil
+}
+
+// recordEHRResources replaces the outputs of the Task that refer to resources in the EHR with the given outputs.
+// If the Task already has exactly these outputs, it isn't updated and nil is returned: updating it would notify the Task's participants again,
+// which would cause the Task to be delivered to the EHR again.
+func recordEHRResources(ctx context.Context, cpsClient fhirclient.Client, taskID string, outputs []fhir.TaskOutput) (*fhir.Task, error) {
+	var task fhir.Task
+	if err := cpsClient.ReadWithContext(ctx, "Task/"+taskID, &task); err != nil {
+		return nil, errors.Wrap(err, "failed to read Task to record EHR resources")
+	}
+	var taskOutputs []fhir.TaskOutput
+	var recordedReferences []string
+	for _, output := range task.Output {
+		if !coolfhir.ConceptContainsCoding(EHRResourceOutputType.Coding[0], output.Type) {
+			taskOutputs = append(taskOutputs, output)
+		} else if output.ValueReference != nil {
+			recordedReferences = append(recordedReferences, to.EmptyString(output.ValueReference.Reference))
+		}
+	}
+	var references []string
+	for _, output := range outputs {
+		references = append(references, to.EmptyString(output.ValueReference.Reference))
+	}
+	slices.Sort(recordedReferences)
+	slices.Sort(references)
+	if slices.Equal(recordedReferences, references) {
+		return nil, nil
+	}
+	task.Output = append(taskOutputs, outputs...)
+	if err := cpsClient.UpdateWithContext(ctx, "Task/"+taskID, task, &task); err != nil {
+		return nil, errors.Wrap(err, "failed to record EHR resources on Task")
+	}
+	return &task, nil
+}
+
+// fetchConditions fetches the Conditions referenced by the ServiceRequest in the BundleSet as reason.
+func fetchConditions(ctx context.Context, cpsClient fhirclient.Client, bundleSet BundleSet) ([]fhir.Condition, error) {
+	var conditionRefs []string
+	for _, bundle := range bundleSet.Bundles {
+		var serviceRequests []fhir.ServiceRequest
+		if err := coolfhir.ResourcesInBundle(&bundle, coolfhir.EntryIsOfType("ServiceRequest"), &serviceRequests); err != nil {
+			return nil, err
+		}
+		for _, serviceRequest := range serviceRequests {
+			for _, reason := range serviceRequest.ReasonReference {
+				if reason.Reference != nil && strings.HasPrefix(*reason.Reference, "Condition/") {
+					conditionRefs = append(conditionRefs, *reason.Reference)
+				}
+			}
+		}
+	}
+	if len(conditionRefs) == 0 {
+		return nil, nil
+	}
+	bundles, err := fetchRefs(ctx, cpsClient, conditionRefs)
+	if err != nil {
+		return nil, err
+	}
+	var conditions []fhir.Condition
+	for _, bundle := range *bundles {
+		var bundleConditions []fhir.Condition
+		if err := coolfhir.ResourcesInBundle(&bundle, coolfhir.EntryIsOfType("Condition"), &bundleConditions); err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, bundleConditions...)
+	}
+	return conditions, nil
+}
+
+func isTransactionResourceType(resourceType string) bool {
+	for _, curr := range transactionResourceTypes {
+		if curr == resourceType {
+			return true
+		}
+	}
+	return false
+}
+
+// isLocalReference returns whether the reference is a relative literal reference (e.g. Patient/123).
+func isLocalReference(reference string) bool {
+	parts := strings.Split(reference, "/")
+	return len(parts) == 2 && !strings.Contains(reference, ":") && !strings.HasPrefix(reference, "#")
+}
+
+// replaceReferences replaces the values of all reference properties in the given resource (recursively) using the given function.
+func replaceReferences(value interface{}, replace func(reference string) string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if reference, ok := child.(string); ok && key == "reference" {
+				v[key] = replace(reference)
+			} else {
+				replaceReferences(child, replace)
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			replaceReferences(child, replace)
+		}
+	}
+}
+
+// conditionalCreateIdentifier returns the identifier to conditionally create the resource on.
+// If the resource doesn't have an identifier with both system and value, an identifier containing the source URL is added.
+func conditionalCreateIdentifier(resource map[string]interface{}, sourceURL string) fhir.Identifier {
+	var identifiers []fhir.Identifier
+	if raw, ok := resource["identifier"]; ok {
+		data, _ := json.Marshal(raw)
+		if _, isList := raw.([]interface{}); isList {
+			_ = json.Unmarshal(data, &identifiers)
+		} else {
+			var identifier fhir.Identifier
+			if json.Unmarshal(data, &identifier) == nil {
+				identifiers = append(identifiers, identifier)
+			}
+		}
+	}
+	for _, identifier := range identifiers {
+		if identifier.System != nil && identifier.Value != nil {
+			return identifier
+		}
+	}
+	identifier := fhir.Identifier{
+		System: to.Ptr(sourceIdentifierSystem),
+		Value:  to.Ptr(sourceURL),
+	}
+	switch existing := resource["identifier"].(type) {
+	case []interface{}:
+		resource["identifier"] = append(existing, identifier)
+	case nil:
+		if resource["resourceType"] == "QuestionnaireResponse" {
+			// QuestionnaireResponse.identifier has a cardinality of 0..1
+			resource["identifier"] = identifier
+		} else {
+			resource["identifier"] = []interface{}{identifier}
+		}
+	}
+	return identifier
+}
+
+// parseLocation parses the resource type and ID from a transaction response entry location, e.g. Patient/123/_history/1.
+func parseLocation(location string) (string, string, error) {
+	if parsed, err := url.Parse(location); err == nil && parsed.IsAbs() {
+		location = parsed.Path
+	}
+	parts := strings.Split(strings.Trim(location, "/"), "/")
+	for i := len(parts) - 1; i > 0; i-- {
+		if parts[i] == "_history" {
+			parts = parts[:i]
+			break
+		}
+	}
+	if len(parts) < 2 {
+		return "", "", fmt.Errorf("invalid EHR transaction response location: %s", location)
+	}
+	return parts[len(parts)-2], parts[len(parts)-1], nil
+}
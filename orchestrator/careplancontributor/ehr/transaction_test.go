@@ -0,0 +1,267 @@
+package ehr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/careplancontributor/mock"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/test"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	"go.uber.org/mock/gomock"
+)
+
+func TestTaskTransaction(t *testing.T) {
+	cpsBaseURL, _ := url.Parse("https://cps.example.com/fhir")
+	patient := fhir.Patient{
+		Id: to.Ptr("1"),
+		Identifier: []fhir.Identifier{
+			{System: to.Ptr(coolfhir.BSNNamingSystem), Value: to.Ptr("111222333")},
+		},
+	}
+	serviceRequest := fhir.ServiceRequest{
+		Id:              to.Ptr("2"),
+		Subject:         fhir.Reference{Reference: to.Ptr("Patient/1")},
+		ReasonReference: []fhir.Reference{{Reference: to.Ptr("Condition/3")}},
+		Requester:       &fhir.Reference{Reference: to.Ptr("https://example.com/fhir/Practitioner/4")},
+	}
+	condition := fhir.Condition{
+		Id:      to.Ptr("3"),
+		Subject: fhir.Reference{Reference: to.Ptr("Patient/1")},
+	}
+	task := fhir.Task{
+		Id:      to.Ptr("5"),
+		For:     &fhir.Reference{Reference: to.Ptr("Patient/1")},
+		Focus:   &fhir.Reference{Reference: to.Ptr("ServiceRequest/2")},
+		BasedOn: []fhir.Reference{{Reference: to.Ptr("CarePlan/6")}},
+	}
+	questionnaireResponse := fhir.QuestionnaireResponse{
+		Id: to.Ptr("7"),
+	}
+	carePlan := fhir.CarePlan{
+		Id: to.Ptr("6"),
+	}
+	bundle := fhir.Bundle{}
+	for _, resource := range []interface{}{task, patient, serviceRequest, questionnaireResponse, carePlan, patient} {
+		bundle.Entry = append(bundle.Entry, fhir.BundleEntry{Resource: json.RawMessage(mustMarshal(t, resource))})
+	}
+
+	tx, err := TaskTransaction(cpsBaseURL, BundleSet{Bundles: []fhir.Bundle{bundle}}, []fhir.Condition{condition})
+
+	require.NoError(t, err)
+	require.Equal(t, fhir.BundleTypeTransaction, tx.Type)
+	require.Len(t, tx.Entry, 5, "CarePlan isn't written and the duplicate Patient is only written once")
+	fullURLs := map[string]string{}
+	resources := map[string]map[string]interface{}{}
+	requests := map[string]fhir.BundleEntryRequest{}
+	for _, entry := range tx.Entry {
+		require.Contains(t, *entry.FullUrl, "urn:uuid:")
+		var resource map[string]interface{}
+		require.NoError(t, json.Unmarshal(entry.Resource, &resource))
+		require.NotContains(t, resource, "id")
+		resourceType := resource["resourceType"].(string)
+		fullURLs[resourceType] = *entry.FullUrl
+		resources[resourceType] = resource
+		requests[resourceType] = *entry.Request
+	}
+	t.Run("references to resources in the transaction are replaced", func(t *testing.T) {
+		var actual fhir.Task
+		require.NoError(t, json.Unmarshal(mustMarshal(t, resources["Task"]), &actual))
+		require.Equal(t, fullURLs["Patient"], *actual.For.Reference)
+		require.Equal(t, fullURLs["ServiceRequest"], *actual.Focus.Reference)
+	})
+	t.Run("other local references are made absolute", func(t *testing.T) {
+		var actual fhir.Task
+		require.NoError(t, json.Unmarshal(mustMarshal(t, resources["Task"]), &actual))
+		require.Equal(t, "https://cps.example.com/fhir/CarePlan/6", *actual.BasedOn[0].Reference)
+	})
+	t.Run("absolute references are kept", func(t *testing.T) {
+		var actual fhir.ServiceRequest
+		require.NoError(t, json.Unmarshal(mustMarshal(t, resources["ServiceRequest"]), &actual))
+		require.Equal(t, "https://example.com/fhir/Practitioner/4", *actual.Requester.Reference)
+		require.Equal(t, fullURLs["Condition"], *actual.ReasonReference[0].Reference)
+	})
+	t.Run("meta.source is set to Care Plan Service URL", func(t *testing.T) {
+		var actual fhir.Patient
+		require.NoError(t, json.Unmarshal(mustMarshal(t, resources["Patient"]), &actual))
+		require.Equal(t, "https://cps.example.com/fhir/Patient/1", *actual.Meta.Source)
+	})
+	t.Run("conditional create on identifier", func(t *testing.T) {
+		for resourceType, request := range requests {
+			if resourceType != "Task" {
+				require.Equal(t, fhir.HTTPVerbPOST, request.Method)
+				require.Equal(t, resourceType, request.Url)
+			}
+		}
+		require.Equal(t, "identifier="+url.QueryEscape(coolfhir.BSNNamingSystem+"|111222333"), *requests["Patient"].IfNoneExist)
+	})
+	t.Run("conditional update of Task on identifier", func(t *testing.T) {
+		var task fhir.Task
+		require.NoError(t, json.Unmarshal(mustMarshal(t, resources["Task"]), &task))
+		require.Equal(t, fhir.HTTPVerbPUT, requests["Task"].Method)
+		require.Equal(t, "Task?identifier="+url.QueryEscape(coolfhir.IdentifierToToken(task.Identifier[0])), requests["Task"].Url)
+		require.Nil(t, requests["Task"].IfNoneExist)
+	})
+	t.Run("source identifier is added to resources without identifier", func(t *testing.T) {
+		var actual fhir.QuestionnaireResponse
+		require.NoError(t, json.Unmarshal(mustMarshal(t, resources["QuestionnaireResponse"]), &actual))
+		require.Equal(t, sourceIdentifierSystem, *actual.Identifier.System)
+		require.Equal(t, "https://cps.example.com/fhir/QuestionnaireResponse/7", *actual.Identifier.Value)
+		require.Equal(t, "identifier="+url.QueryEscape(sourceIdentifierSystem+"|https://cps.example.com/fhir/QuestionnaireResponse/7"), *requests["QuestionnaireResponse"].IfNoneExist)
+	})
+}
+
+func Test_writeTransaction(t *testing.T) {
+	ctx := context.Background()
+	task, resources := notificationTestResources()
+	ehrBaseURL, _ := url.Parse("https://ehr.example.com/fhir")
+	bundleSet := func(t *testing.T, cpsClient fhirclient.Client) BundleSet {
+		result, err := TaskNotificationBundleSet(ctx, cpsClient, *task.Id)
+		require.NoError(t, err)
+		return *result
+	}
+	t.Run("ok", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		cpsClient := &test.StubFHIRClient{Resources: resources}
+		ehrClient := mock.NewMockClient(ctrl)
+		ehrClient.EXPECT().Path(gomock.Any()).DoAndReturn(func(path ...string) *url.URL {
+			return ehrBaseURL.JoinPath(path...)
+		}).AnyTimes()
+		ehrClient.EXPECT().CreateWithContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, resource interface{}, result interface{}, _ ...fhirclient.Option) error {
+				tx := resource.(fhir.Bundle)
+				response := fhir.Bundle{Type: fhir.BundleTypeTransactionResponse}
+				for i := range tx.Entry {
+					response.Entry = append(response.Entry, fhir.BundleEntry{
+						Response: &fhir.BundleEntryResponse{
+							Status:   "201 Created",
+							Location: to.Ptr(strings.Split(tx.Entry[i].Request.Url, "?")[0] + "/ehr-" + string(rune('a'+i)) + "/_history/1"),
+						},
+					})
+				}
+				*result.(*fhir.Bundle) = response
+				return nil
+			})
+
+		writtenTask, err := writeTransaction(ctx, cpsClient, ehrClient, task, bundleSet(t, cpsClient))
+
+		require.NoError(t, err)
+		require.NotNil(t, writtenTask)
+		var updatedTask fhir.Task
+		require.NoError(t, cpsClient.ReadWithContext(ctx, "Task/"+*task.Id, &updatedTask))
+		var ehrReferences []string
+		for _, output := range updatedTask.Output {
+			if coolfhir.ConceptContainsCoding(EHRResourceOutputType.Coding[0], output.Type) {
+				ehrReferences = append(ehrReferences, *output.ValueReference.Reference)
+			}
+		}
+		require.Contains(t, ehrReferences, "https://ehr.example.com/fhir/Task/ehr-a")
+		require.Len(t, updatedTask.Output, len(task.Output)+len(ehrReferences), "existing outputs are retained")
+	})
+	t.Run("EHR resources already recorded", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		task, resources := notificationTestResources()
+		cpsClient := &test.StubFHIRClient{Resources: resources}
+		ehrClient := mock.NewMockClient(ctrl)
+		ehrClient.EXPECT().Path(gomock.Any()).DoAndReturn(func(path ...string) *url.URL {
+			return ehrBaseURL.JoinPath(path...)
+		}).AnyTimes()
+		ehrClient.EXPECT().CreateWithContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, resource interface{}, result interface{}, _ ...fhirclient.Option) error {
+				tx := resource.(fhir.Bundle)
+				response := fhir.Bundle{Type: fhir.BundleTypeTransactionResponse}
+				for i := range tx.Entry {
+					response.Entry = append(response.Entry, fhir.BundleEntry{
+						Response: &fhir.BundleEntryResponse{
+							Status:   "200 OK",
+							Location: to.Ptr(strings.Split(tx.Entry[i].Request.Url, "?")[0] + "/ehr-" + string(rune('a'+i))),
+						},
+					})
+				}
+				*result.(*fhir.Bundle) = response
+				return nil
+			}).Times(2)
+
+		bundles, err := TaskNotificationBundleSet(ctx, cpsClient, *task.Id)
+		require.NoError(t, err)
+		firstWrite, err := writeTransaction(ctx, cpsClient, ehrClient, task, *bundles)
+		require.NoError(t, err)
+		require.NotNil(t, firstWrite)
+		// The EHR resolves the conditional creates to the same resources, so the Task doesn't need to be updated again
+		secondWrite, err := writeTransaction(ctx, cpsClient, ehrClient, task, *bundles)
+
+		require.NoError(t, err)
+		require.Nil(t, secondWrite)
+	})
+	t.Run("EHR rejects transaction", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		cpsClient := &test.StubFHIRClient{Resources: resources}
+		ehrClient := mock.NewMockClient(ctrl)
+		ehrClient.EXPECT().CreateWithContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(fhirclient.OperationOutcomeError{
+				OperationOutcome: fhir.OperationOutcome{
+					Issue: []fhir.OperationOutcomeIssue{{Diagnostics: to.Ptr("invalid Patient")}},
+				},
+				HttpStatusCode: http.StatusUnprocessableEntity,
+			})
+
+		_, err := writeTransaction(ctx, cpsClient, ehrClient, task, bundleSet(t, cpsClient))
+
+		var badRequest *BadRequest
+		require.ErrorAs(t, err, &badRequest)
+		require.Equal(t, "invalid Patient", *badRequest.Reason)
+	})
+	t.Run("EHR unavailable", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		cpsClient := &test.StubFHIRClient{Resources: resources}
+		ehrClient := mock.NewMockClient(ctrl)
+		ehrClient.EXPECT().CreateWithContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(fhirclient.OperationOutcomeError{HttpStatusCode: http.StatusServiceUnavailable})
+
+		_, err := writeTransaction(ctx, cpsClient, ehrClient, task, bundleSet(t, cpsClient))
+
+		var badRequest *BadRequest
+		require.False(t, errors.As(err, &badRequest))
+		require.ErrorContains(t, err, "failed to execute EHR transaction")
+	})
+}
+
+func Test_parseLocation(t *testing.T) {
+	tests := []struct {
+		location     string
+		resourceType string
+		id           string
+		expectError  bool
+	}{
+		{location: "Patient/123", resourceType: "Patient", id: "123"},
+		{location: "Patient/123/_history/1", resourceType: "Patient", id: "123"},
+		{location: "https://ehr.example.com/fhir/Task/abc/_history/2", resourceType: "Task", id: "abc"},
+		{location: "Patient", expectError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.location, func(t *testing.T) {
+			resourceType, id, err := parseLocation(tt.location)
+			if tt.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.resourceType, resourceType)
+			require.Equal(t, tt.id, id)
+		})
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	return data
+}
@@ -0,0 +1,92 @@
+package careplancontributor
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/careplancontributor/ehr"
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/debug"
+	"github.com/SanteonNL/orca/orchestrator/lib/logging"
+	"github.com/SanteonNL/orca/orchestrator/lib/otel"
+	"github.com/google/uuid"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// handleCommunicationNotification handles a notification of a Communication (secure message) sent by another member of a CarePlan's CareTeam.
+// If the local care organization is one of its recipients, it publishes a CommunicationReceivedEvent,
+// which is delivered to the EHR (if configured) so the message appears in the EHR's inbox.
+func (s Service) handleCommunicationNotification(ctx context.Context, fhirClient fhirclient.Client, fhirBaseURL *url.URL, resourceUrl string) error {
+	ctx, span := tracer.Start(
+		ctx,
+		debug.GetFullCallerName(),
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			attribute.String(otel.FHIRResourceType, fhir.ResourceTypeCommunication.String()),
+		),
+	)
+	defer span.End()
+
+	var communication fhir.Communication
+	if err := fhirClient.ReadWithContext(ctx, resourceUrl, &communication); err != nil {
+		return otel.Error(span, err)
+	}
+	if communication.Meta == nil {
+		communication.Meta = &fhir.Meta{}
+	}
+	communication.Meta.Source = &resourceUrl
+
+	identities, err := s.profile.Identities(ctx)
+	if err != nil {
+		return otel.Error(span, err)
+	}
+	localIdentifiers := coolfhir.OrganizationIdentifiers(identities)
+	isRecipient := false
+	for _, recipient := range communication.Recipient {
+		if recipient.Identifier != nil && coolfhir.HasIdentifier(*recipient.Identifier, localIdentifiers...) {
+			isRecipient = true
+			break
+		}
+	}
+	if !isRecipient {
+		slog.DebugContext(ctx, "Local care organization isn't a recipient of Communication, ignoring",
+			slog.String(logging.FieldResourceReference, resourceUrl),
+		)
+		span.SetStatus(codes.Ok, "not a recipient")
+		return nil
+	}
+
+	if s.eventManager.HasSubscribers(ehr.CommunicationReceivedEvent{}) {
+		tenant, err := tenants.FromContext(ctx)
+		if err != nil {
+			return otel.Error(span, err)
+		}
+		if err := s.eventManager.Notify(ctx, &ehr.CommunicationReceivedEvent{
+			FHIRBaseURL:   fhirBaseURL.String(),
+			Communication: communication,
+			TenantID:      tenant.ID,
+			MessageID:     communicationMessageID(fhirBaseURL, communication),
+		}); err != nil {
+			return otel.Error(span, err, "failed to publish communication-received event")
+		}
+	}
+	span.SetStatus(codes.Ok, "")
+	return nil
+}
+
+// communicationMessageID derives the ID of the delivery of the given Communication to the EHR from its URL and version,
+// so repeated notifications of the same Communication version are delivered with the same ID, allowing the EHR to deduplicate them.
+// If the Communication has no version, a random ID is returned.
+func communicationMessageID(fhirBaseURL *url.URL, communication fhir.Communication) string {
+	if communication.Id == nil || communication.Meta == nil || communication.Meta.VersionId == nil {
+		return uuid.NewString()
+	}
+	versionURL := fhirBaseURL.JoinPath("Communication", *communication.Id, "_history", *communication.Meta.VersionId)
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(versionURL.String())).String()
+}
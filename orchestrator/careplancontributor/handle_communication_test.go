@@ -0,0 +1,103 @@
+package careplancontributor
+
+import (
+	"context"
+	"testing"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/careplancontributor/ehr"
+	"github.com/SanteonNL/orca/orchestrator/careplancontributor/mock"
+	"github.com/SanteonNL/orca/orchestrator/cmd/profile"
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/events"
+	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/must"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/SanteonNL/orca/orchestrator/messaging"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	"go.uber.org/mock/gomock"
+)
+
+func TestService_handleCommunicationNotification(t *testing.T) {
+	ctx := tenants.WithTenant(context.Background(), tenants.Test().Sole())
+	fhirBaseURL := must.ParseURL("https://example.com/cps")
+	resourceURL := fhirBaseURL.String() + "/Communication/1"
+	communicationTo := func(recipient auth.Principal) fhir.Communication {
+		return fhir.Communication{
+			Id:      to.Ptr("1"),
+			Meta:    &fhir.Meta{VersionId: to.Ptr("2")},
+			BasedOn: []fhir.Reference{{Reference: to.Ptr("CarePlan/1")}},
+			Sender: &fhir.Reference{
+				Identifier: &auth.TestPrincipal1.Organization.Identifier[0],
+			},
+			Recipient: []fhir.Reference{{Identifier: &recipient.Organization.Identifier[0]}},
+			Payload:   []fhir.CommunicationPayload{{ContentString: to.Ptr("Hello")}},
+		}
+	}
+	setup := func(t *testing.T, communication fhir.Communication) (*Service, fhirclient.Client, *[]ehr.CommunicationReceivedEvent) {
+		ctrl := gomock.NewController(t)
+		fhirClient := mock.NewMockClient(ctrl)
+		fhirClient.EXPECT().ReadWithContext(gomock.Any(), resourceURL, gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, result interface{}, _ ...fhirclient.Option) error {
+				*result.(*fhir.Communication) = communication
+				return nil
+			})
+		eventManager := events.NewManager(messaging.NewMemoryBroker())
+		var received []ehr.CommunicationReceivedEvent
+		require.NoError(t, eventManager.Subscribe(ehr.CommunicationReceivedEvent{}, func(_ context.Context, event events.Type) error {
+			received = append(received, *event.(*ehr.CommunicationReceivedEvent))
+			return nil
+		}))
+		service := &Service{
+			profile:      profile.TestProfile{Principal: auth.TestPrincipal2},
+			eventManager: eventManager,
+		}
+		return service, fhirClient, &received
+	}
+	t.Run("local organization is recipient", func(t *testing.T) {
+		service, fhirClient, received := setup(t, communicationTo(*auth.TestPrincipal2))
+
+		err := service.handleCommunicationNotification(ctx, fhirClient, fhirBaseURL, resourceURL)
+
+		require.NoError(t, err)
+		require.Len(t, *received, 1)
+		event := (*received)[0]
+		require.Equal(t, fhirBaseURL.String(), event.FHIRBaseURL)
+		require.Equal(t, tenants.Test().Sole().ID, event.TenantID)
+		require.Equal(t, communicationMessageID(fhirBaseURL, communicationTo(*auth.TestPrincipal2)), event.MessageID)
+		require.Equal(t, resourceURL, *event.Communication.Meta.Source)
+		require.Equal(t, "Hello", *event.Communication.Payload[0].ContentString)
+	})
+	t.Run("local organization is not a recipient", func(t *testing.T) {
+		service, fhirClient, received := setup(t, communicationTo(*auth.TestPrincipal3))
+
+		err := service.handleCommunicationNotification(ctx, fhirClient, fhirBaseURL, resourceURL)
+
+		require.NoError(t, err)
+		require.Empty(t, *received)
+	})
+}
+
+func Test_communicationMessageID(t *testing.T) {
+	fhirBaseURL := must.ParseURL("https://example.com/cps")
+	communication := func(id string, versionID string) fhir.Communication {
+		return fhir.Communication{Id: to.Ptr(id), Meta: &fhir.Meta{VersionId: to.Ptr(versionID)}}
+	}
+
+	t.Run("same version", func(t *testing.T) {
+		require.Equal(t, communicationMessageID(fhirBaseURL, communication("1", "1")), communicationMessageID(fhirBaseURL, communication("1", "1")))
+	})
+	t.Run("other version", func(t *testing.T) {
+		require.NotEqual(t, communicationMessageID(fhirBaseURL, communication("1", "1")), communicationMessageID(fhirBaseURL, communication("1", "2")))
+	})
+	t.Run("other Communication", func(t *testing.T) {
+		require.NotEqual(t, communicationMessageID(fhirBaseURL, communication("1", "1")), communicationMessageID(fhirBaseURL, communication("2", "1")))
+	})
+	t.Run("other CPS", func(t *testing.T) {
+		require.NotEqual(t, communicationMessageID(fhirBaseURL, communication("1", "1")), communicationMessageID(must.ParseURL("https://example.org/cps"), communication("1", "1")))
+	})
+	t.Run("no version", func(t *testing.T) {
+		require.NotEqual(t, communicationMessageID(fhirBaseURL, fhir.Communication{Id: to.Ptr("1")}), communicationMessageID(fhirBaseURL, fhir.Communication{Id: to.Ptr("1")}))
+	})
+}
@@ -320,6 +320,95 @@ func (s *Service) acceptPrimaryTask(ctx context.Context, cpsClient fhirclient.Cl
 	return nil
 }
 
+// isTaskReviewRequired determines whether the primary Task must be reviewed by a care professional before it can be accepted,
+// according to the tenant's Task Engine configuration.
+func (s *Service) isTaskReviewRequired(ctx context.Context, cpsClient fhirclient.Client, primaryTask *fhir.Task) (bool, error) {
+	tenant, err := tenants.FromContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	review := tenant.TaskEngine.Review
+	if review.Enabled {
+		return true, nil
+	}
+	if len(review.Services) == 0 {
+		return false, nil
+	}
+	if primaryTask.Focus == nil || primaryTask.Focus.Reference == nil {
+		return false, fmt.Errorf("Task.focus must reference the ServiceRequest to determine if Task requires review (task=%s)", to.Value(primaryTask.Id))
+	}
+	var serviceRequest fhir.ServiceRequest
+	if err := cpsClient.Read(*primaryTask.Focus.Reference, &serviceRequest); err != nil {
+		return false, fmt.Errorf("failed to fetch ServiceRequest to determine if Task requires review (path=%s, task=%s): %w", *primaryTask.Focus.Reference, *primaryTask.Id, err)
+	}
+	if serviceRequest.Code == nil {
+		return false, nil
+	}
+	return review.Required(serviceRequest.Code.Coding), nil
+}
+
+// requestPrimaryTaskReview marks the primary Task as awaiting review (status received, business status awaiting-review),
+// and notifies the EHR so a care professional can accept or reject it.
+func (s *Service) requestPrimaryTaskReview(ctx context.Context, cpsClient fhirclient.Client, primaryTask *fhir.Task) error {
+	ctx, span := tracer.Start(
+		ctx,
+		debug.GetFullCallerName(),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String(otel.FHIRTaskID, to.Value(primaryTask.Id)),
+			attribute.String(otel.FHIRTaskStatus, primaryTask.Status.Code()),
+		),
+	)
+	defer span.End()
+
+	if primaryTask.Status != fhir.TaskStatusRequested && primaryTask.Status != fhir.TaskStatusReceived {
+		slog.DebugContext(ctx, "primary Task.status != requested||received (workflow already started) - not requesting review")
+		span.SetStatus(codes.Ok, "Task status not requested or received, skipping")
+		return nil
+	}
+	if isTaskAwaitingReview(primaryTask) {
+		slog.DebugContext(ctx, "primary Task already awaits review - not requesting review again")
+		span.SetStatus(codes.Ok, "Task already awaits review, skipping")
+		return nil
+	}
+	ref := "Task/" + *primaryTask.Id
+	slog.InfoContext(
+		ctx,
+		"TaskEngine: primary Task requires review before it can be accepted",
+		slog.String(logging.FieldResourceReference, ref),
+		slog.String(logging.FieldResourceType, fhir.ResourceTypeTask.String()),
+		slog.String(logging.FieldResourceID, *primaryTask.Id),
+	)
+	primaryTask.Status = fhir.TaskStatusReceived
+	primaryTask.BusinessStatus = &fhir.CodeableConcept{
+		Text: to.Ptr(taskBusinessStatusAwaitingReview),
+	}
+	if note := s.getTaskStatusNote(primaryTask.Status); note != nil {
+		primaryTask.Note = append(primaryTask.Note, fhir.Annotation{
+			Text: *note,
+		})
+	}
+	if err := cpsClient.Update(ref, primaryTask, primaryTask); err != nil {
+		return otel.Error(span, fmt.Errorf("failed to update primary Task for review (id=%s): %w", ref, err), err.Error())
+	}
+
+	if s.notifier != nil {
+		if err := s.notifier.NotifyTaskReviewRequested(ctx, cpsClient.Path().String(), primaryTask); err != nil {
+			// The Task can still be reviewed through the review endpoint, so don't fail
+			slog.WarnContext(
+				ctx,
+				"Task awaits review, but notifying the EHR failed",
+				slog.String(logging.FieldResourceReference, ref),
+				slog.String(logging.FieldResourceType, fhir.ResourceTypeTask.String()),
+				slog.String(logging.FieldError, otel.Error(span, err, err.Error()).Error()),
+			)
+			return nil
+		}
+	}
+	span.SetStatus(codes.Ok, "")
+	return nil
+}
+
 func (s *Service) fetchQuestionnaireByID(ctx context.Context, cpsClient fhirclient.Client, ref string, questionnaire *fhir.Questionnaire) error {
 	slog.DebugContext(
 		ctx,
@@ -496,8 +585,22 @@ func (s *Service) createSubTaskOrAcceptPrimaryTask(ctx context.Context, cpsClien
 				slog.String(logging.FieldResourceID, *task.Id),
 				slog.String(logging.FieldResourceType, fhir.ResourceTypeTask.String()),
 			)
+			reviewRequired, err := s.isTaskReviewRequired(ctx, cpsClient, primaryTask)
+			if err != nil {
+				return otel.Error(span, err, err.Error())
+			}
+			span.SetAttributes(attribute.Bool("task.review_required", reviewRequired))
+			if reviewRequired {
+				// Leave acceptance to a care professional, who accepts or rejects the Task through the review endpoint
+				err = s.requestPrimaryTaskReview(ctx, cpsClient, primaryTask)
+				if err != nil {
+					return otel.Error(span, err, err.Error())
+				}
+				span.SetStatus(codes.Ok, "Primary task awaits review")
+				return nil
+			}
 			// Mark the primary task as accepted
-			err := s.acceptPrimaryTask(ctx, cpsClient, primaryTask)
+			err = s.acceptPrimaryTask(ctx, cpsClient, primaryTask)
 			if err != nil {
 				return otel.Error(span, err, err.Error())
 			} else {
@@ -566,7 +669,8 @@ func (s *Service) createSubTaskOrAcceptPrimaryTask(ctx context.Context, cpsClien
 
 // selectWorkflow determines the workflow to use based on the Task's focus, and reasonCode or reasonReference.
 // It first selects the type of service, from the Task.focus (ServiceRequest), and then selects the workflow based on the Task.reasonCode or Task.reasonReference.
-// If it finds no, or multiple, matching workflows, it returns an error.
+// If multiple workflows match, one is chosen based on the workflow requested by the placer, Task.performerType and workflow priority (see taskengine.SelectWorkflow).
+// If it finds no matching workflows, or can't choose between them, it returns an error.
 func (s *Service) selectWorkflow(ctx context.Context, cpsClient fhirclient.Client, task *fhir.Task) (*taskengine.Workflow, error) {
 	ctx, span := tracer.Start(
 		ctx,
@@ -631,7 +735,7 @@ func (s *Service) selectWorkflow(ctx context.Context, cpsClient fhirclient.Clien
 		}
 		for _, reasonCoding := range taskReasonCodes {
 			workflowLookups++
-			workflow, err := s.workflows.Provide(ctx, serviceCoding, reasonCoding)
+			workflows, err := s.workflows.Provide(ctx, serviceCoding, reasonCoding)
 			if errors.Is(err, taskengine.ErrWorkflowNotFound) {
 				slog.DebugContext(ctx, "No workflow found",
 					slog.String(logging.FieldError, err.Error()),
@@ -645,7 +749,7 @@ func (s *Service) selectWorkflow(ctx context.Context, cpsClient fhirclient.Clien
 				// Other error occurred
 				return nil, otel.Error(span, fmt.Errorf("workflow lookup (service=%s|%s, condition=%s|%s, task=%s): %w", *serviceCoding.System, *serviceCoding.Code, *reasonCoding.System, *reasonCoding.Code, *task.Id, err), err.Error())
 			}
-			matchedWorkflows = append(matchedWorkflows, workflow)
+			matchedWorkflows = append(matchedWorkflows, workflows...)
 		}
 	}
 
@@ -656,12 +760,20 @@ func (s *Service) selectWorkflow(ctx context.Context, cpsClient fhirclient.Clien
 
 	if len(matchedWorkflows) == 0 {
 		return nil, otel.Error(span, fmt.Errorf("ServiceRequest.code and Task.reason.code does not match any workflows (task=%s)", *task.Id), "no matching workflows found")
-	} else if len(matchedWorkflows) > 1 {
+	}
+	workflow, err := taskengine.SelectWorkflow(*task, matchedWorkflows)
+	if errors.Is(err, taskengine.ErrMultipleWorkflows) {
 		return nil, otel.Error(span, fmt.Errorf("ServiceRequest.code and Task.reason.code matches multiple workflows, need to choose one (task=%s)", *task.Id), "multiple workflows matched")
+	} else if err != nil {
+		return nil, otel.Error(span, fmt.Errorf("ServiceRequest.code and Task.reason.code: %w (task=%s)", err, *task.Id), "requested workflow not matched")
 	}
+	span.SetAttributes(
+		attribute.String("workflow.questionnaire_url", workflow.Start().QuestionnaireUrl),
+		attribute.Int("workflow.priority", workflow.Priority),
+	)
 
 	span.SetStatus(codes.Ok, "")
-	return matchedWorkflows[0], nil
+	return workflow, nil
 }
 
 // getSubTask creates a new subtask providing the questionnaire reference as Task.input.valueReference
@@ -705,14 +817,7 @@ func (s *Service) getSubTask(parentTask *fhir.Task, questionnaireRef string) fhi
 }
 
 func (s *Service) getTaskStatusNote(status fhir.TaskStatus) *string {
-	// remove all non A-Z characters
-	mapKey := strings.Map(func(r rune) rune {
-		if r >= 'a' && r <= 'z' {
-			return r
-		}
-		return -1
-	}, status.Code())
-	if note, ok := s.config.TaskFiller.StatusNote[mapKey]; ok {
+	if note, ok := s.config.TaskFiller.StatusNote[tenants.TaskStatusKey(status)]; ok {
 		return &note
 	}
 	return nil
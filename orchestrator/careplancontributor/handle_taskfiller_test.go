@@ -85,6 +85,7 @@ func TestService_handleTaskFillerCreate(t *testing.T) {
 		numBundlesPosted        int
 		mock                    func(*mock.MockClient)
 		expectSubmission        bool
+		expectReviewRequest     bool
 		expectPrimaryTaskStatus *fhir.TaskStatus
 		expectNote              string
 	}{
@@ -147,6 +148,40 @@ func TestService_handleTaskFillerCreate(t *testing.T) {
 			}),
 			expectedError: errors.New("failed to process new primary Task: task rejected by filler: ServiceRequest.code and Task.reason.code matches multiple workflows, need to choose one (task=primary)"),
 		},
+		{
+			name:                    "primary task, multiple workflow matches, placer chooses workflow",
+			expectPrimaryTaskStatus: to.Ptr(fhir.TaskStatusReceived),
+			notificationTask: deep.AlterCopy(primaryTask, func(t *fhir.Task) {
+				t.ReasonCode = &fhir.CodeableConcept{
+					Coding: []fhir.Coding{
+						{
+							System: to.Ptr("http://snomed.info/sct"),
+							Code:   to.Ptr("13645005"), // COPD
+						},
+						{
+							System: to.Ptr("http://snomed.info/sct"),
+							Code:   to.Ptr("84114007"), // Heart failure
+						},
+					},
+				}
+				t.Input = append(t.Input, fhir.TaskInput{
+					Type:           fhir.CodeableConcept{Coding: []fhir.Coding{taskengine.WorkflowInputType}},
+					ValueCanonical: to.Ptr("http://example.com/fhir/Questionnaire/questionnaire-copd"),
+				})
+			}),
+			numBundlesPosted: 1,
+		},
+		{
+			name:                    "error: primary task, requested workflow doesn't match",
+			expectPrimaryTaskStatus: to.Ptr(fhir.TaskStatusFailed),
+			notificationTask: deep.AlterCopy(primaryTask, func(t *fhir.Task) {
+				t.Input = append(t.Input, fhir.TaskInput{
+					Type:           fhir.CodeableConcept{Coding: []fhir.Coding{taskengine.WorkflowInputType}},
+					ValueReference: &fhir.Reference{Reference: to.Ptr("Questionnaire/other")},
+				})
+			}),
+			expectedError: errors.New("failed to process new primary Task: task rejected by filler: ServiceRequest.code and Task.reason.code: requested workflow is not offered for the service and condition: [Questionnaire/other] (task=primary)"),
+		},
 		{
 			name:                    "primary task, duplicate reasonCodes (but fine, since they're the same)",
 			expectPrimaryTaskStatus: to.Ptr(fhir.TaskStatusReceived),
@@ -286,6 +321,70 @@ func TestService_handleTaskFillerCreate(t *testing.T) {
 			expectSubmission: true,
 			expectNote:       "Task accepted by TaskFiller",
 		},
+		{
+			name:             "subtask status=completed, tenant requires review, primary task should await review",
+			notificationTask: subTask,
+			ctx: tenants.WithTenant(defaultCtx, tenants.Test(func(properties *tenants.Properties) {
+				properties.TaskEngine = tenants.TaskEngineProperties{
+					Enabled: true,
+					Review: tenants.TaskReviewProperties{
+						Enabled: true,
+					},
+				}
+			}).Sole()),
+			mock: func(client *mock.MockClient) {
+				client.EXPECT().
+					Update("Task/primary", gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ string, updatedPrimaryTask *fhir.Task, _ interface{}, options ...fhirclient.Option) error {
+						assert.Equal(t, fhir.TaskStatusReceived, updatedPrimaryTask.Status)
+						assert.True(t, isTaskAwaitingReview(updatedPrimaryTask))
+						return nil
+					})
+			},
+			expectReviewRequest: true,
+		},
+		{
+			name:             "subtask status=completed, review required for requested service, primary task should await review",
+			notificationTask: subTask,
+			ctx: tenants.WithTenant(defaultCtx, tenants.Test(func(properties *tenants.Properties) {
+				properties.TaskEngine = tenants.TaskEngineProperties{
+					Enabled: true,
+					Review: tenants.TaskReviewProperties{
+						Services: []string{"http://snomed.info/sct|719858009"},
+					},
+				}
+			}).Sole()),
+			mock: func(client *mock.MockClient) {
+				client.EXPECT().
+					Update("Task/primary", gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ string, updatedPrimaryTask *fhir.Task, _ interface{}, options ...fhirclient.Option) error {
+						assert.Equal(t, fhir.TaskStatusReceived, updatedPrimaryTask.Status)
+						return nil
+					})
+			},
+			expectReviewRequest: true,
+		},
+		{
+			name:             "subtask status=completed, review required for other service, primary task should be accepted",
+			notificationTask: subTask,
+			ctx: tenants.WithTenant(defaultCtx, tenants.Test(func(properties *tenants.Properties) {
+				properties.TaskEngine = tenants.TaskEngineProperties{
+					Enabled: true,
+					Review: tenants.TaskReviewProperties{
+						Services: []string{"http://snomed.info/sct|other"},
+					},
+				}
+			}).Sole()),
+			mock: func(client *mock.MockClient) {
+				client.EXPECT().
+					Update("Task/primary", gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ string, updatedPrimaryTask *fhir.Task, _ interface{}, options ...fhirclient.Option) error {
+						assert.Equal(t, fhir.TaskStatusAccepted, updatedPrimaryTask.Status)
+						return nil
+					})
+			},
+			expectSubmission: true,
+		},
 		{
 			name:             "subtask status=completed, primary task status=accepted (nothing should be done)",
 			notificationTask: subTask,
@@ -421,6 +520,11 @@ func TestService_handleTaskFillerCreate(t *testing.T) {
 				expectedSubmissions = 1
 			}
 			notifierMock.EXPECT().NotifyTaskAccepted(gomock.Any(), fhirBaseURL.String(), gomock.Any()).Times(expectedSubmissions)
+			expectedReviewRequests := 0
+			if tt.expectReviewRequest {
+				expectedReviewRequests = 1
+			}
+			notifierMock.EXPECT().NotifyTaskReviewRequested(gomock.Any(), fhirBaseURL.String(), gomock.Any()).Times(expectedReviewRequests)
 			var capturedTx fhir.Bundle
 			if tt.numBundlesPosted > 0 {
 				mockFHIRClient.EXPECT().
@@ -514,7 +618,7 @@ func TestService_getSubTask(t *testing.T) {
 	taskBytes, _ := json.Marshal(primaryTask)
 	var task fhir.Task
 	json.Unmarshal(taskBytes, &task)
-	workflow, err := service.workflows.Provide(context.Background(),
+	workflows, err := service.workflows.Provide(context.Background(),
 		fhir.Coding{
 			System: to.Ptr("http://snomed.info/sct"),
 			Code:   to.Ptr("719858009"),
@@ -523,8 +627,8 @@ func TestService_getSubTask(t *testing.T) {
 			System: to.Ptr("http://snomed.info/sct"),
 			Code:   to.Ptr("13645005"),
 		})
-	workflowStep := workflow.Start()
 	require.NoError(t, err)
+	workflowStep := workflows[0].Start()
 	questionnaire, err := service.workflows.QuestionnaireLoader().Load(context.Background(), workflowStep.QuestionnaireUrl)
 	require.NoError(t, err)
 	require.NotNil(t, questionnaire)
@@ -652,3 +756,17 @@ var subTask = deep.AlterCopy(primaryTask, func(subTask *fhir.Task) {
 	subTask.Id = to.Ptr("subtask")
 	subTask.Status = fhir.TaskStatusCompleted
 })
+
+func TestService_isTaskReviewRequired(t *testing.T) {
+	ctx := tenants.WithTenant(context.Background(), tenants.Test(func(properties *tenants.Properties) {
+		properties.TaskEngine.Review.Services = []string{"http://snomed.info/sct|719858009"}
+	}).Sole())
+	t.Run("Task without focus", func(t *testing.T) {
+		service := &Service{}
+
+		reviewRequired, err := service.isTaskReviewRequired(ctx, mock.NewMockClient(gomock.NewController(t)), &fhir.Task{Id: to.Ptr("primary")})
+
+		require.EqualError(t, err, "Task.focus must reference the ServiceRequest to determine if Task requires review (task=primary)")
+		require.False(t, reviewRequired)
+	})
+}
@@ -0,0 +1,151 @@
+package careplancontributor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/debug"
+	"github.com/SanteonNL/orca/orchestrator/lib/logging"
+	"github.com/SanteonNL/orca/orchestrator/lib/otel"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// taskBusinessStatusAwaitingReview is set as Task.businessStatus on primary Tasks that completed Questionnaire negotiation,
+// but need to be reviewed by a care professional before they are accepted or rejected.
+const taskBusinessStatusAwaitingReview = "awaiting-review"
+
+const (
+	taskReviewDecisionAccept = "accept"
+	taskReviewDecisionReject = "reject"
+)
+
+func isTaskAwaitingReview(task *fhir.Task) bool {
+	return task.BusinessStatus != nil && task.BusinessStatus.Text != nil && *task.BusinessStatus.Text == taskBusinessStatusAwaitingReview
+}
+
+// handleTaskReview handles the review decision of a care professional on a Task that awaits review.
+// It takes FHIR Parameters with the following parameters:
+// - task: absolute reference to the reviewed Task (valueReference)
+// - decision: accept or reject (valueCode)
+// - reason: reason for the decision, required when rejecting (valueString)
+func (s *Service) handleTaskReview(httpResponse http.ResponseWriter, httpRequest *http.Request) {
+	task, err := s.reviewTask(httpRequest)
+	if err != nil {
+		coolfhir.WriteOperationOutcomeFromError(httpRequest.Context(), err, "CarePlanContributor/TaskReview", httpResponse)
+		return
+	}
+	coolfhir.SendResponse(httpResponse, http.StatusOK, task)
+}
+
+func (s *Service) reviewTask(httpRequest *http.Request) (*fhir.Task, error) {
+	ctx, span := tracer.Start(
+		httpRequest.Context(),
+		debug.GetFullCallerName(),
+		trace.WithSpanKind(trace.SpanKindServer),
+	)
+	defer span.End()
+
+	requestBody, err := io.ReadAll(httpRequest.Body)
+	if err != nil {
+		return nil, otel.Error(span, fmt.Errorf("failed to read request body: %w", err))
+	}
+	var params fhir.Parameters
+	if err := json.Unmarshal(requestBody, &params); err != nil {
+		return nil, otel.Error(span, coolfhir.BadRequest("failed to parse request body as FHIR Parameters: %s", err.Error()))
+	}
+	taskReference, err := getParameter[fhir.Reference](params, "task", func(parameter fhir.ParametersParameter) *fhir.Reference {
+		if parameter.ValueReference == nil || parameter.ValueReference.Reference == nil {
+			return nil
+		}
+		return parameter.ValueReference
+	})
+	if err != nil {
+		return nil, otel.Error(span, coolfhir.BadRequestError(err))
+	}
+	decision, err := getParameter[string](params, "decision", func(parameter fhir.ParametersParameter) *string {
+		return parameter.ValueCode
+	})
+	if err != nil {
+		return nil, otel.Error(span, coolfhir.BadRequestError(err))
+	}
+	if *decision != taskReviewDecisionAccept && *decision != taskReviewDecisionReject {
+		return nil, otel.Error(span, coolfhir.BadRequest("parameter decision must be either '%s' or '%s'", taskReviewDecisionAccept, taskReviewDecisionReject))
+	}
+	reason, _ := getParameter[string](params, "reason", func(parameter fhir.ParametersParameter) *string {
+		return parameter.ValueString
+	})
+	if *decision == taskReviewDecisionReject && reason == nil {
+		return nil, otel.Error(span, coolfhir.BadRequest("parameter reason is required when rejecting a Task"))
+	}
+	span.SetAttributes(
+		attribute.String("task.review_decision", *decision),
+		attribute.String("fhir.task_ref", *taskReference.Reference),
+	)
+
+	cpsBaseURL, taskRef, err := coolfhir.ParseExternalLiteralReference(*taskReference.Reference, "Task")
+	if err != nil {
+		return nil, otel.Error(span, coolfhir.BadRequest("parameter task must be an absolute reference to a Task"))
+	}
+	if err := s.validateFHIRBaseURL(cpsBaseURL); err != nil {
+		return nil, otel.Error(span, coolfhir.BadRequestError(err))
+	}
+	cpsClient, _, err := s.createFHIRClientForURL(ctx, cpsBaseURL)
+	if err != nil {
+		return nil, otel.Error(span, err)
+	}
+	var task fhir.Task
+	if err := cpsClient.ReadWithContext(ctx, taskRef, &task); err != nil {
+		return nil, otel.Error(span, fmt.Errorf("failed to read Task (ref=%s): %w", *taskReference.Reference, err))
+	}
+
+	identities, err := s.profile.Identities(ctx)
+	if err != nil {
+		return nil, otel.Error(span, err)
+	}
+	if isOwner, _ := coolfhir.IsIdentifierTaskOwnerAndRequester(&task, coolfhir.OrganizationIdentifiers(identities)); !isOwner {
+		return nil, otel.Error(span, coolfhir.NewErrorWithCode("only the Task owner can review the Task", http.StatusForbidden))
+	}
+	if task.Status != fhir.TaskStatusReceived || !isTaskAwaitingReview(&task) {
+		return nil, otel.Error(span, coolfhir.NewErrorWithCode("Task does not await review", http.StatusConflict))
+	}
+
+	slog.InfoContext(
+		ctx,
+		"Task reviewed by care professional",
+		slog.String(logging.FieldResourceReference, taskRef),
+		slog.String(logging.FieldResourceType, fhir.ResourceTypeTask.String()),
+		slog.String("decision", *decision),
+	)
+	task.BusinessStatus = nil
+	if *decision == taskReviewDecisionAccept {
+		if reason != nil {
+			task.Note = append(task.Note, fhir.Annotation{
+				Text: *reason,
+			})
+		}
+		err = s.acceptPrimaryTask(ctx, cpsClient, &task)
+	} else {
+		task.Status = fhir.TaskStatusRejected
+		task.StatusReason = &fhir.CodeableConcept{
+			Text: reason,
+		}
+		if note := s.getTaskStatusNote(task.Status); note != nil {
+			task.Note = append(task.Note, fhir.Annotation{
+				Text: *note,
+			})
+		}
+		err = cpsClient.UpdateWithContext(ctx, taskRef, task, &task)
+	}
+	if err != nil {
+		return nil, otel.Error(span, err)
+	}
+	span.SetStatus(codes.Ok, "")
+	return &task, nil
+}
@@ -0,0 +1,171 @@
+package careplancontributor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/careplancontributor/ehr"
+	"github.com/SanteonNL/orca/orchestrator/careplancontributor/mock"
+	"github.com/SanteonNL/orca/orchestrator/cmd/profile"
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/deep"
+	"github.com/SanteonNL/orca/orchestrator/lib/must"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	"go.uber.org/mock/gomock"
+)
+
+func TestService_handleTaskReview(t *testing.T) {
+	ctx := tenants.WithTenant(context.Background(), tenants.Test().Sole())
+	cpsBaseURL := must.ParseURL("https://example.com/cps")
+	task := fhir.Task{
+		Id:     to.Ptr("1"),
+		Status: fhir.TaskStatusReceived,
+		BusinessStatus: &fhir.CodeableConcept{
+			Text: to.Ptr(taskBusinessStatusAwaitingReview),
+		},
+		Owner: &fhir.Reference{
+			Identifier: &auth.TestPrincipal1.Organization.Identifier[0],
+		},
+		Requester: &fhir.Reference{
+			Identifier: &auth.TestPrincipal2.Organization.Identifier[0],
+		},
+	}
+	reviewParams := func(decision string, reason *string) fhir.Parameters {
+		params := fhir.Parameters{
+			Parameter: []fhir.ParametersParameter{
+				{
+					Name: "task",
+					ValueReference: &fhir.Reference{
+						Reference: to.Ptr(cpsBaseURL.JoinPath("Task/1").String()),
+					},
+				},
+				{
+					Name:      "decision",
+					ValueCode: to.Ptr(decision),
+				},
+			},
+		}
+		if reason != nil {
+			params.Parameter = append(params.Parameter, fhir.ParametersParameter{
+				Name:        "reason",
+				ValueString: reason,
+			})
+		}
+		return params
+	}
+
+	tests := []struct {
+		name           string
+		params         fhir.Parameters
+		task           fhir.Task
+		mock           func(client *mock.MockClient, notifier *ehr.MockNotifier)
+		expectedStatus int
+		expectedTask   fhir.TaskStatus
+	}{
+		{
+			name:   "accept",
+			params: reviewParams(taskReviewDecisionAccept, nil),
+			task:   task,
+			mock: func(client *mock.MockClient, notifier *ehr.MockNotifier) {
+				client.EXPECT().Update("Task/1", gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ string, updatedTask *fhir.Task, _ interface{}, _ ...fhirclient.Option) error {
+						assert.Nil(t, updatedTask.BusinessStatus)
+						return nil
+					})
+				notifier.EXPECT().NotifyTaskAccepted(gomock.Any(), cpsBaseURL.String(), gomock.Any())
+			},
+			expectedStatus: http.StatusOK,
+			expectedTask:   fhir.TaskStatusAccepted,
+		},
+		{
+			name:   "reject",
+			params: reviewParams(taskReviewDecisionReject, to.Ptr("patient does not meet criteria")),
+			task:   task,
+			mock: func(client *mock.MockClient, _ *ehr.MockNotifier) {
+				client.EXPECT().UpdateWithContext(gomock.Any(), "Task/1", gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ string, updatedTask fhir.Task, result *fhir.Task, _ ...fhirclient.Option) error {
+						assert.Equal(t, "patient does not meet criteria", *updatedTask.StatusReason.Text)
+						*result = updatedTask
+						return nil
+					})
+			},
+			expectedStatus: http.StatusOK,
+			expectedTask:   fhir.TaskStatusRejected,
+		},
+		{
+			name:           "reject without reason",
+			params:         reviewParams(taskReviewDecisionReject, nil),
+			task:           task,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid decision",
+			params:         reviewParams("maybe", nil),
+			task:           task,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "Task does not await review",
+			params: reviewParams(taskReviewDecisionAccept, nil),
+			task: deep.AlterCopy(task, func(t *fhir.Task) {
+				t.BusinessStatus = nil
+			}),
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:   "not the Task owner",
+			params: reviewParams(taskReviewDecisionAccept, nil),
+			task: deep.AlterCopy(task, func(t *fhir.Task) {
+				t.Owner = &fhir.Reference{
+					Identifier: &auth.TestPrincipal3.Organization.Identifier[0],
+				}
+			}),
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			fhirClient := mock.NewMockClient(ctrl)
+			notifier := ehr.NewMockNotifier(ctrl)
+			fhirClient.EXPECT().Path().Return(cpsBaseURL).AnyTimes()
+			fhirClient.EXPECT().ReadWithContext(gomock.Any(), "Task/1", gomock.Any()).
+				DoAndReturn(func(_ context.Context, _ string, target interface{}, _ ...fhirclient.Option) error {
+					*target.(*fhir.Task) = deep.Copy(tt.task)
+					return nil
+				}).AnyTimes()
+			if tt.mock != nil {
+				tt.mock(fhirClient, notifier)
+			}
+			service := &Service{
+				profile:  profile.Test(),
+				notifier: notifier,
+				createFHIRClientForURL: func(_ context.Context, _ *url.URL) (fhirclient.Client, *http.Client, error) {
+					return fhirClient, nil, nil
+				},
+			}
+			requestBody, _ := json.Marshal(tt.params)
+			httpRequest := httptest.NewRequestWithContext(ctx, http.MethodPost, "/cpc/test/taskfiller/$review", bytes.NewReader(requestBody))
+			httpResponse := httptest.NewRecorder()
+
+			service.handleTaskReview(httpResponse, httpRequest)
+
+			require.Equal(t, tt.expectedStatus, httpResponse.Code)
+			if tt.expectedStatus == http.StatusOK {
+				var result fhir.Task
+				require.NoError(t, json.Unmarshal(httpResponse.Body.Bytes(), &result))
+				require.Equal(t, tt.expectedTask, result.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,358 @@
+package careplancontributor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/audit"
+	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/logging"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	"golang.org/x/time/rate"
+)
+
+// patientScopeSearchParams are the search parameters through which a query on the health data view endpoint is bound to a patient.
+var patientScopeSearchParams = []string{"patient", "patient:Patient", "patient:identifier", "subject", "subject:Patient", "subject:identifier"}
+
+// resultSearchParams are the search parameters that only affect how results are returned, which are always allowed.
+var resultSearchParams = []string{"_count", "_sort", "_summary", "_elements", "_total", "_format"}
+
+// healthDataViewRequest is a read or search on the health data view endpoint.
+type healthDataViewRequest struct {
+	resourceType string
+	// id is set when the request is a read
+	id string
+	// params contains the search parameters, from both the URL query and (for POST _search) the request body
+	params url.Values
+}
+
+func (r healthDataViewRequest) interaction() string {
+	if r.id != "" {
+		return "read"
+	}
+	return "search"
+}
+
+// parseHealthDataViewRequest parses the request. For POST _search the body is read and then restored, so it can still be proxied.
+func parseHealthDataViewRequest(request *http.Request) (*healthDataViewRequest, error) {
+	result := &healthDataViewRequest{
+		resourceType: request.PathValue("resourceType"),
+		id:           request.PathValue("id"),
+		params:       request.URL.Query(),
+	}
+	if request.Method == http.MethodPost && request.Body != nil {
+		body, err := io.ReadAll(request.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		request.Body = io.NopCloser(bytes.NewReader(body))
+		bodyParams, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, coolfhir.BadRequest("invalid search parameters in request body")
+		}
+		for key, values := range bodyParams {
+			result.params[key] = append(result.params[key], values...)
+		}
+	}
+	return result, nil
+}
+
+// authorizeHealthDataViewParameters checks the resource type and search parameters against the tenant's allow-list.
+// If the tenant has no allow-list configured, all resource types and search parameters are allowed, except in strict mode.
+func authorizeHealthDataViewParameters(properties tenants.HealthDataViewProperties, strictMode bool, request healthDataViewRequest) error {
+	if !properties.Configured() {
+		if strictMode {
+			return coolfhir.NewErrorWithCode("health data view: no resource types are allowed for this tenant", http.StatusForbidden)
+		}
+		return nil
+	}
+	allowedParams, ok := properties.SearchParameters(request.resourceType)
+	if !ok {
+		return coolfhir.NewErrorWithCode(fmt.Sprintf("health data view: resource type %s is not allowed", request.resourceType), http.StatusForbidden)
+	}
+	for param := range request.params {
+		if slices.Contains(patientScopeSearchParams, param) || slices.Contains(resultSearchParams, param) {
+			continue
+		}
+		if request.resourceType == "Patient" && (param == "_id" || param == "identifier") {
+			continue
+		}
+		// Modifiers (e.g. code:text) are allowed if the search parameter itself is allowed, chained parameters must be allowed explicitly.
+		name, _, _ := strings.Cut(param, ":")
+		if !slices.Contains(allowedParams, param) && !slices.Contains(allowedParams, name) {
+			return coolfhir.NewErrorWithCode(fmt.Sprintf("health data view: search parameter %s is not allowed for resource type %s", param, request.resourceType), http.StatusForbidden)
+		}
+	}
+	return nil
+}
+
+// patientScope binds health data view requests to the subject of the CarePlan the requester is a CareTeam member of.
+// References to Patient resources are resolved by reading the Patient from the EHR and comparing its identifiers to the CarePlan subject.
+type patientScope struct {
+	subject   fhir.Identifier
+	ehrClient fhirclient.Client
+	// patients caches whether a Patient (by ID) is the CarePlan subject
+	patients map[string]bool
+}
+
+func newPatientScope(carePlan *fhir.CarePlan, ehrClient fhirclient.Client) (*patientScope, error) {
+	if carePlan.Subject.Identifier == nil || carePlan.Subject.Identifier.System == nil || carePlan.Subject.Identifier.Value == nil {
+		return nil, errors.New("CarePlan subject must be identified by a logical identifier")
+	}
+	if ehrClient == nil {
+		return nil, errors.New("no EHR FHIR client configured for tenant")
+	}
+	return &patientScope{
+		subject:   *carePlan.Subject.Identifier,
+		ehrClient: ehrClient,
+		patients:  map[string]bool{},
+	}, nil
+}
+
+// authorizeSearch checks that the search is bound to the CarePlan subject: every patient search parameter must refer to it,
+// and there must be at least one.
+func (p *patientScope) authorizeSearch(ctx context.Context, request healthDataViewRequest) error {
+	scoped := false
+	for param, values := range request.params {
+		var check func(ctx context.Context, value string) (bool, error)
+		switch {
+		case param == "patient:identifier" || param == "subject:identifier" || (request.resourceType == "Patient" && param == "identifier"):
+			check = p.containsIdentifier
+		case slices.Contains(patientScopeSearchParams, param) || (request.resourceType == "Patient" && param == "_id"):
+			check = p.containsReference
+		default:
+			continue
+		}
+		for _, value := range values {
+			for _, item := range strings.Split(value, ",") {
+				ok, err := check(ctx, item)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return coolfhir.NewErrorWithCode(fmt.Sprintf("health data view: search parameter %s does not refer to the CarePlan subject", param), http.StatusForbidden)
+				}
+			}
+		}
+		scoped = true
+	}
+	if !scoped {
+		return coolfhir.NewErrorWithCode("health data view: search must be scoped to the CarePlan subject (e.g. using the patient or subject search parameter)", http.StatusForbidden)
+	}
+	return nil
+}
+
+// authorizeResource checks that the read resource is the CarePlan subject, or refers to it through its subject or patient element.
+func (p *patientScope) authorizeResource(ctx context.Context, resourceData []byte) error {
+	var resource struct {
+		Type       string            `json:"resourceType"`
+		ID         string            `json:"id"`
+		Identifier []fhir.Identifier `json:"identifier"`
+		Subject    *fhir.Reference   `json:"subject"`
+		Patient    *fhir.Reference   `json:"patient"`
+	}
+	if err := json.Unmarshal(resourceData, &resource); err != nil {
+		return fmt.Errorf("failed to parse resource from EHR: %w", err)
+	}
+	var ok bool
+	var err error
+	if resource.Type == "Patient" {
+		ok = coolfhir.HasIdentifier(p.subject, resource.Identifier...)
+	} else {
+		for _, reference := range []*fhir.Reference{resource.Subject, resource.Patient} {
+			if ok, err = p.containsPatientReference(ctx, reference); ok || err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return coolfhir.NewErrorWithCode("health data view: resource does not belong to the CarePlan subject", http.StatusForbidden)
+	}
+	return nil
+}
+
+func (p *patientScope) containsPatientReference(ctx context.Context, reference *fhir.Reference) (bool, error) {
+	if reference == nil {
+		return false, nil
+	}
+	if reference.Identifier != nil {
+		return coolfhir.IdentifierEquals(reference.Identifier, &p.subject), nil
+	}
+	if reference.Reference != nil {
+		return p.containsReference(ctx, *reference.Reference)
+	}
+	return false, nil
+}
+
+func (p *patientScope) containsIdentifier(_ context.Context, token string) (bool, error) {
+	identifier, err := coolfhir.TokenToIdentifier(token)
+	if err != nil {
+		return false, coolfhir.BadRequest("invalid identifier search parameter: %s", token)
+	}
+	return coolfhir.IdentifierEquals(identifier, &p.subject), nil
+}
+
+// containsReference resolves the given Patient reference (Patient/<id> or <id>) and checks whether it's the CarePlan subject.
+func (p *patientScope) containsReference(ctx context.Context, reference string) (bool, error) {
+	patientID := strings.TrimPrefix(reference, "Patient/")
+	if patientID == "" || strings.Contains(patientID, "/") {
+		return false, nil
+	}
+	if result, ok := p.patients[patientID]; ok {
+		return result, nil
+	}
+	var patient fhir.Patient
+	if err := p.ehrClient.ReadWithContext(ctx, "Patient/"+patientID, &patient); err != nil {
+		var outcomeError fhirclient.OperationOutcomeError
+		if errors.As(err, &outcomeError) && outcomeError.HttpStatusCode == http.StatusNotFound {
+			p.patients[patientID] = false
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to resolve Patient/%s: %w", patientID, err)
+	}
+	p.patients[patientID] = coolfhir.HasIdentifier(p.subject, patient.Identifier...)
+	return p.patients[patientID], nil
+}
+
+// organizationRateLimiter limits the number of requests per requesting organization.
+type organizationRateLimiter struct {
+	requestsPerMinute int
+	mux               sync.Mutex
+	limiters          map[string]*rate.Limiter
+}
+
+func newOrganizationRateLimiter(requestsPerMinute int) *organizationRateLimiter {
+	return &organizationRateLimiter{
+		requestsPerMinute: requestsPerMinute,
+		limiters:          map[string]*rate.Limiter{},
+	}
+}
+
+// allow returns whether the organization identified by the given key may perform another request.
+// A nil limiter or a limit of 0 allows all requests.
+func (l *organizationRateLimiter) allow(key string) bool {
+	if l == nil || l.requestsPerMinute <= 0 {
+		return true
+	}
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	limiter, ok := l.limiters[key]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Every(time.Minute/time.Duration(l.requestsPerMinute)), l.requestsPerMinute)
+		l.limiters[key] = limiter
+	}
+	return limiter.Allow()
+}
+
+// auditHealthDataViewAccess records an access (allowed or not) to the health data view endpoint.
+// It's logged, and recorded as AuditEvent in the tenant's EHR FHIR API.
+func (s Service) auditHealthDataViewAccess(ctx context.Context, request *http.Request, dataViewRequest *healthDataViewRequest, status int) {
+	principal, principalErr := auth.PrincipalFromContext(ctx)
+	var requester []string
+	if principalErr == nil {
+		for _, identifier := range principal.Organization.Identifier {
+			requester = append(requester, coolfhir.ToString(identifier))
+		}
+	}
+	attrs := []any{
+		slog.String("audit", "health_data_view"),
+		slog.Any("requester", requester),
+		slog.String("careplan", request.Header.Get(carePlanURLHeaderKey)),
+		slog.String(logging.FieldPath, request.URL.Path),
+		slog.Int("status", status),
+	}
+	if dataViewRequest != nil {
+		attrs = append(attrs,
+			slog.String("interaction", dataViewRequest.interaction()),
+			slog.String(logging.FieldResourceType, dataViewRequest.resourceType),
+			// Only log the names of the search parameters, since their values may contain patient identifiers
+			slog.Any("search_params", slices.Sorted(maps.Keys(dataViewRequest.params))),
+		)
+	}
+	slog.InfoContext(ctx, "Health data view access", attrs...)
+
+	// Without an authenticated requester or tenant, there's no access to audit
+	tenant, err := tenants.FromContext(ctx)
+	if err != nil || principalErr != nil || len(principal.Organization.Identifier) == 0 {
+		return
+	}
+	ehrClient := s.ehrFHIRClientByTenant[tenant.ID]
+	if ehrClient == nil {
+		return
+	}
+	identities, err := s.profile.Identities(ctx)
+	if err != nil || len(coolfhir.OrganizationIdentifiers(identities)) == 0 {
+		slog.ErrorContext(ctx, "Failed to record health data view AuditEvent: unknown local identity")
+		return
+	}
+	auditEvent := healthDataViewAuditEvent(coolfhir.OrganizationIdentifiers(identities)[0], principal, request.Header.Get(carePlanURLHeaderKey), dataViewRequest, status)
+	if err := ehrClient.CreateWithContext(ctx, auditEvent, new(fhir.AuditEvent)); err != nil {
+		slog.ErrorContext(ctx, "Failed to record health data view AuditEvent", slog.String(logging.FieldError, err.Error()))
+	}
+}
+
+// healthDataViewAuditEvent returns the AuditEvent for an access to the health data view endpoint by the principal,
+// referring to the queried resource (if known) and the CarePlan. The outcome reflects the response status.
+func healthDataViewAuditEvent(localIdentity fhir.Identifier, principal auth.Principal, carePlanURL string, dataViewRequest *healthDataViewRequest, status int) *fhir.AuditEvent {
+	action := fhir.AuditEventActionE
+	var resourceReference *fhir.Reference
+	if dataViewRequest != nil {
+		resourceReference = &fhir.Reference{Type: to.Ptr(dataViewRequest.resourceType)}
+		if dataViewRequest.interaction() == "read" {
+			action = fhir.AuditEventActionR
+			resourceReference.Reference = to.Ptr(dataViewRequest.resourceType + "/" + dataViewRequest.id)
+		}
+	}
+	result := audit.Event(localIdentity, action, resourceReference, &fhir.Reference{
+		Type:       to.Ptr("Organization"),
+		Identifier: &principal.Organization.Identifier[0],
+	}, principal.UserAuditAgent(), nil)
+	if resourceReference == nil {
+		result.Entity = nil
+	}
+	if dataViewRequest != nil && len(dataViewRequest.params) > 0 {
+		// Only record the names of the search parameters, like in the log
+		queryEntity := fhir.AuditEventEntity{
+			Type: &fhir.Coding{
+				System:  to.Ptr("http://terminology.hl7.org/CodeSystem/audit-entity-type"),
+				Code:    to.Ptr("2"),
+				Display: to.Ptr("Query Parameters"),
+			},
+		}
+		for _, name := range slices.Sorted(maps.Keys(dataViewRequest.params)) {
+			queryEntity.Detail = append(queryEntity.Detail, fhir.AuditEventEntityDetail{Type: name, ValueString: to.Ptr("")})
+		}
+		result.Entity = append(result.Entity, queryEntity)
+	}
+	if carePlanURL != "" {
+		result.Entity = append(result.Entity, fhir.AuditEventEntity{
+			What: &fhir.Reference{Type: to.Ptr("CarePlan"), Reference: to.Ptr(carePlanURL)},
+		})
+	}
+	switch {
+	case status >= 500:
+		result.Outcome = to.Ptr(fhir.AuditEventOutcome8)
+	case status >= 400:
+		result.Outcome = to.Ptr(fhir.AuditEventOutcome4)
+	}
+	return result
+}
@@ -0,0 +1,195 @@
+package careplancontributor
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/careplancontributor/mock"
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/must"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	"go.uber.org/mock/gomock"
+)
+
+func Test_authorizeHealthDataViewParameters(t *testing.T) {
+	properties := tenants.HealthDataViewProperties{
+		Resources: map[string][]string{
+			"observation": {"code", "date"},
+			"patient":     nil,
+		},
+	}
+	search := func(resourceType string, query string) healthDataViewRequest {
+		params, _ := url.ParseQuery(query)
+		return healthDataViewRequest{resourceType: resourceType, params: params}
+	}
+	t.Run("allowed", func(t *testing.T) {
+		assert.NoError(t, authorizeHealthDataViewParameters(properties, true, search("Observation", "patient=Patient/1&code=1234&_count=10")))
+	})
+	t.Run("allowed with modifier", func(t *testing.T) {
+		assert.NoError(t, authorizeHealthDataViewParameters(properties, true, search("Observation", "subject:identifier=a|b&code:text=bp")))
+	})
+	t.Run("Patient identifier is always allowed", func(t *testing.T) {
+		assert.NoError(t, authorizeHealthDataViewParameters(properties, true, search("Patient", "identifier=a|b")))
+	})
+	t.Run("resource type not allowed", func(t *testing.T) {
+		err := authorizeHealthDataViewParameters(properties, true, search("Condition", "patient=Patient/1"))
+		require.Error(t, err)
+		assert.Equal(t, http.StatusForbidden, err.(*coolfhir.ErrorWithCode).StatusCode)
+	})
+	t.Run("search parameter not allowed", func(t *testing.T) {
+		err := authorizeHealthDataViewParameters(properties, true, search("Observation", "patient=Patient/1&_revinclude=Provenance:target"))
+		require.EqualError(t, err, "health data view: search parameter _revinclude is not allowed for resource type Observation")
+	})
+	t.Run("chained search parameter not allowed", func(t *testing.T) {
+		err := authorizeHealthDataViewParameters(properties, true, search("Observation", "patient.name=Jansen"))
+		require.Error(t, err)
+	})
+	t.Run("not configured", func(t *testing.T) {
+		t.Run("strict mode", func(t *testing.T) {
+			err := authorizeHealthDataViewParameters(tenants.HealthDataViewProperties{}, true, search("Observation", "patient=Patient/1"))
+			require.Error(t, err)
+		})
+		t.Run("non-strict mode", func(t *testing.T) {
+			err := authorizeHealthDataViewParameters(tenants.HealthDataViewProperties{}, false, search("Observation", "patient=Patient/1"))
+			require.NoError(t, err)
+		})
+	})
+}
+
+func Test_patientScope(t *testing.T) {
+	bsn := fhir.Identifier{System: to.Ptr("http://fhir.nl/fhir/NamingSystem/bsn"), Value: to.Ptr("111222333")}
+	carePlan := &fhir.CarePlan{Subject: fhir.Reference{Identifier: &bsn}}
+	newScope := func(t *testing.T) *patientScope {
+		ctrl := gomock.NewController(t)
+		ehrClient := mock.NewMockClient(ctrl)
+		ehrClient.EXPECT().ReadWithContext(gomock.Any(), "Patient/1", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, target interface{}, _ ...fhirclient.Option) error {
+				*target.(*fhir.Patient) = fhir.Patient{Id: to.Ptr("1"), Identifier: []fhir.Identifier{bsn}}
+				return nil
+			}).MaxTimes(1)
+		ehrClient.EXPECT().ReadWithContext(gomock.Any(), "Patient/2", gomock.Any()).
+			Return(fhirclient.OperationOutcomeError{HttpStatusCode: http.StatusNotFound}).AnyTimes()
+		scope, err := newPatientScope(carePlan, ehrClient)
+		require.NoError(t, err)
+		return scope
+	}
+	search := func(resourceType string, query string) healthDataViewRequest {
+		params, _ := url.ParseQuery(query)
+		return healthDataViewRequest{resourceType: resourceType, params: params}
+	}
+	ctx := context.Background()
+
+	t.Run("search", func(t *testing.T) {
+		t.Run("by reference", func(t *testing.T) {
+			scope := newScope(t)
+			require.NoError(t, scope.authorizeSearch(ctx, search("Observation", "patient=Patient/1")))
+			require.NoError(t, scope.authorizeSearch(ctx, search("Observation", "subject=1")), "resolved Patient should be cached")
+		})
+		t.Run("by identifier", func(t *testing.T) {
+			require.NoError(t, newScope(t).authorizeSearch(ctx, search("Observation", "subject:identifier=http://fhir.nl/fhir/NamingSystem/bsn|111222333")))
+			require.NoError(t, newScope(t).authorizeSearch(ctx, search("Patient", "identifier=http://fhir.nl/fhir/NamingSystem/bsn|111222333")))
+		})
+		t.Run("other patient", func(t *testing.T) {
+			require.Error(t, newScope(t).authorizeSearch(ctx, search("Observation", "patient=Patient/2")))
+			require.Error(t, newScope(t).authorizeSearch(ctx, search("Observation", "patient=Patient/1,Patient/2")))
+			require.Error(t, newScope(t).authorizeSearch(ctx, search("Patient", "identifier=http://fhir.nl/fhir/NamingSystem/bsn|999")))
+		})
+		t.Run("not scoped", func(t *testing.T) {
+			require.Error(t, newScope(t).authorizeSearch(ctx, search("Observation", "code=1234")))
+		})
+	})
+	t.Run("read", func(t *testing.T) {
+		t.Run("Patient", func(t *testing.T) {
+			require.NoError(t, newScope(t).authorizeResource(ctx, must.MarshalJSON(fhir.Patient{Identifier: []fhir.Identifier{bsn}})))
+			require.Error(t, newScope(t).authorizeResource(ctx, must.MarshalJSON(fhir.Patient{})))
+		})
+		t.Run("resource with subject", func(t *testing.T) {
+			observation := fhir.Observation{Subject: &fhir.Reference{Reference: to.Ptr("Patient/1")}}
+			require.NoError(t, newScope(t).authorizeResource(ctx, must.MarshalJSON(observation)))
+			observation.Subject.Reference = to.Ptr("Patient/2")
+			require.Error(t, newScope(t).authorizeResource(ctx, must.MarshalJSON(observation)))
+		})
+		t.Run("resource with patient", func(t *testing.T) {
+			allergy := fhir.AllergyIntolerance{Patient: fhir.Reference{Identifier: &bsn}}
+			require.NoError(t, newScope(t).authorizeResource(ctx, must.MarshalJSON(allergy)))
+		})
+		t.Run("resource not in patient compartment", func(t *testing.T) {
+			require.Error(t, newScope(t).authorizeResource(ctx, must.MarshalJSON(fhir.Practitioner{})))
+		})
+	})
+	t.Run("CarePlan subject without identifier", func(t *testing.T) {
+		_, err := newPatientScope(&fhir.CarePlan{Subject: fhir.Reference{Reference: to.Ptr("Patient/1")}}, mock.NewMockClient(gomock.NewController(t)))
+		require.Error(t, err)
+	})
+}
+
+func Test_organizationRateLimiter(t *testing.T) {
+	t.Run("limited per organization", func(t *testing.T) {
+		limiter := newOrganizationRateLimiter(2)
+		assert.True(t, limiter.allow("org1"))
+		assert.True(t, limiter.allow("org1"))
+		assert.False(t, limiter.allow("org1"))
+		assert.True(t, limiter.allow("org2"))
+	})
+	t.Run("disabled", func(t *testing.T) {
+		limiter := newOrganizationRateLimiter(0)
+		for i := 0; i < 10; i++ {
+			assert.True(t, limiter.allow("org1"))
+		}
+		var nilLimiter *organizationRateLimiter
+		assert.True(t, nilLimiter.allow("org1"))
+	})
+}
+
+func Test_healthDataViewAuditEvent(t *testing.T) {
+	localIdentity := fhir.Identifier{System: to.Ptr(coolfhir.URANamingSystem), Value: to.Ptr("1")}
+	principal := *auth.TestPrincipal2
+	carePlanURL := "http://example.com/cps/CarePlan/1"
+
+	t.Run("read", func(t *testing.T) {
+		dataViewRequest := &healthDataViewRequest{resourceType: "Condition", id: "1"}
+
+		auditEvent := healthDataViewAuditEvent(localIdentity, principal, carePlanURL, dataViewRequest, http.StatusOK)
+
+		require.Equal(t, fhir.AuditEventActionR, *auditEvent.Action)
+		require.Equal(t, fhir.AuditEventOutcome0, *auditEvent.Outcome)
+		require.Equal(t, principal.Organization.Identifier[0], *auditEvent.Agent[0].Who.Identifier)
+		require.Len(t, auditEvent.Entity, 2)
+		require.Equal(t, "Condition/1", *auditEvent.Entity[0].What.Reference)
+		require.Equal(t, carePlanURL, *auditEvent.Entity[1].What.Reference)
+	})
+	t.Run("search", func(t *testing.T) {
+		dataViewRequest := &healthDataViewRequest{resourceType: "Observation", params: url.Values{"subject": {"Patient/1"}, "code": {"1234"}}}
+
+		auditEvent := healthDataViewAuditEvent(localIdentity, principal, carePlanURL, dataViewRequest, http.StatusOK)
+
+		require.Equal(t, fhir.AuditEventActionE, *auditEvent.Action)
+		require.Len(t, auditEvent.Entity, 3)
+		require.Equal(t, "Observation", *auditEvent.Entity[0].What.Type)
+		require.Nil(t, auditEvent.Entity[0].What.Reference)
+		require.Len(t, auditEvent.Entity[1].Detail, 2)
+		require.Equal(t, "code", auditEvent.Entity[1].Detail[0].Type)
+		require.Equal(t, "", *auditEvent.Entity[1].Detail[0].ValueString, "search parameter values must not be recorded")
+		require.Equal(t, carePlanURL, *auditEvent.Entity[2].What.Reference)
+	})
+	t.Run("denied", func(t *testing.T) {
+		auditEvent := healthDataViewAuditEvent(localIdentity, principal, carePlanURL, nil, http.StatusForbidden)
+
+		require.Equal(t, fhir.AuditEventOutcome4, *auditEvent.Outcome)
+		require.Len(t, auditEvent.Entity, 1)
+		require.Equal(t, carePlanURL, *auditEvent.Entity[0].What.Reference)
+	})
+	t.Run("failed", func(t *testing.T) {
+		auditEvent := healthDataViewAuditEvent(localIdentity, principal, carePlanURL, nil, http.StatusBadGateway)
+
+		require.Equal(t, fhir.AuditEventOutcome8, *auditEvent.Outcome)
+	})
+}
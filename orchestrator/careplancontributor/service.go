@@ -2,6 +2,7 @@
 package careplancontributor
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -35,6 +36,7 @@ import (
 	"go.opentelemetry.io/otel/trace"
 
 	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/careplancontributor/applaunch/backend"
 	"github.com/SanteonNL/orca/orchestrator/careplancontributor/applaunch/clients"
 	"github.com/SanteonNL/orca/orchestrator/careplancontributor/ehr"
 	"github.com/SanteonNL/orca/orchestrator/careplancontributor/taskengine"
@@ -42,6 +44,7 @@ import (
 	events "github.com/SanteonNL/orca/orchestrator/events"
 	"github.com/SanteonNL/orca/orchestrator/globals"
 	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/consent"
 	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
 	"github.com/SanteonNL/orca/orchestrator/lib/pubsub"
 	"github.com/SanteonNL/orca/orchestrator/lib/to"
@@ -94,6 +97,15 @@ func New(
 	if config.TaskFiller.QuestionnaireFHIR.BaseURL == "" {
 		// Use embedded workflow provider
 		memoryWorkflowProvider := &taskengine.MemoryWorkflowProvider{}
+		if len(config.TaskFiller.TerminologyURLs) > 0 {
+			memoryWorkflowProvider.Terminology = &taskengine.Terminology{}
+			for _, bundleUrl := range config.TaskFiller.TerminologyURLs {
+				slog.InfoContext(ctx, "Loading Task Filler terminology resources from URL", slog.String(logging.FieldUrl, bundleUrl))
+				if err := memoryWorkflowProvider.Terminology.LoadBundle(ctx, bundleUrl); err != nil {
+					return nil, fmt.Errorf("failed to load Task Filler terminology resources (url=%s): %w", bundleUrl, err)
+				}
+			}
+		}
 		for _, bundleUrl := range config.TaskFiller.QuestionnaireSyncURLs {
 			slog.InfoContext(ctx, "Loading Task Filler Questionnaires/HealthcareService resources from URL", slog.String(logging.FieldUrl, bundleUrl))
 			if err := memoryWorkflowProvider.LoadBundle(ctx, bundleUrl); err != nil {
@@ -103,6 +115,9 @@ func New(
 		workflowProvider = memoryWorkflowProvider
 	} else {
 		// Use FHIR-based workflow provider
+		if len(config.TaskFiller.TerminologyURLs) > 0 {
+			slog.WarnContext(ctx, "Task Filler terminology is only used when Questionnaires and HealthcareServices are kept in-memory, ignoring terminology URLs")
+		}
 		_, questionnaireFhirClient, err := coolfhir.NewAuthRoundTripper(config.TaskFiller.QuestionnaireFHIR, coolfhir.Config())
 		if err != nil {
 			return nil, err
@@ -145,6 +160,7 @@ func New(
 		ehrFHIRClientByTenant:         make(map[string]fhirclient.Client),
 		workflows:                     workflowProvider,
 		healthdataviewEndpointEnabled: config.HealthDataViewEndpointEnabled,
+		healthDataViewRateLimiter:     newOrganizationRateLimiter(config.HealthDataViewRateLimit),
 		eventManager:                  eventManager,
 		httpHandler:                   httpHandler,
 	}
@@ -163,21 +179,55 @@ func New(
 	}
 
 	result.createFHIRClientForURL = result.defaultCreateFHIRClientForURL
-	if config.TaskFiller.TaskAcceptedBundleEndpoint != "" {
-		result.notifier, err = ehr.NewNotifier(eventManager, tenants, config.TaskFiller.TaskAcceptedBundleEndpoint, result.createFHIRClientForURL)
+	pubsub.DefaultSubscribers.FhirSubscriptionNotify = result.handleNotification
+
+	if err = result.initializeAppLaunches(sessionManager, globals.StrictMode); err != nil {
+		return nil, fmt.Errorf("failed to initialize AppLaunch services: %w", err)
+	}
+	// The EHR notifier is created after the app launches are initialized, since it uses their EHR FHIR clients
+	if NotifierEnabled(config, tenants) {
+		result.notifier, err = ehr.NewNotifier(eventManager, tenants, config.TaskFiller.TaskAcceptedBundleEndpoint, result.createFHIRClientForURL, result.ehrFHIRClientByTenant)
 		if err != nil {
 			return nil, fmt.Errorf("TaskEngine: failed to create EHR notifier: %w", err)
 		}
 		slog.InfoContext(ctx, "TaskEngine: created EHR notifier", slog.String(logging.FieldEndpoint, config.TaskFiller.TaskAcceptedBundleEndpoint))
 	}
-	pubsub.DefaultSubscribers.FhirSubscriptionNotify = result.handleNotification
-
-	if err = result.initializeAppLaunches(sessionManager, globals.StrictMode); err != nil {
-		return nil, fmt.Errorf("failed to initialize AppLaunch services: %w", err)
+	// Consent checkers are created after the app launches are initialized, since Consents might be read from the EHR FHIR API
+	if err = result.initializeConsentCheckers(); err != nil {
+		return nil, err
 	}
 	return result, nil
 }
 
+func (s *Service) initializeConsentCheckers() error {
+	s.consentCheckerByTenant = make(map[string]consent.Checker)
+	for _, tenant := range s.tenants {
+		checker, err := consent.New(tenant.Consent, func(source consent.Source) (fhirclient.Client, error) {
+			if source == consent.SourceEHR {
+				if fhirClient := s.ehrFHIRClientByTenant[tenant.ID]; fhirClient != nil {
+					return fhirClient, nil
+				}
+				return nil, errors.New("EHR FHIR API is not configured")
+			}
+			if tenant.CPS.FHIR.BaseURL == "" {
+				return nil, errors.New("CPS FHIR API is not configured")
+			}
+			_, fhirClient, err := coolfhir.NewAuthRoundTripper(tenant.CPS.FHIR, coolfhir.Config())
+			if err != nil {
+				return nil, err
+			}
+			return coolfhir.NewTracedFHIRClient(fhirClient, tracer), nil
+		})
+		if err != nil {
+			return fmt.Errorf("tenant %s: %w", tenant.ID, err)
+		}
+		if checker != nil {
+			s.consentCheckerByTenant[tenant.ID] = checker
+		}
+	}
+	return nil
+}
+
 type Service struct {
 	config                        Config
 	tenants                       tenants.Config
@@ -188,6 +238,7 @@ type Service struct {
 	ehrFHIRClientByTenant         map[string]fhirclient.Client
 	workflows                     taskengine.WorkflowProvider
 	healthdataviewEndpointEnabled bool
+	healthDataViewRateLimiter     *organizationRateLimiter
 	notifier                      ehr.Notifier
 	eventManager                  events.Manager
 	createFHIRClientForURL        func(ctx context.Context, fhirBaseURL *url.URL) (fhirclient.Client, *http.Client, error)
@@ -196,6 +247,8 @@ type Service struct {
 	tokenClient                   *rp.Client
 	appLaunches                   []applaunch.Service
 	cpsEnabled                    bool
+	// consentCheckerByTenant contains the consent checker of each tenant that has consent checks enabled.
+	consentCheckerByTenant map[string]consent.Checker
 }
 
 func (s *Service) RegisterHandlers(mux *http.ServeMux) {
@@ -335,6 +388,17 @@ func (s *Service) RegisterHandlers(mux *http.ServeMux) {
 				s.withUserAuth,
 			),
 		},
+		// This endpoint is used by the EHR and ORCA Frontend to accept or reject a Task that awaits review by a care professional.
+		{
+			Method:  "POST",
+			Path:    basePathWithTenant + "/taskfiller/$review",
+			Handler: s.handleTaskReview,
+			Middleware: httpserv.Chain(
+				otel.HandlerWithTracing(tracer, "TaskReview"),
+				s.tenants.HttpHandler,
+				s.withUserAuth,
+			),
+		},
 		{
 			Method:  "GET",
 			Path:    basePath + "/context",
@@ -386,6 +450,8 @@ func (s *Service) initializeAppLaunches(sessionManager *user.SessionManager[sess
 		service := demo.New(sessionManager, s.config.AppLaunch.Demo, s.tenants, s.orcaPublicURL, frontendUrl, s.profile)
 		s.appLaunches = append(s.appLaunches, service)
 	}
+	// Tenants can configure system-level access to their EHR FHIR API, independent of the app launch that's used.
+	s.appLaunches = append(s.appLaunches, backend.New(s.tenants, s.orcaPublicURL))
 	if s.config.AppLaunch.ZorgPlatform.Enabled {
 		service, err := zorgplatform.New(sessionManager, s.config.AppLaunch.ZorgPlatform, s.tenants, s.orcaPublicURL.String(), frontendUrl, s.profile)
 		if err != nil {
@@ -516,14 +582,25 @@ func (s *Service) handleFHIRSearchEndpoints(httpResponse http.ResponseWriter, ht
 		return
 	}
 
-	// Find the URA identifier
+	// Find the URA identifier, which might be configured as equivalent to one of the organization's identifiers (e.g. AGB).
 	var organizationUra string
 	for _, identifier := range principal.Organization.Identifier {
-		if identifier.System != nil && *identifier.System == coolfhir.URANamingSystem && identifier.Value != nil {
-			organizationUra = *identifier.Value
+		if ura := coolfhir.EquivalentIdentifierOfSystem(identifier, coolfhir.URANamingSystem); ura != nil {
+			organizationUra = *ura.Value
 			break
 		}
 	}
+	if organizationUra == "" {
+		// Other identifiers can't be used in place of the URA, since the applications identify the organization by URA
+		for _, appConfig := range s.config.AppLaunch.External {
+			if strings.Contains(appConfig.URL, "{organization}") {
+				coolfhir.WriteOperationOutcomeFromError(httpRequest.Context(), coolfhir.BadRequest(
+					"requesting organization has no URA (nor an identifier configured as equivalent to a URA), which is required by application %s", appConfig.Name,
+				), fmt.Sprintf("CarePlanContributor/%s %s", httpRequest.Method, httpRequest.URL.Path), httpResponse)
+				return
+			}
+		}
+	}
 
 	bundle := coolfhir.BundleBuilder{}
 	bundle.Type = fhir.BundleTypeSearchset
@@ -564,10 +641,9 @@ func (s *Service) handleFHIRSearchEndpoints(httpResponse http.ResponseWriter, ht
 }
 
 func (s *Service) handleFHIRProxyGetOrSearch(writer http.ResponseWriter, request *http.Request) {
-	//TODO: Make this endpoint more secure, currently it is only allowed when strict mode is disabled
-	if !s.healthdataviewEndpointEnabled || globals.StrictMode {
+	if !s.healthdataviewEndpointEnabled {
 		coolfhir.WriteOperationOutcomeFromError(request.Context(), &coolfhir.ErrorWithCode{
-			Message:    "health data view proxy endpoint is disabled or strict mode is enabled",
+			Message:    "health data view proxy endpoint is disabled",
 			StatusCode: http.StatusMethodNotAllowed,
 		}, fmt.Sprintf("CarePlanContributor/%s %s", request.Method, request.URL.Path), writer)
 		return
@@ -600,7 +676,7 @@ func (s Service) handleProxyAppRequestToEHR(writer http.ResponseWriter, request
 		trace.WithSpanKind(trace.SpanKindServer),
 		trace.WithAttributes(
 			attribute.String(otel.HTTPMethod, request.Method),
-			attribute.String(otel.HTTPURL, request.URL.String()),
+			attribute.String(otel.HTTPURL, otel.RedactURL(request.URL)),
 		),
 	)
 	defer span.End()
@@ -633,18 +709,36 @@ func (s Service) handleProxyAppRequestToEHR(writer http.ResponseWriter, request
 
 // handleProxyExternalRequestToEHR handles a request from an external SCP-node (e.g. CarePlanContributor), forwarding it to the local EHR's FHIR API.
 // This is typically used by remote parties to retrieve patient data from the local EHR.
-func (s Service) handleProxyExternalRequestToEHR(writer http.ResponseWriter, request *http.Request) error {
+// The request must be for a resource type and search parameters allowed for the tenant, must be scoped to the subject of the CarePlan
+// the requester is a CareTeam member of, and is subject to a rate limit per requesting organization. Every access is audited.
+func (s Service) handleProxyExternalRequestToEHR(writer http.ResponseWriter, request *http.Request) (err error) {
 	ctx, span := tracer.Start(
 		request.Context(),
 		debug.GetFullCallerName(),
 		trace.WithSpanKind(trace.SpanKindServer),
 		trace.WithAttributes(
 			attribute.String(otel.HTTPMethod, request.Method),
-			attribute.String(otel.HTTPURL, request.URL.String()),
+			attribute.String(otel.HTTPURL, otel.RedactURL(request.URL)),
 		),
 	)
 	defer span.End()
 
+	var dataViewRequest *healthDataViewRequest
+	responseStatus := http.StatusOK
+	defer func() {
+		if err != nil {
+			responseStatus = http.StatusInternalServerError
+			var errorWithCode *coolfhir.ErrorWithCode
+			var outcomeError fhirclient.OperationOutcomeError
+			if errors.As(err, &errorWithCode) {
+				responseStatus = errorWithCode.StatusCode
+			} else if errors.As(err, &outcomeError) {
+				responseStatus = outcomeError.HttpStatusCode
+			}
+		}
+		s.auditHealthDataViewAccess(ctx, request, dataViewRequest, responseStatus)
+	}()
+
 	tenant, err := tenants.FromContext(ctx)
 	if err != nil {
 		return err
@@ -656,17 +750,83 @@ func (s Service) handleProxyExternalRequestToEHR(writer http.ResponseWriter, req
 	}
 
 	slog.DebugContext(ctx, "Handling external FHIR API request")
-	_, err = s.authorizeScpMember(request.WithContext(ctx))
+	validationResult, err := s.authorizeScpMember(request.WithContext(ctx))
 	if err != nil {
 		return otel.Error(span, err)
 	}
+	principal, err := auth.PrincipalFromContext(ctx)
+	if err != nil {
+		return otel.Error(span, err)
+	}
+	if err = s.enforceConsent(ctx, tenant.ID, validationResult.carePlan, principal); err != nil {
+		return otel.Error(span, err)
+	}
+	if !s.healthDataViewRateLimiter.allow(tenant.ID + "/" + coolfhir.ToString(principal.Organization.Identifier)) {
+		return otel.Error(span, coolfhir.NewErrorWithCode("health data view: rate limit exceeded", http.StatusTooManyRequests))
+	}
+	dataViewRequest, err = parseHealthDataViewRequest(request)
+	if err != nil {
+		return otel.Error(span, err)
+	}
+	if err = authorizeHealthDataViewParameters(tenant.HealthDataView, globals.StrictMode, *dataViewRequest); err != nil {
+		return otel.Error(span, err)
+	}
+	scope, err := newPatientScope(validationResult.carePlan, s.ehrFHIRClientByTenant[tenant.ID])
+	if err != nil {
+		return otel.Error(span, coolfhir.NewErrorWithCode("health data view: "+err.Error(), http.StatusForbidden))
+	}
+	if dataViewRequest.interaction() == "search" {
+		if err = scope.authorizeSearch(ctx, *dataViewRequest); err != nil {
+			return otel.Error(span, err)
+		}
+	}
 
-	ehrProxy.ServeHTTP(writer, request.WithContext(ctx))
+	// Buffer the response, so that a read resource can be checked to belong to the CarePlan subject before it's returned
+	response := &memoryResponseWriter{
+		headers: make(http.Header),
+		body:    new(bytes.Buffer),
+	}
+	ehrProxy.ServeHTTP(response, request.WithContext(ctx))
+	if response.status == 0 {
+		response.status = http.StatusOK
+	}
+	if dataViewRequest.interaction() == "read" && response.status == http.StatusOK {
+		if err = scope.authorizeResource(ctx, response.body.Bytes()); err != nil {
+			return otel.Error(span, err)
+		}
+	}
+	for key, values := range response.headers {
+		writer.Header()[key] = values
+	}
+	writer.WriteHeader(response.status)
+	_, _ = writer.Write(response.body.Bytes())
+	responseStatus = response.status
 
 	span.SetStatus(codes.Ok, "")
 	return nil
 }
 
+// enforceConsent checks whether the subject of the CarePlan consented to sharing EHR data with the principal's care organization.
+func (s Service) enforceConsent(ctx context.Context, tenantID string, carePlan *fhir.CarePlan, principal auth.Principal) error {
+	checker := s.consentCheckerByTenant[tenantID]
+	if checker == nil {
+		return nil
+	}
+	if !coolfhir.IsLogicalIdentifier(carePlan.Subject.Identifier) {
+		return coolfhir.NewErrorWithCode("consent can't be checked: CarePlan subject has no identifier", http.StatusForbidden)
+	}
+	identities, err := s.profile.Identities(ctx)
+	if err != nil {
+		return err
+	}
+	// Denials are recorded as AuditEvent in the EHR's FHIR API, like accesses to the health data view
+	return consent.Enforce(ctx, checker, s.ehrFHIRClientByTenant[tenantID], consent.Request{
+		Patient:   *carePlan.Subject.Identifier,
+		Custodian: coolfhir.OrganizationIdentifiers(identities),
+		Actor:     principal.Organization.Identifier,
+	})
+}
+
 // TODO: Fix the logic in this method, it doesn't work as intended
 func (s Service) authorizeScpMember(request *http.Request) (*ScpValidationResult, error) {
 	// Authorize requester before proxying FHIR request
@@ -740,12 +900,37 @@ func (s Service) authorizeScpMember(request *http.Request) (*ScpValidationResult
 	if !isValid {
 		return nil, coolfhir.NewErrorWithCode("requester does not have access to resource", http.StatusForbidden)
 	}
+	if err = resolvePseudonymizedSubject(request.Context(), cpsFHIRClient, &carePlan); err != nil {
+		return nil, err
+	}
 	return &ScpValidationResult{
 		carePlan:  &carePlan,
 		careTeams: &[]fhir.CareTeam{*careTeam},
 	}, nil
 }
 
+// resolvePseudonymizedSubject replaces the pseudonymous patient identifier in the subject of the CarePlan with the BSN of the
+// Patient it references (read from the CPS), since consent and EHR data are registered for the actual patient.
+func resolvePseudonymizedSubject(ctx context.Context, cpsFHIRClient fhirclient.Client, carePlan *fhir.CarePlan) error {
+	subject := carePlan.Subject
+	if subject.Identifier == nil || to.Empty(subject.Identifier.System) != coolfhir.PatientPseudonymNamingSystem {
+		return nil
+	}
+	if subject.Reference == nil {
+		return coolfhir.NewErrorWithCode("pseudonymized CarePlan subject doesn't reference a Patient", http.StatusForbidden)
+	}
+	var patient fhir.Patient
+	if err := cpsFHIRClient.ReadWithContext(ctx, *subject.Reference, &patient); err != nil {
+		return fmt.Errorf("failed to read Patient of pseudonymized CarePlan subject: %w", err)
+	}
+	bsn := coolfhir.FilterFirstIdentifier(&patient.Identifier, coolfhir.BSNNamingSystem)
+	if bsn == nil || bsn.Value == nil {
+		return coolfhir.NewErrorWithCode("Patient of pseudonymized CarePlan subject has no BSN", http.StatusForbidden)
+	}
+	carePlan.Subject.Identifier = bsn
+	return nil
+}
+
 func (s Service) handleGetContext(response http.ResponseWriter, _ *http.Request, sessionData *session.Data) {
 	contextData := struct {
 		Patient          string  `json:"patient"`
@@ -791,6 +976,18 @@ func (s Service) getAndValidateUserSession(request *http.Request) (*session.Data
 	return nil, nil
 }
 
+// userFromSession returns the user (Practitioner and PractitionerRole) from the app launch context of the user session, or nil if it doesn't contain a Practitioner.
+func userFromSession(sessionData *session.Data) *auth.User {
+	practitioner := session.Get[fhir.Practitioner](sessionData)
+	if practitioner == nil {
+		return nil
+	}
+	return &auth.User{
+		Practitioner:     *practitioner,
+		PractitionerRole: session.Get[fhir.PractitionerRole](sessionData),
+	}
+}
+
 func (s Service) withUserAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(response http.ResponseWriter, request *http.Request) {
 		ctx, span := tracer.Start(
@@ -814,6 +1011,10 @@ func (s Service) withUserAuth(next http.HandlerFunc) http.HandlerFunc {
 			// Valid user session found, proceed
 			span.SetAttributes(attribute.String(otel.AuthNMethod, otel.AuthNMethodUserSession))
 			span.SetAttributes(attribute.String(otel.AuthNOutcome, otel.AuthNOutcomeOK))
+			if user := userFromSession(sessionData); user != nil {
+				// Propagate the user, so it's known in requests that are made on behalf of the user (e.g. to the CarePlanService)
+				request = request.WithContext(auth.WithUser(request.Context(), *user))
+			}
 			next(response, request)
 			return
 		}
@@ -963,6 +1164,10 @@ func (s Service) handleNotification(ctx context.Context, resource any) error {
 		}
 
 		task.Meta.Source = &resourceUrl
+		if err := s.notifyTaskStatusChanged(ctx, fhirBaseURL, &task); err != nil {
+			// Fail handling the notification, so the transition isn't lost: the notification is retried by the CarePlanService
+			return otel.Error(span, fmt.Errorf("failed to notify EHR of Task status change: %w", err))
+		}
 		err = s.handleTaskNotification(ctx, fhirClient, &task)
 		rejection := new(TaskRejection)
 		if errors.As(err, &rejection) || errors.As(err, rejection) {
@@ -978,6 +1183,10 @@ func (s Service) handleNotification(ctx context.Context, resource any) error {
 		} else if err != nil {
 			return otel.Error(span, err)
 		}
+	case "Communication":
+		if err := s.handleCommunicationNotification(ctx, fhirClient, fhirBaseURL, resourceUrl); err != nil {
+			return otel.Error(span, err)
+		}
 	default:
 		slog.DebugContext(ctx, "No handler for notification of type, ignoring", slog.String(logging.FieldResourceType, *focusReference.Type))
 	}
@@ -987,6 +1196,38 @@ func (s Service) handleNotification(ctx context.Context, resource any) error {
 	return nil
 }
 
+// notifyTaskStatusChanged notifies the EHR of the Task's current status, if the local care organization is requester or owner of the Task.
+func (s Service) notifyTaskStatusChanged(ctx context.Context, fhirBaseURL *url.URL, task *fhir.Task) error {
+	if s.notifier == nil {
+		return nil
+	}
+	identities, err := s.profile.Identities(ctx)
+	if err != nil {
+		return err
+	}
+	isOwner, isRequester := coolfhir.IsIdentifierTaskOwnerAndRequester(task, coolfhir.OrganizationIdentifiers(identities))
+	if !isOwner && !isRequester {
+		return nil
+	}
+	return s.notifier.NotifyTaskStatusChanged(ctx, fhirBaseURL.String(), task)
+}
+
+// NotifierEnabled returns whether the EHR is notified of Tasks, which is the case if the Task Filler's TaskAcceptedBundleEndpoint is set,
+// or if any tenant has configured the EHR to be notified of Task status changes, has its own EHR endpoint,
+// has Task data written into its EHR's FHIR API, or has an EHR endpoint for received Communications.
+func NotifierEnabled(config Config, tenantsConfig tenants.Config) bool {
+	if config.TaskFiller.TaskAcceptedBundleEndpoint != "" {
+		return true
+	}
+	for _, tenant := range tenantsConfig {
+		if len(tenant.TaskNotification.Statuses) > 0 || tenant.TaskNotification.DefaultEndpoint != "" ||
+			tenant.TaskNotification.Delivery == tenants.TaskDeliveryFHIR || tenant.CommunicationNotification.Endpoint != "" {
+			return true
+		}
+	}
+	return false
+}
+
 func (s Service) rejectTask(ctx context.Context, client fhirclient.Client, task fhir.Task, rejection TaskRejection) error {
 	slog.InfoContext(
 		ctx,
@@ -1183,6 +1424,10 @@ func (s *Service) handleFHIRImportOperation(httpResponse http.ResponseWriter, ht
 }
 
 func (s *Service) handleFHIRExternalProxy(httpResponse http.ResponseWriter, httpRequest *http.Request) {
+	if isAggregatedSearchRequest(httpRequest) {
+		s.handleAggregatedSearch(httpResponse, httpRequest)
+		return
+	}
 	// TODO: Extract relevant data from the bearer JWT
 	fhirBaseURL, httpClient, err := s.createFHIRClientForExternalRequest(httpRequest.Context(), httpRequest)
 	if err != nil {
@@ -12,6 +12,7 @@ import (
 	"github.com/SanteonNL/orca/orchestrator/careplancontributor/applaunch/clients"
 	"github.com/SanteonNL/orca/orchestrator/careplancontributor/applaunch/external"
 	"github.com/SanteonNL/orca/orchestrator/careplancontributor/applaunch/session"
+	"github.com/SanteonNL/orca/orchestrator/careplancontributor/ehr"
 	"github.com/SanteonNL/orca/orchestrator/careplancontributor/mock"
 	"github.com/SanteonNL/orca/orchestrator/careplancontributor/oidc/rp"
 	"github.com/SanteonNL/orca/orchestrator/careplancontributor/taskengine"
@@ -20,6 +21,7 @@ import (
 	"github.com/SanteonNL/orca/orchestrator/events"
 	"github.com/SanteonNL/orca/orchestrator/globals"
 	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/consent"
 	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
 	"github.com/SanteonNL/orca/orchestrator/lib/must"
 	"github.com/SanteonNL/orca/orchestrator/lib/test"
@@ -37,7 +39,9 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -65,6 +69,8 @@ func TestService_Proxy_Get_And_Search(t *testing.T) {
 		// Default is "/cpc/fhir/Patient/1"
 		url          *string
 		allowCaching bool
+		// Set to check patient consent
+		consentChecker consent.Checker
 	}{
 		{
 			name:                          "Fails: No healthDataViewEndpointEnabled flag",
@@ -127,7 +133,7 @@ func TestService_Proxy_Get_And_Search(t *testing.T) {
 			readBodyReturnFile: "./testdata/careplan-not-found.json",
 			xSCPContext:        "CarePlan/not-exists",
 			method:             to.Ptr("POST"),
-			url:                to.Ptr("/cpc/test/fhir/Patient/_search"),
+			url:                to.Ptr("/cpc/test/fhir/Patient/_search?identifier=http://fhir.nl/fhir/NamingSystem/bsn%7C111222333"),
 			expectedJSON:       `{"issue":[{"severity":"error","code":"processing","diagnostics":"CarePlanContributor/POST /cpc/test/fhir/Patient/_search failed: Not Found"}],"resourceType":"OperationOutcome"}`,
 		},
 		{
@@ -145,7 +151,7 @@ func TestService_Proxy_Get_And_Search(t *testing.T) {
 			readStatusReturn:   http.StatusOK,
 			xSCPContext:        "CarePlan/cps-careplan-01",
 			method:             to.Ptr("POST"),
-			url:                to.Ptr("/cpc/test/fhir/Patient/_search"),
+			url:                to.Ptr("/cpc/test/fhir/Patient/_search?identifier=http://fhir.nl/fhir/NamingSystem/bsn%7C111222333"),
 			expectedJSON:       `{"issue":[{"severity":"error","code":"processing","diagnostics":"CarePlanContributor/POST /cpc/test/fhir/Patient/_search failed: Internal Server Error"}],"resourceType":"OperationOutcome"}`,
 		},
 		{
@@ -165,7 +171,7 @@ func TestService_Proxy_Get_And_Search(t *testing.T) {
 			readStatusReturn:   http.StatusOK,
 			xSCPContext:        "CarePlan/cps-careplan-01",
 			method:             to.Ptr("POST"),
-			url:                to.Ptr("/cpc/test/fhir/Patient/_search"),
+			url:                to.Ptr("/cpc/test/fhir/Patient/_search?identifier=http://fhir.nl/fhir/NamingSystem/bsn%7C111222333"),
 			expectedJSON:       `{"issue":[{"severity":"error","code":"processing","diagnostics":"CarePlanContributor/POST /cpc/test/fhir/Patient/_search failed: Forbidden"}],"resourceType":"OperationOutcome"}`,
 		},
 		{
@@ -186,7 +192,7 @@ func TestService_Proxy_Get_And_Search(t *testing.T) {
 			mockedFHIRRequestURL:         to.Ptr("GET /fhir/Patient/1"),
 			mockedFHIRResponseStatusCode: to.Ptr(http.StatusNotFound),
 			method:                       to.Ptr("POST"),
-			url:                          to.Ptr("/cpc/test/fhir/Patient/_search"),
+			url:                          to.Ptr("/cpc/test/fhir/Patient/_search?identifier=http://fhir.nl/fhir/NamingSystem/bsn%7C111222333"),
 		},
 		{
 			name:                         "Fails: requester is CareTeam member but Period is expired - GET",
@@ -209,9 +215,70 @@ func TestService_Proxy_Get_And_Search(t *testing.T) {
 			mockedFHIRRequestURL:         to.Ptr("GET /fhir/Patient/1"),
 			mockedFHIRResponseStatusCode: to.Ptr(http.StatusOK),
 			method:                       to.Ptr("POST"),
-			url:                          to.Ptr("/cpc/test/fhir/Patient/_search"),
+			url:                          to.Ptr("/cpc/test/fhir/Patient/_search?identifier=http://fhir.nl/fhir/NamingSystem/bsn%7C111222333"),
 			expectedJSON:                 `{"issue":[{"severity":"error","code":"processing","diagnostics":"CarePlanContributor/POST /cpc/test/fhir/Patient/_search failed: Forbidden"}],"resourceType":"OperationOutcome"}`,
 		},
+		{
+			name:               "Fails: search not scoped to CarePlan subject",
+			expectedStatus:     http.StatusForbidden,
+			readBodyReturnFile: "./testdata/careplan-valid.json",
+			readStatusReturn:   http.StatusOK,
+			xSCPContext:        "CarePlan/cps-careplan-01",
+			url:                to.Ptr("/cpc/test/fhir/Patient"),
+			expectedJSON:       `{"issue":[{"severity":"error","code":"processing","diagnostics":"CarePlanContributor/GET /cpc/test/fhir/Patient failed: Forbidden"}],"resourceType":"OperationOutcome"}`,
+		},
+		{
+			name:               "Fails: search for other patient",
+			expectedStatus:     http.StatusForbidden,
+			readBodyReturnFile: "./testdata/careplan-valid.json",
+			readStatusReturn:   http.StatusOK,
+			xSCPContext:        "CarePlan/cps-careplan-01",
+			url:                to.Ptr("/cpc/test/fhir/Patient?identifier=http://fhir.nl/fhir/NamingSystem/bsn%7C1333333337"),
+			expectedJSON:       `{"issue":[{"severity":"error","code":"processing","diagnostics":"CarePlanContributor/GET /cpc/test/fhir/Patient failed: Forbidden"}],"resourceType":"OperationOutcome"}`,
+		},
+		{
+			name:                         "Fails: patient did not consent",
+			expectedStatus:               http.StatusForbidden,
+			readBodyReturnFile:           "./testdata/careplan-valid.json",
+			mockedFHIRRequestURL:         to.Ptr("/Patient/1"),
+			readStatusReturn:             http.StatusOK,
+			xSCPContext:                  "CarePlan/cps-careplan-01",
+			mockedFHIRResponseStatusCode: to.Ptr(http.StatusOK),
+			consentChecker:               consent.StubChecker{Deny: []string{"http://fhir.nl/fhir/NamingSystem/bsn|111222333"}},
+			expectedJSON:                 `{"issue":[{"severity":"error","code":"business-rule","diagnostics":"patient has not consented to sharing data with the requesting care organization"}],"resourceType":"OperationOutcome"}`,
+		},
+		{
+			name:                         "Fails: patient of pseudonymized CarePlan did not consent",
+			expectedStatus:               http.StatusForbidden,
+			readBodyReturnFile:           "./testdata/careplan-pseudonymized.json",
+			mockedFHIRRequestURL:         to.Ptr("/Patient/1"),
+			readStatusReturn:             http.StatusOK,
+			xSCPContext:                  "CarePlan/cps-careplan-02",
+			mockedFHIRResponseStatusCode: to.Ptr(http.StatusOK),
+			consentChecker:               consent.StubChecker{Deny: []string{"http://fhir.nl/fhir/NamingSystem/bsn|111222333"}},
+			expectedJSON:                 `{"issue":[{"severity":"error","code":"business-rule","diagnostics":"patient has not consented to sharing data with the requesting care organization"}],"resourceType":"OperationOutcome"}`,
+		},
+		{
+			name:                         "Success: pseudonymized CarePlan, search scoped to BSN of its Patient",
+			expectedStatus:               http.StatusOK,
+			readBodyReturnFile:           "./testdata/careplan-pseudonymized.json",
+			mockedFHIRRequestURL:         to.Ptr("/Patient"),
+			url:                          to.Ptr("/cpc/test/fhir/Patient?identifier=http://fhir.nl/fhir/NamingSystem/bsn%7C111222333"),
+			readStatusReturn:             http.StatusOK,
+			xSCPContext:                  "CarePlan/cps-careplan-02",
+			mockedFHIRResponseStatusCode: to.Ptr(http.StatusOK),
+			consentChecker:               consent.StubChecker{Deny: []string{"http://fhir.nl/fhir/NamingSystem/bsn|1333333337"}},
+		},
+		{
+			name:                         "Success: patient consented",
+			expectedStatus:               http.StatusOK,
+			readBodyReturnFile:           "./testdata/careplan-valid.json",
+			mockedFHIRRequestURL:         to.Ptr("/Patient/1"),
+			readStatusReturn:             http.StatusOK,
+			xSCPContext:                  "CarePlan/cps-careplan-01",
+			mockedFHIRResponseStatusCode: to.Ptr(http.StatusOK),
+			consentChecker:               consent.StubChecker{Deny: []string{"http://fhir.nl/fhir/NamingSystem/bsn|1333333337"}},
+		},
 		{
 			name:                         "Success: valid request - GET",
 			expectedStatus:               http.StatusOK,
@@ -226,7 +293,7 @@ func TestService_Proxy_Get_And_Search(t *testing.T) {
 			expectedStatus:               http.StatusOK,
 			readBodyReturnFile:           "./testdata/careplan-valid.json",
 			mockedFHIRRequestURL:         to.Ptr("/Patient"),
-			url:                          to.Ptr("/cpc/test/fhir/Patient"),
+			url:                          to.Ptr("/cpc/test/fhir/Patient?identifier=http://fhir.nl/fhir/NamingSystem/bsn%7C111222333"),
 			readStatusReturn:             http.StatusOK,
 			xSCPContext:                  "CarePlan/cps-careplan-01",
 			mockedFHIRResponseStatusCode: to.Ptr(http.StatusOK),
@@ -250,7 +317,7 @@ func TestService_Proxy_Get_And_Search(t *testing.T) {
 			xSCPContext:                  "CarePlan/cps-careplan-01",
 			mockedFHIRResponseStatusCode: to.Ptr(http.StatusOK),
 			method:                       to.Ptr("POST"),
-			url:                          to.Ptr("/cpc/test/fhir/Patient/_search"),
+			url:                          to.Ptr("/cpc/test/fhir/Patient/_search?identifier=http://fhir.nl/fhir/NamingSystem/bsn%7C111222333"),
 		},
 		{
 			name:                         "Success: valid request - POST - Allow caching",
@@ -261,7 +328,7 @@ func TestService_Proxy_Get_And_Search(t *testing.T) {
 			xSCPContext:                  "CarePlan/cps-careplan-01",
 			mockedFHIRResponseStatusCode: to.Ptr(http.StatusOK),
 			method:                       to.Ptr("POST"),
-			url:                          to.Ptr("/cpc/test/fhir/Patient/_search"),
+			url:                          to.Ptr("/cpc/test/fhir/Patient/_search?identifier=http://fhir.nl/fhir/NamingSystem/bsn%7C111222333"),
 			allowCaching:                 true,
 		},
 	}
@@ -274,6 +341,13 @@ func TestService_Proxy_Get_And_Search(t *testing.T) {
 			sessionManager, _ := createTestSession()
 			messageBroker, err := messaging.New(messaging.Config{}, nil)
 			require.NoError(t, err)
+			var auditEventsCreated atomic.Int32
+			fhirServerMux.HandleFunc("POST /AuditEvent", func(writer http.ResponseWriter, request *http.Request) {
+				auditEventsCreated.Add(1)
+				writer.Header().Set("Content-Type", "application/fhir+json")
+				writer.WriteHeader(http.StatusCreated)
+				_, _ = io.Copy(writer, request.Body)
+			})
 
 			carePlanServiceMux := http.NewServeMux()
 			carePlanService := httptest.NewServer(carePlanServiceMux)
@@ -302,6 +376,9 @@ func TestService_Proxy_Get_And_Search(t *testing.T) {
 			service.ehrFHIRClientByTenant = map[string]fhirclient.Client{
 				tenant.ID: fhirclient.New(fhirServerURL, &http.Client{}, nil),
 			}
+			if tt.consentChecker != nil {
+				service.consentCheckerByTenant[tenant.ID] = tt.consentChecker
+			}
 
 			// Setup: configure the service to proxy to the backing FHIR server
 			frontServerMux := http.NewServeMux()
@@ -313,6 +390,11 @@ func TestService_Proxy_Get_And_Search(t *testing.T) {
 					_, _ = writer.Write(rawJson)
 				})
 			}
+			// Patient of pseudonymized CarePlans
+			carePlanServiceMux.HandleFunc("GET /cps/test/Patient/1", func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set("Content-Type", "application/fhir+json")
+				_, _ = writer.Write([]byte(`{"resourceType":"Patient","id":"1","identifier":[{"system":"http://fhir.nl/fhir/NamingSystem/bsn","value":"111222333"}]}`))
+			})
 			if tt.mockedFHIRRequestURL != nil && tt.mockedFHIRResponseStatusCode != nil {
 				fhirServerMux.HandleFunc(*tt.mockedFHIRRequestURL, func(writer http.ResponseWriter, request *http.Request) {
 					writer.Header().Set("Content-Type", "application/fhir+json")
@@ -334,7 +416,7 @@ func TestService_Proxy_Get_And_Search(t *testing.T) {
 											"identifier": [
 												{
 													"system": "http://fhir.nl/fhir/NamingSystem/bsn",
-													"value": "1333333337"
+													"value": "111222333"
 												}
 											]
 										}
@@ -349,7 +431,7 @@ func TestService_Proxy_Get_And_Search(t *testing.T) {
 								"identifier": [
 									{
 										"system": "http://fhir.nl/fhir/NamingSystem/bsn",
-										"value": "1333333337"
+										"value": "111222333"
 									}
 								]
 							}`))
@@ -393,6 +475,12 @@ func TestService_Proxy_Get_And_Search(t *testing.T) {
 				body, _ := io.ReadAll(httpResponse.Body)
 				require.JSONEq(t, tt.expectedJSON, string(body))
 			}
+			if tt.expectedStatus == http.StatusOK {
+				// Access to the health data view is recorded as AuditEvent in the EHR's FHIR API
+				require.Eventually(t, func() bool {
+					return auditEventsCreated.Load() == 1
+				}, time.Second, 10*time.Millisecond)
+			}
 			if tt.readBodyReturnFile != "" {
 				if tt.expectedStatus == http.StatusOK {
 					if tt.allowCaching {
@@ -654,6 +742,158 @@ func TestService_HandleNotification_Valid(t *testing.T) {
 	require.Equal(t, fhirServerURL.String(), capturedFhirBaseUrl)
 }
 
+func TestService_notifyTaskStatusChanged(t *testing.T) {
+	ctx := tenants.WithTenant(context.Background(), tenants.Test().Sole())
+	fhirBaseURL := must.ParseURL("https://example.com/cps")
+	taskWithParties := func(requester *auth.Principal, owner *auth.Principal) *fhir.Task {
+		return &fhir.Task{
+			Id:     to.Ptr("1"),
+			Status: fhir.TaskStatusCompleted,
+			Requester: &fhir.Reference{
+				Identifier: &requester.Organization.Identifier[0],
+			},
+			Owner: &fhir.Reference{
+				Identifier: &owner.Organization.Identifier[0],
+			},
+		}
+	}
+	t.Run("local organization is requester", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		notifier := ehr.NewMockNotifier(ctrl)
+		task := taskWithParties(auth.TestPrincipal1, auth.TestPrincipal2)
+		notifier.EXPECT().NotifyTaskStatusChanged(gomock.Any(), fhirBaseURL.String(), task).Return(nil)
+		service := &Service{profile: profile.Test(), notifier: notifier}
+
+		err := service.notifyTaskStatusChanged(ctx, fhirBaseURL, task)
+
+		require.NoError(t, err)
+	})
+	t.Run("local organization is owner", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		notifier := ehr.NewMockNotifier(ctrl)
+		task := taskWithParties(auth.TestPrincipal2, auth.TestPrincipal1)
+		notifier.EXPECT().NotifyTaskStatusChanged(gomock.Any(), fhirBaseURL.String(), task).Return(nil)
+		service := &Service{profile: profile.Test(), notifier: notifier}
+
+		err := service.notifyTaskStatusChanged(ctx, fhirBaseURL, task)
+
+		require.NoError(t, err)
+	})
+	t.Run("local organization is not requester or owner", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		notifier := ehr.NewMockNotifier(ctrl)
+		service := &Service{profile: profile.Test(), notifier: notifier}
+
+		err := service.notifyTaskStatusChanged(ctx, fhirBaseURL, taskWithParties(auth.TestPrincipal2, auth.TestPrincipal3))
+
+		require.NoError(t, err)
+	})
+	t.Run("no notifier", func(t *testing.T) {
+		service := &Service{profile: profile.Test()}
+
+		err := service.notifyTaskStatusChanged(ctx, fhirBaseURL, taskWithParties(auth.TestPrincipal1, auth.TestPrincipal2))
+
+		require.NoError(t, err)
+	})
+}
+
+// versioningFHIRClient is a StubFHIRClient that supports reading by absolute URL and increments meta.versionId on update, like a FHIR server would.
+type versioningFHIRClient struct {
+	*test.StubFHIRClient
+	baseURL string
+}
+
+func (c *versioningFHIRClient) Read(path string, target any, opts ...fhirclient.Option) error {
+	return c.StubFHIRClient.ReadWithContext(context.Background(), strings.TrimPrefix(path, c.baseURL+"/"), target, opts...)
+}
+
+func (c *versioningFHIRClient) UpdateWithContext(ctx context.Context, path string, resource any, result any, opts ...fhirclient.Option) error {
+	if task, ok := resource.(fhir.Task); ok {
+		version := 0
+		if task.Meta != nil && task.Meta.VersionId != nil {
+			version, _ = strconv.Atoi(*task.Meta.VersionId)
+		} else {
+			task.Meta = &fhir.Meta{}
+		}
+		task.Meta = &fhir.Meta{VersionId: to.Ptr(strconv.Itoa(version + 1))}
+		resource = task
+	}
+	return c.StubFHIRClient.UpdateWithContext(ctx, path, resource, result, opts...)
+}
+
+func TestService_HandleNotification_EHRWriteBack(t *testing.T) {
+	// Task data written into the EHR is recorded on the Task at the CPS, which causes a notification of the Task.
+	// That notification must not cause the Task to be delivered to the EHR again.
+	tenantCfg := tenants.Test(func(properties *tenants.Properties) {
+		properties.TaskNotification.Delivery = tenants.TaskDeliveryFHIR
+		properties.TaskNotification.Statuses = []string{"completed"}
+	})
+	tenant := tenantCfg.Sole()
+	cpsBaseURL := "https://example.com/cps"
+	task := fhir.Task{
+		Id:        to.Ptr("1"),
+		Meta:      &fhir.Meta{VersionId: to.Ptr("1")},
+		Status:    fhir.TaskStatusCompleted,
+		Requester: &fhir.Reference{Identifier: &auth.TestPrincipal1.Organization.Identifier[0]},
+		Owner:     &fhir.Reference{Identifier: &auth.TestPrincipal2.Organization.Identifier[0]},
+		BasedOn:   []fhir.Reference{{Reference: to.Ptr("CarePlan/1")}},
+		For:       &fhir.Reference{Reference: to.Ptr("Patient/1")},
+		Focus:     &fhir.Reference{Reference: to.Ptr("ServiceRequest/1")},
+	}
+	cpsClient := &versioningFHIRClient{
+		baseURL: cpsBaseURL,
+		StubFHIRClient: &test.StubFHIRClient{Resources: []any{
+			task,
+			fhir.Task{Id: to.Ptr("2"), PartOf: []fhir.Reference{{Reference: to.Ptr("Task/1")}}},
+			fhir.CarePlan{Id: to.Ptr("1"), Subject: fhir.Reference{Reference: to.Ptr("Patient/1")}},
+			fhir.Patient{Id: to.Ptr("1")},
+			fhir.ServiceRequest{Id: to.Ptr("1")},
+		}},
+	}
+	ctrl := gomock.NewController(t)
+	ehrClient := mock.NewMockClient(ctrl)
+	ehrClient.EXPECT().Path(gomock.Any()).DoAndReturn(func(path ...string) *url.URL {
+		return must.ParseURL("https://ehr.example.com/fhir").JoinPath(path...)
+	}).AnyTimes()
+	ehrClient.EXPECT().CreateWithContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, resource interface{}, result interface{}, _ ...fhirclient.Option) error {
+			tx := resource.(fhir.Bundle)
+			response := fhir.Bundle{Type: fhir.BundleTypeTransactionResponse}
+			for i := range tx.Entry {
+				response.Entry = append(response.Entry, fhir.BundleEntry{
+					Response: &fhir.BundleEntryResponse{Status: "201 Created", Location: to.Ptr(tx.Entry[i].Request.Url + "/" + strconv.Itoa(i))},
+				})
+			}
+			*result.(*fhir.Bundle) = response
+			return nil
+		}).Times(1)
+	notifier, err := ehr.NewNotifier(events.NewManager(messaging.NewMemoryBroker()), tenantCfg, "",
+		func(_ context.Context, _ *url.URL) (fhirclient.Client, *http.Client, error) {
+			return cpsClient, nil, nil
+		}, map[string]fhirclient.Client{tenant.ID: ehrClient})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		fhirClientFactory = createFHIRClient
+	})
+	fhirClientFactory = func(_ *url.URL, _ *http.Client) fhirclient.Client {
+		return cpsClient
+	}
+	service := &Service{profile: profile.Test(), notifier: notifier}
+	ctx := tenants.WithTenant(context.Background(), tenant)
+	ctx = auth.WithPrincipal(ctx, *auth.TestPrincipal2)
+	notification := coolfhir.CreateSubscriptionNotification(must.ParseURL(cpsBaseURL),
+		time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		fhir.Reference{Reference: to.Ptr("CareTeam/1")}, 1, fhir.Reference{Reference: to.Ptr("Task/1"), Type: to.Ptr("Task")})
+
+	// Task completed: delivered to the EHR, after which the EHR resources are recorded on the Task
+	require.NoError(t, service.handleNotification(ctx, &notification))
+	var updatedTask fhir.Task
+	require.NoError(t, cpsClient.ReadWithContext(ctx, "Task/1", &updatedTask))
+	require.Equal(t, "2", *updatedTask.Meta.VersionId)
+	// Notification of the Task update: not delivered again
+	require.NoError(t, service.handleNotification(ctx, &notification))
+}
+
 func TestService_Proxy_ProxyToEHR_WithLogout(t *testing.T) {
 	// Test that the service registers the EHR FHIR proxy URL that proxies to the backing FHIR server of the EHR
 	// Setup: configure backing EHR FHIR server to which the service proxies
@@ -795,6 +1035,52 @@ func TestService_HandleSearchEndpoints(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, http.StatusBadRequest, httpResponse.StatusCode)
 	})
+	t.Run("organization placeholder", func(t *testing.T) {
+		service, err := New(Config{
+			AppLaunch: applaunch.Config{
+				External: map[string]external.Config{
+					"app1": {
+						Name: "App 1",
+						URL:  "https://example.com/app1/{organization}",
+					},
+				},
+			},
+		}, tenants.Test(), profile.Test(), orcaPublicURL, sessionManager, events.NewManager(messageBroker), false, nil)
+		require.NoError(t, err)
+		frontServerMux := http.NewServeMux()
+		service.RegisterHandlers(frontServerMux)
+		frontServer := httptest.NewServer(frontServerMux)
+		agbPrincipal := *auth.TestPrincipal1
+		agbPrincipal.Organization.Identifier = []fhir.Identifier{{System: to.Ptr("http://fhir.nl/fhir/NamingSystem/agb-z"), Value: to.Ptr("01234567")}}
+		get := func(t *testing.T, principal *auth.Principal) *http.Response {
+			httpClient := &http.Client{Transport: auth.AuthenticatedTestRoundTripper(nil, principal, "")}
+			httpResponse, err := httpClient.Get(frontServer.URL + "/cpc/test/fhir/Endpoint")
+			require.NoError(t, err)
+			return httpResponse
+		}
+
+		t.Run("URA", func(t *testing.T) {
+			httpResponse := get(t, auth.TestPrincipal1)
+			require.Equal(t, http.StatusOK, httpResponse.StatusCode)
+			responseData, _ := io.ReadAll(httpResponse.Body)
+			require.Contains(t, string(responseData), "https://example.com/app1/1")
+		})
+		t.Run("identifier equivalent to URA", func(t *testing.T) {
+			require.NoError(t, coolfhir.SetIdentifierEquivalences([][]string{{"http://fhir.nl/fhir/NamingSystem/agb-z|01234567", coolfhir.URANamingSystem + "|5"}}))
+			defer coolfhir.SetIdentifierEquivalences(nil)
+
+			httpResponse := get(t, &agbPrincipal)
+			require.Equal(t, http.StatusOK, httpResponse.StatusCode)
+			responseData, _ := io.ReadAll(httpResponse.Body)
+			require.Contains(t, string(responseData), "https://example.com/app1/5")
+		})
+		t.Run("no URA", func(t *testing.T) {
+			httpResponse := get(t, &agbPrincipal)
+			require.Equal(t, http.StatusBadRequest, httpResponse.StatusCode)
+			responseData, _ := io.ReadAll(httpResponse.Body)
+			require.Contains(t, string(responseData), "requesting organization has no URA")
+		})
+	})
 }
 
 func TestService_handleGetContext(t *testing.T) {
@@ -1463,3 +1749,23 @@ func TestService_HealthCheck(t *testing.T) {
 		require.Equal(t, http.StatusUnauthorized, httpResponse.StatusCode)
 	})
 }
+
+func TestUserFromSession(t *testing.T) {
+	t.Run("Practitioner and PractitionerRole", func(t *testing.T) {
+		sessionData := &session.Data{}
+		sessionData.Set("Practitioner/1", fhir.Practitioner{Id: to.Ptr("1")})
+		sessionData.Set("PractitionerRole/1", fhir.PractitionerRole{Id: to.Ptr("1")})
+
+		user := userFromSession(sessionData)
+
+		require.NotNil(t, user)
+		require.Equal(t, "1", *user.Practitioner.Id)
+		require.Equal(t, "1", *user.PractitionerRole.Id)
+	})
+	t.Run("no Practitioner", func(t *testing.T) {
+		sessionData := &session.Data{}
+		sessionData.Set("Patient/1", fhir.Patient{Id: to.Ptr("1")})
+
+		require.Nil(t, userFromSession(sessionData))
+	})
+}
@@ -0,0 +1,153 @@
+package taskengine
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+// WorkflowPriorityExtensionURL is the URL of the extension (valueInteger) on a HealthcareService or Questionnaire,
+// that specifies its priority when multiple workflows match a Task. Higher values take precedence, the default is 0.
+const WorkflowPriorityExtensionURL = "http://santeonnl.github.io/orca/StructureDefinition/workflow-priority"
+
+// WorkflowInputType is the Task.input.type through which the placer can name the desired workflow,
+// by referring to the HealthcareService or Questionnaire (valueReference, valueCanonical or valueString).
+var WorkflowInputType = fhir.Coding{
+	System: to.Ptr("http://santeonnl.github.io/orca/CodeSystem/task-input-type"),
+	Code:   to.Ptr("workflow"),
+}
+
+// ErrMultipleWorkflows is returned when multiple workflows match a Task, and none of them takes precedence.
+var ErrMultipleWorkflows = errors.New("multiple workflows match, need to choose one")
+
+// ErrRequestedWorkflowNotFound is returned when the placer requested a workflow that isn't among the matching workflows.
+var ErrRequestedWorkflowNotFound = errors.New("requested workflow is not offered for the service and condition")
+
+// SelectWorkflow chooses a workflow from the workflows matching a Task, in the following order:
+//  1. If the Task names the desired workflow in Task.input (see WorkflowInputType), that workflow is chosen.
+//  2. If Task.performerType matches the specialty of the HealthcareService of some workflows, only those are considered.
+//  3. The workflow with the highest priority is chosen.
+//
+// If multiple workflows remain, ErrMultipleWorkflows is returned.
+func SelectWorkflow(task fhir.Task, candidates []*Workflow) (*Workflow, error) {
+	candidates = deduplicateWorkflows(candidates)
+	if len(candidates) == 0 {
+		return nil, ErrWorkflowNotFound
+	}
+	if requested := RequestedWorkflows(task); len(requested) > 0 {
+		var matches []*Workflow
+		for _, candidate := range candidates {
+			for _, reference := range requested {
+				if candidate.References(reference) {
+					matches = append(matches, candidate)
+					break
+				}
+			}
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("%w: %v", ErrRequestedWorkflowNotFound, requested)
+		}
+		candidates = matches
+	}
+	if len(candidates) > 1 && len(task.PerformerType) > 0 {
+		var matches []*Workflow
+		for _, candidate := range candidates {
+			if performerTypeMatches(task.PerformerType, candidate.Specialties) {
+				matches = append(matches, candidate)
+			}
+		}
+		// Task.performerType is only used to narrow down the candidates, it doesn't need to match
+		if len(matches) > 0 {
+			candidates = matches
+		}
+	}
+	var selected []*Workflow
+	for _, candidate := range candidates {
+		if len(selected) == 0 || candidate.Priority > selected[0].Priority {
+			selected = []*Workflow{candidate}
+		} else if candidate.Priority == selected[0].Priority {
+			selected = append(selected, candidate)
+		}
+	}
+	if len(selected) > 1 {
+		return nil, ErrMultipleWorkflows
+	}
+	return selected[0], nil
+}
+
+// RequestedWorkflows returns the references to the workflows (HealthcareServices or Questionnaires) the placer named in Task.input.
+func RequestedWorkflows(task fhir.Task) []string {
+	var result []string
+	for _, input := range task.Input {
+		if !coolfhir.ConceptContainsCoding(WorkflowInputType, input.Type) {
+			continue
+		}
+		switch {
+		case input.ValueReference != nil && input.ValueReference.Reference != nil:
+			result = append(result, *input.ValueReference.Reference)
+		case input.ValueCanonical != nil:
+			result = append(result, *input.ValueCanonical)
+		case input.ValueString != nil:
+			result = append(result, *input.ValueString)
+		}
+	}
+	return result
+}
+
+func performerTypeMatches(performerTypes []fhir.CodeableConcept, specialties []fhir.CodeableConcept) bool {
+	for _, performerType := range performerTypes {
+		for _, coding := range performerType.Coding {
+			if coolfhir.ConceptContainsCoding(coding, specialties...) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// deduplicateWorkflows removes workflows that start with the same Questionnaire,
+// which happens when multiple codes of a Task match the same workflow.
+func deduplicateWorkflows(workflows []*Workflow) []*Workflow {
+	var result []*Workflow
+	for _, workflow := range workflows {
+		duplicate := false
+		for i, existing := range result {
+			if existing.Start().QuestionnaireUrl == workflow.Start().QuestionnaireUrl &&
+				existing.HealthcareService == workflow.HealthcareService {
+				duplicate = true
+				if workflow.Priority > existing.Priority {
+					result[i] = workflow
+				}
+				break
+			}
+		}
+		if !duplicate {
+			result = append(result, workflow)
+		}
+	}
+	return result
+}
+
+// workflowPriority returns the priority of a workflow from the given extension lists.
+// Later lists take precedence, e.g. the priority of the Questionnaire over the HealthcareService.
+func workflowPriority(extensionLists ...[]fhir.Extension) int {
+	result := 0
+	for _, extensions := range extensionLists {
+		if priority, ok := priorityExtension(extensions); ok {
+			result = priority
+		}
+	}
+	return result
+}
+
+func priorityExtension(extensions []fhir.Extension) (int, bool) {
+	for _, extension := range extensions {
+		if extension.Url == WorkflowPriorityExtensionURL && extension.ValueInteger != nil {
+			return *extension.ValueInteger, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,108 @@
+package taskengine
+
+import (
+	"testing"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+func TestSelectWorkflow(t *testing.T) {
+	copd := &Workflow{
+		Steps:             []WorkflowStep{{QuestionnaireUrl: "Questionnaire/copd", QuestionnaireCanonical: "http://example.com/Questionnaire/copd"}},
+		HealthcareService: "HealthcareService/telemonitoring-copd",
+		Specialties: []fhir.CodeableConcept{{Coding: []fhir.Coding{{
+			System: to.Ptr("http://snomed.info/sct"),
+			Code:   to.Ptr("418112009"), // Pulmonology
+		}}}},
+	}
+	heartFailure := &Workflow{
+		Steps:             []WorkflowStep{{QuestionnaireUrl: "Questionnaire/heartfailure"}},
+		HealthcareService: "HealthcareService/telemonitoring-heartfailure",
+	}
+	prioritized := func(workflow *Workflow, priority int) *Workflow {
+		result := *workflow
+		result.Priority = priority
+		return &result
+	}
+	requestWorkflow := func(input fhir.TaskInput) fhir.Task {
+		input.Type = fhir.CodeableConcept{Coding: []fhir.Coding{WorkflowInputType}}
+		return fhir.Task{Input: []fhir.TaskInput{input}}
+	}
+
+	t.Run("single workflow", func(t *testing.T) {
+		actual, err := SelectWorkflow(fhir.Task{}, []*Workflow{copd})
+		require.NoError(t, err)
+		require.Same(t, copd, actual)
+	})
+	t.Run("duplicate workflows", func(t *testing.T) {
+		actual, err := SelectWorkflow(fhir.Task{}, []*Workflow{copd, copd})
+		require.NoError(t, err)
+		require.Same(t, copd, actual)
+	})
+	t.Run("multiple workflows with the same priority", func(t *testing.T) {
+		_, err := SelectWorkflow(fhir.Task{}, []*Workflow{copd, heartFailure})
+		require.ErrorIs(t, err, ErrMultipleWorkflows)
+	})
+	t.Run("workflow with highest priority", func(t *testing.T) {
+		expected := prioritized(heartFailure, 10)
+		actual, err := SelectWorkflow(fhir.Task{}, []*Workflow{copd, expected})
+		require.NoError(t, err)
+		require.Same(t, expected, actual)
+	})
+	t.Run("no workflows", func(t *testing.T) {
+		_, err := SelectWorkflow(fhir.Task{}, nil)
+		require.ErrorIs(t, err, ErrWorkflowNotFound)
+	})
+	t.Run("requested workflow", func(t *testing.T) {
+		t.Run("HealthcareService reference", func(t *testing.T) {
+			task := requestWorkflow(fhir.TaskInput{ValueReference: &fhir.Reference{Reference: to.Ptr("https://example.com/fhir/HealthcareService/telemonitoring-heartfailure")}})
+			actual, err := SelectWorkflow(task, []*Workflow{prioritized(copd, 10), heartFailure})
+			require.NoError(t, err)
+			require.Same(t, heartFailure, actual, "requested workflow takes precedence over priority")
+		})
+		t.Run("Questionnaire canonical", func(t *testing.T) {
+			task := requestWorkflow(fhir.TaskInput{ValueCanonical: to.Ptr("http://example.com/Questionnaire/copd")})
+			actual, err := SelectWorkflow(task, []*Workflow{copd, heartFailure})
+			require.NoError(t, err)
+			require.Same(t, copd, actual)
+		})
+		t.Run("Questionnaire reference as string", func(t *testing.T) {
+			task := requestWorkflow(fhir.TaskInput{ValueString: to.Ptr("Questionnaire/heartfailure")})
+			actual, err := SelectWorkflow(task, []*Workflow{copd, heartFailure})
+			require.NoError(t, err)
+			require.Same(t, heartFailure, actual)
+		})
+		t.Run("not offered", func(t *testing.T) {
+			task := requestWorkflow(fhir.TaskInput{ValueString: to.Ptr("Questionnaire/asthma")})
+			_, err := SelectWorkflow(task, []*Workflow{copd, heartFailure})
+			require.ErrorIs(t, err, ErrRequestedWorkflowNotFound)
+		})
+	})
+	t.Run("performerType", func(t *testing.T) {
+		t.Run("matches specialty", func(t *testing.T) {
+			task := fhir.Task{PerformerType: copd.Specialties}
+			actual, err := SelectWorkflow(task, []*Workflow{copd, heartFailure})
+			require.NoError(t, err)
+			require.Same(t, copd, actual)
+		})
+		t.Run("doesn't match any specialty", func(t *testing.T) {
+			task := fhir.Task{PerformerType: []fhir.CodeableConcept{{Coding: []fhir.Coding{{
+				System: to.Ptr("http://snomed.info/sct"),
+				Code:   to.Ptr("394579002"), // Cardiology
+			}}}}}
+			_, err := SelectWorkflow(task, []*Workflow{copd, heartFailure})
+			require.ErrorIs(t, err, ErrMultipleWorkflows)
+		})
+	})
+}
+
+func Test_workflowPriority(t *testing.T) {
+	priority := func(value int) []fhir.Extension {
+		return []fhir.Extension{{Url: WorkflowPriorityExtensionURL, ValueInteger: to.Ptr(value)}}
+	}
+	require.Equal(t, 0, workflowPriority(nil, nil))
+	require.Equal(t, 5, workflowPriority(priority(5), nil))
+	require.Equal(t, 7, workflowPriority(priority(5), priority(7)), "later extension list takes precedence")
+}
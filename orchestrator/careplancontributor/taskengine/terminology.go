@@ -0,0 +1,297 @@
+package taskengine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/debug"
+	"github.com/SanteonNL/orca/orchestrator/lib/otel"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ValueSetReferenceExtensionURL is the URL of the extension on a CodeableConcept that refers to a ValueSet (by its canonical URL),
+// meaning the CodeableConcept matches any code in that ValueSet.
+const ValueSetReferenceExtensionURL = "http://hl7.org/fhir/StructureDefinition/valueset-reference"
+
+// Terminology matches codes using CodeSystem hierarchies and ValueSets loaded from local terminology bundles,
+// so workflows can be offered for a group of codes (e.g. all SNOMED CT subtypes of a condition) instead of individual codes.
+// A nil Terminology only matches codes that are exactly equal.
+type Terminology struct {
+	// parents maps a code (system|code) to the codes it's a direct subtype of (is-a).
+	parents map[string][]string
+	// valueSets maps the canonical URL of a ValueSet to the ValueSet.
+	valueSets map[string]fhir.ValueSet
+}
+
+// LoadBundle loads the CodeSystem and ValueSet resources from the FHIR Bundle at the given URL (http, https or file) into the Terminology.
+// CodeSystem hierarchies can be specified by nesting concepts, or through the "parent" property of concepts.
+func (t *Terminology) LoadBundle(ctx context.Context, bundleUrl string) error {
+	ctx, span := tracer.Start(
+		ctx,
+		debug.GetFullCallerName(),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("bundle.url", bundleUrl),
+		),
+	)
+	defer span.End()
+
+	bundle, err := readBundle(ctx, bundleUrl)
+	if err != nil {
+		return otel.Error(span, err)
+	}
+	var codeSystems []fhir.CodeSystem
+	if err := coolfhir.ResourcesInBundle(bundle, coolfhir.EntryIsOfType("CodeSystem"), &codeSystems); err != nil {
+		return otel.Error(span, fmt.Errorf("could not extract code systems from bundle: %w", err))
+	}
+	var valueSets []fhir.ValueSet
+	if err := coolfhir.ResourcesInBundle(bundle, coolfhir.EntryIsOfType("ValueSet"), &valueSets); err != nil {
+		return otel.Error(span, fmt.Errorf("could not extract value sets from bundle: %w", err))
+	}
+	if t.parents == nil {
+		t.parents = make(map[string][]string)
+	}
+	if t.valueSets == nil {
+		t.valueSets = make(map[string]fhir.ValueSet)
+	}
+	for _, codeSystem := range codeSystems {
+		if codeSystem.Url == nil {
+			return otel.Error(span, fmt.Errorf("CodeSystem without url (id=%s)", to.EmptyString(codeSystem.Id)))
+		}
+		t.addConcepts(*codeSystem.Url, "", codeSystem.Concept)
+	}
+	for _, valueSet := range valueSets {
+		if valueSet.Url == nil {
+			return otel.Error(span, fmt.Errorf("ValueSet without url (id=%s)", to.EmptyString(valueSet.Id)))
+		}
+		t.valueSets[*valueSet.Url] = valueSet
+	}
+
+	span.SetAttributes(
+		attribute.Int("code_systems.loaded", len(codeSystems)),
+		attribute.Int("value_sets.loaded", len(valueSets)),
+	)
+	span.SetStatus(codes.Ok, "")
+	return nil
+}
+
+func (t *Terminology) addConcepts(system string, parentCode string, concepts []fhir.CodeSystemConcept) {
+	for _, concept := range concepts {
+		key := system + "|" + concept.Code
+		if parentCode != "" {
+			t.parents[key] = append(t.parents[key], system+"|"+parentCode)
+		}
+		for _, property := range concept.Property {
+			if property.Code == "parent" && property.ValueCode != nil {
+				t.parents[key] = append(t.parents[key], system+"|"+*property.ValueCode)
+			}
+		}
+		t.addConcepts(system, concept.Code, concept.Concept)
+	}
+}
+
+// Subsumes returns whether the given code is equal to, or a (transitive) subtype of the given ancestor code.
+func (t *Terminology) Subsumes(ancestor fhir.Coding, code fhir.Coding) bool {
+	if ancestor.System == nil || ancestor.Code == nil || code.System == nil || code.Code == nil {
+		return false
+	}
+	if *ancestor.System != *code.System {
+		return false
+	}
+	if *ancestor.Code == *code.Code {
+		return true
+	}
+	if t == nil {
+		return false
+	}
+	target := *ancestor.System + "|" + *ancestor.Code
+	visited := map[string]bool{}
+	queue := []string{*code.System + "|" + *code.Code}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, parent := range t.parents[current] {
+			if parent == target {
+				return true
+			}
+			if !visited[parent] {
+				visited[parent] = true
+				queue = append(queue, parent)
+			}
+		}
+	}
+	return false
+}
+
+// ValueSetContains returns whether the ValueSet with the given canonical URL contains the given code.
+// It supports ValueSets with an expansion, or a compose with concepts and is-a, descendent-of or = filters on the concept property.
+// It returns false if the ValueSet isn't known.
+func (t *Terminology) ValueSetContains(valueSetURL string, code fhir.Coding) bool {
+	if t == nil || code.System == nil || code.Code == nil {
+		return false
+	}
+	return t.valueSetContains(valueSetURL, code, map[string]bool{})
+}
+
+func (t *Terminology) valueSetContains(valueSetURL string, code fhir.Coding, visited map[string]bool) bool {
+	valueSetURL, _, _ = strings.Cut(valueSetURL, "|") // ignore version
+	if visited[valueSetURL] {
+		return false
+	}
+	visited[valueSetURL] = true
+	defer delete(visited, valueSetURL)
+	valueSet, ok := t.valueSets[valueSetURL]
+	if !ok {
+		return false
+	}
+	if valueSet.Expansion != nil && expansionContains(valueSet.Expansion.Contains, code) {
+		return true
+	}
+	if valueSet.Compose == nil {
+		return false
+	}
+	for _, exclude := range valueSet.Compose.Exclude {
+		if t.includeContains(exclude, code, visited) {
+			return false
+		}
+	}
+	for _, include := range valueSet.Compose.Include {
+		if t.includeContains(include, code, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Terminology) includeContains(include fhir.ValueSetComposeInclude, code fhir.Coding, visited map[string]bool) bool {
+	for _, valueSetURL := range include.ValueSet {
+		if !t.valueSetContains(valueSetURL, code, visited) {
+			return false
+		}
+	}
+	if include.System == nil {
+		return len(include.ValueSet) > 0
+	}
+	if *include.System != *code.System {
+		return false
+	}
+	if len(include.Concept) > 0 {
+		found := false
+		for _, concept := range include.Concept {
+			if concept.Code == *code.Code {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, filter := range include.Filter {
+		if filter.Property != "concept" {
+			return false
+		}
+		ancestor := fhir.Coding{System: include.System, Code: &filter.Value}
+		switch filter.Op {
+		case fhir.FilterOperatorEquals:
+			if filter.Value != *code.Code {
+				return false
+			}
+		case fhir.FilterOperatorIsA:
+			if !t.Subsumes(ancestor, code) {
+				return false
+			}
+		case fhir.FilterOperatorDescendentOf:
+			if filter.Value == *code.Code || !t.Subsumes(ancestor, code) {
+				return false
+			}
+		case fhir.FilterOperatorIsNotA:
+			if t.Subsumes(ancestor, code) {
+				return false
+			}
+		default:
+			// Unsupported filter, so we can't tell whether the code is included
+			return false
+		}
+	}
+	return true
+}
+
+func expansionContains(contains []fhir.ValueSetExpansionContains, code fhir.Coding) bool {
+	for _, curr := range contains {
+		if curr.System != nil && curr.Code != nil && *curr.System == *code.System && *curr.Code == *code.Code {
+			return true
+		}
+		if expansionContains(curr.Contains, code) {
+			return true
+		}
+	}
+	return false
+}
+
+// ConceptContainsCoding returns whether the given code matches any of the given concepts.
+// A code matches a concept if it's subsumed by (equal to, or a subtype of) one of its codings,
+// or if it's contained in the ValueSet the concept refers to through the valueset-reference extension.
+func (t *Terminology) ConceptContainsCoding(code fhir.Coding, concepts ...fhir.CodeableConcept) bool {
+	if coolfhir.ConceptContainsCoding(code, concepts...) {
+		return true
+	}
+	for _, concept := range concepts {
+		for _, coding := range concept.Coding {
+			if t.Subsumes(coding, code) {
+				return true
+			}
+		}
+		for _, extension := range concept.Extension {
+			if extension.Url == ValueSetReferenceExtensionURL && extension.ValueUri != nil && t.ValueSetContains(*extension.ValueUri, code) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// readBundle reads a FHIR Bundle from the given URL, which can be a file:// URL or an HTTP(S) URL.
+func readBundle(ctx context.Context, bundleUrl string) (*fhir.Bundle, error) {
+	var data []byte
+	fileUrlPrefix := "file://"
+	if strings.HasPrefix(bundleUrl, fileUrlPrefix) {
+		var err error
+		data, err = os.ReadFile(bundleUrl[len(fileUrlPrefix):])
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		httpRequest, err := http.NewRequestWithContext(ctx, http.MethodGet, bundleUrl, nil)
+		if err != nil {
+			return nil, err
+		}
+		httpResponse, err := otel.NewTracedHTTPClient("taskengine.Terminology").Do(httpRequest)
+		if err != nil {
+			return nil, err
+		}
+		defer httpResponse.Body.Close()
+		if httpResponse.StatusCode <= 199 || httpResponse.StatusCode >= 300 {
+			return nil, fmt.Errorf("unexpected status code: %d", httpResponse.StatusCode)
+		}
+		data, err = io.ReadAll(io.LimitReader(httpResponse.Body, 1024*1024*50))
+		if err != nil {
+			return nil, err
+		}
+	}
+	var bundle fhir.Bundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, fmt.Errorf("could not unmarshal bundle: %w", err)
+	}
+	return &bundle, nil
+}
@@ -0,0 +1,142 @@
+package taskengine
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+const snomed = "http://snomed.info/sct"
+
+func snomedCode(code string) fhir.Coding {
+	return fhir.Coding{System: to.Ptr(snomed), Code: to.Ptr(code)}
+}
+
+// testTerminology returns a Terminology with a small SNOMED CT hierarchy:
+// 13645005 (COPD) has subtypes 195951007 (Acute exacerbation of COPD) and 313296004 (Mild COPD),
+// 195951007 has subtype 106001000119101 (through the parent property).
+func testTerminology(t *testing.T) *Terminology {
+	bundle := fhir.Bundle{
+		Type: fhir.BundleTypeCollection,
+	}
+	addEntry := func(resource interface{}) {
+		data, err := json.Marshal(resource)
+		require.NoError(t, err)
+		bundle.Entry = append(bundle.Entry, fhir.BundleEntry{Resource: data})
+	}
+	addEntry(fhir.CodeSystem{
+		Url: to.Ptr(snomed),
+		Concept: []fhir.CodeSystemConcept{
+			{
+				Code: "13645005",
+				Concept: []fhir.CodeSystemConcept{
+					{Code: "195951007"},
+					{Code: "313296004"},
+				},
+			},
+			{
+				Code:     "106001000119101",
+				Property: []fhir.CodeSystemConceptProperty{{Code: "parent", ValueCode: to.Ptr("195951007")}},
+			},
+		},
+	})
+	addEntry(fhir.ValueSet{
+		Url: to.Ptr("http://example.com/ValueSet/copd"),
+		Compose: &fhir.ValueSetCompose{
+			Include: []fhir.ValueSetComposeInclude{
+				{
+					System: to.Ptr(snomed),
+					Filter: []fhir.ValueSetComposeIncludeFilter{{Property: "concept", Op: fhir.FilterOperatorIsA, Value: "13645005"}},
+				},
+			},
+			Exclude: []fhir.ValueSetComposeInclude{
+				{
+					System:  to.Ptr(snomed),
+					Concept: []fhir.ValueSetComposeIncludeConcept{{Code: "313296004"}},
+				},
+			},
+		},
+	})
+	addEntry(fhir.ValueSet{
+		Url: to.Ptr("http://example.com/ValueSet/heartfailure"),
+		Expansion: &fhir.ValueSetExpansion{
+			Contains: []fhir.ValueSetExpansionContains{{System: to.Ptr(snomed), Code: to.Ptr("84114007")}},
+		},
+	})
+	data, err := json.Marshal(bundle)
+	require.NoError(t, err)
+	bundlePath := filepath.Join(t.TempDir(), "terminology.json")
+	require.NoError(t, os.WriteFile(bundlePath, data, 0644))
+
+	result := &Terminology{}
+	require.NoError(t, result.LoadBundle(context.Background(), "file://"+bundlePath))
+	return result
+}
+
+func TestTerminology_Subsumes(t *testing.T) {
+	terminology := testTerminology(t)
+	copd := snomedCode("13645005")
+
+	t.Run("equal", func(t *testing.T) {
+		require.True(t, terminology.Subsumes(copd, copd))
+	})
+	t.Run("nested concept", func(t *testing.T) {
+		require.True(t, terminology.Subsumes(copd, snomedCode("195951007")))
+	})
+	t.Run("transitive, through parent property", func(t *testing.T) {
+		require.True(t, terminology.Subsumes(copd, snomedCode("106001000119101")))
+	})
+	t.Run("ancestor isn't subsumed by descendant", func(t *testing.T) {
+		require.False(t, terminology.Subsumes(snomedCode("195951007"), copd))
+	})
+	t.Run("different system", func(t *testing.T) {
+		require.False(t, terminology.Subsumes(copd, fhir.Coding{System: to.Ptr("http://loinc.org"), Code: to.Ptr("195951007")}))
+	})
+	t.Run("nil terminology only matches equal codes", func(t *testing.T) {
+		var terminology *Terminology
+		require.True(t, terminology.Subsumes(copd, copd))
+		require.False(t, terminology.Subsumes(copd, snomedCode("195951007")))
+	})
+}
+
+func TestTerminology_ValueSetContains(t *testing.T) {
+	terminology := testTerminology(t)
+
+	t.Run("is-a filter", func(t *testing.T) {
+		require.True(t, terminology.ValueSetContains("http://example.com/ValueSet/copd", snomedCode("13645005")))
+		require.True(t, terminology.ValueSetContains("http://example.com/ValueSet/copd", snomedCode("106001000119101")))
+	})
+	t.Run("excluded", func(t *testing.T) {
+		require.False(t, terminology.ValueSetContains("http://example.com/ValueSet/copd", snomedCode("313296004")))
+	})
+	t.Run("expansion", func(t *testing.T) {
+		require.True(t, terminology.ValueSetContains("http://example.com/ValueSet/heartfailure|1.0.0", snomedCode("84114007")))
+		require.False(t, terminology.ValueSetContains("http://example.com/ValueSet/heartfailure", snomedCode("13645005")))
+	})
+	t.Run("unknown ValueSet", func(t *testing.T) {
+		require.False(t, terminology.ValueSetContains("http://example.com/ValueSet/other", snomedCode("13645005")))
+	})
+}
+
+func TestTerminology_ConceptContainsCoding(t *testing.T) {
+	terminology := testTerminology(t)
+
+	t.Run("subtype of coding", func(t *testing.T) {
+		concept := fhir.CodeableConcept{Coding: []fhir.Coding{snomedCode("13645005")}}
+		require.True(t, terminology.ConceptContainsCoding(snomedCode("195951007"), concept))
+		require.False(t, terminology.ConceptContainsCoding(snomedCode("84114007"), concept))
+	})
+	t.Run("ValueSet reference", func(t *testing.T) {
+		concept := fhir.CodeableConcept{
+			Extension: []fhir.Extension{{Url: ValueSetReferenceExtensionURL, ValueUri: to.Ptr("http://example.com/ValueSet/heartfailure")}},
+		}
+		require.True(t, terminology.ConceptContainsCoding(snomedCode("84114007"), concept))
+		require.False(t, terminology.ConceptContainsCoding(snomedCode("13645005"), concept))
+	})
+}
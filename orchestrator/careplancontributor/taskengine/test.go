@@ -77,13 +77,13 @@ func (m TestWorkflowProvider) QuestionnaireLoader() QuestionnaireLoader {
 	return TestQuestionnaireLoader{}
 }
 
-func (m TestWorkflowProvider) Provide(_ context.Context, serviceCode fhir.Coding, conditionCode fhir.Coding) (*Workflow, error) {
+func (m TestWorkflowProvider) Provide(_ context.Context, serviceCode fhir.Coding, conditionCode fhir.Coding) ([]*Workflow, error) {
 	if serviceCode.System == nil || serviceCode.Code == nil || conditionCode.System == nil || conditionCode.Code == nil {
 		return nil, errors.New("serviceCode and conditionCode must have a system and code")
 	}
 	if workflows, ok := m[*serviceCode.System+"|"+*serviceCode.Code]; ok {
 		if workflow, ok := workflows[*conditionCode.System+"|"+*conditionCode.Code]; ok {
-			return &workflow, nil
+			return []*Workflow{&workflow}, nil
 		}
 		return nil, errors.Join(ErrWorkflowNotFound, fmt.Errorf("condition code does not match any conditions (service=%s|%s, condition=%s|%s)", *serviceCode.System, *serviceCode.Code, *conditionCode.System, *conditionCode.Code))
 	}
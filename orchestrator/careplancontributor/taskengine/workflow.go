@@ -2,6 +2,7 @@ package taskengine
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/SanteonNL/orca/orchestrator/lib/debug"
@@ -25,9 +26,9 @@ var tracer = baseotel.Tracer("careplancontributor")
 
 // WorkflowProvider provides workflows (a set of questionnaires required for accepting a Task) to the Task Filler.
 type WorkflowProvider interface {
-	// Provide returns the workflow for a given service and condition.
+	// Provide returns the candidate workflows for a given service and condition, from which SelectWorkflow chooses one.
 	// If no workflow is found, an error is returned.
-	Provide(ctx context.Context, serviceCode fhir.Coding, conditionCode fhir.Coding) (*Workflow, error)
+	Provide(ctx context.Context, serviceCode fhir.Coding, conditionCode fhir.Coding) ([]*Workflow, error)
 	QuestionnaireLoader() QuestionnaireLoader
 }
 
@@ -38,11 +39,14 @@ type FhirApiWorkflowProvider struct {
 	Client fhirclient.Client
 }
 
-// Provide returns the workflow for a given service and condition.
-// It looks up the workflow through FHIR HealthcareServices in the FHIR API, searching for instances that match:
+// Provide returns the candidate workflows for a given service and condition.
+// It looks up the workflows through FHIR HealthcareServices in the FHIR API, searching for instances that match:
 //   - Service code must be present in HealthcareService.category
 //   - Condition code must be present in the HealthcareService.type
-func (f FhirApiWorkflowProvider) Provide(ctx context.Context, serviceCode fhir.Coding, conditionCode fhir.Coding) (*Workflow, error) {
+//
+// A workflow is returned for every combination of matching HealthcareService and Questionnaire.
+// Codes are matched by the FHIR API's token search, so unlike MemoryWorkflowProvider it doesn't support matching through a Terminology.
+func (f FhirApiWorkflowProvider) Provide(ctx context.Context, serviceCode fhir.Coding, conditionCode fhir.Coding) ([]*Workflow, error) {
 	ctx, span := tracer.Start(
 		ctx,
 		debug.GetFullCallerName(),
@@ -61,7 +65,8 @@ func (f FhirApiWorkflowProvider) Provide(ctx context.Context, serviceCode fhir.C
 		return nil, otel.Error(span, err)
 	}
 
-	if err := f.searchHealthcareService(ctx, serviceCode, conditionCode); err != nil {
+	healthcareServices, err := f.searchHealthcareServices(ctx, serviceCode, conditionCode)
+	if err != nil {
 		return nil, otel.Error(span, err)
 	}
 
@@ -72,30 +77,37 @@ func (f FhirApiWorkflowProvider) Provide(ctx context.Context, serviceCode fhir.C
 	); err != nil {
 		return nil, otel.Error(span, err)
 	}
-	// TODO: Might want to support multiple questionnaires in future
-	if len(questionnaireBundle.Entry) != 1 {
-		err := errors.Join(ErrWorkflowNotFound, fmt.Errorf("expected 1 questionnaire, got %d", len(questionnaireBundle.Entry)))
-		return nil, otel.Error(span, err)
+	var questionnaires []fhir.Questionnaire
+	var questionnaireUrls []string
+	for _, entry := range questionnaireBundle.Entry {
+		if !coolfhir.EntryIsOfType("Questionnaire")(entry) {
+			continue
+		}
+		var questionnaire fhir.Questionnaire
+		if err := json.Unmarshal(entry.Resource, &questionnaire); err != nil {
+			return nil, otel.Error(span, fmt.Errorf("could not unmarshal questionnaire: %w", err))
+		}
+		questionnaires = append(questionnaires, questionnaire)
+		questionnaireUrls = append(questionnaireUrls, to.EmptyString(entry.FullUrl))
 	}
-
-	workflow := &Workflow{
-		Steps: []WorkflowStep{
-			{
-				QuestionnaireUrl: *questionnaireBundle.Entry[0].FullUrl,
-			},
-		},
+	if len(questionnaires) == 0 {
+		err := errors.Join(ErrWorkflowNotFound, errors.New("no questionnaires found"))
+		return nil, otel.Error(span, err)
 	}
+	workflows := newWorkflows(healthcareServices, func(healthcareService fhir.HealthcareService) string {
+		return f.Client.Path("HealthcareService", to.EmptyString(healthcareService.Id)).String()
+	}, questionnaires, questionnaireUrls)
 
 	span.SetAttributes(
-		attribute.Int("questionnaire.count", len(questionnaireBundle.Entry)),
-		attribute.String("questionnaire.url", *questionnaireBundle.Entry[0].FullUrl),
-		attribute.Int("workflow.steps", len(workflow.Steps)),
+		attribute.Int("questionnaire.count", len(questionnaires)),
+		attribute.Int("workflow.count", len(workflows)),
 	)
 	span.SetStatus(codes.Ok, "")
-	return workflow, nil
+	return workflows, nil
 }
 
-func (f FhirApiWorkflowProvider) searchHealthcareService(ctx context.Context, serviceCode fhir.Coding, conditionCode fhir.Coding) error {
+// searchHealthcareServices searches for the HealthcareServices offering the given service for the given condition.
+func (f FhirApiWorkflowProvider) searchHealthcareServices(ctx context.Context, serviceCode fhir.Coding, conditionCode fhir.Coding) ([]fhir.HealthcareService, error) {
 	ctx, span := tracer.Start(
 		ctx,
 		debug.GetFullCallerName(),
@@ -115,7 +127,7 @@ func (f FhirApiWorkflowProvider) searchHealthcareService(ctx context.Context, se
 	}
 	var results fhir.Bundle
 	if err := f.Client.ReadWithContext(ctx, "HealthcareService", &results, queryParams...); err != nil {
-		return otel.Error(span, err)
+		return nil, otel.Error(span, err)
 	}
 
 	span.SetAttributes(
@@ -123,15 +135,18 @@ func (f FhirApiWorkflowProvider) searchHealthcareService(ctx context.Context, se
 	)
 
 	if len(results.Entry) == 0 {
-		return otel.Error(span, ErrWorkflowNotFound, "no healthcare services found")
+		return nil, otel.Error(span, ErrWorkflowNotFound, "no healthcare services found")
 	}
-	if len(results.Entry) > 2 {
-		err := errors.Join(ErrWorkflowNotFound, errors.New("multiple workflows found"))
-		return otel.Error(span, err)
+	var healthcareServices []fhir.HealthcareService
+	if err := coolfhir.ResourcesInBundle(&results, coolfhir.EntryIsOfType("HealthcareService"), &healthcareServices); err != nil {
+		return nil, otel.Error(span, err)
+	}
+	if len(healthcareServices) == 0 {
+		return nil, otel.Error(span, ErrWorkflowNotFound, "no healthcare services found")
 	}
 
 	span.SetStatus(codes.Ok, "")
-	return nil
+	return healthcareServices, nil
 }
 
 func (f FhirApiWorkflowProvider) QuestionnaireLoader() QuestionnaireLoader {
@@ -146,6 +161,9 @@ var _ QuestionnaireLoader = &MemoryWorkflowProvider{}
 // MemoryWorkflowProvider is a WorkflowProvider that uses in-memory FHIR resources to provide workflows.
 // To use this provider, you must first load the resources using LoadBundle.
 type MemoryWorkflowProvider struct {
+	// Terminology is used to match service and condition codes, allowing HealthcareServices and Questionnaires
+	// to be offered for subtypes of their codes or for ValueSets. If nil, codes must be exactly equal.
+	Terminology        *Terminology
 	questionnaires     []fhir.Questionnaire
 	healthcareServices []fhir.HealthcareService
 }
@@ -199,7 +217,7 @@ func (e *MemoryWorkflowProvider) LoadBundle(ctx context.Context, bundleUrl strin
 	return nil
 }
 
-func (e *MemoryWorkflowProvider) Provide(ctx context.Context, serviceCode fhir.Coding, conditionCode fhir.Coding) (*Workflow, error) {
+func (e *MemoryWorkflowProvider) Provide(ctx context.Context, serviceCode fhir.Coding, conditionCode fhir.Coding) ([]*Workflow, error) {
 	ctx, span := tracer.Start(
 		ctx,
 		debug.GetFullCallerName(),
@@ -215,23 +233,24 @@ func (e *MemoryWorkflowProvider) Provide(ctx context.Context, serviceCode fhir.C
 	defer span.End()
 
 	// Mimicks Questionnaire and HealthcareService search like it's done in FhirApiWorkflowProvider, but just in-memory filtering.
-	supported := false
+	// A workflow is returned for every combination of matching HealthcareService and Questionnaire.
+	var healthcareServices []fhir.HealthcareService
 	for _, healthcareService := range e.healthcareServices {
-		if coolfhir.ConceptContainsCoding(serviceCode, healthcareService.Category...) && coolfhir.ConceptContainsCoding(conditionCode, healthcareService.Type...) {
-			supported = true
-			break
+		if e.Terminology.ConceptContainsCoding(serviceCode, healthcareService.Category...) && e.Terminology.ConceptContainsCoding(conditionCode, healthcareService.Type...) {
+			healthcareServices = append(healthcareServices, healthcareService)
 		}
 	}
 
 	span.SetAttributes(
 		attribute.Int("healthcare_services.total", len(e.healthcareServices)),
-		attribute.Bool("workflow.supported", supported),
+		attribute.Bool("workflow.supported", len(healthcareServices) > 0),
 	)
 
-	if !supported {
+	if len(healthcareServices) == 0 {
 		return nil, otel.Error(span, ErrWorkflowNotFound, "Workflow not supported by any healthcare service")
 	}
 
+	var questionnaires []fhir.Questionnaire
 	for _, questionnaire := range e.questionnaires {
 		matchesServiceCode := false
 		matchesConditionCode := false
@@ -239,37 +258,60 @@ func (e *MemoryWorkflowProvider) Provide(ctx context.Context, serviceCode fhir.C
 			if usageContext.ValueCodeableConcept == nil {
 				continue
 			}
-			if coolfhir.ConceptContainsCoding(serviceCode, *usageContext.ValueCodeableConcept) {
+			if e.Terminology.ConceptContainsCoding(serviceCode, *usageContext.ValueCodeableConcept) {
 				matchesServiceCode = true
 			}
-			if coolfhir.ConceptContainsCoding(conditionCode, *usageContext.ValueCodeableConcept) {
+			if e.Terminology.ConceptContainsCoding(conditionCode, *usageContext.ValueCodeableConcept) {
 				matchesConditionCode = true
 			}
 		}
 		if matchesServiceCode && matchesConditionCode {
-			workflow := &Workflow{
-				Steps: []WorkflowStep{
-					{
-						QuestionnaireUrl: "Questionnaire/" + *questionnaire.Id,
-					},
-				},
-			}
-
-			span.SetAttributes(
-				attribute.Int("questionnaires.total", len(e.questionnaires)),
-				attribute.String("questionnaire.id", to.EmptyString(questionnaire.Id)),
-				attribute.String("questionnaire.url", "Questionnaire/"+to.EmptyString(questionnaire.Id)),
-				attribute.Int("workflow.steps", len(workflow.Steps)),
-			)
-			span.SetStatus(codes.Ok, "")
-			return workflow, nil
+			questionnaires = append(questionnaires, questionnaire)
 		}
 	}
 
 	span.SetAttributes(
 		attribute.Int("questionnaires.total", len(e.questionnaires)),
 	)
-	return nil, otel.Error(span, ErrWorkflowNotFound, "No matching questionnaire found")
+	if len(questionnaires) == 0 {
+		return nil, otel.Error(span, ErrWorkflowNotFound, "No matching questionnaire found")
+	}
+	var questionnaireUrls []string
+	for _, questionnaire := range questionnaires {
+		questionnaireUrls = append(questionnaireUrls, "Questionnaire/"+to.EmptyString(questionnaire.Id))
+	}
+	workflows := newWorkflows(healthcareServices, func(healthcareService fhir.HealthcareService) string {
+		return "HealthcareService/" + to.EmptyString(healthcareService.Id)
+	}, questionnaires, questionnaireUrls)
+
+	span.SetAttributes(
+		attribute.Int("workflow.count", len(workflows)),
+	)
+	span.SetStatus(codes.Ok, "")
+	return workflows, nil
+}
+
+// newWorkflows returns a workflow for every combination of the given HealthcareServices and Questionnaires (with their URLs),
+// leaving the choice between them to SelectWorkflow.
+func newWorkflows(healthcareServices []fhir.HealthcareService, healthcareServiceUrl func(fhir.HealthcareService) string,
+	questionnaires []fhir.Questionnaire, questionnaireUrls []string) []*Workflow {
+	var result []*Workflow
+	for _, healthcareService := range healthcareServices {
+		for i, questionnaire := range questionnaires {
+			result = append(result, &Workflow{
+				Steps: []WorkflowStep{
+					{
+						QuestionnaireUrl:       questionnaireUrls[i],
+						QuestionnaireCanonical: to.EmptyString(questionnaire.Url),
+					},
+				},
+				HealthcareService: healthcareServiceUrl(healthcareService),
+				Specialties:       healthcareService.Specialty,
+				Priority:          workflowPriority(healthcareService.Extension, questionnaire.Extension),
+			})
+		}
+	}
+	return result
 }
 
 func (e *MemoryWorkflowProvider) Load(ctx context.Context, questionnaireUrl string) (*fhir.Questionnaire, error) {
@@ -309,6 +351,35 @@ func (e *MemoryWorkflowProvider) QuestionnaireLoader() QuestionnaireLoader {
 
 type Workflow struct {
 	Steps []WorkflowStep
+	// HealthcareService is the reference to the HealthcareService that offers the workflow, if known.
+	HealthcareService string
+	// Specialties contains the specialties of the HealthcareService that offers the workflow,
+	// which are matched against Task.performerType when selecting a workflow.
+	Specialties []fhir.CodeableConcept
+	// Priority is used to choose between multiple workflows matching a Task: the workflow with the highest priority is chosen.
+	// It's taken from the workflow-priority extension on the Questionnaire, or if not present, on the HealthcareService.
+	Priority int
+}
+
+// References returns whether the given reference (literal reference or canonical URL) refers to the workflow's HealthcareService or one of its Questionnaires.
+func (w Workflow) References(reference string) bool {
+	if reference == "" {
+		return false
+	}
+	candidates := []string{w.HealthcareService}
+	for _, step := range w.Steps {
+		candidates = append(candidates, step.QuestionnaireUrl, step.QuestionnaireCanonical)
+	}
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+		// Relative references (e.g. Questionnaire/123) match absolute references to the same resource
+		if candidate == reference || strings.HasSuffix(candidate, "/"+reference) || strings.HasSuffix(reference, "/"+candidate) {
+			return true
+		}
+	}
+	return false
 }
 
 func (w Workflow) Start() WorkflowStep {
@@ -330,4 +401,6 @@ func (w Workflow) Proceed(previousQuestionnaireRef string) (*WorkflowStep, error
 
 type WorkflowStep struct {
 	QuestionnaireUrl string
+	// QuestionnaireCanonical is the canonical URL (Questionnaire.url) of the questionnaire, if known.
+	QuestionnaireCanonical string
 }
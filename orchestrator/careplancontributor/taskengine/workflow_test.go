@@ -2,6 +2,9 @@ package taskengine
 
 import (
 	"context"
+	"encoding/json"
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/lib/must"
 	"github.com/SanteonNL/orca/orchestrator/lib/to"
 	"github.com/stretchr/testify/require"
 	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
@@ -48,9 +51,10 @@ func TestMemoryFHIRResourcesWorkflowProvider_Provide(t *testing.T) {
 
 	t.Run("provide workflow", func(t *testing.T) {
 		t.Run("ok", func(t *testing.T) {
-			workflow, err := provider.Provide(context.Background(), serviceCode, conditionCode)
+			workflows, err := provider.Provide(context.Background(), serviceCode, conditionCode)
 			require.NoError(t, err)
-			require.NotNil(t, workflow)
+			require.Len(t, workflows, 1)
+			workflow := workflows[0]
 
 			t.Run("proceed, no more steps", func(t *testing.T) {
 				step := workflow.Start()
@@ -75,16 +79,109 @@ func TestMemoryFHIRResourcesWorkflowProvider_Provide(t *testing.T) {
 			_, err := provider.Provide(context.Background(), serviceCode, conditionCode)
 			require.ErrorIs(t, err, ErrWorkflowNotFound)
 		})
+		t.Run("condition subtype, matched through terminology", func(t *testing.T) {
+			terminology := &Terminology{
+				parents: map[string][]string{
+					// Heart failure with reduced ejection fraction is-a Heart failure
+					"http://snomed.info/sct|703272007": {"http://snomed.info/sct|84114007"},
+				},
+			}
+			conditionCode := fhir.Coding{
+				System: to.Ptr("http://snomed.info/sct"),
+				Code:   to.Ptr("703272007"),
+			}
+			providerWithTerminology := &MemoryWorkflowProvider{
+				Terminology:        terminology,
+				questionnaires:     provider.questionnaires,
+				healthcareServices: provider.healthcareServices,
+			}
+
+			workflows, err := providerWithTerminology.Provide(context.Background(), serviceCode, conditionCode)
+			require.NoError(t, err)
+			require.Len(t, workflows, 1)
+			require.NotEmpty(t, workflows[0].HealthcareService)
+
+			_, err = provider.Provide(context.Background(), serviceCode, conditionCode)
+			require.ErrorIs(t, err, ErrWorkflowNotFound, "without terminology, codes must be equal")
+		})
+		t.Run("HealthcareServices with same priority, selected by performerType", func(t *testing.T) {
+			healthcareService := func(id string, specialty string) fhir.HealthcareService {
+				return fhir.HealthcareService{
+					Id:        to.Ptr(id),
+					Category:  []fhir.CodeableConcept{{Coding: []fhir.Coding{serviceCode}}},
+					Type:      []fhir.CodeableConcept{{Coding: []fhir.Coding{conditionCode}}},
+					Specialty: []fhir.CodeableConcept{{Coding: []fhir.Coding{{System: to.Ptr("http://snomed.info/sct"), Code: to.Ptr(specialty)}}}},
+				}
+			}
+			providerWithSpecialties := &MemoryWorkflowProvider{
+				questionnaires: provider.questionnaires,
+				healthcareServices: []fhir.HealthcareService{
+					healthcareService("cardiology", "394579002"),
+					healthcareService("pulmonology", "418112009"),
+				},
+			}
+
+			workflows, err := providerWithSpecialties.Provide(context.Background(), serviceCode, conditionCode)
+			require.NoError(t, err)
+			require.Len(t, workflows, 2)
+
+			task := fhir.Task{
+				PerformerType: []fhir.CodeableConcept{{Coding: []fhir.Coding{{System: to.Ptr("http://snomed.info/sct"), Code: to.Ptr("418112009")}}}},
+			}
+			workflow, err := SelectWorkflow(task, workflows)
+			require.NoError(t, err)
+			require.Equal(t, "HealthcareService/pulmonology", workflow.HealthcareService)
+
+			_, err = SelectWorkflow(fhir.Task{}, workflows)
+			require.ErrorIs(t, err, ErrMultipleWorkflows, "without performerType, none takes precedence")
+		})
 	})
 	t.Run("load questionnaire", func(t *testing.T) {
 		t.Run("ok", func(t *testing.T) {
-			workflow, err := provider.Provide(context.Background(), serviceCode, conditionCode)
+			workflows, err := provider.Provide(context.Background(), serviceCode, conditionCode)
 			require.NoError(t, err)
-			require.NotNil(t, workflow)
+			require.Len(t, workflows, 1)
 
-			questionnaire, err := provider.QuestionnaireLoader().Load(context.Background(), workflow.Start().QuestionnaireUrl)
+			questionnaire, err := provider.QuestionnaireLoader().Load(context.Background(), workflows[0].Start().QuestionnaireUrl)
 			require.NoError(t, err)
 			require.NotNil(t, questionnaire)
 		})
 	})
 }
+
+func TestFhirApiWorkflowProvider_Provide(t *testing.T) {
+	healthcareService := func(id string) fhir.HealthcareService {
+		return fhir.HealthcareService{Id: to.Ptr(id)}
+	}
+	httpServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		var bundle fhir.Bundle
+		switch request.URL.Path {
+		case "/HealthcareService":
+			for _, id := range []string{"hs1", "hs2", "hs3"} {
+				bundle.Entry = append(bundle.Entry, fhir.BundleEntry{Resource: must.MarshalJSON(healthcareService(id))})
+			}
+		case "/Questionnaire":
+			bundle.Entry = append(bundle.Entry, fhir.BundleEntry{
+				FullUrl:  to.Ptr("http://example.com/fhir/Questionnaire/q1"),
+				Resource: must.MarshalJSON(fhir.Questionnaire{Id: to.Ptr("q1"), Url: to.Ptr("http://example.com/Questionnaire/q1")}),
+			})
+		default:
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/fhir+json")
+		_ = json.NewEncoder(writer).Encode(bundle)
+	}))
+	defer httpServer.Close()
+	provider := FhirApiWorkflowProvider{
+		Client: fhirclient.New(must.ParseURL(httpServer.URL), httpServer.Client(), nil),
+	}
+	serviceCode := fhir.Coding{System: to.Ptr("http://snomed.info/sct"), Code: to.Ptr("719858009")}
+	conditionCode := fhir.Coding{System: to.Ptr("http://snomed.info/sct"), Code: to.Ptr("84114007")}
+
+	workflows, err := provider.Provide(context.Background(), serviceCode, conditionCode)
+
+	require.NoError(t, err)
+	require.Len(t, workflows, 3, "all matching HealthcareServices are candidates")
+	require.Equal(t, httpServer.URL+"/HealthcareService/hs3", workflows[2].HealthcareService)
+}
@@ -51,7 +51,8 @@ func (i internalDispatchHTTPRoundTripper) RoundTrip(request *http.Request) (*htt
 		return nil, errors.New("expected exactly one identity")
 	}
 
-	ctx := auth.WithPrincipal(request.Context(), auth.Principal{Organization: identities[0]})
+	// The request is made on behalf of the local organization, and its logged-in user (if any).
+	ctx := auth.WithPrincipal(request.Context(), auth.Principal{Organization: identities[0], User: auth.UserFromContext(request.Context())})
 	request = request.WithContext(ctx)
 	if i.requestVisitor != nil {
 		i.requestVisitor(request)
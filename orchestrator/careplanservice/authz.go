@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 	"net/url"
+	"time"
 
 	"github.com/SanteonNL/orca/orchestrator/cmd/profile"
 	"github.com/SanteonNL/orca/orchestrator/lib/auth"
@@ -113,6 +114,9 @@ type RelatedResourcePolicy[T any, R any] struct {
 	// If the resource lacks a reference to the related resource, this function should return nil for searchParams.
 	// In that case, the policy will deny access.
 	relatedResourceSearchParams func(ctx context.Context, resource T) (resourceType string, searchParams url.Values)
+	// isRelatedResource is a function that returns whether the given related resource is related to the given resource.
+	// If set, access to multiple resources is decided using a single search for the related resources (see HasAccessBatch).
+	isRelatedResource func(resource T, related R) bool
 }
 
 func (r RelatedResourcePolicy[T, R]) HasAccess(ctx context.Context, resource T, principal auth.Principal) (*PolicyDecision, error) {
@@ -123,38 +127,24 @@ func (r RelatedResourcePolicy[T, R]) HasAccess(ctx context.Context, resource T,
 			Reasons: []string{"RelatedResourcePolicy: no related resource search parameters"},
 		}, nil
 	}
-	searchHandler := FHIRSearchOperationHandler[R]{
-		fhirClientFactory: r.fhirClientFactory,
-		authzPolicy:       r.relatedResourcePolicy,
-	}
 	const maxIterations = 100
 	for i := 0; i < maxIterations; i++ {
-		results, searchSet, policyDecisions, err := searchHandler.searchAndFilter(ctx, searchParams, &principal, resourceType)
+		page, err := r.searchRelatedResources(ctx, resourceType, searchParams, principal)
 		if err != nil {
-			return nil, fmt.Errorf("related resource search (related resource type=%s): %w", resourceType, err)
+			return nil, err
 		}
-		if len(results) > 0 {
+		if len(page.resources) > 0 {
 			// found a related resource the user has access to, grant access
 			return &PolicyDecision{
 				Allowed: true,
-				Reasons: append([]string{"RelatedResourcePolicy: access to related resource(s)"}, policyDecisions[0].Reasons...),
+				Reasons: append([]string{"RelatedResourcePolicy: access to related resource(s)"}, page.decisions[0].Reasons...),
 			}, nil
 		}
 		// Try next page of search results if there is one
-		hasNext := false
-		for _, link := range searchSet.Link {
-			if link.Relation == "next" {
-				nextURL, err := url.Parse(link.Url)
-				if err != nil {
-					return nil, fmt.Errorf("invalid 'next' link for search set: %w", err)
-				}
-				searchParams = nextURL.Query()
-				hasNext = true
-			}
-		}
-		if !hasNext {
+		if page.next == nil {
 			break
 		}
+		searchParams = page.next
 		// Make sure we don't loop endlessly due to a bug in ORCA or the FHIR server
 		if i == maxIterations-1 {
 			return nil, fmt.Errorf("max. search iterations reached (%d), possible bug", maxIterations)
@@ -166,6 +156,47 @@ func (r RelatedResourcePolicy[T, R]) HasAccess(ctx context.Context, resource T,
 	}, nil
 }
 
+// relatedResourcesPage is a page of related resources the principal has access to.
+type relatedResourcesPage[R any] struct {
+	resources []R
+	decisions []PolicyDecision
+	// next contains the search parameters of the next page, or nil if there is none.
+	next url.Values
+}
+
+// searchRelatedResources searches a page of related resources, and filters them on whether the principal has access to them.
+// Results are cached for the duration of the request (see withAuthzCache).
+func (r RelatedResourcePolicy[T, R]) searchRelatedResources(ctx context.Context, resourceType string, searchParams url.Values, principal auth.Principal) (*relatedResourcesPage[R], error) {
+	cacheKey := principal.ID() + "@" + resourceType + "?" + searchParams.Encode()
+	cache := authzCacheFromContext(ctx)
+	if cached, ok := cache.get(cacheKey).(*relatedResourcesPage[R]); ok {
+		return cached, nil
+	}
+	searchHandler := FHIRSearchOperationHandler[R]{
+		fhirClientFactory: r.fhirClientFactory,
+		authzPolicy:       r.relatedResourcePolicy,
+	}
+	results, searchSet, policyDecisions, err := searchHandler.searchAndFilter(ctx, searchParams, &principal, resourceType)
+	if err != nil {
+		return nil, fmt.Errorf("related resource search (related resource type=%s): %w", resourceType, err)
+	}
+	page := &relatedResourcesPage[R]{
+		resources: results,
+		decisions: policyDecisions,
+	}
+	for _, link := range searchSet.Link {
+		if link.Relation == "next" {
+			nextURL, err := url.Parse(link.Url)
+			if err != nil {
+				return nil, fmt.Errorf("invalid 'next' link for search set: %w", err)
+			}
+			page.next = nextURL.Query()
+		}
+	}
+	cache.put(cacheKey, page)
+	return page, nil
+}
+
 var _ Policy[*fhir.Task] = &TaskOwnerOrRequesterPolicy[fhir.Task]{}
 
 // TaskOwnerOrRequesterPolicy is a policy that allows access if the user is the owner of the task or the requester of the task.
@@ -200,6 +231,9 @@ var _ Policy[*fhir.CarePlan] = &CareTeamMemberPolicy[fhir.CarePlan]{}
 
 // CareTeamMemberPolicy is a policy that allows access if the user is a member of the care team.
 type CareTeamMemberPolicy[T fhir.CarePlan] struct {
+	// activeMembersOnly makes the policy deny access to principals whose CareTeam membership has ended (or not yet started).
+	// By default, former CareTeam members keep access.
+	activeMembersOnly bool
 }
 
 func (c CareTeamMemberPolicy[T]) HasAccess(ctx context.Context, resource *T, principal auth.Principal) (*PolicyDecision, error) {
@@ -207,7 +241,7 @@ func (c CareTeamMemberPolicy[T]) HasAccess(ctx context.Context, resource *T, pri
 	if !ok {
 		return nil, fmt.Errorf("resource is not a CarePlan")
 	}
-	careTeam, err := coolfhir.CareTeamFromCarePlan(carePlan)
+	careTeam, err := authzCacheFromContext(ctx).careTeamFromCarePlan(carePlan)
 	// INT-630: We changed CareTeam to be contained within the CarePlan, but old test data in the CarePlan resource does not have CareTeam.
 	//          For temporary backwards compatibility, ignore these CarePlans. It can be removed when old data has been purged.
 	if err != nil {
@@ -221,6 +255,18 @@ func (c CareTeamMemberPolicy[T]) HasAccess(ctx context.Context, resource *T, pri
 			Reasons: []string{"CareTeamMemberPolicy: unable to derive CareTeam from CarePlan"},
 		}, nil
 	}
+	if c.activeMembersOnly {
+		if isActiveCareTeamMember(principal, careTeam, time.Now()) {
+			return &PolicyDecision{
+				Allowed: true,
+				Reasons: []string{"CareTeamMemberPolicy: principal is active member of CareTeam"},
+			}, nil
+		}
+		return &PolicyDecision{
+			Allowed: false,
+			Reasons: []string{"CareTeamMemberPolicy: principal is not an active member of CareTeam"},
+		}, nil
+	}
 	if validatePrincipalInCareTeam(principal, careTeam) == nil {
 		return &PolicyDecision{
 			Allowed: true,
@@ -233,6 +279,19 @@ func (c CareTeamMemberPolicy[T]) HasAccess(ctx context.Context, resource *T, pri
 	}, nil
 }
 
+// isActiveCareTeamMember returns whether the principal is a participant of the CareTeam with a period that includes the given time.
+func isActiveCareTeamMember(principal auth.Principal, careTeam *fhir.CareTeam, now time.Time) bool {
+	for _, participant := range careTeam.Participant {
+		if participant.Member == nil || participant.Member.Identifier == nil || !coolfhir.HasIdentifier(*participant.Member.Identifier, principal.Organization.Identifier...) {
+			continue
+		}
+		if active, _ := coolfhir.ValidateCareTeamParticipantPeriod(participant, now); active {
+			return true
+		}
+	}
+	return false
+}
+
 // AnyonePolicy is a policy that allows access to anyone.
 type AnyonePolicy[T any] struct {
 }
@@ -272,3 +331,38 @@ func (o CreatorPolicy[T]) HasAccess(ctx context.Context, resource T, principal a
 }
 
 var _ Policy[fhir.HasExtension] = &CreatorPolicy[fhir.HasExtension]{}
+
+var _ Policy[any] = &UserRolePolicy[any]{}
+
+// UserRolePolicy is a policy that allows access if the principal's user has one of the given roles, and the wrapped policy allows access.
+// It denies access if the principal has no (known) user, e.g. when the access token doesn't contain an employee credential.
+type UserRolePolicy[T any] struct {
+	Roles  []fhir.Coding
+	Policy Policy[T]
+}
+
+func (u UserRolePolicy[T]) HasAccess(ctx context.Context, resource T, principal auth.Principal) (*PolicyDecision, error) {
+	if principal.User == nil {
+		return &PolicyDecision{
+			Allowed: false,
+			Reasons: []string{"UserRolePolicy: principal has no user"},
+		}, nil
+	}
+	for _, role := range u.Roles {
+		if !principal.User.HasRole(role) {
+			continue
+		}
+		decision, err := u.Policy.HasAccess(ctx, resource, principal)
+		if err != nil {
+			return nil, err
+		}
+		return &PolicyDecision{
+			Allowed: decision.Allowed,
+			Reasons: append([]string{"UserRolePolicy: user has role " + to.EmptyString(role.System) + "|" + to.EmptyString(role.Code)}, decision.Reasons...),
+		}, nil
+	}
+	return &PolicyDecision{
+		Allowed: false,
+		Reasons: []string{"UserRolePolicy: user doesn't have any of the required roles"},
+	}, nil
+}
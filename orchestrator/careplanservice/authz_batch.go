@@ -0,0 +1,194 @@
+package careplanservice
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+)
+
+// BatchPolicy is a Policy that can decide on access to multiple resources at once,
+// e.g. to look up the related resources of a whole page of search results in a single FHIR search.
+type BatchPolicy[T any] interface {
+	Policy[T]
+	// HasAccessBatch decides on access to the given resources. The returned decisions are in the same order as the resources.
+	HasAccessBatch(ctx context.Context, resources []T, principal auth.Principal) ([]*PolicyDecision, error)
+}
+
+var _ BatchPolicy[any] = &AnyMatchPolicy[any]{}
+var _ BatchPolicy[any] = &RelatedResourcePolicy[any, any]{}
+
+// hasAccessBatch decides on access to the given resources, using HasAccessBatch if the policy is a BatchPolicy.
+// Otherwise, the policy is evaluated for each resource.
+func hasAccessBatch[T any](ctx context.Context, policy Policy[T], resources []T, principal auth.Principal) ([]*PolicyDecision, error) {
+	if batchPolicy, ok := policy.(BatchPolicy[T]); ok {
+		return batchPolicy.HasAccessBatch(ctx, resources, principal)
+	}
+	results := make([]*PolicyDecision, len(resources))
+	for i, resource := range resources {
+		decision, err := policy.HasAccess(ctx, resource, principal)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = decision
+	}
+	return results, nil
+}
+
+func (e AnyMatchPolicy[T]) HasAccessBatch(ctx context.Context, resources []T, principal auth.Principal) ([]*PolicyDecision, error) {
+	results := make([]*PolicyDecision, len(resources))
+	// undecided contains the indices of the resources to which no policy granted access (yet)
+	undecided := make([]int, len(resources))
+	for i := range resources {
+		undecided[i] = i
+	}
+	for _, policy := range e.Policies {
+		if len(undecided) == 0 {
+			break
+		}
+		subset := make([]T, len(undecided))
+		for i, idx := range undecided {
+			subset[i] = resources[idx]
+		}
+		decisions, err := hasAccessBatch(ctx, policy, subset, principal)
+		if err != nil {
+			return nil, err
+		}
+		var stillUndecided []int
+		for i, idx := range undecided {
+			if decisions[i].Allowed {
+				results[idx] = &PolicyDecision{
+					Allowed: true,
+					Reasons: append([]string{"AnyMatchPolicy"}, decisions[i].Reasons...),
+				}
+			} else {
+				stillUndecided = append(stillUndecided, idx)
+			}
+		}
+		undecided = stillUndecided
+	}
+	for _, idx := range undecided {
+		results[idx] = &PolicyDecision{Allowed: false, Reasons: []string{"AnyMatchPolicy: none match"}}
+	}
+	return results, nil
+}
+
+// HasAccessBatch decides on access to the given resources by searching the related resources of all resources at once,
+// combining the values of their search parameters (e.g. _id=a,b,c). It falls back to evaluating each resource separately
+// if the policy can't match related resources to resources, or if the search parameters of the resources can't be combined.
+func (r RelatedResourcePolicy[T, R]) HasAccessBatch(ctx context.Context, resources []T, principal auth.Principal) ([]*PolicyDecision, error) {
+	results := make([]*PolicyDecision, len(resources))
+	var pending []int
+	var searchParamsPerResource []url.Values
+	var relatedResourceType string
+	for i, resource := range resources {
+		resourceType, searchParams := r.relatedResourceSearchParams(ctx, resource)
+		if searchParams == nil {
+			results[i] = &PolicyDecision{
+				Allowed: false,
+				Reasons: []string{"RelatedResourcePolicy: no related resource search parameters"},
+			}
+			continue
+		}
+		if relatedResourceType != "" && relatedResourceType != resourceType {
+			return r.hasAccessForEach(ctx, resources, principal)
+		}
+		relatedResourceType = resourceType
+		pending = append(pending, i)
+		searchParamsPerResource = append(searchParamsPerResource, searchParams)
+	}
+	if len(pending) == 0 {
+		return results, nil
+	}
+	searchParams := combineSearchParams(searchParamsPerResource)
+	if r.isRelatedResource == nil || searchParams == nil {
+		return r.hasAccessForEach(ctx, resources, principal)
+	}
+
+	const maxIterations = 100
+	for i := 0; i < maxIterations && len(pending) > 0; i++ {
+		page, err := r.searchRelatedResources(ctx, relatedResourceType, searchParams, principal)
+		if err != nil {
+			return nil, err
+		}
+		var stillPending []int
+		for _, idx := range pending {
+			for j, related := range page.resources {
+				if r.isRelatedResource(resources[idx], related) {
+					results[idx] = &PolicyDecision{
+						Allowed: true,
+						Reasons: append([]string{"RelatedResourcePolicy: access to related resource(s)"}, page.decisions[j].Reasons...),
+					}
+					break
+				}
+			}
+			if results[idx] == nil {
+				stillPending = append(stillPending, idx)
+			}
+		}
+		pending = stillPending
+		if page.next == nil {
+			break
+		}
+		searchParams = page.next
+		// Make sure we don't loop endlessly due to a bug in ORCA or the FHIR server
+		if i == maxIterations-1 && len(pending) > 0 {
+			return nil, fmt.Errorf("max. search iterations reached (%d), possible bug", maxIterations)
+		}
+	}
+	for _, idx := range pending {
+		results[idx] = &PolicyDecision{
+			Allowed: false,
+			Reasons: []string{"RelatedResourcePolicy: no access to related resource(s)"},
+		}
+	}
+	return results, nil
+}
+
+func (r RelatedResourcePolicy[T, R]) hasAccessForEach(ctx context.Context, resources []T, principal auth.Principal) ([]*PolicyDecision, error) {
+	results := make([]*PolicyDecision, len(resources))
+	for i, resource := range resources {
+		decision, err := r.HasAccess(ctx, resource, principal)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = decision
+	}
+	return results, nil
+}
+
+// combineSearchParams combines the given search parameters into a single search, by OR-ing the values of the one parameter that differs.
+// It returns nil if the search parameters can't be combined, e.g. because they have different parameters, or more than one parameter differs.
+func combineSearchParams(searchParams []url.Values) url.Values {
+	result := url.Values{}
+	var combinedParam string
+	for name, values := range searchParams[0] {
+		result[name] = slices.Clone(values)
+	}
+	for _, curr := range searchParams[1:] {
+		if len(curr) != len(result) {
+			return nil
+		}
+		for name, values := range curr {
+			existing, ok := result[name]
+			if !ok {
+				return nil
+			}
+			if slices.Equal(existing, values) && name != combinedParam {
+				continue
+			}
+			if combinedParam != "" && combinedParam != name {
+				return nil
+			}
+			combinedParam = name
+			for _, value := range values {
+				if !slices.Contains(result[name], value) {
+					result[name] = append(result[name], value)
+				}
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,132 @@
+package careplanservice
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/careplancontributor/mock"
+	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/must"
+	"github.com/SanteonNL/orca/orchestrator/lib/test"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRelatedResourcePolicy_HasAccessBatch(t *testing.T) {
+	carePlan := func(id string, member fhir.Identifier) fhir.CarePlan {
+		return fhir.CarePlan{
+			Id:       to.Ptr(id),
+			CareTeam: []fhir.Reference{{Type: to.Ptr("CareTeam"), Reference: to.Ptr("#ct")}},
+			Contained: must.MarshalJSON([]fhir.CareTeam{
+				{
+					Id: to.Ptr("ct"),
+					Participant: []fhir.CareTeamParticipant{
+						{Member: &fhir.Reference{Type: to.Ptr("Organization"), Identifier: &member}},
+					},
+				},
+			}),
+		}
+	}
+	carePlan1 := carePlan("cp1", auth.TestPrincipal1.Organization.Identifier[0])
+	carePlan2 := carePlan("cp2", auth.TestPrincipal2.Organization.Identifier[0])
+	task := func(id string, carePlanIDs ...string) *fhir.Task {
+		result := &fhir.Task{Id: to.Ptr(id)}
+		for _, carePlanID := range carePlanIDs {
+			result.BasedOn = append(result.BasedOn, fhir.Reference{Reference: to.Ptr("CarePlan/" + carePlanID)})
+		}
+		return result
+	}
+	tasks := []*fhir.Task{task("t1", "cp1"), task("t2", "cp2"), task("t3", "cp1"), task("t4")}
+	stubClient := &test.StubFHIRClient{Resources: []any{carePlan1, carePlan2}}
+	expectSearch := func(t *testing.T) (*mock.MockClient, *gomock.Call) {
+		fhirClient := mock.NewMockClient(gomock.NewController(t))
+		call := fhirClient.EXPECT().SearchWithContext(gomock.Any(), "CarePlan", gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, resourceType string, query url.Values, target any, opts ...fhirclient.Option) error {
+				return stubClient.SearchWithContext(ctx, resourceType, query, target, opts...)
+			})
+		return fhirClient, call
+	}
+
+	t.Run("single search for all resources", func(t *testing.T) {
+		fhirClient, call := expectSearch(t)
+		call.Times(1).Do(func(_ context.Context, _ string, query url.Values, _ any, _ ...fhirclient.Option) {
+			require.Equal(t, "cp1,cp2", query.Get("_id"))
+		})
+		policy := ReadTaskAuthzPolicy(FHIRClientFactoryFor(fhirClient)).(AnyMatchPolicy[*fhir.Task]).Policies[1].(RelatedResourcePolicy[*fhir.Task, *fhir.CarePlan])
+
+		decisions, err := policy.HasAccessBatch(context.Background(), tasks, *auth.TestPrincipal1)
+
+		require.NoError(t, err)
+		require.Len(t, decisions, 4)
+		require.True(t, decisions[0].Allowed)
+		require.False(t, decisions[1].Allowed)
+		require.True(t, decisions[2].Allowed)
+		require.False(t, decisions[3].Allowed)
+		require.Equal(t, []string{"RelatedResourcePolicy: no related resource search parameters"}, decisions[3].Reasons)
+	})
+	t.Run("via AnyMatchPolicy", func(t *testing.T) {
+		fhirClient, call := expectSearch(t)
+		call.Times(1)
+		policy := ReadTaskAuthzPolicy(FHIRClientFactoryFor(fhirClient))
+
+		decisions, err := hasAccessBatch(context.Background(), policy, tasks, *auth.TestPrincipal1)
+
+		require.NoError(t, err)
+		require.True(t, decisions[0].Allowed)
+		require.Equal(t, "AnyMatchPolicy", decisions[0].Reasons[0])
+		require.False(t, decisions[1].Allowed)
+		require.Equal(t, []string{"AnyMatchPolicy: none match"}, decisions[1].Reasons)
+	})
+	t.Run("lookups are cached within a request", func(t *testing.T) {
+		fhirClient, call := expectSearch(t)
+		call.Times(1)
+		policy := ReadTaskAuthzPolicy(FHIRClientFactoryFor(fhirClient))
+		ctx := withAuthzCache(context.Background())
+
+		decision, err := policy.HasAccess(ctx, tasks[0], *auth.TestPrincipal1)
+		require.NoError(t, err)
+		require.True(t, decision.Allowed)
+		decision, err = policy.HasAccess(ctx, tasks[2], *auth.TestPrincipal1)
+		require.NoError(t, err)
+		require.True(t, decision.Allowed)
+	})
+	t.Run("without cache, each lookup is performed", func(t *testing.T) {
+		fhirClient, call := expectSearch(t)
+		call.Times(2)
+		policy := ReadTaskAuthzPolicy(FHIRClientFactoryFor(fhirClient))
+
+		_, err := policy.HasAccess(context.Background(), tasks[0], *auth.TestPrincipal1)
+		require.NoError(t, err)
+		_, err = policy.HasAccess(context.Background(), tasks[2], *auth.TestPrincipal1)
+		require.NoError(t, err)
+	})
+}
+
+func TestCombineSearchParams(t *testing.T) {
+	t.Run("one differing parameter", func(t *testing.T) {
+		result := combineSearchParams([]url.Values{
+			{"subject": {"Patient/1"}, "status": {"active"}},
+			{"subject": {"Patient/2"}, "status": {"active"}},
+			{"subject": {"Patient/1"}, "status": {"active"}},
+		})
+		require.Equal(t, url.Values{"subject": {"Patient/1", "Patient/2"}, "status": {"active"}}, result)
+	})
+	t.Run("multiple differing parameters", func(t *testing.T) {
+		result := combineSearchParams([]url.Values{
+			{"subject": {"Patient/1"}, "status": {"active"}},
+			{"subject": {"Patient/2"}, "status": {"completed"}},
+		})
+		require.Nil(t, result)
+	})
+	t.Run("different parameters", func(t *testing.T) {
+		result := combineSearchParams([]url.Values{
+			{"subject": {"Patient/1"}},
+			{"_id": {"1"}},
+		})
+		require.Nil(t, result)
+	})
+}
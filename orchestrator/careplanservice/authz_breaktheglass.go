@@ -0,0 +1,317 @@
+package careplanservice
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/lib/audit"
+	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/logging"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+// PurposeOfUseHeader is the HTTP request header that specifies the purpose of use of the request.
+// Break-the-glass access is requested by specifying BTG.
+const PurposeOfUseHeader = "X-Scp-Purpose-Of-Use"
+
+// JustificationHeader is the HTTP request header that contains the justification of break-the-glass access.
+const JustificationHeader = "X-Scp-Justification"
+
+type breakTheGlassContextKeyType struct{}
+
+var breakTheGlassContextKey = breakTheGlassContextKeyType{}
+
+// breakTheGlassRequest is the break-the-glass request of a principal, which collects the CarePlans it granted access to.
+type breakTheGlassRequest struct {
+	justification string
+	mux           sync.Mutex
+	carePlans     []*fhir.CarePlan
+}
+
+func (r *breakTheGlassRequest) grant(carePlan *fhir.CarePlan) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	for _, granted := range r.carePlans {
+		if to.EmptyString(granted.Id) == to.EmptyString(carePlan.Id) {
+			return
+		}
+	}
+	r.carePlans = append(r.carePlans, carePlan)
+}
+
+func (r *breakTheGlassRequest) grantedCarePlans() []*fhir.CarePlan {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return append([]*fhir.CarePlan{}, r.carePlans...)
+}
+
+// withBreakTheGlass returns a context with the break-the-glass request specified by the HTTP request headers.
+// If the request doesn't specify a purpose of use, the context is returned as-is.
+// It returns an error if break-the-glass access is requested, but it's disabled or the request has no justification.
+func (s *Service) withBreakTheGlass(ctx context.Context, header http.Header) (context.Context, error) {
+	purposeOfUse := header.Get(PurposeOfUseHeader)
+	if purposeOfUse == "" {
+		return ctx, nil
+	}
+	if purposeOfUse != *audit.BreakTheGlassPurposeOfUse.Code {
+		return nil, coolfhir.BadRequest("unsupported purpose of use: %s", purposeOfUse)
+	}
+	if !s.breakTheGlass.Enabled {
+		return nil, coolfhir.BadRequest("break-the-glass access is not enabled")
+	}
+	justification := strings.TrimSpace(header.Get(JustificationHeader))
+	if justification == "" {
+		return nil, coolfhir.BadRequest("break-the-glass access requires a justification (%s header)", JustificationHeader)
+	}
+	return context.WithValue(ctx, breakTheGlassContextKey, &breakTheGlassRequest{justification: justification}), nil
+}
+
+func breakTheGlassRequestFromContext(ctx context.Context) *breakTheGlassRequest {
+	request, _ := ctx.Value(breakTheGlassContextKey).(*breakTheGlassRequest)
+	return request
+}
+
+var _ BatchPolicy[*fhir.CarePlan] = &BreakTheGlassPolicy{}
+
+// BreakTheGlassPolicy is a policy that allows emergency read access to a CarePlan (and through it, its related resources)
+// for care organizations that aren't a member of its CareTeam.
+// Access is allowed if the request specifies break-the-glass as purpose of use and a justification (see withBreakTheGlass),
+// or if the principal's organization was granted break-the-glass access to the CarePlan within the configured duration.
+// Grants are recorded as AuditEvents (see breakTheGlassAuditEvent), which are looked up to decide on subsequent requests.
+type BreakTheGlassPolicy struct {
+	fhirClientFactory FHIRClientFactory
+	// duration is how long a grant gives access.
+	duration time.Duration
+}
+
+func (b BreakTheGlassPolicy) HasAccess(ctx context.Context, carePlan *fhir.CarePlan, principal auth.Principal) (*PolicyDecision, error) {
+	decisions, err := b.HasAccessBatch(ctx, []*fhir.CarePlan{carePlan}, principal)
+	if err != nil {
+		return nil, err
+	}
+	return decisions[0], nil
+}
+
+func (b BreakTheGlassPolicy) HasAccessBatch(ctx context.Context, carePlans []*fhir.CarePlan, principal auth.Principal) ([]*PolicyDecision, error) {
+	now := time.Now()
+	grants, err := b.grants(ctx, carePlans, now)
+	if err != nil {
+		return nil, err
+	}
+	request := breakTheGlassRequestFromContext(ctx)
+	results := make([]*PolicyDecision, len(carePlans))
+	for i, carePlan := range carePlans {
+		if grant := activeBreakTheGlassGrant(grants[to.EmptyString(carePlan.Id)], principal, now.Add(-b.duration)); grant != nil {
+			results[i] = &PolicyDecision{
+				Allowed: true,
+				Reasons: []string{"BreakTheGlassPolicy: principal was granted emergency access (AuditEvent/" + to.EmptyString(grant.Id) + ")"},
+			}
+		} else if request != nil && carePlan.Id != nil {
+			request.grant(carePlan)
+			results[i] = &PolicyDecision{
+				Allowed: true,
+				Reasons: []string{"BreakTheGlassPolicy: principal requested emergency access"},
+			}
+		} else {
+			results[i] = &PolicyDecision{
+				Allowed: false,
+				Reasons: []string{"BreakTheGlassPolicy: principal has no emergency access"},
+			}
+		}
+	}
+	return results, nil
+}
+
+// grants returns the break-the-glass AuditEvents recorded within the policy's duration, per CarePlan ID.
+// The lookups are cached per CarePlan for the duration of the request.
+func (b BreakTheGlassPolicy) grants(ctx context.Context, carePlans []*fhir.CarePlan, now time.Time) (map[string][]fhir.AuditEvent, error) {
+	cache := authzCacheFromContext(ctx)
+	result := make(map[string][]fhir.AuditEvent)
+	var uncached []string
+	for _, carePlan := range carePlans {
+		if carePlan.Id == nil {
+			continue
+		}
+		if cached, ok := cache.get("BreakTheGlassPolicy/CarePlan/" + *carePlan.Id).([]fhir.AuditEvent); ok {
+			result[*carePlan.Id] = cached
+		} else if _, ok := result[*carePlan.Id]; !ok {
+			uncached = append(uncached, "CarePlan/"+*carePlan.Id)
+			result[*carePlan.Id] = nil
+		}
+	}
+	if len(uncached) == 0 {
+		return result, nil
+	}
+	fhirClient, err := b.fhirClientFactory(ctx)
+	if err != nil {
+		return nil, err
+	}
+	auditEvents, err := searchBreakTheGlassAuditEvents(ctx, fhirClient, url.Values{
+		"entity": []string{strings.Join(uncached, ",")},
+		"date":   []string{"ge" + now.Add(-b.duration).Format(time.RFC3339)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for break-the-glass grants: %w", err)
+	}
+	for _, auditEvent := range auditEvents {
+		for _, entity := range auditEvent.Entity {
+			if entity.What == nil || entity.What.Reference == nil {
+				continue
+			}
+			carePlanID, found := strings.CutPrefix(*entity.What.Reference, "CarePlan/")
+			if _, requested := result[carePlanID]; found && requested {
+				result[carePlanID] = append(result[carePlanID], auditEvent)
+			}
+		}
+	}
+	for _, reference := range uncached {
+		carePlanID := strings.TrimPrefix(reference, "CarePlan/")
+		cache.put("BreakTheGlassPolicy/CarePlan/"+carePlanID, append([]fhir.AuditEvent{}, result[carePlanID]...))
+	}
+	return result, nil
+}
+
+// activeBreakTheGlassGrant returns the grant that was recorded for the principal's organization since the given time, or nil if there's none.
+func activeBreakTheGlassGrant(grants []fhir.AuditEvent, principal auth.Principal, since time.Time) *fhir.AuditEvent {
+	for _, grant := range grants {
+		recorded, err := time.Parse(time.RFC3339, grant.Recorded)
+		if err != nil || recorded.Before(since) {
+			continue
+		}
+		for _, agent := range grant.Agent {
+			if agent.Requestor && agent.Who != nil && agent.Who.Identifier != nil &&
+				coolfhir.HasIdentifier(*agent.Who.Identifier, principal.Organization.Identifier...) {
+				return &grant
+			}
+		}
+	}
+	return nil
+}
+
+// searchBreakTheGlassAuditEvents searches for AuditEvents that record break-the-glass grants, using the given additional search parameters.
+func searchBreakTheGlassAuditEvents(ctx context.Context, fhirClient fhirclient.Client, params url.Values) ([]fhir.AuditEvent, error) {
+	params.Set("subtype", *audit.BreakTheGlassPurposeOfUse.System+"|"+*audit.BreakTheGlassPurposeOfUse.Code)
+	var bundle fhir.Bundle
+	if err := fhirClient.SearchWithContext(ctx, "AuditEvent", params, &bundle); err != nil {
+		return nil, err
+	}
+	var auditEvents []fhir.AuditEvent
+	if err := coolfhir.ResourcesInBundle(&bundle, coolfhir.EntryIsOfType("AuditEvent"), &auditEvents); err != nil {
+		return nil, err
+	}
+	// Make sure only actual break-the-glass AuditEvents are returned, even if the FHIR server ignored the subtype parameter.
+	var result []fhir.AuditEvent
+	for _, auditEvent := range auditEvents {
+		if coolfhir.ConceptContainsCoding(audit.BreakTheGlassPurposeOfUse, auditEvent.PurposeOfEvent...) {
+			result = append(result, auditEvent)
+		}
+	}
+	return result, nil
+}
+
+// isBreakTheGlassAuditEvent returns whether the given resource is an AuditEvent that records a break-the-glass grant.
+func isBreakTheGlassAuditEvent(resource any) bool {
+	var auditEvent *fhir.AuditEvent
+	switch r := resource.(type) {
+	case *fhir.AuditEvent:
+		auditEvent = r
+	case fhir.AuditEvent:
+		auditEvent = &r
+	default:
+		return false
+	}
+	return auditEvent != nil && coolfhir.ContainsCoding(audit.BreakTheGlassPurposeOfUse, auditEvent.Subtype...)
+}
+
+// breakTheGlassAuditEvent creates the AuditEvent that records a break-the-glass grant to the given CarePlan.
+// Besides as purpose of event, BTG is recorded as subtype, since FHIR R4 has no search parameter for AuditEvent.purposeOfEvent.
+// The CarePlan author is recorded as custodian agent, so it can be notified of the grant.
+func breakTheGlassAuditEvent(localIdentity fhir.Identifier, principal auth.Principal, carePlan *fhir.CarePlan, justification string) *fhir.AuditEvent {
+	purposeOfUse := fhir.CodeableConcept{
+		Coding: []fhir.Coding{audit.BreakTheGlassPurposeOfUse},
+		Text:   to.Ptr(justification),
+	}
+	auditEvent := audit.Event(localIdentity, fhir.AuditEventActionR, &fhir.Reference{
+		Id:        carePlan.Id,
+		Type:      to.Ptr("CarePlan"),
+		Reference: to.Ptr("CarePlan/" + *carePlan.Id),
+	}, &fhir.Reference{
+		Identifier: &principal.Organization.Identifier[0],
+		Type:       to.Ptr("Organization"),
+	}, principal.UserAuditAgent(), []string{"BreakTheGlassPolicy: principal requested emergency access"})
+	auditEvent.Subtype = append(auditEvent.Subtype, audit.BreakTheGlassPurposeOfUse)
+	auditEvent.PurposeOfEvent = []fhir.CodeableConcept{purposeOfUse}
+	for i := range auditEvent.Agent {
+		auditEvent.Agent[i].PurposeOfUse = []fhir.CodeableConcept{purposeOfUse}
+	}
+	if carePlan.Author != nil && coolfhir.IsLogicalIdentifier(carePlan.Author.Identifier) {
+		auditEvent.Agent = append(auditEvent.Agent, fhir.AuditEventAgent{
+			Type:      &fhir.CodeableConcept{Coding: []fhir.Coding{audit.CustodianParticipationType}},
+			Who:       carePlan.Author,
+			Requestor: false,
+		})
+	}
+	return auditEvent
+}
+
+// recordBreakTheGlass adds an AuditEvent to the transaction for each CarePlan that was accessed through break-the-glass while handling the request.
+// The returned FHIRHandlerResult notifies the custodians (CarePlan authors) of the grants after the transaction has been committed.
+// It returns nil if the request didn't gain access through break-the-glass.
+// Break-the-glass access is limited to a single patient: a request (e.g. a search) that would grant access to CarePlans of multiple patients is rejected.
+func (s *Service) recordBreakTheGlass(ctx context.Context, request FHIRHandlerRequest, tx *coolfhir.BundleBuilder) (FHIRHandlerResult, error) {
+	btgRequest := breakTheGlassRequestFromContext(ctx)
+	if btgRequest == nil {
+		return nil, nil
+	}
+	carePlans := btgRequest.grantedCarePlans()
+	if len(carePlans) == 0 {
+		return nil, nil
+	}
+	patients := make(map[string]bool)
+	for _, carePlan := range carePlans {
+		if coolfhir.IsLogicalIdentifier(carePlan.Subject.Identifier) {
+			patients[coolfhir.IdentifierToToken(*carePlan.Subject.Identifier)] = true
+		} else {
+			patients[to.EmptyString(carePlan.Subject.Reference)] = true
+		}
+	}
+	if len(patients) > 1 {
+		return nil, coolfhir.BadRequest("break-the-glass access is limited to the CarePlans of a single patient")
+	}
+
+	var entryIdxs []int
+	for _, carePlan := range carePlans {
+		tx.Create(breakTheGlassAuditEvent(*request.LocalIdentity, *request.Principal, carePlan, btgRequest.justification))
+		entryIdxs = append(entryIdxs, len(tx.Entry)-1)
+		slog.InfoContext(ctx, "Break-the-glass access granted",
+			slog.String("audit", "breaktheglass"),
+			slog.String(logging.FieldResourceType, "CarePlan"),
+			slog.String(logging.FieldResourceID, *carePlan.Id),
+			slog.String(logging.FieldIdentifier, coolfhir.IdentifierToToken(request.Principal.Organization.Identifier[0])),
+		)
+	}
+	fhirClient, err := s.createFHIRClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return func(txResult *fhir.Bundle) ([]*fhir.BundleEntry, []any, error) {
+		var notifications []any
+		for _, idx := range entryIdxs {
+			var auditEvent fhir.AuditEvent
+			if _, err := coolfhir.NormalizeTransactionBundleResponseEntry(ctx, fhirClient, request.BaseURL, &tx.Entry[idx], &txResult.Entry[idx], &auditEvent); err != nil {
+				return nil, nil, fmt.Errorf("failed to process break-the-glass AuditEvent: %w", err)
+			}
+			notifications = append(notifications, &auditEvent)
+		}
+		return nil, notifications, nil
+	}, nil
+}
@@ -0,0 +1,231 @@
+package careplanservice
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/careplancontributor/mock"
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/audit"
+	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	"go.uber.org/mock/gomock"
+)
+
+func TestBreakTheGlassPolicy(t *testing.T) {
+	carePlan := &fhir.CarePlan{
+		Id:      to.Ptr("cp1"),
+		Subject: *coolfhir.LogicalReference("Patient", coolfhir.BSNNamingSystem, "1333333337"),
+		Author:  coolfhir.LogicalReference("Organization", coolfhir.URANamingSystem, "author"),
+	}
+	grant := func(principal *auth.Principal, recorded time.Time) fhir.AuditEvent {
+		result := *breakTheGlassAuditEvent(auth.TestPrincipal3.Organization.Identifier[0], *principal, carePlan, "patient unconscious")
+		result.Id = to.Ptr("ae1")
+		result.Recorded = recorded.Format(time.RFC3339)
+		return result
+	}
+	fhirClientReturning := func(t *testing.T, auditEvents ...fhir.AuditEvent) *mock.MockClient {
+		fhirClient := mock.NewMockClient(gomock.NewController(t))
+		fhirClient.EXPECT().SearchWithContext(gomock.Any(), "AuditEvent", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, query url.Values, target any, _ ...fhirclient.Option) error {
+				require.Equal(t, "CarePlan/cp1", query.Get("entity"))
+				require.Equal(t, "http://terminology.hl7.org/CodeSystem/v3-ActReason|BTG", query.Get("subtype"))
+				bundle := coolfhir.SearchSet()
+				for _, auditEvent := range auditEvents {
+					bundle.Append(auditEvent, nil, nil)
+				}
+				*target.(*fhir.Bundle) = bundle.Bundle()
+				return nil
+			})
+		return fhirClient
+	}
+
+	t.Run("no grant, no emergency access requested", func(t *testing.T) {
+		policy := BreakTheGlassPolicy{fhirClientFactory: FHIRClientFactoryFor(fhirClientReturning(t)), duration: time.Hour}
+
+		decision, err := policy.HasAccess(context.Background(), carePlan, *auth.TestPrincipal2)
+
+		require.NoError(t, err)
+		require.False(t, decision.Allowed)
+	})
+	t.Run("emergency access requested", func(t *testing.T) {
+		policy := BreakTheGlassPolicy{fhirClientFactory: FHIRClientFactoryFor(fhirClientReturning(t)), duration: time.Hour}
+		request := &breakTheGlassRequest{justification: "patient unconscious"}
+		ctx := context.WithValue(context.Background(), breakTheGlassContextKey, request)
+
+		decision, err := policy.HasAccess(ctx, carePlan, *auth.TestPrincipal2)
+
+		require.NoError(t, err)
+		require.True(t, decision.Allowed)
+		require.Equal(t, []*fhir.CarePlan{carePlan}, request.grantedCarePlans())
+	})
+	t.Run("active grant", func(t *testing.T) {
+		fhirClient := fhirClientReturning(t, grant(auth.TestPrincipal2, time.Now().Add(-30*time.Minute)))
+		policy := BreakTheGlassPolicy{fhirClientFactory: FHIRClientFactoryFor(fhirClient), duration: time.Hour}
+
+		decision, err := policy.HasAccess(context.Background(), carePlan, *auth.TestPrincipal2)
+
+		require.NoError(t, err)
+		require.True(t, decision.Allowed)
+		require.Equal(t, []string{"BreakTheGlassPolicy: principal was granted emergency access (AuditEvent/ae1)"}, decision.Reasons)
+	})
+	t.Run("expired grant", func(t *testing.T) {
+		fhirClient := fhirClientReturning(t, grant(auth.TestPrincipal2, time.Now().Add(-2*time.Hour)))
+		policy := BreakTheGlassPolicy{fhirClientFactory: FHIRClientFactoryFor(fhirClient), duration: time.Hour}
+
+		decision, err := policy.HasAccess(context.Background(), carePlan, *auth.TestPrincipal2)
+
+		require.NoError(t, err)
+		require.False(t, decision.Allowed)
+	})
+	t.Run("grant of other organization", func(t *testing.T) {
+		fhirClient := fhirClientReturning(t, grant(auth.TestPrincipal1, time.Now()))
+		policy := BreakTheGlassPolicy{fhirClientFactory: FHIRClientFactoryFor(fhirClient), duration: time.Hour}
+
+		decision, err := policy.HasAccess(context.Background(), carePlan, *auth.TestPrincipal2)
+
+		require.NoError(t, err)
+		require.False(t, decision.Allowed)
+	})
+	t.Run("lookup is cached per request", func(t *testing.T) {
+		policy := BreakTheGlassPolicy{fhirClientFactory: FHIRClientFactoryFor(fhirClientReturning(t)), duration: time.Hour}
+		ctx := withAuthzCache(context.Background())
+
+		_, err := policy.HasAccess(ctx, carePlan, *auth.TestPrincipal2)
+		require.NoError(t, err)
+		_, err = policy.HasAccess(ctx, carePlan, *auth.TestPrincipal2)
+		require.NoError(t, err)
+	})
+	t.Run("search fails", func(t *testing.T) {
+		fhirClient := mock.NewMockClient(gomock.NewController(t))
+		fhirClient.EXPECT().SearchWithContext(gomock.Any(), "AuditEvent", gomock.Any(), gomock.Any()).Return(errors.New("failure"))
+		policy := BreakTheGlassPolicy{fhirClientFactory: FHIRClientFactoryFor(fhirClient), duration: time.Hour}
+
+		_, err := policy.HasAccess(context.Background(), carePlan, *auth.TestPrincipal2)
+
+		require.EqualError(t, err, "failed to search for break-the-glass grants: failure")
+	})
+	t.Run("CarePlan read policy", func(t *testing.T) {
+		result, err := buildAuthzPolicies(nil, tenants.Test(), nil, nil, &BreakTheGlassPolicy{duration: time.Hour})
+		require.NoError(t, err)
+
+		carePlanPolicy := result[authzPolicyKey("test", "CarePlan", AuthzInteractionRead)].(AnyMatchPolicy[*fhir.CarePlan])
+		require.Equal(t, ReadCarePlanAuthzPolicy(), carePlanPolicy.Policies[0])
+		require.IsType(t, BreakTheGlassPolicy{}, carePlanPolicy.Policies[1])
+		// Related resources inherit break-the-glass access through the CarePlan
+		taskPolicy := result[authzPolicyKey("test", "Task", AuthzInteractionRead)].(AnyMatchPolicy[*fhir.Task])
+		relatedCarePlanPolicy := taskPolicy.Policies[1].(RelatedResourcePolicy[*fhir.Task, *fhir.CarePlan])
+		require.Equal(t, carePlanPolicy, relatedCarePlanPolicy.relatedResourcePolicy)
+	})
+}
+
+func TestService_withBreakTheGlass(t *testing.T) {
+	enabled := &Service{breakTheGlass: BreakTheGlassConfig{Enabled: true, Duration: time.Hour}}
+	header := func(purposeOfUse, justification string) http.Header {
+		result := http.Header{}
+		result.Set(PurposeOfUseHeader, purposeOfUse)
+		result.Set(JustificationHeader, justification)
+		return result
+	}
+	t.Run("ok", func(t *testing.T) {
+		ctx, err := enabled.withBreakTheGlass(context.Background(), header("BTG", "patient unconscious"))
+
+		require.NoError(t, err)
+		require.Equal(t, "patient unconscious", breakTheGlassRequestFromContext(ctx).justification)
+	})
+	t.Run("not requested", func(t *testing.T) {
+		ctx, err := enabled.withBreakTheGlass(context.Background(), http.Header{})
+
+		require.NoError(t, err)
+		require.Nil(t, breakTheGlassRequestFromContext(ctx))
+	})
+	t.Run("disabled", func(t *testing.T) {
+		_, err := (&Service{}).withBreakTheGlass(context.Background(), header("BTG", "patient unconscious"))
+
+		require.EqualError(t, err, "break-the-glass access is not enabled")
+	})
+	t.Run("no justification", func(t *testing.T) {
+		_, err := enabled.withBreakTheGlass(context.Background(), header("BTG", " "))
+
+		require.EqualError(t, err, "break-the-glass access requires a justification (X-Scp-Justification header)")
+	})
+	t.Run("unsupported purpose of use", func(t *testing.T) {
+		_, err := enabled.withBreakTheGlass(context.Background(), header("TREAT", "patient unconscious"))
+
+		require.EqualError(t, err, "unsupported purpose of use: TREAT")
+	})
+}
+
+func Test_breakTheGlassAuditEvent(t *testing.T) {
+	carePlan := &fhir.CarePlan{
+		Id:     to.Ptr("cp1"),
+		Author: coolfhir.LogicalReference("Organization", coolfhir.URANamingSystem, "author"),
+	}
+	localIdentity := auth.TestPrincipal3.Organization.Identifier[0]
+
+	auditEvent := breakTheGlassAuditEvent(localIdentity, *auth.TestPrincipal2, carePlan, "patient unconscious")
+
+	require.Equal(t, "CarePlan/cp1", *auditEvent.Entity[0].What.Reference)
+	require.True(t, coolfhir.ConceptContainsCoding(audit.BreakTheGlassPurposeOfUse, auditEvent.PurposeOfEvent...))
+	require.True(t, coolfhir.ContainsCoding(audit.BreakTheGlassPurposeOfUse, auditEvent.Subtype...))
+	require.Equal(t, "patient unconscious", *auditEvent.PurposeOfEvent[0].Text)
+	require.Len(t, auditEvent.Agent, 2)
+	require.True(t, auditEvent.Agent[0].Requestor)
+	require.Equal(t, auth.TestPrincipal2.Organization.Identifier[0], *auditEvent.Agent[0].Who.Identifier)
+	require.True(t, coolfhir.ConceptContainsCoding(audit.CustodianParticipationType, *auditEvent.Agent[1].Type))
+	require.Equal(t, carePlan.Author, auditEvent.Agent[1].Who)
+}
+
+func TestService_recordBreakTheGlass(t *testing.T) {
+	carePlan := func(id string, bsn string) *fhir.CarePlan {
+		return &fhir.CarePlan{
+			Id:      to.Ptr(id),
+			Subject: *coolfhir.LogicalReference("Patient", coolfhir.BSNNamingSystem, bsn),
+		}
+	}
+	service := &Service{fhirClientByTenant: map[string]fhirclient.Client{"test": mock.NewMockClient(gomock.NewController(t))}}
+	ctx := tenants.WithTenant(context.Background(), tenants.Test().Sole())
+	request := FHIRHandlerRequest{
+		Principal:     auth.TestPrincipal2,
+		LocalIdentity: to.Ptr(auth.TestPrincipal3.Organization.Identifier[0]),
+	}
+	t.Run("records grants", func(t *testing.T) {
+		btgRequest := &breakTheGlassRequest{justification: "patient unconscious"}
+		btgRequest.grant(carePlan("cp1", "1"))
+		btgRequest.grant(carePlan("cp2", "1"))
+		btgRequest.grant(carePlan("cp1", "1"))
+		tx := coolfhir.Transaction()
+
+		result, err := service.recordBreakTheGlass(context.WithValue(ctx, breakTheGlassContextKey, btgRequest), request, tx)
+
+		require.NoError(t, err)
+		require.NotNil(t, result)
+		require.Len(t, tx.Entry, 2)
+	})
+	t.Run("multiple patients", func(t *testing.T) {
+		btgRequest := &breakTheGlassRequest{justification: "patient unconscious"}
+		btgRequest.grant(carePlan("cp1", "1"))
+		btgRequest.grant(carePlan("cp2", "2"))
+
+		_, err := service.recordBreakTheGlass(context.WithValue(ctx, breakTheGlassContextKey, btgRequest), request, coolfhir.Transaction())
+
+		require.EqualError(t, err, "break-the-glass access is limited to the CarePlans of a single patient")
+	})
+	t.Run("not requested", func(t *testing.T) {
+		tx := coolfhir.Transaction()
+
+		result, err := service.recordBreakTheGlass(ctx, request, tx)
+
+		require.NoError(t, err)
+		require.Nil(t, result)
+		require.Empty(t, tx.Entry)
+	})
+}
@@ -0,0 +1,82 @@
+package careplanservice
+
+import (
+	"context"
+	"sync"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+type authzCacheContextKeyType struct{}
+
+var authzCacheContextKey = authzCacheContextKeyType{}
+
+// authzCache is a request-scoped cache for authorization policies, so that policy checks within one request (or Bundle)
+// share the lookups of related resources (e.g. CarePlans) and the CareTeams derived from them.
+// A nil authzCache is valid, and doesn't cache anything.
+type authzCache struct {
+	mux       sync.Mutex
+	entries   map[string]any
+	careTeams map[string]*fhir.CareTeam
+}
+
+// withAuthzCache returns a context with an authorization cache, unless the context already has one.
+func withAuthzCache(ctx context.Context) context.Context {
+	if authzCacheFromContext(ctx) != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, authzCacheContextKey, &authzCache{
+		entries:   map[string]any{},
+		careTeams: map[string]*fhir.CareTeam{},
+	})
+}
+
+func authzCacheFromContext(ctx context.Context) *authzCache {
+	cache, _ := ctx.Value(authzCacheContextKey).(*authzCache)
+	return cache
+}
+
+func (c *authzCache) get(key string) any {
+	if c == nil {
+		return nil
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.entries[key]
+}
+
+func (c *authzCache) put(key string, value any) {
+	if c == nil {
+		return
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.entries[key] = value
+}
+
+// careTeamFromCarePlan returns the CareTeam of the given CarePlan, which is cached by CarePlan ID and version.
+func (c *authzCache) careTeamFromCarePlan(carePlan *fhir.CarePlan) (*fhir.CareTeam, error) {
+	if c == nil || carePlan.Id == nil {
+		return coolfhir.CareTeamFromCarePlan(carePlan)
+	}
+	key := *carePlan.Id
+	if carePlan.Meta != nil {
+		key += "/_history/" + to.EmptyString(carePlan.Meta.VersionId)
+	}
+	c.mux.Lock()
+	careTeam, ok := c.careTeams[key]
+	c.mux.Unlock()
+	if ok {
+		return careTeam, nil
+	}
+	careTeam, err := coolfhir.CareTeamFromCarePlan(carePlan)
+	if err != nil {
+		return nil, err
+	}
+	c.mux.Lock()
+	c.careTeams[key] = careTeam
+	c.mux.Unlock()
+	return careTeam, nil
+}
@@ -1,7 +1,133 @@
 package careplanservice
 
-import "github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
 
 func ReadCarePlanAuthzPolicy() Policy[*fhir.CarePlan] {
 	return CareTeamMemberPolicy[fhir.CarePlan]{}
 }
+
+// CreateCarePlanResourceAuthzPolicy returns the policy for creating resources that are shared through a CarePlan (e.g. Goal, Communication):
+// the principal must be an active member of the CareTeams of all CarePlans the resource refers to (see carePlanReferences).
+func CreateCarePlanResourceAuthzPolicy[T any](fhirClientFactory FHIRClientFactory, carePlanReferences func(resource T) []fhir.Reference) Policy[T] {
+	return AllCarePlanReferencesPolicy[T]{
+		fhirClientFactory:  fhirClientFactory,
+		carePlanPolicy:     CareTeamMemberPolicy[fhir.CarePlan]{activeMembersOnly: true},
+		carePlanReferences: carePlanReferences,
+	}
+}
+
+var _ Policy[any] = &AllCarePlanReferencesPolicy[any]{}
+
+// AllCarePlanReferencesPolicy is a policy that allows access if the principal has access to every CarePlan the resource refers to.
+// Otherwise, a resource could be shared through a CarePlan the principal has no access to, by also referring to one it does have access to.
+type AllCarePlanReferencesPolicy[T any] struct {
+	fhirClientFactory  FHIRClientFactory
+	carePlanPolicy     Policy[*fhir.CarePlan]
+	carePlanReferences func(resource T) []fhir.Reference
+}
+
+func (a AllCarePlanReferencesPolicy[T]) HasAccess(ctx context.Context, resource T, principal auth.Principal) (*PolicyDecision, error) {
+	reasons := []string{"AllCarePlanReferencesPolicy: access to all referenced CarePlans"}
+	for _, reference := range a.carePlanReferences(resource) {
+		if reference.Reference == nil || !strings.HasPrefix(*reference.Reference, "CarePlan/") {
+			continue
+		}
+		policy := carePlanReferenceRelation(a.fhirClientFactory, a.carePlanPolicy, func(T) []fhir.Reference {
+			return []fhir.Reference{reference}
+		})
+		decision, err := policy.HasAccess(ctx, resource, principal)
+		if err != nil {
+			return nil, err
+		}
+		if !decision.Allowed {
+			return &PolicyDecision{
+				Allowed: false,
+				Reasons: append([]string{"AllCarePlanReferencesPolicy: no access to " + *reference.Reference}, decision.Reasons...),
+			}, nil
+		}
+		reasons = append(reasons, decision.Reasons...)
+	}
+	if len(reasons) == 1 {
+		return &PolicyDecision{
+			Allowed: false,
+			Reasons: []string{"AllCarePlanReferencesPolicy: resource does not refer to a CarePlan"},
+		}, nil
+	}
+	return &PolicyDecision{
+		Allowed: true,
+		Reasons: reasons,
+	}, nil
+}
+
+// carePlanReferenceRelation allows access to a resource if the principal has access to a CarePlan the resource refers to,
+// e.g. through Communication.basedOn.
+func carePlanReferenceRelation[T any](fhirClientFactory FHIRClientFactory, carePlanPolicy Policy[*fhir.CarePlan], carePlanReferences func(resource T) []fhir.Reference) Policy[T] {
+	carePlanIDs := func(resource T) []string {
+		var ids []string
+		for _, reference := range carePlanReferences(resource) {
+			if reference.Reference != nil && strings.HasPrefix(*reference.Reference, "CarePlan/") {
+				ids = append(ids, getResourceID(*reference.Reference))
+			}
+		}
+		return ids
+	}
+	return RelatedResourcePolicy[T, *fhir.CarePlan]{
+		fhirClientFactory:     fhirClientFactory,
+		relatedResourcePolicy: carePlanPolicy,
+		relatedResourceSearchParams: func(ctx context.Context, resource T) (string, url.Values) {
+			ids := carePlanIDs(resource)
+			if len(ids) == 0 {
+				return "", nil
+			}
+			return "CarePlan", url.Values{
+				"_id": []string{strings.Join(ids, ",")},
+			}
+		},
+		isRelatedResource: func(resource T, carePlan *fhir.CarePlan) bool {
+			for _, id := range carePlanIDs(resource) {
+				if carePlan.Id != nil && *carePlan.Id == id {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// carePlanReferrerRelation allows access to a resource if the principal has access to a CarePlan that refers to the resource,
+// e.g. through CarePlan.goal. The CarePlans are searched using the given search parameter (e.g. goal), which is defined on the given CarePlan element.
+func carePlanReferrerRelation[T any](fhirClientFactory FHIRClientFactory, carePlanPolicy Policy[*fhir.CarePlan], searchParam string, carePlanElement func(carePlan *fhir.CarePlan) []fhir.Reference) Policy[T] {
+	return RelatedResourcePolicy[T, *fhir.CarePlan]{
+		fhirClientFactory:     fhirClientFactory,
+		relatedResourcePolicy: carePlanPolicy,
+		relatedResourceSearchParams: func(ctx context.Context, resource T) (string, url.Values) {
+			resourceID := coolfhir.ResourceID(resource)
+			if resourceID == nil {
+				return "", nil
+			}
+			return "CarePlan", url.Values{
+				searchParam: []string{coolfhir.ResourceType(resource) + "/" + *resourceID},
+			}
+		},
+		isRelatedResource: func(resource T, carePlan *fhir.CarePlan) bool {
+			resourceID := coolfhir.ResourceID(resource)
+			if resourceID == nil {
+				return false
+			}
+			for _, reference := range carePlanElement(carePlan) {
+				if reference.Reference != nil && *reference.Reference == coolfhir.ResourceType(resource)+"/"+*resourceID {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
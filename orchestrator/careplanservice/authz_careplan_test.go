@@ -50,4 +50,71 @@ func TestCarePlanAuthzPolicy(t *testing.T) {
 			},
 		})
 	})
+	t.Run("active members only", func(t *testing.T) {
+		carePlan := fhir.CarePlan{
+			Id:       to.Ptr("cp1"),
+			CareTeam: []fhir.Reference{{Type: to.Ptr("CareTeam"), Reference: to.Ptr("#ct")}},
+			Contained: must.MarshalJSON([]fhir.CareTeam{
+				{
+					Id: to.Ptr("ct"),
+					Participant: []fhir.CareTeamParticipant{
+						{
+							Member: &fhir.Reference{Type: to.Ptr("Organization"), Identifier: &auth.TestPrincipal1.Organization.Identifier[0]},
+							Period: &fhir.Period{Start: to.Ptr("2020-01-01T00:00:00Z")},
+						},
+						{
+							Member: &fhir.Reference{Type: to.Ptr("Organization"), Identifier: &auth.TestPrincipal2.Organization.Identifier[0]},
+							Period: &fhir.Period{Start: to.Ptr("2020-01-01T00:00:00Z"), End: to.Ptr("2021-01-01T00:00:00Z")},
+						},
+					},
+				},
+			}),
+		}
+		policy := CareTeamMemberPolicy[fhir.CarePlan]{activeMembersOnly: true}
+		testPolicies(t, []AuthzPolicyTest[*fhir.CarePlan]{
+			{
+				name:      "allow (active member)",
+				policy:    policy,
+				resource:  &carePlan,
+				principal: auth.TestPrincipal1,
+				wantAllow: true,
+			},
+			{
+				name:      "disallow (former member)",
+				policy:    policy,
+				resource:  &carePlan,
+				principal: auth.TestPrincipal2,
+				wantAllow: false,
+			},
+			{
+				name:      "disallow (not in CareTeam)",
+				policy:    policy,
+				resource:  &carePlan,
+				principal: auth.TestPrincipal3,
+				wantAllow: false,
+			},
+		})
+	})
+}
+
+// testCarePlanWithActiveMember returns a CarePlan whose CareTeam has the given principal as active member.
+func testCarePlanWithActiveMember(id string, member *auth.Principal) fhir.CarePlan {
+	return fhir.CarePlan{
+		Id:       to.Ptr(id),
+		CareTeam: []fhir.Reference{{Type: to.Ptr("CareTeam"), Reference: to.Ptr("#ct")}},
+		Contained: must.MarshalJSON([]fhir.CareTeam{
+			{
+				Id: to.Ptr("ct"),
+				Participant: []fhir.CareTeamParticipant{
+					{
+						Member: &fhir.Reference{
+							Type:       to.Ptr("Organization"),
+							Identifier: &member.Organization.Identifier[0],
+						},
+						Period: &fhir.Period{Start: to.Ptr("2020-01-01T00:00:00Z")},
+					},
+				},
+			},
+		}),
+	}
 }
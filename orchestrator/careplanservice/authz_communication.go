@@ -0,0 +1,33 @@
+package careplanservice
+
+import "github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+
+func CreateCommunicationAuthzPolicy(fhirClientFactory FHIRClientFactory) Policy[*fhir.Communication] {
+	return CreateCarePlanResourceAuthzPolicy(fhirClientFactory, communicationCarePlanReferences)
+}
+
+func UpdateCommunicationAuthzPolicy() Policy[*fhir.Communication] {
+	return CreatorPolicy[*fhir.Communication]{}
+}
+
+func ReadCommunicationAuthzPolicy(fhirClientFactory FHIRClientFactory) Policy[*fhir.Communication] {
+	return readCommunicationAuthzPolicy(fhirClientFactory, ReadCarePlanAuthzPolicy())
+}
+
+func readCommunicationAuthzPolicy(fhirClientFactory FHIRClientFactory, carePlanPolicy Policy[*fhir.CarePlan]) Policy[*fhir.Communication] {
+	return AnyMatchPolicy[*fhir.Communication]{
+		Policies: []Policy[*fhir.Communication]{
+			communicationCarePlanRelation(fhirClientFactory, carePlanPolicy),
+			CreatorPolicy[*fhir.Communication]{},
+		},
+	}
+}
+
+// communicationCarePlanRelation allows access to a Communication if the principal has access to a CarePlan it's based on.
+func communicationCarePlanRelation(fhirClientFactory FHIRClientFactory, carePlanPolicy Policy[*fhir.CarePlan]) Policy[*fhir.Communication] {
+	return carePlanReferenceRelation(fhirClientFactory, carePlanPolicy, communicationCarePlanReferences)
+}
+
+func communicationCarePlanReferences(communication *fhir.Communication) []fhir.Reference {
+	return communication.BasedOn
+}
@@ -0,0 +1,80 @@
+package careplanservice
+
+import (
+	"testing"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/test"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+func TestCommunicationAuthzPolicy(t *testing.T) {
+	communication := fhir.Communication{
+		Id:      to.Ptr("m1"),
+		BasedOn: []fhir.Reference{{Reference: to.Ptr("CarePlan/cp1")}},
+	}
+	communicationWithCreator := communication
+	communicationWithCreator.Extension = TestCreatorExtension
+	otherCarePlanCommunication := fhir.Communication{
+		Id:      to.Ptr("m2"),
+		BasedOn: []fhir.Reference{{Reference: to.Ptr("CarePlan/other")}},
+	}
+
+	fhirClient := &test.StubFHIRClient{
+		Resources: []any{testCarePlanWithActiveMember("cp1", auth.TestPrincipal2)},
+	}
+
+	t.Run("create", func(t *testing.T) {
+		policy := CreateCommunicationAuthzPolicy(FHIRClientFactoryFor(fhirClient))
+		testPolicies(t, []AuthzPolicyTest[*fhir.Communication]{
+			{
+				name:      "allow (member of CarePlan's CareTeam)",
+				policy:    policy,
+				resource:  &communication,
+				principal: auth.TestPrincipal2,
+				wantAllow: true,
+			},
+			{
+				name:      "disallow (not a member of CarePlan's CareTeam)",
+				policy:    policy,
+				resource:  &communication,
+				principal: auth.TestPrincipal3,
+				wantAllow: false,
+			},
+			{
+				name:      "disallow (CarePlan doesn't exist)",
+				policy:    policy,
+				resource:  &otherCarePlanCommunication,
+				principal: auth.TestPrincipal2,
+				wantAllow: false,
+			},
+		})
+	})
+	t.Run("read", func(t *testing.T) {
+		policy := ReadCommunicationAuthzPolicy(FHIRClientFactoryFor(fhirClient))
+		testPolicies(t, []AuthzPolicyTest[*fhir.Communication]{
+			{
+				name:      "allow (in CareTeam of CarePlan)",
+				policy:    policy,
+				resource:  &communication,
+				principal: auth.TestPrincipal2,
+				wantAllow: true,
+			},
+			{
+				name:      "allow (creator)",
+				policy:    policy,
+				resource:  &communicationWithCreator,
+				principal: auth.TestPrincipal1,
+				wantAllow: true,
+			},
+			{
+				name:      "disallow (not in CareTeam)",
+				policy:    policy,
+				resource:  &communication,
+				principal: auth.TestPrincipal3,
+				wantAllow: false,
+			},
+		})
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"net/url"
 
 	"github.com/SanteonNL/orca/orchestrator/cmd/profile"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
 	"github.com/SanteonNL/orca/orchestrator/lib/logging"
 	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
 )
@@ -22,27 +23,39 @@ func UpdateConditionAuthzPolicy() Policy[*fhir.Condition] {
 }
 
 func ReadConditionAuthzPolicy(fhirClientFactory FHIRClientFactory) Policy[*fhir.Condition] {
+	return readConditionAuthzPolicy(fhirClientFactory, ReadPatientAuthzPolicy(fhirClientFactory))
+}
+
+func readConditionAuthzPolicy(fhirClientFactory FHIRClientFactory, patientPolicy Policy[*fhir.Patient]) Policy[*fhir.Condition] {
 	// TODO: Find out new auth requirements for condition
 	return AnyMatchPolicy[*fhir.Condition]{
 		Policies: []Policy[*fhir.Condition]{
-			RelatedResourcePolicy[*fhir.Condition, *fhir.Patient]{
-				fhirClientFactory:     fhirClientFactory,
-				relatedResourcePolicy: ReadPatientAuthzPolicy(fhirClientFactory),
-				relatedResourceSearchParams: func(ctx context.Context, resource *fhir.Condition) (string, url.Values) {
-					if resource.Subject.Identifier == nil || resource.Subject.Identifier.System == nil || resource.Subject.Identifier.Value == nil {
-						slog.WarnContext(
-							ctx,
-							"Condition does not have Patient as subject, can't verify access",
-							slog.String(logging.FieldResourceType, fhir.ResourceTypeCondition.String()),
-						)
-						return "Patient", nil
-					}
-					return "Patient", url.Values{
-						"identifier": []string{fmt.Sprintf("%s|%s", *resource.Subject.Identifier.System, *resource.Subject.Identifier.Value)},
-					}
-				},
-			},
+			conditionPatientRelation(fhirClientFactory, patientPolicy),
 			CreatorPolicy[*fhir.Condition]{},
 		},
 	}
 }
+
+// conditionPatientRelation allows access to a Condition if the principal has access to the Patient that is its subject.
+func conditionPatientRelation(fhirClientFactory FHIRClientFactory, patientPolicy Policy[*fhir.Patient]) Policy[*fhir.Condition] {
+	return RelatedResourcePolicy[*fhir.Condition, *fhir.Patient]{
+		fhirClientFactory:     fhirClientFactory,
+		relatedResourcePolicy: patientPolicy,
+		relatedResourceSearchParams: func(ctx context.Context, resource *fhir.Condition) (string, url.Values) {
+			if resource.Subject.Identifier == nil || resource.Subject.Identifier.System == nil || resource.Subject.Identifier.Value == nil {
+				slog.WarnContext(
+					ctx,
+					"Condition does not have Patient as subject, can't verify access",
+					slog.String(logging.FieldResourceType, fhir.ResourceTypeCondition.String()),
+				)
+				return "Patient", nil
+			}
+			return "Patient", url.Values{
+				"identifier": []string{fmt.Sprintf("%s|%s", *resource.Subject.Identifier.System, *resource.Subject.Identifier.Value)},
+			}
+		},
+		isRelatedResource: func(resource *fhir.Condition, patient *fhir.Patient) bool {
+			return coolfhir.HasIdentifier(*resource.Subject.Identifier, patient.Identifier...)
+		},
+	}
+}
@@ -0,0 +1,522 @@
+package careplanservice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/SanteonNL/orca/orchestrator/cmd/profile"
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	"gopkg.in/yaml.v3"
+)
+
+// AuthzInteraction is a FHIR interaction on a resource type, which is authorized by a policy.
+type AuthzInteraction string
+
+const (
+	AuthzInteractionCreate AuthzInteraction = "create"
+	AuthzInteractionUpdate AuthzInteraction = "update"
+	// AuthzInteractionRead applies to both reading and searching resources.
+	AuthzInteractionRead AuthzInteraction = "read"
+)
+
+// authzPolicyResourceTypes contains the resource types per interaction that are authorized using policies.
+// Other interactions (e.g. creating a Task or CarePlan) are authorized by their specific handlers.
+var authzPolicyResourceTypes = map[AuthzInteraction][]string{
+	AuthzInteractionCreate: {"Communication", "Condition", "DocumentReference", "Goal", "Observation", "Patient", "Questionnaire", "QuestionnaireResponse", "ServiceRequest"},
+	AuthzInteractionUpdate: {"Communication", "Condition", "DocumentReference", "Goal", "Observation", "Patient", "Questionnaire", "QuestionnaireResponse", "ServiceRequest"},
+	AuthzInteractionRead:   {"CarePlan", "Communication", "Condition", "DocumentReference", "Goal", "Observation", "Patient", "Questionnaire", "QuestionnaireResponse", "ServiceRequest", "Task"},
+}
+
+// authzRelations contains the relations that can be used by relatedResource policies, per resource type and related resource type.
+// The given policy is the read policy of the related resource type.
+var authzRelations = map[string]map[string]func(fhirClientFactory FHIRClientFactory, relatedResourcePolicy any) any{
+	"Communication": {
+		"CarePlan": func(fhirClientFactory FHIRClientFactory, relatedResourcePolicy any) any {
+			return communicationCarePlanRelation(fhirClientFactory, relatedResourcePolicy.(Policy[*fhir.CarePlan]))
+		},
+	},
+	"Condition": {
+		"Patient": func(fhirClientFactory FHIRClientFactory, relatedResourcePolicy any) any {
+			return conditionPatientRelation(fhirClientFactory, relatedResourcePolicy.(Policy[*fhir.Patient]))
+		},
+	},
+	"DocumentReference": {
+		"CarePlan": func(fhirClientFactory FHIRClientFactory, relatedResourcePolicy any) any {
+			return supportingInfoCarePlanRelation[*fhir.DocumentReference](fhirClientFactory, relatedResourcePolicy.(Policy[*fhir.CarePlan]))
+		},
+	},
+	"Goal": {
+		"CarePlan": func(fhirClientFactory FHIRClientFactory, relatedResourcePolicy any) any {
+			return goalCarePlanRelation(fhirClientFactory, relatedResourcePolicy.(Policy[*fhir.CarePlan]))
+		},
+	},
+	"Observation": {
+		"CarePlan": func(fhirClientFactory FHIRClientFactory, relatedResourcePolicy any) any {
+			return supportingInfoCarePlanRelation[*fhir.Observation](fhirClientFactory, relatedResourcePolicy.(Policy[*fhir.CarePlan]))
+		},
+	},
+	"Patient": {
+		"CarePlan": func(fhirClientFactory FHIRClientFactory, relatedResourcePolicy any) any {
+			return patientCarePlanRelation(fhirClientFactory, relatedResourcePolicy.(Policy[*fhir.CarePlan]))
+		},
+	},
+	"QuestionnaireResponse": {
+		"Task": func(fhirClientFactory FHIRClientFactory, relatedResourcePolicy any) any {
+			return questionnaireResponseTaskRelation(fhirClientFactory, relatedResourcePolicy.(Policy[*fhir.Task]))
+		},
+	},
+	"ServiceRequest": {
+		"Task": func(fhirClientFactory FHIRClientFactory, relatedResourcePolicy any) any {
+			return serviceRequestTaskRelation(fhirClientFactory, relatedResourcePolicy.(Policy[*fhir.Task]))
+		},
+	},
+	"Task": {
+		"CarePlan": func(fhirClientFactory FHIRClientFactory, relatedResourcePolicy any) any {
+			return taskCarePlanRelation(fhirClientFactory, relatedResourcePolicy.(Policy[*fhir.CarePlan]))
+		},
+	},
+}
+
+// AuthzPolicies contains authorization policies that replace the CPS' built-in policies,
+// per tenant, resource type and interaction.
+type AuthzPolicies struct {
+	Rules []AuthzPolicyRule `yaml:"policies"`
+}
+
+// AuthzPolicyRule configures the policy of a resource type for one or more interactions.
+type AuthzPolicyRule struct {
+	// Tenants contains the IDs of the tenants the rule applies to. If empty, it applies to all tenants.
+	// Rules for a specific tenant take precedence over rules that apply to all tenants.
+	Tenants      []string              `yaml:"tenants"`
+	ResourceType string                `yaml:"resourceType"`
+	Interactions []AuthzInteraction    `yaml:"interactions"`
+	Policy       AuthzPolicyDefinition `yaml:"policy"`
+}
+
+// AuthzPolicyDefinition declares a policy using the CPS' policy building blocks. Exactly one of the fields must be set.
+type AuthzPolicyDefinition struct {
+	// AnyOf allows access if any of the policies allows access.
+	AnyOf []AuthzPolicyDefinition `yaml:"anyOf"`
+	// Anyone allows access to anyone.
+	Anyone *struct{} `yaml:"anyone"`
+	// Creator allows access if the principal created the resource.
+	Creator *struct{} `yaml:"creator"`
+	// LocalOrganization allows access if the principal is the local care organization.
+	LocalOrganization *struct{} `yaml:"localOrganization"`
+	// TaskOwnerOrRequester allows access if the principal is the owner or requester of the Task. It only applies to Task.
+	TaskOwnerOrRequester *struct{} `yaml:"taskOwnerOrRequester"`
+	// CareTeamMember allows access if the principal is a member of the CarePlan's CareTeam. It only applies to CarePlan.
+	CareTeamMember *CareTeamMemberPolicyDefinition `yaml:"careTeamMember"`
+	// RelatedResource allows access if the principal has read access to a related resource.
+	RelatedResource *RelatedResourcePolicyDefinition `yaml:"relatedResource"`
+	// UserRole allows access if the principal's user has one of the roles, and the nested policy allows access.
+	UserRole *UserRolePolicyDefinition `yaml:"userRole"`
+}
+
+type CareTeamMemberPolicyDefinition struct {
+	// ActiveMembersOnly denies access to former CareTeam members.
+	ActiveMembersOnly bool `yaml:"activeMembersOnly"`
+}
+
+type RelatedResourcePolicyDefinition struct {
+	// ResourceType is the type of the related resource, e.g. CarePlan for Patient. Access to it is decided by its (configured) read policy.
+	ResourceType string `yaml:"resourceType"`
+}
+
+type UserRolePolicyDefinition struct {
+	// Roles contains the role codes in the form of <system>|<code>.
+	Roles  []string               `yaml:"roles"`
+	Policy *AuthzPolicyDefinition `yaml:"policy"`
+}
+
+// LoadAuthzPolicies loads authorization policies from the given YAML file.
+func LoadAuthzPolicies(filePath string) (*AuthzPolicies, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read authorization policy file: %w", err)
+	}
+	var result AuthzPolicies
+	if err := yaml.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("invalid authorization policy file (%s): %w", filePath, err)
+	}
+	return &result, nil
+}
+
+// Validate checks whether the rules apply to known tenants, and to resource types and interactions that are authorized using policies.
+// It also checks there's at most one rule per tenant, resource type and interaction.
+func (p AuthzPolicies) Validate(tenantCfg tenants.Config) error {
+	seen := map[string]bool{}
+	for i, rule := range p.Rules {
+		for _, tenantID := range rule.Tenants {
+			if _, err := tenantCfg.Get(tenantID); err != nil {
+				return fmt.Errorf("authorization policy %d: unknown tenant: %s", i, tenantID)
+			}
+		}
+		if len(rule.Interactions) == 0 {
+			return fmt.Errorf("authorization policy %d: no interactions specified", i)
+		}
+		for _, interaction := range rule.Interactions {
+			if !slices.Contains(authzPolicyResourceTypes[interaction], rule.ResourceType) {
+				return fmt.Errorf("authorization policy %d: unsupported interaction for resource type: %s %s", i, interaction, rule.ResourceType)
+			}
+			scopes := rule.Tenants
+			if len(scopes) == 0 {
+				scopes = []string{"*"}
+			}
+			for _, scope := range scopes {
+				key := authzPolicyKey(scope, rule.ResourceType, interaction)
+				if seen[key] {
+					return fmt.Errorf("authorization policy %d: multiple policies for %s %s (tenant: %s)", i, interaction, rule.ResourceType, scope)
+				}
+				seen[key] = true
+			}
+		}
+	}
+	return nil
+}
+
+// definition returns the configured policy definition for the tenant, resource type and interaction, or nil if there is none.
+func (p *AuthzPolicies) definition(tenantID string, resourceType string, interaction AuthzInteraction) *AuthzPolicyDefinition {
+	if p == nil {
+		return nil
+	}
+	var result *AuthzPolicyDefinition
+	for i, rule := range p.Rules {
+		if rule.ResourceType != resourceType || !slices.Contains(rule.Interactions, interaction) {
+			continue
+		}
+		if slices.Contains(rule.Tenants, tenantID) {
+			return &p.Rules[i].Policy
+		}
+		if len(rule.Tenants) == 0 && result == nil {
+			result = &p.Rules[i].Policy
+		}
+	}
+	return result
+}
+
+func authzPolicyKey(tenantID string, resourceType string, interaction AuthzInteraction) string {
+	return tenantID + "/" + string(interaction) + "/" + resourceType
+}
+
+// buildAuthzPolicies builds the policies of all tenants, resource types and interactions, which are either configured or built-in.
+// The resulting map is keyed by authzPolicyKey, its values are Policy[T] with T being the resource type.
+// If breakTheGlass is not nil, it's added to the CarePlan read policy of every tenant.
+func buildAuthzPolicies(policies *AuthzPolicies, tenantCfg tenants.Config, fhirClientFactory FHIRClientFactory, profile profile.Provider, breakTheGlass *BreakTheGlassPolicy) (map[string]any, error) {
+	result := map[string]any{}
+	for _, tenant := range tenantCfg {
+		builder := authzPolicyBuilder{
+			tenantID:          tenant.ID,
+			policies:          policies,
+			fhirClientFactory: fhirClientFactory,
+			profile:           profile,
+			breakTheGlass:     breakTheGlass,
+		}
+		for interaction, resourceTypes := range authzPolicyResourceTypes {
+			for _, resourceType := range resourceTypes {
+				policy, err := builder.policy(resourceType, interaction)
+				if err != nil {
+					return nil, fmt.Errorf("authorization policy (tenant=%s): %w", tenant.ID, err)
+				}
+				result[authzPolicyKey(tenant.ID, resourceType, interaction)] = policy
+			}
+		}
+	}
+	return result, nil
+}
+
+// authzPolicy returns the authorization policy of the tenant for the resource type and interaction.
+func authzPolicy[T any](s *Service, tenantID string, resourceType string, interaction AuthzInteraction) Policy[T] {
+	if policy, ok := s.authzPolicies[authzPolicyKey(tenantID, resourceType, interaction)]; ok {
+		return policy.(Policy[T])
+	}
+	// Policies weren't built at startup (e.g. tenant isn't configured), use the built-in policy
+	builder := authzPolicyBuilder{fhirClientFactory: s.createFHIRClient, profile: s.profile}
+	policy, _ := builder.builtinPolicy(resourceType, interaction)
+	return policy.(Policy[T])
+}
+
+type authzPolicyBuilder struct {
+	tenantID          string
+	policies          *AuthzPolicies
+	fhirClientFactory FHIRClientFactory
+	profile           profile.Provider
+	breakTheGlass     *BreakTheGlassPolicy
+}
+
+// policy returns the configured policy for the resource type and interaction, or the built-in policy if none is configured.
+// If break-the-glass is enabled, the CarePlan read policy also allows emergency access,
+// which the read policies of related resources (e.g. Task, Patient) inherit through their relation to the CarePlan.
+func (b authzPolicyBuilder) policy(resourceType string, interaction AuthzInteraction) (any, error) {
+	result, err := b.configuredPolicy(resourceType, interaction)
+	if err != nil || b.breakTheGlass == nil || resourceType != "CarePlan" || interaction != AuthzInteractionRead {
+		return result, err
+	}
+	return AnyMatchPolicy[*fhir.CarePlan]{
+		Policies: []Policy[*fhir.CarePlan]{result.(Policy[*fhir.CarePlan]), *b.breakTheGlass},
+	}, nil
+}
+
+func (b authzPolicyBuilder) configuredPolicy(resourceType string, interaction AuthzInteraction) (any, error) {
+	definition := b.policies.definition(b.tenantID, resourceType, interaction)
+	if definition == nil {
+		result, err := b.builtinPolicy(resourceType, interaction)
+		if err != nil {
+			return nil, err
+		}
+		if result == nil {
+			return nil, fmt.Errorf("no policy for %s %s", interaction, resourceType)
+		}
+		return result, nil
+	}
+	authzResourceType := authzResourceTypeOf(resourceType)
+	if authzResourceType == nil {
+		return nil, fmt.Errorf("unsupported resource type: %s", resourceType)
+	}
+	result, err := authzResourceType.build(b, *definition)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", interaction, resourceType, err)
+	}
+	return result, nil
+}
+
+// builtinPolicy returns the built-in policy for the resource type and interaction, or nil if there is none.
+// Built-in read policies that depend on related resources use the (configured) read policy of the related resource type.
+func (b authzPolicyBuilder) builtinPolicy(resourceType string, interaction AuthzInteraction) (any, error) {
+	switch interaction {
+	case AuthzInteractionCreate:
+		switch resourceType {
+		case "Communication":
+			return CreateCommunicationAuthzPolicy(b.fhirClientFactory), nil
+		case "Condition":
+			return CreateConditionAuthzPolicy(b.profile), nil
+		case "DocumentReference":
+			return CreateDocumentReferenceAuthzPolicy(b.fhirClientFactory), nil
+		case "Goal":
+			return CreateGoalAuthzPolicy(b.fhirClientFactory), nil
+		case "Observation":
+			return CreateObservationAuthzPolicy(b.fhirClientFactory), nil
+		case "Patient":
+			return CreatePatientAuthzPolicy(b.profile), nil
+		case "Questionnaire":
+			return CreateQuestionnaireAuthzPolicy(), nil
+		case "QuestionnaireResponse":
+			return CreateQuestionnaireResponseAuthzPolicy(b.profile), nil
+		case "ServiceRequest":
+			return CreateServiceRequestAuthzPolicy(b.profile), nil
+		}
+	case AuthzInteractionUpdate:
+		switch resourceType {
+		case "Communication":
+			return UpdateCommunicationAuthzPolicy(), nil
+		case "Condition":
+			return UpdateConditionAuthzPolicy(), nil
+		case "DocumentReference":
+			return UpdateDocumentReferenceAuthzPolicy(), nil
+		case "Goal":
+			return UpdateGoalAuthzPolicy(), nil
+		case "Observation":
+			return UpdateObservationAuthzPolicy(), nil
+		case "Patient":
+			return UpdatePatientAuthzPolicy(), nil
+		case "Questionnaire":
+			return UpdateQuestionnaireAuthzPolicy(), nil
+		case "QuestionnaireResponse":
+			return UpdateQuestionnaireResponseAuthzPolicy(), nil
+		case "ServiceRequest":
+			return UpdateServiceRequestAuthzPolicy(), nil
+		}
+	case AuthzInteractionRead:
+		switch resourceType {
+		case "CarePlan":
+			return ReadCarePlanAuthzPolicy(), nil
+		case "Questionnaire":
+			return ReadQuestionnaireAuthzPolicy(), nil
+		case "Condition":
+			patientPolicy, err := b.policy("Patient", AuthzInteractionRead)
+			if err != nil {
+				return nil, err
+			}
+			return readConditionAuthzPolicy(b.fhirClientFactory, patientPolicy.(Policy[*fhir.Patient])), nil
+		case "Communication":
+			carePlanPolicy, err := b.policy("CarePlan", AuthzInteractionRead)
+			if err != nil {
+				return nil, err
+			}
+			return readCommunicationAuthzPolicy(b.fhirClientFactory, carePlanPolicy.(Policy[*fhir.CarePlan])), nil
+		case "DocumentReference":
+			carePlanPolicy, err := b.policy("CarePlan", AuthzInteractionRead)
+			if err != nil {
+				return nil, err
+			}
+			return readDocumentReferenceAuthzPolicy(b.fhirClientFactory, carePlanPolicy.(Policy[*fhir.CarePlan])), nil
+		case "Goal":
+			carePlanPolicy, err := b.policy("CarePlan", AuthzInteractionRead)
+			if err != nil {
+				return nil, err
+			}
+			return readGoalAuthzPolicy(b.fhirClientFactory, carePlanPolicy.(Policy[*fhir.CarePlan])), nil
+		case "Observation":
+			carePlanPolicy, err := b.policy("CarePlan", AuthzInteractionRead)
+			if err != nil {
+				return nil, err
+			}
+			return readObservationAuthzPolicy(b.fhirClientFactory, carePlanPolicy.(Policy[*fhir.CarePlan])), nil
+		case "Patient":
+			carePlanPolicy, err := b.policy("CarePlan", AuthzInteractionRead)
+			if err != nil {
+				return nil, err
+			}
+			return readPatientAuthzPolicy(b.fhirClientFactory, carePlanPolicy.(Policy[*fhir.CarePlan])), nil
+		case "QuestionnaireResponse":
+			taskPolicy, err := b.policy("Task", AuthzInteractionRead)
+			if err != nil {
+				return nil, err
+			}
+			return readQuestionnaireResponseAuthzPolicy(b.fhirClientFactory, taskPolicy.(Policy[*fhir.Task])), nil
+		case "ServiceRequest":
+			taskPolicy, err := b.policy("Task", AuthzInteractionRead)
+			if err != nil {
+				return nil, err
+			}
+			return readServiceRequestAuthzPolicy(b.fhirClientFactory, taskPolicy.(Policy[*fhir.Task])), nil
+		case "Task":
+			carePlanPolicy, err := b.policy("CarePlan", AuthzInteractionRead)
+			if err != nil {
+				return nil, err
+			}
+			return readTaskAuthzPolicy(b.fhirClientFactory, carePlanPolicy.(Policy[*fhir.CarePlan])), nil
+		}
+	}
+	return nil, nil
+}
+
+// authzResourceType contains the type-specific functions to build and evaluate policies of a resource type.
+type authzResourceType struct {
+	build     func(b authzPolicyBuilder, definition AuthzPolicyDefinition) (any, error)
+	hasAccess func(ctx context.Context, policy any, resourceJSON []byte, principal auth.Principal) (*PolicyDecision, error)
+}
+
+func authzResourceTypeOf(resourceType string) *authzResourceType {
+	switch resourceType {
+	case "CarePlan":
+		return newAuthzResourceType[*fhir.CarePlan](resourceType)
+	case "Communication":
+		return newAuthzResourceType[*fhir.Communication](resourceType)
+	case "Condition":
+		return newAuthzResourceType[*fhir.Condition](resourceType)
+	case "DocumentReference":
+		return newAuthzResourceType[*fhir.DocumentReference](resourceType)
+	case "Goal":
+		return newAuthzResourceType[*fhir.Goal](resourceType)
+	case "Observation":
+		return newAuthzResourceType[*fhir.Observation](resourceType)
+	case "Patient":
+		return newAuthzResourceType[*fhir.Patient](resourceType)
+	case "Questionnaire":
+		return newAuthzResourceType[*fhir.Questionnaire](resourceType)
+	case "QuestionnaireResponse":
+		return newAuthzResourceType[*fhir.QuestionnaireResponse](resourceType)
+	case "ServiceRequest":
+		return newAuthzResourceType[*fhir.ServiceRequest](resourceType)
+	case "Task":
+		return newAuthzResourceType[*fhir.Task](resourceType)
+	}
+	return nil
+}
+
+func newAuthzResourceType[T fhir.HasExtension](resourceType string) *authzResourceType {
+	return &authzResourceType{
+		build: func(b authzPolicyBuilder, definition AuthzPolicyDefinition) (any, error) {
+			return buildAuthzPolicy[T](b, resourceType, definition)
+		},
+		hasAccess: func(ctx context.Context, policy any, resourceJSON []byte, principal auth.Principal) (*PolicyDecision, error) {
+			var resource T
+			if err := json.Unmarshal(resourceJSON, &resource); err != nil {
+				return nil, fmt.Errorf("unmarshal %s: %w", resourceType, err)
+			}
+			return policy.(Policy[T]).HasAccess(ctx, resource, principal)
+		},
+	}
+}
+
+func buildAuthzPolicy[T fhir.HasExtension](b authzPolicyBuilder, resourceType string, definition AuthzPolicyDefinition) (Policy[T], error) {
+	var buildingBlocks int
+	for _, isSet := range []bool{
+		definition.AnyOf != nil, definition.Anyone != nil, definition.Creator != nil, definition.LocalOrganization != nil,
+		definition.TaskOwnerOrRequester != nil, definition.CareTeamMember != nil, definition.RelatedResource != nil, definition.UserRole != nil,
+	} {
+		if isSet {
+			buildingBlocks++
+		}
+	}
+	if buildingBlocks != 1 {
+		return nil, fmt.Errorf("policy must specify exactly one of anyOf, anyone, creator, localOrganization, taskOwnerOrRequester, careTeamMember, relatedResource or userRole")
+	}
+	switch {
+	case definition.AnyOf != nil:
+		var policies []Policy[T]
+		for _, current := range definition.AnyOf {
+			policy, err := buildAuthzPolicy[T](b, resourceType, current)
+			if err != nil {
+				return nil, fmt.Errorf("anyOf: %w", err)
+			}
+			policies = append(policies, policy)
+		}
+		return AnyMatchPolicy[T]{Policies: policies}, nil
+	case definition.Anyone != nil:
+		return AnyonePolicy[T]{}, nil
+	case definition.Creator != nil:
+		return CreatorPolicy[T]{}, nil
+	case definition.LocalOrganization != nil:
+		return LocalOrganizationPolicy[T]{profile: b.profile}, nil
+	case definition.TaskOwnerOrRequester != nil:
+		if policy, ok := any(TaskOwnerOrRequesterPolicy[fhir.Task]{}).(Policy[T]); ok {
+			return policy, nil
+		}
+		return nil, fmt.Errorf("taskOwnerOrRequester only applies to Task")
+	case definition.CareTeamMember != nil:
+		policy := CareTeamMemberPolicy[fhir.CarePlan]{activeMembersOnly: definition.CareTeamMember.ActiveMembersOnly}
+		if result, ok := any(policy).(Policy[T]); ok {
+			return result, nil
+		}
+		return nil, fmt.Errorf("careTeamMember only applies to CarePlan")
+	case definition.RelatedResource != nil:
+		relatedResourceType := definition.RelatedResource.ResourceType
+		relation, ok := authzRelations[resourceType][relatedResourceType]
+		if !ok {
+			return nil, fmt.Errorf("relatedResource: unsupported related resource type for %s: %s", resourceType, relatedResourceType)
+		}
+		relatedResourcePolicy, err := b.policy(relatedResourceType, AuthzInteractionRead)
+		if err != nil {
+			return nil, fmt.Errorf("relatedResource: %w", err)
+		}
+		return relation(b.fhirClientFactory, relatedResourcePolicy).(Policy[T]), nil
+	default:
+		if definition.UserRole.Policy == nil {
+			return nil, fmt.Errorf("userRole: policy is required")
+		}
+		var roles []fhir.Coding
+		for _, token := range definition.UserRole.Roles {
+			identifier, err := coolfhir.TokenToIdentifier(token)
+			if err != nil || !coolfhir.IsLogicalIdentifier(identifier) {
+				return nil, fmt.Errorf("userRole: invalid role (expected <system>|<code>): %s", token)
+			}
+			roles = append(roles, fhir.Coding{System: identifier.System, Code: identifier.Value})
+		}
+		if len(roles) == 0 {
+			return nil, fmt.Errorf("userRole: roles are required")
+		}
+		policy, err := buildAuthzPolicy[T](b, resourceType, *definition.UserRole.Policy)
+		if err != nil {
+			return nil, fmt.Errorf("userRole: %w", err)
+		}
+		return UserRolePolicy[T]{Roles: roles, Policy: policy}, nil
+	}
+}
@@ -0,0 +1,153 @@
+package careplanservice
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+const testAuthzPolicies = `
+policies:
+  - resourceType: CarePlan
+    interactions: [read]
+    policy:
+      careTeamMember:
+        activeMembersOnly: true
+  - tenants: [test]
+    resourceType: Patient
+    interactions: [read]
+    policy:
+      anyOf:
+        - relatedResource:
+            resourceType: CarePlan
+        - userRole:
+            roles: ["http://snomed.info/sct|158965000"]
+            policy:
+              localOrganization: {}
+  - resourceType: Patient
+    interactions: [read, update]
+    policy:
+      creator: {}
+`
+
+func TestLoadAuthzPolicies(t *testing.T) {
+	filePath := path.Join(t.TempDir(), "policies.yaml")
+	require.NoError(t, os.WriteFile(filePath, []byte(testAuthzPolicies), 0644))
+
+	policies, err := LoadAuthzPolicies(filePath)
+
+	require.NoError(t, err)
+	require.NoError(t, policies.Validate(tenants.Test()))
+	require.Len(t, policies.Rules, 3)
+	t.Run("definition", func(t *testing.T) {
+		t.Run("tenant-specific rule takes precedence", func(t *testing.T) {
+			definition := policies.definition("test", "Patient", AuthzInteractionRead)
+			require.Len(t, definition.AnyOf, 2)
+		})
+		t.Run("rule for all tenants", func(t *testing.T) {
+			definition := policies.definition("other", "Patient", AuthzInteractionRead)
+			require.NotNil(t, definition.Creator)
+		})
+		t.Run("not configured", func(t *testing.T) {
+			require.Nil(t, policies.definition("test", "Patient", AuthzInteractionCreate))
+		})
+	})
+	t.Run("build", func(t *testing.T) {
+		result, err := buildAuthzPolicies(policies, tenants.Test(), nil, nil, nil)
+		require.NoError(t, err)
+
+		patientPolicy := result[authzPolicyKey("test", "Patient", AuthzInteractionRead)].(AnyMatchPolicy[*fhir.Patient])
+		require.Len(t, patientPolicy.Policies, 2)
+		relatedResourcePolicy := patientPolicy.Policies[0].(RelatedResourcePolicy[*fhir.Patient, *fhir.CarePlan])
+		require.Equal(t, CareTeamMemberPolicy[fhir.CarePlan]{activeMembersOnly: true}, relatedResourcePolicy.relatedResourcePolicy)
+		userRolePolicy := patientPolicy.Policies[1].(UserRolePolicy[*fhir.Patient])
+		require.Equal(t, []fhir.Coding{{System: to.Ptr("http://snomed.info/sct"), Code: to.Ptr("158965000")}}, userRolePolicy.Roles)
+		require.IsType(t, LocalOrganizationPolicy[*fhir.Patient]{}, userRolePolicy.Policy)
+
+		require.IsType(t, CreatorPolicy[*fhir.Patient]{}, result[authzPolicyKey("test", "Patient", AuthzInteractionUpdate)])
+		// Not configured: built-in policy
+		require.IsType(t, LocalOrganizationPolicy[*fhir.Patient]{}, result[authzPolicyKey("test", "Patient", AuthzInteractionCreate)])
+		// Not configured, but built-in policy uses configured policy of related resource type
+		taskPolicy := result[authzPolicyKey("test", "Task", AuthzInteractionRead)].(AnyMatchPolicy[*fhir.Task])
+		relatedCarePlanPolicy := taskPolicy.Policies[1].(RelatedResourcePolicy[*fhir.Task, *fhir.CarePlan])
+		require.Equal(t, CareTeamMemberPolicy[fhir.CarePlan]{activeMembersOnly: true}, relatedCarePlanPolicy.relatedResourcePolicy)
+	})
+	t.Run("file does not exist", func(t *testing.T) {
+		_, err := LoadAuthzPolicies(path.Join(t.TempDir(), "nonexistent.yaml"))
+		require.ErrorContains(t, err, "failed to read authorization policy file")
+	})
+}
+
+func TestAuthzPolicies_Validate(t *testing.T) {
+	creator := AuthzPolicyDefinition{Creator: &struct{}{}}
+	t.Run("unknown tenant", func(t *testing.T) {
+		policies := AuthzPolicies{Rules: []AuthzPolicyRule{
+			{Tenants: []string{"other"}, ResourceType: "Patient", Interactions: []AuthzInteraction{AuthzInteractionRead}, Policy: creator},
+		}}
+		require.EqualError(t, policies.Validate(tenants.Test()), "authorization policy 0: unknown tenant: other")
+	})
+	t.Run("no interactions", func(t *testing.T) {
+		policies := AuthzPolicies{Rules: []AuthzPolicyRule{
+			{ResourceType: "Patient", Policy: creator},
+		}}
+		require.EqualError(t, policies.Validate(tenants.Test()), "authorization policy 0: no interactions specified")
+	})
+	t.Run("unsupported interaction", func(t *testing.T) {
+		policies := AuthzPolicies{Rules: []AuthzPolicyRule{
+			{ResourceType: "Task", Interactions: []AuthzInteraction{AuthzInteractionCreate}, Policy: creator},
+		}}
+		require.EqualError(t, policies.Validate(tenants.Test()), "authorization policy 0: unsupported interaction for resource type: create Task")
+	})
+	t.Run("multiple policies", func(t *testing.T) {
+		policies := AuthzPolicies{Rules: []AuthzPolicyRule{
+			{ResourceType: "Patient", Interactions: []AuthzInteraction{AuthzInteractionRead}, Policy: creator},
+			{Tenants: []string{"test"}, ResourceType: "Patient", Interactions: []AuthzInteraction{AuthzInteractionRead}, Policy: creator},
+			{Tenants: []string{"test"}, ResourceType: "Patient", Interactions: []AuthzInteraction{AuthzInteractionRead}, Policy: creator},
+		}}
+		require.EqualError(t, policies.Validate(tenants.Test()), "authorization policy 2: multiple policies for read Patient (tenant: test)")
+	})
+}
+
+func TestBuildAuthzPolicy(t *testing.T) {
+	builder := authzPolicyBuilder{tenantID: "test"}
+	t.Run("no building block", func(t *testing.T) {
+		_, err := buildAuthzPolicy[*fhir.Patient](builder, "Patient", AuthzPolicyDefinition{})
+		require.ErrorContains(t, err, "policy must specify exactly one of")
+	})
+	t.Run("multiple building blocks", func(t *testing.T) {
+		_, err := buildAuthzPolicy[*fhir.Patient](builder, "Patient", AuthzPolicyDefinition{Creator: &struct{}{}, Anyone: &struct{}{}})
+		require.ErrorContains(t, err, "policy must specify exactly one of")
+	})
+	t.Run("taskOwnerOrRequester on Patient", func(t *testing.T) {
+		_, err := buildAuthzPolicy[*fhir.Patient](builder, "Patient", AuthzPolicyDefinition{TaskOwnerOrRequester: &struct{}{}})
+		require.EqualError(t, err, "taskOwnerOrRequester only applies to Task")
+	})
+	t.Run("careTeamMember on Task", func(t *testing.T) {
+		_, err := buildAuthzPolicy[*fhir.Task](builder, "Task", AuthzPolicyDefinition{AnyOf: []AuthzPolicyDefinition{
+			{CareTeamMember: &CareTeamMemberPolicyDefinition{}},
+		}})
+		require.EqualError(t, err, "anyOf: careTeamMember only applies to CarePlan")
+	})
+	t.Run("unsupported related resource type", func(t *testing.T) {
+		_, err := buildAuthzPolicy[*fhir.Patient](builder, "Patient", AuthzPolicyDefinition{RelatedResource: &RelatedResourcePolicyDefinition{ResourceType: "Task"}})
+		require.EqualError(t, err, "relatedResource: unsupported related resource type for Patient: Task")
+	})
+	t.Run("userRole with invalid role", func(t *testing.T) {
+		_, err := buildAuthzPolicy[*fhir.Patient](builder, "Patient", AuthzPolicyDefinition{UserRole: &UserRolePolicyDefinition{
+			Roles:  []string{"nurse"},
+			Policy: &AuthzPolicyDefinition{Anyone: &struct{}{}},
+		}})
+		require.EqualError(t, err, "userRole: invalid role (expected <system>|<code>): nurse")
+	})
+	t.Run("userRole without policy", func(t *testing.T) {
+		_, err := buildAuthzPolicy[*fhir.Patient](builder, "Patient", AuthzPolicyDefinition{UserRole: &UserRolePolicyDefinition{
+			Roles: []string{"http://snomed.info/sct|158965000"},
+		}})
+		require.EqualError(t, err, "userRole: policy is required")
+	})
+}
@@ -0,0 +1,32 @@
+package careplanservice
+
+import "github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+
+func CreateDocumentReferenceAuthzPolicy(fhirClientFactory FHIRClientFactory) Policy[*fhir.DocumentReference] {
+	return CreateCarePlanResourceAuthzPolicy(fhirClientFactory, documentReferenceCarePlanReferences)
+}
+
+func UpdateDocumentReferenceAuthzPolicy() Policy[*fhir.DocumentReference] {
+	return CreatorPolicy[*fhir.DocumentReference]{}
+}
+
+func ReadDocumentReferenceAuthzPolicy(fhirClientFactory FHIRClientFactory) Policy[*fhir.DocumentReference] {
+	return readDocumentReferenceAuthzPolicy(fhirClientFactory, ReadCarePlanAuthzPolicy())
+}
+
+func readDocumentReferenceAuthzPolicy(fhirClientFactory FHIRClientFactory, carePlanPolicy Policy[*fhir.CarePlan]) Policy[*fhir.DocumentReference] {
+	return AnyMatchPolicy[*fhir.DocumentReference]{
+		Policies: []Policy[*fhir.DocumentReference]{
+			supportingInfoCarePlanRelation[*fhir.DocumentReference](fhirClientFactory, carePlanPolicy),
+			CreatorPolicy[*fhir.DocumentReference]{},
+		},
+	}
+}
+
+// documentReferenceCarePlanReferences returns the CarePlans the DocumentReference is related to (DocumentReference.context.related).
+func documentReferenceCarePlanReferences(documentReference *fhir.DocumentReference) []fhir.Reference {
+	if documentReference.Context == nil {
+		return nil
+	}
+	return documentReference.Context.Related
+}
@@ -0,0 +1,65 @@
+package careplanservice
+
+import (
+	"testing"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/test"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+func TestDocumentReferenceAuthzPolicy(t *testing.T) {
+	documentReference := fhir.DocumentReference{
+		Id: to.Ptr("d1"),
+		Context: &fhir.DocumentReferenceContext{
+			Related: []fhir.Reference{{Reference: to.Ptr("CarePlan/cp1")}},
+		},
+	}
+	withoutContext := fhir.DocumentReference{Id: to.Ptr("d2")}
+
+	carePlan := testCarePlanWithActiveMember("cp1", auth.TestPrincipal2)
+	carePlan.SupportingInfo = []fhir.Reference{{Reference: to.Ptr("DocumentReference/d1")}}
+	fhirClient := &test.StubFHIRClient{
+		Resources: []any{carePlan},
+	}
+
+	t.Run("create", func(t *testing.T) {
+		policy := CreateDocumentReferenceAuthzPolicy(FHIRClientFactoryFor(fhirClient))
+		testPolicies(t, []AuthzPolicyTest[*fhir.DocumentReference]{
+			{
+				name:      "allow (member of CarePlan's CareTeam)",
+				policy:    policy,
+				resource:  &documentReference,
+				principal: auth.TestPrincipal2,
+				wantAllow: true,
+			},
+			{
+				name:      "disallow (no context)",
+				policy:    policy,
+				resource:  &withoutContext,
+				principal: auth.TestPrincipal2,
+				wantAllow: false,
+			},
+		})
+	})
+	t.Run("read", func(t *testing.T) {
+		policy := ReadDocumentReferenceAuthzPolicy(FHIRClientFactoryFor(fhirClient))
+		testPolicies(t, []AuthzPolicyTest[*fhir.DocumentReference]{
+			{
+				name:      "allow (in CareTeam of CarePlan.supportingInfo)",
+				policy:    policy,
+				resource:  &documentReference,
+				principal: auth.TestPrincipal2,
+				wantAllow: true,
+			},
+			{
+				name:      "disallow (not in CareTeam)",
+				policy:    policy,
+				resource:  &documentReference,
+				principal: auth.TestPrincipal3,
+				wantAllow: false,
+			},
+		})
+	})
+}
@@ -0,0 +1,46 @@
+package careplanservice
+
+import "github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+
+// GoalCarePlanExtensionURL is the URL of the extension that specifies the CarePlan a Goal is created for,
+// since Goal has no element that refers to a CarePlan. The CPS adds the Goal to CarePlan.goal when it's created.
+const GoalCarePlanExtensionURL = "http://santeonnl.github.io/shared-care-planning/StructureDefinition/goal-careplan"
+
+func CreateGoalAuthzPolicy(fhirClientFactory FHIRClientFactory) Policy[*fhir.Goal] {
+	return CreateCarePlanResourceAuthzPolicy(fhirClientFactory, goalCarePlanReferences)
+}
+
+func UpdateGoalAuthzPolicy() Policy[*fhir.Goal] {
+	return CreatorPolicy[*fhir.Goal]{}
+}
+
+func ReadGoalAuthzPolicy(fhirClientFactory FHIRClientFactory) Policy[*fhir.Goal] {
+	return readGoalAuthzPolicy(fhirClientFactory, ReadCarePlanAuthzPolicy())
+}
+
+func readGoalAuthzPolicy(fhirClientFactory FHIRClientFactory, carePlanPolicy Policy[*fhir.CarePlan]) Policy[*fhir.Goal] {
+	return AnyMatchPolicy[*fhir.Goal]{
+		Policies: []Policy[*fhir.Goal]{
+			goalCarePlanRelation(fhirClientFactory, carePlanPolicy),
+			CreatorPolicy[*fhir.Goal]{},
+		},
+	}
+}
+
+// goalCarePlanRelation allows access to a Goal if the principal has access to a CarePlan that refers to it through CarePlan.goal.
+func goalCarePlanRelation(fhirClientFactory FHIRClientFactory, carePlanPolicy Policy[*fhir.CarePlan]) Policy[*fhir.Goal] {
+	return carePlanReferrerRelation[*fhir.Goal](fhirClientFactory, carePlanPolicy, "goal", func(carePlan *fhir.CarePlan) []fhir.Reference {
+		return carePlan.Goal
+	})
+}
+
+// goalCarePlanReferences returns the CarePlans the Goal is created for (see GoalCarePlanExtensionURL).
+func goalCarePlanReferences(goal *fhir.Goal) []fhir.Reference {
+	var result []fhir.Reference
+	for _, extension := range goal.Extension {
+		if extension.Url == GoalCarePlanExtensionURL && extension.ValueReference != nil {
+			result = append(result, *extension.ValueReference)
+		}
+	}
+	return result
+}
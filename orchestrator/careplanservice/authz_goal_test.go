@@ -0,0 +1,123 @@
+package careplanservice
+
+import (
+	"testing"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/test"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+func TestGoalAuthzPolicy(t *testing.T) {
+	goal := fhir.Goal{
+		Id: to.Ptr("g1"),
+		Extension: []fhir.Extension{
+			{
+				Url:            GoalCarePlanExtensionURL,
+				ValueReference: &fhir.Reference{Reference: to.Ptr("CarePlan/cp1")},
+			},
+		},
+	}
+	goalWithCreator := goal
+	goalWithCreator.Extension = append(append([]fhir.Extension{}, goal.Extension...), TestCreatorExtension...)
+	unlinkedGoal := fhir.Goal{Id: to.Ptr("g2")}
+	goalForTwoCarePlans := goal
+	goalForTwoCarePlans.Extension = append(append([]fhir.Extension{}, goal.Extension...), fhir.Extension{
+		Url:            GoalCarePlanExtensionURL,
+		ValueReference: &fhir.Reference{Reference: to.Ptr("CarePlan/cp2")},
+	})
+
+	carePlan := testCarePlanWithActiveMember("cp1", auth.TestPrincipal2)
+	carePlan.Goal = []fhir.Reference{{Reference: to.Ptr("Goal/g1")}}
+	otherCarePlan := testCarePlanWithActiveMember("cp2", auth.TestPrincipal3)
+	fhirClient := &test.StubFHIRClient{
+		Resources: []any{carePlan, otherCarePlan},
+	}
+
+	t.Run("create", func(t *testing.T) {
+		policy := CreateGoalAuthzPolicy(FHIRClientFactoryFor(fhirClient))
+		testPolicies(t, []AuthzPolicyTest[*fhir.Goal]{
+			{
+				name:      "allow (member of CarePlan's CareTeam)",
+				policy:    policy,
+				resource:  &goal,
+				principal: auth.TestPrincipal2,
+				wantAllow: true,
+			},
+			{
+				name:      "disallow (not a member of CarePlan's CareTeam)",
+				policy:    policy,
+				resource:  &goal,
+				principal: auth.TestPrincipal3,
+				wantAllow: false,
+			},
+			{
+				name:      "disallow (no CarePlan)",
+				policy:    policy,
+				resource:  &unlinkedGoal,
+				principal: auth.TestPrincipal2,
+				wantAllow: false,
+			},
+			{
+				name:      "disallow (member of only one of the CarePlans' CareTeams)",
+				policy:    policy,
+				resource:  &goalForTwoCarePlans,
+				principal: auth.TestPrincipal2,
+				wantAllow: false,
+			},
+		})
+	})
+	t.Run("update", func(t *testing.T) {
+		policy := UpdateGoalAuthzPolicy()
+		testPolicies(t, []AuthzPolicyTest[*fhir.Goal]{
+			{
+				name:      "allow (creator)",
+				policy:    policy,
+				resource:  &goalWithCreator,
+				principal: auth.TestPrincipal1,
+				wantAllow: true,
+			},
+			{
+				name:      "disallow (not the creator)",
+				policy:    policy,
+				resource:  &goalWithCreator,
+				principal: auth.TestPrincipal2,
+				wantAllow: false,
+			},
+		})
+	})
+	t.Run("read", func(t *testing.T) {
+		policy := ReadGoalAuthzPolicy(FHIRClientFactoryFor(fhirClient))
+		testPolicies(t, []AuthzPolicyTest[*fhir.Goal]{
+			{
+				name:      "allow (in CareTeam of CarePlan.goal)",
+				policy:    policy,
+				resource:  &goal,
+				principal: auth.TestPrincipal2,
+				wantAllow: true,
+			},
+			{
+				name:      "allow (creator)",
+				policy:    policy,
+				resource:  &goalWithCreator,
+				principal: auth.TestPrincipal1,
+				wantAllow: true,
+			},
+			{
+				name:      "disallow (not in CareTeam)",
+				policy:    policy,
+				resource:  &goal,
+				principal: auth.TestPrincipal3,
+				wantAllow: false,
+			},
+			{
+				name:      "disallow (not referenced by CarePlan)",
+				policy:    policy,
+				resource:  &unlinkedGoal,
+				principal: auth.TestPrincipal2,
+				wantAllow: false,
+			},
+		})
+	})
+}
@@ -0,0 +1,37 @@
+package careplanservice
+
+import "github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+
+func CreateObservationAuthzPolicy(fhirClientFactory FHIRClientFactory) Policy[*fhir.Observation] {
+	return CreateCarePlanResourceAuthzPolicy(fhirClientFactory, observationCarePlanReferences)
+}
+
+func UpdateObservationAuthzPolicy() Policy[*fhir.Observation] {
+	return CreatorPolicy[*fhir.Observation]{}
+}
+
+func ReadObservationAuthzPolicy(fhirClientFactory FHIRClientFactory) Policy[*fhir.Observation] {
+	return readObservationAuthzPolicy(fhirClientFactory, ReadCarePlanAuthzPolicy())
+}
+
+func readObservationAuthzPolicy(fhirClientFactory FHIRClientFactory, carePlanPolicy Policy[*fhir.CarePlan]) Policy[*fhir.Observation] {
+	return AnyMatchPolicy[*fhir.Observation]{
+		Policies: []Policy[*fhir.Observation]{
+			supportingInfoCarePlanRelation[*fhir.Observation](fhirClientFactory, carePlanPolicy),
+			CreatorPolicy[*fhir.Observation]{},
+		},
+	}
+}
+
+// observationCarePlanReferences returns the CarePlans the Observation is based on.
+func observationCarePlanReferences(observation *fhir.Observation) []fhir.Reference {
+	return observation.BasedOn
+}
+
+// supportingInfoCarePlanRelation allows access to a resource (e.g. Observation or DocumentReference)
+// if the principal has access to a CarePlan that refers to it through CarePlan.supportingInfo.
+func supportingInfoCarePlanRelation[T any](fhirClientFactory FHIRClientFactory, carePlanPolicy Policy[*fhir.CarePlan]) Policy[T] {
+	return carePlanReferrerRelation[T](fhirClientFactory, carePlanPolicy, "supporting-info", func(carePlan *fhir.CarePlan) []fhir.Reference {
+		return carePlan.SupportingInfo
+	})
+}
@@ -0,0 +1,72 @@
+package careplanservice
+
+import (
+	"testing"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/test"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+func TestObservationAuthzPolicy(t *testing.T) {
+	observation := fhir.Observation{
+		Id:      to.Ptr("o1"),
+		BasedOn: []fhir.Reference{{Reference: to.Ptr("CarePlan/cp1")}},
+	}
+	observationWithCreator := observation
+	observationWithCreator.Extension = TestCreatorExtension
+	unlinkedObservation := fhir.Observation{Id: to.Ptr("o2")}
+
+	carePlan := testCarePlanWithActiveMember("cp1", auth.TestPrincipal2)
+	carePlan.SupportingInfo = []fhir.Reference{{Reference: to.Ptr("Observation/o1")}}
+	fhirClient := &test.StubFHIRClient{
+		Resources: []any{carePlan},
+	}
+
+	t.Run("create", func(t *testing.T) {
+		policy := CreateObservationAuthzPolicy(FHIRClientFactoryFor(fhirClient))
+		testPolicies(t, []AuthzPolicyTest[*fhir.Observation]{
+			{
+				name:      "allow (member of CarePlan's CareTeam)",
+				policy:    policy,
+				resource:  &observation,
+				principal: auth.TestPrincipal2,
+				wantAllow: true,
+			},
+			{
+				name:      "disallow (not a member of CarePlan's CareTeam)",
+				policy:    policy,
+				resource:  &observation,
+				principal: auth.TestPrincipal3,
+				wantAllow: false,
+			},
+		})
+	})
+	t.Run("read", func(t *testing.T) {
+		policy := ReadObservationAuthzPolicy(FHIRClientFactoryFor(fhirClient))
+		testPolicies(t, []AuthzPolicyTest[*fhir.Observation]{
+			{
+				name:      "allow (in CareTeam of CarePlan.supportingInfo)",
+				policy:    policy,
+				resource:  &observation,
+				principal: auth.TestPrincipal2,
+				wantAllow: true,
+			},
+			{
+				name:      "allow (creator)",
+				policy:    policy,
+				resource:  &observationWithCreator,
+				principal: auth.TestPrincipal1,
+				wantAllow: true,
+			},
+			{
+				name:      "disallow (not referenced by CarePlan)",
+				policy:    policy,
+				resource:  &unlinkedObservation,
+				principal: auth.TestPrincipal2,
+				wantAllow: false,
+			},
+		})
+	})
+}
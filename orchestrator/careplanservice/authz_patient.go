@@ -18,16 +18,28 @@ func UpdatePatientAuthzPolicy() Policy[*fhir.Patient] {
 }
 
 func ReadPatientAuthzPolicy(fhirClientFactory FHIRClientFactory) Policy[*fhir.Patient] {
+	return readPatientAuthzPolicy(fhirClientFactory, ReadCarePlanAuthzPolicy())
+}
+
+func readPatientAuthzPolicy(fhirClientFactory FHIRClientFactory, carePlanPolicy Policy[*fhir.CarePlan]) Policy[*fhir.Patient] {
 	return AnyMatchPolicy[*fhir.Patient]{
 		Policies: []Policy[*fhir.Patient]{
-			RelatedResourcePolicy[*fhir.Patient, *fhir.CarePlan]{
-				fhirClientFactory:     fhirClientFactory,
-				relatedResourcePolicy: CareTeamMemberPolicy[fhir.CarePlan]{},
-				relatedResourceSearchParams: func(ctx context.Context, resource *fhir.Patient) (resourceType string, searchParams url.Values) {
-					return "CarePlan", url.Values{"subject": []string{"Patient/" + *resource.Id}}
-				},
-			},
+			patientCarePlanRelation(fhirClientFactory, carePlanPolicy),
 			CreatorPolicy[*fhir.Patient]{},
 		},
 	}
 }
+
+// patientCarePlanRelation allows access to a Patient if the principal has access to a CarePlan of which the Patient is the subject.
+func patientCarePlanRelation(fhirClientFactory FHIRClientFactory, carePlanPolicy Policy[*fhir.CarePlan]) Policy[*fhir.Patient] {
+	return RelatedResourcePolicy[*fhir.Patient, *fhir.CarePlan]{
+		fhirClientFactory:     fhirClientFactory,
+		relatedResourcePolicy: carePlanPolicy,
+		relatedResourceSearchParams: func(ctx context.Context, resource *fhir.Patient) (resourceType string, searchParams url.Values) {
+			return "CarePlan", url.Values{"subject": []string{"Patient/" + *resource.Id}}
+		},
+		isRelatedResource: func(resource *fhir.Patient, carePlan *fhir.CarePlan) bool {
+			return carePlan.Subject.Reference != nil && *carePlan.Subject.Reference == "Patient/"+*resource.Id
+		},
+	}
+}
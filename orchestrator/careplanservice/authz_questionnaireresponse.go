@@ -18,16 +18,25 @@ func UpdateQuestionnaireResponseAuthzPolicy() Policy[*fhir.QuestionnaireResponse
 }
 
 func ReadQuestionnaireResponseAuthzPolicy(fhirClientFactory FHIRClientFactory) Policy[*fhir.QuestionnaireResponse] {
+	return readQuestionnaireResponseAuthzPolicy(fhirClientFactory, ReadTaskAuthzPolicy(fhirClientFactory))
+}
+
+func readQuestionnaireResponseAuthzPolicy(fhirClientFactory FHIRClientFactory, taskPolicy Policy[*fhir.Task]) Policy[*fhir.QuestionnaireResponse] {
 	return AnyMatchPolicy[*fhir.QuestionnaireResponse]{
 		Policies: []Policy[*fhir.QuestionnaireResponse]{
-			RelatedResourcePolicy[*fhir.QuestionnaireResponse, *fhir.Task]{
-				fhirClientFactory:     fhirClientFactory,
-				relatedResourcePolicy: ReadTaskAuthzPolicy(fhirClientFactory),
-				relatedResourceSearchParams: func(ctx context.Context, resource *fhir.QuestionnaireResponse) (resourceType string, searchParams url.Values) {
-					return "Task", url.Values{"output-reference": []string{"QuestionnaireResponse/" + *resource.Id}}
-				},
-			},
+			questionnaireResponseTaskRelation(fhirClientFactory, taskPolicy),
 			CreatorPolicy[*fhir.QuestionnaireResponse]{},
 		},
 	}
 }
+
+// questionnaireResponseTaskRelation allows access to a QuestionnaireResponse if the principal has access to a Task that has it as output.
+func questionnaireResponseTaskRelation(fhirClientFactory FHIRClientFactory, taskPolicy Policy[*fhir.Task]) Policy[*fhir.QuestionnaireResponse] {
+	return RelatedResourcePolicy[*fhir.QuestionnaireResponse, *fhir.Task]{
+		fhirClientFactory:     fhirClientFactory,
+		relatedResourcePolicy: taskPolicy,
+		relatedResourceSearchParams: func(ctx context.Context, resource *fhir.QuestionnaireResponse) (resourceType string, searchParams url.Values) {
+			return "Task", url.Values{"output-reference": []string{"QuestionnaireResponse/" + *resource.Id}}
+		},
+	}
+}
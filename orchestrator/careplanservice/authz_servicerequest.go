@@ -18,16 +18,28 @@ func UpdateServiceRequestAuthzPolicy() Policy[*fhir.ServiceRequest] {
 }
 
 func ReadServiceRequestAuthzPolicy(fhirClientFactory FHIRClientFactory) Policy[*fhir.ServiceRequest] {
+	return readServiceRequestAuthzPolicy(fhirClientFactory, ReadTaskAuthzPolicy(fhirClientFactory))
+}
+
+func readServiceRequestAuthzPolicy(fhirClientFactory FHIRClientFactory, taskPolicy Policy[*fhir.Task]) Policy[*fhir.ServiceRequest] {
 	return AnyMatchPolicy[*fhir.ServiceRequest]{
 		Policies: []Policy[*fhir.ServiceRequest]{
-			RelatedResourcePolicy[*fhir.ServiceRequest, *fhir.Task]{
-				fhirClientFactory:     fhirClientFactory,
-				relatedResourcePolicy: ReadTaskAuthzPolicy(fhirClientFactory),
-				relatedResourceSearchParams: func(ctx context.Context, resource *fhir.ServiceRequest) (string, url.Values) {
-					return "Task", url.Values{"focus": []string{"ServiceRequest/" + *resource.Id}}
-				},
-			},
+			serviceRequestTaskRelation(fhirClientFactory, taskPolicy),
 			CreatorPolicy[*fhir.ServiceRequest]{},
 		},
 	}
 }
+
+// serviceRequestTaskRelation allows access to a ServiceRequest if the principal has access to a Task that has it as focus.
+func serviceRequestTaskRelation(fhirClientFactory FHIRClientFactory, taskPolicy Policy[*fhir.Task]) Policy[*fhir.ServiceRequest] {
+	return RelatedResourcePolicy[*fhir.ServiceRequest, *fhir.Task]{
+		fhirClientFactory:     fhirClientFactory,
+		relatedResourcePolicy: taskPolicy,
+		relatedResourceSearchParams: func(ctx context.Context, resource *fhir.ServiceRequest) (string, url.Values) {
+			return "Task", url.Values{"focus": []string{"ServiceRequest/" + *resource.Id}}
+		},
+		isRelatedResource: func(resource *fhir.ServiceRequest, task *fhir.Task) bool {
+			return task.Focus != nil && task.Focus.Reference != nil && *task.Focus.Reference == "ServiceRequest/"+*resource.Id
+		},
+	}
+}
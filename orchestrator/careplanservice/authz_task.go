@@ -7,27 +7,44 @@ import (
 )
 
 func ReadTaskAuthzPolicy(fhirClientFactory FHIRClientFactory) Policy[*fhir.Task] {
+	return readTaskAuthzPolicy(fhirClientFactory, ReadCarePlanAuthzPolicy())
+}
+
+func readTaskAuthzPolicy(fhirClientFactory FHIRClientFactory, carePlanPolicy Policy[*fhir.CarePlan]) Policy[*fhir.Task] {
 	return AnyMatchPolicy[*fhir.Task]{
 		Policies: []Policy[*fhir.Task]{
 			TaskOwnerOrRequesterPolicy[fhir.Task]{},
-			RelatedResourcePolicy[*fhir.Task, *fhir.CarePlan]{
-				fhirClientFactory:     fhirClientFactory,
-				relatedResourcePolicy: CareTeamMemberPolicy[fhir.CarePlan]{},
-				relatedResourceSearchParams: func(ctx context.Context, resource *fhir.Task) (string, url.Values) {
-					var ids []string
-					for _, reference := range resource.BasedOn {
-						if reference.Reference != nil {
-							ids = append(ids, getResourceID(*reference.Reference))
-						}
-					}
-					if len(ids) == 0 {
-						return "", nil
-					}
-					return "CarePlan", url.Values{
-						"_id": ids,
-					}
-				},
-			},
+			taskCarePlanRelation(fhirClientFactory, carePlanPolicy),
+		},
+	}
+}
+
+// taskCarePlanRelation allows access to a Task if the principal has access to a CarePlan the Task is based on.
+func taskCarePlanRelation(fhirClientFactory FHIRClientFactory, carePlanPolicy Policy[*fhir.CarePlan]) Policy[*fhir.Task] {
+	return RelatedResourcePolicy[*fhir.Task, *fhir.CarePlan]{
+		fhirClientFactory:     fhirClientFactory,
+		relatedResourcePolicy: carePlanPolicy,
+		relatedResourceSearchParams: func(ctx context.Context, resource *fhir.Task) (string, url.Values) {
+			var ids []string
+			for _, reference := range resource.BasedOn {
+				if reference.Reference != nil {
+					ids = append(ids, getResourceID(*reference.Reference))
+				}
+			}
+			if len(ids) == 0 {
+				return "", nil
+			}
+			return "CarePlan", url.Values{
+				"_id": ids,
+			}
+		},
+		isRelatedResource: func(resource *fhir.Task, carePlan *fhir.CarePlan) bool {
+			for _, reference := range resource.BasedOn {
+				if reference.Reference != nil && carePlan.Id != nil && getResourceID(*reference.Reference) == *carePlan.Id {
+					return true
+				}
+			}
+			return false
 		},
 	}
 }
@@ -235,3 +235,43 @@ func TestSetCreatorExtensionOnResource(t *testing.T) {
 		})
 	}
 }
+
+func TestUserRolePolicy_HasAccess(t *testing.T) {
+	nurse := fhir.Coding{System: to.Ptr("http://snomed.info/sct"), Code: to.Ptr("224535009")}
+	physician := fhir.Coding{System: to.Ptr("http://snomed.info/sct"), Code: to.Ptr("309343006")}
+	nursePrincipal := *auth.TestPrincipal1
+	nursePrincipal.User = &auth.User{
+		Practitioner: fhir.Practitioner{
+			Identifier: []fhir.Identifier{{System: to.Ptr("http://fhir.nl/fhir/NamingSystem/uzi-nr-pers"), Value: to.Ptr("1")}},
+		},
+		PractitionerRole: &fhir.PractitionerRole{
+			Code: []fhir.CodeableConcept{{Coding: []fhir.Coding{nurse}}},
+		},
+	}
+	testPolicies(t, []AuthzPolicyTest[any]{
+		{
+			name:      "allow (user has role)",
+			principal: &nursePrincipal,
+			wantAllow: true,
+			policy:    UserRolePolicy[any]{Roles: []fhir.Coding{physician, nurse}, Policy: AnyonePolicy[any]{}},
+		},
+		{
+			name:      "disallow (user doesn't have role)",
+			principal: &nursePrincipal,
+			wantAllow: false,
+			policy:    UserRolePolicy[any]{Roles: []fhir.Coding{physician}, Policy: AnyonePolicy[any]{}},
+		},
+		{
+			name:      "disallow (no user)",
+			principal: auth.TestPrincipal1,
+			wantAllow: false,
+			policy:    UserRolePolicy[any]{Roles: []fhir.Coding{nurse}, Policy: AnyonePolicy[any]{}},
+		},
+		{
+			name:      "disallow (wrapped policy denies)",
+			principal: &nursePrincipal,
+			wantAllow: false,
+			policy:    UserRolePolicy[any]{Roles: []fhir.Coding{nurse}, Policy: AnyMatchPolicy[any]{}},
+		},
+	})
+}
@@ -1,21 +1,85 @@
 package careplanservice
 
+import (
+	"errors"
+	"time"
+
+	"github.com/SanteonNL/orca/orchestrator/globals"
+)
+
 func DefaultConfig() Config {
-	return Config{}
+	return Config{
+		Authz: AuthzConfig{
+			BreakTheGlass: BreakTheGlassConfig{
+				Duration: time.Hour,
+			},
+		},
+	}
 }
 
 type Config struct {
 	Enabled bool         `koanf:"enabled"`
 	Events  EventsConfig `koanf:"events"`
+	Authz   AuthzConfig  `koanf:"authz"`
+	// ProfileValidation configures validation of incoming resources against FHIR profiles.
+	ProfileValidation ProfileValidationConfig `koanf:"profilevalidation"`
+	// Pseudonymization configures pseudonymous patient identifiers in CarePlans and Tasks.
+	Pseudonymization PseudonymizationConfig `koanf:"pseudonymization"`
 }
 
 func (c Config) Validate() error {
 	if !c.Enabled {
 		return nil
 	}
+	if c.Authz.BreakTheGlass.Enabled && c.Authz.BreakTheGlass.Duration <= 0 {
+		return errors.New("authz.breaktheglass.duration must be positive when break-the-glass is enabled")
+	}
+	if globals.StrictMode && c.Authz.ExplainAccess {
+		// $explain-access reads the resource before the caller is authorized, so it's only meant for debugging
+		return errors.New("authz.explainaccess is not allowed in strict mode")
+	}
+	if len(c.ProfileValidation.Profiles) > 0 && len(c.ProfileValidation.Packages) == 0 {
+		return errors.New("profilevalidation.packages must be set when profilevalidation.profiles is set")
+	}
 	return nil
 }
 
+// ProfileValidationConfig configures validation of created and updated resources against the profiles (StructureDefinitions) of FHIR packages,
+// e.g. the Shared Care Planning Implementation Guide.
+type ProfileValidationConfig struct {
+	// Packages contains the paths to FHIR packages (.tgz) containing the profiles. If not set, resources aren't validated against profiles.
+	Packages []string `koanf:"packages"`
+	// Profiles contains the canonical URLs of profiles that all resources of the profile's type must conform to.
+	// Resources are also validated against the loaded profiles they claim conformance to in meta.profile.
+	Profiles []string `koanf:"profiles"`
+}
+
+// PseudonymizationConfig configures pseudonymization of patient identifiers, see pseudonymizedSubject.
+type PseudonymizationConfig struct {
+	// Enabled enables issuing a pseudonymous patient identifier for new CarePlans, which replaces the BSN in CarePlan.subject and Task.for.
+	// It's disabled by default.
+	Enabled bool `koanf:"enabled"`
+}
+
+type AuthzConfig struct {
+	// PolicyFile is the path to a YAML file with authorization policies that replace the built-in policies,
+	// per tenant, resource type and interaction.
+	PolicyFile string `koanf:"policyfile"`
+	// ExplainAccess enables the $explain-access operation, which returns the authorization decision of the caller for a resource.
+	// It's intended for debugging authorization policies, and can't be enabled in strict mode.
+	ExplainAccess bool `koanf:"explainaccess"`
+	// BreakTheGlass configures emergency access to CarePlans for care organizations that aren't (yet) a member of the CareTeam.
+	BreakTheGlass BreakTheGlassConfig `koanf:"breaktheglass"`
+}
+
+// BreakTheGlassConfig configures break-the-glass (emergency) access, see BreakTheGlassPolicy.
+type BreakTheGlassConfig struct {
+	// Enabled enables break-the-glass access. It's disabled by default.
+	Enabled bool `koanf:"enabled"`
+	// Duration is how long a break-the-glass grant gives access to the CarePlan and its related resources.
+	Duration time.Duration `koanf:"duration"`
+}
+
 type EventsConfig struct {
 	WebHooks []WebHookEventHandlerConfig `koanf:"webhooks"`
 }
@@ -3,6 +3,7 @@ package careplanservice
 import (
 	"testing"
 
+	"github.com/SanteonNL/orca/orchestrator/globals"
 	"github.com/stretchr/testify/require"
 )
 
@@ -15,4 +16,32 @@ func TestConfig_Validate(t *testing.T) {
 		err := Config{Enabled: true}.Validate()
 		require.NoError(t, err)
 	})
+	t.Run("break-the-glass enabled without duration", func(t *testing.T) {
+		config := Config{Enabled: true}
+		config.Authz.BreakTheGlass.Enabled = true
+		err := config.Validate()
+		require.EqualError(t, err, "authz.breaktheglass.duration must be positive when break-the-glass is enabled")
+	})
+	t.Run("explain access in strict mode", func(t *testing.T) {
+		globals.StrictMode = true
+		defer func() {
+			globals.StrictMode = false
+		}()
+		config := Config{Enabled: true}
+		config.Authz.ExplainAccess = true
+		err := config.Validate()
+		require.EqualError(t, err, "authz.explainaccess is not allowed in strict mode")
+	})
+	t.Run("explain access in non-strict mode", func(t *testing.T) {
+		config := Config{Enabled: true}
+		config.Authz.ExplainAccess = true
+		err := config.Validate()
+		require.NoError(t, err)
+	})
+	t.Run("profiles without packages", func(t *testing.T) {
+		config := Config{Enabled: true}
+		config.ProfileValidation.Profiles = []string{"http://example.com/StructureDefinition/task"}
+		err := config.Validate()
+		require.EqualError(t, err, "profilevalidation.packages must be set when profilevalidation.profiles is set")
+	})
 }
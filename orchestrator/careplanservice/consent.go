@@ -0,0 +1,122 @@
+package careplanservice
+
+import (
+	"context"
+	"log/slog"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/lib/consent"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/logging"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+// consentRequestFor returns the consent request for sharing the given resource with the principal of the given request,
+// or nil if no consent check is required: the principal is the local care organization,
+// or the resource isn't patient data (e.g. a Questionnaire).
+// If the resource refers to the patient by a pseudonym, consent is checked for the BSN of the referenced Patient.
+func consentRequestFor(ctx context.Context, fhirClient fhirclient.Client, request FHIRHandlerRequest, resource any) (*consent.Request, error) {
+	if request.LocalIdentity == nil || coolfhir.HasIdentifier(*request.LocalIdentity, request.Principal.Organization.Identifier...) {
+		return nil, nil
+	}
+	patient := patientIdentifierOf(resource)
+	if patient == nil {
+		return nil, nil
+	}
+	if isPatientPseudonym(patient) {
+		var err error
+		if patient, err = resolvePseudonym(ctx, fhirClient, *patientSubjectOf(resource)); err != nil {
+			return nil, err
+		}
+	}
+	return &consent.Request{
+		Patient:   *patient,
+		Custodian: []fhir.Identifier{*request.LocalIdentity},
+		Actor:     request.Principal.Organization.Identifier,
+	}, nil
+}
+
+// patientIdentifierOf returns the identifier of the patient the given resource is about, or nil if it's unknown.
+// For Patient resources, the BSN is preferred.
+func patientIdentifierOf(resource any) *fhir.Identifier {
+	if patient, ok := resource.(*fhir.Patient); ok {
+		if bsn := coolfhir.FilterFirstIdentifier(&patient.Identifier, coolfhir.BSNNamingSystem); bsn != nil {
+			return bsn
+		}
+		if len(patient.Identifier) > 0 {
+			return &patient.Identifier[0]
+		}
+		return nil
+	}
+	subject := patientSubjectOf(resource)
+	if subject == nil || !coolfhir.IsLogicalIdentifier(subject.Identifier) {
+		return nil
+	}
+	return subject.Identifier
+}
+
+// patientSubjectOf returns the reference to the patient the given resource is about, or nil if the resource has none.
+func patientSubjectOf(resource any) *fhir.Reference {
+	switch r := resource.(type) {
+	case *fhir.CarePlan:
+		return &r.Subject
+	case *fhir.Condition:
+		return &r.Subject
+	case *fhir.ServiceRequest:
+		return &r.Subject
+	case *fhir.Task:
+		return r.For
+	case *fhir.QuestionnaireResponse:
+		return r.Subject
+	case *fhir.Goal:
+		return &r.Subject
+	case *fhir.Observation:
+		return r.Subject
+	case *fhir.DocumentReference:
+		return r.Subject
+	case *fhir.Communication:
+		return r.Subject
+	}
+	return nil
+}
+
+// consentFilter checks consent for search results, caching decisions per patient within the search.
+// If consent can't be checked, the resource is considered not permitted.
+type consentFilter struct {
+	checker consent.Checker
+	// fhirClient is used to resolve pseudonymized subjects to the BSN of the referenced Patient,
+	// and to record consent denials as AuditEvent.
+	fhirClient fhirclient.Client
+	decisions  map[string]bool
+}
+
+func (f *consentFilter) permitted(ctx context.Context, request FHIRHandlerRequest, resource any) bool {
+	if f.checker == nil {
+		return true
+	}
+	consentRequest, err := consentRequestFor(ctx, f.fhirClient, request, resource)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error resolving patient for consent check, excluding search result", slog.String(logging.FieldError, err.Error()))
+		return false
+	}
+	if consentRequest == nil {
+		return true
+	}
+	key := coolfhir.IdentifierToToken(consentRequest.Patient)
+	if permitted, ok := f.decisions[key]; ok {
+		return permitted
+	}
+	decision, err := f.checker.Check(ctx, *consentRequest)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error checking consent, excluding search result", slog.String(logging.FieldError, err.Error()))
+		return false
+	}
+	if !decision.Permitted {
+		consent.Audit(ctx, f.fhirClient, *consentRequest, *decision)
+	}
+	if f.decisions == nil {
+		f.decisions = make(map[string]bool)
+	}
+	f.decisions[key] = decision.Permitted
+	return decision.Permitted
+}
@@ -0,0 +1,91 @@
+package careplanservice
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/test"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+func TestConsentRequestFor(t *testing.T) {
+	ctx := context.Background()
+	fhirClient := &test.StubFHIRClient{}
+	bsn := fhir.Identifier{System: to.Ptr(coolfhir.BSNNamingSystem), Value: to.Ptr("111222333")}
+	request := FHIRHandlerRequest{
+		Principal:     auth.TestPrincipal2,
+		LocalIdentity: &auth.TestPrincipal1.Organization.Identifier[0],
+	}
+	t.Run("Patient, BSN is preferred", func(t *testing.T) {
+		patient := &fhir.Patient{Identifier: []fhir.Identifier{
+			{System: to.Ptr("http://example.com/mrn"), Value: to.Ptr("1")},
+			bsn,
+		}}
+
+		result, err := consentRequestFor(ctx, fhirClient, request, patient)
+
+		require.NoError(t, err)
+		require.NotNil(t, result)
+		require.Equal(t, bsn, result.Patient)
+		require.Equal(t, []fhir.Identifier{*request.LocalIdentity}, result.Custodian)
+		require.Equal(t, auth.TestPrincipal2.Organization.Identifier, result.Actor)
+	})
+	t.Run("CarePlan", func(t *testing.T) {
+		result, err := consentRequestFor(ctx, fhirClient, request, &fhir.CarePlan{Subject: fhir.Reference{Identifier: &bsn}})
+
+		require.NoError(t, err)
+		require.Equal(t, bsn, result.Patient)
+	})
+	t.Run("subject without identifier", func(t *testing.T) {
+		result, err := consentRequestFor(ctx, fhirClient, request, &fhir.CarePlan{Subject: fhir.Reference{Reference: to.Ptr("Patient/1")}})
+
+		require.NoError(t, err)
+		require.Nil(t, result)
+	})
+	t.Run("not patient data", func(t *testing.T) {
+		result, err := consentRequestFor(ctx, fhirClient, request, &fhir.Questionnaire{})
+
+		require.NoError(t, err)
+		require.Nil(t, result)
+	})
+	t.Run("principal is local care organization", func(t *testing.T) {
+		localRequest := request
+		localRequest.Principal = auth.TestPrincipal1
+
+		result, err := consentRequestFor(ctx, fhirClient, localRequest, &fhir.CarePlan{Subject: fhir.Reference{Identifier: &bsn}})
+
+		require.NoError(t, err)
+		require.Nil(t, result)
+	})
+	t.Run("pseudonymized subject is resolved to the BSN of the Patient", func(t *testing.T) {
+		fhirClient := &test.StubFHIRClient{Resources: []any{fhir.Patient{Id: to.Ptr("1"), Identifier: []fhir.Identifier{bsn}}}}
+		task := &fhir.Task{For: to.Ptr(pseudonymizedSubject(fhir.Reference{Reference: to.Ptr("Patient/1")}))}
+
+		result, err := consentRequestFor(ctx, fhirClient, request, task)
+
+		require.NoError(t, err)
+		require.Equal(t, bsn, result.Patient)
+	})
+	t.Run("pseudonymized subject, Patient has no BSN", func(t *testing.T) {
+		fhirClient := &test.StubFHIRClient{Resources: []any{fhir.Patient{Id: to.Ptr("1")}}}
+		carePlan := &fhir.CarePlan{Subject: pseudonymizedSubject(fhir.Reference{Reference: to.Ptr("Patient/1")})}
+
+		result, err := consentRequestFor(ctx, fhirClient, request, carePlan)
+
+		require.EqualError(t, err, "Patient of pseudonymized subject has no BSN")
+		require.Nil(t, result)
+	})
+	t.Run("pseudonymized subject, reading Patient fails", func(t *testing.T) {
+		fhirClient := &test.StubFHIRClient{Error: errors.New("oops")}
+		carePlan := &fhir.CarePlan{Subject: pseudonymizedSubject(fhir.Reference{Reference: to.Ptr("Patient/1")})}
+
+		_, err := consentRequestFor(ctx, fhirClient, request, carePlan)
+
+		require.EqualError(t, err, "failed to read Patient of pseudonymized subject: oops")
+	})
+}
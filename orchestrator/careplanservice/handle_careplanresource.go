@@ -0,0 +1,182 @@
+package careplanservice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/debug"
+	"github.com/SanteonNL/orca/orchestrator/lib/otel"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// carePlanLinkingHandler wraps the create or update handler of a resource type that is shared through a CarePlan,
+// but which the CarePlan refers to instead of the other way around (e.g. CarePlan.goal, CarePlan.supportingInfo).
+// After the resource has been added to the transaction, it adds a reference to the resource to the CarePlan(s) it was created for,
+// so the CarePlan's CareTeam members get access to it. The updated CarePlans are notified to the CareTeam.
+type carePlanLinkingHandler[T fhir.HasExtension] struct {
+	fhirClientFactory FHIRClientFactory
+	handler           func(context.Context, FHIRHandlerRequest, *coolfhir.BundleBuilder) (FHIRHandlerResult, error)
+	// carePlanReferences returns the references to the CarePlans the resource was created for.
+	carePlanReferences func(resource T) []fhir.Reference
+	// carePlanElement returns the CarePlan element that should refer to the resource.
+	carePlanElement func(carePlan *fhir.CarePlan) *[]fhir.Reference
+	// linkPolicy authorizes adding an updated resource to a CarePlan it wasn't added to before (e.g. when the CarePlan reference was changed).
+	// Created resources are already authorized by their create policy, which requires access to all CarePlans they refer to.
+	linkPolicy Policy[T]
+}
+
+func (h carePlanLinkingHandler[T]) Handle(ctx context.Context, request FHIRHandlerRequest, tx *coolfhir.BundleBuilder) (FHIRHandlerResult, error) {
+	ctx, span := tracer.Start(
+		ctx,
+		debug.GetFullCallerName(),
+		trace.WithSpanKind(trace.SpanKindServer),
+	)
+	defer span.End()
+
+	resourceEntryIdx := len(tx.Entry)
+	result, err := h.handler(ctx, request, tx)
+	if err != nil {
+		return nil, err
+	}
+	resourceEntry := tx.Entry[resourceEntryIdx]
+	var resource T
+	if err := json.Unmarshal(resourceEntry.Resource, &resource); err != nil {
+		return nil, otel.Error(span, err)
+	}
+	// Newly created resources are referenced by their fullUrl, which the FHIR server resolves when executing the transaction.
+	resourceReference := fhir.Reference{
+		Type: to.Ptr(coolfhir.ResourceType(resource)),
+	}
+	if resourceEntry.Request.Method == fhir.HTTPVerbPOST {
+		resourceReference.Reference = resourceEntry.FullUrl
+	} else if resourceID := coolfhir.ResourceID(resource); resourceID != nil {
+		resourceReference.Reference = to.Ptr(*resourceReference.Type + "/" + *resourceID)
+	} else {
+		// Conditional update without ID: the resource was linked when it was created
+		return result, nil
+	}
+
+	fhirClient, err := h.fhirClientFactory(ctx)
+	if err != nil {
+		return nil, otel.Error(span, err)
+	}
+	authorizeLink := func() error {
+		if resourceEntry.Request.Method == fhir.HTTPVerbPOST || h.linkPolicy == nil {
+			return nil
+		}
+		decision, err := h.linkPolicy.HasAccess(ctx, resource, *request.Principal)
+		if decision == nil || !decision.Allowed {
+			if err != nil {
+				otel.Error(span, err, "authorization check failed")
+			}
+			return &coolfhir.ErrorWithCode{
+				Message:    fmt.Sprintf("Participant is not authorized to add %s to CarePlan", *resourceReference.Type),
+				StatusCode: http.StatusForbidden,
+			}
+		}
+		return nil
+	}
+	var carePlanEntryIdxs []int
+	for _, carePlanReference := range h.carePlanReferences(resource) {
+		if carePlanReference.Reference == nil || !strings.HasPrefix(*carePlanReference.Reference, "CarePlan/") {
+			continue
+		}
+		carePlanEntryIdx, err := h.linkToCarePlan(ctx, fhirClient, request, tx, *carePlanReference.Reference, resourceReference, authorizeLink)
+		if err != nil {
+			return nil, otel.Error(span, err)
+		}
+		if carePlanEntryIdx >= 0 {
+			carePlanEntryIdxs = append(carePlanEntryIdxs, carePlanEntryIdx)
+		}
+	}
+
+	span.SetStatus(codes.Ok, "")
+	return func(txResult *fhir.Bundle) ([]*fhir.BundleEntry, []any, error) {
+		entries, notifications, err := result(txResult)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, carePlanEntryIdx := range carePlanEntryIdxs {
+			var updatedCarePlan fhir.CarePlan
+			_, err = coolfhir.NormalizeTransactionBundleResponseEntry(ctx, fhirClient, request.BaseURL, &tx.Entry[carePlanEntryIdx], &txResult.Entry[carePlanEntryIdx], &updatedCarePlan)
+			if err != nil {
+				return nil, nil, err
+			}
+			notifications = append(notifications, &updatedCarePlan)
+		}
+		return entries, notifications, nil
+	}, nil
+}
+
+// linkToCarePlan adds the resource reference to the CarePlan, by adding a CarePlan update to the transaction.
+// If the transaction already updates the CarePlan (e.g. multiple Goals are created in one transaction), that update is amended instead.
+// It returns the index of the added CarePlan update in the transaction, or -1 if no update was added.
+func (h carePlanLinkingHandler[T]) linkToCarePlan(ctx context.Context, fhirClient fhirclient.Client, request FHIRHandlerRequest, tx *coolfhir.BundleBuilder, carePlanReference string, resourceReference fhir.Reference, authorizeLink func() error) (int, error) {
+	for i, entry := range tx.Entry {
+		if entry.Request == nil || entry.Request.Method != fhir.HTTPVerbPUT || entry.Request.Url != carePlanReference {
+			continue
+		}
+		var carePlan fhir.CarePlan
+		if err := json.Unmarshal(entry.Resource, &carePlan); err != nil {
+			return -1, err
+		}
+		if !h.addReference(&carePlan, resourceReference) {
+			return -1, nil
+		}
+		if err := authorizeLink(); err != nil {
+			return -1, err
+		}
+		tx.Entry[i].Resource, _ = json.Marshal(carePlan)
+		return -1, nil
+	}
+
+	var carePlan fhir.CarePlan
+	if err := fhirClient.ReadWithContext(ctx, carePlanReference, &carePlan); err != nil {
+		return -1, fmt.Errorf("failed to read %s: %w", carePlanReference, err)
+	}
+	if !h.addReference(&carePlan, resourceReference) {
+		return -1, nil
+	}
+	if err := authorizeLink(); err != nil {
+		return -1, err
+	}
+	carePlanEntryIdx := len(tx.Entry)
+	tx.Update(carePlan, carePlanReference, coolfhir.WithAuditEvent(ctx, tx, coolfhir.AuditEventInfo{
+		ActingAgent: &fhir.Reference{
+			Identifier: &request.Principal.Organization.Identifier[0],
+			Type:       to.Ptr("Organization"),
+		},
+		ActingUser: request.Principal.UserAuditAgent(),
+		Observer:   *request.LocalIdentity,
+		Action:     fhir.AuditEventActionU,
+	}))
+	return carePlanEntryIdx, nil
+}
+
+// addReference adds the resource reference to the CarePlan element, if it isn't there yet. It returns whether the CarePlan was changed.
+func (h carePlanLinkingHandler[T]) addReference(carePlan *fhir.CarePlan, resourceReference fhir.Reference) bool {
+	element := h.carePlanElement(carePlan)
+	for _, reference := range *element {
+		if reference.Reference != nil && *reference.Reference == *resourceReference.Reference {
+			return false
+		}
+	}
+	*element = append(*element, resourceReference)
+	return true
+}
+
+func carePlanGoals(carePlan *fhir.CarePlan) *[]fhir.Reference {
+	return &carePlan.Goal
+}
+
+func carePlanSupportingInfo(carePlan *fhir.CarePlan) *[]fhir.Reference {
+	return &carePlan.SupportingInfo
+}
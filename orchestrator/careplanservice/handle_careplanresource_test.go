@@ -0,0 +1,158 @@
+package careplanservice
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/SanteonNL/orca/orchestrator/cmd/profile"
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/must"
+	"github.com/SanteonNL/orca/orchestrator/lib/test"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+func TestCarePlanLinkingHandler_Handle(t *testing.T) {
+	carePlan := testCarePlanWithActiveMember("cp1", auth.TestPrincipal1)
+	goal := fhir.Goal{
+		Extension: []fhir.Extension{
+			{
+				Url:            GoalCarePlanExtensionURL,
+				ValueReference: &fhir.Reference{Reference: to.Ptr("CarePlan/cp1")},
+			},
+		},
+	}
+	newHandler := func(fhirClient *test.StubFHIRClient) carePlanLinkingHandler[*fhir.Goal] {
+		return carePlanLinkingHandler[*fhir.Goal]{
+			fhirClientFactory: FHIRClientFactoryFor(fhirClient),
+			handler: FHIRCreateOperationHandler[*fhir.Goal]{
+				authzPolicy:       AnyonePolicy[*fhir.Goal]{},
+				fhirClientFactory: FHIRClientFactoryFor(fhirClient),
+				profile:           profile.Test(),
+			}.Handle,
+			carePlanReferences: goalCarePlanReferences,
+			carePlanElement:    carePlanGoals,
+		}
+	}
+	request := FHIRHandlerRequest{
+		HttpMethod:    http.MethodPost,
+		ResourceData:  must.MarshalJSON(goal),
+		ResourcePath:  "Goal",
+		Principal:     auth.TestPrincipal1,
+		LocalIdentity: &auth.TestPrincipal2.Organization.Identifier[0],
+		Tenant:        tenants.Test().Sole(),
+		BaseURL:       must.ParseURL("http://example.com/fhir"),
+	}
+	carePlanGoalsInTx := func(t *testing.T, tx *coolfhir.BundleBuilder) []fhir.Reference {
+		var carePlanEntries []fhir.BundleEntry
+		for _, entry := range tx.Entry {
+			if entry.Request.Method == fhir.HTTPVerbPUT && entry.Request.Url == "CarePlan/cp1" {
+				carePlanEntries = append(carePlanEntries, entry)
+			}
+		}
+		require.Len(t, carePlanEntries, 1)
+		var updatedCarePlan fhir.CarePlan
+		require.NoError(t, json.Unmarshal(carePlanEntries[0].Resource, &updatedCarePlan))
+		return updatedCarePlan.Goal
+	}
+
+	t.Run("adds created Goal to CarePlan", func(t *testing.T) {
+		fhirClient := &test.StubFHIRClient{Resources: []any{carePlan}}
+		tx := coolfhir.Transaction()
+
+		result, err := newHandler(fhirClient).Handle(context.Background(), request, tx)
+
+		require.NoError(t, err)
+		goals := carePlanGoalsInTx(t, tx)
+		require.Len(t, goals, 1)
+		require.Equal(t, *tx.Entry[0].FullUrl, *goals[0].Reference)
+		var auditEvents []fhir.AuditEvent
+		for _, entry := range tx.Entry {
+			if coolfhir.EntryIsOfType("AuditEvent")(entry) {
+				var auditEvent fhir.AuditEvent
+				require.NoError(t, json.Unmarshal(entry.Resource, &auditEvent))
+				auditEvents = append(auditEvents, auditEvent)
+			}
+		}
+		require.Len(t, auditEvents, 2)
+		require.Equal(t, fhir.AuditEventActionU, *auditEvents[1].Action)
+		require.Equal(t, "CarePlan/cp1", *auditEvents[1].Entity[0].What.Reference)
+
+		txResult := fhir.Bundle{}
+		for _, entry := range tx.Entry {
+			txResult.Entry = append(txResult.Entry, fhir.BundleEntry{
+				Resource: entry.Resource,
+				Response: &fhir.BundleEntryResponse{Status: "201 Created"},
+			})
+		}
+		entries, notifications, err := result(&txResult)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		require.Len(t, notifications, 2)
+		require.IsType(t, &fhir.Goal{}, notifications[0])
+		require.IsType(t, &fhir.CarePlan{}, notifications[1])
+	})
+	t.Run("multiple Goals for the same CarePlan in one transaction", func(t *testing.T) {
+		fhirClient := &test.StubFHIRClient{Resources: []any{carePlan}}
+		tx := coolfhir.Transaction()
+
+		_, err := newHandler(fhirClient).Handle(context.Background(), request, tx)
+		require.NoError(t, err)
+		_, err = newHandler(fhirClient).Handle(context.Background(), request, tx)
+		require.NoError(t, err)
+
+		require.Len(t, carePlanGoalsInTx(t, tx), 2)
+	})
+	t.Run("update of Goal already in CarePlan", func(t *testing.T) {
+		carePlan := carePlan
+		carePlan.Goal = []fhir.Reference{{Reference: to.Ptr("Goal/g1"), Type: to.Ptr("Goal")}}
+		fhirClient := &test.StubFHIRClient{Resources: []any{carePlan}}
+		goal := goal
+		goal.Id = to.Ptr("g1")
+		request := request
+		request.HttpMethod = http.MethodPut
+		request.ResourcePath = "Goal/g1"
+		request.ResourceData = must.MarshalJSON(goal)
+		request.Upsert = true
+		tx := coolfhir.Transaction()
+
+		_, err := newHandler(fhirClient).Handle(context.Background(), request, tx)
+
+		require.NoError(t, err)
+		for _, entry := range tx.Entry {
+			require.NotEqual(t, "CarePlan/cp1", entry.Request.Url)
+		}
+	})
+	t.Run("update adding Goal to CarePlan of another CareTeam", func(t *testing.T) {
+		fhirClient := &test.StubFHIRClient{Resources: []any{carePlan}}
+		goal := goal
+		goal.Id = to.Ptr("g1")
+		request := request
+		request.HttpMethod = http.MethodPut
+		request.ResourcePath = "Goal/g1"
+		request.ResourceData = must.MarshalJSON(goal)
+		request.Upsert = true
+		request.Principal = auth.TestPrincipal3
+		handler := newHandler(fhirClient)
+		handler.linkPolicy = CreateGoalAuthzPolicy(FHIRClientFactoryFor(fhirClient))
+
+		_, err := handler.Handle(context.Background(), request, coolfhir.Transaction())
+
+		var errWithCode *coolfhir.ErrorWithCode
+		require.ErrorAs(t, err, &errWithCode)
+		require.Equal(t, http.StatusForbidden, errWithCode.StatusCode)
+	})
+	t.Run("CarePlan not found", func(t *testing.T) {
+		fhirClient := &test.StubFHIRClient{}
+		tx := coolfhir.Transaction()
+
+		_, err := newHandler(fhirClient).Handle(context.Background(), request, tx)
+
+		require.ErrorContains(t, err, "failed to read CarePlan/cp1")
+	})
+}
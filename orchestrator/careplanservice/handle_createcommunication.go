@@ -0,0 +1,157 @@
+package careplanservice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/debug"
+	"github.com/SanteonNL/orca/orchestrator/lib/logging"
+	"github.com/SanteonNL/orca/orchestrator/lib/otel"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// handleCreateCommunication handles the creation of a Communication (secure message) between members of a CarePlan's CareTeam.
+// The Communication must be based on a single CarePlan. The principal's organization is the sender,
+// and the recipients must be active members of the CareTeam. If no recipients are specified, the message is sent to all other active members.
+// The recipients are notified of the Communication through their subscriptions.
+func (s *Service) handleCreateCommunication(ctx context.Context, request FHIRHandlerRequest, tx *coolfhir.BundleBuilder) (FHIRHandlerResult, error) {
+	ctx, span := tracer.Start(
+		ctx,
+		debug.GetFullCallerName(),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String(otel.FHIRResourceType, "Communication"),
+		),
+	)
+	defer span.End()
+
+	var communication fhir.Communication
+	if err := json.Unmarshal(request.ResourceData, &communication); err != nil {
+		return nil, otel.Error(span, fmt.Errorf("invalid %T: %w", communication, coolfhir.BadRequestError(err)))
+	}
+
+	policy := authzPolicy[*fhir.Communication](s, request.Tenant.ID, "Communication", AuthzInteractionCreate)
+	authzDecision, err := policy.HasAccess(ctx, &communication, *request.Principal)
+	if authzDecision == nil || !authzDecision.Allowed {
+		if err != nil {
+			slog.ErrorContext(ctx, "Error checking if principal is authorized to create Communication",
+				slog.String(logging.FieldError, otel.Error(span, err, "authorization check failed").Error()),
+			)
+		}
+		return nil, otel.Error(span, &coolfhir.ErrorWithCode{
+			Message:    "Participant is not authorized to create Communication",
+			StatusCode: http.StatusForbidden,
+		})
+	}
+
+	if err := s.prepareCommunication(ctx, request, &communication); err != nil {
+		return nil, otel.Error(span, err)
+	}
+	span.SetAttributes(attribute.Int("fhir.communication.recipient_count", len(communication.Recipient)))
+
+	request.ResourceData, _ = json.Marshal(communication)
+	return FHIRCreateOperationHandler[*fhir.Communication]{
+		authzPolicy:       policy,
+		fhirClientFactory: s.createFHIRClient,
+		profile:           s.profile,
+		validator:         resourceValidator[*fhir.Communication](s),
+	}.Handle(ctx, request, tx)
+}
+
+// prepareCommunication validates a new or updated Communication: it must be based on a single CarePlan,
+// the principal's organization must be the sender, and the recipients must be active members of the CarePlan's CareTeam.
+// It sets the sender, recipients (all other active members, if none are specified) and sent time if they're absent.
+func (s *Service) prepareCommunication(ctx context.Context, request FHIRHandlerRequest, communication *fhir.Communication) error {
+	fhirClient, err := s.createFHIRClient(ctx)
+	if err != nil {
+		return err
+	}
+	carePlanReference, err := communicationBasedOn(*communication)
+	if err != nil {
+		return coolfhir.BadRequestError(err)
+	}
+	var carePlan fhir.CarePlan
+	if err := fhirClient.ReadWithContext(ctx, carePlanReference, &carePlan); err != nil {
+		return fmt.Errorf("failed to read %s: %w", carePlanReference, err)
+	}
+	careTeam, err := coolfhir.CareTeamFromCarePlan(&carePlan)
+	if err != nil {
+		return fmt.Errorf("failed to read CareTeam of %s: %w", carePlanReference, err)
+	}
+
+	sender := request.Principal.Organization.Identifier[0]
+	if communication.Sender == nil {
+		communication.Sender = &fhir.Reference{
+			Type:       to.Ptr("Organization"),
+			Identifier: &sender,
+		}
+	} else if communication.Sender.Identifier == nil || !coolfhir.HasIdentifier(*communication.Sender.Identifier, request.Principal.Organization.Identifier...) {
+		return coolfhir.BadRequest("Communication.sender must be the requesting organization")
+	}
+
+	now := time.Now()
+	if len(communication.Recipient) == 0 {
+		for _, participant := range careTeam.Participant {
+			if participant.Member == nil || !coolfhir.IsLogicalIdentifier(participant.Member.Identifier) ||
+				coolfhir.HasIdentifier(*participant.Member.Identifier, request.Principal.Organization.Identifier...) {
+				continue
+			}
+			if active, _ := coolfhir.ValidateCareTeamParticipantPeriod(participant, now); active {
+				communication.Recipient = append(communication.Recipient, *participant.Member)
+			}
+		}
+		if len(communication.Recipient) == 0 {
+			return coolfhir.BadRequest("CareTeam of %s has no other active members to send the Communication to", carePlanReference)
+		}
+	} else {
+		for _, recipient := range communication.Recipient {
+			if !coolfhir.IsLogicalIdentifier(recipient.Identifier) {
+				return coolfhir.BadRequest("Communication.recipient must be a logical reference to an organization")
+			}
+			if !isActiveCareTeamMemberIdentifier(*recipient.Identifier, careTeam, now) {
+				return coolfhir.BadRequest("Communication.recipient %s is not an active member of the CareTeam", coolfhir.IdentifierToToken(*recipient.Identifier))
+			}
+		}
+	}
+	if communication.Sent == nil {
+		communication.Sent = to.Ptr(now.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// communicationBasedOn returns the CarePlan reference the Communication is based on, e.g. CarePlan/123.
+func communicationBasedOn(communication fhir.Communication) (string, error) {
+	var result []string
+	for _, reference := range communication.BasedOn {
+		if reference.Reference != nil && strings.HasPrefix(*reference.Reference, "CarePlan/") {
+			result = append(result, *reference.Reference)
+		}
+	}
+	if len(result) != 1 {
+		return "", errors.New("Communication.basedOn must contain a relative reference to exactly one CarePlan")
+	}
+	return result[0], nil
+}
+
+// isActiveCareTeamMemberIdentifier returns whether the organization identified by the identifier is an active participant of the CareTeam.
+func isActiveCareTeamMemberIdentifier(identifier fhir.Identifier, careTeam *fhir.CareTeam, now time.Time) bool {
+	for _, participant := range careTeam.Participant {
+		if participant.Member == nil || participant.Member.Identifier == nil || !coolfhir.IdentifierEquals(participant.Member.Identifier, &identifier) {
+			continue
+		}
+		if active, _ := coolfhir.ValidateCareTeamParticipantPeriod(participant, now); active {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package careplanservice
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/cmd/profile"
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/must"
+	"github.com/SanteonNL/orca/orchestrator/lib/test"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+func TestService_handleCreateCommunication(t *testing.T) {
+	tenant := tenants.Test().Sole()
+	member := func(principal *auth.Principal, period fhir.Period) fhir.CareTeamParticipant {
+		return fhir.CareTeamParticipant{
+			Member: &fhir.Reference{
+				Type:       to.Ptr("Organization"),
+				Identifier: &principal.Organization.Identifier[0],
+			},
+			Period: &period,
+		}
+	}
+	carePlan := fhir.CarePlan{
+		Id:       to.Ptr("cp1"),
+		CareTeam: []fhir.Reference{{Type: to.Ptr("CareTeam"), Reference: to.Ptr("#ct")}},
+		Contained: must.MarshalJSON([]fhir.CareTeam{
+			{
+				Id: to.Ptr("ct"),
+				Participant: []fhir.CareTeamParticipant{
+					member(auth.TestPrincipal1, fhir.Period{Start: to.Ptr("2020-01-01T00:00:00Z")}),
+					member(auth.TestPrincipal2, fhir.Period{Start: to.Ptr("2020-01-01T00:00:00Z")}),
+					// Former member
+					member(auth.TestPrincipal3, fhir.Period{Start: to.Ptr("2020-01-01T00:00:00Z"), End: to.Ptr("2021-01-01T00:00:00Z")}),
+				},
+			},
+		}),
+	}
+	message := fhir.Communication{
+		Status:  fhir.EventStatusCompleted,
+		BasedOn: []fhir.Reference{{Reference: to.Ptr("CarePlan/cp1")}},
+		Payload: []fhir.CommunicationPayload{{ContentString: to.Ptr("Patient called about medication")}},
+	}
+	create := func(t *testing.T, principal *auth.Principal, communication fhir.Communication) (*fhir.Communication, error) {
+		service := &Service{
+			profile: profile.Test(),
+			fhirClientByTenant: map[string]fhirclient.Client{
+				tenant.ID: &test.StubFHIRClient{Resources: []any{carePlan}},
+			},
+		}
+		tx := coolfhir.Transaction()
+		ctx := tenants.WithTenant(context.Background(), tenant)
+		_, err := service.handleCreateCommunication(ctx, FHIRHandlerRequest{
+			HttpMethod:    http.MethodPost,
+			ResourcePath:  "Communication",
+			ResourceData:  must.MarshalJSON(communication),
+			Principal:     principal,
+			LocalIdentity: &auth.TestPrincipal1.Organization.Identifier[0],
+			Tenant:        tenant,
+		}, tx)
+		if err != nil {
+			return nil, err
+		}
+		var result fhir.Communication
+		require.NoError(t, json.Unmarshal(tx.Entry[0].Resource, &result))
+		return &result, nil
+	}
+
+	t.Run("sent to other active members", func(t *testing.T) {
+		result, err := create(t, auth.TestPrincipal1, message)
+
+		require.NoError(t, err)
+		require.Equal(t, auth.TestPrincipal1.Organization.Identifier[0], *result.Sender.Identifier)
+		require.Len(t, result.Recipient, 1)
+		require.Equal(t, auth.TestPrincipal2.Organization.Identifier[0], *result.Recipient[0].Identifier)
+		require.NotNil(t, result.Sent)
+	})
+	t.Run("specified recipient", func(t *testing.T) {
+		communication := message
+		communication.Recipient = []fhir.Reference{{Identifier: &auth.TestPrincipal1.Organization.Identifier[0]}}
+
+		result, err := create(t, auth.TestPrincipal2, communication)
+
+		require.NoError(t, err)
+		require.Len(t, result.Recipient, 1)
+	})
+	t.Run("recipient is former member", func(t *testing.T) {
+		communication := message
+		communication.Recipient = []fhir.Reference{{Identifier: &auth.TestPrincipal3.Organization.Identifier[0]}}
+
+		_, err := create(t, auth.TestPrincipal1, communication)
+
+		require.ErrorContains(t, err, "is not an active member of the CareTeam")
+	})
+	t.Run("sender is another organization", func(t *testing.T) {
+		communication := message
+		communication.Sender = &fhir.Reference{Identifier: &auth.TestPrincipal2.Organization.Identifier[0]}
+
+		_, err := create(t, auth.TestPrincipal1, communication)
+
+		require.EqualError(t, err, "Communication.sender must be the requesting organization")
+	})
+	t.Run("sender is former member", func(t *testing.T) {
+		_, err := create(t, auth.TestPrincipal3, message)
+
+		var errWithCode *coolfhir.ErrorWithCode
+		require.ErrorAs(t, err, &errWithCode)
+		require.Equal(t, http.StatusForbidden, errWithCode.StatusCode)
+	})
+	t.Run("not based on a CarePlan", func(t *testing.T) {
+		communication := message
+		communication.BasedOn = nil
+
+		_, err := create(t, auth.TestPrincipal1, communication)
+
+		var errWithCode *coolfhir.ErrorWithCode
+		require.ErrorAs(t, err, &errWithCode)
+		require.Equal(t, http.StatusForbidden, errWithCode.StatusCode)
+	})
+}
@@ -0,0 +1,147 @@
+package careplanservice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/debug"
+	"github.com/SanteonNL/orca/orchestrator/lib/logging"
+	"github.com/SanteonNL/orca/orchestrator/lib/otel"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// handleCreatePatient handles the creation of a Patient. If a Patient with the same BSN already exists, it is reused instead of creating a duplicate:
+// the creation becomes a conditional create that resolves to the existing Patient, so references to the Patient in the same transaction point to it.
+// The data of the created Patient that the existing Patient lacks (e.g. other identifiers or telecom) is merged into the existing Patient,
+// attributed to the principal: merging substitutes the creation the principal is authorized for, and it never overwrites or removes data.
+// Existing duplicates (Patients with the same BSN, created before) are linked to the reused Patient (Patient.link of type replaced-by).
+// Linking is attributed to the CPS itself rather than the principal, since the principal isn't authorized to update the duplicates.
+// Reusing a Patient doesn't grant access to it: the existing Patient is only returned if the principal may read it (e.g. through a CarePlan).
+// Merging and linking only update the version of the Patients that was read (If-Match), so concurrent updates aren't overwritten.
+func (s *Service) handleCreatePatient(ctx context.Context, request FHIRHandlerRequest, tx *coolfhir.BundleBuilder) (FHIRHandlerResult, error) {
+	ctx, span := tracer.Start(
+		ctx,
+		debug.GetFullCallerName(),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String(otel.FHIRResourceType, "Patient"),
+		),
+	)
+	defer span.End()
+
+	idx := len(tx.Entry)
+	result, err := FHIRCreateOperationHandler[*fhir.Patient]{
+		authzPolicy:       authzPolicy[*fhir.Patient](s, request.Tenant.ID, "Patient", AuthzInteractionCreate),
+		fhirClientFactory: s.createFHIRClient,
+		profile:           s.profile,
+		validator:         resourceValidator[*fhir.Patient](s, &PatientValidator{Rules: request.Tenant.PatientValidation.RulesFor(request.ServiceCodes)}),
+	}.Handle(ctx, request, tx)
+	if err != nil {
+		return nil, err
+	}
+	// The Patient has been parsed, authorized and validated by the create handler
+	var patient fhir.Patient
+	_ = json.Unmarshal(request.ResourceData, &patient)
+	bsn := patientBSN(patient)
+	if bsn == nil || tx.Entry[idx].Request.Method != fhir.HTTPVerbPOST {
+		return result, nil
+	}
+
+	fhirClient, err := s.createFHIRClient(ctx)
+	if err != nil {
+		return nil, otel.Error(span, err)
+	}
+	bsnToken := coolfhir.IdentifierToToken(*bsn)
+	existingPatients, _, err := handleSearchResource[fhir.Patient](ctx, fhirClient, "Patient", url.Values{"identifier": {bsnToken}}, &fhirclient.Headers{})
+	if err != nil {
+		return nil, otel.Error(span, fmt.Errorf("failed to search for existing Patient: %w", err))
+	}
+	existingPatient := primaryPatient(existingPatients)
+	if existingPatient == nil {
+		// Prevent duplicates when the same patient is created concurrently
+		tx.Entry[idx].Request.IfNoneExist = to.Ptr("identifier=" + url.QueryEscape(bsnToken))
+		return result, nil
+	}
+	span.SetAttributes(attribute.String("fhir.patient.reused_id", *existingPatient.Id))
+	slog.InfoContext(ctx, "Reusing existing Patient with the same BSN",
+		slog.String(logging.FieldResourceReference, "Patient/"+*existingPatient.Id),
+	)
+	tx.Entry[idx].Request.IfNoneExist = to.Ptr("_id=" + url.QueryEscape(*existingPatient.Id))
+
+	if merged, changed := mergePatient(*existingPatient, patient); changed {
+		slog.InfoContext(ctx, "Merging created Patient into existing Patient with the same BSN",
+			slog.String(logging.FieldResourceReference, "Patient/"+*existingPatient.Id),
+		)
+		tx.Update(merged, "Patient/"+*existingPatient.Id, ifMatchVersionOf(*existingPatient), coolfhir.WithAuditEvent(ctx, tx, coolfhir.AuditEventInfo{
+			ActingAgent: &fhir.Reference{
+				Identifier: &request.Principal.Organization.Identifier[0],
+				Type:       to.Ptr("Organization"),
+			},
+			ActingUser: request.Principal.UserAuditAgent(),
+			Observer:   *request.LocalIdentity,
+			Action:     fhir.AuditEventActionU,
+		}))
+	}
+
+	// The CPS acts as system when linking duplicates
+	systemAgent := &fhir.Reference{
+		Identifier: request.LocalIdentity,
+		Type:       to.Ptr("Device"),
+	}
+	for _, duplicate := range existingPatients {
+		if *duplicate.Id == *existingPatient.Id || isReplacedPatient(duplicate) {
+			continue
+		}
+		duplicate.Link = append(duplicate.Link, fhir.PatientLink{
+			Other: fhir.Reference{
+				Reference: to.Ptr("Patient/" + *existingPatient.Id),
+				Type:      to.Ptr("Patient"),
+			},
+			Type: fhir.LinkTypeReplacedBy,
+		})
+		slog.InfoContext(ctx, "Linking duplicate Patient with the same BSN",
+			slog.String(logging.FieldResourceReference, "Patient/"+*duplicate.Id),
+		)
+		tx.Update(duplicate, "Patient/"+*duplicate.Id, ifMatchVersionOf(duplicate), coolfhir.WithAuditEvent(ctx, tx, coolfhir.AuditEventInfo{
+			ActingAgent: systemAgent,
+			Observer:    *request.LocalIdentity,
+			Action:      fhir.AuditEventActionU,
+			Policy:      []string{"Patient de-duplication"},
+		}))
+	}
+
+	readPolicy := authzPolicy[*fhir.Patient](s, request.Tenant.ID, "Patient", AuthzInteractionRead)
+	return func(txResult *fhir.Bundle) ([]*fhir.BundleEntry, []any, error) {
+		entries, _, err := result(txResult)
+		if err != nil {
+			return nil, nil, err
+		}
+		decision, err := readPolicy.HasAccess(ctx, existingPatient, *request.Principal)
+		if err != nil || decision == nil || !decision.Allowed {
+			for _, entry := range entries {
+				entry.Resource = nil
+			}
+		}
+		// No notifications, since the Patient wasn't created
+		return entries, nil, nil
+	}, nil
+}
+
+// ifMatchVersionOf returns a bundle entry option that makes the update conditional on the version of the given resource that was read,
+// so the transaction fails instead of overwriting a concurrent update. It's a no-op if the version is unknown.
+func ifMatchVersionOf(patient fhir.Patient) coolfhir.BundleEntryOption {
+	header := http.Header{}
+	if patient.Meta != nil && patient.Meta.VersionId != nil {
+		header.Set(coolfhir.IfMatchHeader, `W/"`+*patient.Meta.VersionId+`"`)
+	}
+	return coolfhir.WithRequestHeaders(header)
+}
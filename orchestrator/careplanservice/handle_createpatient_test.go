@@ -0,0 +1,160 @@
+package careplanservice
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/cmd/profile"
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/must"
+	"github.com/SanteonNL/orca/orchestrator/lib/test"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+func TestService_handleCreatePatient(t *testing.T) {
+	tenant := tenants.Test().Sole()
+	bsn := fhir.Identifier{System: to.Ptr(coolfhir.BSNNamingSystem), Value: to.Ptr("111222333")}
+	patient := fhir.Patient{
+		Identifier: []fhir.Identifier{bsn},
+		Telecom: []fhir.ContactPoint{
+			{System: to.Ptr(fhir.ContactPointSystemEmail), Value: to.Ptr("test@example.com")},
+			{System: to.Ptr(fhir.ContactPointSystemPhone), Value: to.Ptr("0612345678")},
+		},
+	}
+	existingPatient := func(id string, creator *auth.Principal) fhir.Patient {
+		result := patient
+		result.Id = to.Ptr(id)
+		result.Extension = nil
+		result.Meta = &fhir.Meta{VersionId: to.Ptr("3")}
+		SetCreatorExtensionOnResource(&result, &creator.Organization.Identifier[0])
+		return result
+	}
+	create := func(t *testing.T, patient fhir.Patient, existingResources ...any) (*coolfhir.BundleBuilder, FHIRHandlerResult) {
+		service := &Service{
+			profile: profile.Test(),
+			fhirClientByTenant: map[string]fhirclient.Client{
+				tenant.ID: &test.StubFHIRClient{Resources: existingResources},
+			},
+		}
+		tx := coolfhir.Transaction()
+		ctx := tenants.WithTenant(context.Background(), tenant)
+		result, err := service.handleCreatePatient(ctx, FHIRHandlerRequest{
+			HttpMethod:    http.MethodPost,
+			ResourcePath:  "Patient",
+			ResourceData:  must.MarshalJSON(patient),
+			Principal:     auth.TestPrincipal1,
+			LocalIdentity: &auth.TestPrincipal1.Organization.Identifier[0],
+			Tenant:        tenant,
+			BaseURL:       must.ParseURL("http://example.com/fhir"),
+		}, tx)
+		require.NoError(t, err)
+		return tx, result
+	}
+	// txResult returns the transaction result for a conditional create that matched the given existing Patient.
+	txResult := func(existing fhir.Patient, tx *coolfhir.BundleBuilder) *fhir.Bundle {
+		result := fhir.Bundle{Entry: make([]fhir.BundleEntry, len(tx.Entry))}
+		for i := range result.Entry {
+			result.Entry[i].Response = &fhir.BundleEntryResponse{Status: "200 OK"}
+		}
+		result.Entry[0].Resource = must.MarshalJSON(existing)
+		result.Entry[0].Response.Location = to.Ptr("Patient/" + *existing.Id)
+		return &result
+	}
+
+	t.Run("without BSN", func(t *testing.T) {
+		patient := patient
+		patient.Identifier = nil
+
+		tx, _ := create(t, patient)
+
+		require.Equal(t, fhir.HTTPVerbPOST, tx.Entry[0].Request.Method)
+		require.Nil(t, tx.Entry[0].Request.IfNoneExist)
+	})
+	t.Run("no existing Patient with the same BSN", func(t *testing.T) {
+		tx, _ := create(t, patient)
+
+		require.Equal(t, "identifier="+url.QueryEscape(coolfhir.BSNNamingSystem+"|111222333"), *tx.Entry[0].Request.IfNoneExist)
+	})
+	t.Run("existing Patient with the same BSN is reused", func(t *testing.T) {
+		existing := existingPatient("1", auth.TestPrincipal1)
+
+		tx, result := create(t, patient, existing)
+
+		require.Equal(t, "_id=1", *tx.Entry[0].Request.IfNoneExist)
+		entries, notifications, err := result(txResult(existing, tx))
+		require.NoError(t, err)
+		require.Empty(t, notifications)
+		require.Len(t, entries, 1)
+		require.Equal(t, "Patient/1", *entries[0].Response.Location)
+		require.NotEmpty(t, entries[0].Resource)
+	})
+	t.Run("reused Patient is not returned if the principal may not read it", func(t *testing.T) {
+		existing := existingPatient("1", auth.TestPrincipal2)
+
+		tx, result := create(t, patient, existing)
+
+		require.Equal(t, "_id=1", *tx.Entry[0].Request.IfNoneExist)
+		entries, _, err := result(txResult(existing, tx))
+		require.NoError(t, err)
+		require.Equal(t, "Patient/1", *entries[0].Response.Location)
+		require.Empty(t, entries[0].Resource)
+	})
+	t.Run("duplicates are linked to the reused Patient", func(t *testing.T) {
+		primary := existingPatient("1", auth.TestPrincipal1)
+		duplicate := existingPatient("2", auth.TestPrincipal2)
+		alreadyLinked := existingPatient("3", auth.TestPrincipal2)
+		alreadyLinked.Link = []fhir.PatientLink{{Other: fhir.Reference{Reference: to.Ptr("Patient/1")}, Type: fhir.LinkTypeReplacedBy}}
+
+		tx, _ := create(t, patient, primary, duplicate, alreadyLinked)
+
+		require.Equal(t, "_id=1", *tx.Entry[0].Request.IfNoneExist)
+		// Patient create + AuditEvent, duplicate update + AuditEvent
+		require.Len(t, tx.Entry, 4)
+		require.Equal(t, fhir.HTTPVerbPUT, tx.Entry[2].Request.Method)
+		require.Equal(t, "Patient/2", tx.Entry[2].Request.Url)
+		require.Equal(t, `W/"3"`, *tx.Entry[2].Request.IfMatch)
+		var linked fhir.Patient
+		require.NoError(t, json.Unmarshal(tx.Entry[2].Resource, &linked))
+		require.Len(t, linked.Link, 1)
+		require.Equal(t, fhir.LinkTypeReplacedBy, linked.Link[0].Type)
+		require.Equal(t, "Patient/1", *linked.Link[0].Other.Reference)
+		// Linking is attributed to the CPS, not the principal
+		var auditEvent fhir.AuditEvent
+		require.NoError(t, json.Unmarshal(tx.Entry[3].Resource, &auditEvent))
+		require.Equal(t, "Device", *auditEvent.Agent[0].Who.Type)
+		require.Equal(t, *auth.TestPrincipal1.Organization.Identifier[0].Value, *auditEvent.Agent[0].Who.Identifier.Value)
+		require.Len(t, auditEvent.Agent, 1)
+	})
+	t.Run("data the reused Patient lacks is merged into it", func(t *testing.T) {
+		existing := existingPatient("1", auth.TestPrincipal2)
+		existing.Telecom = nil
+		patient := patient
+		patient.Name = []fhir.HumanName{{Family: to.Ptr("Jansen")}}
+
+		tx, _ := create(t, patient, existing)
+
+		require.Equal(t, "_id=1", *tx.Entry[0].Request.IfNoneExist)
+		// Patient create + AuditEvent, existing Patient update + AuditEvent
+		require.Len(t, tx.Entry, 4)
+		require.Equal(t, fhir.HTTPVerbPUT, tx.Entry[2].Request.Method)
+		require.Equal(t, "Patient/1", tx.Entry[2].Request.Url)
+		require.Equal(t, `W/"3"`, *tx.Entry[2].Request.IfMatch)
+		var merged fhir.Patient
+		require.NoError(t, json.Unmarshal(tx.Entry[2].Resource, &merged))
+		require.Equal(t, patient.Telecom, merged.Telecom)
+		require.Equal(t, patient.Name, merged.Name)
+		require.Equal(t, existing.Extension, merged.Extension, "creator of the existing Patient should be retained")
+		var auditEvent fhir.AuditEvent
+		require.NoError(t, json.Unmarshal(tx.Entry[3].Resource, &auditEvent))
+		require.Equal(t, "Organization", *auditEvent.Agent[0].Who.Type)
+		require.Equal(t, *auth.TestPrincipal1.Organization.Identifier[0].Value, *auditEvent.Agent[0].Who.Identifier.Value)
+	})
+}
@@ -76,6 +76,11 @@ func (s *Service) handleCreateTask(ctx context.Context, request FHIRHandlerReque
 	if err != nil {
 		return nil, otel.Error(span, err, "task validation failed")
 	}
+	if validator := resourceValidator[*fhir.Task](s); validator != nil {
+		if errs := validator.Validate(&task); len(errs) > 0 {
+			return nil, otel.Error(span, validationError("Task", errs), "task validation failed")
+		}
+	}
 
 	if !isPrincipalTaskRequester(&task, request.Principal) {
 		return nil, otel.Error(span, coolfhir.BadRequest("requester must be equal to Task.requester"), "principal is not task requester")
@@ -169,7 +174,8 @@ func (s *Service) handleCreateTask(ctx context.Context, request FHIRHandlerReque
 			if len(patients) == 0 {
 				return nil, otel.Error(span, coolfhir.NewErrorWithCode("Task.For must be set with a local reference, or a logical identifier, referencing an existing patient", http.StatusNotFound), "patient not found")
 			} else {
-				task.For.Reference = to.Ptr("Patient/" + *patients[0].Id)
+				// Prefer the Patient that duplicates were linked to
+				task.For.Reference = to.Ptr("Patient/" + *primaryPatient(patients).Id)
 			}
 		}
 
@@ -184,6 +190,11 @@ func (s *Service) handleCreateTask(ctx context.Context, request FHIRHandlerReque
 			return nil, otel.Error(span, coolfhir.NewErrorWithCode("failed to marshal CareTeam", http.StatusInternalServerError), "failed to marshal care team")
 		}
 
+		if s.pseudonymization {
+			// Only the pseudonym is shared with participants, the BSN can be found through the referenced Patient
+			span.AddEvent("pseudonymizing_careplan_subject")
+			*task.For = pseudonymizedSubject(*task.For)
+		}
 		carePlan.Subject = *task.For
 		carePlan.Status = fhir.RequestStatusActive
 		carePlan.Author = task.Requester
@@ -222,8 +233,9 @@ func (s *Service) handleCreateTask(ctx context.Context, request FHIRHandlerReque
 				Identifier: task.Requester.Identifier,
 				Type:       to.Ptr("Organization"),
 			},
-			Observer: *request.LocalIdentity,
-			Action:   fhir.AuditEventActionC,
+			ActingUser: request.Principal.UserAuditAgent(),
+			Observer:   *request.LocalIdentity,
+			Action:     fhir.AuditEventActionC,
 		}))
 		carePlanBundleEntry = &tx.Entry[carePlanBundleEntryIdx]
 
@@ -233,8 +245,9 @@ func (s *Service) handleCreateTask(ctx context.Context, request FHIRHandlerReque
 				Identifier: task.Requester.Identifier,
 				Type:       to.Ptr("Organization"),
 			},
-			Observer: *request.LocalIdentity,
-			Action:   fhir.AuditEventActionC,
+			ActingUser: request.Principal.UserAuditAgent(),
+			Observer:   *request.LocalIdentity,
+			Action:     fhir.AuditEventActionC,
 		}))
 		taskBundleEntry = tx.Entry[taskEntryIdx]
 
@@ -276,6 +289,16 @@ func (s *Service) handleCreateTask(ctx context.Context, request FHIRHandlerReque
 		}
 
 		span.AddEvent("validating_patient_consistency")
+		if isPatientPseudonym(carePlan.Subject.Identifier) && !isPatientPseudonym(task.For.Identifier) {
+			// Task.for may refer to the patient by reference or BSN, which is replaced by the pseudonym of the CarePlan
+			pseudonymized, err := isPseudonymizedSubject(ctx, fhirClient, *task.For, carePlan.Subject)
+			if err != nil {
+				return nil, otel.Error(span, err, "failed to resolve pseudonymized careplan.subject")
+			}
+			if pseudonymized {
+				task.For = to.Ptr(carePlan.Subject)
+			}
+		}
 		samePatient := false
 		// If either task.For or CarePlan.Subject contains an identifier, they must be equal
 		// If neither contain an identifier, the reference must be equal
@@ -344,8 +367,9 @@ func (s *Service) handleCreateTask(ctx context.Context, request FHIRHandlerReque
 				Identifier: task.Requester.Identifier,
 				Type:       to.Ptr("Organization"),
 			},
-			Observer: *request.LocalIdentity,
-			Action:   fhir.AuditEventActionC,
+			ActingUser: request.Principal.UserAuditAgent(),
+			Observer:   *request.LocalIdentity,
+			Action:     fhir.AuditEventActionC,
 		}))
 
 		if len(task.PartOf) == 0 {
@@ -371,8 +395,9 @@ func (s *Service) handleCreateTask(ctx context.Context, request FHIRHandlerReque
 						Identifier: task.Requester.Identifier,
 						Type:       to.Ptr("Organization"),
 					},
-					Observer: *request.LocalIdentity,
-					Action:   fhir.AuditEventActionU,
+					ActingUser: request.Principal.UserAuditAgent(),
+					Observer:   *request.LocalIdentity,
+					Action:     fhir.AuditEventActionU,
 				}))
 			}
 			carePlanBundleEntry = &tx.Entry[carePlanBundleEntryIdx]
@@ -194,6 +194,7 @@ func Test_handleCreateTask_NoExistingCarePlan(t *testing.T) {
 		errorFromRead              error
 		expectError                error
 		principal                  *auth.Principal
+		pseudonymization           bool
 	}{
 		{
 			name: "happy flow",
@@ -300,6 +301,17 @@ func Test_handleCreateTask_NoExistingCarePlan(t *testing.T) {
 				}
 			}),
 		},
+		{
+			name:             "pseudonymization: BSN in Task.for is replaced by a pseudonym",
+			pseudonymization: true,
+			returnedPatientBundle: &fhir.Bundle{
+				Entry: []fhir.BundleEntry{
+					{
+						Resource: defaultPatient,
+					},
+				},
+			},
+		},
 		{
 			name: "Task location in transaction response bundle contains an absolute URL (Microsoft Azure FHIR behavior)",
 			returnedBundle: deep.AlterCopy(defaultReturnedBundle, func(bundle **fhir.Bundle) {
@@ -318,6 +330,7 @@ func Test_handleCreateTask_NoExistingCarePlan(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			service.pseudonymization = tt.pseudonymization
 
 			if tt.returnedPatientBundle != nil || tt.errorFromPatientBundleRead != nil {
 				mockFHIRClient.EXPECT().SearchWithContext(gomock.Any(), "Patient", gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, path string, params url.Values, result interface{}, option ...fhirclient.Option) error {
@@ -395,6 +408,15 @@ func Test_handleCreateTask_NoExistingCarePlan(t *testing.T) {
 					require.Equal(t, fhir.RequestStatusActive, carePlan.Status)
 					require.Contains(t, *carePlan.Subject.Reference, "Patient/")
 					require.Equal(t, taskToCreate.Requester, carePlan.Author)
+					if tt.pseudonymization {
+						require.Equal(t, coolfhir.PatientPseudonymNamingSystem, *carePlan.Subject.Identifier.System)
+						require.NotEmpty(t, *carePlan.Subject.Identifier.Value)
+						var task fhir.Task
+						require.NoError(t, json.Unmarshal(coolfhir.FirstBundleEntry((*fhir.Bundle)(tx), coolfhir.EntryIsOfType("Task")).Resource, &task))
+						require.Equal(t, carePlan.Subject, *task.For)
+					} else {
+						require.False(t, isPatientPseudonym(carePlan.Subject.Identifier))
+					}
 				})
 			}
 
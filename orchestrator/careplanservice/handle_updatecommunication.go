@@ -0,0 +1,70 @@
+package careplanservice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/debug"
+	"github.com/SanteonNL/orca/orchestrator/lib/logging"
+	"github.com/SanteonNL/orca/orchestrator/lib/otel"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// handleUpdateCommunication handles the update of a Communication, or its creation if it doesn't exist yet (upsert).
+// The updated Communication is subject to the same rules as a new Communication (see handleCreateCommunication),
+// and only its creator may update it.
+func (s *Service) handleUpdateCommunication(ctx context.Context, request FHIRHandlerRequest, tx *coolfhir.BundleBuilder) (FHIRHandlerResult, error) {
+	ctx, span := tracer.Start(
+		ctx,
+		debug.GetFullCallerName(),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String(otel.FHIRResourceType, "Communication"),
+		),
+	)
+	defer span.End()
+
+	var communication fhir.Communication
+	if err := json.Unmarshal(request.ResourceData, &communication); err != nil {
+		return nil, otel.Error(span, fmt.Errorf("invalid %T: %w", communication, coolfhir.BadRequestError(err)))
+	}
+
+	// The updated Communication must be based on a CarePlan the principal may create Communications for
+	createPolicy := authzPolicy[*fhir.Communication](s, request.Tenant.ID, "Communication", AuthzInteractionCreate)
+	authzDecision, err := createPolicy.HasAccess(ctx, &communication, *request.Principal)
+	if authzDecision == nil || !authzDecision.Allowed {
+		if err != nil {
+			slog.ErrorContext(ctx, "Error checking if principal is authorized to update Communication",
+				slog.String(logging.FieldError, otel.Error(span, err, "authorization check failed").Error()),
+			)
+		}
+		return nil, otel.Error(span, &coolfhir.ErrorWithCode{
+			Message:    "Participant is not authorized to update Communication",
+			StatusCode: http.StatusForbidden,
+		})
+	}
+	if err := s.prepareCommunication(ctx, request, &communication); err != nil {
+		return nil, otel.Error(span, err)
+	}
+	span.SetAttributes(attribute.Int("fhir.communication.recipient_count", len(communication.Recipient)))
+
+	request.ResourceData, _ = json.Marshal(communication)
+	return FHIRUpdateOperationHandler[*fhir.Communication]{
+		authzPolicy:       authzPolicy[*fhir.Communication](s, request.Tenant.ID, "Communication", AuthzInteractionUpdate),
+		fhirClientFactory: s.createFHIRClient,
+		profile:           s.profile,
+		validator:         resourceValidator[*fhir.Communication](s),
+		createHandler: &FHIRCreateOperationHandler[*fhir.Communication]{
+			authzPolicy:       createPolicy,
+			fhirClientFactory: s.createFHIRClient,
+			profile:           s.profile,
+			validator:         resourceValidator[*fhir.Communication](s),
+		},
+	}.Handle(ctx, request, tx)
+}
@@ -65,6 +65,11 @@ func (s *Service) handleUpdateTask(ctx context.Context, request FHIRHandlerReque
 	if err != nil {
 		return nil, otel.Error(span, fmt.Errorf("invalid Task: %w", err), "task validation failed")
 	}
+	if validator := resourceValidator[*fhir.Task](s); validator != nil {
+		if errs := validator.Validate(&task); len(errs) > 0 {
+			return nil, otel.Error(span, validationError("Task", errs), "task validation failed")
+		}
+	}
 
 	var taskExisting fhir.Task
 	exists := true
@@ -189,8 +194,9 @@ func (s *Service) handleUpdateTask(ctx context.Context, request FHIRHandlerReque
 			Identifier: &request.Principal.Organization.Identifier[0],
 			Type:       to.Ptr("Organization"),
 		},
-		Observer: *request.LocalIdentity,
-		Action:   fhir.AuditEventActionU,
+		ActingUser: request.Principal.UserAuditAgent(),
+		Observer:   *request.LocalIdentity,
+		Action:     fhir.AuditEventActionU,
 	}))
 
 	// Update care team
@@ -0,0 +1,76 @@
+package careplanservice
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/debug"
+	"github.com/SanteonNL/orca/orchestrator/lib/otel"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// handleBreakTheGlassReview handles the $break-the-glass-review operation, which returns the AuditEvents of the tenant's break-the-glass grants
+// (most recent first), so the local care organization can review emergency access to its CarePlans.
+// The optional since query parameter (date or dateTime) only returns grants recorded since then.
+func (s *Service) handleBreakTheGlassReview(httpResponse http.ResponseWriter, httpRequest *http.Request) {
+	result, err := s.breakTheGlassReview(httpRequest)
+	if err != nil {
+		coolfhir.WriteOperationOutcomeFromError(httpRequest.Context(), err, "CarePlanService/BreakTheGlassReview", httpResponse)
+		return
+	}
+	coolfhir.SendResponse(httpResponse, http.StatusOK, result)
+}
+
+func (s *Service) breakTheGlassReview(httpRequest *http.Request) (*fhir.Bundle, error) {
+	ctx, span := tracer.Start(
+		httpRequest.Context(),
+		debug.GetFullCallerName(),
+		trace.WithSpanKind(trace.SpanKindServer),
+	)
+	defer span.End()
+
+	principal, err := auth.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, otel.Error(span, err)
+	}
+	decision, err := LocalOrganizationPolicy[any]{profile: s.profile}.HasAccess(ctx, nil, principal)
+	if err != nil {
+		return nil, otel.Error(span, err)
+	}
+	if !decision.Allowed {
+		return nil, otel.Error(span, coolfhir.NewErrorWithCode("only the local care organization may review break-the-glass access", http.StatusForbidden))
+	}
+
+	params := url.Values{
+		"_sort": []string{"-date"},
+	}
+	if since := httpRequest.URL.Query().Get("since"); since != "" {
+		if _, err := time.Parse(time.RFC3339, since); err != nil {
+			if _, err := time.Parse(time.DateOnly, since); err != nil {
+				return nil, otel.Error(span, coolfhir.BadRequest("since must be a date or dateTime"))
+			}
+		}
+		params.Set("date", "ge"+since)
+	}
+	fhirClient, err := s.createFHIRClient(ctx)
+	if err != nil {
+		return nil, otel.Error(span, err)
+	}
+	auditEvents, err := searchBreakTheGlassAuditEvents(ctx, fhirClient, params)
+	if err != nil {
+		return nil, otel.Error(span, fmt.Errorf("failed to search for break-the-glass grants: %w", err))
+	}
+	builder := coolfhir.SearchSet()
+	for _, auditEvent := range auditEvents {
+		builder.Append(auditEvent, nil, nil)
+	}
+	result := builder.Bundle()
+	result.Total = to.Ptr(len(auditEvents))
+	return &result, nil
+}
@@ -0,0 +1,75 @@
+package careplanservice
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/careplancontributor/mock"
+	"github.com/SanteonNL/orca/orchestrator/cmd/profile"
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	"go.uber.org/mock/gomock"
+)
+
+func TestService_BreakTheGlassReview(t *testing.T) {
+	tenantCfg := tenants.Test()
+	carePlan := &fhir.CarePlan{Id: to.Ptr("cp1")}
+	grant := *breakTheGlassAuditEvent(auth.TestPrincipal1.Organization.Identifier[0], *auth.TestPrincipal2, carePlan, "patient unconscious")
+	grant.Id = to.Ptr("ae1")
+	// AuditEvent of a regular read, which the FHIR server returns although it doesn't match the subtype
+	read := fhir.AuditEvent{Id: to.Ptr("ae2")}
+	review := func(t *testing.T, principal *auth.Principal, query string) (*fhir.Bundle, url.Values, error) {
+		var capturedQuery url.Values
+		fhirClient := mock.NewMockClient(gomock.NewController(t))
+		fhirClient.EXPECT().SearchWithContext(gomock.Any(), "AuditEvent", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, query url.Values, target any, _ ...fhirclient.Option) error {
+				capturedQuery = query
+				*target.(*fhir.Bundle) = coolfhir.SearchSet().Append(grant, nil, nil).Append(read, nil, nil).Bundle()
+				return nil
+			}).AnyTimes()
+		service := &Service{
+			tenants:            tenantCfg,
+			profile:            profile.Test(),
+			fhirClientByTenant: map[string]fhirclient.Client{"test": fhirClient},
+		}
+		httpRequest := httptest.NewRequest(http.MethodGet, "/cps/test/$break-the-glass-review?"+query, nil)
+		ctx := tenants.WithTenant(context.Background(), tenantCfg.Sole())
+		ctx = auth.WithPrincipal(ctx, *principal)
+		result, err := service.breakTheGlassReview(httpRequest.WithContext(ctx))
+		return result, capturedQuery, err
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		since := time.Now().Add(-24 * time.Hour).Format(time.RFC3339)
+
+		result, query, err := review(t, auth.TestPrincipal1, url.Values{"since": []string{since}}.Encode())
+
+		require.NoError(t, err)
+		require.Equal(t, fhir.BundleTypeSearchset, result.Type)
+		require.Equal(t, 1, *result.Total)
+		require.Len(t, result.Entry, 1)
+		require.Equal(t, "ge"+since, query.Get("date"))
+		require.Equal(t, "-date", query.Get("_sort"))
+	})
+	t.Run("not the local organization", func(t *testing.T) {
+		_, _, err := review(t, auth.TestPrincipal2, "")
+
+		var errWithCode *coolfhir.ErrorWithCode
+		require.ErrorAs(t, err, &errWithCode)
+		require.Equal(t, http.StatusForbidden, errWithCode.StatusCode)
+	})
+	t.Run("invalid since", func(t *testing.T) {
+		_, _, err := review(t, auth.TestPrincipal1, "since=yesterday")
+
+		require.EqualError(t, err, "since must be a date or dateTime")
+	})
+}
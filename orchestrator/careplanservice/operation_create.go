@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 
 	fhirclient "github.com/SanteonNL/go-fhir-client"
@@ -126,9 +127,10 @@ func (h FHIRCreateOperationHandler[T]) Handle(ctx context.Context, request FHIRH
 				Identifier: &request.Principal.Organization.Identifier[0],
 				Type:       to.Ptr("Organization"),
 			},
-			Observer: *request.LocalIdentity,
-			Action:   fhir.AuditEventActionC,
-			Policy:   authzDecision.Reasons,
+			ActingUser: request.Principal.UserAuditAgent(),
+			Observer:   *request.LocalIdentity,
+			Action:     fhir.AuditEventActionC,
+			Policy:     authzDecision.Reasons,
 		}))
 	} else {
 		span.SetAttributes(attribute.String("fhir.operation.mode", "create"))
@@ -138,8 +140,9 @@ func (h FHIRCreateOperationHandler[T]) Handle(ctx context.Context, request FHIRH
 				Identifier: &request.Principal.Organization.Identifier[0],
 				Type:       to.Ptr("Organization"),
 			},
-			Observer: *request.LocalIdentity,
-			Action:   fhir.AuditEventActionC,
+			ActingUser: request.Principal.UserAuditAgent(),
+			Observer:   *request.LocalIdentity,
+			Action:     fhir.AuditEventActionC,
 		}))
 	}
 
@@ -174,45 +177,78 @@ func (h FHIRCreateOperationHandler[T]) validate(ctx context.Context, resource T,
 	)
 	defer span.End()
 
-	if errs := h.validator.Validate(resource); errs != nil {
+	if errs := h.validator.Validate(resource); len(errs) > 0 {
 		span.SetAttributes(
 			attribute.Int("validation.error_count", len(errs)),
 			attribute.String(otel.ValidationResult, "failed"),
 		)
+		return nil, otel.Error(span, validationError(resourceType, errs), "validation failed"), true
+	}
+
+	span.SetAttributes(
+		attribute.String(otel.ValidationResult, "passed"),
+	)
+	span.SetStatus(codes.Ok, "")
+	return nil, nil, false
+}
 
-		var issues []fhir.OperationOutcomeIssue
-		var diagnostics = fmt.Sprintf("Validation failed for %s", resourceType)
-		var codings []fhir.Coding
-		for _, err := range errs {
-			var coding = fhir.Coding{
+// validationError returns an OperationOutcome error (400 Bad Request) for the given validation errors.
+// Errors with a custom code (e.g. reported by PatientValidator) are reported together in the first issue, with the codes as details.
+// Errors with an expression are additionally reported as separate issues, so clients can relate them to the invalid element.
+func validationError(resourceType string, errs []*validation.Error) error {
+	var issues []fhir.OperationOutcomeIssue
+	var codings []fhir.Coding
+	for _, err := range errs {
+		issueType, isIssueType := validationIssueType(err.Code)
+		var coding *fhir.Coding
+		if !isIssueType {
+			coding = &fhir.Coding{
 				Code:   to.Ptr(err.Code),
 				System: to.Ptr("https://zorgbijjou.github.io/scp-homemonitoring/validation/"),
 			}
-			codings = append(codings, coding)
+			codings = append(codings, *coding)
 		}
-
-		issues = append(issues, fhir.OperationOutcomeIssue{
+		if err.Expression == "" {
+			continue
+		}
+		issue := fhir.OperationOutcomeIssue{
+			Severity:   fhir.IssueSeverityError,
+			Code:       issueType,
+			Expression: []string{err.Expression},
+		}
+		if coding != nil {
+			issue.Details = &fhir.CodeableConcept{Coding: []fhir.Coding{*coding}}
+		}
+		if err.Diagnostics != "" {
+			issue.Diagnostics = to.Ptr(err.Diagnostics)
+		}
+		issues = append(issues, issue)
+	}
+	if len(codings) > 0 {
+		issues = append([]fhir.OperationOutcomeIssue{{
 			Severity:    fhir.IssueSeverityError,
 			Code:        fhir.IssueTypeInvariant,
-			Diagnostics: &diagnostics,
+			Diagnostics: to.Ptr(fmt.Sprintf("Validation failed for %s", resourceType)),
 			Details: &fhir.CodeableConcept{
 				Coding: codings,
 			},
-		})
-
-		var err = &fhirclient.OperationOutcomeError{
-			OperationOutcome: fhir.OperationOutcome{
-				Issue: issues,
-			},
-			HttpStatusCode: http.StatusBadRequest,
-		}
-
-		return nil, otel.Error(span, err, "validation failed"), true
+		}}, issues...)
+	}
+	return &fhirclient.OperationOutcomeError{
+		OperationOutcome: fhir.OperationOutcome{
+			Issue: issues,
+		},
+		HttpStatusCode: http.StatusBadRequest,
 	}
+}
 
-	span.SetAttributes(
-		attribute.String(otel.ValidationResult, "passed"),
-	)
-	span.SetStatus(codes.Ok, "")
-	return nil, nil, false
+// validationIssueType returns the OperationOutcome issue type for the validation error code,
+// which is the code itself if it's a FHIR issue type (e.g. "required"), or invariant otherwise.
+// The second return value indicates whether the code is a FHIR issue type.
+func validationIssueType(code string) (fhir.IssueType, bool) {
+	var result fhir.IssueType
+	if err := json.Unmarshal([]byte(strconv.Quote(code)), &result); err != nil {
+		return fhir.IssueTypeInvariant, false
+	}
+	return result, true
 }
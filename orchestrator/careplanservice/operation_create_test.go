@@ -270,6 +270,37 @@ func TestFHIRCreateOperationHandler_Handle(t *testing.T) {
 					assert.Equal(t, http.StatusBadRequest, expectedErr.HttpStatusCode)
 			},
 		},
+		{
+			name: "failed validation with expressions",
+			args: args{
+				resource:  task,
+				validator: &expressionFailureValidator{},
+			},
+			want: func(t *testing.T, tx fhir.Bundle, result FHIRHandlerResult) {
+				assert.Empty(t, tx.Entry)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				expectedErr := new(fhirclient.OperationOutcomeError)
+				if !assert.ErrorAs(t, err, &expectedErr) || !assert.Len(t, expectedErr.OperationOutcome.Issue, 3) {
+					return false
+				}
+				// Custom codes are reported together in the first issue
+				aggregated := expectedErr.OperationOutcome.Issue[0]
+				issue := expectedErr.OperationOutcome.Issue[1]
+				customIssue := expectedErr.OperationOutcome.Issue[2]
+				return assert.Equal(t, http.StatusBadRequest, expectedErr.HttpStatusCode) &&
+					assert.Equal(t, fhir.IssueTypeInvariant, aggregated.Code) &&
+					assert.Len(t, aggregated.Details.Coding, 1) &&
+					assert.Equal(t, "not-an-issue-type", *aggregated.Details.Coding[0].Code) &&
+					assert.Equal(t, fhir.IssueTypeRequired, issue.Code) &&
+					assert.Equal(t, []string{"Task.status"}, issue.Expression) &&
+					assert.Equal(t, "Task.status: minimum required = 1, but only found 0", *issue.Diagnostics) &&
+					assert.Nil(t, issue.Details) &&
+					assert.Equal(t, fhir.IssueTypeInvariant, customIssue.Code) &&
+					assert.Equal(t, []string{"Task"}, customIssue.Expression) &&
+					assert.Equal(t, "not-an-issue-type", *customIssue.Details.Coding[0].Code)
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -322,3 +353,12 @@ func (v *failureValidator) Validate(t *fhir.Task) []*validation.Error {
 	errs = append(errs, &validation.Error{Code: "E001"})
 	return append(errs, &validation.Error{Code: "E002"})
 }
+
+type expressionFailureValidator struct{}
+
+func (v *expressionFailureValidator) Validate(t *fhir.Task) []*validation.Error {
+	return []*validation.Error{
+		{Code: "required", Expression: "Task.status", Diagnostics: "Task.status: minimum required = 1, but only found 0"},
+		{Code: "not-an-issue-type", Expression: "Task"},
+	}
+}
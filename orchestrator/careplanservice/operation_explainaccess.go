@@ -0,0 +1,87 @@
+package careplanservice
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/debug"
+	"github.com/SanteonNL/orca/orchestrator/lib/otel"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// handleExplainAccess handles the $explain-access operation, which returns the authorization decision of the caller for a resource,
+// including the reasons of the policies that were evaluated. The interaction (read or update) is specified by the interaction query parameter,
+// which defaults to read.
+func (s *Service) handleExplainAccess(httpResponse http.ResponseWriter, httpRequest *http.Request) {
+	result, err := s.explainAccessTo(httpRequest)
+	if err != nil {
+		coolfhir.WriteOperationOutcomeFromError(httpRequest.Context(), err, "CarePlanService/ExplainAccess", httpResponse)
+		return
+	}
+	coolfhir.SendResponse(httpResponse, http.StatusOK, result)
+}
+
+func (s *Service) explainAccessTo(httpRequest *http.Request) (*fhir.Parameters, error) {
+	resourceType := httpRequest.PathValue("type")
+	resourceID := httpRequest.PathValue("id")
+	ctx, span := tracer.Start(
+		httpRequest.Context(),
+		debug.GetFullCallerName(),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String(otel.FHIRResourceType, resourceType),
+			attribute.String(otel.FHIRResourceID, resourceID),
+		),
+	)
+	defer span.End()
+	ctx = withAuthzCache(ctx)
+
+	tenant, err := tenants.FromContext(ctx)
+	if err != nil {
+		return nil, otel.Error(span, err)
+	}
+	principal, err := auth.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, otel.Error(span, err)
+	}
+	interaction := AuthzInteraction(httpRequest.URL.Query().Get("interaction"))
+	if interaction == "" {
+		interaction = AuthzInteractionRead
+	}
+	if interaction != AuthzInteractionRead && interaction != AuthzInteractionUpdate {
+		return nil, otel.Error(span, coolfhir.BadRequest("interaction must be read or update"))
+	}
+	policy, ok := s.authzPolicies[authzPolicyKey(tenant.ID, resourceType, interaction)]
+	authzResourceType := authzResourceTypeOf(resourceType)
+	if !ok || authzResourceType == nil {
+		return nil, otel.Error(span, coolfhir.BadRequest("no authorization policy for %s %s", interaction, resourceType))
+	}
+
+	fhirClient, err := s.createFHIRClient(ctx)
+	if err != nil {
+		return nil, otel.Error(span, err)
+	}
+	var resource []byte
+	if err := fhirClient.ReadWithContext(ctx, resourceType+"/"+resourceID, &resource); err != nil {
+		return nil, otel.Error(span, fmt.Errorf("failed to read resource from FHIR server: %w", err))
+	}
+	decision, err := authzResourceType.hasAccess(ctx, policy, resource, principal)
+	if err != nil {
+		return nil, otel.Error(span, fmt.Errorf("authorization check failed: %w", err))
+	}
+	result := &fhir.Parameters{
+		Parameter: []fhir.ParametersParameter{
+			{Name: "allowed", ValueBoolean: to.Ptr(decision.Allowed)},
+		},
+	}
+	for _, reason := range decision.Reasons {
+		result.Parameter = append(result.Parameter, fhir.ParametersParameter{Name: "reason", ValueString: to.Ptr(reason)})
+	}
+	return result, nil
+}
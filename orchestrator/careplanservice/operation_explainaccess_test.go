@@ -0,0 +1,75 @@
+package careplanservice
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/cmd/profile"
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/test"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+func TestService_ExplainAccess(t *testing.T) {
+	tenantCfg := tenants.Test()
+	patient := fhir.Patient{Id: to.Ptr("1")}
+	SetCreatorExtensionOnResource(&patient, &auth.TestPrincipal1.Organization.Identifier[0])
+	service := &Service{
+		tenants:            tenantCfg,
+		profile:            profile.Test(),
+		fhirClientByTenant: map[string]fhirclient.Client{"test": &test.StubFHIRClient{Resources: []any{patient}}},
+	}
+	var err error
+	service.authzPolicies, err = buildAuthzPolicies(nil, tenantCfg, service.createFHIRClient, service.profile, nil)
+	require.NoError(t, err)
+	explainAccess := func(principal *auth.Principal, path string, query string) (*fhir.Parameters, error) {
+		httpRequest := httptest.NewRequest(http.MethodGet, "/cps/test/"+path+"/$explain-access?"+query, nil)
+		httpRequest.SetPathValue("type", getResourceType(path))
+		httpRequest.SetPathValue("id", getResourceID(path))
+		ctx := tenants.WithTenant(context.Background(), tenantCfg.Sole())
+		ctx = auth.WithPrincipal(ctx, *principal)
+		return service.explainAccessTo(httpRequest.WithContext(ctx))
+	}
+
+	t.Run("allowed", func(t *testing.T) {
+		result, err := explainAccess(auth.TestPrincipal1, "Patient/1", "")
+
+		require.NoError(t, err)
+		require.Equal(t, "allowed", result.Parameter[0].Name)
+		require.True(t, *result.Parameter[0].ValueBoolean)
+		require.Equal(t, "reason", result.Parameter[1].Name)
+		require.Equal(t, "AnyMatchPolicy", *result.Parameter[1].ValueString)
+		require.Equal(t, "CreatorPolicy: principal is the creator", *result.Parameter[2].ValueString)
+	})
+	t.Run("denied", func(t *testing.T) {
+		result, err := explainAccess(auth.TestPrincipal2, "Patient/1", "interaction=update")
+
+		require.NoError(t, err)
+		require.False(t, *result.Parameter[0].ValueBoolean)
+		require.Equal(t, "CreatorPolicy: principal is not the creator", *result.Parameter[1].ValueString)
+	})
+	t.Run("unsupported interaction", func(t *testing.T) {
+		_, err := explainAccess(auth.TestPrincipal1, "Patient/1", "interaction=delete")
+
+		require.EqualError(t, err, "interaction must be read or update")
+	})
+	t.Run("resource type without policy", func(t *testing.T) {
+		_, err := explainAccess(auth.TestPrincipal1, "Device/1", "")
+
+		var errWithCode *coolfhir.ErrorWithCode
+		require.ErrorAs(t, err, &errWithCode)
+		require.Equal(t, http.StatusBadRequest, errWithCode.StatusCode)
+	})
+	t.Run("resource not found", func(t *testing.T) {
+		_, err := explainAccess(auth.TestPrincipal1, "Patient/2", "")
+
+		require.ErrorContains(t, err, "failed to read resource from FHIR server")
+	})
+}
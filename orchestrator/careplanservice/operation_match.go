@@ -0,0 +1,146 @@
+package careplanservice
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/debug"
+	"github.com/SanteonNL/orca/orchestrator/lib/otel"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// handlePatientMatch handles the Patient $match operation, which finds existing Patients that are likely the same patient as the given Patient.
+// Patients with the same BSN are certain matches, other Patients are scored on their demographics (see matchPatient).
+// Only Patients the principal may read are returned, so matching doesn't widen access to Patients.
+func (s *Service) handlePatientMatch(httpResponse http.ResponseWriter, httpRequest *http.Request) {
+	result, err := s.matchPatients(httpRequest)
+	if err != nil {
+		coolfhir.WriteOperationOutcomeFromError(httpRequest.Context(), err, "CarePlanService/PatientMatch", httpResponse)
+		return
+	}
+	coolfhir.SendResponse(httpResponse, http.StatusOK, result)
+}
+
+func (s *Service) matchPatients(httpRequest *http.Request) (*fhir.Bundle, error) {
+	ctx, span := tracer.Start(
+		httpRequest.Context(),
+		debug.GetFullCallerName(),
+		trace.WithSpanKind(trace.SpanKindServer),
+	)
+	defer span.End()
+	ctx = withAuthzCache(ctx)
+
+	tenant, err := tenants.FromContext(ctx)
+	if err != nil {
+		return nil, otel.Error(span, err)
+	}
+	principal, err := auth.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, otel.Error(span, err)
+	}
+	var parameters fhir.Parameters
+	if err := s.readRequest(httpRequest, span, &parameters); err != nil {
+		return nil, otel.Error(span, coolfhir.BadRequest("invalid Parameters: %w", err))
+	}
+	var patient *fhir.Patient
+	onlyCertainMatches := false
+	count := 0
+	for _, parameter := range parameters.Parameter {
+		switch parameter.Name {
+		case "resource":
+			if err := json.Unmarshal(parameter.Resource, &patient); err != nil {
+				return nil, otel.Error(span, coolfhir.BadRequest("invalid resource parameter: %w", err))
+			}
+		case "onlyCertainMatches":
+			onlyCertainMatches = parameter.ValueBoolean != nil && *parameter.ValueBoolean
+		case "count":
+			if parameter.ValueInteger != nil {
+				count = *parameter.ValueInteger
+			}
+		}
+	}
+	if patient == nil {
+		return nil, otel.Error(span, coolfhir.BadRequest("resource parameter (Patient) is required"))
+	}
+	bsn := patientBSN(*patient)
+	if bsn == nil && patient.BirthDate == nil {
+		return nil, otel.Error(span, coolfhir.BadRequest("Patient must have a BSN or birth date to be matched"))
+	}
+
+	fhirClient, err := s.createFHIRClient(ctx)
+	if err != nil {
+		return nil, otel.Error(span, err)
+	}
+	// Find candidates by BSN, and by birth date for demographic matching
+	var candidates []fhir.Patient
+	if bsn != nil {
+		patients, _, err := handleSearchResource[fhir.Patient](ctx, fhirClient, "Patient", url.Values{"identifier": {coolfhir.IdentifierToToken(*bsn)}}, &fhirclient.Headers{})
+		if err != nil {
+			return nil, otel.Error(span, fmt.Errorf("failed to search for Patients by BSN: %w", err))
+		}
+		candidates = append(candidates, patients...)
+	}
+	if patient.BirthDate != nil && !onlyCertainMatches {
+		patients, _, err := handleSearchResource[fhir.Patient](ctx, fhirClient, "Patient", url.Values{"birthdate": {*patient.BirthDate}}, &fhirclient.Headers{})
+		if err != nil {
+			return nil, otel.Error(span, fmt.Errorf("failed to search for Patients by birth date: %w", err))
+		}
+		candidates = append(candidates, patients...)
+	}
+
+	readPolicy := authzPolicy[*fhir.Patient](s, tenant.ID, "Patient", AuthzInteractionRead)
+	cpsBaseURL := tenant.URL(s.orcaPublicURL, FHIRBaseURL)
+	var entries []fhir.BundleEntry
+	seen := map[string]bool{}
+	for _, candidate := range candidates {
+		if candidate.Id == nil || seen[*candidate.Id] || isReplacedPatient(candidate) {
+			continue
+		}
+		seen[*candidate.Id] = true
+		score, grade := matchPatient(*patient, candidate)
+		if grade == "" || (onlyCertainMatches && grade != matchGradeCertain) {
+			continue
+		}
+		decision, err := readPolicy.HasAccess(ctx, &candidate, principal)
+		if err != nil || decision == nil || !decision.Allowed {
+			continue
+		}
+		candidateJSON, err := json.Marshal(candidate)
+		if err != nil {
+			return nil, otel.Error(span, err)
+		}
+		entries = append(entries, fhir.BundleEntry{
+			FullUrl:  to.Ptr(cpsBaseURL.JoinPath("Patient", *candidate.Id).String()),
+			Resource: candidateJSON,
+			Search: &fhir.BundleEntrySearch{
+				Extension: []fhir.Extension{
+					{Url: matchGradeExtensionURL, ValueCode: to.Ptr(grade)},
+				},
+				Mode:  to.Ptr(fhir.SearchEntryModeMatch),
+				Score: to.Ptr(score),
+			},
+		})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return *entries[i].Search.Score > *entries[j].Search.Score
+	})
+	if count > 0 && len(entries) > count {
+		entries = entries[:count]
+	}
+	span.SetAttributes(attribute.Int("fhir.patient.match_count", len(entries)))
+	return &fhir.Bundle{
+		Type:  fhir.BundleTypeSearchset,
+		Total: to.Ptr(len(entries)),
+		Entry: entries,
+	}, nil
+}
@@ -0,0 +1,144 @@
+package careplanservice
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/careplancontributor/mock"
+	"github.com/SanteonNL/orca/orchestrator/cmd/profile"
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/must"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	"go.uber.org/mock/gomock"
+)
+
+func TestService_MatchPatients(t *testing.T) {
+	tenantCfg := tenants.Test()
+	bsn := fhir.Identifier{System: to.Ptr(coolfhir.BSNNamingSystem), Value: to.Ptr("111222333")}
+	name := []fhir.HumanName{{Family: to.Ptr("Jansen"), Given: []string{"Jan"}}}
+	newPatient := func(id string, creator *auth.Principal, mutator func(patient *fhir.Patient)) fhir.Patient {
+		patient := fhir.Patient{Id: to.Ptr(id), BirthDate: to.Ptr("1980-01-15")}
+		mutator(&patient)
+		SetCreatorExtensionOnResource(&patient, &creator.Organization.Identifier[0])
+		return patient
+	}
+	// Same BSN
+	certainMatch := newPatient("1", auth.TestPrincipal1, func(patient *fhir.Patient) {
+		patient.Identifier = []fhir.Identifier{bsn}
+	})
+	// Same birth date, family and given name
+	probableMatch := newPatient("2", auth.TestPrincipal1, func(patient *fhir.Patient) {
+		patient.Name = name
+	})
+	// Same BSN, but replaced by the certain match
+	replaced := newPatient("3", auth.TestPrincipal1, func(patient *fhir.Patient) {
+		patient.Identifier = []fhir.Identifier{bsn}
+		patient.Link = []fhir.PatientLink{{Other: fhir.Reference{Reference: to.Ptr("Patient/1")}, Type: fhir.LinkTypeReplacedBy}}
+	})
+	// Only the same birth date
+	noMatch := newPatient("4", auth.TestPrincipal1, func(patient *fhir.Patient) {
+		patient.Name = []fhir.HumanName{{Family: to.Ptr("Pietersen")}}
+	})
+	// Same BSN, but the principal may not read it
+	notAuthorized := newPatient("5", auth.TestPrincipal2, func(patient *fhir.Patient) {
+		patient.Identifier = []fhir.Identifier{bsn}
+	})
+
+	match := func(t *testing.T, parameters fhir.Parameters) (*fhir.Bundle, []url.Values, error) {
+		var capturedQueries []url.Values
+		fhirClient := mock.NewMockClient(gomock.NewController(t))
+		fhirClient.EXPECT().SearchWithContext(gomock.Any(), "Patient", gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, query url.Values, target any, _ ...fhirclient.Option) error {
+				capturedQueries = append(capturedQueries, query)
+				if query.Has("identifier") {
+					*target.(*fhir.Bundle) = coolfhir.SearchSet().Append(certainMatch, nil, nil).Append(replaced, nil, nil).Append(notAuthorized, nil, nil).Bundle()
+				} else {
+					*target.(*fhir.Bundle) = coolfhir.SearchSet().Append(certainMatch, nil, nil).Append(probableMatch, nil, nil).Append(noMatch, nil, nil).Bundle()
+				}
+				return nil
+			}).AnyTimes()
+		fhirClient.EXPECT().SearchWithContext(gomock.Any(), "CarePlan", gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, _ url.Values, target any, _ ...fhirclient.Option) error {
+				*target.(*fhir.Bundle) = coolfhir.SearchSet().Bundle()
+				return nil
+			}).AnyTimes()
+		service := &Service{
+			tenants:            tenantCfg,
+			profile:            profile.Test(),
+			orcaPublicURL:      must.ParseURL("https://example.com/orca"),
+			maxReadBodySize:    1024 * 1024,
+			fhirClientByTenant: map[string]fhirclient.Client{"test": fhirClient},
+		}
+		httpRequest := httptest.NewRequest(http.MethodPost, "/cps/test/Patient/$match", bytes.NewReader(must.MarshalJSON(parameters)))
+		ctx := tenants.WithTenant(context.Background(), tenantCfg.Sole())
+		ctx = auth.WithPrincipal(ctx, *auth.TestPrincipal1)
+		result, err := service.matchPatients(httpRequest.WithContext(ctx))
+		return result, capturedQueries, err
+	}
+	matchParameters := func(patient fhir.Patient, parameters ...fhir.ParametersParameter) fhir.Parameters {
+		return fhir.Parameters{
+			Parameter: append([]fhir.ParametersParameter{{Name: "resource", Resource: must.MarshalJSON(patient)}}, parameters...),
+		}
+	}
+	patient := fhir.Patient{
+		Identifier: []fhir.Identifier{bsn},
+		Name:       name,
+		BirthDate:  to.Ptr("1980-01-15"),
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		result, queries, err := match(t, matchParameters(patient))
+
+		require.NoError(t, err)
+		require.Len(t, queries, 2)
+		require.Equal(t, fhir.BundleTypeSearchset, result.Type)
+		require.Equal(t, 2, *result.Total)
+		require.Equal(t, "https://example.com/orca/cps/test/Patient/1", *result.Entry[0].FullUrl)
+		require.Equal(t, 1.0, *result.Entry[0].Search.Score)
+		require.Equal(t, "certain", *result.Entry[0].Search.Extension[0].ValueCode)
+		require.Equal(t, "https://example.com/orca/cps/test/Patient/2", *result.Entry[1].FullUrl)
+		require.Equal(t, 0.8, *result.Entry[1].Search.Score)
+		require.Equal(t, "probable", *result.Entry[1].Search.Extension[0].ValueCode)
+	})
+	t.Run("only certain matches", func(t *testing.T) {
+		result, queries, err := match(t, matchParameters(patient, fhir.ParametersParameter{Name: "onlyCertainMatches", ValueBoolean: to.Ptr(true)}))
+
+		require.NoError(t, err)
+		require.Len(t, queries, 1)
+		require.Len(t, result.Entry, 1)
+		require.Equal(t, "https://example.com/orca/cps/test/Patient/1", *result.Entry[0].FullUrl)
+	})
+	t.Run("count", func(t *testing.T) {
+		result, _, err := match(t, matchParameters(patient, fhir.ParametersParameter{Name: "count", ValueInteger: to.Ptr(1)}))
+
+		require.NoError(t, err)
+		require.Len(t, result.Entry, 1)
+	})
+	t.Run("demographics only", func(t *testing.T) {
+		result, queries, err := match(t, matchParameters(fhir.Patient{Name: name, BirthDate: to.Ptr("1980-01-15")}))
+
+		require.NoError(t, err)
+		require.Len(t, queries, 1)
+		require.Len(t, result.Entry, 1)
+		require.Equal(t, "https://example.com/orca/cps/test/Patient/2", *result.Entry[0].FullUrl)
+	})
+	t.Run("no Patient", func(t *testing.T) {
+		_, _, err := match(t, fhir.Parameters{})
+
+		require.EqualError(t, err, "resource parameter (Patient) is required")
+	})
+	t.Run("no BSN or birth date", func(t *testing.T) {
+		_, _, err := match(t, matchParameters(fhir.Patient{Name: name}))
+
+		require.EqualError(t, err, "Patient must have a BSN or birth date to be matched")
+	})
+}
@@ -10,6 +10,7 @@ import (
 
 	fhirclient "github.com/SanteonNL/go-fhir-client"
 	"github.com/SanteonNL/orca/orchestrator/lib/audit"
+	"github.com/SanteonNL/orca/orchestrator/lib/consent"
 	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
 	"github.com/SanteonNL/orca/orchestrator/lib/debug"
 	"github.com/SanteonNL/orca/orchestrator/lib/logging"
@@ -26,6 +27,9 @@ var _ FHIROperation = &FHIRReadOperationHandler[fhir.HasExtension]{}
 type FHIRReadOperationHandler[T fhir.HasExtension] struct {
 	fhirClientFactory FHIRClientFactory
 	authzPolicy       Policy[T]
+	// consentChecker checks whether the patient consented to sharing the resource with the principal.
+	// If nil, consent isn't checked.
+	consentChecker consent.Checker
 }
 
 func (h FHIRReadOperationHandler[T]) Handle(ctx context.Context, request FHIRHandlerRequest, tx *coolfhir.BundleBuilder) (FHIRHandlerResult, error) {
@@ -67,6 +71,18 @@ func (h FHIRReadOperationHandler[T]) Handle(ctx context.Context, request FHIRHan
 		})
 	}
 
+	if h.consentChecker != nil {
+		consentRequest, err := consentRequestFor(ctx, fhirClient, request, resource)
+		if err != nil {
+			return nil, otel.Error(span, err, "failed to resolve patient for consent check")
+		}
+		if consentRequest != nil {
+			if err = consent.Enforce(ctx, h.consentChecker, fhirClient, *consentRequest); err != nil {
+				return nil, otel.Error(span, err)
+			}
+		}
+	}
+
 	// Add authorization decision details to span
 	span.SetAttributes(
 		attribute.Bool("fhir.authorization.allowed", authzDecision.Allowed),
@@ -99,7 +115,7 @@ func (h FHIRReadOperationHandler[T]) Handle(ctx context.Context, request FHIRHan
 	}, &fhir.Reference{
 		Identifier: &request.Principal.Organization.Identifier[0],
 		Type:       to.Ptr("Organization"),
-	}, authzDecision.Reasons)
+	}, request.Principal.UserAuditAgent(), authzDecision.Reasons)
 	tx.Create(auditEvent)
 
 	span.SetStatus(codes.Ok, "")
@@ -4,6 +4,7 @@ import (
 	"context"
 	fhirclient "github.com/SanteonNL/go-fhir-client"
 	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/consent"
 	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
 	"github.com/SanteonNL/orca/orchestrator/lib/must"
 	"github.com/SanteonNL/orca/orchestrator/lib/test"
@@ -89,6 +90,66 @@ func TestFHIRReadOperationHandler_Handle(t *testing.T) {
 		assert.NoError(t, err)
 		assert.NotNil(t, result)
 	})
+	t.Run("patient did not consent", func(t *testing.T) {
+		fhirClient := &test.StubFHIRClient{
+			Resources: []any{
+				fhir.Task{
+					Id:  to.Ptr("1"),
+					For: &fhir.Reference{Identifier: &fhir.Identifier{System: to.Ptr(coolfhir.BSNNamingSystem), Value: to.Ptr("1")}},
+				},
+			},
+		}
+		request := FHIRHandlerRequest{
+			ResourcePath:  "Task/1",
+			ResourceId:    "1",
+			Principal:     auth.TestPrincipal2,
+			LocalIdentity: &auth.TestPrincipal1.Organization.Identifier[0],
+			FhirHeaders:   new(fhirclient.Headers),
+		}
+		tx := coolfhir.Transaction()
+		result, err := FHIRReadOperationHandler[*fhir.Task]{
+			fhirClientFactory: FHIRClientFactoryFor(fhirClient),
+			authzPolicy:       AnyonePolicy[*fhir.Task]{},
+			consentChecker:    consent.StubChecker{Deny: []string{coolfhir.BSNNamingSystem + "|1"}},
+		}.Handle(ctx, request, tx)
+		var outcome fhirclient.OperationOutcomeError
+		require.ErrorAs(t, err, &outcome)
+		assert.Equal(t, http.StatusForbidden, outcome.HttpStatusCode)
+		assert.Nil(t, result)
+		assert.Empty(t, tx.Entry)
+		assert.Len(t, fhirClient.CreatedResources["AuditEvent"], 1, "denial should be recorded as AuditEvent")
+	})
+	t.Run("patient of pseudonymized Task did not consent", func(t *testing.T) {
+		fhirClient := &test.StubFHIRClient{
+			Resources: []any{
+				fhir.Task{
+					Id:  to.Ptr("1"),
+					For: to.Ptr(pseudonymizedSubject(fhir.Reference{Reference: to.Ptr("Patient/1")})),
+				},
+				fhir.Patient{
+					Id:         to.Ptr("1"),
+					Identifier: []fhir.Identifier{{System: to.Ptr(coolfhir.BSNNamingSystem), Value: to.Ptr("1")}},
+				},
+			},
+		}
+		request := FHIRHandlerRequest{
+			ResourcePath:  "Task/1",
+			ResourceId:    "1",
+			Principal:     auth.TestPrincipal2,
+			LocalIdentity: &auth.TestPrincipal1.Organization.Identifier[0],
+			FhirHeaders:   new(fhirclient.Headers),
+		}
+		tx := coolfhir.Transaction()
+		result, err := FHIRReadOperationHandler[*fhir.Task]{
+			fhirClientFactory: FHIRClientFactoryFor(fhirClient),
+			authzPolicy:       AnyonePolicy[*fhir.Task]{},
+			consentChecker:    consent.StubChecker{Deny: []string{coolfhir.BSNNamingSystem + "|1"}},
+		}.Handle(ctx, request, tx)
+		var outcome fhirclient.OperationOutcomeError
+		require.ErrorAs(t, err, &outcome)
+		assert.Equal(t, http.StatusForbidden, outcome.HttpStatusCode)
+		assert.Nil(t, result)
+	})
 }
 
 type TestPolicy[T any] struct {
@@ -12,6 +12,7 @@ import (
 	fhirclient "github.com/SanteonNL/go-fhir-client"
 	"github.com/SanteonNL/orca/orchestrator/lib/audit"
 	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/consent"
 	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
 	"github.com/SanteonNL/orca/orchestrator/lib/debug"
 	"github.com/SanteonNL/orca/orchestrator/lib/logging"
@@ -28,6 +29,9 @@ var _ FHIROperation = &FHIRSearchOperationHandler[any]{}
 type FHIRSearchOperationHandler[T any] struct {
 	fhirClientFactory FHIRClientFactory
 	authzPolicy       Policy[T]
+	// consentChecker checks whether the patients of the search results consented to sharing them with the principal.
+	// Results the principal may not see are left out. If nil, consent isn't checked.
+	consentChecker consent.Checker
 }
 
 func (h FHIRSearchOperationHandler[T]) Handle(ctx context.Context, request FHIRHandlerRequest, tx *coolfhir.BundleBuilder) (FHIRHandlerResult, error) {
@@ -50,6 +54,27 @@ func (h FHIRSearchOperationHandler[T]) Handle(ctx context.Context, request FHIRH
 		return nil, otel.Error(span, err, "search and filter failed")
 	}
 
+	// Leave out results of patients that didn't consent to sharing their data with the principal
+	if h.consentChecker != nil {
+		fhirClient, err := h.fhirClientFactory(ctx)
+		if err != nil {
+			return nil, otel.Error(span, err)
+		}
+		filter := consentFilter{checker: h.consentChecker, fhirClient: fhirClient}
+		j := 0
+		for i, resource := range resources {
+			if filter.permitted(ctx, request, resource) {
+				resources[j] = resource
+				bundle.Entry[j] = bundle.Entry[i]
+				policyDecisions[j] = policyDecisions[i]
+				j++
+			}
+		}
+		resources = resources[:j]
+		bundle.Entry = bundle.Entry[:j]
+		policyDecisions = policyDecisions[:j]
+	}
+
 	// Set meta.source
 	for i, resource := range resources {
 		updateMetaSource(resource, request.BaseURL)
@@ -99,7 +124,7 @@ func (h FHIRSearchOperationHandler[T]) Handle(ctx context.Context, request FHIRH
 		}, &fhir.Reference{
 			Identifier: &request.Principal.Organization.Identifier[0],
 			Type:       to.Ptr("Organization"),
-		}, policyDecisions[i].Reasons)
+		}, request.Principal.UserAuditAgent(), policyDecisions[i].Reasons)
 		tx.Create(auditEvent)
 	}
 
@@ -129,13 +154,28 @@ func (h FHIRSearchOperationHandler[T]) searchAndFilter(ctx context.Context, quer
 
 	span.SetAttributes(attribute.Int("fhir.search.raw_results", len(resources)))
 
-	// Filter authorized resources
+	// Filter authorized resources. Decide on all resources at once if possible, which reduces the number of lookups for related resources.
+	// If that fails, decide on each resource separately, so a single failing resource doesn't fail the whole search.
+	var batchDecisions []*PolicyDecision
+	if len(resources) > 0 {
+		batchDecisions, err = hasAccessBatch(ctx, h.authzPolicy, resources, *principal)
+		if err != nil {
+			slog.WarnContext(ctx, "Error checking authz policy for all search results, checking each result separately",
+				slog.String(logging.FieldError, err.Error()),
+				slog.String(logging.FieldResourceType, resourceType))
+		}
+	}
 	j := 0
 	var allowedPolicyDecisions []PolicyDecision
 	authzErrors := 0
 	for i, resource := range resources {
 		resourceID := *coolfhir.ResourceID(resource)
-		authzDecision, err := h.authzPolicy.HasAccess(ctx, resource, *principal)
+		var authzDecision *PolicyDecision
+		if batchDecisions != nil {
+			authzDecision = batchDecisions[i]
+		} else {
+			authzDecision, err = h.authzPolicy.HasAccess(ctx, resource, *principal)
+		}
 		if err != nil {
 			authzErrors++
 			slog.ErrorContext(ctx, "Error checking authz policy",
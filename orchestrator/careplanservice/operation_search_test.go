@@ -4,11 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/consent"
 	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
 	"github.com/SanteonNL/orca/orchestrator/lib/must"
 	"github.com/SanteonNL/orca/orchestrator/lib/test"
 	"github.com/SanteonNL/orca/orchestrator/lib/to"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
 	"testing"
 )
@@ -133,4 +135,70 @@ func TestFHIRSearchOperationHandler_Handle(t *testing.T) {
 		assert.Empty(t, notifications)
 		assert.Empty(t, tx.Entry)
 	})
+	t.Run("patient did not consent", func(t *testing.T) {
+		task := func(id string, bsn string) fhir.Task {
+			return fhir.Task{
+				Id:  to.Ptr(id),
+				For: &fhir.Reference{Identifier: &fhir.Identifier{System: to.Ptr(coolfhir.BSNNamingSystem), Value: to.Ptr(bsn)}},
+			}
+		}
+		fhirClient := &test.StubFHIRClient{
+			Resources: []any{task("1", "111"), task("2", "222"), task("3", "111")},
+		}
+		request := FHIRHandlerRequest{
+			ResourcePath:  "Task/_search",
+			QueryParams:   map[string][]string{"_id": {"1,2,3"}},
+			Principal:     auth.TestPrincipal2,
+			LocalIdentity: &auth.TestPrincipal1.Organization.Identifier[0],
+			BaseURL:       baseURL,
+		}
+		tx := coolfhir.Transaction()
+		result, err := FHIRSearchOperationHandler[*fhir.Task]{
+			fhirClientFactory: FHIRClientFactoryFor(fhirClient),
+			authzPolicy:       AnyonePolicy[*fhir.Task]{},
+			consentChecker:    consent.StubChecker{Deny: []string{coolfhir.BSNNamingSystem + "|111"}},
+		}.Handle(ctx, request, tx)
+		require.NoError(t, err)
+		searchResults, _, err := result(nil)
+		require.NoError(t, err)
+		require.Len(t, searchResults, 1)
+		assert.Contains(t, string(searchResults[0].Resource), `"id":"2"`)
+		assert.Len(t, tx.Entry, 1)
+		assert.Len(t, fhirClient.CreatedResources["AuditEvent"], 1, "denial should be recorded once per patient")
+	})
+	t.Run("patient of pseudonymized Task did not consent", func(t *testing.T) {
+		task := func(id string, patientID string) fhir.Task {
+			return fhir.Task{
+				Id:  to.Ptr(id),
+				For: to.Ptr(pseudonymizedSubject(fhir.Reference{Reference: to.Ptr("Patient/" + patientID)})),
+			}
+		}
+		patient := func(id string, bsn string) fhir.Patient {
+			return fhir.Patient{
+				Id:         to.Ptr(id),
+				Identifier: []fhir.Identifier{{System: to.Ptr(coolfhir.BSNNamingSystem), Value: to.Ptr(bsn)}},
+			}
+		}
+		fhirClient := &test.StubFHIRClient{
+			Resources: []any{task("1", "1"), task("2", "2"), patient("1", "111"), patient("2", "222")},
+		}
+		request := FHIRHandlerRequest{
+			ResourcePath:  "Task/_search",
+			QueryParams:   map[string][]string{"_id": {"1,2"}},
+			Principal:     auth.TestPrincipal2,
+			LocalIdentity: &auth.TestPrincipal1.Organization.Identifier[0],
+			BaseURL:       baseURL,
+		}
+		tx := coolfhir.Transaction()
+		result, err := FHIRSearchOperationHandler[*fhir.Task]{
+			fhirClientFactory: FHIRClientFactoryFor(fhirClient),
+			authzPolicy:       AnyonePolicy[*fhir.Task]{},
+			consentChecker:    consent.StubChecker{Deny: []string{coolfhir.BSNNamingSystem + "|111"}},
+		}.Handle(ctx, request, tx)
+		require.NoError(t, err)
+		searchResults, _, err := result(nil)
+		require.NoError(t, err)
+		require.Len(t, searchResults, 1)
+		assert.Contains(t, string(searchResults[0].Resource), `"id":"2"`)
+	})
 }
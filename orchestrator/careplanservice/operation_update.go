@@ -16,6 +16,7 @@ import (
 	"github.com/SanteonNL/orca/orchestrator/lib/logging"
 	"github.com/SanteonNL/orca/orchestrator/lib/otel"
 	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/SanteonNL/orca/orchestrator/lib/validation"
 	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -28,6 +29,7 @@ type FHIRUpdateOperationHandler[T fhir.HasExtension] struct {
 	authzPolicy       Policy[T]
 	fhirClientFactory FHIRClientFactory
 	profile           profile.Provider
+	validator         validation.Validator[T]
 	// createHandler is used for upserting
 	createHandler *FHIRCreateOperationHandler[T]
 }
@@ -141,6 +143,13 @@ func (h FHIRUpdateOperationHandler[T]) Handle(ctx context.Context, request FHIRH
 		attribute.StringSlice("fhir.authorization.reasons", authzDecision.Reasons),
 	)
 
+	if h.validator != nil {
+		if errs := h.validator.Validate(resource); len(errs) > 0 {
+			span.SetAttributes(attribute.String(otel.ValidationResult, "failed"))
+			return nil, otel.Error(span, validationError(resourceType, errs), "validation failed")
+		}
+	}
+
 	SetCreatorExtensionOnResource(resource, &request.Principal.Organization.Identifier[0])
 
 	slog.InfoContext(
@@ -158,9 +167,10 @@ func (h FHIRUpdateOperationHandler[T]) Handle(ctx context.Context, request FHIRH
 			Identifier: &request.Principal.Organization.Identifier[0],
 			Type:       to.Ptr("Organization"),
 		},
-		Observer: *request.LocalIdentity,
-		Action:   fhir.AuditEventActionU,
-		Policy:   authzDecision.Reasons,
+		ActingUser: request.Principal.UserAuditAgent(),
+		Observer:   *request.LocalIdentity,
+		Action:     fhir.AuditEventActionU,
+		Policy:     authzDecision.Reasons,
 	}))
 
 	span.SetStatus(codes.Ok, "")
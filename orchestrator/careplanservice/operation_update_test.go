@@ -3,12 +3,14 @@ package careplanservice
 import (
 	"context"
 	"encoding/json"
+	fhirclient "github.com/SanteonNL/go-fhir-client"
 	"github.com/SanteonNL/orca/orchestrator/cmd/profile"
 	"github.com/SanteonNL/orca/orchestrator/lib/auth"
 	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
 	"github.com/SanteonNL/orca/orchestrator/lib/must"
 	"github.com/SanteonNL/orca/orchestrator/lib/test"
 	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/SanteonNL/orca/orchestrator/lib/validation"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
@@ -66,6 +68,7 @@ func TestFHIRUpdateOperationHandler_Handle(t *testing.T) {
 		want      func(t *testing.T, tx fhir.Bundle, result FHIRHandlerResult)
 		wantErr   assert.ErrorAssertionFunc
 		policy    Policy[*fhir.Task]
+		validator validation.Validator[*fhir.Task]
 		fhirError error
 	}
 	tests := []testCase{
@@ -242,6 +245,25 @@ func TestFHIRUpdateOperationHandler_Handle(t *testing.T) {
 				return assert.EqualError(t, err, "failed to search for Task: assert.AnError general error for testing")
 			},
 		},
+		{
+			name: "failed validation",
+			args: args{
+				resource:          updatedTaskWithCreatorExtension,
+				existingResources: []fhir.Task{existingTaskWithCreatorExtension},
+			},
+			validator: &expressionFailureValidator{},
+			want: func(t *testing.T, tx fhir.Bundle, result FHIRHandlerResult) {
+				assert.Empty(t, tx.Entry)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				expectedErr := new(fhirclient.OperationOutcomeError)
+				return assert.ErrorAs(t, err, &expectedErr) &&
+					assert.Equal(t, http.StatusBadRequest, expectedErr.HttpStatusCode) &&
+					assert.Len(t, expectedErr.OperationOutcome.Issue, 3) &&
+					assert.Equal(t, fhir.IssueTypeRequired, expectedErr.OperationOutcome.Issue[1].Code) &&
+					assert.Equal(t, []string{"Task.status"}, expectedErr.OperationOutcome.Issue[1].Expression)
+			},
+		},
 		{
 			name: "invalid input resource",
 			args: args{
@@ -296,6 +318,7 @@ func TestFHIRUpdateOperationHandler_Handle(t *testing.T) {
 				authzPolicy:       policy,
 				fhirClientFactory: FHIRClientFactoryFor(fhirClient),
 				profile:           profile.Test(),
+				validator:         tt.validator,
 				createHandler: &FHIRCreateOperationHandler[*fhir.Task]{
 					authzPolicy:       policy,
 					fhirClientFactory: FHIRClientFactoryFor(fhirClient),
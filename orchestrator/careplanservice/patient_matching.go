@@ -0,0 +1,156 @@
+package careplanservice
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+// matchGradeExtensionURL is the URL of the extension that indicates the match grade of a $match result.
+const matchGradeExtensionURL = "http://hl7.org/fhir/StructureDefinition/match-grade"
+
+const (
+	matchGradeCertain  = "certain"
+	matchGradeProbable = "probable"
+	matchGradePossible = "possible"
+)
+
+// patientBSN returns the BSN identifier of the Patient, or nil if it has none.
+func patientBSN(patient fhir.Patient) *fhir.Identifier {
+	bsn := coolfhir.FilterFirstIdentifier(&patient.Identifier, coolfhir.BSNNamingSystem)
+	if bsn == nil || bsn.Value == nil || *bsn.Value == "" {
+		return nil
+	}
+	return bsn
+}
+
+// isReplacedPatient returns whether the Patient has been replaced by (linked to) another Patient.
+func isReplacedPatient(patient fhir.Patient) bool {
+	for _, link := range patient.Link {
+		if link.Type == fhir.LinkTypeReplacedBy {
+			return true
+		}
+	}
+	return false
+}
+
+// primaryPatient returns the Patient that represents the patient when multiple Patients are found for the same patient (e.g. by BSN):
+// the first Patient that hasn't been replaced by another Patient. It returns nil if there are no Patients.
+func primaryPatient(patients []fhir.Patient) *fhir.Patient {
+	for i := range patients {
+		if !isReplacedPatient(patients[i]) {
+			return &patients[i]
+		}
+	}
+	if len(patients) > 0 {
+		return &patients[0]
+	}
+	return nil
+}
+
+// mergePatient returns the existing Patient with the data of the other Patient (of the same patient) it lacks:
+// identifiers, names, telecom and addresses it doesn't have yet, and gender and birth date if unset.
+// Existing data is never overwritten or removed. It returns whether the existing Patient was changed.
+func mergePatient(existing fhir.Patient, other fhir.Patient) (fhir.Patient, bool) {
+	changed := false
+	for _, identifier := range other.Identifier {
+		if !coolfhir.HasIdentifier(identifier, existing.Identifier...) {
+			existing.Identifier = append(existing.Identifier, identifier)
+			changed = true
+		}
+	}
+	for _, name := range other.Name {
+		if !slices.ContainsFunc(existing.Name, func(existingName fhir.HumanName) bool { return reflect.DeepEqual(existingName, name) }) {
+			existing.Name = append(existing.Name, name)
+			changed = true
+		}
+	}
+	for _, telecom := range other.Telecom {
+		if !slices.ContainsFunc(existing.Telecom, func(existingTelecom fhir.ContactPoint) bool { return reflect.DeepEqual(existingTelecom, telecom) }) {
+			existing.Telecom = append(existing.Telecom, telecom)
+			changed = true
+		}
+	}
+	for _, address := range other.Address {
+		if !slices.ContainsFunc(existing.Address, func(existingAddress fhir.Address) bool { return reflect.DeepEqual(existingAddress, address) }) {
+			existing.Address = append(existing.Address, address)
+			changed = true
+		}
+	}
+	if existing.Gender == nil && other.Gender != nil {
+		existing.Gender = other.Gender
+		changed = true
+	}
+	if existing.BirthDate == nil && other.BirthDate != nil {
+		existing.BirthDate = other.BirthDate
+		changed = true
+	}
+	return existing, changed
+}
+
+// matchPatient scores how likely the candidate Patient is the same patient as the given Patient.
+// A matching BSN is a certain match, a different BSN is never a match (score 0).
+// Otherwise, the score is based on demographics: birth date, family name, given name, gender and postal code.
+func matchPatient(patient fhir.Patient, candidate fhir.Patient) (float64, string) {
+	if bsn, candidateBSN := patientBSN(patient), patientBSN(candidate); bsn != nil && candidateBSN != nil {
+		if *bsn.Value == *candidateBSN.Value {
+			return 1, matchGradeCertain
+		}
+		return 0, ""
+	}
+	// Points out of 10, to prevent floating point rounding errors
+	var points int
+	if patient.BirthDate != nil && candidate.BirthDate != nil && *patient.BirthDate == *candidate.BirthDate {
+		points += 4
+	}
+	if family := patientFamilyName(patient); family != "" && family == patientFamilyName(candidate) {
+		points += 3
+	}
+	if given := patientGivenName(patient); given != "" && given == patientGivenName(candidate) {
+		points++
+	}
+	if patient.Gender != nil && candidate.Gender != nil && *patient.Gender == *candidate.Gender {
+		points++
+	}
+	if postalCode := patientPostalCode(patient); postalCode != "" && postalCode == patientPostalCode(candidate) {
+		points++
+	}
+	score := float64(points) / 10
+	switch {
+	case points >= 8:
+		return score, matchGradeProbable
+	case points >= 5:
+		return score, matchGradePossible
+	}
+	return score, ""
+}
+
+func patientFamilyName(patient fhir.Patient) string {
+	for _, name := range patient.Name {
+		if name.Family != nil {
+			return strings.ToLower(strings.TrimSpace(*name.Family))
+		}
+	}
+	return ""
+}
+
+func patientGivenName(patient fhir.Patient) string {
+	for _, name := range patient.Name {
+		if len(name.Given) > 0 {
+			return strings.ToLower(strings.TrimSpace(name.Given[0]))
+		}
+	}
+	return ""
+}
+
+func patientPostalCode(patient fhir.Patient) string {
+	for _, address := range patient.Address {
+		if address.PostalCode != nil {
+			return strings.ToUpper(strings.ReplaceAll(*address.PostalCode, " ", ""))
+		}
+	}
+	return ""
+}
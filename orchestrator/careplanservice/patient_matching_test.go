@@ -0,0 +1,97 @@
+package careplanservice
+
+import (
+	"testing"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+func Test_matchPatient(t *testing.T) {
+	bsn := func(value string) []fhir.Identifier {
+		return []fhir.Identifier{{System: to.Ptr(coolfhir.BSNNamingSystem), Value: to.Ptr(value)}}
+	}
+	patient := fhir.Patient{
+		Identifier: bsn("111222333"),
+		Name:       []fhir.HumanName{{Family: to.Ptr("Jansen"), Given: []string{"Jan"}}},
+		BirthDate:  to.Ptr("1980-01-15"),
+		Gender:     to.Ptr(fhir.AdministrativeGenderMale),
+		Address:    []fhir.Address{{PostalCode: to.Ptr("1234 AB")}},
+	}
+	t.Run("same BSN", func(t *testing.T) {
+		score, grade := matchPatient(patient, fhir.Patient{Identifier: bsn("111222333")})
+		require.Equal(t, 1.0, score)
+		require.Equal(t, matchGradeCertain, grade)
+	})
+	t.Run("different BSN", func(t *testing.T) {
+		candidate := patient
+		candidate.Identifier = bsn("444555666")
+		score, grade := matchPatient(patient, candidate)
+		require.Equal(t, 0.0, score)
+		require.Empty(t, grade)
+	})
+	t.Run("same demographics", func(t *testing.T) {
+		candidate := patient
+		candidate.Identifier = nil
+		candidate.Name = []fhir.HumanName{{Family: to.Ptr(" jansen"), Given: []string{"JAN"}}}
+		candidate.Address = []fhir.Address{{PostalCode: to.Ptr("1234ab")}}
+		score, grade := matchPatient(patient, candidate)
+		require.Equal(t, 1.0, score)
+		require.Equal(t, matchGradeProbable, grade)
+	})
+	t.Run("same birth date and family name", func(t *testing.T) {
+		score, grade := matchPatient(patient, fhir.Patient{BirthDate: patient.BirthDate, Name: []fhir.HumanName{{Family: to.Ptr("Jansen")}}})
+		require.Equal(t, 0.7, score)
+		require.Equal(t, matchGradePossible, grade)
+	})
+	t.Run("only same birth date", func(t *testing.T) {
+		_, grade := matchPatient(patient, fhir.Patient{BirthDate: patient.BirthDate})
+		require.Empty(t, grade)
+	})
+}
+
+func Test_primaryPatient(t *testing.T) {
+	replaced := fhir.Patient{Id: to.Ptr("1"), Link: []fhir.PatientLink{{Type: fhir.LinkTypeReplacedBy}}}
+	primary := fhir.Patient{Id: to.Ptr("2")}
+	require.Equal(t, "2", *primaryPatient([]fhir.Patient{replaced, primary}).Id)
+	require.Equal(t, "1", *primaryPatient([]fhir.Patient{replaced}).Id)
+	require.Nil(t, primaryPatient(nil))
+}
+
+func Test_mergePatient(t *testing.T) {
+	bsn := fhir.Identifier{System: to.Ptr(coolfhir.BSNNamingSystem), Value: to.Ptr("111222333")}
+	localIdentifier := fhir.Identifier{System: to.Ptr("http://example.com/patient"), Value: to.Ptr("1")}
+	existing := fhir.Patient{
+		Id:         to.Ptr("1"),
+		Identifier: []fhir.Identifier{bsn},
+		Name:       []fhir.HumanName{{Family: to.Ptr("Jansen")}},
+		Gender:     to.Ptr(fhir.AdministrativeGenderMale),
+	}
+	t.Run("adds missing data", func(t *testing.T) {
+		other := fhir.Patient{
+			Identifier: []fhir.Identifier{bsn, localIdentifier},
+			Name:       []fhir.HumanName{{Family: to.Ptr("Jansen")}},
+			Telecom:    []fhir.ContactPoint{{System: to.Ptr(fhir.ContactPointSystemEmail), Value: to.Ptr("test@example.com")}},
+			Gender:     to.Ptr(fhir.AdministrativeGenderFemale),
+			BirthDate:  to.Ptr("1980-01-15"),
+		}
+
+		merged, changed := mergePatient(existing, other)
+
+		require.True(t, changed)
+		require.Equal(t, "1", *merged.Id)
+		require.Equal(t, []fhir.Identifier{bsn, localIdentifier}, merged.Identifier)
+		require.Len(t, merged.Name, 1)
+		require.Equal(t, other.Telecom, merged.Telecom)
+		require.Equal(t, fhir.AdministrativeGenderMale, *merged.Gender, "existing data should not be overwritten")
+		require.Equal(t, "1980-01-15", *merged.BirthDate)
+	})
+	t.Run("nothing to merge", func(t *testing.T) {
+		merged, changed := mergePatient(existing, fhir.Patient{Identifier: []fhir.Identifier{bsn}})
+
+		require.False(t, changed)
+		require.Equal(t, existing, merged)
+	})
+}
@@ -0,0 +1,70 @@
+package careplanservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/google/uuid"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+// isPatientPseudonym returns whether the given identifier is a pseudonymous patient identifier issued by the CPS.
+func isPatientPseudonym(identifier *fhir.Identifier) bool {
+	return identifier != nil && identifier.System != nil && *identifier.System == coolfhir.PatientPseudonymNamingSystem
+}
+
+// pseudonymizedSubject returns the subject of a new CarePlan with its identifier (e.g. the BSN) replaced by a new pseudonymous patient identifier.
+// The pseudonym is issued per CarePlan, so it can't be used to relate CarePlans of the same patient.
+// The subject must reference the Patient, so participants that may read the Patient can translate the pseudonym back to the BSN.
+func pseudonymizedSubject(subject fhir.Reference) fhir.Reference {
+	subject.Identifier = &fhir.Identifier{
+		System: to.Ptr(coolfhir.PatientPseudonymNamingSystem),
+		Value:  to.Ptr(uuid.NewString()),
+	}
+	return subject
+}
+
+// isPseudonymizedSubject returns whether the given Task.for refers to the same patient as the pseudonymized CarePlan.subject,
+// by its reference to the Patient or by the BSN of the referenced Patient.
+// It allows participants to create Tasks for a CarePlan with a pseudonymized subject without knowing the pseudonym.
+func isPseudonymizedSubject(ctx context.Context, fhirClient fhirclient.Client, taskFor fhir.Reference, subject fhir.Reference) (bool, error) {
+	if subject.Reference == nil {
+		return false, nil
+	}
+	if taskFor.Reference != nil && *taskFor.Reference != *subject.Reference {
+		return false, nil
+	}
+	if taskFor.Identifier == nil {
+		return taskFor.Reference != nil, nil
+	}
+	if taskFor.Identifier.System == nil || *taskFor.Identifier.System != coolfhir.BSNNamingSystem || taskFor.Identifier.Value == nil {
+		return false, nil
+	}
+	var patient fhir.Patient
+	if err := fhirClient.ReadWithContext(ctx, *subject.Reference, &patient); err != nil {
+		return false, fmt.Errorf("failed to read Patient of CarePlan: %w", err)
+	}
+	bsn := patientBSN(patient)
+	return bsn != nil && *bsn.Value == *taskFor.Identifier.Value, nil
+}
+
+// resolvePseudonym returns the BSN of the Patient the pseudonymized subject references,
+// since consent is registered for the actual patient rather than a pseudonym.
+func resolvePseudonym(ctx context.Context, fhirClient fhirclient.Client, subject fhir.Reference) (*fhir.Identifier, error) {
+	if subject.Reference == nil {
+		return nil, errors.New("pseudonymized subject doesn't reference a Patient")
+	}
+	var patient fhir.Patient
+	if err := fhirClient.ReadWithContext(ctx, *subject.Reference, &patient); err != nil {
+		return nil, fmt.Errorf("failed to read Patient of pseudonymized subject: %w", err)
+	}
+	bsn := patientBSN(patient)
+	if bsn == nil || bsn.Value == nil {
+		return nil, errors.New("Patient of pseudonymized subject has no BSN")
+	}
+	return bsn, nil
+}
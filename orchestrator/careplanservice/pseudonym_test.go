@@ -0,0 +1,76 @@
+package careplanservice
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/test"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+func Test_pseudonymizedSubject(t *testing.T) {
+	subject := fhir.Reference{
+		Reference:  to.Ptr("Patient/1"),
+		Identifier: &fhir.Identifier{System: to.Ptr(coolfhir.BSNNamingSystem), Value: to.Ptr("111222333")},
+	}
+
+	first := pseudonymizedSubject(subject)
+	second := pseudonymizedSubject(subject)
+
+	require.Equal(t, "Patient/1", *first.Reference)
+	require.True(t, isPatientPseudonym(first.Identifier))
+	require.NotEqual(t, *first.Identifier.Value, *second.Identifier.Value, "pseudonyms should be unique per CarePlan")
+	require.Equal(t, coolfhir.BSNNamingSystem, *subject.Identifier.System, "given subject should not be altered")
+}
+
+func Test_isPseudonymizedSubject(t *testing.T) {
+	ctx := context.Background()
+	patient := fhir.Patient{
+		Id:         to.Ptr("1"),
+		Identifier: []fhir.Identifier{{System: to.Ptr(coolfhir.BSNNamingSystem), Value: to.Ptr("111222333")}},
+	}
+	fhirClient := &test.StubFHIRClient{Resources: []any{patient}}
+	subject := pseudonymizedSubject(fhir.Reference{Reference: to.Ptr("Patient/1")})
+	bsn := func(value string) *fhir.Identifier {
+		return &fhir.Identifier{System: to.Ptr(coolfhir.BSNNamingSystem), Value: to.Ptr(value)}
+	}
+
+	t.Run("same reference", func(t *testing.T) {
+		result, err := isPseudonymizedSubject(ctx, fhirClient, fhir.Reference{Reference: to.Ptr("Patient/1")}, subject)
+		require.NoError(t, err)
+		require.True(t, result)
+	})
+	t.Run("other reference", func(t *testing.T) {
+		result, err := isPseudonymizedSubject(ctx, fhirClient, fhir.Reference{Reference: to.Ptr("Patient/2"), Identifier: bsn("111222333")}, subject)
+		require.NoError(t, err)
+		require.False(t, result)
+	})
+	t.Run("BSN of the Patient", func(t *testing.T) {
+		result, err := isPseudonymizedSubject(ctx, fhirClient, fhir.Reference{Identifier: bsn("111222333")}, subject)
+		require.NoError(t, err)
+		require.True(t, result)
+	})
+	t.Run("other BSN", func(t *testing.T) {
+		result, err := isPseudonymizedSubject(ctx, fhirClient, fhir.Reference{Identifier: bsn("999999999")}, subject)
+		require.NoError(t, err)
+		require.False(t, result)
+	})
+	t.Run("other identifier system", func(t *testing.T) {
+		result, err := isPseudonymizedSubject(ctx, fhirClient, fhir.Reference{Identifier: &fhir.Identifier{System: to.Ptr("other"), Value: to.Ptr("111222333")}}, subject)
+		require.NoError(t, err)
+		require.False(t, result)
+	})
+	t.Run("CarePlan.subject without reference", func(t *testing.T) {
+		result, err := isPseudonymizedSubject(ctx, fhirClient, fhir.Reference{Identifier: bsn("111222333")}, fhir.Reference{Identifier: subject.Identifier})
+		require.NoError(t, err)
+		require.False(t, result)
+	})
+	t.Run("reading Patient fails", func(t *testing.T) {
+		_, err := isPseudonymizedSubject(ctx, &test.StubFHIRClient{Error: errors.New("oops")}, fhir.Reference{Identifier: bsn("111222333")}, subject)
+		require.EqualError(t, err, "failed to read Patient of CarePlan: oops")
+	})
+}
@@ -34,10 +34,12 @@ import (
 	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir/pipeline"
 
 	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/SanteonNL/orca/orchestrator/lib/validation/fhirprofile"
 
 	fhirclient "github.com/SanteonNL/go-fhir-client"
 	"github.com/SanteonNL/orca/orchestrator/careplanservice/subscriptions"
 	"github.com/SanteonNL/orca/orchestrator/cmd/profile"
+	"github.com/SanteonNL/orca/orchestrator/lib/consent"
 	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
 	"github.com/SanteonNL/orca/orchestrator/lib/otel"
 	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
@@ -128,6 +130,55 @@ func New(config Config, tenantCfg tenants.Config, profile profile.Provider, orca
 		subscriptionManager: subscriptionMgr,
 		eventManager:        eventManager,
 		maxReadBodySize:     fhirClientConfig.MaxResponseSize,
+		explainAccess:       config.Authz.ExplainAccess,
+		breakTheGlass:       config.Authz.BreakTheGlass,
+		pseudonymization:    config.Pseudonymization.Enabled,
+	}
+
+	var authzPolicies *AuthzPolicies
+	if config.Authz.PolicyFile != "" {
+		if authzPolicies, err = LoadAuthzPolicies(config.Authz.PolicyFile); err != nil {
+			return nil, err
+		}
+		if err = authzPolicies.Validate(tenantCfg); err != nil {
+			return nil, err
+		}
+	}
+	var breakTheGlassPolicy *BreakTheGlassPolicy
+	if s.breakTheGlass.Enabled {
+		breakTheGlassPolicy = &BreakTheGlassPolicy{fhirClientFactory: s.createFHIRClient, duration: s.breakTheGlass.Duration}
+	}
+	if s.authzPolicies, err = buildAuthzPolicies(authzPolicies, tenantCfg, s.createFHIRClient, profile, breakTheGlassPolicy); err != nil {
+		return nil, err
+	}
+
+	if len(config.ProfileValidation.Packages) > 0 {
+		if s.profileValidator, err = fhirprofile.Load(config.ProfileValidation.Packages, config.ProfileValidation.Profiles); err != nil {
+			return nil, fmt.Errorf("profile validation: %w", err)
+		}
+	}
+
+	s.consentCheckerByTenant = make(map[string]consent.Checker)
+	for _, tenant := range tenantCfg {
+		checker, err := consent.New(tenant.Consent, func(source consent.Source) (fhirclient.Client, error) {
+			if source == consent.SourceCPS {
+				return fhirClientByTenant[tenant.ID], nil
+			}
+			if tenant.EHR.FHIR.BaseURL == "" {
+				return nil, errors.New("EHR FHIR API is not configured")
+			}
+			_, fhirClient, err := coolfhir.NewAuthRoundTripper(tenant.EHR.FHIR, fhirClientConfig)
+			if err != nil {
+				return nil, err
+			}
+			return coolfhir.NewTracedFHIRClient(fhirClient, tracer), nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tenant.ID, err)
+		}
+		if checker != nil {
+			s.consentCheckerByTenant[tenant.ID] = checker
+		}
 	}
 
 	// Register event handlers
@@ -177,7 +228,17 @@ type Service struct {
 	subscriptionManager subscriptions.Manager
 	eventManager        events.Manager
 	maxReadBodySize     int
-	handlerProvider     func(method string, resourceType string) func(context.Context, FHIRHandlerRequest, *coolfhir.BundleBuilder) (FHIRHandlerResult, error)
+	// authzPolicies contains the authorization policies per tenant, resource type and interaction (see authzPolicyKey).
+	authzPolicies map[string]any
+	// consentCheckerByTenant contains the consent checker of each tenant that has consent checks enabled.
+	consentCheckerByTenant map[string]consent.Checker
+	// profileValidator validates created and updated resources against FHIR profiles, if configured.
+	profileValidator *fhirprofile.Validator
+	explainAccess    bool
+	breakTheGlass    BreakTheGlassConfig
+	// pseudonymization indicates whether new CarePlans get a pseudonymous patient identifier instead of the BSN.
+	pseudonymization bool
+	handlerProvider  func(method string, resourceType string) func(context.Context, FHIRHandlerRequest, *coolfhir.BundleBuilder) (FHIRHandlerResult, error)
 }
 
 // FHIRHandler defines a function that handles a FHIR request and returns a function to write the response.
@@ -204,6 +265,9 @@ type FHIRHandlerRequest struct {
 	// LocalIdentity contains the identifier of the local care organization handling the FHIR operation invocation.
 	LocalIdentity *fhir.Identifier
 	Upsert        bool
+	// ServiceCodes contains the codes of the ServiceRequests in the same transaction Bundle,
+	// identifying the workflow the request is part of (e.g. for workflow-specific Patient validation).
+	ServiceCodes []fhir.Coding
 }
 
 func (r FHIRHandlerRequest) bundleEntryWithResource(res any) fhir.BundleEntry {
@@ -340,6 +404,17 @@ func (s *Service) RegisterHandlers(mux *http.ServeMux) {
 				s.profile.Authenticator,
 			),
 		},
+		// Custom operations - Patient matching
+		{
+			Method:  "POST",
+			Path:    basePathWithTenant + "/Patient/$match",
+			Handler: s.handlePatientMatch,
+			Middleware: httpserv.Chain(
+				otel.HandlerWithTracing(tracer, fmt.Sprintf("%s.fhir.patient_match", tracerName)),
+				s.tenants.HttpHandler,
+				s.profile.Authenticator,
+			),
+		},
 		// Custom operations - Import
 		{
 			Method:  "POST",
@@ -352,6 +427,33 @@ func (s *Service) RegisterHandlers(mux *http.ServeMux) {
 			),
 		},
 	}
+	if s.explainAccess {
+		// Custom operations - Explain access (debugging authorization policies)
+		routes = append(routes, httpserv.Route{
+			Method:  "GET",
+			Path:    basePathWithTenant + "/{type}/{id}/$explain-access",
+			Handler: s.handleExplainAccess,
+			Middleware: httpserv.Chain(
+				otel.HandlerWithTracing(tracer, fmt.Sprintf("%s.fhir.explain_access", tracerName)),
+				s.tenants.HttpHandler,
+				s.profile.Authenticator,
+			),
+		})
+	}
+
+	if s.breakTheGlass.Enabled {
+		// Custom operations - Break-the-glass review (reviewing emergency access by the local care organization)
+		routes = append(routes, httpserv.Route{
+			Method:  "GET",
+			Path:    basePathWithTenant + "/$break-the-glass-review",
+			Handler: s.handleBreakTheGlassReview,
+			Middleware: httpserv.Chain(
+				otel.HandlerWithTracing(tracer, fmt.Sprintf("%s.fhir.break_the_glass_review", tracerName)),
+				s.tenants.HttpHandler,
+				s.profile.Authenticator,
+			),
+		})
+	}
 
 	httpserv.RegisterRoutes(mux, routes...)
 }
@@ -371,11 +473,10 @@ func (s *Service) commitTransaction(fhirClient fhirclient.Client, request *http.
 	defer span.End()
 
 	if slog.Default().Enabled(ctx, slog.LevelDebug) {
-		txJson, _ := json.MarshalIndent(tx, "", "  ")
 		slog.DebugContext(
 			ctx,
 			"FHIR Transaction",
-			slog.String("request", string(txJson)),
+			slog.Any("request", transactionEntrySummary(tx.Bundle())),
 		)
 	}
 
@@ -384,12 +485,11 @@ func (s *Service) commitTransaction(fhirClient fhirclient.Client, request *http.
 	if err := fhirClient.CreateWithContext(ctx, tx.Bundle(), &txResult, fhirclient.AtPath("/")); err != nil {
 		otel.Error(span, err, "failed to execute FHIR transaction")
 		// If the error is a FHIR OperationOutcome, we should sanitize it before returning it
-		txResultJson, _ := json.Marshal(tx.Bundle())
 		slog.ErrorContext(
 			ctx,
 			"Failed to execute transaction",
 			slog.String(logging.FieldUrl, request.URL.String()),
-			slog.String("request", string(txResultJson)),
+			slog.Any("request", transactionEntrySummary(tx.Bundle())),
 			slog.String(logging.FieldError, err.Error()),
 		)
 		var operationOutcomeErr fhirclient.OperationOutcomeError
@@ -407,11 +507,10 @@ func (s *Service) commitTransaction(fhirClient fhirclient.Client, request *http.
 		Type: fhir.BundleTypeTransactionResponse,
 	}
 	if slog.Default().Enabled(ctx, slog.LevelDebug) {
-		txJson, _ := json.MarshalIndent(txResult, "", "  ")
 		slog.DebugContext(
 			ctx,
 			"FHIR Transaction response",
-			slog.String("response", string(txJson)),
+			slog.Any("response", transactionEntrySummary(txResult)),
 		)
 	}
 	var notificationResources []any
@@ -441,6 +540,21 @@ func (s *Service) commitTransaction(fhirClient fhirclient.Client, request *http.
 	return &resultBundle, nil
 }
 
+// transactionEntrySummary describes the entries of a transaction (response) Bundle for logging,
+// without the resources themselves, since they contain patient data (e.g. the BSN).
+func transactionEntrySummary(bundle fhir.Bundle) []string {
+	var result []string
+	for _, entry := range bundle.Entry {
+		switch {
+		case entry.Request != nil:
+			result = append(result, entry.Request.Method.Code()+" "+entry.Request.Url)
+		case entry.Response != nil:
+			result = append(result, entry.Response.Status+" "+to.Empty(entry.Response.Location))
+		}
+	}
+	return result
+}
+
 // handleTransactionEntry executes the FHIR operation in the HTTP request. It adds the FHIR operations to be executed to the given transaction Bundle,
 // and returns the function that must be executed after the transaction is committed.
 func (s *Service) handleTransactionEntry(ctx context.Context, span trace.Span, request FHIRHandlerRequest, tx *coolfhir.BundleBuilder) (FHIRHandlerResult, error) {
@@ -595,6 +709,8 @@ func (s *Service) handleModification(httpRequest *http.Request, httpResponse htt
 		),
 	)
 	defer span.End()
+	// Share lookups (e.g. CarePlans) between authorization checks within this request
+	ctx = withAuthzCache(ctx)
 
 	tx := coolfhir.Transaction()
 	var bodyBytes []byte
@@ -637,6 +753,7 @@ func (s *Service) handleModification(httpRequest *http.Request, httpResponse htt
 		BaseURL:       tenant.CPS.FHIR.ParseBaseURL(),
 	}
 
+	// Break-the-glass only grants read access, so it isn't recorded for modifications
 	result, err := s.handleTransactionEntry(ctx, span, fhirRequest, tx)
 	if err != nil {
 		coolfhir.WriteOperationOutcomeFromError(ctx, otel.Error(span, err), operationName, httpResponse)
@@ -665,6 +782,13 @@ func (s *Service) handleGet(httpRequest *http.Request, httpResponse http.Respons
 		),
 	)
 	defer span.End()
+	// Share lookups (e.g. CarePlans) between authorization checks within this request
+	ctx = withAuthzCache(ctx)
+	ctx, err := s.withBreakTheGlass(ctx, httpRequest.Header)
+	if err != nil {
+		coolfhir.WriteOperationOutcomeFromError(httpRequest.Context(), otel.Error(span, err), operationName, httpResponse)
+		return
+	}
 
 	fhirHeaders := new(fhirclient.Headers)
 
@@ -704,8 +828,16 @@ func (s *Service) handleGet(httpRequest *http.Request, httpResponse http.Respons
 		coolfhir.WriteOperationOutcomeFromError(ctx, otel.Error(span, err), operationName, httpResponse)
 		return
 	}
+	resultHandlers := []FHIRHandlerResult{result}
+	breakTheGlassResult, err := s.recordBreakTheGlass(ctx, fhirRequest, tx)
+	if err != nil {
+		coolfhir.WriteOperationOutcomeFromError(ctx, otel.Error(span, err), operationName, httpResponse)
+		return
+	} else if breakTheGlassResult != nil {
+		resultHandlers = append(resultHandlers, breakTheGlassResult)
+	}
 
-	txResult, err := s.commitTransaction(s.fhirClientByTenant[tenant.ID], httpRequest.WithContext(ctx), tx, []FHIRHandlerResult{result})
+	txResult, err := s.commitTransaction(s.fhirClientByTenant[tenant.ID], httpRequest.WithContext(ctx), tx, resultHandlers)
 	if err != nil {
 		coolfhir.WriteOperationOutcomeFromError(ctx, otel.Error(span, err), operationName, httpResponse)
 		return
@@ -729,36 +861,72 @@ func (s *Service) handleCreate(resourcePath string) func(context.Context, FHIRHa
 			switch resourceType {
 			case "ServiceRequest":
 				handler = FHIRCreateOperationHandler[*fhir.ServiceRequest]{
-					authzPolicy:       CreateServiceRequestAuthzPolicy(s.profile),
+					authzPolicy:       authzPolicy[*fhir.ServiceRequest](s, request.Tenant.ID, "ServiceRequest", AuthzInteractionCreate),
 					fhirClientFactory: s.createFHIRClient,
 					profile:           s.profile,
+					validator:         resourceValidator[*fhir.ServiceRequest](s),
 				}.Handle
 			case "Patient":
-				handler = FHIRCreateOperationHandler[*fhir.Patient]{
-					authzPolicy:       CreatePatientAuthzPolicy(s.profile),
-					fhirClientFactory: s.createFHIRClient,
-					profile:           s.profile,
-
-					validator: &PatientValidator{},
-				}.Handle
+				handler = s.handleCreatePatient
 			case "Questionnaire":
 				handler = FHIRCreateOperationHandler[*fhir.Questionnaire]{
-					authzPolicy:       CreateQuestionnaireAuthzPolicy(),
+					authzPolicy:       authzPolicy[*fhir.Questionnaire](s, request.Tenant.ID, "Questionnaire", AuthzInteractionCreate),
 					fhirClientFactory: s.createFHIRClient,
 					profile:           s.profile,
+					validator:         resourceValidator[*fhir.Questionnaire](s),
 				}.Handle
 			case "QuestionnaireResponse":
 				handler = FHIRCreateOperationHandler[*fhir.QuestionnaireResponse]{
-					authzPolicy:       CreateQuestionnaireResponseAuthzPolicy(s.profile),
+					authzPolicy:       authzPolicy[*fhir.QuestionnaireResponse](s, request.Tenant.ID, "QuestionnaireResponse", AuthzInteractionCreate),
 					fhirClientFactory: s.createFHIRClient,
 					profile:           s.profile,
+					validator:         resourceValidator[*fhir.QuestionnaireResponse](s),
 				}.Handle
 			case "Condition":
 				handler = FHIRCreateOperationHandler[*fhir.Condition]{
-					authzPolicy:       CreateConditionAuthzPolicy(s.profile),
+					authzPolicy:       authzPolicy[*fhir.Condition](s, request.Tenant.ID, "Condition", AuthzInteractionCreate),
 					fhirClientFactory: s.createFHIRClient,
 					profile:           s.profile,
+					validator:         resourceValidator[*fhir.Condition](s),
+				}.Handle
+			case "Goal":
+				handler = carePlanLinkingHandler[*fhir.Goal]{
+					fhirClientFactory: s.createFHIRClient,
+					handler: FHIRCreateOperationHandler[*fhir.Goal]{
+						authzPolicy:       authzPolicy[*fhir.Goal](s, request.Tenant.ID, "Goal", AuthzInteractionCreate),
+						fhirClientFactory: s.createFHIRClient,
+						profile:           s.profile,
+						validator:         resourceValidator[*fhir.Goal](s),
+					}.Handle,
+					carePlanReferences: goalCarePlanReferences,
+					carePlanElement:    carePlanGoals,
+				}.Handle
+			case "Observation":
+				handler = carePlanLinkingHandler[*fhir.Observation]{
+					fhirClientFactory: s.createFHIRClient,
+					handler: FHIRCreateOperationHandler[*fhir.Observation]{
+						authzPolicy:       authzPolicy[*fhir.Observation](s, request.Tenant.ID, "Observation", AuthzInteractionCreate),
+						fhirClientFactory: s.createFHIRClient,
+						profile:           s.profile,
+						validator:         resourceValidator[*fhir.Observation](s),
+					}.Handle,
+					carePlanReferences: observationCarePlanReferences,
+					carePlanElement:    carePlanSupportingInfo,
+				}.Handle
+			case "DocumentReference":
+				handler = carePlanLinkingHandler[*fhir.DocumentReference]{
+					fhirClientFactory: s.createFHIRClient,
+					handler: FHIRCreateOperationHandler[*fhir.DocumentReference]{
+						authzPolicy:       authzPolicy[*fhir.DocumentReference](s, request.Tenant.ID, "DocumentReference", AuthzInteractionCreate),
+						fhirClientFactory: s.createFHIRClient,
+						profile:           s.profile,
+						validator:         resourceValidator[*fhir.DocumentReference](s),
+					}.Handle,
+					carePlanReferences: documentReferenceCarePlanReferences,
+					carePlanElement:    carePlanSupportingInfo,
 				}.Handle
+			case "Communication":
+				handler = s.handleCreateCommunication
 			default:
 				handler = func(ctx context.Context, request FHIRHandlerRequest, tx *coolfhir.BundleBuilder) (FHIRHandlerResult, error) {
 					return s.handleUnmanagedOperation(ctx, request, tx)
@@ -781,59 +949,130 @@ func (s *Service) handleUpdate(resourcePath string) func(context.Context, FHIRHa
 			handler = s.handleUpdateTask
 		case "ServiceRequest":
 			handler = FHIRUpdateOperationHandler[*fhir.ServiceRequest]{
-				authzPolicy:       UpdateServiceRequestAuthzPolicy(),
+				authzPolicy:       authzPolicy[*fhir.ServiceRequest](s, request.Tenant.ID, "ServiceRequest", AuthzInteractionUpdate),
 				fhirClientFactory: s.createFHIRClient,
 				profile:           s.profile,
+				validator:         resourceValidator[*fhir.ServiceRequest](s),
 				createHandler: &FHIRCreateOperationHandler[*fhir.ServiceRequest]{
-					authzPolicy:       CreateServiceRequestAuthzPolicy(s.profile),
+					authzPolicy:       authzPolicy[*fhir.ServiceRequest](s, request.Tenant.ID, "ServiceRequest", AuthzInteractionCreate),
 					fhirClientFactory: s.createFHIRClient,
 					profile:           s.profile,
+					validator:         resourceValidator[*fhir.ServiceRequest](s),
 				},
 			}.Handle
 		case "Patient":
+			// Updated Patients must satisfy the same rules as created Patients
+			patientValidator := &PatientValidator{Rules: request.Tenant.PatientValidation.RulesFor(request.ServiceCodes)}
 			handler = FHIRUpdateOperationHandler[*fhir.Patient]{
-				authzPolicy:       UpdatePatientAuthzPolicy(),
+				authzPolicy:       authzPolicy[*fhir.Patient](s, request.Tenant.ID, "Patient", AuthzInteractionUpdate),
 				fhirClientFactory: s.createFHIRClient,
 				profile:           s.profile,
+				validator:         resourceValidator[*fhir.Patient](s, patientValidator),
 				createHandler: &FHIRCreateOperationHandler[*fhir.Patient]{
-					authzPolicy:       CreatePatientAuthzPolicy(s.profile),
+					authzPolicy:       authzPolicy[*fhir.Patient](s, request.Tenant.ID, "Patient", AuthzInteractionCreate),
 					fhirClientFactory: s.createFHIRClient,
 					profile:           s.profile,
+					validator:         resourceValidator[*fhir.Patient](s, patientValidator),
 				},
 			}.Handle
 		case "Questionnaire":
 			handler = FHIRUpdateOperationHandler[*fhir.Questionnaire]{
-				authzPolicy:       UpdateQuestionnaireAuthzPolicy(),
+				authzPolicy:       authzPolicy[*fhir.Questionnaire](s, request.Tenant.ID, "Questionnaire", AuthzInteractionUpdate),
 				fhirClientFactory: s.createFHIRClient,
 				profile:           s.profile,
+				validator:         resourceValidator[*fhir.Questionnaire](s),
 				createHandler: &FHIRCreateOperationHandler[*fhir.Questionnaire]{
-					authzPolicy:       CreateQuestionnaireAuthzPolicy(),
+					authzPolicy:       authzPolicy[*fhir.Questionnaire](s, request.Tenant.ID, "Questionnaire", AuthzInteractionCreate),
 					fhirClientFactory: s.createFHIRClient,
 					profile:           s.profile,
+					validator:         resourceValidator[*fhir.Questionnaire](s),
 				},
 			}.Handle
 		case "QuestionnaireResponse":
 			handler = FHIRUpdateOperationHandler[*fhir.QuestionnaireResponse]{
-				authzPolicy:       UpdateQuestionnaireResponseAuthzPolicy(),
+				authzPolicy:       authzPolicy[*fhir.QuestionnaireResponse](s, request.Tenant.ID, "QuestionnaireResponse", AuthzInteractionUpdate),
 				fhirClientFactory: s.createFHIRClient,
 				profile:           s.profile,
+				validator:         resourceValidator[*fhir.QuestionnaireResponse](s),
 				createHandler: &FHIRCreateOperationHandler[*fhir.QuestionnaireResponse]{
-					authzPolicy:       CreateQuestionnaireResponseAuthzPolicy(s.profile),
+					authzPolicy:       authzPolicy[*fhir.QuestionnaireResponse](s, request.Tenant.ID, "QuestionnaireResponse", AuthzInteractionCreate),
 					fhirClientFactory: s.createFHIRClient,
 					profile:           s.profile,
+					validator:         resourceValidator[*fhir.QuestionnaireResponse](s),
 				},
 			}.Handle
 		case "Condition":
 			handler = FHIRUpdateOperationHandler[*fhir.Condition]{
-				authzPolicy:       UpdateConditionAuthzPolicy(),
+				authzPolicy:       authzPolicy[*fhir.Condition](s, request.Tenant.ID, "Condition", AuthzInteractionUpdate),
 				fhirClientFactory: s.createFHIRClient,
 				profile:           s.profile,
+				validator:         resourceValidator[*fhir.Condition](s),
 				createHandler: &FHIRCreateOperationHandler[*fhir.Condition]{
-					authzPolicy:       CreateConditionAuthzPolicy(s.profile),
+					authzPolicy:       authzPolicy[*fhir.Condition](s, request.Tenant.ID, "Condition", AuthzInteractionCreate),
 					fhirClientFactory: s.createFHIRClient,
 					profile:           s.profile,
+					validator:         resourceValidator[*fhir.Condition](s),
 				},
 			}.Handle
+		case "Goal":
+			handler = carePlanLinkingHandler[*fhir.Goal]{
+				fhirClientFactory: s.createFHIRClient,
+				handler: FHIRUpdateOperationHandler[*fhir.Goal]{
+					authzPolicy:       authzPolicy[*fhir.Goal](s, request.Tenant.ID, "Goal", AuthzInteractionUpdate),
+					fhirClientFactory: s.createFHIRClient,
+					profile:           s.profile,
+					validator:         resourceValidator[*fhir.Goal](s),
+					createHandler: &FHIRCreateOperationHandler[*fhir.Goal]{
+						authzPolicy:       authzPolicy[*fhir.Goal](s, request.Tenant.ID, "Goal", AuthzInteractionCreate),
+						fhirClientFactory: s.createFHIRClient,
+						profile:           s.profile,
+						validator:         resourceValidator[*fhir.Goal](s),
+					},
+				}.Handle,
+				carePlanReferences: goalCarePlanReferences,
+				carePlanElement:    carePlanGoals,
+				linkPolicy:         authzPolicy[*fhir.Goal](s, request.Tenant.ID, "Goal", AuthzInteractionCreate),
+			}.Handle
+		case "Observation":
+			handler = carePlanLinkingHandler[*fhir.Observation]{
+				fhirClientFactory: s.createFHIRClient,
+				handler: FHIRUpdateOperationHandler[*fhir.Observation]{
+					authzPolicy:       authzPolicy[*fhir.Observation](s, request.Tenant.ID, "Observation", AuthzInteractionUpdate),
+					fhirClientFactory: s.createFHIRClient,
+					profile:           s.profile,
+					validator:         resourceValidator[*fhir.Observation](s),
+					createHandler: &FHIRCreateOperationHandler[*fhir.Observation]{
+						authzPolicy:       authzPolicy[*fhir.Observation](s, request.Tenant.ID, "Observation", AuthzInteractionCreate),
+						fhirClientFactory: s.createFHIRClient,
+						profile:           s.profile,
+						validator:         resourceValidator[*fhir.Observation](s),
+					},
+				}.Handle,
+				carePlanReferences: observationCarePlanReferences,
+				carePlanElement:    carePlanSupportingInfo,
+				linkPolicy:         authzPolicy[*fhir.Observation](s, request.Tenant.ID, "Observation", AuthzInteractionCreate),
+			}.Handle
+		case "DocumentReference":
+			handler = carePlanLinkingHandler[*fhir.DocumentReference]{
+				fhirClientFactory: s.createFHIRClient,
+				handler: FHIRUpdateOperationHandler[*fhir.DocumentReference]{
+					authzPolicy:       authzPolicy[*fhir.DocumentReference](s, request.Tenant.ID, "DocumentReference", AuthzInteractionUpdate),
+					fhirClientFactory: s.createFHIRClient,
+					profile:           s.profile,
+					validator:         resourceValidator[*fhir.DocumentReference](s),
+					createHandler: &FHIRCreateOperationHandler[*fhir.DocumentReference]{
+						authzPolicy:       authzPolicy[*fhir.DocumentReference](s, request.Tenant.ID, "DocumentReference", AuthzInteractionCreate),
+						fhirClientFactory: s.createFHIRClient,
+						profile:           s.profile,
+						validator:         resourceValidator[*fhir.DocumentReference](s),
+					},
+				}.Handle,
+				carePlanReferences: documentReferenceCarePlanReferences,
+				carePlanElement:    carePlanSupportingInfo,
+				linkPolicy:         authzPolicy[*fhir.DocumentReference](s, request.Tenant.ID, "DocumentReference", AuthzInteractionCreate),
+			}.Handle
+		case "Communication":
+			handler = s.handleUpdateCommunication
 		default:
 			handler = func(ctx context.Context, request FHIRHandlerRequest, tx *coolfhir.BundleBuilder) (FHIRHandlerResult, error) {
 				return s.handleUnmanagedOperation(ctx, request, tx)
@@ -854,38 +1093,69 @@ func (s *Service) handleRead(resourcePath string) func(context.Context, FHIRHand
 		switch resourceType {
 		case "Patient":
 			handleFunc = FHIRReadOperationHandler[*fhir.Patient]{
-				authzPolicy:       ReadPatientAuthzPolicy(s.createFHIRClient),
+				authzPolicy:       authzPolicy[*fhir.Patient](s, request.Tenant.ID, "Patient", AuthzInteractionRead),
 				fhirClientFactory: s.createFHIRClient,
+				consentChecker:    s.consentCheckerByTenant[request.Tenant.ID],
 			}.Handle
 		case "Condition":
 			handleFunc = FHIRReadOperationHandler[*fhir.Condition]{
-				authzPolicy:       ReadConditionAuthzPolicy(s.createFHIRClient),
+				authzPolicy:       authzPolicy[*fhir.Condition](s, request.Tenant.ID, "Condition", AuthzInteractionRead),
 				fhirClientFactory: s.createFHIRClient,
+				consentChecker:    s.consentCheckerByTenant[request.Tenant.ID],
 			}.Handle
 		case "CarePlan":
 			handleFunc = FHIRReadOperationHandler[*fhir.CarePlan]{
-				authzPolicy:       ReadCarePlanAuthzPolicy(),
+				authzPolicy:       authzPolicy[*fhir.CarePlan](s, request.Tenant.ID, "CarePlan", AuthzInteractionRead),
 				fhirClientFactory: s.createFHIRClient,
+				consentChecker:    s.consentCheckerByTenant[request.Tenant.ID],
 			}.Handle
 		case "Task":
 			handleFunc = FHIRReadOperationHandler[*fhir.Task]{
-				authzPolicy:       ReadTaskAuthzPolicy(s.createFHIRClient),
+				authzPolicy:       authzPolicy[*fhir.Task](s, request.Tenant.ID, "Task", AuthzInteractionRead),
 				fhirClientFactory: s.createFHIRClient,
+				consentChecker:    s.consentCheckerByTenant[request.Tenant.ID],
 			}.Handle
 		case "ServiceRequest":
 			handleFunc = FHIRReadOperationHandler[*fhir.ServiceRequest]{
-				authzPolicy:       ReadServiceRequestAuthzPolicy(s.createFHIRClient),
+				authzPolicy:       authzPolicy[*fhir.ServiceRequest](s, request.Tenant.ID, "ServiceRequest", AuthzInteractionRead),
 				fhirClientFactory: s.createFHIRClient,
+				consentChecker:    s.consentCheckerByTenant[request.Tenant.ID],
 			}.Handle
 		case "Questionnaire":
 			handleFunc = FHIRReadOperationHandler[*fhir.Questionnaire]{
-				authzPolicy:       ReadQuestionnaireAuthzPolicy(),
+				authzPolicy:       authzPolicy[*fhir.Questionnaire](s, request.Tenant.ID, "Questionnaire", AuthzInteractionRead),
 				fhirClientFactory: s.createFHIRClient,
+				consentChecker:    s.consentCheckerByTenant[request.Tenant.ID],
 			}.Handle
 		case "QuestionnaireResponse":
 			handleFunc = FHIRReadOperationHandler[*fhir.QuestionnaireResponse]{
-				authzPolicy:       ReadQuestionnaireResponseAuthzPolicy(s.createFHIRClient),
+				authzPolicy:       authzPolicy[*fhir.QuestionnaireResponse](s, request.Tenant.ID, "QuestionnaireResponse", AuthzInteractionRead),
+				fhirClientFactory: s.createFHIRClient,
+				consentChecker:    s.consentCheckerByTenant[request.Tenant.ID],
+			}.Handle
+		case "Goal":
+			handleFunc = FHIRReadOperationHandler[*fhir.Goal]{
+				authzPolicy:       authzPolicy[*fhir.Goal](s, request.Tenant.ID, "Goal", AuthzInteractionRead),
+				fhirClientFactory: s.createFHIRClient,
+				consentChecker:    s.consentCheckerByTenant[request.Tenant.ID],
+			}.Handle
+		case "Observation":
+			handleFunc = FHIRReadOperationHandler[*fhir.Observation]{
+				authzPolicy:       authzPolicy[*fhir.Observation](s, request.Tenant.ID, "Observation", AuthzInteractionRead),
+				fhirClientFactory: s.createFHIRClient,
+				consentChecker:    s.consentCheckerByTenant[request.Tenant.ID],
+			}.Handle
+		case "DocumentReference":
+			handleFunc = FHIRReadOperationHandler[*fhir.DocumentReference]{
+				authzPolicy:       authzPolicy[*fhir.DocumentReference](s, request.Tenant.ID, "DocumentReference", AuthzInteractionRead),
 				fhirClientFactory: s.createFHIRClient,
+				consentChecker:    s.consentCheckerByTenant[request.Tenant.ID],
+			}.Handle
+		case "Communication":
+			handleFunc = FHIRReadOperationHandler[*fhir.Communication]{
+				authzPolicy:       authzPolicy[*fhir.Communication](s, request.Tenant.ID, "Communication", AuthzInteractionRead),
+				fhirClientFactory: s.createFHIRClient,
+				consentChecker:    s.consentCheckerByTenant[request.Tenant.ID],
 			}.Handle
 		default:
 			handleFunc = s.handleUnmanagedOperation
@@ -938,38 +1208,69 @@ func (s *Service) handleSearch(resourcePath string) func(context.Context, FHIRHa
 		switch resourceType {
 		case "Patient":
 			handleFunc = FHIRSearchOperationHandler[*fhir.Patient]{
-				authzPolicy:       ReadPatientAuthzPolicy(s.createFHIRClient),
+				authzPolicy:       authzPolicy[*fhir.Patient](s, request.Tenant.ID, "Patient", AuthzInteractionRead),
 				fhirClientFactory: s.createFHIRClient,
+				consentChecker:    s.consentCheckerByTenant[request.Tenant.ID],
 			}.Handle
 		case "Condition":
 			handleFunc = FHIRSearchOperationHandler[*fhir.Condition]{
-				authzPolicy:       ReadConditionAuthzPolicy(s.createFHIRClient),
+				authzPolicy:       authzPolicy[*fhir.Condition](s, request.Tenant.ID, "Condition", AuthzInteractionRead),
 				fhirClientFactory: s.createFHIRClient,
+				consentChecker:    s.consentCheckerByTenant[request.Tenant.ID],
 			}.Handle
 		case "CarePlan":
 			handleFunc = FHIRSearchOperationHandler[*fhir.CarePlan]{
-				authzPolicy:       ReadCarePlanAuthzPolicy(),
+				authzPolicy:       authzPolicy[*fhir.CarePlan](s, request.Tenant.ID, "CarePlan", AuthzInteractionRead),
 				fhirClientFactory: s.createFHIRClient,
+				consentChecker:    s.consentCheckerByTenant[request.Tenant.ID],
 			}.Handle
 		case "Task":
 			handleFunc = FHIRSearchOperationHandler[*fhir.Task]{
-				authzPolicy:       ReadTaskAuthzPolicy(s.createFHIRClient),
+				authzPolicy:       authzPolicy[*fhir.Task](s, request.Tenant.ID, "Task", AuthzInteractionRead),
 				fhirClientFactory: s.createFHIRClient,
+				consentChecker:    s.consentCheckerByTenant[request.Tenant.ID],
 			}.Handle
 		case "ServiceRequest":
 			handleFunc = FHIRSearchOperationHandler[*fhir.ServiceRequest]{
-				authzPolicy:       ReadServiceRequestAuthzPolicy(s.createFHIRClient),
+				authzPolicy:       authzPolicy[*fhir.ServiceRequest](s, request.Tenant.ID, "ServiceRequest", AuthzInteractionRead),
 				fhirClientFactory: s.createFHIRClient,
+				consentChecker:    s.consentCheckerByTenant[request.Tenant.ID],
 			}.Handle
 		case "Questionnaire":
 			handleFunc = FHIRSearchOperationHandler[*fhir.Questionnaire]{
-				authzPolicy:       ReadQuestionnaireAuthzPolicy(),
+				authzPolicy:       authzPolicy[*fhir.Questionnaire](s, request.Tenant.ID, "Questionnaire", AuthzInteractionRead),
 				fhirClientFactory: s.createFHIRClient,
+				consentChecker:    s.consentCheckerByTenant[request.Tenant.ID],
 			}.Handle
 		case "QuestionnaireResponse":
 			handleFunc = FHIRSearchOperationHandler[*fhir.QuestionnaireResponse]{
-				authzPolicy:       ReadQuestionnaireResponseAuthzPolicy(s.createFHIRClient),
+				authzPolicy:       authzPolicy[*fhir.QuestionnaireResponse](s, request.Tenant.ID, "QuestionnaireResponse", AuthzInteractionRead),
+				fhirClientFactory: s.createFHIRClient,
+				consentChecker:    s.consentCheckerByTenant[request.Tenant.ID],
+			}.Handle
+		case "Goal":
+			handleFunc = FHIRSearchOperationHandler[*fhir.Goal]{
+				authzPolicy:       authzPolicy[*fhir.Goal](s, request.Tenant.ID, "Goal", AuthzInteractionRead),
 				fhirClientFactory: s.createFHIRClient,
+				consentChecker:    s.consentCheckerByTenant[request.Tenant.ID],
+			}.Handle
+		case "Observation":
+			handleFunc = FHIRSearchOperationHandler[*fhir.Observation]{
+				authzPolicy:       authzPolicy[*fhir.Observation](s, request.Tenant.ID, "Observation", AuthzInteractionRead),
+				fhirClientFactory: s.createFHIRClient,
+				consentChecker:    s.consentCheckerByTenant[request.Tenant.ID],
+			}.Handle
+		case "DocumentReference":
+			handleFunc = FHIRSearchOperationHandler[*fhir.DocumentReference]{
+				authzPolicy:       authzPolicy[*fhir.DocumentReference](s, request.Tenant.ID, "DocumentReference", AuthzInteractionRead),
+				fhirClientFactory: s.createFHIRClient,
+				consentChecker:    s.consentCheckerByTenant[request.Tenant.ID],
+			}.Handle
+		case "Communication":
+			handleFunc = FHIRSearchOperationHandler[*fhir.Communication]{
+				authzPolicy:       authzPolicy[*fhir.Communication](s, request.Tenant.ID, "Communication", AuthzInteractionRead),
+				fhirClientFactory: s.createFHIRClient,
+				consentChecker:    s.consentCheckerByTenant[request.Tenant.ID],
 			}.Handle
 		default:
 			handleFunc = s.handleUnmanagedOperation
@@ -990,6 +1291,14 @@ func (s *Service) handleSearchRequest(httpRequest *http.Request, httpResponse ht
 		),
 	)
 	defer span.End()
+	// Share lookups (e.g. CarePlans) between authorization checks within this request
+	ctx = withAuthzCache(ctx)
+	ctx, err := s.withBreakTheGlass(ctx, httpRequest.Header)
+	if err != nil {
+		otel.Error(span, err)
+		coolfhir.WriteOperationOutcomeFromError(httpRequest.Context(), err, operationName, httpResponse)
+		return
+	}
 
 	if err := s.validateSearchRequest(httpRequest); err != nil {
 		otel.Error(span, err)
@@ -1060,6 +1369,15 @@ func (s *Service) handleSearchRequest(httpRequest *http.Request, httpResponse ht
 		coolfhir.WriteOperationOutcomeFromError(ctx, err, operationName, httpResponse)
 		return
 	}
+	resultHandlers := []FHIRHandlerResult{result}
+	breakTheGlassResult, err := s.recordBreakTheGlass(ctx, fhirRequest, tx)
+	if err != nil {
+		otel.Error(span, err)
+		coolfhir.WriteOperationOutcomeFromError(ctx, err, operationName, httpResponse)
+		return
+	} else if breakTheGlassResult != nil {
+		resultHandlers = append(resultHandlers, breakTheGlassResult)
+	}
 
 	// Execute the transaction
 	fhirClient, err := s.createFHIRClient(ctx)
@@ -1068,7 +1386,7 @@ func (s *Service) handleSearchRequest(httpRequest *http.Request, httpResponse ht
 		coolfhir.WriteOperationOutcomeFromError(ctx, err, operationName, httpResponse)
 		return
 	}
-	txResult, err := s.commitTransaction(fhirClient, httpRequest.WithContext(ctx), tx, []FHIRHandlerResult{result})
+	txResult, err := s.commitTransaction(fhirClient, httpRequest.WithContext(ctx), tx, resultHandlers)
 	if err != nil {
 		otel.Error(span, err)
 		coolfhir.WriteOperationOutcomeFromError(ctx, err, operationName, httpResponse)
@@ -1079,6 +1397,23 @@ func (s *Service) handleSearchRequest(httpRequest *http.Request, httpResponse ht
 	s.writeSearchResponse(httpResponse, txResult, ctx)
 }
 
+// serviceRequestCodes returns the codes of the ServiceRequests in the given Bundle.
+func serviceRequestCodes(bundle fhir.Bundle) []fhir.Coding {
+	var result []fhir.Coding
+	for _, entry := range bundle.Entry {
+		var serviceRequest struct {
+			ResourceType string                `json:"resourceType"`
+			Code         *fhir.CodeableConcept `json:"code"`
+		}
+		if err := json.Unmarshal(entry.Resource, &serviceRequest); err != nil ||
+			serviceRequest.ResourceType != "ServiceRequest" || serviceRequest.Code == nil {
+			continue
+		}
+		result = append(result, serviceRequest.Code.Coding...)
+	}
+	return result
+}
+
 func (s *Service) handleBundle(httpResponse http.ResponseWriter, httpRequest *http.Request) {
 	ctx, span := tracer.Start(
 		httpRequest.Context(),
@@ -1089,6 +1424,8 @@ func (s *Service) handleBundle(httpResponse http.ResponseWriter, httpRequest *ht
 		),
 	)
 	defer span.End()
+	// Share lookups (e.g. CarePlans) between authorization checks within this request
+	ctx = withAuthzCache(ctx)
 
 	// Create Bundle
 	var bundle fhir.Bundle
@@ -1132,6 +1469,8 @@ func (s *Service) handleBundle(httpResponse http.ResponseWriter, httpRequest *ht
 		return
 	}
 
+	serviceCodes := serviceRequestCodes(bundle)
+
 	span.AddEvent(otel.FHIRTransactionPrepare)
 	// Perform each individual operation. Note this doesn't actually create/update resources at the backing FHIR server,
 	// but only prepares the transaction.
@@ -1170,6 +1509,7 @@ func (s *Service) handleBundle(httpResponse http.ResponseWriter, httpRequest *ht
 			LocalIdentity: localIdentity,
 			Tenant:        tenant,
 			BaseURL:       tenant.CPS.FHIR.ParseBaseURL(),
+			ServiceCodes:  serviceCodes,
 		}
 		if len(resourcePathParts) == 2 {
 			fhirRequest.ResourceId = resourcePathParts[1]
@@ -1368,6 +1708,22 @@ func (s *Service) ensureCustomSearchParametersExists(ctx context.Context) error
 				Xpath:       to.Ptr("f:Task/f:input/f:valueReference"),
 			},
 		},
+		{
+			SearchParamId: "CarePlan-supporting-info",
+			SearchParam: fhir.SearchParameter{
+				Id:          to.Ptr("CarePlan-supporting-info"),
+				Url:         "http://santeonnl.github.io/shared-care-planning/cps-searchparameter-careplan-supporting-info.json",
+				Name:        "supporting-info",
+				Status:      fhir.PublicationStatusActive,
+				Description: "Search CarePlans by supporting information (e.g. Observations, DocumentReferences)",
+				Code:        "supporting-info",
+				Base:        []fhir.ResourceType{fhir.ResourceTypeCarePlan},
+				Type:        fhir.SearchParamTypeReference,
+				Expression:  to.Ptr("CarePlan.supportingInfo"),
+				XpathUsage:  to.Ptr(fhir.XPathUsageTypeNormal),
+				Xpath:       to.Ptr("f:CarePlan/f:supportingInfo"),
+			},
+		},
 	}
 
 	fhirClient := s.fhirClientByTenant[tenant.ID]
@@ -1670,6 +2026,11 @@ func shouldNotify(resource any) bool {
 		return true
 	case "CarePlan":
 		return true
+	case "Communication":
+		return true
+	case "AuditEvent":
+		// Only break-the-glass AuditEvents are notified, to inform the custodian of the grant
+		return isBreakTheGlassAuditEvent(resource)
 	default:
 		return false
 	}
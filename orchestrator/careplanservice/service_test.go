@@ -21,9 +21,11 @@ import (
 
 	fhirclient "github.com/SanteonNL/go-fhir-client"
 	"github.com/SanteonNL/orca/orchestrator/cmd/profile"
+	"github.com/SanteonNL/orca/orchestrator/lib/audit"
 	"github.com/SanteonNL/orca/orchestrator/lib/auth"
 	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
 	"github.com/SanteonNL/orca/orchestrator/lib/deep"
+	"github.com/SanteonNL/orca/orchestrator/lib/must"
 	"github.com/SanteonNL/orca/orchestrator/lib/test"
 	"github.com/SanteonNL/orca/orchestrator/lib/to"
 	"github.com/stretchr/testify/assert"
@@ -240,7 +242,8 @@ func TestService_ValidationErrorHandling(t *testing.T) {
 
 	require.NotNil(t, target)
 	require.NotEmpty(t, target.Issue)
-	assert.Len(t, target.Issue, 1)
+	// First issue contains all error codes, followed by an issue per invalid element
+	assert.Len(t, target.Issue, 3)
 
 	issue := target.Issue[0]
 	assert.Len(t, issue.Details.Coding, 2)
@@ -254,6 +257,10 @@ func TestService_ValidationErrorHandling(t *testing.T) {
 	}
 	assert.Contains(t, codes, InvalidPhone)
 	assert.Contains(t, codes, InvalidEmail)
+	assert.Equal(t, []string{"Patient.telecom[1].value"}, target.Issue[1].Expression)
+	assert.Equal(t, InvalidEmail, *target.Issue[1].Details.Coding[0].Code)
+	assert.Equal(t, []string{"Patient.telecom[0].value"}, target.Issue[2].Expression)
+	assert.Equal(t, InvalidPhone, *target.Issue[2].Details.Coding[0].Code)
 }
 
 func TestService_Handle(t *testing.T) {
@@ -815,7 +822,7 @@ func TestService_ensureCustomSearchParametersExists(t *testing.T) {
 		}
 		err := service.ensureCustomSearchParametersExists(ctx)
 		require.NoError(t, err)
-		require.Len(t, fhirClient.CreatedResources["SearchParameter"], 3)
+		require.Len(t, fhirClient.CreatedResources["SearchParameter"], 4)
 		// First SearchParameter create, rest should be OK
 		searchParam := fhirClient.CreatedResources["SearchParameter"][0].(fhir.SearchParameter)
 		assert.Equal(t, "CarePlan-subject-identifier", *searchParam.Id)
@@ -841,6 +848,9 @@ func TestService_ensureCustomSearchParametersExists(t *testing.T) {
 				fhir.SearchParameter{
 					Url: "http://santeonnl.github.io/shared-care-planning/cps-searchparameter-task-input-reference.json",
 				},
+				fhir.SearchParameter{
+					Url: "http://santeonnl.github.io/shared-care-planning/cps-searchparameter-careplan-supporting-info.json",
+				},
 			},
 			Metadata: fhir.CapabilityStatement{
 				Rest: []fhir.CapabilityStatementRest{
@@ -857,6 +867,9 @@ func TestService_ensureCustomSearchParametersExists(t *testing.T) {
 									{
 										Definition: to.Ptr("http://santeonnl.github.io/shared-care-planning/cps-searchparameter-task-input-reference.json"),
 									},
+									{
+										Definition: to.Ptr("http://santeonnl.github.io/shared-care-planning/cps-searchparameter-careplan-supporting-info.json"),
+									},
 								},
 							},
 						},
@@ -886,6 +899,9 @@ func TestService_ensureCustomSearchParametersExists(t *testing.T) {
 			fhir.SearchParameter{
 				Url: "http://santeonnl.github.io/shared-care-planning/cps-searchparameter-task-input-reference.json",
 			},
+			fhir.SearchParameter{
+				Url: "http://santeonnl.github.io/shared-care-planning/cps-searchparameter-careplan-supporting-info.json",
+			},
 		}
 
 		fhirClient := test.StubFHIRClient{
@@ -923,6 +939,9 @@ func TestService_ensureCustomSearchParametersExists(t *testing.T) {
 			fhir.SearchParameter{
 				Url: "http://santeonnl.github.io/shared-care-planning/cps-searchparameter-task-input-reference.json",
 			},
+			fhir.SearchParameter{
+				Url: "http://santeonnl.github.io/shared-care-planning/cps-searchparameter-careplan-supporting-info.json",
+			},
 		}
 
 		fhirClient := test.StubFHIRClient{
@@ -939,6 +958,9 @@ func TestService_ensureCustomSearchParametersExists(t *testing.T) {
 									{
 										Definition: to.Ptr("http://santeonnl.github.io/shared-care-planning/cps-searchparameter-task-input-reference.json"),
 									},
+									{
+										Definition: to.Ptr("http://santeonnl.github.io/shared-care-planning/cps-searchparameter-careplan-supporting-info.json"),
+									},
 								},
 							},
 						},
@@ -1094,4 +1116,169 @@ func TestService_notifySubscribers(t *testing.T) {
 		}
 		s.notifySubscribers(context.Background(), &fhir.ActivityDefinition{})
 	})
+	t.Run("break-the-glass AuditEvent causes notification", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		subscriptionManager := subscriptions.NewMockManager(ctrl)
+		subscriptionManager.EXPECT().Notify(gomock.Any(), gomock.Any())
+		s := &Service{
+			subscriptionManager: subscriptionManager,
+		}
+		s.notifySubscribers(context.Background(), &fhir.AuditEvent{Subtype: []fhir.Coding{audit.BreakTheGlassPurposeOfUse}})
+	})
+	t.Run("other AuditEvent does not cause notification", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		subscriptionManager := subscriptions.NewMockManager(ctrl)
+		s := &Service{
+			subscriptionManager: subscriptionManager,
+		}
+		s.notifySubscribers(context.Background(), &fhir.AuditEvent{})
+	})
+}
+
+func Test_serviceRequestCodes(t *testing.T) {
+	serviceRequest := fhir.ServiceRequest{
+		Code: &fhir.CodeableConcept{
+			Coding: []fhir.Coding{{System: to.Ptr("http://snomed.info/sct"), Code: to.Ptr("719858009")}},
+		},
+	}
+	task := fhir.Task{
+		Code: &fhir.CodeableConcept{
+			Coding: []fhir.Coding{{System: to.Ptr("http://snomed.info/sct"), Code: to.Ptr("123")}},
+		},
+	}
+	bundle := coolfhir.Transaction().
+		Create(serviceRequest).
+		Create(task).
+		Bundle()
+
+	codes := serviceRequestCodes(bundle)
+
+	require.Len(t, codes, 1)
+	assert.Equal(t, "719858009", *codes[0].Code)
+}
+
+func TestService_handleUpdate_Patient(t *testing.T) {
+	tenant := tenants.Test().Sole()
+	validPatient := fhir.Patient{
+		Id: to.Ptr("1"),
+		Telecom: []fhir.ContactPoint{
+			{System: to.Ptr(fhir.ContactPointSystemEmail), Value: to.Ptr("test@example.com")},
+			{System: to.Ptr(fhir.ContactPointSystemPhone), Value: to.Ptr("0612345678")},
+		},
+	}
+	invalidPatient := validPatient
+	invalidPatient.Telecom = nil
+	update := func(t *testing.T, patient fhir.Patient, existingResources ...any) error {
+		service := &Service{
+			profile: profile.Test(),
+			fhirClientByTenant: map[string]fhirclient.Client{
+				tenant.ID: &test.StubFHIRClient{Resources: existingResources},
+			},
+		}
+		ctx := tenants.WithTenant(context.Background(), tenant)
+		_, err := service.handleUpdate("Patient/1")(ctx, FHIRHandlerRequest{
+			HttpMethod:    http.MethodPut,
+			ResourcePath:  "Patient/1",
+			ResourceId:    "1",
+			ResourceData:  must.MarshalJSON(patient),
+			Principal:     auth.TestPrincipal1,
+			LocalIdentity: &auth.TestPrincipal1.Organization.Identifier[0],
+			Tenant:        tenant,
+			RequestUrl:    must.ParseURL("Patient/1"),
+			BaseURL:       must.ParseURL("http://example.com/fhir"),
+		}, coolfhir.Transaction())
+		return err
+	}
+	existingPatient := validPatient
+	SetCreatorExtensionOnResource(&existingPatient, &auth.TestPrincipal1.Organization.Identifier[0])
+
+	t.Run("update", func(t *testing.T) {
+		require.NoError(t, update(t, validPatient, existingPatient))
+	})
+	t.Run("update, invalid Patient", func(t *testing.T) {
+		err := update(t, invalidPatient, existingPatient)
+		var outcomeErr *fhirclient.OperationOutcomeError
+		require.ErrorAs(t, err, &outcomeErr)
+		require.ErrorContains(t, err, "Validation failed for Patient")
+	})
+	t.Run("upsert", func(t *testing.T) {
+		require.NoError(t, update(t, validPatient))
+	})
+	t.Run("upsert, invalid Patient", func(t *testing.T) {
+		err := update(t, invalidPatient)
+		var outcomeErr *fhirclient.OperationOutcomeError
+		require.ErrorAs(t, err, &outcomeErr)
+		require.ErrorContains(t, err, "Validation failed for Patient")
+	})
+}
+
+func TestService_handleGet_BreakTheGlass(t *testing.T) {
+	carePlan := fhir.CarePlan{
+		Id:      to.Ptr("cp1"),
+		Subject: *coolfhir.LogicalReference("Patient", coolfhir.BSNNamingSystem, "1333333337"),
+		Author:  coolfhir.LogicalReference("Organization", coolfhir.URANamingSystem, "author"),
+	}
+	var capturedTx fhir.Bundle
+	fhirServerMux := http.NewServeMux()
+	fhirServerMux.HandleFunc("POST /fhir/", func(writer http.ResponseWriter, request *http.Request) {
+		require.NoError(t, json.NewDecoder(request.Body).Decode(&capturedTx))
+		coolfhir.SendResponse(writer, http.StatusOK, fhir.Bundle{
+			Type: fhir.BundleTypeTransactionResponse,
+			Entry: []fhir.BundleEntry{
+				{
+					Resource: must.MarshalJSON(carePlan),
+					Response: &fhir.BundleEntryResponse{Status: "200 OK", Location: to.Ptr("CarePlan/cp1")},
+				},
+				{
+					Resource: must.MarshalJSON(fhir.AuditEvent{Id: to.Ptr("ae1")}),
+					Response: &fhir.BundleEntryResponse{Status: "201 Created", Location: to.Ptr("AuditEvent/ae1")},
+				},
+			},
+		})
+	})
+	mockCustomSearchParams(fhirServerMux)
+	fhirServer := httptest.NewServer(fhirServerMux)
+	tenantCfg := tenants.Test(func(properties *tenants.Properties) {
+		properties.CPS = tenants.CarePlanServiceProperties{
+			FHIR: coolfhir.ClientConfig{
+				BaseURL: fhirServer.URL + "/fhir",
+			},
+		}
+	})
+	tenant := tenantCfg.Sole()
+	config := DefaultConfig()
+	config.Authz.BreakTheGlass.Enabled = true
+	messageBroker := messaging.NewMemoryBroker()
+	service, err := New(config, tenantCfg, profile.Test(), orcaPublicURL.JoinPath("cps"), messageBroker, events.NewManager(messageBroker))
+	require.NoError(t, err)
+	service.handlerProvider = func(method string, resourceType string) func(context.Context, FHIRHandlerRequest, *coolfhir.BundleBuilder) (FHIRHandlerResult, error) {
+		return func(ctx context.Context, request FHIRHandlerRequest, tx *coolfhir.BundleBuilder) (FHIRHandlerResult, error) {
+			// Simulates access being granted by the break-the-glass policy
+			breakTheGlassRequestFromContext(ctx).grant(&carePlan)
+			tx.Get(fhir.CarePlan{}, "CarePlan/cp1")
+			return func(txResult *fhir.Bundle) ([]*fhir.BundleEntry, []any, error) {
+				return []*fhir.BundleEntry{&txResult.Entry[0]}, nil, nil
+			}, nil
+		}
+	}
+	frontServerMux := http.NewServeMux()
+	service.RegisterHandlers(frontServerMux)
+	frontServer := httptest.NewServer(frontServerMux)
+	httpClient := &http.Client{Transport: auth.AuthenticatedTestRoundTripper(nil, auth.TestPrincipal2, "")}
+
+	httpRequest, _ := http.NewRequest(http.MethodGet, frontServer.URL+"/cps/"+tenant.ID+"/CarePlan/cp1", nil)
+	httpRequest.Header.Set(PurposeOfUseHeader, "BTG")
+	httpRequest.Header.Set(JustificationHeader, "patient unconscious")
+	httpResponse, err := httpClient.Do(httpRequest)
+
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, httpResponse.StatusCode)
+	require.Len(t, capturedTx.Entry, 2)
+	require.Equal(t, fhir.HTTPVerbPOST, capturedTx.Entry[1].Request.Method)
+	var auditEvent fhir.AuditEvent
+	require.NoError(t, json.Unmarshal(capturedTx.Entry[1].Resource, &auditEvent))
+	require.True(t, coolfhir.ConceptContainsCoding(audit.BreakTheGlassPurposeOfUse, auditEvent.PurposeOfEvent...))
+	require.Equal(t, "CarePlan/cp1", *auditEvent.Entity[0].What.Reference)
 }
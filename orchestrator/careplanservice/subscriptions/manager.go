@@ -8,9 +8,11 @@ import (
 	"fmt"
 	"log/slog"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/audit"
 	"github.com/SanteonNL/orca/orchestrator/lib/debug"
 	"github.com/SanteonNL/orca/orchestrator/lib/logging"
 	"github.com/SanteonNL/orca/orchestrator/lib/otel"
@@ -58,6 +60,9 @@ var _ Manager = RetryableManager{}
 // that triggered the notification:
 // - Task: it notifies the Task filler and owner
 // - CareTeam: it notifies all participants
+// - CarePlan: it notifies all participants of its CareTeam
+// - Communication: it notifies its recipients
+// - AuditEvent: it notifies the custodians of the accessed CarePlan (e.g. of break-the-glass access), with the CarePlan as focus
 // TODO: It does not yet store the subscription notifications in the FHIR store, which is required to support monotonically increasing event numbers.
 type RetryableManager struct {
 	cpsBaseURLFunc func(tenants.Properties) *url.URL
@@ -157,6 +162,47 @@ func (r RetryableManager) Notify(ctx context.Context, resource interface{}) erro
 				subscribers = append(subscribers, *participant.Member.Identifier)
 			}
 		}
+	case "Communication":
+		communication := resource.(*fhir.Communication)
+		focus = fhir.Reference{
+			Reference: to.Ptr("Communication/" + *communication.Id),
+			Type:      to.Ptr("Communication"),
+		}
+
+		span.SetAttributes(attribute.String(otel.FHIRResourceID, *communication.Id))
+
+		for _, recipient := range communication.Recipient {
+			if coolfhir.IsLogicalIdentifier(recipient.Identifier) {
+				subscribers = append(subscribers, *recipient.Identifier)
+			}
+		}
+	case "AuditEvent":
+		auditEvent := resource.(*fhir.AuditEvent)
+		span.SetAttributes(attribute.String(otel.FHIRResourceID, *auditEvent.Id))
+
+		// Subscribers can't read AuditEvents from the CPS, so the focus is the accessed CarePlan instead.
+		for _, entity := range auditEvent.Entity {
+			if entity.What != nil && strings.HasPrefix(to.EmptyString(entity.What.Reference), "CarePlan/") {
+				focus = fhir.Reference{
+					Reference: entity.What.Reference,
+					Type:      to.Ptr("CarePlan"),
+				}
+				break
+			}
+		}
+		if focus.Reference == nil {
+			return otel.Error(span, fmt.Errorf("AuditEvent/%s does not refer to a CarePlan", *auditEvent.Id))
+		}
+
+		// Notify the custodians of the accessed data, e.g. the author of a CarePlan accessed through break-the-glass
+		for _, agent := range auditEvent.Agent {
+			if agent.Type == nil || !coolfhir.ConceptContainsCoding(audit.CustodianParticipationType, *agent.Type) {
+				continue
+			}
+			if agent.Who != nil && coolfhir.IsLogicalIdentifier(agent.Who.Identifier) {
+				subscribers = append(subscribers, *agent.Who.Identifier)
+			}
+		}
 	default:
 		return otel.Error(span, fmt.Errorf("subscription manager does not support notifying for resource type: %s", coolfhir.ResourceType(resource)), "unsupported resource type")
 	}
@@ -3,6 +3,7 @@ package subscriptions
 import (
 	"context"
 	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/audit"
 	"github.com/SanteonNL/orca/orchestrator/lib/must"
 	"github.com/SanteonNL/orca/orchestrator/messaging"
 	"net/url"
@@ -80,4 +81,76 @@ func TestDerivingManager_Notify(t *testing.T) {
 		require.Equal(t, "http://example.com/fhir/CareTeam/10", *focus.Reference)
 		require.Equal(t, "CareTeam", *focus.Type)
 	})
+
+	t.Run("AuditEvent notifies custodian", func(t *testing.T) {
+		custodian := coolfhir.LogicalReference("Organization", coolfhir.URANamingSystem, "1")
+		auditEvent := &fhir.AuditEvent{
+			Id: to.Ptr("30"),
+			Entity: []fhir.AuditEventEntity{
+				{What: &fhir.Reference{Reference: to.Ptr("CarePlan/31")}},
+			},
+			Agent: []fhir.AuditEventAgent{
+				{Who: coolfhir.LogicalReference("Organization", coolfhir.URANamingSystem, "2"), Requestor: true},
+				{Who: custodian, Type: &fhir.CodeableConcept{Coding: []fhir.Coding{audit.CustodianParticipationType}}},
+			},
+		}
+
+		ctrl := gomock.NewController(t)
+		channelFactory := NewMockChannelFactory(ctrl)
+
+		var capturedNotification coolfhir.SubscriptionNotification
+		custodianChannel := NewMockChannel(ctrl)
+		custodianChannel.EXPECT().Notify(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, resource interface{}) error {
+			capturedNotification = resource.(coolfhir.SubscriptionNotification)
+			return nil
+		})
+		channelFactory.EXPECT().Create(gomock.Any(), *custodian.Identifier).Return(custodianChannel, nil)
+
+		manager, err := NewManager(baseURLFunc, tenants.Test(), channelFactory, messaging.NewMemoryBroker())
+		require.NoError(t, err)
+
+		err = manager.Notify(ctx, auditEvent)
+
+		require.NoError(t, err)
+		focus, _ := capturedNotification.GetFocus()
+		require.Equal(t, "http://example.com/fhir/CarePlan/31", *focus.Reference)
+		require.Equal(t, "CarePlan", *focus.Type)
+	})
+
+	t.Run("AuditEvent without CarePlan", func(t *testing.T) {
+		manager, err := NewManager(baseURLFunc, tenants.Test(), NewMockChannelFactory(gomock.NewController(t)), messaging.NewMemoryBroker())
+		require.NoError(t, err)
+
+		err = manager.Notify(ctx, &fhir.AuditEvent{Id: to.Ptr("30")})
+
+		require.EqualError(t, err, "AuditEvent/30 does not refer to a CarePlan")
+	})
+	t.Run("Communication notifies recipients", func(t *testing.T) {
+		recipient := coolfhir.LogicalReference("Organization", coolfhir.URANamingSystem, "1")
+		communication := &fhir.Communication{
+			Id:        to.Ptr("40"),
+			Sender:    coolfhir.LogicalReference("Organization", coolfhir.URANamingSystem, "2"),
+			Recipient: []fhir.Reference{*recipient},
+		}
+
+		ctrl := gomock.NewController(t)
+		channelFactory := NewMockChannelFactory(ctrl)
+
+		var capturedNotification coolfhir.SubscriptionNotification
+		recipientChannel := NewMockChannel(ctrl)
+		recipientChannel.EXPECT().Notify(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, resource interface{}) error {
+			capturedNotification = resource.(coolfhir.SubscriptionNotification)
+			return nil
+		})
+		channelFactory.EXPECT().Create(gomock.Any(), *recipient.Identifier).Return(recipientChannel, nil)
+
+		manager, err := NewManager(baseURLFunc, tenants.Test(), channelFactory, messaging.NewMemoryBroker())
+		require.NoError(t, err)
+
+		err = manager.Notify(ctx, communication)
+
+		require.NoError(t, err)
+		focus, _ := capturedNotification.GetFocus()
+		require.Equal(t, "http://example.com/fhir/Communication/40", *focus.Reference)
+	})
 }
@@ -1,22 +1,30 @@
 package careplanservice
 
 import (
+	"fmt"
 	"log/slog"
 	"net/mail"
 	"regexp"
+	"slices"
 	"strings"
 
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
 	"github.com/SanteonNL/orca/orchestrator/lib/validation"
 	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
 )
 
+// PatientValidator validates Patients against the configured rules.
+// The zero value requires an email address and a Dutch, Belgian or German mobile phone number.
 type PatientValidator struct {
+	Rules tenants.PatientValidationRules
 }
 
 func (v *PatientValidator) Validate(patient *fhir.Patient) []*validation.Error {
 	var errs []*validation.Error
 	hasEmail, hasPhone := false, false
 	hasValidPhoneNumber := false
+	firstPhoneIdx := -1
 
 	if patient == nil {
 		errs = append(errs, &validation.Error{
@@ -25,18 +33,30 @@ func (v *PatientValidator) Validate(patient *fhir.Patient) []*validation.Error {
 		return errs
 	}
 
-	for _, point := range patient.Telecom {
+	requiredTelecom := v.Rules.RequiredTelecomSystems()
+	emailRequired := slices.Contains(requiredTelecom, "email")
+	phoneRequired := slices.Contains(requiredTelecom, "phone")
+	phoneFormats := v.Rules.AllowedPhoneFormats()
+
+	for i, point := range patient.Telecom {
 		if point.System != nil {
 			switch *point.System {
 			case fhir.ContactPointSystemEmail:
+				if (point.Value == nil || *point.Value == "") && !emailRequired {
+					continue
+				}
 				if err := validateEmail(point.Value); err != nil {
+					err.Expression = fmt.Sprintf("Patient.telecom[%d].value", i)
 					errs = append(errs, err)
 				}
 				hasEmail = true
 			case fhir.ContactPointSystemPhone:
 				if point.Value != nil && *point.Value != "" {
 					hasPhone = true
-					if err := validatePhone(point.Value); err == nil {
+					if firstPhoneIdx == -1 {
+						firstPhoneIdx = i
+					}
+					if err := validatePhone(point.Value, phoneFormats); err == nil {
 						hasValidPhoneNumber = true
 					}
 				}
@@ -46,14 +66,27 @@ func (v *PatientValidator) Validate(patient *fhir.Patient) []*validation.Error {
 		}
 	}
 
-	if !hasEmail {
-		errs = append(errs, &validation.Error{Code: EmailRequired})
+	if emailRequired && !hasEmail {
+		errs = append(errs, &validation.Error{Code: EmailRequired, Expression: "Patient.telecom"})
 	}
-	if !hasPhone {
-		errs = append(errs, &validation.Error{Code: PhoneRequired})
+	if phoneRequired && !hasPhone {
+		errs = append(errs, &validation.Error{Code: PhoneRequired, Expression: "Patient.telecom"})
 	}
 	if hasPhone && !hasValidPhoneNumber {
-		errs = append(errs, &validation.Error{Code: InvalidPhone})
+		errs = append(errs, &validation.Error{
+			Code:        InvalidPhone,
+			Expression:  fmt.Sprintf("Patient.telecom[%d].value", firstPhoneIdx),
+			Diagnostics: fmt.Sprintf("phone number must be in one of the allowed formats: %s", strings.Join(phoneFormats, ", ")),
+		})
+	}
+	if v.Rules.RequireBSN {
+		bsn := coolfhir.FilterFirstIdentifier(&patient.Identifier, coolfhir.BSNNamingSystem)
+		if bsn == nil || bsn.Value == nil || *bsn.Value == "" {
+			errs = append(errs, &validation.Error{Code: BSNRequired, Expression: "Patient.identifier"})
+		}
+	}
+	if v.Rules.RequireBirthDate && (patient.BirthDate == nil || *patient.BirthDate == "") {
+		errs = append(errs, &validation.Error{Code: BirthDateRequired, Expression: "Patient.birthDate"})
 	}
 
 	if len(errs) > 0 {
@@ -76,36 +109,50 @@ func validateEmail(email *string) *validation.Error {
 	return nil
 }
 
-func validatePhone(phone *string) *validation.Error {
+var nonPhoneCharacters = regexp.MustCompile("[^0-9+]")
+
+// validatePhone checks whether the phone number is in one of the given formats (see tenants.PhoneFormatNLMobile etc.).
+func validatePhone(phone *string, formats []string) *validation.Error {
 	if phone == nil || *phone == "" {
 		return &validation.Error{Code: PhoneRequired}
 	}
 
-	normalised := regexp.MustCompile("[^0-9+]").ReplaceAllString(*phone, "")
+	normalised := nonPhoneCharacters.ReplaceAllString(*phone, "")
 
-	// Dutch mobile: 06xxxxxxxx (10 digits) or +316xxxxxxxx (12 digits)
-	if (len(normalised) == 10 && strings.HasPrefix(normalised, "06")) ||
-		(len(normalised) == 12 && strings.HasPrefix(normalised, "+316")) {
-		return nil
+	for _, format := range formats {
+		if phoneFormatMatchers[format] != nil && phoneFormatMatchers[format](normalised) {
+			return nil
+		}
 	}
+	return &validation.Error{Code: InvalidPhone}
+}
 
+var phoneFormatMatchers = map[string]func(normalised string) bool{
+	// Dutch mobile: 06xxxxxxxx (10 digits) or +316xxxxxxxx (12 digits)
+	tenants.PhoneFormatNLMobile: func(normalised string) bool {
+		return (len(normalised) == 10 && strings.HasPrefix(normalised, "06")) ||
+			(len(normalised) == 12 && strings.HasPrefix(normalised, "+316"))
+	},
 	// Belgian mobile: +324xxxxxxxx (12 digits)
-	if len(normalised) == 12 && strings.HasPrefix(normalised, "+324") {
-		return nil
-	}
-
+	tenants.PhoneFormatBEMobile: func(normalised string) bool {
+		return len(normalised) == 12 && strings.HasPrefix(normalised, "+324")
+	},
 	// German mobile: +4915x/+4916x/+4917x (13-14 digits)
-	if (len(normalised) == 13 || len(normalised) == 14) && (strings.HasPrefix(normalised, "+4915") || strings.HasPrefix(normalised, "+4916") || strings.HasPrefix(normalised, "+4917")) {
-		return nil
-	}
-
-	return &validation.Error{Code: InvalidPhone}
+	tenants.PhoneFormatDEMobile: func(normalised string) bool {
+		return (len(normalised) == 13 || len(normalised) == 14) && (strings.HasPrefix(normalised, "+4915") || strings.HasPrefix(normalised, "+4916") || strings.HasPrefix(normalised, "+4917"))
+	},
+	// Dutch (landline or mobile): 0xxxxxxxxx (10 digits) or +31xxxxxxxxx (12 digits)
+	tenants.PhoneFormatNL: regexp.MustCompile(`^(0[1-9][0-9]{8}|\+31[1-9][0-9]{8})$`).MatchString,
+	// International (E.164): + followed by country code and subscriber number, 8 to 15 digits in total
+	tenants.PhoneFormatInternational: regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`).MatchString,
 }
 
 const (
-	EmailRequired   = "E0001"
-	PhoneRequired   = "E0002"
-	InvalidEmail    = "E0003"
-	InvalidPhone    = "E0004"
-	PatientRequired = "E9999"
+	EmailRequired     = "E0001"
+	PhoneRequired     = "E0002"
+	InvalidEmail      = "E0003"
+	InvalidPhone      = "E0004"
+	BSNRequired       = "E0005"
+	BirthDateRequired = "E0006"
+	PatientRequired   = "E9999"
 )
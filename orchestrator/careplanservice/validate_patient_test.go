@@ -3,8 +3,11 @@ package careplanservice
 import (
 	"testing"
 
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
 	"github.com/SanteonNL/orca/orchestrator/lib/to"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
 )
 
@@ -490,3 +493,111 @@ func TestPatientValidator_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestPatientValidator_Validate_Rules(t *testing.T) {
+	emailSystem := fhir.ContactPointSystemEmail
+	phoneSystem := fhir.ContactPointSystemPhone
+	patientWithPhone := func(phone string) *fhir.Patient {
+		return &fhir.Patient{
+			Telecom: []fhir.ContactPoint{
+				{System: &emailSystem, Value: to.Ptr("test@example.com")},
+				{System: &phoneSystem, Value: to.Ptr(phone)},
+			},
+		}
+	}
+
+	tests := []struct {
+		name                string
+		rules               tenants.PatientValidationRules
+		patient             *fhir.Patient
+		expectedErr         []string
+		expectedExpressions []string
+	}{
+		{
+			name:                "no telecom required",
+			rules:               tenants.PatientValidationRules{RequiredTelecom: []string{tenants.PatientTelecomNone}},
+			patient:             &fhir.Patient{},
+			expectedErr:         nil,
+			expectedExpressions: nil,
+		},
+		{
+			name:    "only phone required",
+			rules:   tenants.PatientValidationRules{RequiredTelecom: []string{"phone"}},
+			patient: &fhir.Patient{Telecom: []fhir.ContactPoint{{System: &phoneSystem, Value: to.Ptr("0612345678")}}},
+		},
+		{
+			name:                "email not required, but invalid",
+			rules:               tenants.PatientValidationRules{RequiredTelecom: []string{"phone"}},
+			patient:             &fhir.Patient{Telecom: []fhir.ContactPoint{{System: &emailSystem, Value: to.Ptr("invalid")}, {System: &phoneSystem, Value: to.Ptr("0612345678")}}},
+			expectedErr:         []string{InvalidEmail},
+			expectedExpressions: []string{"Patient.telecom[0].value"},
+		},
+		{
+			name:                "required telecom missing",
+			rules:               tenants.PatientValidationRules{},
+			patient:             &fhir.Patient{},
+			expectedErr:         []string{EmailRequired, PhoneRequired},
+			expectedExpressions: []string{"Patient.telecom", "Patient.telecom"},
+		},
+		{
+			name:                "Dutch landline not allowed by default",
+			patient:             patientWithPhone("0301234567"),
+			expectedErr:         []string{InvalidPhone},
+			expectedExpressions: []string{"Patient.telecom[1].value"},
+		},
+		{
+			name:    "Dutch landline allowed",
+			rules:   tenants.PatientValidationRules{PhoneFormats: []string{tenants.PhoneFormatNL}},
+			patient: patientWithPhone("+31 30 123 4567"),
+		},
+		{
+			name:    "international number allowed",
+			rules:   tenants.PatientValidationRules{PhoneFormats: []string{tenants.PhoneFormatInternational}},
+			patient: patientWithPhone("+33 6 12 34 56 78"),
+		},
+		{
+			name:                "international number requires country code",
+			rules:               tenants.PatientValidationRules{PhoneFormats: []string{tenants.PhoneFormatInternational}},
+			patient:             patientWithPhone("0612345678"),
+			expectedErr:         []string{InvalidPhone},
+			expectedExpressions: []string{"Patient.telecom[1].value"},
+		},
+		{
+			name:                "Belgian mobile not allowed",
+			rules:               tenants.PatientValidationRules{PhoneFormats: []string{tenants.PhoneFormatNLMobile}},
+			patient:             patientWithPhone("+32485128355"),
+			expectedErr:         []string{InvalidPhone},
+			expectedExpressions: []string{"Patient.telecom[1].value"},
+		},
+		{
+			name:                "BSN and birth date required, but missing",
+			rules:               tenants.PatientValidationRules{RequireBSN: true, RequireBirthDate: true},
+			patient:             patientWithPhone("0612345678"),
+			expectedErr:         []string{BSNRequired, BirthDateRequired},
+			expectedExpressions: []string{"Patient.identifier", "Patient.birthDate"},
+		},
+		{
+			name:  "BSN and birth date required and present",
+			rules: tenants.PatientValidationRules{RequireBSN: true, RequireBirthDate: true},
+			patient: func() *fhir.Patient {
+				patient := patientWithPhone("0612345678")
+				patient.Identifier = []fhir.Identifier{{System: to.Ptr(coolfhir.BSNNamingSystem), Value: to.Ptr("111222333")}}
+				patient.BirthDate = to.Ptr("1980-01-15")
+				return patient
+			}(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator := &PatientValidator{Rules: tt.rules}
+			errs := validator.Validate(tt.patient)
+
+			require.Len(t, errs, len(tt.expectedErr))
+			for i, expected := range tt.expectedErr {
+				assert.Equal(t, expected, errs[i].Code)
+				assert.Equal(t, tt.expectedExpressions[i], errs[i].Expression)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package careplanservice
+
+import (
+	"encoding/json"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/validation"
+	"github.com/SanteonNL/orca/orchestrator/lib/validation/fhirprofile"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+// ProfileValidator validates resources against the FHIR profiles (StructureDefinitions) loaded from the configured FHIR packages.
+// The validation errors contain the issue type as code (e.g. required), the expression of the invalid element and diagnostics.
+type ProfileValidator[T any] struct {
+	validator *fhirprofile.Validator
+}
+
+func (v ProfileValidator[T]) Validate(resource T) []*validation.Error {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return []*validation.Error{{Code: fhir.IssueTypeStructure.Code(), Diagnostics: err.Error()}}
+	}
+	issues, err := v.validator.Validate(data)
+	if err != nil {
+		return []*validation.Error{{Code: fhir.IssueTypeStructure.Code(), Diagnostics: err.Error()}}
+	}
+	var errs []*validation.Error
+	for _, issue := range issues {
+		if issue.Severity != fhir.IssueSeverityError && issue.Severity != fhir.IssueSeverityFatal {
+			continue
+		}
+		errs = append(errs, &validation.Error{
+			Code:        issue.Code.Code(),
+			Expression:  issue.Expression,
+			Diagnostics: issue.Diagnostics,
+		})
+	}
+	return errs
+}
+
+// resourceValidator returns the validator for created or updated resources of type T:
+// the given validators, and the ProfileValidator if profile validation is configured. It returns nil if there are no validators.
+func resourceValidator[T any](s *Service, validators ...validation.Validator[T]) validation.Validator[T] {
+	if s.profileValidator != nil {
+		validators = append(validators, ProfileValidator[T]{validator: s.profileValidator})
+	}
+	switch len(validators) {
+	case 0:
+		return nil
+	case 1:
+		return validators[0]
+	default:
+		return validation.Validators[T](validators)
+	}
+}
@@ -0,0 +1,134 @@
+package careplanservice
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/cmd/profile"
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/must"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/SanteonNL/orca/orchestrator/lib/validation"
+	"github.com/SanteonNL/orca/orchestrator/lib/validation/fhirprofile"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+const testTaskProfileURL = "http://example.com/fhir/StructureDefinition/task-with-description"
+
+// testProfileValidator returns a validator that requires Tasks to have a description.
+func testProfileValidator(t *testing.T) *fhirprofile.Validator {
+	structureDefinition := must.MarshalJSON(map[string]any{
+		"resourceType": "StructureDefinition",
+		"url":          testTaskProfileURL,
+		"type":         "Task",
+		"snapshot": map[string]any{
+			"element": []any{
+				map[string]any{"id": "Task", "path": "Task"},
+				map[string]any{"id": "Task.description", "path": "Task.description", "min": 1, "max": "1"},
+			},
+		},
+	})
+	buf := new(bytes.Buffer)
+	gzipWriter := gzip.NewWriter(buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+	require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: "package/StructureDefinition-task.json", Mode: 0644, Size: int64(len(structureDefinition)), Typeflag: tar.TypeReg}))
+	_, err := tarWriter.Write(structureDefinition)
+	require.NoError(t, err)
+	require.NoError(t, tarWriter.Close())
+	require.NoError(t, gzipWriter.Close())
+	pkg, err := fhirprofile.ReadPackage(buf)
+	require.NoError(t, err)
+	validator, err := fhirprofile.New([]*fhirprofile.Package{pkg}, []string{testTaskProfileURL})
+	require.NoError(t, err)
+	return validator
+}
+
+func TestProfileValidator_Validate(t *testing.T) {
+	validator := ProfileValidator[*fhir.Task]{validator: testProfileValidator(t)}
+	t.Run("valid", func(t *testing.T) {
+		errs := validator.Validate(&fhir.Task{Description: to.Ptr("Telemonitoring")})
+
+		require.Empty(t, errs)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		errs := validator.Validate(&fhir.Task{})
+
+		require.Len(t, errs, 1)
+		require.Equal(t, "required", errs[0].Code)
+		require.Equal(t, "Task.description", errs[0].Expression)
+		require.Equal(t, "Task.description: minimum required = 1, but only found 0 (profile "+testTaskProfileURL+")", errs[0].Diagnostics)
+	})
+}
+
+func Test_resourceValidator(t *testing.T) {
+	t.Run("no validators", func(t *testing.T) {
+		require.Nil(t, resourceValidator[*fhir.Task](&Service{}))
+	})
+	t.Run("only given validator", func(t *testing.T) {
+		validator := resourceValidator[*fhir.Patient](&Service{}, &PatientValidator{})
+
+		require.IsType(t, &PatientValidator{}, validator)
+	})
+	t.Run("only profile validator", func(t *testing.T) {
+		validator := resourceValidator[*fhir.Task](&Service{profileValidator: testProfileValidator(t)})
+
+		require.IsType(t, ProfileValidator[*fhir.Task]{}, validator)
+	})
+	t.Run("given and profile validator", func(t *testing.T) {
+		validator := resourceValidator[*fhir.Task](&Service{profileValidator: testProfileValidator(t)}, &failureValidator{})
+
+		errs := validator.Validate(&fhir.Task{})
+
+		require.IsType(t, validation.Validators[*fhir.Task]{}, validator)
+		require.Len(t, errs, 3)
+	})
+}
+
+func Test_handleCreateTask_ProfileValidation(t *testing.T) {
+	tenant := tenants.Test().Sole()
+	service := &Service{
+		profile:          profile.Test(),
+		profileValidator: testProfileValidator(t),
+	}
+	task := fhir.Task{
+		Meta:      &fhir.Meta{Profile: []string{coolfhir.SCPTaskProfile}},
+		Intent:    "order",
+		Status:    fhir.TaskStatusRequested,
+		Requester: coolfhir.LogicalReference("Organization", coolfhir.URANamingSystem, "1"),
+		Owner:     coolfhir.LogicalReference("Organization", coolfhir.URANamingSystem, "2"),
+		For: &fhir.Reference{
+			Identifier: &fhir.Identifier{
+				System: to.Ptr(coolfhir.BSNNamingSystem),
+				Value:  to.Ptr("1333333337"),
+			},
+		},
+	}
+	taskJSON, _ := json.Marshal(task)
+	ctx := tenants.WithTenant(context.Background(), tenant)
+
+	_, err := service.handleCreateTask(ctx, FHIRHandlerRequest{
+		ResourcePath:  "Task",
+		ResourceData:  taskJSON,
+		HttpMethod:    http.MethodPost,
+		Principal:     auth.TestPrincipal1,
+		LocalIdentity: &auth.TestPrincipal1.Organization.Identifier[0],
+		Tenant:        tenant,
+	}, coolfhir.Transaction())
+
+	var operationOutcomeErr *fhirclient.OperationOutcomeError
+	require.ErrorAs(t, err, &operationOutcomeErr)
+	require.Equal(t, http.StatusBadRequest, operationOutcomeErr.HttpStatusCode)
+	require.Len(t, operationOutcomeErr.OperationOutcome.Issue, 1)
+	issue := operationOutcomeErr.OperationOutcome.Issue[0]
+	require.Equal(t, fhir.IssueTypeRequired, issue.Code)
+	require.Equal(t, []string{"Task.description"}, issue.Expression)
+}
@@ -11,7 +11,10 @@ import (
 	"github.com/SanteonNL/orca/orchestrator/careplancontributor"
 	"github.com/SanteonNL/orca/orchestrator/careplanservice"
 	"github.com/SanteonNL/orca/orchestrator/cmd/profile/nuts"
+	"github.com/SanteonNL/orca/orchestrator/cmd/profile/static"
 	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/csd"
 	"github.com/SanteonNL/orca/orchestrator/lib/otel"
 	"github.com/SanteonNL/orca/orchestrator/messaging"
 	"github.com/knadh/koanf/v2"
@@ -19,9 +22,23 @@ import (
 	"github.com/knadh/koanf/providers/env"
 )
 
+const (
+	NutsProfile   = "nuts"
+	StaticProfile = "static"
+)
+
 type Config struct {
+	// Profile selects the SCP profile, which determines authentication, Care Services Discovery and local identities: nuts (default) or static.
+	Profile string `koanf:"profile"`
 	// Nuts holds the configuration for communicating with the Nuts API.
 	Nuts nuts.Config `koanf:"nuts"`
+	// Static holds the configuration for the static profile, which runs the SCP-node without a Nuts node.
+	Static static.Config `koanf:"static"`
+	// CSD holds the configuration of directories that are consulted before the profile's Care Services Discovery directory.
+	CSD csd.Config `koanf:"csd"`
+	// IdentifierEquivalence holds groups of identifiers (<system>|<value>) that identify the same organization in different identifier systems,
+	// e.g. its URA and AGB code. Identifiers in the same group are considered equal when authorizing and looking up organizations.
+	IdentifierEquivalence map[string][]string `koanf:"identifierequivalence"`
 	// Public holds the configuration for the public interface.
 	Public InterfaceConfig `koanf:"public"`
 	// CarePlanContributor holds the configuration for the CarePlanContributor.
@@ -37,8 +54,30 @@ type Config struct {
 }
 
 func (c Config) Validate() error {
-	if err := c.Nuts.Validate(); err != nil {
-		return fmt.Errorf("invalid Nuts configuration: %w", err)
+	switch c.Profile {
+	case "", NutsProfile:
+		if err := c.Nuts.Validate(c.Tenants); err != nil {
+			return fmt.Errorf("invalid Nuts configuration: %w", err)
+		}
+	case StaticProfile:
+		if err := c.Static.Validate(c.Tenants); err != nil {
+			return fmt.Errorf("invalid static profile configuration: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported profile: %s", c.Profile)
+	}
+	if err := c.CSD.Validate(); err != nil {
+		return err
+	}
+	for name, identifiers := range c.IdentifierEquivalence {
+		if len(identifiers) < 2 {
+			return fmt.Errorf("identifier equivalence %s: at least 2 identifiers are required", name)
+		}
+		for _, identifier := range identifiers {
+			if parsed, err := coolfhir.TokenToIdentifier(identifier); err != nil || !coolfhir.IsLogicalIdentifier(parsed) {
+				return fmt.Errorf("identifier equivalence %s: invalid identifier (expected <system>|<value>): %s", name, identifier)
+			}
+		}
 	}
 	if err := c.Tenants.Validate(c.CarePlanService.Enabled); err != nil {
 		return fmt.Errorf("invalid tenant configuration: %w", err)
@@ -124,12 +163,14 @@ func splitWithEscaping(s, separator, escape string) []string {
 // DefaultConfig returns sensible, but not complete, default configuration values.
 func DefaultConfig() Config {
 	return Config{
+		Profile:    NutsProfile,
 		LogLevel:   slog.LevelInfo,
 		StrictMode: true,
 		Public: InterfaceConfig{
 			Address: ":8080",
 			URL:     "/",
 		},
+		CSD:                 csd.DefaultConfig(),
 		CarePlanContributor: careplancontributor.DefaultConfig(),
 		CarePlanService:     careplanservice.DefaultConfig(),
 		OpenTelemetry:       otel.DefaultConfig(),
@@ -30,6 +30,29 @@ func TestConfig_Validate(t *testing.T) {
 		err := c.Validate()
 		require.EqualError(t, err, "public base URL is not configured")
 	})
+	t.Run("unsupported profile", func(t *testing.T) {
+		c := Config{
+			Profile: "other",
+			Public:  InterfaceConfig{URL: "http://example.com"},
+		}
+		err := c.Validate()
+		require.EqualError(t, err, "unsupported profile: other")
+	})
+	t.Run("invalid identifier equivalence", func(t *testing.T) {
+		c := Config{
+			Nuts: nuts.Config{
+				DiscoveryService: "test",
+				API:              nuts.APIConfig{URL: "http://example.com"},
+				Public:           nuts.PublicConfig{URL: "http://example.com"},
+			},
+			IdentifierEquivalence: map[string][]string{
+				"hospital": {"http://fhir.nl/fhir/NamingSystem/ura|1", "1234"},
+			},
+			Public: InterfaceConfig{URL: "http://example.com"},
+		}
+		err := c.Validate()
+		require.EqualError(t, err, "identifier equivalence hospital: invalid identifier (expected <system>|<value>): 1234")
+	})
 }
 
 func TestLoadConfig(t *testing.T) {
@@ -42,6 +42,10 @@ var tracer = baseotel.Tracer("nuts")
 //	 "organization_ura": "4567",
 //	 "scope": "careplanservice"
 //	}
+//
+// If the access token was requested with a NutsEmployeeCredential, the user (employee) acting on behalf of the organization
+// is taken from the employee_identifier, employee_name and employee_role claims.
+// The identifier and role may be specified as <system>|<value>, otherwise the identifier is considered assigned by the organization.
 func (d DutchNutsProfile) Authenticator(fn http.HandlerFunc) http.HandlerFunc {
 	authConfig := middleware.Config{
 		TokenIntrospectionEndpoint: d.Config.API.Parse().JoinPath("internal/auth/v2/accesstoken/introspect").String(),
@@ -91,6 +95,7 @@ func (d DutchNutsProfile) Authenticator(fn http.HandlerFunc) http.HandlerFunc {
 				}
 				principal := auth.Principal{
 					Organization: *organization,
+					User:         claimsToUser(userInfo, *organization),
 				}
 				slog.DebugContext(
 					request.Context(),
@@ -168,3 +173,58 @@ func claimsToOrganization(claims map[string]interface{}) (*fhir.Organization, er
 		},
 	}, nil
 }
+
+func claimsToUser(claims map[string]interface{}, organization fhir.Organization) *auth.User {
+	employeeIdentifier, ok := claims["employee_identifier"].(string)
+	if !ok || employeeIdentifier == "" {
+		return nil
+	}
+	identifier, err := coolfhir.TokenToIdentifier(employeeIdentifier)
+	if err != nil || !coolfhir.IsLogicalIdentifier(identifier) {
+		identifier = &fhir.Identifier{
+			Value: to.Ptr(employeeIdentifier),
+			Assigner: &fhir.Reference{
+				Type:       to.Ptr("Organization"),
+				Identifier: &organization.Identifier[0],
+			},
+		}
+	}
+	result := auth.User{
+		Practitioner: fhir.Practitioner{
+			Identifier: []fhir.Identifier{*identifier},
+		},
+	}
+	if name, ok := claims["employee_name"].(string); ok && name != "" {
+		result.Practitioner.Name = []fhir.HumanName{{Text: to.Ptr(name)}}
+	}
+	var roles []string
+	switch role := claims["employee_role"].(type) {
+	case string:
+		roles = []string{role}
+	case []interface{}:
+		for _, curr := range role {
+			if roleString, ok := curr.(string); ok {
+				roles = append(roles, roleString)
+			}
+		}
+	}
+	for _, role := range roles {
+		if role == "" {
+			continue
+		}
+		if result.PractitionerRole == nil {
+			result.PractitionerRole = &fhir.PractitionerRole{
+				Organization: &fhir.Reference{
+					Type:       to.Ptr("Organization"),
+					Identifier: &organization.Identifier[0],
+				},
+			}
+		}
+		code := fhir.CodeableConcept{Text: to.Ptr(role)}
+		if coding, err := coolfhir.TokenToIdentifier(role); err == nil && coolfhir.IsLogicalIdentifier(coding) {
+			code = fhir.CodeableConcept{Coding: []fhir.Coding{{System: coding.System, Code: coding.Value}}}
+		}
+		result.PractitionerRole.Code = append(result.PractitionerRole.Code, code)
+	}
+	return &result
+}
@@ -13,8 +13,10 @@ import (
 	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
 	"github.com/SanteonNL/orca/orchestrator/lib/auth"
 	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
 )
 
 func TestDutchNutsProfile_Authenticator(t *testing.T) {
@@ -48,6 +50,28 @@ func TestDutchNutsProfile_Authenticator(t *testing.T) {
 		require.Equal(t, *capturedPrincipal.Organization.Identifier[0].Value, "1")
 		require.Len(t, capturedPrincipal.Organization.Address, 1)
 		require.Equal(t, *capturedPrincipal.Organization.Address[0].City, "CareTown")
+		require.Nil(t, capturedPrincipal.User)
+	})
+	t.Run("authenticated with employee", func(t *testing.T) {
+		var capturedPrincipal auth.Principal
+		handler := profile.Authenticator(func(writer http.ResponseWriter, request *http.Request) {
+			capturedPrincipal, _ = auth.PrincipalFromContext(request.Context())
+			writer.WriteHeader(http.StatusOK)
+		})
+		httpRequest := httptest.NewRequest("GET", "/", nil)
+		httpRequest.Header.Add("Authorization", "Bearer valid-employee")
+
+		handler(httptest.NewRecorder(), httpRequest)
+
+		require.NotNil(t, capturedPrincipal.User)
+		practitioner := capturedPrincipal.User.Practitioner
+		require.Nil(t, practitioner.Identifier[0].System)
+		require.Equal(t, "jdoe", *practitioner.Identifier[0].Value)
+		require.Equal(t, "1", *practitioner.Identifier[0].Assigner.Identifier.Value)
+		require.Equal(t, "John Doe", *practitioner.Name[0].Text)
+		require.Len(t, capturedPrincipal.User.Roles(), 2)
+		require.Equal(t, "Nurse", *capturedPrincipal.User.Roles()[0].Text)
+		require.True(t, capturedPrincipal.User.HasRole(fhir.Coding{System: to.Ptr("http://snomed.info/sct"), Code: to.Ptr("224535009")}))
 	})
 	t.Run("invalid token", func(t *testing.T) {
 		var capturedError error
@@ -119,6 +143,22 @@ func setupAuthorizationServer(t *testing.T) *url.URL {
 			})
 			writer.WriteHeader(http.StatusOK)
 			_, _ = writer.Write(responseData)
+		case "token=valid-employee":
+			writer.Header().Set("Content-Type", "application/json")
+			responseData, _ := json.Marshal(map[string]interface{}{
+				"active":              true,
+				"organization_ura":    "1",
+				"organization_name":   "Hospital",
+				"organization_city":   "CareTown",
+				"employee_identifier": "jdoe",
+				"employee_name":       "John Doe",
+				"employee_role":       []string{"Nurse", "http://snomed.info/sct|224535009"},
+				"scope":               "careplanservice",
+				"iss":                 "http://localhost:8080/oauth2/test",
+				"client_id":           "http://localhost:8080/oauth2/other",
+			})
+			writer.WriteHeader(http.StatusOK)
+			_, _ = writer.Write(responseData)
 		default:
 			writer.WriteHeader(http.StatusUnauthorized)
 			return
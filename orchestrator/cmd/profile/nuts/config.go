@@ -2,7 +2,10 @@ package nuts
 
 import (
 	"errors"
+	"fmt"
 	"net/url"
+
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
 )
 
 type Config struct {
@@ -10,6 +13,15 @@ type Config struct {
 	Public           PublicConfig        `koanf:"public"`
 	DiscoveryService string              `koanf:"discoveryservice"`
 	AzureKeyVault    AzureKeyVaultConfig `koanf:"azurekv"`
+	// IdentifierMapping maps identifier systems other than URA to the credential attribute they're searched on in the Discovery Service.
+	IdentifierMapping map[string]IdentifierMapping `koanf:"identifiermapping"`
+}
+
+type IdentifierMapping struct {
+	// System is the FHIR identifier system, e.g. http://fhir.nl/fhir/NamingSystem/agb-z
+	System string `koanf:"system"`
+	// Attribute is the credential attribute that contains the identifier value, e.g. credentialSubject.organization.agb
+	Attribute string `koanf:"attribute"`
 }
 
 type AzureKeyVaultConfig struct {
@@ -18,7 +30,7 @@ type AzureKeyVaultConfig struct {
 	ClientCertName []string `koanf:"clientcertname"`
 }
 
-func (c Config) Validate() error {
+func (c Config) Validate(tenants tenants.Config) error {
 	_, err := url.Parse(c.API.URL)
 	if err != nil || c.API.URL == "" {
 		return errors.New("invalid Nuts API URL")
@@ -39,6 +51,16 @@ func (c Config) Validate() error {
 			return errors.New("invalid/empty Azure Key Vault URL")
 		}
 	}
+	for name, mapping := range c.IdentifierMapping {
+		if mapping.System == "" || mapping.Attribute == "" {
+			return fmt.Errorf("identifier mapping %s: system and attribute are required", name)
+		}
+	}
+	for id, props := range tenants {
+		if props.Nuts.Subject == "" {
+			return fmt.Errorf("tenant %s: missing Nuts subject", id)
+		}
+	}
 	return nil
 }
 
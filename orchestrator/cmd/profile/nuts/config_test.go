@@ -1,8 +1,10 @@
 package nuts
 
 import (
-	"github.com/stretchr/testify/require"
 	"testing"
+
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/stretchr/testify/require"
 )
 
 func TestConfig_Validate(t *testing.T) {
@@ -15,7 +17,7 @@ func TestConfig_Validate(t *testing.T) {
 				URL: "http://nutsnode:8080",
 			},
 		}
-		err := c.Validate()
+		err := c.Validate(nil)
 		require.EqualError(t, err, "invalid/empty Discovery Service ID")
 	})
 	t.Run("public URL not set", func(t *testing.T) {
@@ -25,7 +27,7 @@ func TestConfig_Validate(t *testing.T) {
 			},
 			DiscoveryService: "discovery",
 		}
-		err := c.Validate()
+		err := c.Validate(nil)
 		require.EqualError(t, err, "invalid/empty Nuts public URL")
 	})
 	t.Run("API URL not set", func(t *testing.T) {
@@ -35,7 +37,7 @@ func TestConfig_Validate(t *testing.T) {
 			},
 			DiscoveryService: "discovery",
 		}
-		err := c.Validate()
+		err := c.Validate(nil)
 		require.EqualError(t, err, "invalid Nuts API URL")
 	})
 	t.Run("ok", func(t *testing.T) {
@@ -46,7 +48,34 @@ func TestConfig_Validate(t *testing.T) {
 			Public:           PublicConfig{URL: "http://nutsnode:8080"},
 			DiscoveryService: "discovery",
 		}
-		err := c.Validate()
+		err := c.Validate(nil)
 		require.NoError(t, err)
 	})
+	t.Run("tenant without Nuts subject", func(t *testing.T) {
+		c := Config{
+			API: APIConfig{
+				URL: "http://nutsnode:8081",
+			},
+			Public:           PublicConfig{URL: "http://nutsnode:8080"},
+			DiscoveryService: "discovery",
+		}
+		err := c.Validate(tenants.Config{
+			"sub": tenants.Properties{ID: "sub"},
+		})
+		require.EqualError(t, err, "tenant sub: missing Nuts subject")
+	})
+	t.Run("identifier mapping without attribute", func(t *testing.T) {
+		c := Config{
+			API: APIConfig{
+				URL: "http://nutsnode:8081",
+			},
+			Public:           PublicConfig{URL: "http://nutsnode:8080"},
+			DiscoveryService: "discovery",
+			IdentifierMapping: map[string]IdentifierMapping{
+				"agb": {System: "http://fhir.nl/fhir/NamingSystem/agb-z"},
+			},
+		}
+		err := c.Validate(nil)
+		require.EqualError(t, err, "identifier mapping agb: system and attribute are required")
+	})
 }
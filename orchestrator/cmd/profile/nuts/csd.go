@@ -29,10 +29,13 @@ func (d DutchNutsProfile) CsdDirectory() csd.Directory {
 // It looks up fhir.Endpoint instances of owning entities (e.g. care organizations) in the Nuts Discovery Service.
 type CsdDirectory struct {
 	// APIClient is a REST API client to invoke the Nuts node's private Discovery Service API.
-	APIClient  discovery.ClientWithResponsesInterface
-	ServiceID  string
-	entryCache map[string]cacheEntry
-	cacheMux   sync.RWMutex
+	APIClient discovery.ClientWithResponsesInterface
+	ServiceID string
+	// IdentifierMapping maps identifier systems (other than URA) to the credential attribute they're searched on,
+	// e.g. http://fhir.nl/fhir/NamingSystem/agb-z to credentialSubject.organization.agb.
+	IdentifierMapping map[string]string
+	entryCache        map[string]cacheEntry
+	cacheMux          sync.RWMutex
 }
 
 type cacheEntry struct {
@@ -41,7 +44,7 @@ type cacheEntry struct {
 }
 
 // LookupEndpoint searches for endpoints of the given owner, with the given endpointName in the given Discovery Service.
-// It queries the Nuts Discovery Service, translating the owner's identifier to a credential attribute (see IdentifierCredentialMapping).
+// It queries the Nuts Discovery Service, translating the owner's identifier to a credential attribute (see IdentifierMapping).
 // The endpoint is retrieved from the Nuts Discovery Service registration's registrationParameters, identified by endpointName.
 // If the owner is nil, it will search for all endpoints in the Discovery Service.
 func (n *CsdDirectory) LookupEndpoint(ctx context.Context, owner *fhir.Identifier, endpointName string) ([]fhir.Endpoint, error) {
@@ -99,8 +102,10 @@ func (n *CsdDirectory) find(ctx context.Context, owner fhir.Identifier) (*discov
 	if owner.Value == nil || owner.System == nil {
 		return nil, errors.New("identifier must contain both System and Value")
 	}
-	if *owner.System != coolfhir.URANamingSystem {
-		return nil, errors.New("identifier.system must be " + coolfhir.URANamingSystem)
+	// Identifiers of systems that can't be searched on are translated to an equivalent identifier (e.g. AGB to URA)
+	query, err := n.queryFor(owner)
+	if err != nil {
+		return nil, err
 	}
 
 	// Check if the entry is cached
@@ -118,14 +123,50 @@ func (n *CsdDirectory) find(ctx context.Context, owner fhir.Identifier) (*discov
 		n.cacheMux.Unlock()
 	}
 
+	var searchResponse *discovery.SearchPresentationsResponse
+	if *query.System == coolfhir.URANamingSystem {
+		searchResponse, err = n.searchURA(ctx, *query.Value)
+	} else {
+		searchResponse, err = n.doSearch(ctx, discovery.SearchPresentationsParams{
+			Query: &map[string]interface{}{
+				n.IdentifierMapping[*query.System]: *query.Value,
+			},
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Cache the entry
+	n.cacheMux.Lock()
+	n.entryCache[cacheKey] = cacheEntry{
+		response: *searchResponse,
+		created:  time.Now(),
+	}
+	n.cacheMux.Unlock()
+
+	return searchResponse, nil
+}
+
+// queryFor returns the identifier to search the Discovery Service on: the given identifier if its system is URA or mapped to a credential attribute,
+// otherwise the first equivalent identifier that is.
+func (n *CsdDirectory) queryFor(owner fhir.Identifier) (*fhir.Identifier, error) {
+	for _, candidate := range append([]fhir.Identifier{owner}, coolfhir.EquivalentIdentifiers(owner)...) {
+		if _, isMapped := n.IdentifierMapping[*candidate.System]; isMapped || *candidate.System == coolfhir.URANamingSystem {
+			return &candidate, nil
+		}
+	}
+	return nil, fmt.Errorf("identifier.system must be %s or mapped to a credential attribute: %s", coolfhir.URANamingSystem, *owner.System)
+}
+
+func (n *CsdDirectory) searchURA(ctx context.Context, ura string) (*discovery.SearchPresentationsResponse, error) {
 	// 2 credentials are supported:
 	// - NutsUraCredential, which contains credentialSubject.organization.ura
 	// - X509Credential, which contains credentialSubject.san.otherName (which is a string that contains the URA)
 	//   Example otherName: 2.16.528.1.1007.99.2110-1-1234-S-86446-00.000-5678 (86446 is the URA)
-	var searchResponse *discovery.SearchPresentationsResponse
 	searchResponse, err := n.doSearch(ctx, discovery.SearchPresentationsParams{
 		Query: &map[string]interface{}{
-			"credentialSubject.san.otherName": "*-S-" + *owner.Value + "-00.000*",
+			"credentialSubject.san.otherName": "*-S-" + ura + "-00.000*",
 		},
 	})
 	if err != nil {
@@ -134,7 +175,7 @@ func (n *CsdDirectory) find(ctx context.Context, owner fhir.Identifier) (*discov
 	// Filter UziServerCertificateCredential to check actually match the URA, removing entries that don't match. Important since we do a wildcard match.
 	j := 0
 	for i := 0; i < len(*searchResponse.JSON200); i++ {
-		if *owner.Value != (*searchResponse.JSON200)[i].Fields["organization_ura"] {
+		if ura != (*searchResponse.JSON200)[i].Fields["organization_ura"] {
 			continue
 		}
 		(*searchResponse.JSON200)[j] = (*searchResponse.JSON200)[i]
@@ -145,22 +186,13 @@ func (n *CsdDirectory) find(ctx context.Context, owner fhir.Identifier) (*discov
 	if len(*searchResponse.JSON200) == 0 {
 		searchResponse, err = n.doSearch(ctx, discovery.SearchPresentationsParams{
 			Query: &map[string]interface{}{
-				"credentialSubject.organization.ura": *owner.Value,
+				"credentialSubject.organization.ura": ura,
 			},
 		})
 		if err != nil {
 			return nil, err
 		}
 	}
-
-	// Cache the entry
-	n.cacheMux.Lock()
-	n.entryCache[cacheKey] = cacheEntry{
-		response: *searchResponse,
-		created:  time.Now(),
-	}
-	n.cacheMux.Unlock()
-
 	return searchResponse, nil
 }
 
@@ -3,6 +3,7 @@ package nuts
 import (
 	"context"
 	"encoding/json"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
 	"github.com/SanteonNL/orca/orchestrator/lib/csd"
 	"github.com/SanteonNL/orca/orchestrator/lib/to"
 	"github.com/nuts-foundation/go-nuts-client/nuts/discovery"
@@ -29,6 +30,14 @@ func TestCsdDirectory_Lookup(t *testing.T) {
 		System: to.Ptr("http://fhir.nl/fhir/NamingSystem/ura"),
 		Value:  to.Ptr("456"),
 	}
+	ownerAGBCodingSystem := &fhir.Identifier{
+		System: to.Ptr("http://fhir.nl/fhir/NamingSystem/agb-z"),
+		Value:  to.Ptr("01234567"),
+	}
+	ownerKvKCodingSystem := &fhir.Identifier{
+		System: to.Ptr("http://fhir.nl/fhir/NamingSystem/kvk"),
+		Value:  to.Ptr("87654321"),
+	}
 	const serviceID = "svc-test"
 	const urlEndpointID = "url-endpoint"
 	const mapEndpointID = "map-endpoint"
@@ -39,7 +48,8 @@ func TestCsdDirectory_Lookup(t *testing.T) {
 	discoveryServerRouter.HandleFunc("/internal/discovery/v1/"+serviceID, func(w http.ResponseWriter, r *http.Request) {
 		numInvocations.Add(1)
 		var response []discovery.SearchResult
-		if r.URL.Query().Get("credentialSubject.organization.ura") == *ownerURACodingSystem.Value {
+		if r.URL.Query().Get("credentialSubject.organization.ura") == *ownerURACodingSystem.Value ||
+			r.URL.Query().Get("credentialSubject.organization.agb") == *ownerAGBCodingSystem.Value {
 			response = []discovery.SearchResult{
 				{
 					RegistrationParameters: map[string]interface{}{
@@ -77,8 +87,11 @@ func TestCsdDirectory_Lookup(t *testing.T) {
 	discoveryServer := httptest.NewServer(discoveryServerRouter)
 	apiClient, _ := discovery.NewClientWithResponses(discoveryServer.URL)
 	directory := CsdDirectory{
-		APIClient:  apiClient,
-		ServiceID:  serviceID,
+		APIClient: apiClient,
+		ServiceID: serviceID,
+		IdentifierMapping: map[string]string{
+			*ownerAGBCodingSystem.System: "credentialSubject.organization.agb",
+		},
 		entryCache: make(map[string]cacheEntry),
 		cacheMux:   sync.RWMutex{},
 	}
@@ -129,7 +142,23 @@ func TestCsdDirectory_Lookup(t *testing.T) {
 		})
 		t.Run("FHIR CodingSystem not mapped to Verifiable Credential property", func(t *testing.T) {
 			_, err := directory.LookupEndpoint(ctx, ownerUnsupportedCodingSystem, urlEndpointID)
-			require.EqualError(t, err, "identifier.system must be http://fhir.nl/fhir/NamingSystem/ura")
+			require.EqualError(t, err, "identifier.system must be http://fhir.nl/fhir/NamingSystem/ura or mapped to a credential attribute: custom")
+		})
+		t.Run("identifier system mapped to Verifiable Credential property", func(t *testing.T) {
+			result, err := directory.LookupEndpoint(ctx, ownerAGBCodingSystem, urlEndpointID)
+			require.NoError(t, err)
+			require.Len(t, result, 1)
+			require.Equal(t, endpoint, result[0].Address)
+		})
+		t.Run("identifier system equivalent to URA", func(t *testing.T) {
+			require.NoError(t, coolfhir.SetIdentifierEquivalences([][]string{{coolfhir.IdentifierToToken(*ownerKvKCodingSystem), coolfhir.IdentifierToToken(*ownerURACodingSystem)}}))
+			t.Cleanup(func() {
+				_ = coolfhir.SetIdentifierEquivalences(nil)
+			})
+			result, err := directory.LookupEndpoint(ctx, ownerKvKCodingSystem, urlEndpointID)
+			require.NoError(t, err)
+			require.Len(t, result, 1)
+			require.Equal(t, endpoint, result[0].Address)
 		})
 		t.Run("non-OK status", func(t *testing.T) {
 			result, err := directory.LookupEndpoint(ctx, ownerURACodingSystem, mapEndpointID)
@@ -90,14 +90,19 @@ func New(config Config, tenants tenants.Config) (*DutchNutsProfile, error) {
 		return nil, err
 	}
 	apiClient, _ := discovery.NewClientWithResponses(config.API.URL, discovery.WithHTTPClient(nutsAPIHTTPClient))
+	identifierMapping := make(map[string]string)
+	for _, mapping := range config.IdentifierMapping {
+		identifierMapping[mapping.System] = mapping.Attribute
+	}
 	return &DutchNutsProfile{
 		Config:    config,
 		vcrClient: vcrClient,
 		csd: &CsdDirectory{
-			APIClient:  apiClient,
-			ServiceID:  config.DiscoveryService,
-			entryCache: make(map[string]cacheEntry),
-			cacheMux:   sync.RWMutex{},
+			APIClient:         apiClient,
+			ServiceID:         config.DiscoveryService,
+			IdentifierMapping: identifierMapping,
+			entryCache:        make(map[string]cacheEntry),
+			cacheMux:          sync.RWMutex{},
 		},
 		cachedIdentities:      map[string][]fhir.Organization{},
 		identitiesRefreshedAt: map[string]time.Time{},
@@ -137,7 +142,7 @@ func (d DutchNutsProfile) HttpClient(ctx context.Context, serverIdentity fhir.Id
 		if authzServerURL == "" {
 			return nil, fmt.Errorf("no OAuth Authorization Server URL found in CapabilityStatement, expected at CapabilityStatement.rest.security.service.extension[%s]", nutsAuthorizationServerExtensionURL)
 		}
-	case coolfhir.URANamingSystem:
+	default:
 		slog.DebugContext(ctx, "Using CSD lookup for OAuth2 token acquisition")
 		// Care Plan Contributor: need to look up authz server URL in CSD
 		authServerURLEndpoints, err := d.csd.LookupEndpoint(ctx, &serverIdentity, authzServerURLEndpointName)
@@ -148,8 +153,6 @@ func (d DutchNutsProfile) HttpClient(ctx context.Context, serverIdentity fhir.Id
 			return nil, fmt.Errorf("no authz server URL found for owner %s", coolfhir.ToString(serverIdentity))
 		}
 		authzServerURL = authServerURLEndpoints[0].Address
-	default:
-		return nil, fmt.Errorf("unsupported server identity system: %s", *serverIdentity.System)
 	}
 
 	slog.DebugContext(ctx, "Using OAuth2 Authorization Server", slog.String(logging.FieldUrl, authzServerURL))
@@ -170,6 +173,7 @@ func (d DutchNutsProfile) HttpClient(ctx context.Context, serverIdentity fhir.Id
 
 	tracedTransport := otelhttp.NewTransport(
 		underlyingTransport,
+		otel.WithURLRedaction(),
 		otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
 			return fmt.Sprintf("(nuts-secured) %s %s", strings.ToLower(r.Method), r.URL.Path)
 		}),
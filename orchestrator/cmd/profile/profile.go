@@ -28,3 +28,20 @@ type Provider interface {
 	Identities(ctx context.Context) ([]fhir.Organization, error)
 	CapabilityStatement(ctx context.Context, cp *fhir.CapabilityStatement) error
 }
+
+// WithCsdDirectory returns a Provider that uses the given directory for Care Services Discovery, instead of the provider's own directory.
+func WithCsdDirectory(provider Provider, directory csd.Directory) Provider {
+	return csdDirectoryProvider{
+		Provider:  provider,
+		directory: directory,
+	}
+}
+
+type csdDirectoryProvider struct {
+	Provider
+	directory csd.Directory
+}
+
+func (c csdDirectoryProvider) CsdDirectory() csd.Directory {
+	return c.directory
+}
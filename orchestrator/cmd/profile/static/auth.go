@@ -0,0 +1,247 @@
+package static
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/debug"
+	"github.com/SanteonNL/orca/orchestrator/lib/logging"
+	"github.com/SanteonNL/orca/orchestrator/lib/otel"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	baseotel "go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = baseotel.Tracer("static")
+
+var oidSubjectAltName = asn1.ObjectIdentifier{2, 5, 29, 17}
+
+// uziOtherNameUraRegex matches the URA in the otherName of UZI server certificates,
+// e.g. 2.16.528.1.1007.99.2110-1-1234-S-86446-00.000-5678 (86446 is the URA).
+var uziOtherNameUraRegex = regexp.MustCompile("^[0-9.]+-\\d+-\\d+-S-(\\d+)-00\\.000-\\d+$")
+
+// Authenticator authenticates the caller using a JWT bearer token (if provided in the Authorization header and JWT authentication is configured),
+// or otherwise using the TLS client certificate.
+//   - JWTs must be signed by a trusted issuer and contain the organization_ura (and optionally organization_name) claims.
+//   - Client certificates must chain to the trust store. The URA is taken from the UZI otherName,
+//     or if not present, from the subject's serialNumber. The organization name is taken from the subject's O.
+func (p Profile) Authenticator(fn http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		ctx, span := tracer.Start(
+			request.Context(),
+			debug.GetFullCallerName(),
+			trace.WithSpanKind(trace.SpanKindServer),
+		)
+		defer span.End()
+		request = request.WithContext(ctx)
+
+		if principal, err := auth.PrincipalFromContext(ctx); err == nil {
+			span.SetAttributes(attribute.String(otel.AuthNMethod, "static(pre-authenticated)"))
+			fn(writer, request.WithContext(auth.WithPrincipal(ctx, principal)))
+			return
+		}
+
+		var organization *fhir.Organization
+		var err error
+		authHeader := request.Header.Get("Authorization")
+		switch {
+		case p.Config.JWT.Enabled() && strings.HasPrefix(authHeader, "Bearer "):
+			span.SetAttributes(attribute.String(otel.AuthNMethod, otel.AuthNMethodJWT))
+			organization, err = p.authenticateJWT(ctx, strings.TrimPrefix(authHeader, "Bearer "))
+		case p.Config.MTLS.Enabled():
+			span.SetAttributes(attribute.String(otel.AuthNMethod, otel.AuthNMethodMTLS))
+			organization, err = p.authenticateClientCertificate(request)
+		default:
+			err = errors.New("no bearer token provided")
+		}
+		if err != nil {
+			span.SetAttributes(attribute.String(otel.AuthNOutcome, otel.AuthNOutcomeFailed))
+			otel.Error(span, err)
+			slog.ErrorContext(ctx, "Authentication failed", slog.String(logging.FieldError, err.Error()))
+			http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		principal := auth.Principal{
+			Organization: *organization,
+		}
+		slog.DebugContext(
+			ctx,
+			"Authenticated user",
+			slog.Any("principal", principal),
+			slog.String("route", request.URL.Path),
+		)
+		span.SetAttributes(attribute.String(otel.AuthNOutcome, otel.AuthNOutcomeOK))
+		fn(writer, request.WithContext(auth.WithPrincipal(ctx, principal)))
+	}
+}
+
+func (p Profile) authenticateJWT(ctx context.Context, token string) (*fhir.Organization, error) {
+	unverified, err := jwt.ParseString(token, jwt.WithVerify(false), jwt.WithValidate(false))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT: %w", err)
+	}
+	var jwksURL string
+	for _, issuer := range p.Config.JWT.TrustedIssuers {
+		if issuer.IssuerURL == unverified.Issuer() {
+			jwksURL = issuer.JWKSURL
+			break
+		}
+	}
+	if jwksURL == "" {
+		return nil, fmt.Errorf("JWT issuer is not trusted: %s", unverified.Issuer())
+	}
+	keySet, err := p.jwks.Get(ctx, jwksURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get JWKS of issuer %s: %w", unverified.Issuer(), err)
+	}
+	verified, err := jwt.ParseString(token,
+		jwt.WithKeySet(keySet, jws.WithInferAlgorithmFromKey(true)),
+		jwt.WithValidate(true),
+		jwt.WithIssuer(unverified.Issuer()),
+		jwt.WithAudience(p.Config.JWT.Audience),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT: %w", err)
+	}
+	claims, err := verified.AsMap(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ura, _ := claims["organization_ura"].(string)
+	if ura == "" {
+		return nil, errors.New("missing organization_ura claim in JWT")
+	}
+	name, _ := claims["organization_name"].(string)
+	return organization(ura, name), nil
+}
+
+func (p Profile) authenticateClientCertificate(request *http.Request) (*fhir.Organization, error) {
+	var chain []*x509.Certificate
+	if p.Config.MTLS.ClientCertHeader != "" {
+		headerValue := request.Header.Get(p.Config.MTLS.ClientCertHeader)
+		if headerValue == "" {
+			return nil, errors.New("no client certificate provided")
+		}
+		data, err := url.QueryUnescape(headerValue)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate header: %w", err)
+		}
+		rest := []byte(data)
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			certificate, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("invalid client certificate: %w", err)
+			}
+			chain = append(chain, certificate)
+		}
+	} else if request.TLS != nil {
+		chain = request.TLS.PeerCertificates
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("no client certificate provided")
+	}
+	intermediates := x509.NewCertPool()
+	for _, certificate := range chain[1:] {
+		intermediates.AddCert(certificate)
+	}
+	if _, err := chain[0].Verify(x509.VerifyOptions{
+		Roots:         p.trustStore,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		return nil, fmt.Errorf("untrusted client certificate: %w", err)
+	}
+	ura := uraFromCertificate(chain[0])
+	if ura == "" {
+		return nil, errors.New("client certificate doesn't contain a URA")
+	}
+	var name string
+	if len(chain[0].Subject.Organization) > 0 {
+		name = chain[0].Subject.Organization[0]
+	}
+	return organization(ura, name), nil
+}
+
+// uraFromCertificate returns the URA from the certificate's UZI otherName, or if not present, the subject's serialNumber.
+func uraFromCertificate(certificate *x509.Certificate) string {
+	for _, otherName := range otherNames(certificate) {
+		if match := uziOtherNameUraRegex.FindStringSubmatch(otherName); len(match) > 1 {
+			return match[1]
+		}
+	}
+	return certificate.Subject.SerialNumber
+}
+
+// otherNames returns the (string) otherName values of the certificate's subjectAltName extension.
+func otherNames(certificate *x509.Certificate) []string {
+	var results []string
+	for _, extension := range certificate.Extensions {
+		if !extension.Id.Equal(oidSubjectAltName) {
+			continue
+		}
+		var generalNames asn1.RawValue
+		if _, err := asn1.Unmarshal(extension.Value, &generalNames); err != nil {
+			return nil
+		}
+		rest := generalNames.Bytes
+		for len(rest) > 0 {
+			var generalName asn1.RawValue
+			var err error
+			if rest, err = asn1.Unmarshal(rest, &generalName); err != nil {
+				return results
+			}
+			// otherName [0] IMPLICIT SEQUENCE { type-id OBJECT IDENTIFIER, value [0] EXPLICIT ANY }
+			if generalName.Class != asn1.ClassContextSpecific || generalName.Tag != 0 {
+				continue
+			}
+			var otherName struct {
+				TypeID asn1.ObjectIdentifier
+				Value  asn1.RawValue `asn1:"explicit,tag:0"`
+			}
+			if _, err := asn1.UnmarshalWithParams(generalName.FullBytes, &otherName, "tag:0"); err != nil {
+				continue
+			}
+			var value string
+			if _, err := asn1.Unmarshal(otherName.Value.Bytes, &value); err != nil {
+				continue
+			}
+			results = append(results, value)
+		}
+	}
+	return results
+}
+
+func organization(ura string, name string) *fhir.Organization {
+	result := &fhir.Organization{
+		Identifier: []fhir.Identifier{
+			{
+				System: to.Ptr(coolfhir.URANamingSystem),
+				Value:  to.Ptr(ura),
+			},
+		},
+	}
+	if name != "" {
+		result.Name = to.Ptr(name)
+	}
+	return result
+}
@@ -0,0 +1,278 @@
+package static
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/auth"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfile_Authenticator(t *testing.T) {
+	t.Run("mTLS", func(t *testing.T) {
+		caCert, caKey := createCA(t)
+		trustStore := x509.NewCertPool()
+		trustStore.AddCert(caCert)
+		profile := Profile{
+			Config: Config{
+				MTLS: MTLSConfig{
+					TrustStoreFile:   "truststore.pem",
+					ClientCertHeader: "X-SSL-Client-Cert",
+				},
+			},
+			trustStore: trustStore,
+		}
+		t.Run("UZI server certificate", func(t *testing.T) {
+			clientCert := createClientCertificate(t, caCert, caKey, pkix.Name{Organization: []string{"Hospital"}}, "2.16.528.1.1007.99.2110-1-1234-S-86446-00.000-5678")
+			httpRequest := httptest.NewRequest("GET", "/", nil)
+			httpRequest.Header.Set("X-SSL-Client-Cert", url.QueryEscape(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: clientCert.Raw}))))
+
+			principal, httpResponse := authenticate(profile, httpRequest)
+
+			require.Equal(t, http.StatusOK, httpResponse.Code)
+			require.Equal(t, coolfhir.URANamingSystem, *principal.Organization.Identifier[0].System)
+			require.Equal(t, "86446", *principal.Organization.Identifier[0].Value)
+			require.Equal(t, "Hospital", *principal.Organization.Name)
+		})
+		t.Run("URA in subject serialNumber", func(t *testing.T) {
+			clientCert := createClientCertificate(t, caCert, caKey, pkix.Name{SerialNumber: "1234"}, "")
+			httpRequest := httptest.NewRequest("GET", "/", nil)
+			httpRequest.Header.Set("X-SSL-Client-Cert", url.QueryEscape(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: clientCert.Raw}))))
+
+			principal, httpResponse := authenticate(profile, httpRequest)
+
+			require.Equal(t, http.StatusOK, httpResponse.Code)
+			require.Equal(t, "1234", *principal.Organization.Identifier[0].Value)
+			require.Nil(t, principal.Organization.Name)
+		})
+		t.Run("certificate from TLS connection", func(t *testing.T) {
+			profile := profile
+			profile.Config.MTLS.ClientCertHeader = ""
+			clientCert := createClientCertificate(t, caCert, caKey, pkix.Name{SerialNumber: "1234"}, "")
+			httpRequest := httptest.NewRequest("GET", "/", nil)
+			httpRequest.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{clientCert}}
+
+			principal, httpResponse := authenticate(profile, httpRequest)
+
+			require.Equal(t, http.StatusOK, httpResponse.Code)
+			require.Equal(t, "1234", *principal.Organization.Identifier[0].Value)
+		})
+		t.Run("untrusted certificate", func(t *testing.T) {
+			otherCACert, otherCAKey := createCA(t)
+			clientCert := createClientCertificate(t, otherCACert, otherCAKey, pkix.Name{SerialNumber: "1234"}, "")
+			httpRequest := httptest.NewRequest("GET", "/", nil)
+			httpRequest.Header.Set("X-SSL-Client-Cert", url.QueryEscape(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: clientCert.Raw}))))
+
+			_, httpResponse := authenticate(profile, httpRequest)
+
+			require.Equal(t, http.StatusUnauthorized, httpResponse.Code)
+		})
+		t.Run("certificate without URA", func(t *testing.T) {
+			clientCert := createClientCertificate(t, caCert, caKey, pkix.Name{Organization: []string{"Hospital"}}, "")
+			httpRequest := httptest.NewRequest("GET", "/", nil)
+			httpRequest.Header.Set("X-SSL-Client-Cert", url.QueryEscape(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: clientCert.Raw}))))
+
+			_, httpResponse := authenticate(profile, httpRequest)
+
+			require.Equal(t, http.StatusUnauthorized, httpResponse.Code)
+		})
+		t.Run("no certificate", func(t *testing.T) {
+			_, httpResponse := authenticate(profile, httptest.NewRequest("GET", "/", nil))
+
+			require.Equal(t, http.StatusUnauthorized, httpResponse.Code)
+		})
+	})
+	t.Run("JWT", func(t *testing.T) {
+		signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		publicKey, err := jwk.FromRaw(signingKey.Public())
+		require.NoError(t, err)
+		_ = publicKey.Set(jwk.KeyIDKey, "key-1")
+		keySet := jwk.NewSet()
+		require.NoError(t, keySet.AddKey(publicKey))
+		jwksServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			writer.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(writer).Encode(keySet)
+		}))
+		t.Cleanup(jwksServer.Close)
+		config := Config{
+			JWT: JWTConfig{
+				TrustedIssuers: map[string]TrustedIssuer{
+					"test": {
+						IssuerURL: "https://issuer.example.com",
+						JWKSURL:   jwksServer.URL,
+					},
+				},
+				Audience: "https://orca.example.com",
+			},
+		}
+		profile := Profile{Config: config, jwks: jwk.NewCache(t.Context())}
+		require.NoError(t, profile.jwks.Register(jwksServer.URL))
+		createToken := func(t *testing.T, issuer string, audience string, claims map[string]any) string {
+			token := jwt.New()
+			_ = token.Set(jwt.IssuerKey, issuer)
+			_ = token.Set(jwt.AudienceKey, audience)
+			_ = token.Set(jwt.ExpirationKey, time.Now().Add(time.Minute))
+			for key, value := range claims {
+				_ = token.Set(key, value)
+			}
+			privateKey, err := jwk.FromRaw(signingKey)
+			require.NoError(t, err)
+			_ = privateKey.Set(jwk.KeyIDKey, "key-1")
+			signed, err := jwt.Sign(token, jwt.WithKey(jwa.ES256, privateKey))
+			require.NoError(t, err)
+			return string(signed)
+		}
+
+		t.Run("ok", func(t *testing.T) {
+			httpRequest := httptest.NewRequest("GET", "/", nil)
+			httpRequest.Header.Set("Authorization", "Bearer "+createToken(t, "https://issuer.example.com", "https://orca.example.com", map[string]any{
+				"organization_ura":  "1234",
+				"organization_name": "Hospital",
+			}))
+
+			principal, httpResponse := authenticate(profile, httpRequest)
+
+			require.Equal(t, http.StatusOK, httpResponse.Code)
+			require.Equal(t, "1234", *principal.Organization.Identifier[0].Value)
+			require.Equal(t, "Hospital", *principal.Organization.Name)
+		})
+		t.Run("untrusted issuer", func(t *testing.T) {
+			httpRequest := httptest.NewRequest("GET", "/", nil)
+			httpRequest.Header.Set("Authorization", "Bearer "+createToken(t, "https://other.example.com", "https://orca.example.com", map[string]any{
+				"organization_ura": "1234",
+			}))
+
+			_, httpResponse := authenticate(profile, httpRequest)
+
+			require.Equal(t, http.StatusUnauthorized, httpResponse.Code)
+		})
+		t.Run("wrong audience", func(t *testing.T) {
+			httpRequest := httptest.NewRequest("GET", "/", nil)
+			httpRequest.Header.Set("Authorization", "Bearer "+createToken(t, "https://issuer.example.com", "https://other.example.com", map[string]any{
+				"organization_ura": "1234",
+			}))
+
+			_, httpResponse := authenticate(profile, httpRequest)
+
+			require.Equal(t, http.StatusUnauthorized, httpResponse.Code)
+		})
+		t.Run("missing URA claim", func(t *testing.T) {
+			httpRequest := httptest.NewRequest("GET", "/", nil)
+			httpRequest.Header.Set("Authorization", "Bearer "+createToken(t, "https://issuer.example.com", "https://orca.example.com", nil))
+
+			_, httpResponse := authenticate(profile, httpRequest)
+
+			require.Equal(t, http.StatusUnauthorized, httpResponse.Code)
+		})
+		t.Run("invalid signature", func(t *testing.T) {
+			token := createToken(t, "https://issuer.example.com", "https://orca.example.com", map[string]any{
+				"organization_ura": "1234",
+			})
+			httpRequest := httptest.NewRequest("GET", "/", nil)
+			httpRequest.Header.Set("Authorization", "Bearer "+token[:len(token)-4]+"AAAA")
+
+			_, httpResponse := authenticate(profile, httpRequest)
+
+			require.Equal(t, http.StatusUnauthorized, httpResponse.Code)
+		})
+	})
+	t.Run("pre-authenticated", func(t *testing.T) {
+		httpRequest := httptest.NewRequest("GET", "/", nil)
+		httpRequest = httpRequest.WithContext(auth.WithPrincipal(httpRequest.Context(), *auth.TestPrincipal1))
+
+		principal, httpResponse := authenticate(Profile{}, httpRequest)
+
+		require.Equal(t, http.StatusOK, httpResponse.Code)
+		require.Equal(t, *auth.TestPrincipal1, principal)
+	})
+}
+
+func Test_otherNames(t *testing.T) {
+	caCert, caKey := createCA(t)
+	clientCert := createClientCertificate(t, caCert, caKey, pkix.Name{}, "2.16.528.1.1007.99.2110-1-1234-S-86446-00.000-5678")
+
+	assert.Equal(t, []string{"2.16.528.1.1007.99.2110-1-1234-S-86446-00.000-5678"}, otherNames(clientCert))
+	assert.Empty(t, otherNames(caCert))
+}
+
+func authenticate(profile Profile, httpRequest *http.Request) (auth.Principal, *httptest.ResponseRecorder) {
+	var capturedPrincipal auth.Principal
+	handler := profile.Authenticator(func(writer http.ResponseWriter, request *http.Request) {
+		capturedPrincipal, _ = auth.PrincipalFromContext(request.Context())
+		writer.WriteHeader(http.StatusOK)
+	})
+	httpResponse := httptest.NewRecorder()
+	handler(httpResponse, httpRequest)
+	return capturedPrincipal, httpResponse
+}
+
+func createCA(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	data, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	certificate, err := x509.ParseCertificate(data)
+	require.NoError(t, err)
+	return certificate, key
+}
+
+func createClientCertificate(t *testing.T, caCert *x509.Certificate, caKey *ecdsa.PrivateKey, subject pkix.Name, otherName string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if otherName != "" {
+		value, err := asn1.Marshal(otherName)
+		require.NoError(t, err)
+		generalName, err := asn1.MarshalWithParams(struct {
+			TypeID asn1.ObjectIdentifier
+			Value  asn1.RawValue
+		}{
+			TypeID: asn1.ObjectIdentifier{2, 5, 5, 5},
+			Value:  asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: value},
+		}, "tag:0")
+		require.NoError(t, err)
+		generalNames, err := asn1.Marshal(asn1.RawValue{Tag: asn1.TagSequence, IsCompound: true, Bytes: generalName})
+		require.NoError(t, err)
+		template.ExtraExtensions = []pkix.Extension{{Id: oidSubjectAltName, Value: generalNames}}
+	}
+	data, err := x509.CreateCertificate(rand.Reader, template, caCert, key.Public(), caKey)
+	require.NoError(t, err)
+	certificate, err := x509.ParseCertificate(data)
+	require.NoError(t, err)
+	return certificate
+}
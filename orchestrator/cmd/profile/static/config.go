@@ -0,0 +1,103 @@
+package static
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+)
+
+type Config struct {
+	// CSDFile is the path to the YAML file or FHIR Bundle (JSON) that contains the organizations and their endpoints.
+	CSDFile string `koanf:"csdfile"`
+	// MTLS configures authentication of incoming requests using TLS client certificates.
+	MTLS MTLSConfig `koanf:"mtls"`
+	// JWT configures authentication of incoming requests using JWT bearer tokens.
+	JWT JWTConfig `koanf:"jwt"`
+	// Client configures authentication of outgoing requests.
+	Client ClientConfig `koanf:"client"`
+}
+
+type MTLSConfig struct {
+	// TrustStoreFile is the path to a PEM file containing the CA certificates client certificates must chain to.
+	TrustStoreFile string `koanf:"truststore"`
+	// ClientCertHeader is the HTTP header that contains the URL-encoded PEM client certificate (chain),
+	// set by the TLS-terminating reverse proxy (e.g. $ssl_client_escaped_cert for nginx).
+	// If not set, the client certificate of the TLS connection is used.
+	ClientCertHeader string `koanf:"clientcertheader"`
+}
+
+func (c MTLSConfig) Enabled() bool {
+	return c.TrustStoreFile != ""
+}
+
+type JWTConfig struct {
+	// TrustedIssuers is a map of friendly names to issuers of which JWTs are accepted.
+	TrustedIssuers map[string]TrustedIssuer `koanf:"trustedissuers"`
+	// Audience is the expected aud claim of JWTs.
+	Audience string `koanf:"audience"`
+}
+
+type TrustedIssuer struct {
+	// IssuerURL is the expected iss claim of JWTs.
+	IssuerURL string `koanf:"issuerurl"`
+	// JWKSURL is the URL of the JSON Web Key Set with the issuer's signing keys.
+	JWKSURL string `koanf:"jwksurl"`
+}
+
+func (c JWTConfig) Enabled() bool {
+	return len(c.TrustedIssuers) > 0
+}
+
+type ClientConfig struct {
+	// Certificate is the TLS client certificate presented to remote SCP-nodes.
+	Certificate coolfhir.ClientCertificateConfig `koanf:"clientcert"`
+	// OAuth2 configures acquiring access tokens for remote SCP-nodes using the OAuth2 client credentials grant,
+	// with a JWT assertion signed using the configured key.
+	OAuth2 OAuth2ClientConfig `koanf:"oauth2"`
+}
+
+type OAuth2ClientConfig struct {
+	TokenEndpoint string                    `koanf:"tokenendpoint"`
+	ClientID      string                    `koanf:"clientid"`
+	SigningKey    coolfhir.SigningKeyConfig `koanf:"signingkey"`
+	// Scope is the requested scope, defaults to the Care Plan Service scope.
+	Scope string `koanf:"scope"`
+}
+
+func (c Config) Validate(tenants tenants.Config) error {
+	if c.CSDFile == "" {
+		return errors.New("invalid/empty CSD file")
+	}
+	if !c.MTLS.Enabled() && !c.JWT.Enabled() {
+		return errors.New("either mTLS or JWT authentication must be configured")
+	}
+	if c.JWT.Enabled() && c.JWT.Audience == "" {
+		return errors.New("invalid/empty JWT audience")
+	}
+	for name, issuer := range c.JWT.TrustedIssuers {
+		if issuer.IssuerURL == "" || issuer.JWKSURL == "" {
+			return fmt.Errorf("trusted issuer %s: issuer URL and JWKS URL are required", name)
+		}
+	}
+	if c.Client.Certificate.CertFile != "" || c.Client.Certificate.AzureKeyVault.URL != "" {
+		if err := c.Client.Certificate.Validate(); err != nil {
+			return fmt.Errorf("invalid client certificate: %w", err)
+		}
+	}
+	if c.Client.OAuth2.TokenEndpoint != "" {
+		if c.Client.OAuth2.ClientID == "" {
+			return errors.New("invalid/empty OAuth2 client ID")
+		}
+		if err := c.Client.OAuth2.SigningKey.Validate(); err != nil {
+			return fmt.Errorf("invalid OAuth2 signing key: %w", err)
+		}
+	}
+	for id, props := range tenants {
+		if _, err := props.Static.Organization(); err != nil {
+			return fmt.Errorf("tenant %s: invalid static identity: %w", id, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package static
+
+import (
+	"testing"
+
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	validTenants := tenants.Config{
+		"test": tenants.Properties{
+			ID: "test",
+			Static: tenants.StaticProperties{
+				Identifiers: []string{coolfhir.URANamingSystem + "|1"},
+			},
+		},
+	}
+	t.Run("ok", func(t *testing.T) {
+		c := Config{
+			CSDFile: "directory.yaml",
+			MTLS:    MTLSConfig{TrustStoreFile: "truststore.pem"},
+		}
+		require.NoError(t, c.Validate(validTenants))
+	})
+	t.Run("CSD file not set", func(t *testing.T) {
+		c := Config{
+			MTLS: MTLSConfig{TrustStoreFile: "truststore.pem"},
+		}
+		require.EqualError(t, c.Validate(validTenants), "invalid/empty CSD file")
+	})
+	t.Run("no authentication configured", func(t *testing.T) {
+		c := Config{
+			CSDFile: "directory.yaml",
+		}
+		require.EqualError(t, c.Validate(validTenants), "either mTLS or JWT authentication must be configured")
+	})
+	t.Run("JWT audience not set", func(t *testing.T) {
+		c := Config{
+			CSDFile: "directory.yaml",
+			JWT: JWTConfig{
+				TrustedIssuers: map[string]TrustedIssuer{
+					"test": {IssuerURL: "https://issuer.example.com", JWKSURL: "https://issuer.example.com/jwks"},
+				},
+			},
+		}
+		require.EqualError(t, c.Validate(validTenants), "invalid/empty JWT audience")
+	})
+	t.Run("trusted issuer without JWKS URL", func(t *testing.T) {
+		c := Config{
+			CSDFile: "directory.yaml",
+			JWT: JWTConfig{
+				TrustedIssuers: map[string]TrustedIssuer{
+					"test": {IssuerURL: "https://issuer.example.com"},
+				},
+				Audience: "https://orca.example.com",
+			},
+		}
+		require.EqualError(t, c.Validate(validTenants), "trusted issuer test: issuer URL and JWKS URL are required")
+	})
+	t.Run("OAuth2 client without signing key", func(t *testing.T) {
+		c := Config{
+			CSDFile: "directory.yaml",
+			MTLS:    MTLSConfig{TrustStoreFile: "truststore.pem"},
+			Client: ClientConfig{
+				OAuth2: OAuth2ClientConfig{
+					TokenEndpoint: "https://example.com/token",
+					ClientID:      "orca",
+				},
+			},
+		}
+		require.ErrorContains(t, c.Validate(validTenants), "invalid OAuth2 signing key")
+	})
+	t.Run("tenant without identifiers", func(t *testing.T) {
+		c := Config{
+			CSDFile: "directory.yaml",
+			MTLS:    MTLSConfig{TrustStoreFile: "truststore.pem"},
+		}
+		err := c.Validate(tenants.Config{
+			"test": tenants.Properties{ID: "test"},
+		})
+		require.EqualError(t, err, "tenant test: invalid static identity: no identifiers configured")
+	})
+}
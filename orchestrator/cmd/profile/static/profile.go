@@ -0,0 +1,177 @@
+package static
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/SanteonNL/orca/orchestrator/careplancontributor"
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/globals"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/csd"
+	"github.com/SanteonNL/orca/orchestrator/lib/otel"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/oauth2"
+)
+
+var certificatesRestfulSecurityServiceCoding = fhir.Coding{
+	System: to.Ptr("http://hl7.org/fhir/ValueSet/restful-security-service"),
+	Code:   to.Ptr("Certificates"),
+}
+
+var oauthRestfulSecurityServiceCoding = fhir.Coding{
+	System: to.Ptr("http://hl7.org/fhir/ValueSet/restful-security-service"),
+	Code:   to.Ptr("OAuth"),
+}
+
+// Profile is the Profile for running the SCP-node without a Nuts node, for partners outside the Nuts network and test environments.
+// - Authentication: TLS client certificates issued by a trusted CA, and/or JWT bearer tokens issued by trusted issuers
+// - Care Services Discovery: static directory loaded from a YAML file or FHIR Bundle
+// - Identities: configured per tenant
+type Profile struct {
+	Config     Config
+	csd        csd.Directory
+	trustStore *x509.CertPool
+	jwks       *jwk.Cache
+	// transport is the transport for outgoing requests, which presents the client certificate (if configured).
+	transport   http.RoundTripper
+	tokenSource oauth2.TokenSource
+}
+
+func New(config Config) (*Profile, error) {
+	directory, err := csd.LoadStaticDirectory(config.CSDFile)
+	if err != nil {
+		return nil, err
+	}
+	result := &Profile{
+		Config: config,
+		csd:    directory,
+	}
+	if config.MTLS.Enabled() {
+		data, err := os.ReadFile(config.MTLS.TrustStoreFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read trust store: %w", err)
+		}
+		result.trustStore = x509.NewCertPool()
+		if !result.trustStore.AppendCertsFromPEM(data) {
+			return nil, errors.New("no CA certificates found in trust store")
+		}
+	}
+	if config.JWT.Enabled() {
+		result.jwks = jwk.NewCache(context.Background())
+		for _, issuer := range config.JWT.TrustedIssuers {
+			if err := result.jwks.Register(issuer.JWKSURL); err != nil {
+				return nil, fmt.Errorf("failed to register JWKS URL (issuer=%s): %w", issuer.IssuerURL, err)
+			}
+		}
+	}
+	if config.Client.Certificate.CertFile != "" || config.Client.Certificate.AzureKeyVault.URL != "" {
+		result.transport, err = coolfhir.NewMutualTLSTransport(config.Client.Certificate)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = globals.DefaultTLSConfig
+		result.transport = transport
+	}
+	if config.Client.OAuth2.TokenEndpoint != "" {
+		scope := config.Client.OAuth2.Scope
+		if scope == "" {
+			scope = careplancontributor.CarePlanServiceOAuth2Scope
+		}
+		result.tokenSource, err = coolfhir.NewOAuth2ClientCredentialsTokenSource(coolfhir.AuthConfig{
+			Type:          coolfhir.OAuth2ClientCredentials,
+			TokenEndpoint: config.Client.OAuth2.TokenEndpoint,
+			ClientID:      config.Client.OAuth2.ClientID,
+			SigningKey:    config.Client.OAuth2.SigningKey,
+			OAuth2Scopes:  scope,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to create OAuth2 token source: %w", err)
+		}
+	}
+	return result, nil
+}
+
+func (p Profile) CsdDirectory() csd.Directory {
+	return p.csd
+}
+
+// HttpClient returns an HTTP client that presents the configured client certificate and/or OAuth2 access token.
+func (p Profile) HttpClient(ctx context.Context, serverIdentity fhir.Identifier) (*http.Client, error) {
+	if serverIdentity.System == nil || serverIdentity.Value == nil {
+		return nil, fmt.Errorf("server identity must have system and value")
+	}
+	if _, err := tenants.FromContext(ctx); err != nil {
+		return nil, err
+	}
+	transport := p.transport
+	if p.tokenSource != nil {
+		transport = &oauth2.Transport{
+			Source: p.tokenSource,
+			Base:   transport,
+		}
+	}
+	return &http.Client{
+		Transport: otelhttp.NewTransport(
+			transport,
+			otel.WithURLRedaction(),
+			otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+				return fmt.Sprintf("(static-secured) %s %s", strings.ToLower(r.Method), r.URL.Path)
+			}),
+			otelhttp.WithSpanOptions(
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					attribute.String("oauth2.resource_server.identity", coolfhir.ToString(serverIdentity)),
+				),
+			),
+		),
+	}, nil
+}
+
+// Identities returns the identity of the tenant, as configured in the tenant's static properties.
+func (p Profile) Identities(ctx context.Context) ([]fhir.Organization, error) {
+	tenant, err := tenants.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	organization, err := tenant.Static.Organization()
+	if err != nil {
+		return nil, fmt.Errorf("invalid static identity of tenant %s: %w", tenant.ID, err)
+	}
+	return []fhir.Organization{*organization}, nil
+}
+
+func (p Profile) CapabilityStatement(ctx context.Context, cp *fhir.CapabilityStatement) error {
+	if _, err := tenants.FromContext(ctx); err != nil {
+		return err
+	}
+	for i, rest := range cp.Rest {
+		if rest.Security == nil {
+			rest.Security = &fhir.CapabilityStatementRestSecurity{}
+		}
+		if p.Config.MTLS.Enabled() {
+			rest.Security.Service = append(rest.Security.Service, fhir.CodeableConcept{
+				Coding: []fhir.Coding{certificatesRestfulSecurityServiceCoding},
+			})
+		}
+		if p.Config.JWT.Enabled() {
+			rest.Security.Service = append(rest.Security.Service, fhir.CodeableConcept{
+				Coding: []fhir.Coding{oauthRestfulSecurityServiceCoding},
+			})
+		}
+		cp.Rest[i] = rest
+	}
+	return nil
+}
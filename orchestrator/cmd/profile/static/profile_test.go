@@ -0,0 +1,162 @@
+package static
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/SanteonNL/orca/orchestrator/careplancontributor"
+	"github.com/SanteonNL/orca/orchestrator/cmd/profile"
+	"github.com/SanteonNL/orca/orchestrator/cmd/tenants"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+var _ profile.Provider = &Profile{}
+
+func TestNew(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		caCert, _ := createCA(t)
+		trustStoreFile := path.Join(t.TempDir(), "truststore.pem")
+		require.NoError(t, os.WriteFile(trustStoreFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCert.Raw}), 0644))
+
+		result, err := New(Config{
+			CSDFile: "testdata/directory.yaml",
+			MTLS:    MTLSConfig{TrustStoreFile: trustStoreFile},
+		})
+
+		require.NoError(t, err)
+		endpoints, err := result.CsdDirectory().LookupEndpoint(context.Background(), &fhir.Identifier{
+			System: to.Ptr(coolfhir.URANamingSystem),
+			Value:  to.Ptr("2"),
+		}, profile.FHIRBaseURLEndpointName)
+		require.NoError(t, err)
+		require.Len(t, endpoints, 1)
+		require.Equal(t, "https://clinic.example.com/fhir", endpoints[0].Address)
+	})
+	t.Run("CSD file doesn't exist", func(t *testing.T) {
+		_, err := New(Config{CSDFile: "testdata/other.yaml"})
+
+		require.ErrorContains(t, err, "failed to read CSD file")
+	})
+	t.Run("trust store doesn't contain certificates", func(t *testing.T) {
+		trustStoreFile := path.Join(t.TempDir(), "truststore.pem")
+		require.NoError(t, os.WriteFile(trustStoreFile, []byte("not a certificate"), 0644))
+
+		_, err := New(Config{
+			CSDFile: "testdata/directory.yaml",
+			MTLS:    MTLSConfig{TrustStoreFile: trustStoreFile},
+		})
+
+		require.EqualError(t, err, "no CA certificates found in trust store")
+	})
+}
+
+func TestProfile_HttpClient(t *testing.T) {
+	ctx := tenants.WithTenant(context.Background(), tenants.Test().Sole())
+	serverIdentity := fhir.Identifier{System: to.Ptr(coolfhir.URANamingSystem), Value: to.Ptr("2")}
+	t.Run("OAuth2 access token", func(t *testing.T) {
+		signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		keyData, err := x509.MarshalPKCS8PrivateKey(signingKey)
+		require.NoError(t, err)
+		keyFile := path.Join(t.TempDir(), "key.pem")
+		require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyData}), 0600))
+		var capturedScope string
+		tokenServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			_ = request.ParseForm()
+			capturedScope = request.PostForm.Get("scope")
+			writer.Header().Set("Content-Type", "application/json")
+			_, _ = writer.Write([]byte(`{"access_token":"token","token_type":"Bearer","expires_in":300}`))
+		}))
+		t.Cleanup(tokenServer.Close)
+		var capturedAuthHeader string
+		resourceServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			capturedAuthHeader = request.Header.Get("Authorization")
+			writer.WriteHeader(http.StatusOK)
+		}))
+		t.Cleanup(resourceServer.Close)
+		result, err := New(Config{
+			CSDFile: "testdata/directory.yaml",
+			Client: ClientConfig{
+				OAuth2: OAuth2ClientConfig{
+					TokenEndpoint: tokenServer.URL,
+					ClientID:      "orca",
+					SigningKey:    coolfhir.SigningKeyConfig{PEMFile: keyFile},
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		httpClient, err := result.HttpClient(ctx, serverIdentity)
+		require.NoError(t, err)
+		httpResponse, err := httpClient.Get(resourceServer.URL)
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, httpResponse.StatusCode)
+		require.Equal(t, "Bearer token", capturedAuthHeader)
+		require.Equal(t, careplancontributor.CarePlanServiceOAuth2Scope, capturedScope)
+	})
+	t.Run("no tenant", func(t *testing.T) {
+		result, err := New(Config{CSDFile: "testdata/directory.yaml"})
+		require.NoError(t, err)
+
+		_, err = result.HttpClient(context.Background(), serverIdentity)
+
+		require.ErrorIs(t, err, tenants.ErrNoTenant)
+	})
+	t.Run("invalid server identity", func(t *testing.T) {
+		result, err := New(Config{CSDFile: "testdata/directory.yaml"})
+		require.NoError(t, err)
+
+		_, err = result.HttpClient(ctx, fhir.Identifier{})
+
+		require.EqualError(t, err, "server identity must have system and value")
+	})
+}
+
+func TestProfile_Identities(t *testing.T) {
+	tenant := tenants.Test(func(properties *tenants.Properties) {
+		properties.Static = tenants.StaticProperties{
+			Identifiers: []string{coolfhir.URANamingSystem + "|1"},
+			Name:        "Hospital",
+		}
+	}).Sole()
+	ctx := tenants.WithTenant(context.Background(), tenant)
+
+	identities, err := Profile{}.Identities(ctx)
+
+	require.NoError(t, err)
+	require.Len(t, identities, 1)
+	require.Equal(t, "Hospital", *identities[0].Name)
+	require.Equal(t, coolfhir.URANamingSystem, *identities[0].Identifier[0].System)
+	require.Equal(t, "1", *identities[0].Identifier[0].Value)
+}
+
+func TestProfile_CapabilityStatement(t *testing.T) {
+	ctx := tenants.WithTenant(context.Background(), tenants.Test().Sole())
+	p := Profile{
+		Config: Config{
+			MTLS: MTLSConfig{TrustStoreFile: "truststore.pem"},
+			JWT:  JWTConfig{TrustedIssuers: map[string]TrustedIssuer{"test": {}}},
+		},
+	}
+	cp := &fhir.CapabilityStatement{Rest: []fhir.CapabilityStatementRest{{}}}
+
+	err := p.CapabilityStatement(ctx, cp)
+
+	require.NoError(t, err)
+	require.Len(t, cp.Rest[0].Security.Service, 2)
+	require.Equal(t, "Certificates", *cp.Rest[0].Security.Service[0].Coding[0].Code)
+	require.Equal(t, "OAuth", *cp.Rest[0].Security.Service[1].Coding[0].Code)
+}
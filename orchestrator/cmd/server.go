@@ -14,12 +14,17 @@ import (
 
 	"github.com/SanteonNL/orca/orchestrator/careplancontributor"
 	"github.com/SanteonNL/orca/orchestrator/careplancontributor/applaunch/session"
+	"github.com/SanteonNL/orca/orchestrator/careplancontributor/ehr"
 	"github.com/SanteonNL/orca/orchestrator/careplanservice"
 	"github.com/SanteonNL/orca/orchestrator/careplanservice/subscriptions"
+	"github.com/SanteonNL/orca/orchestrator/cmd/profile"
 	"github.com/SanteonNL/orca/orchestrator/cmd/profile/nuts"
+	"github.com/SanteonNL/orca/orchestrator/cmd/profile/static"
 	"github.com/SanteonNL/orca/orchestrator/events"
 	"github.com/SanteonNL/orca/orchestrator/globals"
 	"github.com/SanteonNL/orca/orchestrator/healthcheck"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/csd"
 	"github.com/SanteonNL/orca/orchestrator/lib/logging"
 	"github.com/SanteonNL/orca/orchestrator/lib/otel"
 	"github.com/SanteonNL/orca/orchestrator/messaging"
@@ -55,9 +60,17 @@ func Start(ctx context.Context, config Config) error {
 	}()
 
 	globals.StrictMode = config.StrictMode
+	coolfhir.StrictMode = config.StrictMode
 	if !globals.StrictMode {
 		slog.Warn("Strict mode is disabled, do not use in production")
 	}
+	var identifierEquivalences [][]string
+	for _, identifiers := range config.IdentifierEquivalence {
+		identifierEquivalences = append(identifierEquivalences, identifiers)
+	}
+	if err := coolfhir.SetIdentifierEquivalences(identifierEquivalences); err != nil {
+		return fmt.Errorf("invalid identifier equivalence configuration: %w", err)
+	}
 
 	// Set up dependencies
 	httpHandler := http.NewServeMux()
@@ -83,6 +96,9 @@ func Start(ctx context.Context, config Config) error {
 	if config.CarePlanService.Enabled {
 		messagingEntities = append(messagingEntities, subscriptions.SendNotificationQueue)
 	}
+	if config.CarePlanContributor.Enabled && careplancontributor.NotifierEnabled(config.CarePlanContributor, config.Tenants) {
+		messagingEntities = append(messagingEntities, ehr.TaskAcceptedEvent{}.Entity(), ehr.TaskReviewRequestedEvent{}.Entity(), ehr.TaskTransitionedEvent{}.Entity())
+	}
 	messageBroker, err := messaging.New(config.Messaging, messagingEntities)
 	if err != nil {
 		return fmt.Errorf("message broker initialization: %w", err)
@@ -93,7 +109,7 @@ func Start(ctx context.Context, config Config) error {
 	var services []Service
 	services = append(services, healthcheck.New())
 
-	activeProfile, err := nuts.New(config.Nuts, config.Tenants)
+	activeProfile, err := newProfile(config)
 	if err != nil {
 		return fmt.Errorf("failed to create profile: %w", err)
 	}
@@ -170,3 +186,24 @@ func Start(ctx context.Context, config Config) error {
 type Service interface {
 	RegisterHandlers(mux *http.ServeMux)
 }
+
+// newProfile creates the SCP profile selected in the configuration.
+// Its CSD directory is preceded by the configured CSD file and FHIR directories, if any.
+func newProfile(config Config) (profile.Provider, error) {
+	var result profile.Provider
+	var err error
+	switch config.Profile {
+	case StaticProfile:
+		result, err = static.New(config.Static)
+	default:
+		result, err = nuts.New(config.Nuts, config.Tenants)
+	}
+	if err != nil {
+		return nil, err
+	}
+	directory, err := csd.New(config.CSD, result.CsdDirectory())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CSD directory: %w", err)
+	}
+	return profile.WithCsdDirectory(result, directory), nil
+}
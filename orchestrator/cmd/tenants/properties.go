@@ -1,19 +1,42 @@
 package tenants
 
 import (
+	"errors"
+	"fmt"
+	"maps"
 	"net/url"
+	"slices"
+	"strings"
 
+	"github.com/SanteonNL/orca/orchestrator/lib/consent"
 	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
 )
 
 type Properties struct {
-	ID           string
-	Nuts         NutsProperties            `koanf:"nuts"`
-	ChipSoft     ChipSoftProperties        `koanf:"chipsoft"`
-	Demo         DemoProperties            `koanf:"demo"`
-	CPS          CarePlanServiceProperties `koanf:"cps"`
-	TaskEngine   TaskEngineProperties      `koanf:"taskengine"`
-	EnableImport bool                      `koanf:"enableimport"`
+	ID         string
+	Nuts       NutsProperties            `koanf:"nuts"`
+	Static     StaticProperties          `koanf:"static"`
+	ChipSoft   ChipSoftProperties        `koanf:"chipsoft"`
+	Demo       DemoProperties            `koanf:"demo"`
+	CPS        CarePlanServiceProperties `koanf:"cps"`
+	TaskEngine TaskEngineProperties      `koanf:"taskengine"`
+	// TaskNotification configures which Task status changes are sent to the EHR.
+	TaskNotification TaskNotificationProperties `koanf:"tasknotification"`
+	// CommunicationNotification configures delivery of received Communications (secure messages) to the EHR.
+	CommunicationNotification CommunicationNotificationProperties `koanf:"communicationnotification"`
+	// EHR configures system-level access to the tenant's EHR FHIR API, e.g. using SMART Backend Services.
+	EHR EHRProperties `koanf:"ehr"`
+	// HealthDataView configures which EHR data remote CareTeam members may query through the health data view endpoint.
+	HealthDataView HealthDataViewProperties `koanf:"healthdataview"`
+	// BatchWrite configures which resources remote CareTeam members may create or update in the EHR through FHIR batch Bundles.
+	BatchWrite BatchWriteProperties `koanf:"batchwrite"`
+	// Consent configures how patient consent is checked before data is shared with other care organizations,
+	// through the EHR proxy (CPC) and the Care Plan Service's read and search operations.
+	Consent consent.Config `koanf:"consent"`
+	// PatientValidation configures the rules Patients created through the Care Plan Service must comply with.
+	PatientValidation PatientValidationProperties `koanf:"patientvalidation"`
+	EnableImport      bool                        `koanf:"enableimport"`
 }
 
 type NutsProperties struct {
@@ -21,8 +44,295 @@ type NutsProperties struct {
 	Subject string `koanf:"subject"`
 }
 
+// StaticProperties configures the identity of the care organization when the static profile is used (instead of Nuts).
+type StaticProperties struct {
+	// Identifiers are the identifiers of the care organization, in the form of <system>|<value>.
+	Identifiers []string `koanf:"identifiers"`
+	// Name is the name of the care organization.
+	Name string `koanf:"name"`
+}
+
+// Organization returns the care organization as configured for the static profile.
+func (p StaticProperties) Organization() (*fhir.Organization, error) {
+	result := &fhir.Organization{}
+	for _, token := range p.Identifiers {
+		identifier, err := coolfhir.TokenToIdentifier(token)
+		if err != nil || identifier.System == nil || identifier.Value == nil {
+			return nil, fmt.Errorf("invalid identifier (expected <system>|<value>): %s", token)
+		}
+		result.Identifier = append(result.Identifier, *identifier)
+	}
+	if len(result.Identifier) == 0 {
+		return nil, errors.New("no identifiers configured")
+	}
+	if p.Name != "" {
+		result.Name = &p.Name
+	}
+	return result, nil
+}
+
 type TaskEngineProperties struct {
 	Enabled bool `koanf:"enabled"`
+	// Review configures which Tasks must be reviewed by a care professional before they're accepted,
+	// instead of being accepted by the Task Filler engine as soon as the Questionnaire negotiation completes.
+	Review TaskReviewProperties `koanf:"review"`
+}
+
+type TaskReviewProperties struct {
+	// Enabled makes all Tasks of the tenant require manual review.
+	Enabled bool `koanf:"enabled"`
+	// Services contains the ServiceRequest codes (in the form of <system>|<code>) of workflows that require manual review,
+	// for tenants that only want to review Tasks of specific workflows.
+	Services []string `koanf:"services"`
+}
+
+// Required returns whether a Task for a ServiceRequest with the given codes must be reviewed manually.
+func (r TaskReviewProperties) Required(serviceCodes []fhir.Coding) bool {
+	if r.Enabled {
+		return true
+	}
+	for _, service := range r.Services {
+		for _, coding := range serviceCodes {
+			if coding.System != nil && coding.Code != nil && *coding.System+"|"+*coding.Code == service {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type TaskNotificationProperties struct {
+	// Statuses contains the Task statuses (e.g. rejected, completed) that, when a Task of which the tenant is requester or owner
+	// transitions to it, cause the Task's BundleSet to be sent to the EHR.
+	Statuses []string `koanf:"statuses"`
+	// Endpoint contains the EHR endpoints per Task status (non-letters removed, e.g. enteredinerror) the BundleSet is sent to.
+	// If no endpoint is configured for a status, DefaultEndpoint is used.
+	Endpoint map[string]string `koanf:"endpoint"`
+	// DefaultEndpoint is the EHR endpoint BundleSets are sent to if no endpoint is configured for the Task status.
+	// If not set, the Task Filler's TaskAcceptedBundleEndpoint is used.
+	DefaultEndpoint string `koanf:"defaultendpoint"`
+	// Auth configures how ORCA authenticates to the tenant's EHR endpoints.
+	Auth EndpointAuthProperties `koanf:"auth"`
+	// Delivery specifies how Task data is delivered to the EHR, supported options: bundleset (default), fhir.
+	Delivery TaskDeliveryMode `koanf:"delivery"`
+}
+
+type CommunicationNotificationProperties struct {
+	// Endpoint is the EHR endpoint received Communications are sent to (e.g. to show them in the EHR's inbox).
+	// It authenticates the same way as the TaskNotification endpoints. If not set, Communications aren't sent to the EHR.
+	Endpoint string `koanf:"endpoint"`
+}
+
+type TaskDeliveryMode string
+
+const (
+	// TaskDeliveryBundleSet sends the Task's BundleSet to the EHR endpoint.
+	TaskDeliveryBundleSet TaskDeliveryMode = "bundleset"
+	// TaskDeliveryFHIR writes the Task's data into the tenant's EHR FHIR API using a FHIR transaction.
+	TaskDeliveryFHIR TaskDeliveryMode = "fhir"
+)
+
+type EndpointAuthType string
+
+const (
+	EndpointAuthNone                    EndpointAuthType = ""
+	EndpointAuthMTLS                    EndpointAuthType = "mtls"
+	EndpointAuthOAuth2ClientCredentials EndpointAuthType = "oauth2-client-credentials"
+)
+
+// EndpointAuthProperties configures authentication to an HTTP endpoint of the tenant's EHR.
+type EndpointAuthProperties struct {
+	// Type of authentication to use, supported options: mtls, oauth2-client-credentials.
+	// Leave empty for no authentication.
+	Type EndpointAuthType `koanf:"type"`
+	// CertFile and KeyFile specify the PEM-encoded client certificate and private key used for mtls.
+	CertFile string `koanf:"certfile"`
+	KeyFile  string `koanf:"keyfile"`
+	// CAFile optionally specifies PEM-encoded CA certificates to trust, instead of the system's trust store.
+	CAFile string `koanf:"cafile"`
+	// TokenURL, ClientID, ClientSecret and Scopes specify the OAuth2 client credentials flow for oauth2-client-credentials.
+	TokenURL     string `koanf:"tokenurl"`
+	ClientID     string `koanf:"clientid"`
+	ClientSecret string `koanf:"clientsecret"`
+	Scopes       string `koanf:"scopes"`
+}
+
+func (a EndpointAuthProperties) Validate() error {
+	switch a.Type {
+	case EndpointAuthNone:
+		return nil
+	case EndpointAuthMTLS:
+		if a.CertFile == "" || a.KeyFile == "" {
+			return errors.New("mtls requires certfile and keyfile")
+		}
+	case EndpointAuthOAuth2ClientCredentials:
+		if a.TokenURL == "" || a.ClientID == "" || a.ClientSecret == "" {
+			return errors.New("oauth2-client-credentials requires tokenurl, clientid and clientsecret")
+		}
+	default:
+		return fmt.Errorf("unsupported authentication type: %s", a.Type)
+	}
+	return nil
+}
+
+// Enabled returns whether the EHR must be notified when a Task transitions to the given status.
+func (n TaskNotificationProperties) Enabled(status fhir.TaskStatus) bool {
+	return slices.Contains(n.Statuses, status.Code())
+}
+
+// EndpointFor returns the EHR endpoint configured for the given Task status, or an empty string if none is configured.
+func (n TaskNotificationProperties) EndpointFor(status fhir.TaskStatus) string {
+	return n.Endpoint[TaskStatusKey(status)]
+}
+
+// TaskStatusKey returns the Task status code with all non-letters removed, so it can be used as configuration key.
+func TaskStatusKey(status fhir.TaskStatus) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r
+		}
+		return -1
+	}, status.Code())
+}
+
+const (
+	// PatientTelecomNone can be configured as required telecom system to not require any telecom.
+	PatientTelecomNone = "none"
+	// PhoneFormatNLMobile allows Dutch mobile numbers (06xxxxxxxx or +316xxxxxxxx).
+	PhoneFormatNLMobile = "nl-mobile"
+	// PhoneFormatBEMobile allows Belgian mobile numbers (+324xxxxxxxx).
+	PhoneFormatBEMobile = "be-mobile"
+	// PhoneFormatDEMobile allows German mobile numbers (+4915x, +4916x or +4917x).
+	PhoneFormatDEMobile = "de-mobile"
+	// PhoneFormatNL allows all Dutch numbers, including landlines (0xxxxxxxxx or +31xxxxxxxxx).
+	PhoneFormatNL = "nl"
+	// PhoneFormatInternational allows all international numbers in E.164 format (+ followed by 8 to 15 digits).
+	PhoneFormatInternational = "international"
+)
+
+type PatientValidationProperties struct {
+	// Default contains the rules that apply to Patients that aren't enrolled in a workflow with its own rules.
+	Default PatientValidationRules `koanf:"default"`
+	// Workflow contains rules per workflow (the key is a name of choice), which replace the default rules.
+	Workflow map[string]WorkflowPatientValidationProperties `koanf:"workflow"`
+}
+
+type WorkflowPatientValidationProperties struct {
+	// Services contains the ServiceRequest codes (in the form of <system>|<code>) of the workflow.
+	Services []string `koanf:"services"`
+	// Rules contains the rules that apply to Patients enrolled in the workflow.
+	Rules PatientValidationRules `koanf:"rules"`
+}
+
+type PatientValidationRules struct {
+	// RequiredTelecom contains the telecom systems (email, phone) a Patient must have.
+	// If not set, both email and phone are required. Set to none to not require any telecom.
+	RequiredTelecom []string `koanf:"requiredtelecom"`
+	// RequireBSN requires the Patient to have a BSN identifier.
+	RequireBSN bool `koanf:"requirebsn"`
+	// RequireBirthDate requires the Patient to have a birth date.
+	RequireBirthDate bool `koanf:"requirebirthdate"`
+	// PhoneFormats contains the allowed phone number formats (see PhoneFormatNLMobile etc.). If the Patient has phone numbers,
+	// at least one of them must be in an allowed format. If not set, Dutch, Belgian and German mobile numbers are allowed.
+	PhoneFormats []string `koanf:"phoneformats"`
+}
+
+// RequiredTelecomSystems returns the telecom systems a Patient must have.
+func (r PatientValidationRules) RequiredTelecomSystems() []string {
+	if len(r.RequiredTelecom) == 0 {
+		return []string{"email", "phone"}
+	}
+	var result []string
+	for _, system := range r.RequiredTelecom {
+		if system != PatientTelecomNone {
+			result = append(result, strings.ToLower(system))
+		}
+	}
+	return result
+}
+
+// AllowedPhoneFormats returns the allowed phone number formats.
+func (r PatientValidationRules) AllowedPhoneFormats() []string {
+	if len(r.PhoneFormats) == 0 {
+		return []string{PhoneFormatNLMobile, PhoneFormatBEMobile, PhoneFormatDEMobile}
+	}
+	return r.PhoneFormats
+}
+
+func (r PatientValidationRules) Validate() error {
+	for _, system := range r.RequiredTelecom {
+		switch strings.ToLower(system) {
+		case "email", "phone", PatientTelecomNone:
+		default:
+			return fmt.Errorf("invalid required telecom system: %s", system)
+		}
+	}
+	for _, format := range r.PhoneFormats {
+		switch format {
+		case PhoneFormatNLMobile, PhoneFormatBEMobile, PhoneFormatDEMobile, PhoneFormatNL, PhoneFormatInternational:
+		default:
+			return fmt.Errorf("invalid phone format: %s", format)
+		}
+	}
+	return nil
+}
+
+// RulesFor returns the rules for a Patient enrolled in a workflow identified by one of the given ServiceRequest codes.
+// If no workflow has its own rules, the default rules are returned. If multiple workflows match, the first by name is used.
+func (p PatientValidationProperties) RulesFor(serviceCodes []fhir.Coding) PatientValidationRules {
+	for _, name := range slices.Sorted(maps.Keys(p.Workflow)) {
+		workflow := p.Workflow[name]
+		for _, service := range workflow.Services {
+			for _, coding := range serviceCodes {
+				if coding.System != nil && coding.Code != nil && *coding.System+"|"+*coding.Code == service {
+					return workflow.Rules
+				}
+			}
+		}
+	}
+	return p.Default
+}
+
+type HealthDataViewProperties struct {
+	// Resources contains the resource types (case-insensitive, e.g. observation) remote CareTeam members may query,
+	// and per resource type the search parameters they may use. Parameters that scope the query to the patient
+	// (e.g. patient, subject) and result parameters (e.g. _count) are always allowed.
+	Resources map[string][]string `koanf:"resources"`
+}
+
+// Configured returns whether an allow-list of resource types has been configured.
+func (h HealthDataViewProperties) Configured() bool {
+	return len(h.Resources) > 0
+}
+
+// SearchParameters returns the allowed search parameters of the given resource type,
+// and whether the resource type is allowed at all.
+func (h HealthDataViewProperties) SearchParameters(resourceType string) ([]string, bool) {
+	for allowedType, params := range h.Resources {
+		if strings.EqualFold(allowedType, resourceType) {
+			return params, true
+		}
+	}
+	return nil, false
+}
+
+type BatchWriteProperties struct {
+	// ResourceTypes contains the resource types (case-insensitive, e.g. observation) that may be created (POST) or updated (PUT).
+	// If empty, batch Bundles may only contain reads and searches (GET).
+	ResourceTypes []string `koanf:"resourcetypes"`
+	// Transaction specifies whether batch Bundles containing writes are executed as a FHIR transaction against the EHR's FHIR API,
+	// so that either all or none of the entries succeed. Only enable this if the EHR's FHIR API supports transactions.
+	Transaction bool `koanf:"transaction"`
+}
+
+// Allowed returns whether the given resource type may be written through batch Bundles.
+func (b BatchWriteProperties) Allowed(resourceType string) bool {
+	for _, allowedType := range b.ResourceTypes {
+		if strings.EqualFold(allowedType, resourceType) {
+			return true
+		}
+	}
+	return false
 }
 
 type ChipSoftProperties struct {
@@ -35,6 +345,12 @@ type DemoProperties struct {
 	FHIR coolfhir.ClientConfig `koanf:"fhir"`
 }
 
+type EHRProperties struct {
+	// FHIR specifies the connection to the FHIR API of the EHR.
+	// It's used to proxy requests to the EHR and read/write EHR data, when not done through a user session.
+	FHIR coolfhir.ClientConfig `koanf:"fhir"`
+}
+
 type CarePlanServiceProperties struct {
 	// FHIR specifies the connection to the Care Plan Service FHIR API.
 	// It's required if the Care Plan Service is enabled.
@@ -8,7 +8,9 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
 	"github.com/SanteonNL/orca/orchestrator/lib/logging"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
 )
 
 type Config map[string]Properties
@@ -18,9 +20,6 @@ func (c Config) Validate(cpsEnabled bool) error {
 		if !isIDValid(id) {
 			return fmt.Errorf("tenant %s: invalid ID", id)
 		}
-		if props.Nuts.Subject == "" {
-			return fmt.Errorf("tenant %s: missing Nuts subject", id)
-		}
 		if cpsEnabled {
 			if props.CPS.FHIR.BaseURL == "" {
 				return fmt.Errorf("tenant %s: CPS FHIR URL is not configured", id)
@@ -32,6 +31,44 @@ func (c Config) Validate(cpsEnabled bool) error {
 		if err := props.Demo.FHIR.Validate(); err != nil {
 			return fmt.Errorf("tenant %s: invalid Demo FHIR configuration: %w", id, err)
 		}
+		if err := props.EHR.FHIR.Validate(); err != nil {
+			return fmt.Errorf("tenant %s: invalid EHR FHIR configuration: %w", id, err)
+		}
+		for _, service := range props.TaskEngine.Review.Services {
+			if identifier, err := coolfhir.TokenToIdentifier(service); err != nil || identifier.System == nil || identifier.Value == nil {
+				return fmt.Errorf("tenant %s: invalid Task review service code (expected <system>|<code>): %s", id, service)
+			}
+		}
+		for _, status := range props.TaskNotification.Statuses {
+			var taskStatus fhir.TaskStatus
+			if err := taskStatus.UnmarshalJSON([]byte(strconv.Quote(status))); err != nil {
+				return fmt.Errorf("tenant %s: invalid Task notification status: %s", id, status)
+			}
+		}
+		switch props.TaskNotification.Delivery {
+		case "", TaskDeliveryBundleSet, TaskDeliveryFHIR:
+		default:
+			return fmt.Errorf("tenant %s: invalid Task notification delivery mode: %s", id, props.TaskNotification.Delivery)
+		}
+		if err := props.TaskNotification.Auth.Validate(); err != nil {
+			return fmt.Errorf("tenant %s: invalid Task notification auth configuration: %w", id, err)
+		}
+		if err := props.Consent.Validate(); err != nil {
+			return fmt.Errorf("tenant %s: invalid consent configuration: %w", id, err)
+		}
+		if err := props.PatientValidation.Default.Validate(); err != nil {
+			return fmt.Errorf("tenant %s: invalid Patient validation configuration: %w", id, err)
+		}
+		for name, workflow := range props.PatientValidation.Workflow {
+			for _, service := range workflow.Services {
+				if identifier, err := coolfhir.TokenToIdentifier(service); err != nil || identifier.System == nil || identifier.Value == nil {
+					return fmt.Errorf("tenant %s: invalid Patient validation service code of workflow %s (expected <system>|<code>): %s", id, name, service)
+				}
+			}
+			if err := workflow.Rules.Validate(); err != nil {
+				return fmt.Errorf("tenant %s: invalid Patient validation configuration of workflow %s: %w", id, name, err)
+			}
+		}
 	}
 	return nil
 }
@@ -2,38 +2,196 @@ package tenants
 
 import (
 	"context"
+	"github.com/SanteonNL/orca/orchestrator/lib/consent"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
 	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
 	"testing"
 )
 
 func TestConfig_Validate(t *testing.T) {
-	t.Run("Nuts configuration", func(t *testing.T) {
-		t.Run("subject not set", func(t *testing.T) {
+	t.Run("CarePlanService configuration", func(t *testing.T) {
+		t.Run("CPSFHIR BaseURL not set", func(t *testing.T) {
 			c := Config{
 				"sub": Properties{
 					ID: "sub",
+					Nuts: NutsProperties{
+						Subject: "subject",
+					},
+				},
+			}
+			err := c.Validate(true)
+			require.EqualError(t, err, "tenant sub: CPS FHIR URL is not configured")
+		})
+	})
+	t.Run("Task review configuration", func(t *testing.T) {
+		t.Run("invalid service code", func(t *testing.T) {
+			c := Config{
+				"sub": Properties{
+					ID: "sub",
+					Nuts: NutsProperties{
+						Subject: "subject",
+					},
+					TaskEngine: TaskEngineProperties{
+						Review: TaskReviewProperties{
+							Services: []string{"invalid"},
+						},
+					},
 				},
 			}
 			err := c.Validate(false)
-			require.EqualError(t, err, "tenant sub: missing Nuts subject")
+			require.EqualError(t, err, "tenant sub: invalid Task review service code (expected <system>|<code>): invalid")
 		})
 	})
-	t.Run("CarePlanService configuration", func(t *testing.T) {
-		t.Run("CPSFHIR BaseURL not set", func(t *testing.T) {
+	t.Run("Task notification configuration", func(t *testing.T) {
+		t.Run("invalid status", func(t *testing.T) {
 			c := Config{
 				"sub": Properties{
 					ID: "sub",
 					Nuts: NutsProperties{
 						Subject: "subject",
 					},
+					TaskNotification: TaskNotificationProperties{
+						Statuses: []string{"finished"},
+					},
 				},
 			}
-			err := c.Validate(true)
-			require.EqualError(t, err, "tenant sub: CPS FHIR URL is not configured")
+			err := c.Validate(false)
+			require.EqualError(t, err, "tenant sub: invalid Task notification status: finished")
+		})
+		t.Run("invalid delivery mode", func(t *testing.T) {
+			c := Config{
+				"sub": Properties{
+					ID: "sub",
+					Nuts: NutsProperties{
+						Subject: "subject",
+					},
+					TaskNotification: TaskNotificationProperties{
+						Delivery: "email",
+					},
+				},
+			}
+			err := c.Validate(false)
+			require.EqualError(t, err, "tenant sub: invalid Task notification delivery mode: email")
+		})
+	})
+	t.Run("consent configuration", func(t *testing.T) {
+		c := Config{
+			"sub": Properties{
+				ID: "sub",
+				Nuts: NutsProperties{
+					Subject: "subject",
+				},
+				Consent: consent.Config{
+					Type: consent.TypeRegistry,
+				},
+			},
+		}
+		err := c.Validate(false)
+		require.EqualError(t, err, "tenant sub: invalid consent configuration: consent registry URL is not configured")
+	})
+	t.Run("Patient validation configuration", func(t *testing.T) {
+		t.Run("invalid required telecom", func(t *testing.T) {
+			c := Config{
+				"sub": Properties{
+					ID:   "sub",
+					Nuts: NutsProperties{Subject: "subject"},
+					PatientValidation: PatientValidationProperties{
+						Default: PatientValidationRules{RequiredTelecom: []string{"fax"}},
+					},
+				},
+			}
+			err := c.Validate(false)
+			require.EqualError(t, err, "tenant sub: invalid Patient validation configuration: invalid required telecom system: fax")
+		})
+		t.Run("invalid phone format", func(t *testing.T) {
+			c := Config{
+				"sub": Properties{
+					ID:   "sub",
+					Nuts: NutsProperties{Subject: "subject"},
+					PatientValidation: PatientValidationProperties{
+						Workflow: map[string]WorkflowPatientValidationProperties{
+							"telemonitoring": {
+								Services: []string{"http://snomed.info/sct|719858009"},
+								Rules:    PatientValidationRules{PhoneFormats: []string{"fr-mobile"}},
+							},
+						},
+					},
+				},
+			}
+			err := c.Validate(false)
+			require.EqualError(t, err, "tenant sub: invalid Patient validation configuration of workflow telemonitoring: invalid phone format: fr-mobile")
+		})
+		t.Run("invalid service code", func(t *testing.T) {
+			c := Config{
+				"sub": Properties{
+					ID:   "sub",
+					Nuts: NutsProperties{Subject: "subject"},
+					PatientValidation: PatientValidationProperties{
+						Workflow: map[string]WorkflowPatientValidationProperties{
+							"telemonitoring": {Services: []string{"invalid"}},
+						},
+					},
+				},
+			}
+			err := c.Validate(false)
+			require.EqualError(t, err, "tenant sub: invalid Patient validation service code of workflow telemonitoring (expected <system>|<code>): invalid")
 		})
 	})
 }
 
+func TestTaskNotificationProperties(t *testing.T) {
+	properties := TaskNotificationProperties{
+		Statuses: []string{"completed", "entered-in-error"},
+		Endpoint: map[string]string{
+			"enteredinerror": "https://example.com/error",
+		},
+	}
+	t.Run("Enabled", func(t *testing.T) {
+		require.True(t, properties.Enabled(fhir.TaskStatusCompleted))
+		require.True(t, properties.Enabled(fhir.TaskStatusEnteredInError))
+		require.False(t, properties.Enabled(fhir.TaskStatusRejected))
+	})
+	t.Run("EndpointFor", func(t *testing.T) {
+		require.Equal(t, "https://example.com/error", properties.EndpointFor(fhir.TaskStatusEnteredInError))
+		require.Empty(t, properties.EndpointFor(fhir.TaskStatusCompleted))
+	})
+}
+
+func TestEndpointAuthProperties_Validate(t *testing.T) {
+	t.Run("no authentication", func(t *testing.T) {
+		require.NoError(t, EndpointAuthProperties{}.Validate())
+	})
+	t.Run("mtls without key", func(t *testing.T) {
+		err := EndpointAuthProperties{Type: EndpointAuthMTLS, CertFile: "cert.pem"}.Validate()
+		require.EqualError(t, err, "mtls requires certfile and keyfile")
+	})
+	t.Run("oauth2-client-credentials without secret", func(t *testing.T) {
+		err := EndpointAuthProperties{Type: EndpointAuthOAuth2ClientCredentials, TokenURL: "https://example.com/token", ClientID: "client"}.Validate()
+		require.EqualError(t, err, "oauth2-client-credentials requires tokenurl, clientid and clientsecret")
+	})
+	t.Run("unsupported type", func(t *testing.T) {
+		err := EndpointAuthProperties{Type: "basic"}.Validate()
+		require.EqualError(t, err, "unsupported authentication type: basic")
+	})
+}
+
+func TestTaskReviewProperties_Required(t *testing.T) {
+	codes := []fhir.Coding{{System: to.Ptr("http://snomed.info/sct"), Code: to.Ptr("123")}}
+	t.Run("enabled for all Tasks", func(t *testing.T) {
+		require.True(t, TaskReviewProperties{Enabled: true}.Required(nil))
+	})
+	t.Run("disabled", func(t *testing.T) {
+		require.False(t, TaskReviewProperties{}.Required(codes))
+	})
+	t.Run("enabled for requested service", func(t *testing.T) {
+		require.True(t, TaskReviewProperties{Services: []string{"http://snomed.info/sct|123"}}.Required(codes))
+	})
+	t.Run("enabled for other service", func(t *testing.T) {
+		require.False(t, TaskReviewProperties{Services: []string{"http://snomed.info/sct|456"}}.Required(codes))
+	})
+}
+
 func Test_isIDValid(t *testing.T) {
 	type args struct {
 		tenantID string
@@ -92,3 +250,37 @@ func TestFromContext(t *testing.T) {
 		require.ErrorIs(t, err, ErrNoTenant)
 	})
 }
+
+func TestPatientValidationProperties_RulesFor(t *testing.T) {
+	properties := PatientValidationProperties{
+		Default: PatientValidationRules{RequireBirthDate: true},
+		Workflow: map[string]WorkflowPatientValidationProperties{
+			"telemonitoring": {
+				Services: []string{"http://snomed.info/sct|719858009"},
+				Rules:    PatientValidationRules{RequireBSN: true},
+			},
+		},
+	}
+	t.Run("workflow rules", func(t *testing.T) {
+		rules := properties.RulesFor([]fhir.Coding{{System: to.Ptr("http://snomed.info/sct"), Code: to.Ptr("719858009")}})
+		require.True(t, rules.RequireBSN)
+		require.False(t, rules.RequireBirthDate)
+	})
+	t.Run("default rules for other workflow", func(t *testing.T) {
+		rules := properties.RulesFor([]fhir.Coding{{System: to.Ptr("http://snomed.info/sct"), Code: to.Ptr("123")}})
+		require.True(t, rules.RequireBirthDate)
+	})
+	t.Run("default rules without workflow", func(t *testing.T) {
+		require.True(t, properties.RulesFor(nil).RequireBirthDate)
+	})
+}
+
+func TestPatientValidationRules(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		require.Equal(t, []string{"email", "phone"}, PatientValidationRules{}.RequiredTelecomSystems())
+		require.Equal(t, []string{PhoneFormatNLMobile, PhoneFormatBEMobile, PhoneFormatDEMobile}, PatientValidationRules{}.AllowedPhoneFormats())
+	})
+	t.Run("no telecom required", func(t *testing.T) {
+		require.Empty(t, PatientValidationRules{RequiredTelecom: []string{PatientTelecomNone}}.RequiredTelecomSystems())
+	})
+}
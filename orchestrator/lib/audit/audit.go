@@ -13,7 +13,23 @@ import (
 
 var nowFunc = time.Now
 
-func Event(localIdentity fhir.Identifier, action fhir.AuditEventAction, resourceReference *fhir.Reference, actingAgentRef *fhir.Reference, policy []string) *fhir.AuditEvent {
+// BreakTheGlassPurposeOfUse is the purpose of use of emergency access to data the requester isn't otherwise authorized for.
+var BreakTheGlassPurposeOfUse = fhir.Coding{
+	System:  to.Ptr("http://terminology.hl7.org/CodeSystem/v3-ActReason"),
+	Code:    to.Ptr("BTG"),
+	Display: to.Ptr("break the glass"),
+}
+
+// CustodianParticipationType is the AuditEvent agent type of the party responsible for the accessed data (e.g. the CarePlan author).
+var CustodianParticipationType = fhir.Coding{
+	System:  to.Ptr("http://terminology.hl7.org/CodeSystem/v3-ParticipationType"),
+	Code:    to.Ptr("CST"),
+	Display: to.Ptr("custodian"),
+}
+
+// Event creates an AuditEvent for the given action on the given resource, performed by the acting agent (organization).
+// If actingUser is not nil, it is recorded as additional agent: the natural person that performed the action on behalf of the acting agent.
+func Event(localIdentity fhir.Identifier, action fhir.AuditEventAction, resourceReference *fhir.Reference, actingAgentRef *fhir.Reference, actingUser *fhir.AuditEventAgent, policy []string) *fhir.AuditEvent {
 	// Map AuditEventAction to restful-interaction code
 	var interactionCode, interactionDisplay string
 	switch action {
@@ -71,6 +87,9 @@ func Event(localIdentity fhir.Identifier, action fhir.AuditEventAction, resource
 		},
 	}
 
+	if actingUser != nil {
+		auditEvent.Agent = append(auditEvent.Agent, *actingUser)
+	}
 	return &auditEvent
 }
 
@@ -38,7 +38,7 @@ func TestAuditEvent(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := Event(auth.TestPrincipal2.Organization.Identifier[0], tt.action, tt.resourceRef, tt.actingAgentRef, nil)
+			got := Event(auth.TestPrincipal2.Organization.Identifier[0], tt.action, tt.resourceRef, tt.actingAgentRef, nil, nil)
 
 			assert.Equal(t, fixedTime.Format(time.RFC3339), got.Recorded)
 			assert.Equal(t, tt.action, *got.Action)
@@ -46,6 +46,23 @@ func TestAuditEvent(t *testing.T) {
 			assert.Equal(t, tt.actingAgentRef, got.Agent[0].Who)
 		})
 	}
+	t.Run("with acting user", func(t *testing.T) {
+		principal := *auth.TestPrincipal1
+		principal.User = &auth.User{
+			Practitioner: fhir.Practitioner{
+				Identifier: []fhir.Identifier{{System: to.Ptr("http://fhir.nl/fhir/NamingSystem/uzi-nr-pers"), Value: to.Ptr("123")}},
+			},
+		}
+
+		got := Event(auth.TestPrincipal2.Organization.Identifier[0], fhir.AuditEventActionR, &fhir.Reference{Reference: to.Ptr("Task/123")}, &fhir.Reference{
+			Identifier: &principal.Organization.Identifier[0],
+			Type:       to.Ptr("Organization"),
+		}, principal.UserAuditAgent(), nil)
+
+		assert.Len(t, got.Agent, 2)
+		assert.Equal(t, "Practitioner", *got.Agent[1].Who.Type)
+		assert.Equal(t, "123", *got.Agent[1].Who.Identifier.Value)
+	})
 }
 
 func TestIsCreator(t *testing.T) {
@@ -37,6 +37,8 @@ var _ fmt.Stringer = Principal{}
 
 type Principal struct {
 	Organization fhir.Organization
+	// User is the natural person acting on behalf of the organization, if known.
+	User *User
 }
 
 func (u Principal) ID() string {
@@ -44,9 +46,26 @@ func (u Principal) ID() string {
 }
 
 func (u Principal) String() string {
-	return fmt.Sprintf("Organization (%s=%s, name=%s, city=%s)",
+	result := fmt.Sprintf("Organization (%s=%s, name=%s, city=%s)",
 		*u.Organization.Identifier[0].System,
 		*u.Organization.Identifier[0].Value,
 		*u.Organization.Name,
 		*u.Organization.Address[0].City)
+	if u.User != nil {
+		result += ", user: " + u.User.String()
+	}
+	return result
+}
+
+// UserAuditAgent returns the AuditEvent agent for the principal's user, or nil if the principal has no user.
+func (u Principal) UserAuditAgent() *fhir.AuditEventAgent {
+	if u.User == nil {
+		return nil
+	}
+	who := u.User.Reference()
+	return &fhir.AuditEventAgent{
+		Role:      u.User.Roles(),
+		Who:       &who,
+		Requestor: true,
+	}
 }
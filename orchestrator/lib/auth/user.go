@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+type userContextKeyType struct{}
+
+var userContextKey = userContextKeyType{}
+
+// User is the natural person (e.g. a nurse or physician) that acts on behalf of the principal's organization.
+type User struct {
+	// Practitioner identifies the user, e.g. by UZI number or employee identifier.
+	Practitioner fhir.Practitioner
+	// PractitionerRole contains the user's role(s) within the organization, in PractitionerRole.code. It's optional.
+	PractitionerRole *fhir.PractitionerRole
+}
+
+// WithUser returns a context that carries the user that is logged in to ORCA (e.g. through an app launch),
+// so that it can be propagated to the principal of requests that are made on behalf of the user.
+func WithUser(ctx context.Context, user User) context.Context {
+	return context.WithValue(ctx, userContextKey, user)
+}
+
+// UserFromContext returns the user from the context, or nil if there is none.
+func UserFromContext(ctx context.Context) *User {
+	user, ok := ctx.Value(userContextKey).(User)
+	if !ok {
+		return nil
+	}
+	return &user
+}
+
+// Roles returns the user's role codes.
+func (u User) Roles() []fhir.CodeableConcept {
+	if u.PractitionerRole == nil {
+		return nil
+	}
+	return u.PractitionerRole.Code
+}
+
+// HasRole returns whether the user has a role with the given code.
+func (u User) HasRole(role fhir.Coding) bool {
+	for _, code := range u.Roles() {
+		if coolfhir.ContainsCoding(role, code.Coding...) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reference returns a logical reference to the user's Practitioner, which can be used in e.g. AuditEvent agents.
+func (u User) Reference() fhir.Reference {
+	result := fhir.Reference{
+		Type: to.Ptr("Practitioner"),
+	}
+	if len(u.Practitioner.Identifier) > 0 {
+		result.Identifier = &u.Practitioner.Identifier[0]
+	}
+	if len(u.Practitioner.Name) > 0 {
+		result.Display = u.Practitioner.Name[0].Text
+		if result.Display == nil && u.Practitioner.Name[0].Family != nil {
+			result.Display = u.Practitioner.Name[0].Family
+		}
+	}
+	return result
+}
+
+func (u User) String() string {
+	reference := u.Reference()
+	var identifier string
+	if reference.Identifier != nil {
+		identifier = coolfhir.ToString(*reference.Identifier)
+	}
+	return fmt.Sprintf("Practitioner (identifier=%s, name=%s)", identifier, to.EmptyString(reference.Display))
+}
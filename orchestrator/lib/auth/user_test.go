@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+func TestUser(t *testing.T) {
+	nurse := fhir.Coding{System: to.Ptr("http://snomed.info/sct"), Code: to.Ptr("224535009")}
+	user := User{
+		Practitioner: fhir.Practitioner{
+			Identifier: []fhir.Identifier{{System: to.Ptr("http://fhir.nl/fhir/NamingSystem/uzi-nr-pers"), Value: to.Ptr("123")}},
+			Name:       []fhir.HumanName{{Text: to.Ptr("John Doe")}},
+		},
+		PractitionerRole: &fhir.PractitionerRole{
+			Code: []fhir.CodeableConcept{{Coding: []fhir.Coding{nurse}}},
+		},
+	}
+	t.Run("HasRole", func(t *testing.T) {
+		require.True(t, user.HasRole(nurse))
+		require.False(t, user.HasRole(fhir.Coding{System: to.Ptr("http://snomed.info/sct"), Code: to.Ptr("309343006")}))
+		require.False(t, User{}.HasRole(nurse))
+	})
+	t.Run("String", func(t *testing.T) {
+		require.Equal(t, "Practitioner (identifier=http://fhir.nl/fhir/NamingSystem/uzi-nr-pers|123, name=John Doe)", user.String())
+	})
+	t.Run("context", func(t *testing.T) {
+		require.Nil(t, UserFromContext(context.Background()))
+		require.Equal(t, &user, UserFromContext(WithUser(context.Background(), user)))
+	})
+	t.Run("UserAuditAgent", func(t *testing.T) {
+		require.Nil(t, TestPrincipal1.UserAuditAgent())
+
+		principal := *TestPrincipal1
+		principal.User = &user
+		agent := principal.UserAuditAgent()
+
+		require.NotNil(t, agent)
+		require.True(t, agent.Requestor)
+		require.Equal(t, "Practitioner", *agent.Who.Type)
+		require.Equal(t, "John Doe", *agent.Who.Display)
+		require.Equal(t, user.Roles(), agent.Role)
+	})
+}
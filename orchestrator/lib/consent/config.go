@@ -0,0 +1,100 @@
+package consent
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+)
+
+type Type string
+
+const (
+	// TypeNone disables consent checks.
+	TypeNone Type = ""
+	// TypeFHIR evaluates Consent resources, see FHIRChecker.
+	TypeFHIR Type = "fhir"
+	// TypeRegistry queries a remote consent registry, see RegistryChecker.
+	TypeRegistry Type = "registry"
+	// TypeStub uses a StubChecker, intended for testing.
+	TypeStub Type = "stub"
+)
+
+// Source specifies the FHIR API that holds the Consent resources evaluated by the FHIRChecker.
+type Source string
+
+const (
+	// SourceCPS reads Consent resources from the Care Plan Service's FHIR API.
+	SourceCPS Source = "cps"
+	// SourceEHR reads Consent resources from the EHR's FHIR API.
+	SourceEHR Source = "ehr"
+)
+
+// Config configures the consent checks of a tenant.
+type Config struct {
+	// Type specifies how consent is checked, supported options: fhir, registry, stub.
+	// Leave empty to disable consent checks.
+	Type Type `koanf:"type"`
+	// Source specifies where Consent resources are stored when Type is fhir, supported options: cps (default), ehr.
+	Source Source `koanf:"source"`
+	// Registry specifies the endpoint and authentication of the consent registry when Type is registry.
+	Registry coolfhir.ClientConfig `koanf:"registry"`
+	// Deny contains the identifiers (in the form of <system>|<value>) of patients that didn't consent, when Type is stub.
+	Deny []string `koanf:"deny"`
+}
+
+func (c Config) Validate() error {
+	switch c.Type {
+	case TypeNone, TypeStub:
+	case TypeFHIR:
+		switch c.Source {
+		case "", SourceCPS, SourceEHR:
+		default:
+			return fmt.Errorf("unsupported Consent source: %s", c.Source)
+		}
+	case TypeRegistry:
+		if c.Registry.BaseURL == "" {
+			return errors.New("consent registry URL is not configured")
+		}
+		if err := c.Registry.Validate(); err != nil {
+			return fmt.Errorf("invalid consent registry configuration: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported consent type: %s", c.Type)
+	}
+	return nil
+}
+
+// New creates the Checker for the given configuration. It returns nil if consent checks are disabled.
+// The fhirClient function is used to get the FHIR client for the configured source, when Type is fhir.
+func New(config Config, fhirClient func(source Source) (fhirclient.Client, error)) (Checker, error) {
+	switch config.Type {
+	case TypeNone:
+		return nil, nil
+	case TypeFHIR:
+		source := config.Source
+		if source == "" {
+			source = SourceCPS
+		}
+		client, err := fhirClient(source)
+		if err != nil {
+			return nil, fmt.Errorf("consent: %w", err)
+		}
+		return FHIRChecker{FHIRClient: client}, nil
+	case TypeRegistry:
+		transport, _, err := coolfhir.NewAuthRoundTripper(config.Registry, coolfhir.Config())
+		if err != nil {
+			return nil, fmt.Errorf("consent: failed to create registry client: %w", err)
+		}
+		return RegistryChecker{
+			Endpoint:   config.Registry.BaseURL,
+			HTTPClient: &http.Client{Transport: transport},
+		}, nil
+	case TypeStub:
+		return StubChecker{Deny: config.Deny}, nil
+	default:
+		return nil, fmt.Errorf("consent: unsupported type: %s", config.Type)
+	}
+}
@@ -0,0 +1,124 @@
+// Package consent evaluates whether a patient consented to sharing their data with another care organization,
+// as required in the Netherlands (e.g. through Mitz) before data is exchanged between care organizations.
+package consent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/lib/audit"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/logging"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+// Request describes the data exchange for which consent is checked.
+type Request struct {
+	// Patient is the identifier of the patient whose data is shared, e.g. their BSN.
+	Patient fhir.Identifier `json:"patient"`
+	// Custodian contains the identifiers of the care organization that holds the data (the local care organization).
+	Custodian []fhir.Identifier `json:"custodian"`
+	// Actor contains the identifiers of the care organization that requests the data.
+	Actor []fhir.Identifier `json:"actor"`
+}
+
+// Decision is the outcome of a consent check.
+type Decision struct {
+	Permitted bool `json:"permitted"`
+	// Reason explains the decision, e.g. which Consent resource or registry response it was based on.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Checker checks whether a patient consented to sharing data.
+type Checker interface {
+	// Check returns the consent decision for the given request.
+	// An error is returned if the decision couldn't be made, e.g. because the consent source is unavailable.
+	Check(ctx context.Context, request Request) (*Decision, error)
+}
+
+// Enforce checks consent for the given request and returns an error if the patient didn't consent.
+// Denials are audited: logged, and recorded as AuditEvent through auditClient if it isn't nil.
+// If the checker is nil, consent checks are disabled and the request is permitted.
+// The returned error for a denial is a fhirclient.OperationOutcomeError with HTTP status 403,
+// which explains to the requester why the data isn't shared.
+func Enforce(ctx context.Context, checker Checker, auditClient fhirclient.Client, request Request) error {
+	if checker == nil {
+		return nil
+	}
+	decision, err := checker.Check(ctx, request)
+	if err != nil {
+		return fmt.Errorf("consent check failed: %w", err)
+	}
+	if decision.Permitted {
+		return nil
+	}
+	Audit(ctx, auditClient, request, *decision)
+	return fhirclient.OperationOutcomeError{
+		HttpStatusCode: http.StatusForbidden,
+		OperationOutcome: fhir.OperationOutcome{
+			Issue: []fhir.OperationOutcomeIssue{
+				{
+					Severity:    fhir.IssueSeverityError,
+					Code:        fhir.IssueTypeBusinessRule,
+					Diagnostics: to.Ptr("patient has not consented to sharing data with the requesting care organization"),
+				},
+			},
+		},
+	}
+}
+
+// Audit records a consent denial. The patient identifier isn't logged, since it's personal data.
+// If auditClient isn't nil, the denial is also recorded as AuditEvent (see AuditEvent) in its FHIR API.
+// Failing to record the AuditEvent is logged, but doesn't affect the denial.
+func Audit(ctx context.Context, auditClient fhirclient.Client, request Request, decision Decision) {
+	var actor, custodian []string
+	for _, identifier := range request.Actor {
+		actor = append(actor, coolfhir.IdentifierToToken(identifier))
+	}
+	for _, identifier := range request.Custodian {
+		custodian = append(custodian, coolfhir.IdentifierToToken(identifier))
+	}
+	slog.InfoContext(ctx, "Consent denied",
+		slog.String("audit", "consent"),
+		slog.Any("actor", actor),
+		slog.Any("custodian", custodian),
+		slog.String("reason", decision.Reason),
+	)
+	if auditClient == nil {
+		return
+	}
+	if err := auditClient.CreateWithContext(ctx, AuditEvent(request, decision), new(fhir.AuditEvent)); err != nil {
+		slog.ErrorContext(ctx, "Failed to record consent denial AuditEvent", slog.String(logging.FieldError, err.Error()))
+	}
+}
+
+// AuditEvent returns the AuditEvent of a consent decision: the acting (requesting) care organization accessing the patient's data,
+// observed by the custodian. The reason of the decision is recorded as outcome description.
+func AuditEvent(request Request, decision Decision) *fhir.AuditEvent {
+	var localIdentity fhir.Identifier
+	if len(request.Custodian) > 0 {
+		localIdentity = request.Custodian[0]
+	}
+	var actingAgent *fhir.Reference
+	if len(request.Actor) > 0 {
+		actingAgent = &fhir.Reference{
+			Type:       to.Ptr("Organization"),
+			Identifier: &request.Actor[0],
+		}
+	}
+	result := audit.Event(localIdentity, fhir.AuditEventActionR, &fhir.Reference{
+		Type:       to.Ptr("Patient"),
+		Identifier: &request.Patient,
+	}, actingAgent, nil, nil)
+	if !decision.Permitted {
+		result.Outcome = to.Ptr(fhir.AuditEventOutcome4)
+	}
+	if decision.Reason != "" {
+		result.OutcomeDesc = to.Ptr(decision.Reason)
+	}
+	return result
+}
@@ -0,0 +1,137 @@
+package consent
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/careplancontributor/mock"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	"go.uber.org/mock/gomock"
+)
+
+var testPatient = fhir.Identifier{System: to.Ptr(coolfhir.BSNNamingSystem), Value: to.Ptr("111222333")}
+
+type errorChecker struct{}
+
+func (errorChecker) Check(context.Context, Request) (*Decision, error) {
+	return nil, errors.New("unavailable")
+}
+
+func TestEnforce(t *testing.T) {
+	ctx := context.Background()
+	t.Run("permitted", func(t *testing.T) {
+		err := Enforce(ctx, StubChecker{}, nil, Request{Patient: testPatient})
+		require.NoError(t, err)
+	})
+	t.Run("denied", func(t *testing.T) {
+		err := Enforce(ctx, StubChecker{Deny: []string{coolfhir.BSNNamingSystem + "|111222333"}}, nil, Request{Patient: testPatient})
+
+		var outcomeError fhirclient.OperationOutcomeError
+		require.ErrorAs(t, err, &outcomeError)
+		require.Equal(t, http.StatusForbidden, outcomeError.HttpStatusCode)
+		require.Equal(t, fhir.IssueTypeBusinessRule, outcomeError.Issue[0].Code)
+		require.Equal(t, "patient has not consented to sharing data with the requesting care organization", *outcomeError.Issue[0].Diagnostics)
+	})
+	t.Run("denial is recorded as AuditEvent", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		auditClient := mock.NewMockClient(ctrl)
+		var auditEvent *fhir.AuditEvent
+		auditClient.EXPECT().CreateWithContext(ctx, gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, resource interface{}, _ interface{}, _ ...fhirclient.Option) error {
+			auditEvent = resource.(*fhir.AuditEvent)
+			return nil
+		})
+
+		err := Enforce(ctx, StubChecker{Deny: []string{coolfhir.BSNNamingSystem + "|111222333"}}, auditClient, Request{Patient: testPatient})
+
+		require.Error(t, err)
+		require.NotNil(t, auditEvent)
+		require.Equal(t, fhir.AuditEventOutcome4, *auditEvent.Outcome)
+	})
+	t.Run("failing to record AuditEvent doesn't affect denial", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		auditClient := mock.NewMockClient(ctrl)
+		auditClient.EXPECT().CreateWithContext(ctx, gomock.Any(), gomock.Any()).Return(errors.New("unavailable"))
+
+		err := Enforce(ctx, StubChecker{Deny: []string{coolfhir.BSNNamingSystem + "|111222333"}}, auditClient, Request{Patient: testPatient})
+
+		var outcomeError fhirclient.OperationOutcomeError
+		require.ErrorAs(t, err, &outcomeError)
+	})
+	t.Run("checks disabled", func(t *testing.T) {
+		err := Enforce(ctx, nil, nil, Request{Patient: testPatient})
+		require.NoError(t, err)
+	})
+	t.Run("check fails", func(t *testing.T) {
+		err := Enforce(ctx, errorChecker{}, nil, Request{Patient: testPatient})
+		require.EqualError(t, err, "consent check failed: unavailable")
+	})
+}
+
+func TestAuditEvent(t *testing.T) {
+	custodian := fhir.Identifier{System: to.Ptr(coolfhir.URANamingSystem), Value: to.Ptr("1")}
+	actor := fhir.Identifier{System: to.Ptr(coolfhir.URANamingSystem), Value: to.Ptr("2")}
+	request := Request{Patient: testPatient, Custodian: []fhir.Identifier{custodian}, Actor: []fhir.Identifier{actor}}
+
+	auditEvent := AuditEvent(request, Decision{Reason: "Consent/1 denies access"})
+
+	require.Equal(t, fhir.AuditEventOutcome4, *auditEvent.Outcome)
+	require.Equal(t, "Consent/1 denies access", *auditEvent.OutcomeDesc)
+	require.Equal(t, custodian, *auditEvent.Source.Observer.Identifier)
+	require.Equal(t, actor, *auditEvent.Agent[0].Who.Identifier)
+	require.Equal(t, "Organization", *auditEvent.Agent[0].Who.Type)
+	require.Equal(t, testPatient, *auditEvent.Entity[0].What.Identifier)
+	require.Equal(t, "Patient", *auditEvent.Entity[0].What.Type)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		require.NoError(t, Config{}.Validate())
+	})
+	t.Run("fhir", func(t *testing.T) {
+		require.NoError(t, Config{Type: TypeFHIR, Source: SourceEHR}.Validate())
+	})
+	t.Run("unsupported source", func(t *testing.T) {
+		require.EqualError(t, Config{Type: TypeFHIR, Source: "other"}.Validate(), "unsupported Consent source: other")
+	})
+	t.Run("registry without URL", func(t *testing.T) {
+		require.EqualError(t, Config{Type: TypeRegistry}.Validate(), "consent registry URL is not configured")
+	})
+	t.Run("unsupported type", func(t *testing.T) {
+		require.EqualError(t, Config{Type: "other"}.Validate(), "unsupported consent type: other")
+	})
+}
+
+func TestNew(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		checker, err := New(Config{}, nil)
+		require.NoError(t, err)
+		require.Nil(t, checker)
+	})
+	t.Run("fhir uses CPS by default", func(t *testing.T) {
+		var requestedSource Source
+		checker, err := New(Config{Type: TypeFHIR}, func(source Source) (fhirclient.Client, error) {
+			requestedSource = source
+			return nil, nil
+		})
+		require.NoError(t, err)
+		require.IsType(t, FHIRChecker{}, checker)
+		require.Equal(t, SourceCPS, requestedSource)
+	})
+	t.Run("fhir client not available", func(t *testing.T) {
+		_, err := New(Config{Type: TypeFHIR, Source: SourceEHR}, func(source Source) (fhirclient.Client, error) {
+			return nil, errors.New("no EHR FHIR API configured")
+		})
+		require.EqualError(t, err, "consent: no EHR FHIR API configured")
+	})
+	t.Run("registry", func(t *testing.T) {
+		checker, err := New(Config{Type: TypeRegistry, Registry: coolfhir.ClientConfig{BaseURL: "https://example.com/consent"}}, nil)
+		require.NoError(t, err)
+		require.Equal(t, "https://example.com/consent", checker.(RegistryChecker).Endpoint)
+	})
+}
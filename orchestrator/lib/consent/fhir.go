@@ -0,0 +1,129 @@
+package consent
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+// ScopePatientPrivacy is the Consent.scope for privacy consents, which are the only Consents evaluated by the FHIRChecker.
+const ScopePatientPrivacy = "http://terminology.hl7.org/CodeSystem/consentscope|patient-privacy"
+
+var nowFunc = time.Now
+
+var _ Checker = &FHIRChecker{}
+
+// FHIRChecker checks consent by evaluating the patient's active privacy Consent resources in a FHIR API,
+// e.g. the CPS or the EHR.
+//
+// A Consent only applies if it has no organization (custodian), or one of its organizations matches the custodian.
+// Its provisions are evaluated according to FHIR R4: the base provision permits or denies,
+// nested provisions are exceptions that apply when their period and actors match.
+// A Consent without provision permits sharing. If any applicable Consent denies, sharing is denied.
+// If there's no applicable Consent, the patient didn't consent and sharing is denied.
+type FHIRChecker struct {
+	FHIRClient fhirclient.Client
+}
+
+func (f FHIRChecker) Check(ctx context.Context, request Request) (*Decision, error) {
+	query := url.Values{
+		"patient:identifier": []string{coolfhir.IdentifierToToken(request.Patient)},
+		"status":             []string{"active"},
+		"scope":              []string{ScopePatientPrivacy},
+	}
+	var bundle fhir.Bundle
+	if err := f.FHIRClient.SearchWithContext(ctx, "Consent", query, &bundle); err != nil {
+		return nil, fmt.Errorf("failed to search for Consent resources: %w", err)
+	}
+	var consents []fhir.Consent
+	if err := coolfhir.ResourcesInBundle(&bundle, coolfhir.EntryIsOfType("Consent"), &consents); err != nil {
+		return nil, fmt.Errorf("failed to read Consent resources: %w", err)
+	}
+	now := nowFunc()
+	var permittedBy string
+	for _, consent := range consents {
+		if !appliesToCustodian(consent, request.Custodian) {
+			continue
+		}
+		result, err := evaluateProvision(consent.Provision, fhir.ConsentProvisionTypePermit, request.Actor, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate Consent/%s: %w", to.EmptyString(consent.Id), err)
+		}
+		if result == nil {
+			continue
+		}
+		if *result == fhir.ConsentProvisionTypeDeny {
+			return &Decision{Permitted: false, Reason: "denied by Consent/" + to.EmptyString(consent.Id)}, nil
+		}
+		if permittedBy == "" {
+			permittedBy = "Consent/" + to.EmptyString(consent.Id)
+		}
+	}
+	if permittedBy == "" {
+		return &Decision{Permitted: false, Reason: "no applicable Consent"}, nil
+	}
+	return &Decision{Permitted: true, Reason: "permitted by " + permittedBy}, nil
+}
+
+func appliesToCustodian(consent fhir.Consent, custodian []fhir.Identifier) bool {
+	var hasIdentifiers bool
+	for _, organization := range consent.Organization {
+		if organization.Identifier == nil {
+			continue
+		}
+		hasIdentifiers = true
+		if coolfhir.HasIdentifier(*organization.Identifier, custodian...) {
+			return true
+		}
+	}
+	return !hasIdentifiers
+}
+
+// evaluateProvision returns the outcome of the given provision for the given actor, or nil if the provision doesn't apply.
+// Nested provisions override the outcome of their parent. If nested provisions conflict, deny takes precedence.
+func evaluateProvision(provision *fhir.ConsentProvision, inherited fhir.ConsentProvisionType, actor []fhir.Identifier, now time.Time) (*fhir.ConsentProvisionType, error) {
+	if provision == nil {
+		return &inherited, nil
+	}
+	applies, err := coolfhir.PeriodContains(provision.Period, now)
+	if err != nil || !applies {
+		return nil, err
+	}
+	if len(provision.Actor) > 0 && !hasActor(provision.Actor, actor) {
+		return nil, nil
+	}
+	result := inherited
+	if provision.Type != nil {
+		result = *provision.Type
+	}
+	outcome := result
+	for _, nested := range provision.Provision {
+		nestedResult, err := evaluateProvision(&nested, result, actor, now)
+		if err != nil {
+			return nil, err
+		}
+		if nestedResult == nil {
+			continue
+		}
+		if *nestedResult == fhir.ConsentProvisionTypeDeny {
+			return nestedResult, nil
+		}
+		outcome = *nestedResult
+	}
+	return &outcome, nil
+}
+
+func hasActor(provisionActors []fhir.ConsentProvisionActor, actor []fhir.Identifier) bool {
+	for _, provisionActor := range provisionActors {
+		if provisionActor.Reference.Identifier != nil && coolfhir.HasIdentifier(*provisionActor.Reference.Identifier, actor...) {
+			return true
+		}
+	}
+	return false
+}
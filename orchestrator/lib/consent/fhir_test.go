@@ -0,0 +1,147 @@
+package consent
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/careplancontributor/mock"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	"go.uber.org/mock/gomock"
+)
+
+func TestFHIRChecker_Check(t *testing.T) {
+	nowFunc = func() time.Time {
+		return time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	}
+	defer func() { nowFunc = time.Now }()
+	custodian := fhir.Identifier{System: to.Ptr(coolfhir.URANamingSystem), Value: to.Ptr("1")}
+	actor := fhir.Identifier{System: to.Ptr(coolfhir.URANamingSystem), Value: to.Ptr("2")}
+	otherOrganization := fhir.Identifier{System: to.Ptr(coolfhir.URANamingSystem), Value: to.Ptr("3")}
+	request := Request{Patient: testPatient, Custodian: []fhir.Identifier{custodian}, Actor: []fhir.Identifier{actor}}
+	provisionType := func(provisionType fhir.ConsentProvisionType) *fhir.ConsentProvisionType {
+		return &provisionType
+	}
+	actorProvision := func(provisionType *fhir.ConsentProvisionType, identifier fhir.Identifier) fhir.ConsentProvision {
+		return fhir.ConsentProvision{
+			Type:  provisionType,
+			Actor: []fhir.ConsentProvisionActor{{Reference: fhir.Reference{Identifier: &identifier}}},
+		}
+	}
+	check := func(t *testing.T, consents ...fhir.Consent) (*Decision, error) {
+		fhirClient := mock.NewMockClient(gomock.NewController(t))
+		fhirClient.EXPECT().SearchWithContext(gomock.Any(), "Consent", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, query url.Values, target any, _ ...fhirclient.Option) error {
+				require.Equal(t, coolfhir.BSNNamingSystem+"|111222333", query.Get("patient:identifier"))
+				require.Equal(t, "active", query.Get("status"))
+				require.Equal(t, ScopePatientPrivacy, query.Get("scope"))
+				searchSet := coolfhir.SearchSet()
+				for _, consent := range consents {
+					searchSet.Append(consent, nil, nil)
+				}
+				*target.(*fhir.Bundle) = searchSet.Bundle()
+				return nil
+			})
+		return FHIRChecker{FHIRClient: fhirClient}.Check(context.Background(), request)
+	}
+
+	t.Run("no Consent", func(t *testing.T) {
+		decision, err := check(t)
+
+		require.NoError(t, err)
+		require.False(t, decision.Permitted)
+		require.Equal(t, "no applicable Consent", decision.Reason)
+	})
+	t.Run("Consent without provision permits", func(t *testing.T) {
+		decision, err := check(t, fhir.Consent{Id: to.Ptr("1")})
+
+		require.NoError(t, err)
+		require.True(t, decision.Permitted)
+		require.Equal(t, "permitted by Consent/1", decision.Reason)
+	})
+	t.Run("permit for actor", func(t *testing.T) {
+		provision := actorProvision(provisionType(fhir.ConsentProvisionTypePermit), actor)
+		decision, err := check(t, fhir.Consent{Id: to.Ptr("1"), Provision: &provision})
+
+		require.NoError(t, err)
+		require.True(t, decision.Permitted)
+	})
+	t.Run("permit for other actor", func(t *testing.T) {
+		provision := actorProvision(provisionType(fhir.ConsentProvisionTypePermit), otherOrganization)
+		decision, err := check(t, fhir.Consent{Id: to.Ptr("1"), Provision: &provision})
+
+		require.NoError(t, err)
+		require.False(t, decision.Permitted)
+	})
+	t.Run("deny with exception for actor", func(t *testing.T) {
+		decision, err := check(t, fhir.Consent{Id: to.Ptr("1"), Provision: &fhir.ConsentProvision{
+			Type:      provisionType(fhir.ConsentProvisionTypeDeny),
+			Provision: []fhir.ConsentProvision{actorProvision(provisionType(fhir.ConsentProvisionTypePermit), actor)},
+		}})
+
+		require.NoError(t, err)
+		require.True(t, decision.Permitted)
+	})
+	t.Run("permit with exception for actor", func(t *testing.T) {
+		decision, err := check(t, fhir.Consent{Id: to.Ptr("1"), Provision: &fhir.ConsentProvision{
+			Type:      provisionType(fhir.ConsentProvisionTypePermit),
+			Provision: []fhir.ConsentProvision{actorProvision(provisionType(fhir.ConsentProvisionTypeDeny), actor)},
+		}})
+
+		require.NoError(t, err)
+		require.False(t, decision.Permitted)
+		require.Equal(t, "denied by Consent/1", decision.Reason)
+	})
+	t.Run("deny Consent takes precedence", func(t *testing.T) {
+		decision, err := check(t,
+			fhir.Consent{Id: to.Ptr("1")},
+			fhir.Consent{Id: to.Ptr("2"), Provision: &fhir.ConsentProvision{Type: provisionType(fhir.ConsentProvisionTypeDeny)}},
+		)
+
+		require.NoError(t, err)
+		require.False(t, decision.Permitted)
+		require.Equal(t, "denied by Consent/2", decision.Reason)
+	})
+	t.Run("expired provision", func(t *testing.T) {
+		decision, err := check(t, fhir.Consent{Id: to.Ptr("1"), Provision: &fhir.ConsentProvision{
+			Type:   provisionType(fhir.ConsentProvisionTypePermit),
+			Period: &fhir.Period{End: to.Ptr("2024-01-01")},
+		}})
+
+		require.NoError(t, err)
+		require.False(t, decision.Permitted)
+	})
+	t.Run("Consent of other custodian", func(t *testing.T) {
+		decision, err := check(t, fhir.Consent{Id: to.Ptr("1"), Organization: []fhir.Reference{{Identifier: &otherOrganization}}})
+
+		require.NoError(t, err)
+		require.False(t, decision.Permitted)
+	})
+	t.Run("Consent of custodian", func(t *testing.T) {
+		decision, err := check(t, fhir.Consent{Id: to.Ptr("1"), Organization: []fhir.Reference{{Identifier: &custodian}}})
+
+		require.NoError(t, err)
+		require.True(t, decision.Permitted)
+	})
+	t.Run("invalid period", func(t *testing.T) {
+		_, err := check(t, fhir.Consent{Id: to.Ptr("1"), Provision: &fhir.ConsentProvision{
+			Period: &fhir.Period{Start: to.Ptr("2024")},
+		}})
+
+		require.EqualError(t, err, "failed to evaluate Consent/1: unsupported timestamp format")
+	})
+	t.Run("search fails", func(t *testing.T) {
+		fhirClient := mock.NewMockClient(gomock.NewController(t))
+		fhirClient.EXPECT().SearchWithContext(gomock.Any(), "Consent", gomock.Any(), gomock.Any()).Return(errors.New("timeout"))
+
+		_, err := FHIRChecker{FHIRClient: fhirClient}.Check(context.Background(), request)
+
+		require.EqualError(t, err, "failed to search for Consent resources: timeout")
+	})
+}
@@ -0,0 +1,55 @@
+package consent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// maxRegistryResponseSize limits the size of consent registry responses, which only contain a decision.
+const maxRegistryResponseSize = 64 << 10
+
+var _ Checker = &RegistryChecker{}
+
+// RegistryChecker checks consent through a remote consent registry (e.g. a Mitz gateway).
+// The Request is POSTed as JSON to the endpoint, which must respond with a Decision as JSON.
+type RegistryChecker struct {
+	Endpoint   string
+	HTTPClient *http.Client
+}
+
+func (r RegistryChecker) Check(ctx context.Context, request Request) (*Decision, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, r.Endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	httpRequest.Header.Set("Accept", "application/json")
+	httpResponse, err := r.HTTPClient.Do(httpRequest)
+	if err != nil {
+		return nil, fmt.Errorf("consent registry request failed: %w", err)
+	}
+	defer httpResponse.Body.Close()
+	if httpResponse.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("consent registry returned unexpected status code: %d", httpResponse.StatusCode)
+	}
+	responseData, err := io.ReadAll(io.LimitReader(httpResponse.Body, maxRegistryResponseSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read consent registry response: %w", err)
+	}
+	if len(responseData) > maxRegistryResponseSize {
+		return nil, fmt.Errorf("consent registry response exceeds max. size of %d bytes", maxRegistryResponseSize)
+	}
+	var decision Decision
+	if err = json.Unmarshal(responseData, &decision); err != nil {
+		return nil, fmt.Errorf("invalid consent registry response: %w", err)
+	}
+	return &decision, nil
+}
@@ -0,0 +1,54 @@
+package consent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+func TestRegistryChecker_Check(t *testing.T) {
+	actor := fhir.Identifier{System: to.Ptr(coolfhir.URANamingSystem), Value: to.Ptr("2")}
+	var capturedRequest Request
+	responseStatus := http.StatusOK
+	responseBody := `{"permitted": false, "reason": "opt-out"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&capturedRequest))
+		w.WriteHeader(responseStatus)
+		_, _ = w.Write([]byte(responseBody))
+	}))
+	defer server.Close()
+	checker := RegistryChecker{Endpoint: server.URL, HTTPClient: server.Client()}
+
+	t.Run("ok", func(t *testing.T) {
+		decision, err := checker.Check(context.Background(), Request{Patient: testPatient, Actor: []fhir.Identifier{actor}})
+
+		require.NoError(t, err)
+		require.Equal(t, Decision{Permitted: false, Reason: "opt-out"}, *decision)
+		require.Equal(t, testPatient, capturedRequest.Patient)
+		require.Equal(t, []fhir.Identifier{actor}, capturedRequest.Actor)
+	})
+	t.Run("unexpected status code", func(t *testing.T) {
+		responseStatus = http.StatusInternalServerError
+		defer func() { responseStatus = http.StatusOK }()
+
+		_, err := checker.Check(context.Background(), Request{Patient: testPatient})
+
+		require.EqualError(t, err, "consent registry returned unexpected status code: 500")
+	})
+	t.Run("invalid response", func(t *testing.T) {
+		responseBody = "not JSON"
+
+		_, err := checker.Check(context.Background(), Request{Patient: testPatient})
+
+		require.ErrorContains(t, err, "invalid consent registry response")
+	})
+}
@@ -0,0 +1,23 @@
+package consent
+
+import (
+	"context"
+	"slices"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+)
+
+var _ Checker = &StubChecker{}
+
+// StubChecker is a Checker for testing, which permits sharing data of all patients except those in Deny.
+type StubChecker struct {
+	// Deny contains the identifiers (in the form of <system>|<value>) of patients that didn't consent.
+	Deny []string
+}
+
+func (s StubChecker) Check(_ context.Context, request Request) (*Decision, error) {
+	if slices.Contains(s.Deny, coolfhir.IdentifierToToken(request.Patient)) {
+		return &Decision{Permitted: false, Reason: "denied by stub"}, nil
+	}
+	return &Decision{Permitted: true, Reason: "permitted by stub"}, nil
+}
@@ -0,0 +1,199 @@
+package coolfhir
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/az/azkeyvault"
+	"github.com/SanteonNL/orca/orchestrator/lib/otel"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// tokenSources caches OAuth2 token sources by authentication type, token endpoint, client ID and scope,
+// so access tokens (and signing keys) are reused across FHIR clients that are created for the same FHIR server.
+var tokenSources = struct {
+	mux     sync.Mutex
+	sources map[string]oauth2.TokenSource
+}{sources: map[string]oauth2.TokenSource{}}
+
+// cachedTokenSource returns the cached token source for the given key, or creates it. Tokens are renewed 30 seconds before they expire.
+func cachedTokenSource(key string, create func() (oauth2.TokenSource, error)) (oauth2.TokenSource, error) {
+	tokenSources.mux.Lock()
+	defer tokenSources.mux.Unlock()
+	if source, ok := tokenSources.sources[key]; ok {
+		return source, nil
+	}
+	source, err := create()
+	if err != nil {
+		return nil, err
+	}
+	result := oauth2.ReuseTokenSourceWithExpiry(nil, source, 30*time.Second)
+	tokenSources.sources[key] = result
+	return result, nil
+}
+
+// ClientCertificateConfig configures the client certificate for mutual TLS, either sourced from PEM files or from Azure Key Vault.
+type ClientCertificateConfig struct {
+	// CertFile and KeyFile specify the PEM-encoded client certificate and private key.
+	CertFile string `koanf:"certfile"`
+	KeyFile  string `koanf:"keyfile"`
+	// CAFile optionally specifies PEM-encoded CA certificates to trust, instead of the system's trust store.
+	CAFile        string                         `koanf:"cafile"`
+	AzureKeyVault AzureKeyVaultCertificateConfig `koanf:"azurekv"`
+}
+
+type AzureKeyVaultCertificateConfig struct {
+	URL             string `koanf:"url"`
+	CredentialType  string `koanf:"credentialtype"`
+	CertificateName string `koanf:"certificatename"`
+}
+
+func (c ClientCertificateConfig) Validate() error {
+	if c.AzureKeyVault.URL != "" {
+		if c.AzureKeyVault.CertificateName == "" {
+			return errors.New("azurekv.certificatename is required")
+		}
+		return nil
+	}
+	if c.CertFile == "" || c.KeyFile == "" {
+		return errors.New("certfile and keyfile are required (or an Azure Key Vault certificate)")
+	}
+	return nil
+}
+
+// StrictMode disallows authentication types that are only intended for development (bearer-static).
+// It's set to globals.StrictMode on startup, which can't be used here since the globals package depends on this package.
+var StrictMode bool
+
+func (c AuthConfig) validate() error {
+	switch c.Type {
+	case Default, AzureManagedIdentity:
+		return nil
+	case SmartBackendServices:
+		if c.ClientID == "" {
+			return errors.New("auth.clientid is required for smart-backend-services")
+		}
+		if err := c.SigningKey.Validate(); err != nil {
+			return fmt.Errorf("invalid auth.signingkey: %w", err)
+		}
+	case OAuth2ClientCredentials:
+		if c.TokenEndpoint == "" || c.ClientID == "" {
+			return errors.New("auth.tokenendpoint and auth.clientid are required for oauth2-client-credentials")
+		}
+		if c.ClientSecret == "" {
+			if err := c.SigningKey.Validate(); err != nil {
+				return fmt.Errorf("oauth2-client-credentials requires auth.clientsecret or auth.signingkey: %w", err)
+			}
+		}
+	case MutualTLS:
+		if err := c.ClientCertificate.Validate(); err != nil {
+			return fmt.Errorf("invalid auth.clientcert: %w", err)
+		}
+	case BearerStatic:
+		if StrictMode {
+			return errors.New("bearer-static authentication is not allowed in strict mode")
+		}
+		if c.BearerToken == "" {
+			return errors.New("auth.bearertoken is required for bearer-static")
+		}
+	default:
+		return fmt.Errorf("invalid FHIR authentication type: %s", c.Type)
+	}
+	return nil
+}
+
+// NewOAuth2ClientCredentialsTokenSource returns a (cached) token source that requests access tokens using the OAuth2 client credentials grant,
+// authenticating the client using a client secret, or if not set, a JWT assertion signed with the configured key.
+func NewOAuth2ClientCredentialsTokenSource(config AuthConfig) (oauth2.TokenSource, error) {
+	cacheKey := strings.Join([]string{string(OAuth2ClientCredentials), config.TokenEndpoint, config.ClientID, config.OAuth2Scopes}, "|")
+	return cachedTokenSource(cacheKey, func() (oauth2.TokenSource, error) {
+		if config.ClientSecret == "" {
+			signingKey, err := loadSigningKey(config.SigningKey)
+			if err != nil {
+				return nil, err
+			}
+			return &smartBackendServicesTokenSource{
+				tokenEndpoint: config.TokenEndpoint,
+				clientID:      config.ClientID,
+				scope:         config.OAuth2Scopes,
+				signingKey:    signingKey,
+			}, nil
+		}
+		clientCredentials := clientcredentials.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret,
+			TokenURL:     config.TokenEndpoint,
+		}
+		if config.OAuth2Scopes != "" {
+			clientCredentials.Scopes = strings.Split(config.OAuth2Scopes, " ")
+		}
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, otel.NewTracedHTTPClient("oauth2-client-credentials"))
+		return clientCredentials.TokenSource(ctx), nil
+	})
+}
+
+// NewMutualTLSTransport returns a transport that authenticates using the configured client certificate.
+func NewMutualTLSTransport(config ClientCertificateConfig) (http.RoundTripper, error) {
+	var certificate tls.Certificate
+	if config.AzureKeyVault.URL != "" {
+		credentialType := config.AzureKeyVault.CredentialType
+		if credentialType == "" {
+			credentialType = "managed_identity"
+		}
+		certsClient, err := azkeyvault.NewCertificatesClient(config.AzureKeyVault.URL, credentialType, false)
+		if err != nil {
+			return nil, err
+		}
+		keysClient, err := azkeyvault.NewKeysClient(config.AzureKeyVault.URL, credentialType, false)
+		if err != nil {
+			return nil, err
+		}
+		result, err := azkeyvault.GetTLSCertificate(context.Background(), certsClient, keysClient, config.AzureKeyVault.CertificateName)
+		if err != nil {
+			return nil, err
+		}
+		certificate = *result
+	} else {
+		var err error
+		certificate, err = tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if config.CAFile != "" {
+		caData, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificates: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caData) {
+			return nil, errors.New("no CA certificates found in CA file")
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// newBearerStaticClient returns an HTTP client that sends the configured bearer token. It's intended for development only.
+func newBearerStaticClient(token string) *http.Client {
+	slog.Warn("FHIR client is configured with a static bearer token, which should only be used for development")
+	return oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{
+		AccessToken: token,
+		TokenType:   "Bearer",
+	}))
+}
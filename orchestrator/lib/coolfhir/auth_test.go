@@ -0,0 +1,145 @@
+package coolfhir
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+func TestNewAuthRoundTripper_OAuth2ClientCredentials(t *testing.T) {
+	var tokenRequests int
+	var capturedAuthorization string
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+		clientID, clientSecret, _ := r.BasicAuth()
+		assert.Equal(t, "orca", clientID)
+		assert.Equal(t, "secret", clientSecret)
+		assert.Equal(t, "client_credentials", r.PostFormValue("grant_type"))
+		assert.Equal(t, "fhir", r.PostFormValue("scope"))
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "token-1", "token_type": "bearer", "expires_in": 300})
+	})
+	mux.HandleFunc("GET /fhir/Patient/1", func(w http.ResponseWriter, r *http.Request) {
+		capturedAuthorization = r.Header.Get("Authorization")
+		SendResponse(w, http.StatusOK, fhir.Patient{})
+	})
+	config := ClientConfig{
+		BaseURL: server.URL + "/fhir",
+		Auth: AuthConfig{
+			Type:          OAuth2ClientCredentials,
+			TokenEndpoint: server.URL + "/token",
+			ClientID:      "orca",
+			ClientSecret:  "secret",
+			OAuth2Scopes:  "fhir",
+		},
+	}
+	require.NoError(t, config.Validate())
+
+	for i := 0; i < 2; i++ {
+		_, fhirClient, err := NewAuthRoundTripper(config, &fhirclient.Config{})
+		require.NoError(t, err)
+		var patient fhir.Patient
+		require.NoError(t, fhirClient.Read("Patient/1", &patient))
+	}
+
+	assert.Equal(t, "Bearer token-1", capturedAuthorization)
+	assert.Equal(t, 1, tokenRequests, "access token should be cached")
+}
+
+func TestNewAuthRoundTripper_MutualTLS(t *testing.T) {
+	var capturedClientCertificates int
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		capturedClientCertificates = len(r.TLS.PeerCertificates)
+		SendResponse(w, http.StatusOK, fhir.Patient{})
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+	// Use the server's own certificate as client certificate, and as trusted CA
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	serverCertificate := server.TLS.Certificates[0]
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: serverCertificate.Certificate[0]}), 0600))
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(serverCertificate.PrivateKey)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes}), 0600))
+	config := ClientConfig{
+		BaseURL: server.URL,
+		Auth: AuthConfig{
+			Type: MutualTLS,
+			ClientCertificate: ClientCertificateConfig{
+				CertFile: certFile,
+				KeyFile:  keyFile,
+				CAFile:   certFile,
+			},
+		},
+	}
+	require.NoError(t, config.Validate())
+
+	_, fhirClient, err := NewAuthRoundTripper(config, &fhirclient.Config{})
+	require.NoError(t, err)
+	var patient fhir.Patient
+	require.NoError(t, fhirClient.Read("Patient/1", &patient))
+
+	assert.Equal(t, 1, capturedClientCertificates)
+}
+
+func TestNewAuthRoundTripper_BearerStatic(t *testing.T) {
+	var capturedAuthorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		capturedAuthorization = r.Header.Get("Authorization")
+		SendResponse(w, http.StatusOK, fhir.Patient{})
+	}))
+	defer server.Close()
+
+	_, fhirClient, err := NewAuthRoundTripper(ClientConfig{
+		BaseURL: server.URL,
+		Auth:    AuthConfig{Type: BearerStatic, BearerToken: "dev-token"},
+	}, &fhirclient.Config{})
+	require.NoError(t, err)
+	var patient fhir.Patient
+	require.NoError(t, fhirClient.Read("Patient/1", &patient))
+
+	assert.Equal(t, "Bearer dev-token", capturedAuthorization)
+}
+
+func TestAuthConfig_validate(t *testing.T) {
+	t.Run("oauth2-client-credentials", func(t *testing.T) {
+		assert.ErrorContains(t, AuthConfig{Type: OAuth2ClientCredentials, ClientID: "orca"}.validate(), "auth.tokenendpoint and auth.clientid are required")
+		assert.ErrorContains(t, AuthConfig{Type: OAuth2ClientCredentials, ClientID: "orca", TokenEndpoint: "https://example.com"}.validate(), "requires auth.clientsecret or auth.signingkey")
+		assert.NoError(t, AuthConfig{Type: OAuth2ClientCredentials, ClientID: "orca", TokenEndpoint: "https://example.com", SigningKey: SigningKeyConfig{PEMFile: "key.pem"}}.validate())
+	})
+	t.Run("mtls", func(t *testing.T) {
+		assert.ErrorContains(t, AuthConfig{Type: MutualTLS}.validate(), "certfile and keyfile are required")
+		assert.ErrorContains(t, AuthConfig{Type: MutualTLS, ClientCertificate: ClientCertificateConfig{AzureKeyVault: AzureKeyVaultCertificateConfig{URL: "https://example.com"}}}.validate(), "azurekv.certificatename is required")
+	})
+	t.Run("bearer-static", func(t *testing.T) {
+		assert.ErrorContains(t, AuthConfig{Type: BearerStatic}.validate(), "auth.bearertoken is required")
+		assert.NoError(t, AuthConfig{Type: BearerStatic, BearerToken: "dev-token"}.validate())
+	})
+	t.Run("bearer-static in strict mode", func(t *testing.T) {
+		StrictMode = true
+		defer func() {
+			StrictMode = false
+		}()
+		assert.EqualError(t, AuthConfig{Type: BearerStatic, BearerToken: "dev-token"}.validate(), "bearer-static authentication is not allowed in strict mode")
+	})
+	t.Run("unsupported", func(t *testing.T) {
+		assert.EqualError(t, AuthConfig{Type: "basic"}.validate(), "invalid FHIR authentication type: basic")
+	})
+}
@@ -80,7 +80,7 @@ func (c ClientConfig) Validate() error {
 			return fmt.Errorf("invalid FHIR base URL: %w", err)
 		}
 	}
-	return nil
+	return c.Auth.validate()
 }
 
 type AuthConfigType string
@@ -88,23 +88,44 @@ type AuthConfigType string
 const (
 	Default              AuthConfigType = ""
 	AzureManagedIdentity AuthConfigType = "azure-managedidentity"
+	SmartBackendServices AuthConfigType = "smart-backend-services"
+	// OAuth2ClientCredentials authenticates using an access token obtained through the OAuth2 client credentials grant.
+	OAuth2ClientCredentials AuthConfigType = "oauth2-client-credentials"
+	// MutualTLS authenticates using a TLS client certificate.
+	MutualTLS AuthConfigType = "mtls"
+	// BearerStatic authenticates using a fixed bearer token, intended for development only.
+	BearerStatic AuthConfigType = "bearer-static"
 )
 
 type AuthConfig struct {
-	// Type of authentication to use, supported options: azure-managedidentity.
+	// Type of authentication to use, supported options: azure-managedidentity, smart-backend-services,
+	// oauth2-client-credentials, mtls, bearer-static.
 	// Leave empty for no authentication.
 	Type         AuthConfigType `koanf:"type"`
 	OAuth2Scopes string         `koanf:"scopes"`
+	// ClientID is the OAuth2 client ID, used for smart-backend-services and oauth2-client-credentials.
+	ClientID string `koanf:"clientid"`
+	// ClientSecret is the OAuth2 client secret, used for oauth2-client-credentials.
+	// If not set, the client authenticates using a JWT assertion signed with SigningKey.
+	ClientSecret string `koanf:"clientsecret"`
+	// TokenEndpoint is the OAuth2 token endpoint, used for smart-backend-services and oauth2-client-credentials.
+	// For smart-backend-services it's optional: if not set, it's discovered through the FHIR server's .well-known/smart-configuration.
+	TokenEndpoint string `koanf:"tokenendpoint"`
+	// SigningKey is the key used to sign client assertions, used for smart-backend-services and oauth2-client-credentials.
+	SigningKey SigningKeyConfig `koanf:"signingkey"`
+	// ClientCertificate is the TLS client certificate, used for mtls.
+	ClientCertificate ClientCertificateConfig `koanf:"clientcert"`
+	// BearerToken is the token sent as bearer token, used for bearer-static.
+	BearerToken string `koanf:"bearertoken"`
 }
 
 func NewAuthRoundTripper(config ClientConfig, fhirClientConfig *fhirclient.Config) (http.RoundTripper, fhirclient.Client, error) {
-	var transport http.RoundTripper
-	var fhirClient fhirclient.Client
 	fhirURL, err := url.Parse(config.BaseURL)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	var httpClient *http.Client
 	switch config.Auth.Type {
 	case AzureManagedIdentity:
 		opts := &azidentity.ManagedIdentityCredentialOptions{
@@ -125,56 +146,55 @@ func NewAuthRoundTripper(config ClientConfig, fhirClientConfig *fhirclient.Confi
 		if err != nil {
 			return nil, nil, fmt.Errorf("unable to get credential for Azure FHIR API client: %w", err)
 		}
-		httpClient := NewAzureHTTPClient(credential, scopes)
-
-		// Wrap the transport with OTEL instrumentation
-		transport = otelhttp.NewTransport(
-			httpClient.Transport,
-			otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
-				return fmt.Sprintf("fhir.%s %s", strings.ToLower(r.Method), r.URL.Path)
-			}),
-			otelhttp.WithSpanOptions(
-				trace.WithSpanKind(trace.SpanKindClient),
-				trace.WithAttributes(
-					attribute.String(otel.FHIRBaseURL, fhirURL.String()),
-					attribute.String("fhir.auth_type", string(config.Auth.Type)),
-					attribute.String("service.component", "fhir-client"),
-				),
-			),
-		)
-
-		// Create an instrumented HTTP client
-		instrumentedClient := &http.Client{
-			Transport: transport,
-			Timeout:   httpClient.Timeout,
+		httpClient = NewAzureHTTPClient(credential, scopes)
+	case SmartBackendServices:
+		tokenSource, err := newSmartBackendServicesTokenSource(fhirURL, config.Auth)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to create SMART Backend Services token source: %w", err)
 		}
-
-		fhirClient = fhirclient.New(fhirURL, instrumentedClient, fhirClientConfig)
-	case Default:
-		// Wrap default transport with OTEL instrumentation
-		transport = otelhttp.NewTransport(
-			http.DefaultTransport,
-			otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
-				return fmt.Sprintf("fhir.%s %s", strings.ToLower(r.Method), r.URL.Path)
-			}),
-			otelhttp.WithSpanOptions(
-				trace.WithSpanKind(trace.SpanKindClient),
-				trace.WithAttributes(
-					attribute.String(otel.FHIRBaseURL, fhirURL.String()),
-					attribute.String("fhir.auth_type", string(config.Auth.Type)),
-					attribute.String("service.component", "fhir-client"),
-				),
-			),
-		)
-
-		instrumentedClient := &http.Client{
-			Transport: transport,
+		httpClient = oauth2.NewClient(context.Background(), tokenSource)
+	case OAuth2ClientCredentials:
+		tokenSource, err := NewOAuth2ClientCredentialsTokenSource(config.Auth)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to create OAuth2 client credentials token source: %w", err)
 		}
-
-		fhirClient = fhirclient.New(fhirURL, instrumentedClient, fhirClientConfig)
+		httpClient = oauth2.NewClient(context.Background(), tokenSource)
+	case MutualTLS:
+		tlsTransport, err := NewMutualTLSTransport(config.Auth.ClientCertificate)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to create mTLS transport: %w", err)
+		}
+		httpClient = &http.Client{Transport: tlsTransport}
+	case BearerStatic:
+		httpClient = newBearerStaticClient(config.Auth.BearerToken)
+	case Default:
+		httpClient = &http.Client{Transport: http.DefaultTransport}
 	default:
 		return nil, nil, fmt.Errorf("invalid FHIR authentication type: %s", config.Auth.Type)
 	}
 
+	// Wrap the transport with OTEL instrumentation
+	transport := otelhttp.NewTransport(
+		httpClient.Transport,
+		otel.WithURLRedaction(),
+		otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+			return fmt.Sprintf("fhir.%s %s", strings.ToLower(r.Method), r.URL.Path)
+		}),
+		otelhttp.WithSpanOptions(
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String(otel.FHIRBaseURL, fhirURL.String()),
+				attribute.String("fhir.auth_type", string(config.Auth.Type)),
+				attribute.String("service.component", "fhir-client"),
+			),
+		),
+	)
+
+	// Create an instrumented HTTP client
+	instrumentedClient := &http.Client{
+		Transport: transport,
+		Timeout:   httpClient.Timeout,
+	}
+	fhirClient := fhirclient.New(fhirURL, instrumentedClient, fhirClientConfig)
 	return transport, fhirClient, nil
 }
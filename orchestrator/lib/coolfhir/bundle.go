@@ -140,6 +140,7 @@ func WithFullUrl(fullUrl string) BundleEntryPreOption {
 // AuditEventInfo contains information needed to create an AuditEvent
 type AuditEventInfo struct {
 	ActingAgent      *fhir.Reference         // Who initiated the action, the acting agent
+	ActingUser       *fhir.AuditEventAgent   // The natural person who initiated the action on behalf of the acting agent, if known
 	Observer         fhir.Identifier         // Who observed the action, the local identity
 	Action           fhir.AuditEventAction   // What action was performed (e.g., "create", "update")
 	Metadata         map[string]string       // Additional metadata for the audit event
@@ -252,6 +253,9 @@ func WithAuditEvent(ctx context.Context, t *BundleBuilder, info AuditEventInfo)
 			},
 		}
 
+		if info.ActingUser != nil {
+			auditEvent.Agent = append(auditEvent.Agent, *info.ActingUser)
+		}
 		auditEvent.Entity = append(auditEvent.Entity, info.AdditionalEntity...)
 
 		if info.QueryParams != nil {
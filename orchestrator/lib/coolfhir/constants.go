@@ -8,6 +8,9 @@ const URANamingSystem = "http://fhir.nl/fhir/NamingSystem/ura"
 // BSNNamingSystem is the FHIR NamingSystem URI for the Dutch Social Security Number
 const BSNNamingSystem = "http://fhir.nl/fhir/NamingSystem/bsn"
 
+// PatientPseudonymNamingSystem is the FHIR NamingSystem URI for pseudonymous patient identifiers, issued per CarePlan by the Care Plan Service.
+const PatientPseudonymNamingSystem = "http://santeonnl.github.io/shared-care-planning/NamingSystem/patient-pseudonym"
+
 // FHIRContentType is the content-type for FHIR payloads
 const FHIRContentType = "application/fhir+json"
 
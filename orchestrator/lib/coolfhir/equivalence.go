@@ -0,0 +1,74 @@
+package coolfhir
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+var identifierEquivalences = struct {
+	groups map[string][]fhir.Identifier
+	mux    sync.RWMutex
+}{
+	groups: map[string][]fhir.Identifier{},
+}
+
+// SetIdentifierEquivalences configures groups of identifiers that identify the same entity, in different identifier systems
+// (e.g. the URA and AGB code of the same organization). Identifiers in the same group are considered equal by IdentifierEquals.
+// Each group is specified as a list of FHIR search tokens (<system>|<value>). It replaces any previously configured equivalences.
+func SetIdentifierEquivalences(groups [][]string) error {
+	result := map[string][]fhir.Identifier{}
+	for _, group := range groups {
+		var identifiers []fhir.Identifier
+		for _, token := range group {
+			identifier, err := TokenToIdentifier(token)
+			if err != nil || !IsLogicalIdentifier(identifier) {
+				return fmt.Errorf("invalid identifier (expected <system>|<value>): %s", token)
+			}
+			identifiers = append(identifiers, *identifier)
+		}
+		for _, identifier := range identifiers {
+			key := IdentifierToToken(identifier)
+			if _, exists := result[key]; exists {
+				return fmt.Errorf("identifier is part of multiple equivalence groups: %s", key)
+			}
+			result[key] = identifiers
+		}
+	}
+	identifierEquivalences.mux.Lock()
+	defer identifierEquivalences.mux.Unlock()
+	identifierEquivalences.groups = result
+	return nil
+}
+
+// EquivalentIdentifiers returns the identifiers that are configured to be equivalent to the given identifier (excluding the identifier itself).
+func EquivalentIdentifiers(identifier fhir.Identifier) []fhir.Identifier {
+	if !IsLogicalIdentifier(&identifier) {
+		return nil
+	}
+	identifierEquivalences.mux.RLock()
+	group := identifierEquivalences.groups[IdentifierToToken(identifier)]
+	identifierEquivalences.mux.RUnlock()
+	var result []fhir.Identifier
+	for _, other := range group {
+		if *other.System != *identifier.System || *other.Value != *identifier.Value {
+			result = append(result, other)
+		}
+	}
+	return result
+}
+
+// EquivalentIdentifierOfSystem returns the identifier of the given system that is equivalent to the given identifier.
+// If the identifier itself is of the given system, it is returned as-is. If there is no such identifier, it returns nil.
+func EquivalentIdentifierOfSystem(identifier fhir.Identifier, system string) *fhir.Identifier {
+	if identifier.System != nil && *identifier.System == system {
+		return &identifier
+	}
+	for _, other := range EquivalentIdentifiers(identifier) {
+		if *other.System == system {
+			return &other
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package coolfhir
+
+import (
+	"testing"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+func TestSetIdentifierEquivalences(t *testing.T) {
+	const agbSystem = "http://fhir.nl/fhir/NamingSystem/agb-z"
+	ura := fhir.Identifier{System: to.Ptr(URANamingSystem), Value: to.Ptr("1")}
+	agb := fhir.Identifier{System: to.Ptr(agbSystem), Value: to.Ptr("01234567")}
+	otherURA := fhir.Identifier{System: to.Ptr(URANamingSystem), Value: to.Ptr("2")}
+	t.Cleanup(func() {
+		_ = SetIdentifierEquivalences(nil)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		err := SetIdentifierEquivalences([][]string{{URANamingSystem + "|1", agbSystem + "|01234567"}})
+		require.NoError(t, err)
+
+		require.Equal(t, []fhir.Identifier{agb}, EquivalentIdentifiers(ura))
+		require.Equal(t, []fhir.Identifier{ura}, EquivalentIdentifiers(agb))
+		require.Empty(t, EquivalentIdentifiers(otherURA))
+		t.Run("IdentifierEquals", func(t *testing.T) {
+			require.True(t, IdentifierEquals(&ura, &agb))
+			require.True(t, IdentifierEquals(&agb, &ura))
+			require.False(t, IdentifierEquals(&agb, &otherURA))
+		})
+		t.Run("LogicalReferenceEquals", func(t *testing.T) {
+			require.True(t, LogicalReferenceEquals(fhir.Reference{Identifier: &agb}, fhir.Reference{Identifier: &ura}))
+		})
+		t.Run("FindMatchingParticipantInCareTeam", func(t *testing.T) {
+			careTeam := fhir.CareTeam{
+				Participant: []fhir.CareTeamParticipant{{Member: &fhir.Reference{Identifier: &ura}}},
+			}
+			require.NotNil(t, FindMatchingParticipantInCareTeam(&careTeam, []fhir.Identifier{agb}))
+		})
+		t.Run("EquivalentIdentifierOfSystem", func(t *testing.T) {
+			require.Equal(t, &ura, EquivalentIdentifierOfSystem(agb, URANamingSystem))
+			require.Equal(t, &ura, EquivalentIdentifierOfSystem(ura, URANamingSystem))
+			require.Nil(t, EquivalentIdentifierOfSystem(otherURA, agbSystem))
+		})
+	})
+	t.Run("invalid identifier", func(t *testing.T) {
+		err := SetIdentifierEquivalences([][]string{{"foo"}})
+		require.EqualError(t, err, "invalid identifier (expected <system>|<value>): foo")
+	})
+	t.Run("identifier in multiple groups", func(t *testing.T) {
+		err := SetIdentifierEquivalences([][]string{
+			{URANamingSystem + "|1", agbSystem + "|01234567"},
+			{URANamingSystem + "|1", agbSystem + "|7654321"},
+		})
+		require.EqualError(t, err, "identifier is part of multiple equivalence groups: "+URANamingSystem+"|1")
+	})
+}
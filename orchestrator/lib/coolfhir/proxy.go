@@ -54,7 +54,7 @@ func (f *FHIRClientProxy) ServeHTTP(httpResponseWriter http.ResponseWriter, requ
 		trace.WithSpanKind(trace.SpanKindServer),
 		trace.WithAttributes(
 			attribute.String(otel.HTTPMethod, request.Method),
-			attribute.String(otel.HTTPURL, request.URL.String()),
+			attribute.String(otel.HTTPURL, otel.RedactURL(request.URL)),
 			attribute.String("proxy.base_path", f.proxyBasePath),
 		),
 	)
@@ -307,7 +307,7 @@ func (l LoggingRoundTripper) RoundTrip(request *http.Request) (*http.Response, e
 		trace.WithSpanKind(trace.SpanKindServer),
 		trace.WithAttributes(
 			attribute.String(otel.HTTPMethod, request.Method),
-			attribute.String(otel.HTTPURL, request.URL.String()),
+			attribute.String(otel.HTTPURL, otel.RedactURL(request.URL)),
 		),
 	)
 	defer span.End()
@@ -0,0 +1,293 @@
+package coolfhir
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/az/azkeyvault"
+	"github.com/SanteonNL/orca/orchestrator/lib/otel"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/cryptosigner"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+)
+
+// DefaultSmartBackendServicesScope is requested when no scopes are configured for SMART Backend Services authentication.
+const DefaultSmartBackendServicesScope = "system/*.read"
+
+const smartClientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+const smartClientAssertionExpiry = 5 * time.Minute
+
+// SmartBackendServicesHTTPClient is the HTTP client used to discover the token endpoint and request access tokens.
+// It can be overridden in tests.
+var SmartBackendServicesHTTPClient = otel.NewTracedHTTPClient("smart-backend-services")
+
+// SigningKeyConfig configures the key used to sign JWTs, either sourced from a PEM file or from Azure Key Vault.
+type SigningKeyConfig struct {
+	// PEMFile is the path to a PEM file containing an RSA or EC private key.
+	PEMFile string `koanf:"pemfile"`
+	// KeyID is the key ID (kid) of the key from the PEM file, as registered at the authorization server.
+	// If not set, the SHA-256 JWK thumbprint of the key is used.
+	KeyID         string                 `koanf:"keyid"`
+	AzureKeyVault AzureKeyVaultKeyConfig `koanf:"azurekv"`
+}
+
+type AzureKeyVaultKeyConfig struct {
+	URL            string `koanf:"url"`
+	CredentialType string `koanf:"credentialtype"`
+	KeyName        string `koanf:"keyname"`
+}
+
+func (c SigningKeyConfig) Validate() error {
+	if (c.PEMFile == "") == (c.AzureKeyVault.URL == "") {
+		return errors.New("either a PEM file or an Azure Key Vault must be configured for the signing key")
+	}
+	if c.AzureKeyVault.URL != "" && c.AzureKeyVault.KeyName == "" {
+		return errors.New("azurekv.keyname is required")
+	}
+	return nil
+}
+
+// newSmartBackendServicesTokenSource returns a (cached) token source that requests access tokens using SMART Backend Services:
+// a JWT client assertion signed with the configured key is exchanged for an access token using the client_credentials grant.
+// See https://hl7.org/fhir/smart-app-launch/backend-services.html
+func newSmartBackendServicesTokenSource(fhirBaseURL *url.URL, config AuthConfig) (oauth2.TokenSource, error) {
+	scope := config.OAuth2Scopes
+	if scope == "" {
+		scope = DefaultSmartBackendServicesScope
+	}
+	tokenEndpoint := config.TokenEndpoint
+	if tokenEndpoint == "" {
+		tokenEndpoint = fhirBaseURL.String()
+	}
+	cacheKey := strings.Join([]string{string(SmartBackendServices), tokenEndpoint, config.ClientID, scope}, "|")
+	return cachedTokenSource(cacheKey, func() (oauth2.TokenSource, error) {
+		signingKey, err := loadSigningKey(config.SigningKey)
+		if err != nil {
+			return nil, err
+		}
+		return &smartBackendServicesTokenSource{
+			fhirBaseURL:   fhirBaseURL,
+			tokenEndpoint: config.TokenEndpoint,
+			clientID:      config.ClientID,
+			scope:         scope,
+			signingKey:    signingKey,
+		}, nil
+	})
+}
+
+type smartBackendServicesTokenSource struct {
+	fhirBaseURL   *url.URL
+	clientID      string
+	scope         string
+	signingKey    jose.SigningKey
+	mux           sync.Mutex
+	tokenEndpoint string
+}
+
+func (s *smartBackendServicesTokenSource) Token() (*oauth2.Token, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	tokenEndpoint, err := s.resolveTokenEndpoint(ctx)
+	if err != nil {
+		return nil, err
+	}
+	assertion, err := s.createClientAssertion(tokenEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type":            []string{"client_credentials"},
+		"client_assertion_type": []string{smartClientAssertionType},
+		"client_assertion":      []string{assertion},
+	}
+	if s.scope != "" {
+		form.Set("scope", s.scope)
+	}
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	httpRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpRequest.Header.Set("Accept", "application/json")
+	httpResponse, err := SmartBackendServicesHTTPClient.Do(httpRequest)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer httpResponse.Body.Close()
+	if httpResponse.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token request failed (status=%d)", httpResponse.StatusCode)
+	}
+	var tokenResponse struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(httpResponse.Body).Decode(&tokenResponse); err != nil {
+		return nil, fmt.Errorf("invalid token response: %w", err)
+	}
+	if tokenResponse.AccessToken == "" {
+		return nil, errors.New("token response doesn't contain an access token")
+	}
+	result := &oauth2.Token{
+		AccessToken: tokenResponse.AccessToken,
+		TokenType:   "Bearer",
+	}
+	if tokenResponse.ExpiresIn > 0 {
+		result.Expiry = time.Now().Add(time.Duration(tokenResponse.ExpiresIn) * time.Second)
+	}
+	return result, nil
+}
+
+// resolveTokenEndpoint returns the configured token endpoint, or discovers it through the FHIR server's SMART configuration.
+func (s *smartBackendServicesTokenSource) resolveTokenEndpoint(ctx context.Context) (string, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.tokenEndpoint != "" {
+		return s.tokenEndpoint, nil
+	}
+	discoveryURL := s.fhirBaseURL.JoinPath(".well-known", "smart-configuration")
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	httpRequest.Header.Set("Accept", "application/json")
+	httpResponse, err := SmartBackendServicesHTTPClient.Do(httpRequest)
+	if err != nil {
+		return "", fmt.Errorf("SMART Backend Services: failed to discover token endpoint: %w", err)
+	}
+	defer httpResponse.Body.Close()
+	if httpResponse.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("SMART Backend Services: failed to discover token endpoint (status=%d)", httpResponse.StatusCode)
+	}
+	var configuration struct {
+		TokenEndpoint string `json:"token_endpoint"`
+	}
+	if err := json.NewDecoder(httpResponse.Body).Decode(&configuration); err != nil {
+		return "", fmt.Errorf("SMART Backend Services: invalid SMART configuration: %w", err)
+	}
+	if configuration.TokenEndpoint == "" {
+		return "", errors.New("SMART Backend Services: SMART configuration doesn't contain a token endpoint")
+	}
+	s.tokenEndpoint = configuration.TokenEndpoint
+	return s.tokenEndpoint, nil
+}
+
+func (s *smartBackendServicesTokenSource) createClientAssertion(tokenEndpoint string) (string, error) {
+	signer, err := jose.NewSigner(s.signingKey, (&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		return "", fmt.Errorf("failed to create JWT signer: %w", err)
+	}
+	now := time.Now()
+	claims := jwt.Claims{
+		Issuer:   s.clientID,
+		Subject:  s.clientID,
+		Audience: jwt.Audience{tokenEndpoint},
+		IssuedAt: jwt.NewNumericDate(now),
+		Expiry:   jwt.NewNumericDate(now.Add(smartClientAssertionExpiry)),
+		ID:       uuid.NewString(),
+	}
+	result, err := jwt.Signed(signer).Claims(claims).Serialize()
+	if err != nil {
+		return "", fmt.Errorf("failed to sign client assertion: %w", err)
+	}
+	return result, nil
+}
+
+// loadSigningKey loads the key for signing client assertions. The key ID is set as 'kid' header.
+func loadSigningKey(config SigningKeyConfig) (jose.SigningKey, error) {
+	if config.AzureKeyVault.URL != "" {
+		credentialType := config.AzureKeyVault.CredentialType
+		if credentialType == "" {
+			credentialType = "managed_identity"
+		}
+		keysClient, err := azkeyvault.NewKeysClient(config.AzureKeyVault.URL, credentialType, false)
+		if err != nil {
+			return jose.SigningKey{}, err
+		}
+		key, err := azkeyvault.GetKey(keysClient, config.AzureKeyVault.KeyName)
+		if err != nil {
+			return jose.SigningKey{}, fmt.Errorf("failed to get key (name: %s): %w", config.AzureKeyVault.KeyName, err)
+		}
+		return jose.SigningKey{
+			Algorithm: jose.SignatureAlgorithm(key.SigningAlgorithm()),
+			Key: jose.JSONWebKey{
+				Key:   cryptosigner.Opaque(key),
+				KeyID: hex.EncodeToString(key.PublicKeyThumbprintS256()),
+			},
+		}, nil
+	}
+	data, err := os.ReadFile(config.PEMFile)
+	if err != nil {
+		return jose.SigningKey{}, fmt.Errorf("failed to read signing key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return jose.SigningKey{}, fmt.Errorf("signing key file does not contain a PEM block: %s", config.PEMFile)
+	}
+	privateKey, err := parsePrivateKey(block.Bytes)
+	if err != nil {
+		return jose.SigningKey{}, err
+	}
+	var algorithm jose.SignatureAlgorithm
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		algorithm = jose.RS384
+	case *ecdsa.PrivateKey:
+		switch key.Curve {
+		case elliptic.P256():
+			algorithm = jose.ES256
+		case elliptic.P384():
+			algorithm = jose.ES384
+		case elliptic.P521():
+			algorithm = jose.ES512
+		}
+	}
+	if algorithm == "" {
+		return jose.SigningKey{}, errors.New("unsupported signing key type, expected RSA or EC (P-256, P-384, P-521)")
+	}
+	keyID := config.KeyID
+	if keyID == "" {
+		thumbprint, err := (&jose.JSONWebKey{Key: privateKey.Public()}).Thumbprint(crypto.SHA256)
+		if err != nil {
+			return jose.SigningKey{}, err
+		}
+		keyID = hex.EncodeToString(thumbprint)
+	}
+	return jose.SigningKey{
+		Algorithm: algorithm,
+		Key:       jose.JSONWebKey{Key: privateKey, KeyID: keyID},
+	}, nil
+}
+
+func parsePrivateKey(der []byte) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		if signer, ok := key.(crypto.Signer); ok {
+			return signer, nil
+		}
+		return nil, errors.New("unsupported PKCS#8 signing key")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("failed to parse signing key, expected PKCS#8, PKCS#1 or SEC 1 encoded private key")
+}
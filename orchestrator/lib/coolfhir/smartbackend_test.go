@@ -0,0 +1,111 @@
+package coolfhir
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+func TestNewAuthRoundTripper_SmartBackendServices(t *testing.T) {
+	privateKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+	keyFile := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes}), 0600))
+
+	var tokenRequests int
+	var capturedAssertion jwt.Claims
+	var capturedScope string
+	var capturedAuthorization string
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	mux.HandleFunc("GET /fhir/.well-known/smart-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"token_endpoint": server.URL + "/token"})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+		capturedScope = r.PostFormValue("scope")
+		assert.Equal(t, "client_credentials", r.PostFormValue("grant_type"))
+		assert.Equal(t, smartClientAssertionType, r.PostFormValue("client_assertion_type"))
+		token, err := jwt.ParseSigned(r.PostFormValue("client_assertion"), []jose.SignatureAlgorithm{jose.ES384})
+		require.NoError(t, err)
+		require.NoError(t, token.Claims(privateKey.Public(), &capturedAssertion))
+		assert.Equal(t, "my-key", token.Headers[0].KeyID)
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "token-1", "token_type": "bearer", "expires_in": 300})
+	})
+	mux.HandleFunc("GET /fhir/Patient/1", func(w http.ResponseWriter, r *http.Request) {
+		capturedAuthorization = r.Header.Get("Authorization")
+		SendResponse(w, http.StatusOK, fhir.Patient{})
+	})
+
+	config := ClientConfig{
+		BaseURL: server.URL + "/fhir",
+		Auth: AuthConfig{
+			Type:     SmartBackendServices,
+			ClientID: "orca",
+			SigningKey: SigningKeyConfig{
+				PEMFile: keyFile,
+				KeyID:   "my-key",
+			},
+		},
+	}
+	require.NoError(t, config.Validate())
+
+	_, fhirClient, err := NewAuthRoundTripper(config, &fhirclient.Config{})
+	require.NoError(t, err)
+	var patient fhir.Patient
+	require.NoError(t, fhirClient.Read("Patient/1", &patient))
+
+	assert.Equal(t, "Bearer token-1", capturedAuthorization)
+	assert.Equal(t, DefaultSmartBackendServicesScope, capturedScope)
+	assert.Equal(t, "orca", capturedAssertion.Issuer)
+	assert.Equal(t, "orca", capturedAssertion.Subject)
+	assert.Equal(t, jwt.Audience{server.URL + "/token"}, capturedAssertion.Audience)
+	assert.NotEmpty(t, capturedAssertion.ID)
+	t.Run("token is reused by other clients for the same EHR", func(t *testing.T) {
+		_, otherClient, err := NewAuthRoundTripper(config, &fhirclient.Config{})
+		require.NoError(t, err)
+		require.NoError(t, otherClient.Read("Patient/1", &patient))
+		assert.Equal(t, 1, tokenRequests)
+	})
+	t.Run("tokens are cached per scope", func(t *testing.T) {
+		otherConfig := config
+		otherConfig.Auth.OAuth2Scopes = "system/Patient.read"
+		_, otherClient, err := NewAuthRoundTripper(otherConfig, &fhirclient.Config{})
+		require.NoError(t, err)
+		require.NoError(t, otherClient.Read("Patient/1", &patient))
+		assert.Equal(t, 2, tokenRequests)
+		assert.Equal(t, "system/Patient.read", capturedScope)
+	})
+}
+
+func TestClientConfig_Validate_SmartBackendServices(t *testing.T) {
+	t.Run("missing client ID", func(t *testing.T) {
+		err := ClientConfig{Auth: AuthConfig{Type: SmartBackendServices, SigningKey: SigningKeyConfig{PEMFile: "key.pem"}}}.Validate()
+		assert.EqualError(t, err, "auth.clientid is required for smart-backend-services")
+	})
+	t.Run("missing signing key", func(t *testing.T) {
+		err := ClientConfig{Auth: AuthConfig{Type: SmartBackendServices, ClientID: "orca"}}.Validate()
+		assert.ErrorContains(t, err, "either a PEM file or an Azure Key Vault must be configured")
+	})
+	t.Run("Azure Key Vault without key name", func(t *testing.T) {
+		err := ClientConfig{Auth: AuthConfig{Type: SmartBackendServices, ClientID: "orca", SigningKey: SigningKeyConfig{AzureKeyVault: AzureKeyVaultKeyConfig{URL: "https://example.com"}}}}.Validate()
+		assert.ErrorContains(t, err, "azurekv.keyname is required")
+	})
+}
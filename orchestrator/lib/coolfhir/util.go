@@ -89,6 +89,33 @@ func ValidateCareTeamParticipantPeriod(participant fhir.CareTeamParticipant, now
 	return true, nil
 }
 
+// PeriodContains returns whether the given time falls within the given period.
+// A nil period, or a period without start or end, is considered unbounded on that side.
+func PeriodContains(period *fhir.Period, now time.Time) (bool, error) {
+	if period == nil {
+		return true, nil
+	}
+	if period.Start != nil {
+		startTime, err := parseTimestamp(*period.Start)
+		if err != nil {
+			return false, err
+		}
+		if now.Before(startTime) {
+			return false, nil
+		}
+	}
+	if period.End != nil {
+		endTime, err := parseTimestamp(*period.End)
+		if err != nil {
+			return false, err
+		}
+		if !now.Before(endTime) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
 // ValidateTaskRequiredFields Validates that all required fields are set for a Task (i.e. a cardinality of 1..*) as per: https://santeonnl.github.io/shared-care-planning/StructureDefinition-SCPTask.html
 // and that the value is valid
 func ValidateTaskRequiredFields(task fhir.Task) error {
@@ -212,11 +239,9 @@ func IsLogicalIdentifier(identifier *fhir.Identifier) bool {
 }
 
 // LogicalReferenceEquals checks if two references are contain the same logical identifier, given their system and value.
-// It does not compare identifier type.
+// It does not compare identifier type. Configured identifier equivalences (see SetIdentifierEquivalences) are taken into account.
 func LogicalReferenceEquals(ref, other fhir.Reference) bool {
-	return ref.Identifier != nil && other.Identifier != nil &&
-		ref.Identifier.System != nil && other.Identifier.System != nil && *ref.Identifier.System == *other.Identifier.System &&
-		ref.Identifier.Value != nil && other.Identifier.Value != nil && *ref.Identifier.Value == *other.Identifier.Value
+	return IdentifierEquals(ref.Identifier, other.Identifier)
 }
 
 // ReferenceValueEquals checks if two references are equal based on their reference and type.
@@ -228,6 +253,7 @@ func ReferenceValueEquals(ref, other fhir.Reference) bool {
 // IdentifierEquals compares two logical identifiers based on their system and value.
 // If any of the identifiers is nil or any of the system or value fields is nil, it returns false.
 // If the system and value fields of both identifiers are equal, it returns true.
+// Identifiers of different systems are also considered equal if they're configured to be equivalent (see SetIdentifierEquivalences).
 func IdentifierEquals(one *fhir.Identifier, other *fhir.Identifier) bool {
 	if one == nil || other == nil {
 		return false
@@ -238,7 +264,15 @@ func IdentifierEquals(one *fhir.Identifier, other *fhir.Identifier) bool {
 	if one.Value == nil || other.Value == nil {
 		return false
 	}
-	return *one.System == *other.System && *one.Value == *other.Value
+	if *one.System == *other.System && *one.Value == *other.Value {
+		return true
+	}
+	for _, equivalent := range EquivalentIdentifiers(*one) {
+		if *equivalent.System == *other.System && *equivalent.Value == *other.Value {
+			return true
+		}
+	}
+	return false
 }
 
 // HasIdentifier returns whether a slice of fhir.Identifier contains a specific identifier.
@@ -1099,6 +1099,34 @@ func TestParseTimestamp_UnsupportedFormat(t *testing.T) {
 	assert.Contains(t, err.Error(), "unsupported timestamp format")
 }
 
+func TestPeriodContains(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	t.Run("nil period", func(t *testing.T) {
+		ok, err := PeriodContains(nil, now)
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+	t.Run("within period", func(t *testing.T) {
+		ok, err := PeriodContains(&fhir.Period{Start: to.Ptr("2024-01-01"), End: to.Ptr("2025-01-01T00:00:00Z")}, now)
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+	t.Run("not started", func(t *testing.T) {
+		ok, err := PeriodContains(&fhir.Period{Start: to.Ptr("2024-07-01")}, now)
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+	t.Run("ended", func(t *testing.T) {
+		ok, err := PeriodContains(&fhir.Period{End: to.Ptr("2024-06-01")}, now)
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		_, err := PeriodContains(&fhir.Period{Start: to.Ptr("2024")}, now)
+		require.EqualError(t, err, "unsupported timestamp format")
+	})
+}
+
 func TestParseLocalReference_NoType(t *testing.T) {
 	_, _, err := ParseLocalReference("/Patient")
 	require.EqualError(t, err, "local reference must contain a resource type")
@@ -0,0 +1,53 @@
+package csd
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/logging"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+var _ Directory = CompositeDirectory{}
+
+// CompositeDirectory queries multiple directories in order, returning the result of the first directory that has one.
+// This allows local overrides (e.g. a file) to take precedence over a (national) registry.
+// If a directory fails, the next directory is queried. The error is only returned if no directory has a result.
+type CompositeDirectory []Directory
+
+func (c CompositeDirectory) LookupEndpoint(ctx context.Context, owner *fhir.Identifier, endpointName string) ([]fhir.Endpoint, error) {
+	var errs []error
+	for _, directory := range c {
+		endpoints, err := directory.LookupEndpoint(ctx, owner, endpointName)
+		if err != nil {
+			slog.WarnContext(ctx, "CSD endpoint lookup failed, trying next directory", slog.String(logging.FieldError, err.Error()))
+			errs = append(errs, err)
+			continue
+		}
+		if len(endpoints) > 0 {
+			return endpoints, nil
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+func (c CompositeDirectory) LookupEntity(ctx context.Context, identifier fhir.Identifier) (*fhir.Reference, error) {
+	var errs []error
+	for _, directory := range c {
+		entity, err := directory.LookupEntity(ctx, identifier)
+		if errors.Is(err, ErrEntryNotFound) {
+			continue
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "CSD entity lookup failed, trying next directory", slog.String(logging.FieldError, err.Error()))
+			errs = append(errs, err)
+			continue
+		}
+		return entity, nil
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return nil, ErrEntryNotFound
+}
@@ -0,0 +1,116 @@
+package csd
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCompositeDirectory(t *testing.T) {
+	ctx := context.Background()
+	ura := fhir.Identifier{System: to.Ptr(coolfhir.URANamingSystem), Value: to.Ptr("1")}
+	local := &StaticDirectory{
+		Organizations: []StaticOrganization{
+			{
+				Identifiers: []fhir.Identifier{ura},
+				Name:        "Local name",
+				Endpoints:   map[string]string{"fhirNotificationURL": "https://local.example.com/notify"},
+			},
+		},
+	}
+	t.Run("LookupEndpoint", func(t *testing.T) {
+		t.Run("first directory has the endpoint", func(t *testing.T) {
+			next := NewMockDirectory(gomock.NewController(t))
+
+			endpoints, err := CompositeDirectory{local, next}.LookupEndpoint(ctx, &ura, "fhirNotificationURL")
+
+			require.NoError(t, err)
+			require.Len(t, endpoints, 1)
+			require.Equal(t, "https://local.example.com/notify", endpoints[0].Address)
+		})
+		t.Run("falls back to next directory", func(t *testing.T) {
+			next := NewMockDirectory(gomock.NewController(t))
+			next.EXPECT().LookupEndpoint(ctx, &ura, "fhirBaseURL").Return([]fhir.Endpoint{{Address: "https://remote.example.com/fhir"}}, nil)
+
+			endpoints, err := CompositeDirectory{local, next}.LookupEndpoint(ctx, &ura, "fhirBaseURL")
+
+			require.NoError(t, err)
+			require.Len(t, endpoints, 1)
+			require.Equal(t, "https://remote.example.com/fhir", endpoints[0].Address)
+		})
+		t.Run("failing directory is skipped", func(t *testing.T) {
+			failing := NewMockDirectory(gomock.NewController(t))
+			failing.EXPECT().LookupEndpoint(ctx, &ura, "fhirNotificationURL").Return(nil, errors.New("timeout"))
+
+			endpoints, err := CompositeDirectory{failing, local}.LookupEndpoint(ctx, &ura, "fhirNotificationURL")
+
+			require.NoError(t, err)
+			require.Len(t, endpoints, 1)
+		})
+		t.Run("all directories fail", func(t *testing.T) {
+			failing := NewMockDirectory(gomock.NewController(t))
+			failing.EXPECT().LookupEndpoint(ctx, &ura, "fhirBaseURL").Return(nil, errors.New("timeout"))
+
+			_, err := CompositeDirectory{local, failing}.LookupEndpoint(ctx, &ura, "fhirBaseURL")
+
+			require.EqualError(t, err, "timeout")
+		})
+	})
+	t.Run("LookupEntity", func(t *testing.T) {
+		t.Run("first directory has the entity", func(t *testing.T) {
+			next := NewMockDirectory(gomock.NewController(t))
+
+			entity, err := CompositeDirectory{local, next}.LookupEntity(ctx, ura)
+
+			require.NoError(t, err)
+			require.Equal(t, "Local name", *entity.Display)
+		})
+		t.Run("falls back to next directory", func(t *testing.T) {
+			other := fhir.Identifier{System: to.Ptr(coolfhir.URANamingSystem), Value: to.Ptr("2")}
+			next := NewMockDirectory(gomock.NewController(t))
+			next.EXPECT().LookupEntity(ctx, other).Return(&fhir.Reference{Display: to.Ptr("Remote name")}, nil)
+
+			entity, err := CompositeDirectory{local, next}.LookupEntity(ctx, other)
+
+			require.NoError(t, err)
+			require.Equal(t, "Remote name", *entity.Display)
+		})
+		t.Run("not found", func(t *testing.T) {
+			other := fhir.Identifier{System: to.Ptr(coolfhir.URANamingSystem), Value: to.Ptr("2")}
+
+			_, err := CompositeDirectory{local}.LookupEntity(ctx, other)
+
+			require.ErrorIs(t, err, ErrEntryNotFound)
+		})
+	})
+}
+
+func TestNew(t *testing.T) {
+	next := NewMockDirectory(gomock.NewController(t))
+	t.Run("nothing configured", func(t *testing.T) {
+		result, err := New(DefaultConfig(), next)
+
+		require.NoError(t, err)
+		require.Same(t, next, result)
+	})
+	t.Run("file and FHIR API", func(t *testing.T) {
+		config := DefaultConfig()
+		config.File = "testdata/directory.yaml"
+		config.FHIR.BaseURL = "https://example.com/mcsd"
+
+		result, err := New(config, next)
+
+		require.NoError(t, err)
+		require.IsType(t, CompositeDirectory{}, result)
+		require.Len(t, result, 3)
+		require.IsType(t, &StaticDirectory{}, result.(CompositeDirectory)[0])
+		require.IsType(t, &FHIRDirectory{}, result.(CompositeDirectory)[1])
+		require.Same(t, next, result.(CompositeDirectory)[2])
+	})
+}
@@ -0,0 +1,56 @@
+package csd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+)
+
+// Config configures additional directories that are consulted before the directory of the active profile (e.g. Nuts Discovery Service).
+type Config struct {
+	// File is the path to a YAML file or FHIR Bundle with organizations and endpoints that take precedence over other directories,
+	// e.g. for local overrides or air-gapped environments. See LoadStaticDirectory for the supported formats.
+	File string `koanf:"file"`
+	// FHIR configures the FHIR API of an IHE mCSD directory (e.g. a national registry).
+	FHIR coolfhir.ClientConfig `koanf:"fhir"`
+	// CacheTTL is the duration organizations from the FHIR API are cached.
+	CacheTTL time.Duration `koanf:"cachettl"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		CacheTTL: DefaultCacheTTL,
+	}
+}
+
+func (c Config) Validate() error {
+	if err := c.FHIR.Validate(); err != nil {
+		return fmt.Errorf("invalid CSD FHIR configuration: %w", err)
+	}
+	return nil
+}
+
+// New creates a Directory that consults the configured file and FHIR API (in that order), before the given directory.
+// If neither is configured, the given directory is returned.
+func New(config Config, next Directory) (Directory, error) {
+	var result CompositeDirectory
+	if config.File != "" {
+		directory, err := LoadStaticDirectory(config.File)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, directory)
+	}
+	if config.FHIR.BaseURL != "" {
+		_, fhirClient, err := coolfhir.NewAuthRoundTripper(config.FHIR, coolfhir.Config())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create CSD FHIR client: %w", err)
+		}
+		result = append(result, NewFHIRDirectory(fhirClient, config.CacheTTL))
+	}
+	if len(result) == 0 {
+		return next, nil
+	}
+	return append(result, next), nil
+}
@@ -0,0 +1,176 @@
+package csd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	fhirclient "github.com/SanteonNL/go-fhir-client"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+var _ Directory = &FHIRDirectory{}
+
+// DefaultCacheTTL is the default duration directory entries are cached.
+const DefaultCacheTTL = 5 * time.Minute
+
+// cacheCapacity is the maximum number of directory entries that are cached. When it's reached, the least recently used entry is evicted.
+const cacheCapacity = 10000
+
+const maxSearchPages = 100
+
+// FHIRDirectory is a Directory that is backed by a FHIR API conforming to IHE mCSD, e.g. a national or regional care services registry.
+// Organizations are looked up by identifier (of any system), their endpoints are resolved through Organization.endpoint.
+// The endpoint name (e.g. fhirBaseURL) is matched against Endpoint.name. Endpoints that aren't active are ignored.
+type FHIRDirectory struct {
+	client   fhirclient.Client
+	cacheTTL time.Duration
+	// cache contains the results of lookups by identifier (as FHIR search token). Entries expire cacheTTL after they were looked up.
+	cache *ttlcache.Cache[string, fhirDirectoryCacheEntry]
+}
+
+type fhirDirectoryCacheEntry struct {
+	organization *fhir.Organization
+	endpoints    []fhir.Endpoint
+}
+
+// NewFHIRDirectory creates a FHIRDirectory that queries the given FHIR API. Lookups by identifier are cached for the given duration.
+// If the duration isn't positive, lookups aren't cached.
+func NewFHIRDirectory(client fhirclient.Client, cacheTTL time.Duration) *FHIRDirectory {
+	return &FHIRDirectory{
+		client:   client,
+		cacheTTL: cacheTTL,
+		cache: ttlcache.New[string, fhirDirectoryCacheEntry](
+			ttlcache.WithTTL[string, fhirDirectoryCacheEntry](cacheTTL),
+			ttlcache.WithCapacity[string, fhirDirectoryCacheEntry](cacheCapacity),
+			ttlcache.WithDisableTouchOnHit[string, fhirDirectoryCacheEntry](),
+		),
+	}
+}
+
+// LookupEndpoint returns the active endpoints with the given name of the given owner.
+// If the owner is nil, all active endpoints with the given name are returned.
+func (f *FHIRDirectory) LookupEndpoint(ctx context.Context, owner *fhir.Identifier, endpointName string) ([]fhir.Endpoint, error) {
+	if owner == nil {
+		return f.searchEndpoints(ctx, endpointName)
+	}
+	entry, err := f.find(ctx, *owner)
+	if err != nil {
+		if errors.Is(err, ErrEntryNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var results []fhir.Endpoint
+	for _, endpoint := range entry.endpoints {
+		if to.EmptyString(endpoint.Name) == endpointName {
+			results = append(results, endpoint)
+		}
+	}
+	return results, nil
+}
+
+func (f *FHIRDirectory) LookupEntity(ctx context.Context, identifier fhir.Identifier) (*fhir.Reference, error) {
+	entry, err := f.find(ctx, identifier)
+	if err != nil {
+		return nil, err
+	}
+	result := fhir.Reference{
+		Type:       to.Ptr("Organization"),
+		Identifier: &identifier,
+		Display:    entry.organization.Name,
+	}
+	return &result, nil
+}
+
+// find searches the Organization with the given identifier, including its endpoints.
+func (f *FHIRDirectory) find(ctx context.Context, identifier fhir.Identifier) (*fhirDirectoryCacheEntry, error) {
+	if identifier.System == nil || identifier.Value == nil {
+		return nil, errors.New("identifier must contain both System and Value")
+	}
+	cacheKey := coolfhir.IdentifierToToken(identifier)
+	if item := f.cache.Get(cacheKey); item != nil {
+		entry := item.Value()
+		return &entry, nil
+	}
+
+	var searchSet fhir.Bundle
+	if err := f.client.SearchWithContext(ctx, "Organization", url.Values{
+		"identifier": []string{cacheKey},
+		"_include":   []string{"Organization:endpoint"},
+	}, &searchSet); err != nil {
+		return nil, fmt.Errorf("CSD Organization search failed: %w", err)
+	}
+	var organizations []fhir.Organization
+	if err := coolfhir.ResourcesInBundle(&searchSet, coolfhir.EntryIsOfType("Organization"), &organizations); err != nil {
+		return nil, err
+	}
+	var endpoints []fhir.Endpoint
+	if err := coolfhir.ResourcesInBundle(&searchSet, coolfhir.EntryIsOfType("Endpoint"), &endpoints); err != nil {
+		return nil, err
+	}
+	if len(organizations) == 0 {
+		return nil, ErrEntryNotFound
+	}
+	entry := fhirDirectoryCacheEntry{
+		organization: &organizations[0],
+	}
+	for _, reference := range organizations[0].Endpoint {
+		for _, endpoint := range endpoints {
+			if endpoint.Id != nil && reference.Reference != nil && strings.HasSuffix(*reference.Reference, "Endpoint/"+*endpoint.Id) &&
+				endpoint.Status == fhir.EndpointStatusActive {
+				entry.endpoints = append(entry.endpoints, endpoint)
+			}
+		}
+	}
+	if f.cacheTTL > 0 {
+		// A TTL of 0 would make the entry never expire
+		f.cache.Set(cacheKey, entry, ttlcache.DefaultTTL)
+	}
+	return &entry, nil
+}
+
+// searchEndpoints searches all active endpoints with the given name, following the search set's next links.
+func (f *FHIRDirectory) searchEndpoints(ctx context.Context, endpointName string) ([]fhir.Endpoint, error) {
+	searchParams := url.Values{
+		"name":   []string{endpointName},
+		"status": []string{fhir.EndpointStatusActive.Code()},
+	}
+	var results []fhir.Endpoint
+	for i := 0; i < maxSearchPages; i++ {
+		var searchSet fhir.Bundle
+		if err := f.client.SearchWithContext(ctx, "Endpoint", searchParams, &searchSet); err != nil {
+			return nil, fmt.Errorf("CSD Endpoint search failed: %w", err)
+		}
+		var endpoints []fhir.Endpoint
+		if err := coolfhir.ResourcesInBundle(&searchSet, coolfhir.EntryIsOfType("Endpoint"), &endpoints); err != nil {
+			return nil, err
+		}
+		for _, endpoint := range endpoints {
+			// Not all servers support searching on Endpoint.name, so filter again
+			if to.EmptyString(endpoint.Name) == endpointName && endpoint.Status == fhir.EndpointStatusActive {
+				results = append(results, endpoint)
+			}
+		}
+		searchParams = nil
+		for _, link := range searchSet.Link {
+			if link.Relation == "next" {
+				nextURL, err := url.Parse(link.Url)
+				if err != nil {
+					return nil, fmt.Errorf("invalid 'next' link for search set: %w", err)
+				}
+				searchParams = nextURL.Query()
+			}
+		}
+		if searchParams == nil {
+			return results, nil
+		}
+	}
+	return nil, fmt.Errorf("max. search pages reached (%d)", maxSearchPages)
+}
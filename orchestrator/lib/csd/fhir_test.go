@@ -0,0 +1,170 @@
+package csd
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/SanteonNL/orca/orchestrator/careplancontributor/mock"
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	"go.uber.org/mock/gomock"
+)
+
+func TestFHIRDirectory(t *testing.T) {
+	ctx := context.Background()
+	ura := fhir.Identifier{System: to.Ptr(coolfhir.URANamingSystem), Value: to.Ptr("1")}
+	organization := fhir.Organization{
+		Id:         to.Ptr("org"),
+		Identifier: []fhir.Identifier{ura},
+		Name:       to.Ptr("Hospital"),
+		Endpoint: []fhir.Reference{
+			{Reference: to.Ptr("Endpoint/fhir")},
+			{Reference: to.Ptr("https://example.com/fhir/Endpoint/notify")},
+			{Reference: to.Ptr("Endpoint/old")},
+		},
+	}
+	fhirEndpoint := fhir.Endpoint{
+		Id:      to.Ptr("fhir"),
+		Status:  fhir.EndpointStatusActive,
+		Name:    to.Ptr("fhirBaseURL"),
+		Address: "https://hospital.example.com/fhir",
+	}
+	notificationEndpoint := fhir.Endpoint{
+		Id:      to.Ptr("notify"),
+		Status:  fhir.EndpointStatusActive,
+		Name:    to.Ptr("fhirNotificationURL"),
+		Address: "https://hospital.example.com/fhir/notify",
+	}
+	oldEndpoint := fhir.Endpoint{
+		Id:      to.Ptr("old"),
+		Status:  fhir.EndpointStatusOff,
+		Name:    to.Ptr("fhirBaseURL"),
+		Address: "https://old.hospital.example.com/fhir",
+	}
+	searchSet := coolfhir.SearchSet().
+		Append(organization, nil, nil).
+		Append(fhirEndpoint, nil, nil).
+		Append(notificationEndpoint, nil, nil).
+		Append(oldEndpoint, nil, nil)
+	expectOrganizationSearch := func(fhirClient *mock.MockClient, result fhir.Bundle) *gomock.Call {
+		return fhirClient.EXPECT().SearchWithContext(gomock.Any(), "Organization", url.Values{
+			"identifier": []string{coolfhir.URANamingSystem + "|1"},
+			"_include":   []string{"Organization:endpoint"},
+		}, gomock.Any()).DoAndReturn(func(_ context.Context, _ string, _ url.Values, target *fhir.Bundle, _ ...any) error {
+			*target = result
+			return nil
+		})
+	}
+
+	t.Run("LookupEndpoint", func(t *testing.T) {
+		fhirClient := mock.NewMockClient(gomock.NewController(t))
+		expectOrganizationSearch(fhirClient, fhir.Bundle(*searchSet)).Times(1)
+		directory := NewFHIRDirectory(fhirClient, time.Minute)
+
+		endpoints, err := directory.LookupEndpoint(ctx, &ura, "fhirBaseURL")
+		require.NoError(t, err)
+		require.Len(t, endpoints, 1)
+		require.Equal(t, "https://hospital.example.com/fhir", endpoints[0].Address)
+
+		t.Run("absolute reference, cached", func(t *testing.T) {
+			endpoints, err := directory.LookupEndpoint(ctx, &ura, "fhirNotificationURL")
+			require.NoError(t, err)
+			require.Len(t, endpoints, 1)
+			require.Equal(t, "https://hospital.example.com/fhir/notify", endpoints[0].Address)
+		})
+	})
+	t.Run("LookupEndpoint, organization not found", func(t *testing.T) {
+		fhirClient := mock.NewMockClient(gomock.NewController(t))
+		expectOrganizationSearch(fhirClient, fhir.Bundle{})
+		directory := NewFHIRDirectory(fhirClient, time.Minute)
+
+		endpoints, err := directory.LookupEndpoint(ctx, &ura, "fhirBaseURL")
+
+		require.NoError(t, err)
+		require.Empty(t, endpoints)
+	})
+	t.Run("LookupEndpoint, search fails", func(t *testing.T) {
+		fhirClient := mock.NewMockClient(gomock.NewController(t))
+		fhirClient.EXPECT().SearchWithContext(gomock.Any(), "Organization", gomock.Any(), gomock.Any()).Return(errors.New("timeout"))
+		directory := NewFHIRDirectory(fhirClient, time.Minute)
+
+		_, err := directory.LookupEndpoint(ctx, &ura, "fhirBaseURL")
+
+		require.EqualError(t, err, "CSD Organization search failed: timeout")
+	})
+	t.Run("LookupEndpoint without owner", func(t *testing.T) {
+		fhirClient := mock.NewMockClient(gomock.NewController(t))
+		page1 := fhir.Bundle(*coolfhir.SearchSet().Append(fhirEndpoint, nil, nil).Append(oldEndpoint, nil, nil))
+		page1.Link = []fhir.BundleLink{{Relation: "next", Url: "https://example.com/fhir/Endpoint?page=2"}}
+		otherEndpoint := fhirEndpoint
+		otherEndpoint.Address = "https://clinic.example.com/fhir"
+		page2 := fhir.Bundle(*coolfhir.SearchSet().Append(otherEndpoint, nil, nil))
+		fhirClient.EXPECT().SearchWithContext(gomock.Any(), "Endpoint", url.Values{
+			"name":   []string{"fhirBaseURL"},
+			"status": []string{"active"},
+		}, gomock.Any()).DoAndReturn(func(_ context.Context, _ string, _ url.Values, target *fhir.Bundle, _ ...any) error {
+			*target = page1
+			return nil
+		})
+		fhirClient.EXPECT().SearchWithContext(gomock.Any(), "Endpoint", url.Values{"page": []string{"2"}}, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, _ url.Values, target *fhir.Bundle, _ ...any) error {
+				*target = page2
+				return nil
+			})
+		directory := NewFHIRDirectory(fhirClient, time.Minute)
+
+		endpoints, err := directory.LookupEndpoint(ctx, nil, "fhirBaseURL")
+
+		require.NoError(t, err)
+		require.Len(t, endpoints, 2)
+		require.Equal(t, "https://hospital.example.com/fhir", endpoints[0].Address)
+		require.Equal(t, "https://clinic.example.com/fhir", endpoints[1].Address)
+	})
+	t.Run("LookupEntity", func(t *testing.T) {
+		fhirClient := mock.NewMockClient(gomock.NewController(t))
+		expectOrganizationSearch(fhirClient, fhir.Bundle(*searchSet))
+		directory := NewFHIRDirectory(fhirClient, time.Minute)
+
+		reference, err := directory.LookupEntity(ctx, ura)
+
+		require.NoError(t, err)
+		require.Equal(t, "Organization", *reference.Type)
+		require.Equal(t, "Hospital", *reference.Display)
+		require.Equal(t, ura, *reference.Identifier)
+	})
+	t.Run("LookupEntity, not found", func(t *testing.T) {
+		fhirClient := mock.NewMockClient(gomock.NewController(t))
+		expectOrganizationSearch(fhirClient, fhir.Bundle{})
+		directory := NewFHIRDirectory(fhirClient, time.Minute)
+
+		_, err := directory.LookupEntity(ctx, ura)
+
+		require.ErrorIs(t, err, ErrEntryNotFound)
+	})
+	t.Run("cache expired", func(t *testing.T) {
+		fhirClient := mock.NewMockClient(gomock.NewController(t))
+		expectOrganizationSearch(fhirClient, fhir.Bundle(*searchSet)).Times(2)
+		directory := NewFHIRDirectory(fhirClient, 10*time.Millisecond)
+
+		_, err := directory.LookupEntity(ctx, ura)
+		require.NoError(t, err)
+		time.Sleep(20 * time.Millisecond)
+		_, err = directory.LookupEntity(ctx, ura)
+		require.NoError(t, err)
+	})
+	t.Run("cache disabled", func(t *testing.T) {
+		fhirClient := mock.NewMockClient(gomock.NewController(t))
+		expectOrganizationSearch(fhirClient, fhir.Bundle(*searchSet)).Times(2)
+		directory := NewFHIRDirectory(fhirClient, 0)
+
+		_, err := directory.LookupEntity(ctx, ura)
+		require.NoError(t, err)
+		_, err = directory.LookupEntity(ctx, ura)
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,191 @@
+package csd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+	"gopkg.in/yaml.v3"
+)
+
+var _ Directory = &StaticDirectory{}
+
+// StaticDirectory is a Directory that is backed by a fixed set of organizations and their endpoints,
+// e.g. loaded from a file in environments without a (national) CSD.
+type StaticDirectory struct {
+	Organizations []StaticOrganization
+}
+
+// StaticOrganization is an entry of the StaticDirectory.
+type StaticOrganization struct {
+	Identifiers []fhir.Identifier
+	Name        string
+	// Endpoints maps the name of an endpoint (e.g. fhirBaseURL) to its address.
+	Endpoints map[string]string
+}
+
+// staticDirectoryFile is the YAML representation of a StaticDirectory.
+type staticDirectoryFile struct {
+	Organizations []struct {
+		// Identifiers are given in token notation, e.g. http://fhir.nl/fhir/NamingSystem/ura|1234
+		Identifiers []string          `yaml:"identifiers"`
+		Name        string            `yaml:"name"`
+		Endpoints   map[string]string `yaml:"endpoints"`
+	} `yaml:"organizations"`
+}
+
+// LoadStaticDirectory loads a StaticDirectory from the given file. Supported formats are:
+//   - a YAML file (.yaml, .yml) listing organizations with their identifiers, name and endpoints,
+//   - a FHIR Bundle (.json) containing Organization resources that refer to Endpoint resources through Organization.endpoint.
+//     The Endpoint.name is used as endpoint name.
+func LoadStaticDirectory(filePath string) (*StaticDirectory, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSD file: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".yaml", ".yml":
+		return parseStaticDirectoryYAML(data)
+	case ".json":
+		var bundle fhir.Bundle
+		if err := json.Unmarshal(data, &bundle); err != nil {
+			return nil, fmt.Errorf("failed to parse CSD FHIR Bundle: %w", err)
+		}
+		return StaticDirectoryFromBundle(bundle)
+	default:
+		return nil, fmt.Errorf("unsupported CSD file format (expected .yaml, .yml or .json): %s", filePath)
+	}
+}
+
+func parseStaticDirectoryYAML(data []byte) (*StaticDirectory, error) {
+	var file staticDirectoryFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse CSD YAML file: %w", err)
+	}
+	result := &StaticDirectory{}
+	for i, entry := range file.Organizations {
+		organization := StaticOrganization{
+			Name:      entry.Name,
+			Endpoints: entry.Endpoints,
+		}
+		for _, token := range entry.Identifiers {
+			identifier, err := coolfhir.TokenToIdentifier(token)
+			if err != nil || identifier.System == nil || identifier.Value == nil {
+				return nil, fmt.Errorf("organization %d: invalid identifier (expected <system>|<value>): %s", i, token)
+			}
+			organization.Identifiers = append(organization.Identifiers, *identifier)
+		}
+		if len(organization.Identifiers) == 0 {
+			return nil, fmt.Errorf("organization %d: no identifiers", i)
+		}
+		result.Organizations = append(result.Organizations, organization)
+	}
+	return result, nil
+}
+
+// StaticDirectoryFromBundle creates a StaticDirectory from the Organization and Endpoint resources in the given Bundle.
+// Organizations refer to their Endpoints through Organization.endpoint, which are resolved by the entry's fullUrl or their relative reference.
+// Endpoints that aren't active are ignored.
+func StaticDirectoryFromBundle(bundle fhir.Bundle) (*StaticDirectory, error) {
+	endpoints := map[string]fhir.Endpoint{}
+	var organizations []fhir.Organization
+	for i, entry := range bundle.Entry {
+		var resource coolfhir.Resource
+		if err := json.Unmarshal(entry.Resource, &resource); err != nil {
+			return nil, fmt.Errorf("bundle entry %d: %w", i, err)
+		}
+		switch resource.Type {
+		case "Organization":
+			var organization fhir.Organization
+			if err := json.Unmarshal(entry.Resource, &organization); err != nil {
+				return nil, fmt.Errorf("bundle entry %d: %w", i, err)
+			}
+			organizations = append(organizations, organization)
+		case "Endpoint":
+			var endpoint fhir.Endpoint
+			if err := json.Unmarshal(entry.Resource, &endpoint); err != nil {
+				return nil, fmt.Errorf("bundle entry %d: %w", i, err)
+			}
+			if entry.FullUrl != nil {
+				endpoints[*entry.FullUrl] = endpoint
+			}
+			if endpoint.Id != nil {
+				endpoints["Endpoint/"+*endpoint.Id] = endpoint
+			}
+		}
+	}
+	result := &StaticDirectory{}
+	for _, organization := range organizations {
+		entry := StaticOrganization{
+			Identifiers: organization.Identifier,
+			Name:        to.EmptyString(organization.Name),
+			Endpoints:   map[string]string{},
+		}
+		if len(entry.Identifiers) == 0 {
+			return nil, errors.New("bundle contains Organization without identifiers")
+		}
+		for _, reference := range organization.Endpoint {
+			if reference.Reference == nil {
+				continue
+			}
+			endpoint, ok := endpoints[*reference.Reference]
+			if !ok {
+				return nil, fmt.Errorf("Organization refers to Endpoint that isn't in the bundle: %s", *reference.Reference)
+			}
+			if endpoint.Status != fhir.EndpointStatusActive || endpoint.Name == nil {
+				continue
+			}
+			entry.Endpoints[*endpoint.Name] = endpoint.Address
+		}
+		result.Organizations = append(result.Organizations, entry)
+	}
+	return result, nil
+}
+
+// LookupEndpoint returns the endpoint with the given name of the given owner.
+// If the owner is nil, the endpoints with the given name of all organizations are returned.
+func (s *StaticDirectory) LookupEndpoint(_ context.Context, owner *fhir.Identifier, endpointName string) ([]fhir.Endpoint, error) {
+	var results []fhir.Endpoint
+	for _, organization := range s.Organizations {
+		if owner != nil && !coolfhir.HasIdentifier(*owner, organization.Identifiers...) {
+			continue
+		}
+		address, ok := organization.Endpoints[endpointName]
+		if !ok {
+			continue
+		}
+		results = append(results, fhir.Endpoint{
+			Address: address,
+			Status:  fhir.EndpointStatusActive,
+			ConnectionType: fhir.Coding{
+				System: to.Ptr("http://hl7.org/fhir/ValueSet/endpoint-connection-type"),
+				Code:   to.Ptr("hl7-fhir-rest"),
+			},
+		})
+	}
+	return results, nil
+}
+
+func (s *StaticDirectory) LookupEntity(_ context.Context, identifier fhir.Identifier) (*fhir.Reference, error) {
+	for _, organization := range s.Organizations {
+		if !coolfhir.HasIdentifier(identifier, organization.Identifiers...) {
+			continue
+		}
+		result := fhir.Reference{
+			Type:       to.Ptr("Organization"),
+			Identifier: &identifier,
+		}
+		if organization.Name != "" {
+			result.Display = to.Ptr(organization.Name)
+		}
+		return &result, nil
+	}
+	return nil, ErrEntryNotFound
+}
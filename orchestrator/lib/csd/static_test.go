@@ -0,0 +1,99 @@
+package csd
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/coolfhir"
+	"github.com/SanteonNL/orca/orchestrator/lib/to"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+func TestLoadStaticDirectory(t *testing.T) {
+	ura1 := fhir.Identifier{System: to.Ptr(coolfhir.URANamingSystem), Value: to.Ptr("1")}
+	ura2 := fhir.Identifier{System: to.Ptr(coolfhir.URANamingSystem), Value: to.Ptr("2")}
+	ctx := context.Background()
+
+	t.Run("YAML", func(t *testing.T) {
+		directory, err := LoadStaticDirectory("testdata/directory.yaml")
+		require.NoError(t, err)
+		require.Len(t, directory.Organizations, 2)
+
+		t.Run("LookupEndpoint", func(t *testing.T) {
+			endpoints, err := directory.LookupEndpoint(ctx, &ura1, "fhirBaseURL")
+			require.NoError(t, err)
+			require.Len(t, endpoints, 1)
+			require.Equal(t, "https://hospital.example.com/fhir", endpoints[0].Address)
+			require.Equal(t, fhir.EndpointStatusActive, endpoints[0].Status)
+		})
+		t.Run("LookupEndpoint without owner", func(t *testing.T) {
+			endpoints, err := directory.LookupEndpoint(ctx, nil, "fhirNotificationURL")
+			require.NoError(t, err)
+			require.Len(t, endpoints, 2)
+		})
+		t.Run("LookupEndpoint, endpoint not found", func(t *testing.T) {
+			endpoints, err := directory.LookupEndpoint(ctx, &ura2, "fhirBaseURL")
+			require.NoError(t, err)
+			require.Empty(t, endpoints)
+		})
+		t.Run("LookupEntity", func(t *testing.T) {
+			reference, err := directory.LookupEntity(ctx, ura2)
+			require.NoError(t, err)
+			require.Equal(t, "Organization", *reference.Type)
+			require.Equal(t, "Clinic", *reference.Display)
+			require.Equal(t, ura2, *reference.Identifier)
+		})
+		t.Run("LookupEntity, not found", func(t *testing.T) {
+			_, err := directory.LookupEntity(ctx, fhir.Identifier{System: to.Ptr(coolfhir.URANamingSystem), Value: to.Ptr("3")})
+			require.ErrorIs(t, err, ErrEntryNotFound)
+		})
+	})
+	t.Run("FHIR Bundle", func(t *testing.T) {
+		directory, err := LoadStaticDirectory("testdata/directory.json")
+		require.NoError(t, err)
+		require.Len(t, directory.Organizations, 1)
+
+		endpoints, err := directory.LookupEndpoint(ctx, &ura1, "fhirBaseURL")
+		require.NoError(t, err)
+		require.Len(t, endpoints, 1)
+		require.Equal(t, "https://hospital.example.com/fhir", endpoints[0].Address)
+		endpoints, err = directory.LookupEndpoint(ctx, &ura1, "fhirNotificationURL")
+		require.NoError(t, err)
+		require.Len(t, endpoints, 1)
+		require.Equal(t, "https://hospital.example.com/fhir/notify", endpoints[0].Address)
+		reference, err := directory.LookupEntity(ctx, ura1)
+		require.NoError(t, err)
+		require.Equal(t, "Hospital", *reference.Display)
+	})
+	t.Run("FHIR Bundle, Endpoint not in Bundle", func(t *testing.T) {
+		filePath := path.Join(t.TempDir(), "directory.json")
+		require.NoError(t, os.WriteFile(filePath, []byte(`{
+  "resourceType": "Bundle",
+  "type": "collection",
+  "entry": [{"resource": {"resourceType": "Organization", "identifier": [{"system": "http://fhir.nl/fhir/NamingSystem/ura", "value": "1"}], "endpoint": [{"reference": "Endpoint/other"}]}}]
+}`), 0644))
+
+		_, err := LoadStaticDirectory(filePath)
+
+		require.EqualError(t, err, "Organization refers to Endpoint that isn't in the bundle: Endpoint/other")
+	})
+	t.Run("invalid identifier in YAML", func(t *testing.T) {
+		filePath := path.Join(t.TempDir(), "directory.yaml")
+		require.NoError(t, os.WriteFile(filePath, []byte("organizations:\n  - identifiers: [\"1234\"]\n"), 0644))
+
+		_, err := LoadStaticDirectory(filePath)
+
+		require.EqualError(t, err, "organization 0: invalid identifier (expected <system>|<value>): 1234")
+	})
+	t.Run("unsupported file format", func(t *testing.T) {
+		filePath := path.Join(t.TempDir(), "directory.xml")
+		require.NoError(t, os.WriteFile(filePath, []byte("<Bundle/>"), 0644))
+
+		_, err := LoadStaticDirectory(filePath)
+
+		require.ErrorContains(t, err, "unsupported CSD file format")
+	})
+}
@@ -36,6 +36,7 @@ const (
 	AuthNMethodStaticToken = "static_bearer_token"
 	AuthNMethodJWT         = "jwt"
 	AuthNMethodNuts        = "nuts"
+	AuthNMethodMTLS        = "mtls"
 
 	// AuthZ
 	AuthZAllowed = "authorization.allowed"
@@ -0,0 +1,91 @@
+package otel
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+)
+
+const redactedValue = "REDACTED"
+
+// RedactURL returns the URL for recording in spans, with the values of its query parameters and user info redacted:
+// query parameters may contain personal data, e.g. a BSN when searching for a patient's resources.
+func RedactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	redacted := *u
+	if redacted.User != nil {
+		redacted.User = url.User(redactedValue)
+	}
+	if redacted.RawQuery != "" {
+		var params []string
+		for _, param := range strings.Split(redacted.RawQuery, "&") {
+			name, _, _ := strings.Cut(param, "=")
+			params = append(params, name+"="+redactedValue)
+		}
+		redacted.RawQuery = strings.Join(params, "&")
+	}
+	return redacted.String()
+}
+
+// urlAttributeKeys contains the keys of span attributes that contain a full URL, which are redacted by urlRedactingTracerProvider.
+var urlAttributeKeys = []attribute.Key{semconv.HTTPURLKey, "url.full"}
+
+var _ trace.TracerProvider = urlRedactingTracerProvider{}
+
+// WithURLRedaction returns an otelhttp.Option that redacts the URLs (see RedactURL) otelhttp records in its spans.
+func WithURLRedaction() otelhttp.Option {
+	return otelhttp.WithTracerProvider(urlRedactingTracerProvider{})
+}
+
+// urlRedactingTracerProvider is a trace.TracerProvider that redacts the URL attributes (see RedactURL) of spans started by instrumentation
+// libraries that record full URLs, e.g. otelhttp. It delegates to the TracerProvider of the parent span, or the global TracerProvider.
+type urlRedactingTracerProvider struct {
+	embedded.TracerProvider
+}
+
+func (p urlRedactingTracerProvider) Tracer(name string, options ...trace.TracerOption) trace.Tracer {
+	return urlRedactingTracer{name: name, options: options}
+}
+
+type urlRedactingTracer struct {
+	embedded.Tracer
+	name    string
+	options []trace.TracerOption
+}
+
+func (t urlRedactingTracer) Start(ctx context.Context, spanName string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	provider := otel.GetTracerProvider()
+	if parent := trace.SpanFromContext(ctx); parent.SpanContext().IsValid() {
+		provider = parent.TracerProvider()
+	}
+	ctx, span := provider.Tracer(t.name, t.options...).Start(ctx, spanName, opts...)
+	return ctx, urlRedactingSpan{Span: span}
+}
+
+type urlRedactingSpan struct {
+	trace.Span
+}
+
+func (s urlRedactingSpan) SetAttributes(kv ...attribute.KeyValue) {
+	for i, attr := range kv {
+		if !slices.Contains(urlAttributeKeys, attr.Key) {
+			continue
+		}
+		if u, err := url.Parse(attr.Value.AsString()); err == nil {
+			kv[i] = attr.Key.String(RedactURL(u))
+		} else {
+			kv[i] = attr.Key.String(redactedValue)
+		}
+	}
+	s.Span.SetAttributes(kv...)
+}
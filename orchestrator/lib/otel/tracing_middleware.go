@@ -14,9 +14,15 @@ import (
 )
 
 func NewTracedHTTPClient(spanNameBase string) *http.Client {
+	return NewTracedHTTPClientWithTransport(spanNameBase, http.DefaultTransport)
+}
+
+// NewTracedHTTPClientWithTransport is like NewTracedHTTPClient, but uses the given transport instead of http.DefaultTransport.
+func NewTracedHTTPClientWithTransport(spanNameBase string, transport http.RoundTripper) *http.Client {
 	return &http.Client{
 		Transport: otelhttp.NewTransport(
-			http.DefaultTransport,
+			transport,
+			WithURLRedaction(),
 			otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
 				return fmt.Sprintf("%s.%s %s %s", spanNameBase, operation, strings.ToLower(r.Method), r.URL.Path)
 			}),
@@ -39,7 +45,7 @@ func HandlerWithTracing(tracer trace.Tracer, operationName string) func(http.Han
 				trace.WithSpanKind(trace.SpanKindServer),
 				trace.WithAttributes(
 					semconv.HTTPMethodKey.String(r.Method),
-					semconv.HTTPURLKey.String(r.URL.String()),
+					semconv.HTTPURLKey.String(RedactURL(r.URL)),
 					semconv.HostNameKey.String(r.Host),
 				),
 			)
@@ -8,6 +8,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
 )
 
 func TestNewTracedHTTPClient(t *testing.T) {
@@ -57,3 +59,17 @@ func TestResponseWriter_WriteHeader(t *testing.T) {
 	assert.Equal(t, http.StatusCreated, rw.statusCode)
 	assert.Equal(t, http.StatusCreated, rec.Code)
 }
+
+func TestHandlerWithTracing_RedactsURL(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := trace.NewTracerProvider(trace.WithSpanProcessor(recorder)).Tracer("test")
+	handler := HandlerWithTracing(tracer, "test-op")(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/Patient?identifier=bsn|123456789", nil))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Contains(t, spans[0].Attributes(), semconv.HTTPURLKey.String("/Patient?identifier=REDACTED"))
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -129,19 +130,21 @@ func (s *StubFHIRClient) SearchWithContext(ctx context.Context, resourceType str
 		value := values[0]
 		switch name {
 		case "identifier":
-			token := strings.Split(value, "|")
 			filterCandidates(func(candidate BaseResource) bool {
-				for _, identifier := range candidate.Identifier {
-					if (token[0] == "" || to.EmptyString(identifier.System) == token[0]) &&
-						(token[1] == "" || to.EmptyString(identifier.Value) == token[1]) {
-						return true
+				for _, tokenValue := range strings.Split(value, ",") {
+					token := strings.Split(tokenValue, "|")
+					for _, identifier := range candidate.Identifier {
+						if (token[0] == "" || to.EmptyString(identifier.System) == token[0]) &&
+							(token[1] == "" || to.EmptyString(identifier.Value) == token[1]) {
+							return true
+						}
 					}
 				}
 				return false
 			})
 		case "_id":
 			filterCandidates(func(candidate BaseResource) bool {
-				return candidate.Id == value
+				return slices.Contains(strings.Split(value, ","), candidate.Id)
 			})
 		case "_include":
 			filterCandidates(func(candidate BaseResource) bool {
@@ -222,7 +225,7 @@ func (s *StubFHIRClient) SearchWithContext(ctx context.Context, resourceType str
 				if err := json.Unmarshal(candidate.Data, &task); err != nil {
 					panic(err)
 				}
-				return task.Focus != nil && *task.Focus.Reference == value
+				return task.Focus != nil && slices.Contains(strings.Split(value, ","), *task.Focus.Reference)
 			})
 		case "subject":
 			filterCandidates(func(candidate BaseResource) bool {
@@ -233,12 +236,32 @@ func (s *StubFHIRClient) SearchWithContext(ctx context.Context, resourceType str
 				if err := json.Unmarshal(candidate.Data, &carePlan); err != nil {
 					panic(err)
 				}
-				if carePlan.Subject.Reference != nil && *carePlan.Subject.Reference == value {
+				if carePlan.Subject.Reference != nil && slices.Contains(strings.Split(value, ","), *carePlan.Subject.Reference) {
 					return true
 				}
 				if carePlan.Subject.Identifier != nil {
 					token := fmt.Sprintf("%s|%s", to.EmptyString(carePlan.Subject.Identifier.System), to.EmptyString(carePlan.Subject.Identifier.Value))
-					if value == token {
+					if slices.Contains(strings.Split(value, ","), token) {
+						return true
+					}
+				}
+				return false
+			})
+		case "goal", "supporting-info":
+			filterCandidates(func(candidate BaseResource) bool {
+				if candidate.Type != "CarePlan" {
+					return false
+				}
+				var carePlan fhir.CarePlan
+				if err := json.Unmarshal(candidate.Data, &carePlan); err != nil {
+					panic(err)
+				}
+				references := carePlan.Goal
+				if name == "supporting-info" {
+					references = carePlan.SupportingInfo
+				}
+				for _, reference := range references {
+					if reference.Reference != nil && slices.Contains(strings.Split(value, ","), *reference.Reference) {
 						return true
 					}
 				}
@@ -0,0 +1,425 @@
+package fhirprofile
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// expression is a parsed FHIRPath expression that evaluates to a boolean.
+// The result is nil if the expression evaluates to empty (e.g. comparing an absent element), which doesn't violate an invariant.
+type expression interface {
+	evaluate(context []node) *bool
+}
+
+// parseFHIRPath parses the subset of FHIRPath that is commonly used in simple invariants:
+//   - element paths, relative to the element the invariant is defined on (e.g. "output.type"),
+//     followed by exists(), empty() or count() compared to an integer,
+//   - element paths compared to a string, integer or boolean literal (=, !=, <, <=, >, >=),
+//   - and, or, implies, not() and parentheses.
+//
+// It returns an error for expressions outside this subset, which are then not evaluated.
+func parseFHIRPath(input string) (expression, error) {
+	tokens, err := tokenizeFHIRPath(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &fhirPathParser{tokens: tokens}
+	result, err := p.parseImplies()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("unexpected token: %s", p.tokens[p.pos].value)
+	}
+	return result, nil
+}
+
+type tokenKind int
+
+const (
+	tokenIdentifier tokenKind = iota
+	tokenString
+	tokenNumber
+	tokenSymbol
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+}
+
+func tokenizeFHIRPath(input string) ([]token, error) {
+	var tokens []token
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdentifier, value: string(runes[start:i])})
+		case unicode.IsDigit(r):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, value: string(runes[start:i])})
+		case r == '\'':
+			end := i + 1
+			for end < len(runes) && runes[end] != '\'' {
+				if runes[end] == '\\' {
+					return nil, fmt.Errorf("escape sequences in strings aren't supported")
+				}
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, token{kind: tokenString, value: string(runes[i+1 : end])})
+			i = end + 1
+		case strings.ContainsRune(".()", r):
+			tokens = append(tokens, token{kind: tokenSymbol, value: string(r)})
+			i++
+		case strings.ContainsRune("=!<>", r):
+			if i+1 < len(runes) && runes[i+1] == '=' {
+				tokens = append(tokens, token{kind: tokenSymbol, value: string(runes[i : i+2])})
+				i += 2
+			} else if r == '!' {
+				return nil, fmt.Errorf("unexpected character: %c", r)
+			} else {
+				tokens = append(tokens, token{kind: tokenSymbol, value: string(r)})
+				i++
+			}
+		default:
+			return nil, fmt.Errorf("unsupported character: %c", r)
+		}
+	}
+	return tokens, nil
+}
+
+type fhirPathParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *fhirPathParser) peek(offset int) *token {
+	if p.pos+offset >= len(p.tokens) {
+		return nil
+	}
+	return &p.tokens[p.pos+offset]
+}
+
+func (p *fhirPathParser) accept(kind tokenKind, value string) bool {
+	if t := p.peek(0); t != nil && t.kind == kind && t.value == value {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *fhirPathParser) expect(kind tokenKind, value string) error {
+	if !p.accept(kind, value) {
+		return fmt.Errorf("expected %s", value)
+	}
+	return nil
+}
+
+func (p *fhirPathParser) parseImplies() (expression, error) {
+	left, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept(tokenIdentifier, "implies") {
+		return left, nil
+	}
+	right, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	return impliesExpression{left: left, right: right}, nil
+}
+
+func (p *fhirPathParser) parseOr() (expression, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(tokenIdentifier, "or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orExpression{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *fhirPathParser) parseAnd() (expression, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(tokenIdentifier, "and") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andExpression{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *fhirPathParser) parseUnary() (expression, error) {
+	var result expression
+	var err error
+	if p.accept(tokenSymbol, "(") {
+		if result, err = p.parseImplies(); err != nil {
+			return nil, err
+		}
+		if err = p.expect(tokenSymbol, ")"); err != nil {
+			return nil, err
+		}
+	} else if result, err = p.parseTerm(); err != nil {
+		return nil, err
+	}
+	for p.isFunctionCall("not") {
+		p.pos += 4
+		result = notExpression{operand: result}
+	}
+	return result, nil
+}
+
+// isFunctionCall returns whether the next tokens are a call to the given function without arguments, e.g. ".exists()".
+func (p *fhirPathParser) isFunctionCall(name string) bool {
+	dot, ident, open, closing := p.peek(0), p.peek(1), p.peek(2), p.peek(3)
+	return dot != nil && dot.kind == tokenSymbol && dot.value == "." &&
+		ident != nil && ident.kind == tokenIdentifier && ident.value == name &&
+		open != nil && open.kind == tokenSymbol && open.value == "(" &&
+		closing != nil && closing.kind == tokenSymbol && closing.value == ")"
+}
+
+func (p *fhirPathParser) parseTerm() (expression, error) {
+	var elementPath []string
+	for {
+		t := p.peek(0)
+		if t == nil || t.kind != tokenIdentifier || isKeyword(t.value) {
+			return nil, fmt.Errorf("expected element name")
+		}
+		elementPath = append(elementPath, t.value)
+		p.pos++
+		next, afterNext := p.peek(1), p.peek(2)
+		if p.peek(0) == nil || p.peek(0).value != "." || next == nil || next.kind != tokenIdentifier ||
+			(afterNext != nil && afterNext.kind == tokenSymbol && afterNext.value == "(") {
+			break
+		}
+		p.pos++
+	}
+	switch {
+	case p.isFunctionCall("exists"):
+		p.pos += 4
+		return existsExpression{path: elementPath}, nil
+	case p.isFunctionCall("empty"):
+		p.pos += 4
+		return notExpression{operand: existsExpression{path: elementPath}}, nil
+	case p.isFunctionCall("count"):
+		p.pos += 4
+		operator, literal, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		count, ok := literal.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("count() must be compared to a number")
+		}
+		return countExpression{path: elementPath, operator: operator, value: count}, nil
+	}
+	operator, literal, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	return comparisonExpression{path: elementPath, operator: operator, value: literal}, nil
+}
+
+func (p *fhirPathParser) parseComparison() (string, any, error) {
+	operator := p.peek(0)
+	if operator == nil || operator.kind != tokenSymbol || !strings.Contains(" = != < <= > >= ", " "+operator.value+" ") {
+		return "", nil, fmt.Errorf("expected comparison operator")
+	}
+	p.pos++
+	literal := p.peek(0)
+	if literal == nil {
+		return "", nil, fmt.Errorf("expected literal")
+	}
+	p.pos++
+	switch {
+	case literal.kind == tokenString:
+		return operator.value, literal.value, nil
+	case literal.kind == tokenNumber:
+		return operator.value, json.Number(literal.value), nil
+	case literal.kind == tokenIdentifier && (literal.value == "true" || literal.value == "false"):
+		return operator.value, literal.value == "true", nil
+	}
+	return "", nil, fmt.Errorf("unsupported literal: %s", literal.value)
+}
+
+func isKeyword(value string) bool {
+	switch value {
+	case "and", "or", "xor", "implies", "true", "false":
+		return true
+	}
+	return false
+}
+
+type existsExpression struct {
+	path []string
+}
+
+func (e existsExpression) evaluate(context []node) *bool {
+	return boolPtr(len(navigate(context, e.path)) > 0)
+}
+
+type countExpression struct {
+	path     []string
+	operator string
+	value    json.Number
+}
+
+func (e countExpression) evaluate(context []node) *bool {
+	return compare(json.Number(strconv.Itoa(len(navigate(context, e.path)))), e.operator, e.value)
+}
+
+type comparisonExpression struct {
+	path     []string
+	operator string
+	value    any
+}
+
+func (e comparisonExpression) evaluate(context []node) *bool {
+	values := navigate(context, e.path)
+	if len(values) != 1 {
+		return nil
+	}
+	return compare(values[0].value, e.operator, e.value)
+}
+
+type notExpression struct {
+	operand expression
+}
+
+func (e notExpression) evaluate(context []node) *bool {
+	result := e.operand.evaluate(context)
+	if result == nil {
+		return nil
+	}
+	return boolPtr(!*result)
+}
+
+type andExpression struct {
+	left, right expression
+}
+
+func (e andExpression) evaluate(context []node) *bool {
+	left, right := e.left.evaluate(context), e.right.evaluate(context)
+	if (left != nil && !*left) || (right != nil && !*right) {
+		return boolPtr(false)
+	}
+	if left == nil || right == nil {
+		return nil
+	}
+	return boolPtr(true)
+}
+
+type orExpression struct {
+	left, right expression
+}
+
+func (e orExpression) evaluate(context []node) *bool {
+	left, right := e.left.evaluate(context), e.right.evaluate(context)
+	if (left != nil && *left) || (right != nil && *right) {
+		return boolPtr(true)
+	}
+	if left == nil || right == nil {
+		return nil
+	}
+	return boolPtr(false)
+}
+
+type impliesExpression struct {
+	left, right expression
+}
+
+func (e impliesExpression) evaluate(context []node) *bool {
+	left := e.left.evaluate(context)
+	if left != nil && !*left {
+		return boolPtr(true)
+	}
+	right := e.right.evaluate(context)
+	if left == nil && (right == nil || !*right) {
+		return nil
+	}
+	return right
+}
+
+// compare compares a JSON value to a literal. It returns nil if the values can't be compared (e.g. a string to a number).
+func compare(value any, operator string, literal any) *bool {
+	var order int
+	switch l := literal.(type) {
+	case string:
+		v, ok := value.(string)
+		if !ok {
+			return nil
+		}
+		order = strings.Compare(v, l)
+	case json.Number:
+		v, ok := value.(json.Number)
+		if !ok {
+			return nil
+		}
+		left, err1 := v.Float64()
+		right, err2 := l.Float64()
+		if err1 != nil || err2 != nil {
+			return nil
+		}
+		if left < right {
+			order = -1
+		} else if left > right {
+			order = 1
+		}
+	case bool:
+		v, ok := value.(bool)
+		if !ok || (operator != "=" && operator != "!=") {
+			return nil
+		}
+		if v != l {
+			order = 1
+		}
+	default:
+		return nil
+	}
+	switch operator {
+	case "=":
+		return boolPtr(order == 0)
+	case "!=":
+		return boolPtr(order != 0)
+	case "<":
+		return boolPtr(order < 0)
+	case "<=":
+		return boolPtr(order <= 0)
+	case ">":
+		return boolPtr(order > 0)
+	case ">=":
+		return boolPtr(order >= 0)
+	}
+	return nil
+}
+
+func boolPtr(value bool) *bool {
+	return &value
+}
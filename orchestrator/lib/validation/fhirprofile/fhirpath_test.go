@@ -0,0 +1,72 @@
+package fhirprofile
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseFHIRPath(t *testing.T) {
+	resource := `{
+		"status": "completed",
+		"priority": 2,
+		"active": true,
+		"output": [{"type": {"text": "a"}}, {"type": {"text": "b"}}],
+		"valueString": "foo"
+	}`
+	decoder := json.NewDecoder(strings.NewReader(resource))
+	decoder.UseNumber()
+	var value map[string]any
+	require.NoError(t, decoder.Decode(&value))
+	context := []node{{value: value, expression: "Task"}}
+
+	tests := []struct {
+		expression string
+		expected   *bool
+	}{
+		{expression: "status.exists()", expected: boolPtr(true)},
+		{expression: "statusReason.exists()", expected: boolPtr(false)},
+		{expression: "statusReason.empty()", expected: boolPtr(true)},
+		{expression: "output.type.exists()", expected: boolPtr(true)},
+		{expression: "output.count() > 1", expected: boolPtr(true)},
+		{expression: "output.count() = 1", expected: boolPtr(false)},
+		{expression: "value.exists()", expected: boolPtr(true)},
+		{expression: "status = 'completed'", expected: boolPtr(true)},
+		{expression: "status != 'completed'", expected: boolPtr(false)},
+		{expression: "priority >= 2", expected: boolPtr(true)},
+		{expression: "active = true", expected: boolPtr(true)},
+		{expression: "statusReason = 'foo'", expected: nil},
+		{expression: "status = 'completed' implies output.exists()", expected: boolPtr(true)},
+		{expression: "status = 'completed' implies statusReason.exists()", expected: boolPtr(false)},
+		{expression: "status = 'failed' implies statusReason.exists()", expected: boolPtr(true)},
+		{expression: "statusReason = 'foo' implies status.exists()", expected: boolPtr(true)},
+		{expression: "statusReason.exists() or status.exists()", expected: boolPtr(true)},
+		{expression: "statusReason.exists() and status.exists()", expected: boolPtr(false)},
+		{expression: "(statusReason.exists() or status.exists()).not()", expected: boolPtr(false)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			expr, err := parseFHIRPath(tt.expression)
+			require.NoError(t, err)
+
+			actual := expr.evaluate(context)
+
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+	t.Run("unsupported expressions", func(t *testing.T) {
+		for _, expression := range []string{
+			"hasValue() or (children().count() > id.count())",
+			"output.where(type.exists()).exists()",
+			"status",
+			"extension.exists() != value.exists()",
+			"%resource.status.exists()",
+			"status.exists() xor output.exists()",
+		} {
+			_, err := parseFHIRPath(expression)
+			require.Error(t, err, expression)
+		}
+	})
+}
@@ -0,0 +1,206 @@
+// Package fhirprofile validates FHIR resources against the profiles (StructureDefinitions) of FHIR packages,
+// e.g. the Shared Care Planning Implementation Guide.
+//
+// It supports a pragmatic subset of FHIR profile validation: cardinality, fixed and pattern values,
+// required bindings to ValueSets that can be expanded from the loaded packages, and invariants
+// that only use a simple subset of FHIRPath (see parseFHIRPath). Slices and invariants that can't be evaluated are skipped.
+package fhirprofile
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// Package contains the conformance resources of a FHIR package (NPM package, in .tgz form).
+type Package struct {
+	structureDefinitions []structureDefinition
+	valueSets            []valueSetResource
+	codeSystems          []codeSystemResource
+}
+
+// LoadPackage loads the FHIR package (.tgz) at the given path.
+func LoadPackage(fileName string) (*Package, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("FHIR package %s: %w", fileName, err)
+	}
+	defer f.Close()
+	result, err := ReadPackage(f)
+	if err != nil {
+		return nil, fmt.Errorf("FHIR package %s: %w", fileName, err)
+	}
+	return result, nil
+}
+
+// ReadPackage reads a FHIR package (.tgz) from the given reader.
+// Only the StructureDefinitions, ValueSets and CodeSystems in the package are read, other files are ignored.
+func ReadPackage(reader io.Reader) (*Package, error) {
+	gzipReader, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	defer gzipReader.Close()
+	tarReader := tar.NewReader(gzipReader)
+	result := &Package{}
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg || path.Ext(header.Name) != ".json" ||
+			path.Base(header.Name) == "package.json" || strings.HasPrefix(path.Base(header.Name), ".") {
+			continue
+		}
+		data, err := io.ReadAll(tarReader)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", header.Name, err)
+		}
+		if err := result.add(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", header.Name, err)
+		}
+	}
+	return result, nil
+}
+
+func (p *Package) add(data []byte) error {
+	var resource struct {
+		ResourceType string `json:"resourceType"`
+	}
+	if err := json.Unmarshal(data, &resource); err != nil {
+		return err
+	}
+	var target any
+	switch resource.ResourceType {
+	case "StructureDefinition":
+		p.structureDefinitions = append(p.structureDefinitions, structureDefinition{})
+		target = &p.structureDefinitions[len(p.structureDefinitions)-1]
+	case "ValueSet":
+		p.valueSets = append(p.valueSets, valueSetResource{})
+		target = &p.valueSets[len(p.valueSets)-1]
+	case "CodeSystem":
+		p.codeSystems = append(p.codeSystems, codeSystemResource{})
+		target = &p.codeSystems[len(p.codeSystems)-1]
+	default:
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(target)
+}
+
+type structureDefinition struct {
+	URL        string `json:"url"`
+	Type       string `json:"type"`
+	Derivation string `json:"derivation"`
+	Snapshot   *struct {
+		Element []elementDefinition `json:"element"`
+	} `json:"snapshot"`
+	Differential *struct {
+		Element []elementDefinition `json:"element"`
+	} `json:"differential"`
+}
+
+// elements returns the element definitions of the profile, preferring the snapshot.
+// The differential is only used when the package doesn't contain snapshots.
+func (s structureDefinition) elements() []elementDefinition {
+	if s.Snapshot != nil {
+		return s.Snapshot.Element
+	}
+	if s.Differential != nil {
+		return s.Differential.Element
+	}
+	return nil
+}
+
+type elementDefinition struct {
+	ID               string  `json:"id"`
+	Path             string  `json:"path"`
+	SliceName        string  `json:"sliceName"`
+	Min              *int    `json:"min"`
+	Max              *string `json:"max"`
+	ContentReference string  `json:"contentReference"`
+	Binding          *struct {
+		Strength string `json:"strength"`
+		ValueSet string `json:"valueSet"`
+	} `json:"binding"`
+	Constraint []struct {
+		Key        string `json:"key"`
+		Severity   string `json:"severity"`
+		Human      string `json:"human"`
+		Expression string `json:"expression"`
+	} `json:"constraint"`
+	// Fixed contains the value of fixed[x], if specified.
+	Fixed any `json:"-"`
+	// Pattern contains the value of pattern[x], if specified.
+	Pattern any `json:"-"`
+}
+
+func (e *elementDefinition) UnmarshalJSON(data []byte) error {
+	type plain elementDefinition
+	if err := json.Unmarshal(data, (*plain)(e)); err != nil {
+		return err
+	}
+	var properties map[string]json.RawMessage
+	if err := json.Unmarshal(data, &properties); err != nil {
+		return err
+	}
+	for name, value := range properties {
+		var target *any
+		if strings.HasPrefix(name, "fixed") {
+			target = &e.Fixed
+		} else if strings.HasPrefix(name, "pattern") {
+			target = &e.Pattern
+		} else {
+			continue
+		}
+		decoder := json.NewDecoder(bytes.NewReader(value))
+		decoder.UseNumber()
+		if err := decoder.Decode(target); err != nil {
+			return fmt.Errorf("element %s: %s: %w", e.ID, name, err)
+		}
+	}
+	return nil
+}
+
+type valueSetResource struct {
+	URL     string `json:"url"`
+	Compose *struct {
+		Include []struct {
+			System   string            `json:"system"`
+			Concept  []codeSystemCode  `json:"concept"`
+			Filter   []json.RawMessage `json:"filter"`
+			ValueSet []string          `json:"valueSet"`
+		} `json:"include"`
+		Exclude []json.RawMessage `json:"exclude"`
+	} `json:"compose"`
+	Expansion *struct {
+		Contains []valueSetContains `json:"contains"`
+	} `json:"expansion"`
+}
+
+type valueSetContains struct {
+	System   string             `json:"system"`
+	Code     string             `json:"code"`
+	Contains []valueSetContains `json:"contains"`
+}
+
+type codeSystemResource struct {
+	URL     string           `json:"url"`
+	Content string           `json:"content"`
+	Concept []codeSystemCode `json:"concept"`
+}
+
+type codeSystemCode struct {
+	Code    string           `json:"code"`
+	Concept []codeSystemCode `json:"concept"`
+}
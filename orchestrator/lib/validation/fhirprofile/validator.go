@@ -0,0 +1,455 @@
+package fhirprofile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+// Issue is a validation issue of a resource, which can be reported as OperationOutcome issue.
+type Issue struct {
+	Severity fhir.IssueSeverity
+	Code     fhir.IssueType
+	// Expression is the FHIRPath expression of the invalid element, e.g. Task.input[0].type.
+	Expression  string
+	Diagnostics string
+}
+
+// Validator validates resources against the profiles of loaded FHIR packages.
+type Validator struct {
+	profiles map[string]*profile
+	// requiredProfiles contains the profiles (canonical URLs) per resource type that resources must conform to,
+	// regardless of whether they claim conformance in meta.profile.
+	requiredProfiles map[string][]string
+}
+
+type profile struct {
+	url          string
+	resourceType string
+	elements     []compiledElement
+}
+
+type compiledElement struct {
+	elementDefinition
+	// valueSet contains the codes of the ValueSet of a required binding, as "system|code" and "|code" (for code elements).
+	// It's nil if the element doesn't have a required binding, or its ValueSet can't be expanded from the loaded packages.
+	valueSet    map[string]bool
+	constraints []compiledConstraint
+}
+
+type compiledConstraint struct {
+	key        string
+	human      string
+	expression expression
+}
+
+// New creates a Validator for the profiles in the given packages. Resources are validated against the profiles they claim in meta.profile,
+// and the given required profiles (canonical URLs), which apply to all resources of the profile's type.
+// It returns an error if a required profile isn't found in the packages.
+func New(packages []*Package, requiredProfiles []string) (*Validator, error) {
+	valueSets := expandValueSets(packages)
+	result := &Validator{
+		profiles:         map[string]*profile{},
+		requiredProfiles: map[string][]string{},
+	}
+	for _, pkg := range packages {
+		for _, structureDefinition := range pkg.structureDefinitions {
+			if structureDefinition.URL == "" || structureDefinition.Type == "" {
+				continue
+			}
+			result.profiles[structureDefinition.URL] = compileProfile(structureDefinition, valueSets)
+		}
+	}
+	for _, url := range requiredProfiles {
+		p, ok := result.profiles[url]
+		if !ok {
+			return nil, fmt.Errorf("required profile not found in FHIR packages: %s", url)
+		}
+		result.requiredProfiles[p.resourceType] = append(result.requiredProfiles[p.resourceType], url)
+	}
+	return result, nil
+}
+
+// Load loads the FHIR packages (.tgz) at the given paths and creates a Validator for them (see New).
+func Load(packageFiles []string, requiredProfiles []string) (*Validator, error) {
+	var packages []*Package
+	for _, fileName := range packageFiles {
+		pkg, err := LoadPackage(fileName)
+		if err != nil {
+			return nil, err
+		}
+		packages = append(packages, pkg)
+	}
+	return New(packages, requiredProfiles)
+}
+
+func compileProfile(structureDefinition structureDefinition, valueSets map[string]map[string]bool) *profile {
+	result := &profile{
+		url:          structureDefinition.URL,
+		resourceType: structureDefinition.Type,
+	}
+	for _, element := range structureDefinition.elements() {
+		// Slices (and their children) can't be validated without evaluating the slice discriminator, so they're skipped
+		if element.SliceName != "" || strings.Contains(element.ID, ":") || element.ContentReference != "" {
+			continue
+		}
+		compiled := compiledElement{elementDefinition: element}
+		if element.Binding != nil && element.Binding.Strength == "required" {
+			compiled.valueSet = valueSets[canonicalWithoutVersion(element.Binding.ValueSet)]
+		}
+		for _, constraint := range element.Constraint {
+			if constraint.Severity != "error" || constraint.Expression == "" {
+				continue
+			}
+			expr, err := parseFHIRPath(constraint.Expression)
+			if err != nil {
+				// Not in the supported subset of FHIRPath
+				continue
+			}
+			compiled.constraints = append(compiled.constraints, compiledConstraint{
+				key:        constraint.Key,
+				human:      constraint.Human,
+				expression: expr,
+			})
+		}
+		result.elements = append(result.elements, compiled)
+	}
+	return result
+}
+
+// Validate validates the resource (JSON) against the profiles that apply to it.
+// Profiles claimed in meta.profile that aren't loaded are ignored.
+func (v *Validator) Validate(resourceJSON []byte) ([]Issue, error) {
+	decoder := json.NewDecoder(bytes.NewReader(resourceJSON))
+	decoder.UseNumber()
+	var resource map[string]any
+	if err := decoder.Decode(&resource); err != nil {
+		return nil, err
+	}
+	resourceType, _ := resource["resourceType"].(string)
+	if resourceType == "" {
+		return nil, fmt.Errorf("resource has no resourceType")
+	}
+
+	var profileURLs []string
+	profileURLs = append(profileURLs, v.requiredProfiles[resourceType]...)
+	if meta, ok := resource["meta"].(map[string]any); ok {
+		claimed, _ := meta["profile"].([]any)
+		for _, url := range claimed {
+			if urlString, ok := url.(string); ok {
+				profileURLs = append(profileURLs, canonicalWithoutVersion(urlString))
+			}
+		}
+	}
+
+	var issues []Issue
+	validated := map[string]bool{}
+	for _, url := range profileURLs {
+		p, ok := v.profiles[url]
+		if !ok || validated[url] {
+			continue
+		}
+		validated[url] = true
+		if p.resourceType != resourceType {
+			issues = append(issues, Issue{
+				Severity:    fhir.IssueSeverityError,
+				Code:        fhir.IssueTypeStructure,
+				Expression:  resourceType,
+				Diagnostics: fmt.Sprintf("profile %s applies to %s, not %s", url, p.resourceType, resourceType),
+			})
+			continue
+		}
+		issues = append(issues, p.validate(node{value: resource, expression: resourceType})...)
+	}
+	return issues, nil
+}
+
+func (p *profile) validate(root node) []Issue {
+	var issues []Issue
+	for _, element := range p.elements {
+		segments := strings.Split(element.Path, ".")
+		if len(segments) == 1 {
+			issues = append(issues, element.validateValues(p, []node{root})...)
+			continue
+		}
+		name := segments[len(segments)-1]
+		for _, parent := range navigate([]node{root}, segments[1:len(segments)-1]) {
+			if _, isObject := parent.value.(map[string]any); !isObject {
+				continue
+			}
+			values := navigate([]node{parent}, []string{name})
+			expression := parent.expression + "." + strings.TrimSuffix(name, "[x]")
+			if element.Min != nil && len(values) < *element.Min {
+				issues = append(issues, Issue{
+					Severity:    fhir.IssueSeverityError,
+					Code:        fhir.IssueTypeRequired,
+					Expression:  expression,
+					Diagnostics: fmt.Sprintf("%s: minimum required = %d, but only found %d (profile %s)", element.Path, *element.Min, len(values), p.url),
+				})
+			}
+			if element.Max != nil && *element.Max != "*" {
+				if max, err := strconv.Atoi(*element.Max); err == nil && len(values) > max {
+					issues = append(issues, Issue{
+						Severity:    fhir.IssueSeverityError,
+						Code:        fhir.IssueTypeStructure,
+						Expression:  expression,
+						Diagnostics: fmt.Sprintf("%s: maximum allowed = %d, but found %d (profile %s)", element.Path, max, len(values), p.url),
+					})
+				}
+			}
+			issues = append(issues, element.validateValues(p, values)...)
+		}
+	}
+	return issues
+}
+
+// validateValues validates the values of an element against its fixed value, pattern, binding and constraints.
+func (e compiledElement) validateValues(p *profile, values []node) []Issue {
+	var issues []Issue
+	for _, value := range values {
+		if e.Fixed != nil && !reflect.DeepEqual(value.value, e.Fixed) {
+			issues = append(issues, Issue{
+				Severity:    fhir.IssueSeverityError,
+				Code:        fhir.IssueTypeValue,
+				Expression:  value.expression,
+				Diagnostics: fmt.Sprintf("%s: value must be exactly %s (profile %s)", e.Path, toJSON(e.Fixed), p.url),
+			})
+		}
+		if e.Pattern != nil && !matchesPattern(value.value, e.Pattern) {
+			issues = append(issues, Issue{
+				Severity:    fhir.IssueSeverityError,
+				Code:        fhir.IssueTypeValue,
+				Expression:  value.expression,
+				Diagnostics: fmt.Sprintf("%s: value must match %s (profile %s)", e.Path, toJSON(e.Pattern), p.url),
+			})
+		}
+		if e.valueSet != nil {
+			if code, ok := inValueSet(value.value, e.valueSet); !ok {
+				issues = append(issues, Issue{
+					Severity:    fhir.IssueSeverityError,
+					Code:        fhir.IssueTypeCodeInvalid,
+					Expression:  value.expression,
+					Diagnostics: fmt.Sprintf("%s: code %s is not in value set %s (profile %s)", e.Path, code, e.Binding.ValueSet, p.url),
+				})
+			}
+		}
+		for _, constraint := range e.constraints {
+			if result := constraint.expression.evaluate([]node{value}); result != nil && !*result {
+				issues = append(issues, Issue{
+					Severity:    fhir.IssueSeverityError,
+					Code:        fhir.IssueTypeInvariant,
+					Expression:  value.expression,
+					Diagnostics: fmt.Sprintf("constraint %s failed: %s (profile %s)", constraint.key, constraint.human, p.url),
+				})
+			}
+		}
+	}
+	return issues
+}
+
+// node is a value in a resource, with the FHIRPath expression that selects it.
+type node struct {
+	value      any
+	expression string
+}
+
+// navigate returns the values of the given element path, relative to the given nodes. Array values are flattened.
+// Choice elements (e.g. value[x]) are matched by their type-specific name (e.g. valueString), also if the [x] suffix is omitted.
+func navigate(nodes []node, elementPath []string) []node {
+	for _, name := range elementPath {
+		var next []node
+		for _, current := range nodes {
+			object, ok := current.value.(map[string]any)
+			if !ok {
+				continue
+			}
+			for _, key := range matchingKeys(object, strings.TrimSuffix(name, "[x]")) {
+				expression := current.expression + "." + key
+				if array, isArray := object[key].([]any); isArray {
+					for i, item := range array {
+						next = append(next, node{value: item, expression: expression + "[" + strconv.Itoa(i) + "]"})
+					}
+				} else if object[key] != nil {
+					next = append(next, node{value: object[key], expression: expression})
+				}
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+func matchingKeys(object map[string]any, name string) []string {
+	if _, ok := object[name]; ok {
+		return []string{name}
+	}
+	var result []string
+	for key := range object {
+		if strings.HasPrefix(key, name) && choiceTypes[key[len(name):]] {
+			result = append(result, key)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// choiceTypes contains the (capitalized) data types that can be used for choice elements (e.g. valueString).
+var choiceTypes = map[string]bool{}
+
+func init() {
+	for _, dataType := range strings.Fields(`Base64Binary Boolean Canonical Code Date DateTime Decimal Id Instant Integer Markdown Oid PositiveInt String Time
+		UnsignedInt Uri Url Uuid Address Age Annotation Attachment CodeableConcept Coding ContactPoint Count Distance Duration HumanName
+		Identifier Money Period Quantity Range Ratio Reference SampledData Signature Timing ContactDetail Contributor DataRequirement
+		Expression ParameterDefinition RelatedArtifact TriggerDefinition UsageContext Dosage Meta`) {
+		choiceTypes[dataType] = true
+	}
+}
+
+// matchesPattern returns whether the value contains all properties of the pattern.
+// For arrays, each pattern item must be matched by an item of the value.
+func matchesPattern(value any, pattern any) bool {
+	switch p := pattern.(type) {
+	case map[string]any:
+		v, ok := value.(map[string]any)
+		if !ok {
+			return false
+		}
+		for key, patternValue := range p {
+			if !matchesPattern(v[key], patternValue) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		v, ok := value.([]any)
+		if !ok {
+			return false
+		}
+		for _, patternItem := range p {
+			matched := false
+			for _, item := range v {
+				if matchesPattern(item, patternItem) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(value, pattern)
+	}
+}
+
+// inValueSet returns whether the code, Coding or CodeableConcept is in the expanded ValueSet.
+// It also returns the (first) code that was checked, for reporting.
+func inValueSet(value any, valueSet map[string]bool) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, valueSet["|"+v]
+	case map[string]any:
+		if codings, ok := v["coding"].([]any); ok {
+			var first string
+			for _, coding := range codings {
+				code, ok := inValueSet(coding, valueSet)
+				if ok {
+					return code, true
+				}
+				if first == "" {
+					first = code
+				}
+			}
+			return first, false
+		}
+		system, _ := v["system"].(string)
+		code, _ := v["code"].(string)
+		return system + "|" + code, code != "" && valueSet[system+"|"+code]
+	}
+	return fmt.Sprintf("%v", value), false
+}
+
+// expandValueSets returns the codes of the ValueSets in the packages that can be expanded without a terminology server:
+// ValueSets with an expansion, or that only include codes enumerated in the ValueSet or complete CodeSystems in the packages.
+func expandValueSets(packages []*Package) map[string]map[string]bool {
+	codeSystems := map[string]codeSystemResource{}
+	for _, pkg := range packages {
+		for _, codeSystem := range pkg.codeSystems {
+			if codeSystem.Content == "complete" {
+				codeSystems[codeSystem.URL] = codeSystem
+			}
+		}
+	}
+	result := map[string]map[string]bool{}
+	for _, pkg := range packages {
+		for _, valueSet := range pkg.valueSets {
+			if codes := expandValueSet(valueSet, codeSystems); codes != nil {
+				result[valueSet.URL] = codes
+			}
+		}
+	}
+	return result
+}
+
+func expandValueSet(valueSet valueSetResource, codeSystems map[string]codeSystemResource) map[string]bool {
+	codes := map[string]bool{}
+	add := func(system, code string) {
+		codes[system+"|"+code] = true
+		codes["|"+code] = true
+	}
+	if valueSet.Expansion != nil && len(valueSet.Expansion.Contains) > 0 {
+		var addContains func(contains []valueSetContains)
+		addContains = func(contains []valueSetContains) {
+			for _, c := range contains {
+				if c.Code != "" {
+					add(c.System, c.Code)
+				}
+				addContains(c.Contains)
+			}
+		}
+		addContains(valueSet.Expansion.Contains)
+		return codes
+	}
+	if valueSet.Compose == nil || len(valueSet.Compose.Include) == 0 || len(valueSet.Compose.Exclude) > 0 {
+		return nil
+	}
+	for _, include := range valueSet.Compose.Include {
+		if len(include.Filter) > 0 || len(include.ValueSet) > 0 || include.System == "" {
+			return nil
+		}
+		concepts := include.Concept
+		if len(concepts) == 0 {
+			codeSystem, ok := codeSystems[include.System]
+			if !ok {
+				return nil
+			}
+			concepts = codeSystem.Concept
+		}
+		var addConcepts func(concepts []codeSystemCode)
+		addConcepts = func(concepts []codeSystemCode) {
+			for _, concept := range concepts {
+				add(include.System, concept.Code)
+				addConcepts(concept.Concept)
+			}
+		}
+		addConcepts(concepts)
+	}
+	return codes
+}
+
+func canonicalWithoutVersion(canonical string) string {
+	url, _, _ := strings.Cut(canonical, "|")
+	return url
+}
+
+func toJSON(value any) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
@@ -0,0 +1,304 @@
+package fhirprofile
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/SanteonNL/orca/orchestrator/lib/must"
+	"github.com/stretchr/testify/require"
+	"github.com/zorgbijjou/golang-fhir-models/fhir-models/fhir"
+)
+
+const testProfileURL = "http://example.com/fhir/StructureDefinition/test-task"
+
+// testPackage returns a FHIR package (.tgz) with a Task profile, and the ValueSets and CodeSystems its bindings refer to.
+func testPackage(t *testing.T) []byte {
+	resources := map[string]any{
+		"package/package.json": map[string]any{"name": "test.package", "version": "1.0.0"},
+		"package/StructureDefinition-test-task.json": map[string]any{
+			"resourceType": "StructureDefinition",
+			"url":          testProfileURL,
+			"type":         "Task",
+			"derivation":   "constraint",
+			"snapshot": map[string]any{
+				"element": []any{
+					map[string]any{"id": "Task", "path": "Task", "min": 0, "max": "*", "constraint": []any{
+						map[string]any{"key": "tsk-1", "severity": "error", "human": "Completed Task must have output", "expression": "status = 'completed' implies output.exists()"},
+						map[string]any{"key": "tsk-2", "severity": "warning", "human": "Task should have a description", "expression": "description.exists()"},
+						map[string]any{"key": "ele-1", "severity": "error", "human": "All FHIR elements must have a @value or children", "expression": "hasValue() or (children().count() > id.count())"},
+					}},
+					map[string]any{"id": "Task.status", "path": "Task.status", "min": 1, "max": "1", "binding": map[string]any{
+						"strength": "required", "valueSet": "http://example.com/fhir/ValueSet/task-status|1.0.0",
+					}},
+					map[string]any{"id": "Task.intent", "path": "Task.intent", "min": 1, "max": "1", "fixedCode": "order"},
+					map[string]any{"id": "Task.priority", "path": "Task.priority", "min": 0, "max": "1", "binding": map[string]any{
+						"strength": "required", "valueSet": "http://example.com/fhir/ValueSet/task-priority",
+					}},
+					map[string]any{"id": "Task.code", "path": "Task.code", "min": 1, "max": "1", "patternCodeableConcept": map[string]any{
+						"coding": []any{map[string]any{"system": "http://snomed.info/sct", "code": "508311000146104"}},
+					}},
+					map[string]any{"id": "Task.businessStatus", "path": "Task.businessStatus", "min": 0, "max": "1", "binding": map[string]any{
+						"strength": "required", "valueSet": "http://example.com/fhir/ValueSet/business-status",
+					}},
+					map[string]any{"id": "Task.note", "path": "Task.note", "min": 0, "max": "0"},
+					map[string]any{"id": "Task.input", "path": "Task.input", "min": 0, "max": "*"},
+					map[string]any{"id": "Task.input:questionnaire", "path": "Task.input", "sliceName": "questionnaire", "min": 5, "max": "*"},
+					map[string]any{"id": "Task.input.type", "path": "Task.input.type", "min": 1, "max": "1"},
+					map[string]any{"id": "Task.input.value[x]", "path": "Task.input.value[x]", "min": 1, "max": "1"},
+				},
+			},
+		},
+		"package/ValueSet-task-status.json": map[string]any{
+			"resourceType": "ValueSet",
+			"url":          "http://example.com/fhir/ValueSet/task-status",
+			"compose": map[string]any{"include": []any{map[string]any{
+				"system":  "http://hl7.org/fhir/task-status",
+				"concept": []any{map[string]any{"code": "requested"}, map[string]any{"code": "accepted"}, map[string]any{"code": "completed"}},
+			}}},
+		},
+		"package/ValueSet-task-priority.json": map[string]any{
+			"resourceType": "ValueSet",
+			"url":          "http://example.com/fhir/ValueSet/task-priority",
+			"compose": map[string]any{"include": []any{map[string]any{
+				"system": "http://example.com/fhir/CodeSystem/task-priority",
+			}}},
+		},
+		"package/CodeSystem-task-priority.json": map[string]any{
+			"resourceType": "CodeSystem",
+			"url":          "http://example.com/fhir/CodeSystem/task-priority",
+			"content":      "complete",
+			"concept":      []any{map[string]any{"code": "routine", "concept": []any{map[string]any{"code": "urgent"}}}},
+		},
+		"package/ValueSet-business-status.json": map[string]any{
+			"resourceType": "ValueSet",
+			"url":          "http://example.com/fhir/ValueSet/business-status",
+			"compose": map[string]any{"include": []any{map[string]any{
+				"system": "http://snomed.info/sct",
+				"filter": []any{map[string]any{"property": "concept", "op": "is-a", "value": "123"}},
+			}}},
+		},
+		"package/other/ignored.txt": "not a FHIR resource",
+	}
+	buf := new(bytes.Buffer)
+	gzipWriter := gzip.NewWriter(buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for name, resource := range resources {
+		data := []byte(must.MarshalJSON(resource))
+		if text, ok := resource.(string); ok {
+			data = []byte(text)
+		}
+		require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}))
+		_, err := tarWriter.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tarWriter.Close())
+	require.NoError(t, gzipWriter.Close())
+	return buf.Bytes()
+}
+
+func validTestTask() map[string]any {
+	return map[string]any{
+		"resourceType": "Task",
+		"meta":         map[string]any{"profile": []any{testProfileURL + "|1.0.0"}},
+		"status":       "accepted",
+		"intent":       "order",
+		"priority":     "urgent",
+		"code": map[string]any{
+			"coding": []any{map[string]any{"system": "http://snomed.info/sct", "code": "508311000146104", "display": "Telemonitoring"}},
+			"text":   "Telemonitoring",
+		},
+		"businessStatus": map[string]any{"text": "anything goes, ValueSet can't be expanded"},
+		"input": []any{
+			map[string]any{"type": map[string]any{"text": "a"}, "valueString": "a"},
+		},
+	}
+}
+
+func TestValidator_Validate(t *testing.T) {
+	pkg, err := ReadPackage(bytes.NewReader(testPackage(t)))
+	require.NoError(t, err)
+	validator, err := New([]*Package{pkg}, nil)
+	require.NoError(t, err)
+
+	t.Run("valid", func(t *testing.T) {
+		issues, err := validator.Validate([]byte(must.MarshalJSON(validTestTask())))
+
+		require.NoError(t, err)
+		require.Empty(t, issues)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		tests := []struct {
+			name          string
+			modify        func(task map[string]any)
+			code          fhir.IssueType
+			expression    string
+			diagnostics   string
+			expectedCount int
+		}{
+			{
+				name:        "missing required element",
+				modify:      func(task map[string]any) { delete(task, "status") },
+				code:        fhir.IssueTypeRequired,
+				expression:  "Task.status",
+				diagnostics: "Task.status: minimum required = 1, but only found 0 (profile " + testProfileURL + ")",
+			},
+			{
+				name:        "code not in ValueSet",
+				modify:      func(task map[string]any) { task["status"] = "draft" },
+				code:        fhir.IssueTypeCodeInvalid,
+				expression:  "Task.status",
+				diagnostics: "Task.status: code draft is not in value set http://example.com/fhir/ValueSet/task-status|1.0.0 (profile " + testProfileURL + ")",
+			},
+			{
+				name:       "code not in ValueSet from CodeSystem",
+				modify:     func(task map[string]any) { task["priority"] = "asap" },
+				code:       fhir.IssueTypeCodeInvalid,
+				expression: "Task.priority",
+			},
+			{
+				name:        "fixed value",
+				modify:      func(task map[string]any) { task["intent"] = "plan" },
+				code:        fhir.IssueTypeValue,
+				expression:  "Task.intent",
+				diagnostics: `Task.intent: value must be exactly "order" (profile ` + testProfileURL + ")",
+			},
+			{
+				name: "pattern",
+				modify: func(task map[string]any) {
+					task["code"] = map[string]any{"coding": []any{map[string]any{"system": "http://snomed.info/sct", "code": "123"}}}
+				},
+				code:       fhir.IssueTypeValue,
+				expression: "Task.code",
+			},
+			{
+				name:        "prohibited element",
+				modify:      func(task map[string]any) { task["note"] = []any{map[string]any{"text": "note"}} },
+				code:        fhir.IssueTypeStructure,
+				expression:  "Task.note",
+				diagnostics: "Task.note: maximum allowed = 0, but found 1 (profile " + testProfileURL + ")",
+			},
+			{
+				name: "nested element",
+				modify: func(task map[string]any) {
+					task["input"] = append(task["input"].([]any), map[string]any{"valueString": "b"})
+				},
+				code:       fhir.IssueTypeRequired,
+				expression: "Task.input[1].type",
+			},
+			{
+				name: "choice element",
+				modify: func(task map[string]any) {
+					task["input"] = []any{map[string]any{"type": map[string]any{"text": "a"}}}
+				},
+				code:       fhir.IssueTypeRequired,
+				expression: "Task.input[0].value",
+			},
+			{
+				name:        "invariant",
+				modify:      func(task map[string]any) { task["status"] = "completed" },
+				code:        fhir.IssueTypeInvariant,
+				expression:  "Task",
+				diagnostics: "constraint tsk-1 failed: Completed Task must have output (profile " + testProfileURL + ")",
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				task := validTestTask()
+				tt.modify(task)
+
+				issues, err := validator.Validate([]byte(must.MarshalJSON(task)))
+
+				require.NoError(t, err)
+				require.Len(t, issues, 1)
+				require.Equal(t, fhir.IssueSeverityError, issues[0].Severity)
+				require.Equal(t, tt.code, issues[0].Code)
+				require.Equal(t, tt.expression, issues[0].Expression)
+				if tt.diagnostics != "" {
+					require.Equal(t, tt.diagnostics, issues[0].Diagnostics)
+				}
+			})
+		}
+	})
+	t.Run("profile not claimed", func(t *testing.T) {
+		task := validTestTask()
+		delete(task, "meta")
+		delete(task, "status")
+
+		issues, err := validator.Validate([]byte(must.MarshalJSON(task)))
+
+		require.NoError(t, err)
+		require.Empty(t, issues)
+	})
+	t.Run("unknown profile claimed", func(t *testing.T) {
+		task := validTestTask()
+		task["meta"] = map[string]any{"profile": []any{"http://example.com/fhir/StructureDefinition/other"}}
+		delete(task, "status")
+
+		issues, err := validator.Validate([]byte(must.MarshalJSON(task)))
+
+		require.NoError(t, err)
+		require.Empty(t, issues)
+	})
+	t.Run("profile of other resource type claimed", func(t *testing.T) {
+		issues, err := validator.Validate([]byte(`{"resourceType": "Patient", "meta": {"profile": ["` + testProfileURL + `"]}}`))
+
+		require.NoError(t, err)
+		require.Len(t, issues, 1)
+		require.Equal(t, fhir.IssueTypeStructure, issues[0].Code)
+	})
+	t.Run("required profile", func(t *testing.T) {
+		validator, err := New([]*Package{pkg}, []string{testProfileURL})
+		require.NoError(t, err)
+		task := validTestTask()
+		delete(task, "meta")
+		delete(task, "status")
+
+		issues, err := validator.Validate([]byte(must.MarshalJSON(task)))
+
+		require.NoError(t, err)
+		require.Len(t, issues, 1)
+		require.Equal(t, "Task.status", issues[0].Expression)
+	})
+	t.Run("invalid JSON", func(t *testing.T) {
+		_, err := validator.Validate([]byte(`{`))
+
+		require.Error(t, err)
+	})
+}
+
+func TestNew(t *testing.T) {
+	t.Run("unknown required profile", func(t *testing.T) {
+		_, err := New(nil, []string{testProfileURL})
+
+		require.EqualError(t, err, "required profile not found in FHIR packages: "+testProfileURL)
+	})
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		fileName := path.Join(t.TempDir(), "package.tgz")
+		require.NoError(t, os.WriteFile(fileName, testPackage(t), 0644))
+
+		validator, err := Load([]string{fileName}, []string{testProfileURL})
+
+		require.NoError(t, err)
+		require.Contains(t, validator.profiles, testProfileURL)
+	})
+	t.Run("file does not exist", func(t *testing.T) {
+		_, err := Load([]string{"does-not-exist.tgz"}, nil)
+
+		require.ErrorContains(t, err, "FHIR package does-not-exist.tgz")
+	})
+	t.Run("not a package", func(t *testing.T) {
+		fileName := path.Join(t.TempDir(), "package.tgz")
+		require.NoError(t, os.WriteFile(fileName, []byte(must.MarshalJSON(map[string]any{})), 0644))
+
+		_, err := Load([]string{fileName}, nil)
+
+		require.Error(t, err)
+	})
+}
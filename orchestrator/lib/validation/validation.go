@@ -3,3 +3,14 @@ package validation
 type Validator[T any] interface {
 	Validate(t T) []*Error
 }
+
+// Validators combines multiple validators into one, which returns the errors of all validators.
+type Validators[T any] []Validator[T]
+
+func (v Validators[T]) Validate(t T) []*Error {
+	var errs []*Error
+	for _, validator := range v {
+		errs = append(errs, validator.Validate(t)...)
+	}
+	return errs
+}
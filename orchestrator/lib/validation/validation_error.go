@@ -2,6 +2,10 @@ package validation
 
 type Error struct {
 	Code string
+	// Expression is the FHIRPath expression of the invalid element (e.g. Task.status), if the error applies to a specific element.
+	Expression string
+	// Diagnostics describes the error in a human-readable way, if available.
+	Diagnostics string
 }
 
 func (e *Error) Error() string {
@@ -222,3 +222,24 @@ func TestValidatorInterfaceCanBeImplementedByAnyType(t *testing.T) {
 		assert.Nil(t, validator3)
 	})
 }
+
+func TestValidators(t *testing.T) {
+	t.Run("should return errors of all validators", func(t *testing.T) {
+		validators := Validators[string]{
+			ConcreteValidator{validCodes: map[string]bool{"a": true}},
+			ConcreteValidator{validCodes: map[string]bool{"b": true}},
+		}
+
+		errors := validators.Validate("a")
+
+		assert.Len(t, errors, 1)
+		assert.Equal(t, "INVALID_CODE", errors[0].Code)
+	})
+	t.Run("should return no errors if all validators pass", func(t *testing.T) {
+		validators := Validators[string]{
+			ConcreteValidator{validCodes: map[string]bool{"a": true}},
+		}
+
+		assert.Empty(t, validators.Validate("a"))
+	})
+}